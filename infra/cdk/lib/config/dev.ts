@@ -83,6 +83,15 @@ export const devConfig = {
   // CORS Configuration
   cloudFrontDomain: 'https://d3065hze06690c.cloudfront.net',
 
+  // SEFAZ: base dos web services de autorização (ex.: https://homologacao.nfe.fazenda.sp.gov.br).
+  // Vazio desliga a transmissão; exige NAT Gateway e o certificado A1 nas Lambdas
+  sefazUrl: '',
+  // Contingência: base da SVC da UF (SVC-AN ou SVC-RS) e do Ambiente Nacional (EPEC)
+  sefazSvcUrl: '',
+  sefazEpecUrl: '',
+  // NFC-e: base do autorizador da NFC-e da UF; o CSC (NFCE_CSC) vem junto com o certificado
+  sefazNfceUrl: '',
+
   // CloudWatch Alarms Configuration
   alarms: {
     cpuThreshold: 80,
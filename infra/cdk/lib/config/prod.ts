@@ -85,6 +85,15 @@ export const prodConfig: InfraConfig = {
   // CORS Configuration
   cloudFrontDomain: 'https://nfe.meudominio.com', // Update with actual production domain
 
+  // SEFAZ: base dos web services de autorização (ex.: https://nfe.fazenda.sp.gov.br).
+  // Vazio desliga a transmissão; exige NAT Gateway e o certificado A1 nas Lambdas
+  sefazUrl: '',
+  // Contingência: base da SVC da UF (SVC-AN ou SVC-RS) e do Ambiente Nacional (EPEC)
+  sefazSvcUrl: '',
+  sefazEpecUrl: '',
+  // NFC-e: base do autorizador da NFC-e da UF; o CSC (NFCE_CSC) vem junto com o certificado
+  sefazNfceUrl: '',
+
   // CloudWatch Alarms Configuration
   alarms: {
     cpuThreshold: 70,
@@ -21,8 +21,7 @@ export interface ComputeStackServerlessProps extends cdk.StackProps {
   eventBus: events.EventBus;
   userPoolId?: string; // Cognito User Pool ID (opcional para backward compatibility)
   userPoolClientId?: string; // Cognito User Pool Client ID
-  frontendBucketName?: string; // Nome do bucket S3 do frontend (para PDFs)
-  cloudFrontDomain?: string; // Domínio CloudFront (para URLs dos PDFs)
+  frontendBucketName?: string; // Nome do bucket S3 do frontend (para PDFs; fora do alcance do CloudFront)
 }
 
 /**
@@ -49,7 +48,7 @@ export class ComputeStackServerless extends cdk.Stack {
   constructor(scope: Construct, id: string, props: ComputeStackServerlessProps) {
     super(scope, id, props);
 
-    const { config, vpc, dbSecurityGroup, dbSecret, rdsProxyEndpoint, eventBus, userPoolId, userPoolClientId, frontendBucketName, cloudFrontDomain } = props;
+    const { config, vpc, dbSecurityGroup, dbSecret, rdsProxyEndpoint, eventBus, userPoolId, userPoolClientId, frontendBucketName } = props;
 
     // ===========================
     // 1. SQS Queues (Mensageria)
@@ -110,15 +109,24 @@ export class ComputeStackServerless extends cdk.Stack {
     // Grant EventBridge publish
     eventBus.grantPutEventsTo(lambdaRole);
 
-    // Grant S3 write para PDF uploads (se frontendBucketName fornecido)
+    // Grant S3 write para PDF uploads e leitura para o download pela API (se frontendBucketName fornecido)
     if (frontendBucketName) {
       lambdaRole.addToPolicy(new iam.PolicyStatement({
         effect: iam.Effect.ALLOW,
-        actions: ['s3:PutObject', 's3:PutObjectAcl'],
-        resources: [`arn:aws:s3:::${frontendBucketName}/notas-fiscais/*`],
+        actions: ['s3:PutObject', 's3:PutObjectAcl', 's3:GetObject'],
+        resources: [
+          `arn:aws:s3:::${frontendBucketName}/notas-fiscais/*`,
+          `arn:aws:s3:::${frontendBucketName}/inutilizacoes/*`,
+        ],
       }));
     }
 
+    // Segredo do HMAC dos links de download assinados (GET /api/v1/documentos/...)
+    const linksSecret = new secretsmanager.Secret(this, 'LinksDownloadSecret', {
+      secretName: `nfe-links-download-${config.environment}`,
+      generateSecretString: { passwordLength: 48, excludePunctuation: true },
+    });
+
     // Security Group para Lambdas
     const lambdaSecurityGroup = new ec2.SecurityGroup(this, 'LambdaSecurityGroup', {
       vpc,
@@ -224,6 +232,14 @@ export class ComputeStackServerless extends cdk.Stack {
         EVENT_BUS_NAME: eventBus.eventBusName,
         SQS_ESTOQUE_RESERVA_URL: estoqueReservaQueue.queueUrl,
         CORS_ORIGINS: config.cloudFrontDomain || '*',
+        SEFAZ_URL: config.sefazUrl, // usado em GET /sefaz/status
+        SEFAZ_SVC_URL: config.sefazSvcUrl, // validam a ativacao da contingencia
+        SEFAZ_EPEC_URL: config.sefazEpecUrl,
+        SEFAZ_NFCE_URL: config.sefazNfceUrl,
+        // PDFs lidos do bucket da lambda-pdf para GET /notas/{id}/pdf e os links assinados
+        ARMAZENAMENTO: 's3',
+        ARMAZENAMENTO_BUCKET: frontendBucketName || `nfe-frontend-${config.environment}-${cdk.Aws.ACCOUNT_ID}`,
+        LINKS_SEGREDO: linksSecret.secretValue.unsafeUnwrap(),
       },
       vpc,
       vpcSubnets: { subnetType: ec2.SubnetType.PUBLIC },
@@ -354,7 +370,6 @@ export class ComputeStackServerless extends cdk.Stack {
         DB_SCHEMA: 'faturamento',
         DB_SSLMODE: 'require',
         PDF_BUCKET_NAME: frontendBucketName || `nfe-frontend-${config.environment}-${cdk.Aws.ACCOUNT_ID}`,
-        CLOUDFRONT_DOMAIN: cloudFrontDomain || config.cloudFrontDomain || '',
       },
       vpc,
       vpcSubnets: { subnetType: ec2.SubnetType.PUBLIC },
@@ -362,17 +377,75 @@ export class ComputeStackServerless extends cdk.Stack {
       allowPublicSubnet: true,
     });
 
-    // EventBridge Rule: Trigger PDF Generator quando impressão é solicitada
+    // EventBridge Rule: Trigger PDF Generator quando impressão é solicitada, a nota recebe CC-e
+    // ou muda de situação (o DANFE exige a chave do fechamento e mostra o protocolo da autorização)
     const pdfGeneratorRule = new events.Rule(this, 'PdfGeneratorRule', {
       ruleName: `nfe-pdf-generator-${config.environment}`,
       eventBus: eventBus,
       eventPattern: {
         source: ['nfe.faturamento'],
-        detailType: ['Faturamento.ImpressaoSolicitada'],
+        detailType: [
+          'Faturamento.ImpressaoSolicitada',
+          'Faturamento.CartaCorrecaoRegistrada',
+          'Faturamento.NotaFechada',
+          'Faturamento.NotaAutorizada',
+        ],
       },
     });
     pdfGeneratorRule.addTarget(new targets.LambdaFunction(pdfGeneratorFunction));
 
+    // Lambda: Autorizador SEFAZ (event-driven)
+    const autorizacaoLogGroup = new logs.LogGroup(this, 'AutorizacaoLogGroup', {
+      logGroupName: `/aws/lambda/nfe-autorizacao-${config.environment}`,
+      retention: logs.RetentionDays.ONE_WEEK,
+      removalPolicy: cdk.RemovalPolicy.DESTROY,
+    });
+
+    const autorizacaoFunction = new lambda.Function(this, 'AutorizacaoFunction', {
+      functionName: `nfe-autorizacao-${config.environment}`,
+      runtime: lambda.Runtime.PROVIDED_AL2023,
+      handler: 'bootstrap',
+      code: lambda.Code.fromAsset('../../servico-faturamento/build-autorizacao'),
+      architecture: lambda.Architecture.X86_64,
+      memorySize: 256,
+      // Cobre a transmissão e as consultas do recibo do lote assíncrono
+      timeout: cdk.Duration.minutes(3),
+      role: lambdaRole,
+      logGroup: autorizacaoLogGroup,
+      environment: {
+        ENVIRONMENT: config.environment,
+        LOG_LEVEL: 'INFO',
+        DB_HOST: rdsProxyEndpoint,
+        DB_PORT: '5432',
+        DB_USER: dbSecret.secretValueFromJson('username').unsafeUnwrap(),
+        DB_PASSWORD: dbSecret.secretValueFromJson('password').unsafeUnwrap(),
+        DB_NAME: 'nfe_db',
+        DB_SCHEMA: 'faturamento',
+        DB_SSLMODE: 'require',
+        EVENT_BUS_NAME: eventBus.eventBusName,
+        // Sem SEFAZ_URL a Lambda descarta os eventos e as notas ficam em FECHADA
+        SEFAZ_URL: config.sefazUrl,
+        SEFAZ_SVC_URL: config.sefazSvcUrl,
+        SEFAZ_EPEC_URL: config.sefazEpecUrl,
+        SEFAZ_NFCE_URL: config.sefazNfceUrl,
+      },
+      vpc,
+      vpcSubnets: { subnetType: ec2.SubnetType.PUBLIC },
+      securityGroups: [lambdaSecurityGroup],
+      allowPublicSubnet: true,
+    });
+
+    // EventBridge Rule: Trigger Autorizador quando a nota fechada aguarda a SEFAZ
+    const autorizacaoRule = new events.Rule(this, 'AutorizacaoRule', {
+      ruleName: `nfe-autorizacao-${config.environment}`,
+      eventBus: eventBus,
+      eventPattern: {
+        source: ['nfe.faturamento'],
+        detailType: ['Faturamento.AutorizacaoSolicitada'],
+      },
+    });
+    autorizacaoRule.addTarget(new targets.LambdaFunction(autorizacaoFunction));
+
     // ===========================
     // 4. API Gateway REST APIs
     // ===========================
@@ -400,6 +473,8 @@ export class ComputeStackServerless extends cdk.Stack {
         allowCredentials: false,  // Não permite cookies (stateless API)
       },
       cloudWatchRole: true,
+      // O DANFE sai da Lambda em base64 e chega ao cliente como binário
+      binaryMediaTypes: ['application/pdf'],
     });
 
     // Lambda Integration
@@ -428,6 +503,24 @@ export class ComputeStackServerless extends cdk.Stack {
     const itensResource = notaIdResource.addResource('itens');
     itensResource.addMethod('POST', faturamentoIntegration, protectedMethodOptions);
 
+    // Route: POST /api/v1/notas/{id}/pagamentos (formas de pagamento, obrigatorias na NFC-e)
+    const pagamentosResource = notaIdResource.addResource('pagamentos');
+    pagamentosResource.addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+
+    // Routes: GET /api/v1/notas/{id}/xml e /pdf (download pelo serviço) e POST /links (link assinado)
+    notaIdResource.addResource('xml').addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+    const pdfResource = notaIdResource.addResource('pdf');
+    pdfResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+    notaIdResource.addResource('links').addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+
+    // Routes: POST /api/v1/notas/{id}/pdf/regenerar (nova versão do DANFE) e GET /documentos (histórico)
+    pdfResource.addResource('regenerar').addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+    notaIdResource.addResource('documentos').addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+
+    // Route: GET /api/v1/documentos/notas/{id}/{documento} (SEM autorizador: vale a assinatura do link)
+    apiV1.addResource('documentos').addResource('notas').addResource('{id}').addResource('{documento}')
+      .addMethod('GET', faturamentoIntegration);
+
     // Route: POST /api/v1/notas/{id}/imprimir (dispara saga)
     const imprimirResource = notaIdResource.addResource('imprimir');
     imprimirResource.addMethod('POST', faturamentoIntegration, protectedMethodOptions);
@@ -436,6 +529,57 @@ export class ComputeStackServerless extends cdk.Stack {
     const fecharResource = notaIdResource.addResource('fechar');
     fecharResource.addMethod('PUT', faturamentoIntegration, protectedMethodOptions);
 
+    // Route: POST /api/v1/notas/{id}/cancelar (cancelar nota fechada)
+    const cancelarResource = notaIdResource.addResource('cancelar');
+    cancelarResource.addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+
+    // Route: POST /api/v1/notas/{id}/autorizar (reenviar a nota fechada ou rejeitada a SEFAZ)
+    const autorizarResource = notaIdResource.addResource('autorizar');
+    autorizarResource.addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+
+    // Route: POST /api/v1/notas/{id}/reabrir (devolver a nota rejeitada para correcao)
+    const reabrirResource = notaIdResource.addResource('reabrir');
+    reabrirResource.addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+
+    // Route: POST|GET /api/v1/notas/{id}/correcoes (cartas de correcao)
+    const correcoesResource = notaIdResource.addResource('correcoes');
+    correcoesResource.addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+    correcoesResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+
+    // Route: GET /api/v1/notas/{id}/correcoes/{sequencia}/xml (XML do evento)
+    const correcaoXmlResource = correcoesResource.addResource('{sequencia}').addResource('xml');
+    correcaoXmlResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+
+    // Routes: POST|GET /api/v1/inutilizacoes, GET /api/v1/inutilizacoes/{id} e /{id}/xml
+    const inutilizacoesResource = apiV1.addResource('inutilizacoes');
+    inutilizacoesResource.addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+    inutilizacoesResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+    const inutilizacaoIdResource = inutilizacoesResource.addResource('{id}');
+    inutilizacaoIdResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+    inutilizacaoIdResource.addResource('xml').addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+
+    // Route: GET /api/v1/numeracao/lacunas (numeros que ainda precisam ser inutilizados)
+    const lacunasResource = apiV1.addResource('numeracao').addResource('lacunas');
+    lacunasResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+
+    // Route: GET /api/v1/sefaz/status (status do servico de autorizacao)
+    const sefazStatusResource = apiV1.addResource('sefaz').addResource('status');
+    sefazStatusResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+
+    // Route: GET|POST|DELETE /api/v1/admin/contingencia (modo de emissao SVC/EPEC)
+    const adminResource = apiV1.addResource('admin');
+    const contingenciaResource = adminResource.addResource('contingencia');
+    contingenciaResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+    contingenciaResource.addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+    contingenciaResource.addMethod('DELETE', faturamentoIntegration, protectedMethodOptions);
+
+    // Routes: GET /api/v1/admin/outbox, GET /{id} e POST /{id}/reenfileirar (eventos MORTO)
+    const outboxResource = adminResource.addResource('outbox');
+    outboxResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+    const outboxIdResource = outboxResource.addResource('{id}');
+    outboxIdResource.addMethod('GET', faturamentoIntegration, protectedMethodOptions);
+    outboxIdResource.addResource('reenfileirar').addMethod('POST', faturamentoIntegration, protectedMethodOptions);
+
     // Route: GET /api/v1/solicitacoes-impressao/{id} (consultar status)
     const solicitacoesResource = apiV1.addResource('solicitacoes-impressao');
     const solicitacaoIdResource = solicitacoesResource.addResource('{id}');
@@ -16,6 +16,8 @@ export interface ComputeStackProps extends cdk.StackProps {
   mqSecret: secretsmanager.Secret;
   dbEndpoint: string;
   mqEndpoint: string;
+  userPoolId: string; // Cognito User Pool ID: a API confere o token, o ALB não autentica
+  userPoolClientId: string;
 }
 
 export class ComputeStack extends cdk.Stack {
@@ -28,7 +30,7 @@ export class ComputeStack extends cdk.Stack {
   constructor(scope: Construct, id: string, props: ComputeStackProps) {
     super(scope, id, props);
 
-    const { config, vpc, securityGroup, dbSecret, mqSecret, dbEndpoint, mqEndpoint } = props;
+    const { config, vpc, securityGroup, dbSecret, mqSecret, dbEndpoint, mqEndpoint, userPoolId, userPoolClientId } = props;
 
     // ECR Repositories
     this.faturamentoRepository = new ecr.Repository(this, 'FaturamentoRepo', {
@@ -121,6 +123,8 @@ export class ComputeStack extends cdk.Stack {
         DB_NAME: 'nfe_db',
         DB_SCHEMA: 'faturamento',
         DB_SSLMODE: 'require',
+        COGNITO_USER_POOL_ID: userPoolId,
+        COGNITO_CLIENT_ID: userPoolClientId,
       },
       secrets: {
         DB_USER: ecs.Secret.fromSecretsManager(dbSecret, 'username'),
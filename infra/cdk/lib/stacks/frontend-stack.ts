@@ -58,6 +58,25 @@ export class FrontendStack extends cdk.Stack {
       })
     );
 
+    // O serviço de faturamento guarda DANFEs e XMLs fiscais neste bucket; eles só
+    // saem pelos endpoints autenticados da API e pelos links assinados, nunca pela
+    // distribuição (o 403 cai no index.html do SPA)
+    this.bucket.addToResourcePolicy(
+      new iam.PolicyStatement({
+        effect: iam.Effect.DENY,
+        actions: ['s3:GetObject'],
+        resources: [
+          this.bucket.arnForObjects('notas-fiscais/*'),
+          this.bucket.arnForObjects('inutilizacoes/*'),
+        ],
+        principals: [
+          new iam.CanonicalUserPrincipal(
+            oai.cloudFrontOriginAccessIdentityS3CanonicalUserId
+          ),
+        ],
+      })
+    );
+
     // Cache Policy: Assets (CSS, JS, images) - cache longo
     const assetsCachePolicy = new cloudfront.CachePolicy(this, 'AssetsCachePolicy', {
       cachePolicyName: `nfe-assets-cache-${config.environment}`,
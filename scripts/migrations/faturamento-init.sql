@@ -2,37 +2,260 @@
 
 CREATE EXTENSION IF NOT EXISTS "pgcrypto";
 
+-- Tabela sequencias_numeracao (último nNF por emitente, modelo e série)
+CREATE TABLE IF NOT EXISTS sequencias_numeracao (
+    cnpj_emitente VARCHAR(14) NOT NULL,
+    modelo VARCHAR(2) NOT NULL,
+    serie INT NOT NULL,
+    ultimo_numero BIGINT NOT NULL DEFAULT 0 CHECK (ultimo_numero BETWEEN 0 AND 999999999),
+    data_atualizacao TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (cnpj_emitente, modelo, serie)
+);
+
+-- Tabelas emitentes e clientes (destinatários)
+CREATE TABLE IF NOT EXISTS emitentes (
+    id UUID PRIMARY KEY,
+    cnpj VARCHAR(14) UNIQUE NOT NULL,
+    razao_social VARCHAR(60) NOT NULL,
+    nome_fantasia VARCHAR(60),
+    ie VARCHAR(14) NOT NULL,
+    crt VARCHAR(1) NOT NULL CHECK (crt IN ('1', '2', '3', '4')),
+    logradouro VARCHAR(60) NOT NULL,
+    numero_endereco VARCHAR(60) NOT NULL,
+    complemento VARCHAR(60),
+    bairro VARCHAR(60) NOT NULL,
+    codigo_municipio VARCHAR(7) NOT NULL,
+    municipio VARCHAR(60) NOT NULL,
+    uf VARCHAR(2) NOT NULL,
+    cep VARCHAR(8),
+    telefone VARCHAR(14),
+    data_criacao TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    data_atualizacao TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS clientes (
+    id UUID PRIMARY KEY,
+    documento VARCHAR(14) UNIQUE NOT NULL,
+    nome VARCHAR(60) NOT NULL,
+    indicador_ie VARCHAR(1) NOT NULL CHECK (indicador_ie IN ('1', '2', '9')),
+    ie VARCHAR(14),
+    email VARCHAR(60),
+    logradouro VARCHAR(60) NOT NULL,
+    numero_endereco VARCHAR(60) NOT NULL,
+    complemento VARCHAR(60),
+    bairro VARCHAR(60) NOT NULL,
+    codigo_municipio VARCHAR(7) NOT NULL,
+    municipio VARCHAR(60) NOT NULL,
+    uf VARCHAR(2) NOT NULL,
+    cep VARCHAR(8),
+    telefone VARCHAR(14),
+    data_criacao TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    data_atualizacao TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
 -- Tabela notas_fiscais
 CREATE TABLE IF NOT EXISTS notas_fiscais (
     id UUID PRIMARY KEY,
-    numero VARCHAR(20) UNIQUE NOT NULL,
-    status VARCHAR(20) NOT NULL CHECK (status IN ('ABERTA', 'FECHADA', 'CANCELADA')),
+    numero VARCHAR(20) NOT NULL,
+    cnpj_emitente VARCHAR(14) NOT NULL DEFAULT '',
+    modelo VARCHAR(2) NOT NULL DEFAULT '55' CHECK (modelo IN ('55', '65')),
+    serie INT NOT NULL CHECK (serie BETWEEN 0 AND 999),
+    emitente_id UUID REFERENCES emitentes(id),
+    cliente_id UUID REFERENCES clientes(id),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('ABERTA', 'FECHADA', 'AUTORIZADA', 'REJEITADA', 'DENEGADA', 'CANCELADA')),
+    autorizacao_pendente BOOLEAN NOT NULL DEFAULT FALSE,
     data_criacao TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    data_fechada TIMESTAMPTZ
+    data_fechada TIMESTAMPTZ,
+    chave_acesso CHAR(44) UNIQUE,
+    protocolo_autorizacao VARCHAR(15),
+    c_stat INT,
+    x_motivo VARCHAR(255),
+    data_autorizacao TIMESTAMPTZ,
+    xml_autorizado TEXT,
+    xml_fechamento TEXT,
+    tipo_emissao VARCHAR(1) NOT NULL DEFAULT '1' CHECK (tipo_emissao IN ('1', '4', '6', '7', '9')),
+    data_contingencia TIMESTAMPTZ,
+    justificativa_contingencia VARCHAR(255),
+    protocolo_epec VARCHAR(15),
+    data_epec TIMESTAMPTZ,
+    data_cancelamento TIMESTAMPTZ,
+    justificativa_cancelamento VARCHAR(255),
+    protocolo_cancelamento VARCHAR(15),
+    xml_cancelamento TEXT
 );
 
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notas_numeracao ON notas_fiscais(cnpj_emitente, modelo, serie, numero);
 CREATE INDEX IF NOT EXISTS idx_notas_numero ON notas_fiscais(numero);
 CREATE INDEX IF NOT EXISTS idx_notas_status ON notas_fiscais(status);
+CREATE INDEX IF NOT EXISTS idx_notas_fiscais_emitente_id ON notas_fiscais(emitente_id);
+CREATE INDEX IF NOT EXISTS idx_notas_fiscais_cliente_id ON notas_fiscais(cliente_id);
 CREATE INDEX IF NOT EXISTS idx_notas_data_criacao ON notas_fiscais(data_criacao DESC);
 
+-- Regras tributárias: critérios vazios valem para qualquer valor e a regra mais
+-- específica vence (ver dominio.SelecionarRegra)
+CREATE TABLE IF NOT EXISTS regras_tributarias (
+    id UUID PRIMARY KEY,
+    descricao VARCHAR(120) NOT NULL,
+    regime VARCHAR(7),
+    cfop VARCHAR(4),
+    ncm VARCHAR(8),
+    origem VARCHAR(1),
+    uf_destino VARCHAR(2),
+    vigencia_inicio TIMESTAMPTZ,
+    vigencia_fim TIMESTAMPTZ,
+    cst_icms VARCHAR(2),
+    csosn VARCHAR(3),
+    p_icms NUMERIC(7,4) NOT NULL DEFAULT 0,
+    p_red_bc_icms NUMERIC(7,4) NOT NULL DEFAULT 0,
+    p_cred_sn NUMERIC(7,4) NOT NULL DEFAULT 0,
+    cst_ipi VARCHAR(2),
+    c_enq_ipi VARCHAR(3),
+    p_ipi NUMERIC(7,4) NOT NULL DEFAULT 0,
+    cst_pis VARCHAR(2),
+    p_pis NUMERIC(7,4) NOT NULL DEFAULT 0,
+    cst_cofins VARCHAR(2),
+    p_cofins NUMERIC(7,4) NOT NULL DEFAULT 0,
+    cst_ibs_cbs VARCHAR(3),
+    c_class_trib VARCHAR(6),
+    data_criacao TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    data_atualizacao TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_regras_tributarias_cfop ON regras_tributarias(cfop);
+CREATE INDEX IF NOT EXISTS idx_regras_tributarias_ncm ON regras_tributarias(ncm);
+
+-- Alíquotas de IBS/CBS por período (Reforma Tributária); vale o período vigente
+-- na data de fechamento com início mais recente
+CREATE TABLE IF NOT EXISTS aliquotas_ibs_cbs (
+    id UUID PRIMARY KEY,
+    descricao VARCHAR(120) NOT NULL,
+    vigencia_inicio TIMESTAMPTZ NOT NULL,
+    vigencia_fim TIMESTAMPTZ,
+    p_ibs_uf NUMERIC(7,4) NOT NULL DEFAULT 0,
+    p_ibs_mun NUMERIC(7,4) NOT NULL DEFAULT 0,
+    p_cbs NUMERIC(7,4) NOT NULL DEFAULT 0,
+    data_criacao TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    data_atualizacao TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_aliquotas_ibs_cbs_vigencia_inicio ON aliquotas_ibs_cbs(vigencia_inicio);
+
+-- Fase de teste de 2026: 0,1% de IBS estadual e 0,9% de CBS
+INSERT INTO aliquotas_ibs_cbs (id, descricao, vigencia_inicio, vigencia_fim, p_ibs_uf, p_ibs_mun, p_cbs)
+VALUES ('5c1b7f3e-2026-4a10-9b00-000000000001', 'Fase de teste 2026', '2026-01-01T00:00:00-03:00', '2027-01-01T00:00:00-03:00', 0.1, 0, 0.9)
+ON CONFLICT (id) DO NOTHING;
+
 -- Tabela itens_nota
 CREATE TABLE IF NOT EXISTS itens_nota (
     id UUID PRIMARY KEY,
     nota_id UUID NOT NULL REFERENCES notas_fiscais(id) ON DELETE CASCADE,
     produto_id UUID NOT NULL,
-    quantidade INT NOT NULL CHECK (quantidade > 0),
-    preco_unitario DECIMAL(10,2) NOT NULL CHECK (preco_unitario >= 0)
+    quantidade NUMERIC(15,4) NOT NULL CHECK (quantidade > 0),
+    preco_unitario NUMERIC(15,4) NOT NULL CHECK (preco_unitario >= 0),
+    descricao VARCHAR(120),
+    ncm VARCHAR(8),
+    cfop VARCHAR(4),
+    unidade VARCHAR(6) DEFAULT 'UN',
+    origem VARCHAR(1) NOT NULL DEFAULT '0',
+    -- Tributos gravados no fechamento pelo motor tributário
+    regra_tributaria_id UUID,
+    cst_icms VARCHAR(3),
+    vbc_icms NUMERIC(15,4) NOT NULL DEFAULT 0,
+    p_red_bc_icms NUMERIC(7,4) NOT NULL DEFAULT 0,
+    p_icms NUMERIC(7,4) NOT NULL DEFAULT 0,
+    v_icms NUMERIC(15,4) NOT NULL DEFAULT 0,
+    p_cred_sn NUMERIC(7,4) NOT NULL DEFAULT 0,
+    v_cred_icms_sn NUMERIC(15,4) NOT NULL DEFAULT 0,
+    cst_ipi VARCHAR(2),
+    c_enq_ipi VARCHAR(3),
+    vbc_ipi NUMERIC(15,4) NOT NULL DEFAULT 0,
+    p_ipi NUMERIC(7,4) NOT NULL DEFAULT 0,
+    v_ipi NUMERIC(15,4) NOT NULL DEFAULT 0,
+    cst_pis VARCHAR(2),
+    vbc_pis NUMERIC(15,4) NOT NULL DEFAULT 0,
+    p_pis NUMERIC(7,4) NOT NULL DEFAULT 0,
+    v_pis NUMERIC(15,4) NOT NULL DEFAULT 0,
+    cst_cofins VARCHAR(2),
+    vbc_cofins NUMERIC(15,4) NOT NULL DEFAULT 0,
+    p_cofins NUMERIC(7,4) NOT NULL DEFAULT 0,
+    v_cofins NUMERIC(15,4) NOT NULL DEFAULT 0,
+    cst_ibs_cbs VARCHAR(3),
+    c_class_trib VARCHAR(6),
+    vbc_ibs_cbs NUMERIC(15,4) NOT NULL DEFAULT 0,
+    p_ibs_uf NUMERIC(7,4) NOT NULL DEFAULT 0,
+    v_ibs_uf NUMERIC(15,4) NOT NULL DEFAULT 0,
+    p_ibs_mun NUMERIC(7,4) NOT NULL DEFAULT 0,
+    v_ibs_mun NUMERIC(15,4) NOT NULL DEFAULT 0,
+    p_cbs NUMERIC(7,4) NOT NULL DEFAULT 0,
+    v_cbs NUMERIC(15,4) NOT NULL DEFAULT 0
 );
 
 CREATE INDEX IF NOT EXISTS idx_itens_nota_id ON itens_nota(nota_id);
 CREATE INDEX IF NOT EXISTS idx_itens_produto_id ON itens_nota(produto_id);
 
+-- Formas de pagamento (grupo pag): obrigatórias na NFC-e; o excedente ao vNF é o troco
+CREATE TABLE IF NOT EXISTS pagamentos_nota (
+    id UUID PRIMARY KEY,
+    nota_id UUID NOT NULL REFERENCES notas_fiscais(id) ON DELETE CASCADE,
+    forma VARCHAR(2) NOT NULL,
+    descricao VARCHAR(60),
+    valor NUMERIC(15,2) NOT NULL CHECK (valor >= 0),
+    bandeira VARCHAR(2),
+    autorizacao VARCHAR(128)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pagamentos_nota_id ON pagamentos_nota(nota_id);
+
+-- Cartas de correção (CC-e): até 20 por nota, a de maior sequência substitui as anteriores
+CREATE TABLE IF NOT EXISTS cartas_correcao (
+    id UUID PRIMARY KEY,
+    nota_id UUID NOT NULL REFERENCES notas_fiscais(id) ON DELETE CASCADE,
+    sequencia INT NOT NULL CHECK (sequencia BETWEEN 1 AND 20),
+    correcao VARCHAR(1000) NOT NULL,
+    data_evento TIMESTAMPTZ NOT NULL,
+    xml TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cartas_correcao_sequencia ON cartas_correcao(nota_id, sequencia);
+
+-- Contingências (SVC-AN, SVC-RS, EPEC): o período sem data_fim é o modo vigente
+CREATE TABLE IF NOT EXISTS contingencias (
+    id UUID PRIMARY KEY,
+    modo VARCHAR(10) NOT NULL CHECK (modo IN ('SVC-AN', 'SVC-RS', 'EPEC')),
+    justificativa VARCHAR(255) NOT NULL,
+    automatica BOOLEAN NOT NULL DEFAULT FALSE,
+    data_inicio TIMESTAMPTZ NOT NULL,
+    data_fim TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_contingencias_data_inicio ON contingencias(data_inicio);
+
+-- Tabela inutilizacoes (faixas de numeracao descartadas na SEFAZ)
+CREATE TABLE IF NOT EXISTS inutilizacoes (
+    id UUID PRIMARY KEY,
+    emitente_id UUID REFERENCES emitentes(id),
+    cnpj_emitente VARCHAR(14) NOT NULL,
+    modelo VARCHAR(2) NOT NULL CHECK (modelo IN ('55', '65')),
+    serie INT NOT NULL CHECK (serie BETWEEN 0 AND 999),
+    numero_inicial BIGINT NOT NULL CHECK (numero_inicial >= 1),
+    numero_final BIGINT NOT NULL CHECK (numero_final <= 999999999),
+    ano VARCHAR(2) NOT NULL,
+    justificativa VARCHAR(255) NOT NULL,
+    data_registro TIMESTAMPTZ NOT NULL,
+    xml TEXT NOT NULL,
+    CHECK (numero_inicial <= numero_final)
+);
+
+CREATE INDEX IF NOT EXISTS idx_inutilizacoes_serie ON inutilizacoes(cnpj_emitente, modelo, serie);
+CREATE INDEX IF NOT EXISTS idx_inutilizacoes_emitente_id ON inutilizacoes(emitente_id);
+
 -- Tabela solicitacoes_impressao
 CREATE TABLE IF NOT EXISTS solicitacoes_impressao (
     id UUID PRIMARY KEY,
     nota_id UUID NOT NULL REFERENCES notas_fiscais(id) ON DELETE CASCADE,
     status VARCHAR(20) NOT NULL CHECK (status IN ('PENDENTE', 'CONCLUIDA', 'FALHOU')),
     mensagem_erro TEXT,
+    pdf_chave VARCHAR(255),
     chave_idempotencia VARCHAR(100) UNIQUE NOT NULL,
     data_criacao TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     data_conclusao TIMESTAMPTZ
@@ -42,6 +265,43 @@ CREATE INDEX IF NOT EXISTS idx_solicitacoes_nota_id ON solicitacoes_impressao(no
 CREATE INDEX IF NOT EXISTS idx_solicitacoes_status ON solicitacoes_impressao(status);
 CREATE INDEX IF NOT EXISTS idx_solicitacoes_chave ON solicitacoes_impressao(chave_idempotencia);
 
+-- Versões dos PDFs gerados (imutáveis): regenerar grava uma nova versão com o hash
+CREATE TABLE IF NOT EXISTS documentos (
+    id UUID PRIMARY KEY,
+    nota_id UUID NOT NULL REFERENCES notas_fiscais(id) ON DELETE CASCADE,
+    tipo VARCHAR(20) NOT NULL,
+    versao INT NOT NULL CHECK (versao >= 1),
+    versao_layout VARCHAR(30) NOT NULL,
+    hash_sha256 VARCHAR(64) NOT NULL,
+    tamanho INT NOT NULL,
+    chave VARCHAR(255) NOT NULL,
+    motivo VARCHAR(255) NOT NULL,
+    data_criacao TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_documentos_versao ON documentos(nota_id, tipo, versao);
+
+-- Envios do DANFE e do XML por email, um por destinatário
+CREATE TABLE IF NOT EXISTS envios_email (
+    id UUID PRIMARY KEY,
+    nota_id UUID NOT NULL REFERENCES notas_fiscais(id) ON DELETE CASCADE,
+    destinatario VARCHAR(254) NOT NULL,
+    origem VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDENTE',
+    tentativas INT NOT NULL DEFAULT 0,
+    proxima_tentativa TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    ultimo_erro TEXT,
+    message_id VARCHAR(255),
+    data_envio TIMESTAMPTZ,
+    data_devolucao TIMESTAMPTZ,
+    motivo_devolucao TEXT,
+    data_criacao TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_envios_email_nota_id ON envios_email(nota_id);
+CREATE INDEX IF NOT EXISTS idx_envios_email_pendentes ON envios_email(status, proxima_tentativa);
+CREATE INDEX IF NOT EXISTS idx_envios_email_message_id ON envios_email(message_id);
+
 -- Tabela eventos_outbox
 CREATE TABLE IF NOT EXISTS eventos_outbox (
     id BIGSERIAL PRIMARY KEY,
@@ -49,7 +309,14 @@ CREATE TABLE IF NOT EXISTS eventos_outbox (
     id_agregado UUID NOT NULL,
     payload JSONB NOT NULL,
     data_ocorrencia TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    data_publicacao TIMESTAMPTZ
+    data_publicacao TIMESTAMPTZ,
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDENTE' CHECK (status IN ('PENDENTE', 'PUBLICADO', 'MORTO')),
+    tentativas INT NOT NULL DEFAULT 0,
+    ultimo_erro TEXT,
+    proxima_tentativa TIMESTAMPTZ,
+    data_morte TIMESTAMPTZ,
+    reservado_por VARCHAR(100),
+    reservado_ate TIMESTAMPTZ
 );
 
 CREATE INDEX IF NOT EXISTS idx_faturamento_outbox_pendentes 
@@ -57,6 +324,22 @@ CREATE INDEX IF NOT EXISTS idx_faturamento_outbox_pendentes
     WHERE data_publicacao IS NULL;
 
 CREATE INDEX IF NOT EXISTS idx_faturamento_outbox_tipo ON eventos_outbox(tipo_evento);
+CREATE INDEX IF NOT EXISTS idx_eventos_outbox_status ON eventos_outbox(status);
+-- Ordem por agregado: o publicador só reserva o evento pendente mais antigo de cada id_agregado
+CREATE INDEX IF NOT EXISTS idx_eventos_outbox_agregado ON eventos_outbox(id_agregado);
+
+-- NOTIFY eventos_outbox a cada INSERT: o publicador da API escuta o canal (LISTEN)
+-- e publica no commit, sem esperar a varredura
+CREATE OR REPLACE FUNCTION notificar_eventos_outbox() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('eventos_outbox', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER trg_eventos_outbox_notificar
+    AFTER INSERT ON eventos_outbox
+    FOR EACH STATEMENT EXECUTE FUNCTION notificar_eventos_outbox();
 
 -- Tabela mensagens_processadas (idempotência RabbitMQ)
 CREATE TABLE IF NOT EXISTS mensagens_processadas (
@@ -67,7 +350,11 @@ CREATE TABLE IF NOT EXISTS mensagens_processadas (
 CREATE INDEX IF NOT EXISTS idx_mensagens_data ON mensagens_processadas(data_processada DESC);
 
 -- Dados de exemplo (opcional)
-INSERT INTO notas_fiscais (id, numero, status, data_criacao) VALUES
-    (gen_random_uuid(), 'NFE-DEMO-001', 'ABERTA', NOW()),
-    (gen_random_uuid(), 'NFE-DEMO-002', 'ABERTA', NOW())
-ON CONFLICT (numero) DO NOTHING;
+INSERT INTO notas_fiscais (id, numero, serie, status, data_criacao) VALUES
+    (gen_random_uuid(), '1', 1, 'ABERTA', NOW()),
+    (gen_random_uuid(), '2', 1, 'ABERTA', NOW())
+ON CONFLICT DO NOTHING;
+
+INSERT INTO sequencias_numeracao (cnpj_emitente, modelo, serie, ultimo_numero) VALUES
+    ('', '55', 1, 2)
+ON CONFLICT DO NOTHING;
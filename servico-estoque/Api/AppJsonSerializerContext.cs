@@ -28,6 +28,7 @@ namespace ServicoEstoque.Api;
 [JsonSerializable(typeof(ProblemDetails))]
 [JsonSerializable(typeof(EventoSolicitacaoImpressao))]
 [JsonSerializable(typeof(ItemEventoImpressao))]
+[JsonSerializable(typeof(EventoNotaCancelada))]
 [JsonSerializable(typeof(EventoReservaItemPayload))]
 [JsonSerializable(typeof(EventoReservaSucessoPayload))]
 [JsonSerializable(typeof(EventoReservaRejeitadaPayload))]
@@ -77,6 +77,7 @@ builder.Services.AddDbContext<ContextoBancoDados>(opts =>
 // Configurar schema no OnModelCreating será necessário no ContextoBancoDados
 
 builder.Services.AddScoped<ReservarEstoqueHandler>();
+builder.Services.AddScoped<LiberarReservasHandler>();
 
 // RabbitMQ hosted services - desabilitar quando RABBITMQ_URL vazio ou "disabled" (Lambda/EventBridge)
 var rabbitMqUrl = Environment.GetEnvironmentVariable("RABBITMQ_URL");
@@ -0,0 +1,72 @@
+using System.Linq;
+using Microsoft.EntityFrameworkCore;
+using Microsoft.Extensions.Logging;
+using ServicoEstoque.Dominio.Entidades;
+using ServicoEstoque.Infraestrutura.Persistencia;
+
+namespace ServicoEstoque.Aplicacao.CasosDeUso;
+
+/// <summary>
+/// Devolve ao saldo dos produtos as reservas de uma nota cancelada no Faturamento.
+/// Reservas ja canceladas sao ignoradas, entao reprocessar o evento nao credita em dobro.
+/// </summary>
+public sealed class LiberarReservasHandler
+{
+    private const int TentativasMaximas = 3;
+
+    private readonly ContextoBancoDados _ctx;
+    private readonly ILogger<LiberarReservasHandler> _logger;
+
+    public LiberarReservasHandler(ContextoBancoDados ctx, ILogger<LiberarReservasHandler> logger)
+    {
+        _ctx = ctx;
+        _logger = logger;
+    }
+
+    public async Task<Resultado> Executar(Guid notaId, CancellationToken ct = default)
+    {
+        for (int tentativa = 1; ; tentativa++)
+        {
+            await using var tx = await _ctx.Database.BeginTransactionAsync(ct);
+            try
+            {
+                var reservas = await _ctx.ReservasEstoque
+                    .Where(r => r.NotaId == notaId && r.Status == "RESERVADO")
+                    .ToListAsync(ct);
+
+                if (reservas.Count == 0)
+                {
+                    _logger.LogInformation("[LiberarReservas] Nenhuma reserva ativa para NotaId={NotaId}", notaId);
+                    return Resultado.Sucesso();
+                }
+
+                foreach (var reserva in reservas)
+                {
+                    var produto = CompiledQueries.ProdutoPorIdTracking(_ctx, reserva.ProdutoId)
+                        ?? throw new InvalidOperationException($"Produto {reserva.ProdutoId} nao encontrado.");
+
+                    var resultadoCredito = produto.CreditarEstoque(reserva.Quantidade);
+                    if (resultadoCredito.Falhou)
+                    {
+                        throw new InvalidOperationException($"Produto {reserva.ProdutoId}: {resultadoCredito.Mensagem}");
+                    }
+                    reserva.Status = "CANCELADO";
+                }
+
+                await _ctx.SaveChangesAsync(ct);
+                await tx.CommitAsync(ct);
+
+                _logger.LogInformation(
+                    "[LiberarReservas] {Quantidade} reservas devolvidas ao estoque para NotaId={NotaId}",
+                    reservas.Count, notaId);
+                return Resultado.Sucesso();
+            }
+            catch (DbUpdateConcurrencyException ex) when (tentativa < TentativasMaximas)
+            {
+                await tx.RollbackAsync(ct);
+                _ctx.ChangeTracker.Clear();
+                _logger.LogWarning(ex, "[LiberarReservas] Conflito de concorrencia na tentativa {Tentativa} para NotaId={NotaId}", tentativa, notaId);
+            }
+        }
+    }
+}
@@ -11,7 +11,8 @@ public record ReservarEstoqueLoteCommand(
     IReadOnlyCollection<ReservarEstoqueItem> Itens
 );
 
+// quantidade decimal como enviada pelo faturamento; o lote so aceita valores inteiros
 public record ReservarEstoqueItem(
     Guid ProdutoId,
-    int Quantidade
+    decimal Quantidade
 );
@@ -138,6 +138,13 @@ public sealed class ReservarEstoqueHandler
         {
             foreach (var item in cmd.Itens)
             {
+                // o saldo e controlado em unidades inteiras (kg, m fracionados nao tem reserva)
+                if (item.Quantidade != decimal.Truncate(item.Quantidade))
+                {
+                    throw new InvalidOperationException($"Produto {item.ProdutoId}: quantidade fracionada {item.Quantidade} nao suportada pelo estoque.");
+                }
+                var quantidade = (int)item.Quantidade;
+
                 var produto = CompiledQueries.ProdutoPorIdTracking(_ctx, item.ProdutoId);
 
                 if (produto is null)
@@ -145,7 +152,7 @@ public sealed class ReservarEstoqueHandler
                     throw new InvalidOperationException($"Produto {item.ProdutoId} nao encontrado.");
                 }
 
-                var resultadoDebito = produto.DebitarEstoque(item.Quantidade);
+                var resultadoDebito = produto.DebitarEstoque(quantidade);
                 if (resultadoDebito.Falhou)
                 {
                     await tx.RollbackAsync(ct);
@@ -158,7 +165,7 @@ public sealed class ReservarEstoqueHandler
                     Id = Guid.NewGuid(),
                     NotaId = cmd.NotaId,
                     ProdutoId = item.ProdutoId,
-                    Quantidade = item.Quantidade,
+                    Quantidade = quantidade,
                     Status = "RESERVADO",
                     DataCriacao = DateTime.UtcNow
                 };
@@ -174,7 +181,7 @@ public sealed class ReservarEstoqueHandler
             await _ctx.SaveChangesAsync(ct);
 
             var itensLote = cmd.Itens
-                .Select(i => new EventoReservaItemPayload(i.ProdutoId, i.Quantidade))
+                .Select(i => new EventoReservaItemPayload(i.ProdutoId, (int)i.Quantidade))
                 .ToList();
             var payloadLote = new EventoReservaSucessoPayload(cmd.NotaId, itensLote);
 
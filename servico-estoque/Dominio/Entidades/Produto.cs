@@ -41,6 +41,15 @@ public sealed class Produto
         return Resultado.Sucesso();
     }
 
+    public Resultado CreditarEstoque(int qtd)
+    {
+        if (qtd <= 0)
+            return Resultado.Falha("Quantidade deve ser positiva");
+
+        Saldo += qtd;
+        return Resultado.Sucesso();
+    }
+
     public void AtualizarSaldo(int novoSaldo)
     {
         if (novoSaldo < 0) throw new InvalidOperationException("Saldo negativo");
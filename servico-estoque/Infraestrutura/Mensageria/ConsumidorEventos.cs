@@ -1,5 +1,6 @@
 using System.Text;
 using System.Text.Json;
+using System.Text.Json.Serialization;
 using Microsoft.EntityFrameworkCore;
 using RabbitMQ.Client;
 using RabbitMQ.Client.Events;
@@ -12,7 +13,7 @@ using ServicoEstoque.Infraestrutura.Persistencia;
 namespace ServicoEstoque.Infraestrutura.Mensageria;
 
 /// <summary>
-/// Consumidor de eventos do RabbitMQ para processar solicitacoes de reserva vindas do Faturamento
+/// Consumidor de eventos do RabbitMQ para processar solicitacoes de reserva e cancelamentos vindos do Faturamento
 /// Implementa idempotencia e processamento transacional
 /// </summary>
 public class ConsumidorEventos : BackgroundService
@@ -114,7 +115,13 @@ public class ConsumidorEventos : BackgroundService
             routingKey: "Faturamento.ImpressaoSolicitada"
         );
 
-        _logger.LogInformation("Escutando: Faturamento.ImpressaoSolicitada");
+        _canal.QueueBind(
+            queue: nomeFila,
+            exchange: "faturamento-eventos",
+            routingKey: "Faturamento.NotaCancelada"
+        );
+
+        _logger.LogInformation("Escutando: Faturamento.ImpressaoSolicitada, Faturamento.NotaCancelada");
 
         _canal.BasicQos(prefetchSize: 0, prefetchCount: 1, global: false);
 
@@ -159,6 +166,12 @@ public class ConsumidorEventos : BackgroundService
             return;
         }
 
+        if (args.RoutingKey == "Faturamento.NotaCancelada")
+        {
+            await ProcessarCancelamento(escopo, contexto, idMensagem, args);
+            return;
+        }
+
         // deserializar payload JSON
         var corpo = Encoding.UTF8.GetString(args.Body.ToArray());
         var evento = JsonSerializer.Deserialize(
@@ -202,6 +215,42 @@ public class ConsumidorEventos : BackgroundService
         }
     }
 
+    // nota cancelada no Faturamento: devolve ao saldo o que foi reservado para ela
+    private async Task ProcessarCancelamento(
+        IServiceScope escopo,
+        ContextoBancoDados contexto,
+        string idMensagem,
+        BasicDeliverEventArgs args)
+    {
+        var corpo = Encoding.UTF8.GetString(args.Body.ToArray());
+        var evento = JsonSerializer.Deserialize(
+            corpo,
+            AppJsonSerializerContext.Default.EventoNotaCancelada);
+
+        if (evento is null || evento.NotaId == Guid.Empty)
+        {
+            _logger.LogError("Falha ao deserializar evento de cancelamento: {Corpo}", corpo);
+            return;
+        }
+
+        _logger.LogInformation("Processando cancelamento da nota {NotaId}", evento.NotaId);
+
+        var handler = escopo.ServiceProvider.GetRequiredService<LiberarReservasHandler>();
+        var resultado = await handler.Executar(evento.NotaId);
+
+        contexto.MensagensProcessadas.Add(new MensagemProcessada
+        {
+            IDMensagem = idMensagem,
+            DataProcessada = DateTime.UtcNow
+        });
+        await contexto.SaveChangesAsync();
+
+        if (resultado.Falhou)
+        {
+            _logger.LogWarning("Falha ao liberar reservas da nota {NotaId}: {Motivo}", evento.NotaId, resultado.Mensagem);
+        }
+    }
+
     public override void Dispose()
     {
         _canal?.Close();
@@ -216,7 +265,14 @@ internal record EventoSolicitacaoImpressao(
     List<ItemEventoImpressao> Itens
 );
 
+// quantidade chega como string decimal ("2.5"); numeros JSON continuam aceitos
 internal record ItemEventoImpressao(
     Guid ProdutoId,
-    int Quantidade
+    [property: JsonNumberHandling(JsonNumberHandling.AllowReadingFromString)] decimal Quantidade
+);
+
+// so o NotaId e usado: as quantidades devolvidas sao as das reservas gravadas
+internal record EventoNotaCancelada(
+    Guid NotaId,
+    string? Justificativa
 );
@@ -7,7 +7,7 @@ docker run --rm `
   -v "${PWD}:/app" `
   -w /app `
   golang:1.24-alpine `
-  sh -c "apk add --no-cache git && GOOS=linux GOARCH=arm64 go build -tags lambda.norpc -o build/bootstrap-new cmd/lambda/main.go"
+  sh -c "apk add --no-cache git && GOOS=linux GOARCH=arm64 go build -tags lambda.norpc -o build/bootstrap-new ./cmd/lambda"
 
 if ($LASTEXITCODE -eq 0) {
     Write-Host "Build successful!"
@@ -6,15 +6,25 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/autenticacao"
 	"servico-faturamento/internal/config"
 	"servico-faturamento/internal/consumidor"
+	"servico-faturamento/internal/contingencia"
+	"servico-faturamento/internal/email"
 	"servico-faturamento/internal/health"
+	"servico-faturamento/internal/impressao"
+	"servico-faturamento/internal/linkassinado"
 	"servico-faturamento/internal/logger"
 	"servico-faturamento/internal/manipulador"
+	"servico-faturamento/internal/mensageria"
 	"servico-faturamento/internal/publicador"
+	"servico-faturamento/internal/sefaz"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,18 +44,104 @@ func main() {
 	sqlDB, _ := db.DB()
 	defer sqlDB.Close()
 
-	handlers := &manipulador.Handlers{DB: db}
+	certificado, err := assinatura.CarregarConfigurado()
+	if err != nil {
+		slog.Error("Erro ao carregar certificado A1", "erro", err.Error())
+		os.Exit(1)
+	}
+	if certificado == nil {
+		slog.Warn("Certificado A1 nao configurado; XMLs serao gerados sem assinatura")
+	} else {
+		slog.Info("Certificado A1 carregado", "titular", certificado.X509.Subject.CommonName, "validade", certificado.X509.NotAfter)
+	}
 
-	if err := publicador.IniciarPublicador(db); err != nil {
-		slog.Error("Erro ao iniciar publicador outbox", "erro", err.Error())
+	clienteSefaz, err := sefaz.CarregarConfigurado(certificado)
+	if err != nil {
+		slog.Error("Erro ao configurar cliente SEFAZ", "erro", err.Error())
 		os.Exit(1)
 	}
+	if clienteSefaz == nil {
+		slog.Warn("SEFAZ nao configurada (SEFAZ_URL); notas fechadas nao serao transmitidas")
+	}
+
+	// Em Docker Compose os documentos ficam em disco (ARMAZENAMENTO_DIRETORIO, num volume)
+	docs, err := armazenamento.CarregarConfigurado(context.Background(), armazenamento.BackendLocal)
+	if err != nil {
+		slog.Error("Erro ao configurar armazenamento de documentos", "erro", err.Error())
+		os.Exit(1)
+	}
+
+	links, err := linkassinado.CarregarConfigurado()
+	if err != nil {
+		slog.Error("Erro ao configurar links assinados", "erro", err.Error())
+		os.Exit(1)
+	}
+	if links == nil {
+		slog.Warn("LINKS_SEGREDO nao configurado; links de download assinados desligados")
+	}
+
+	// Sem a API Gateway na frente (Docker Compose, ECS atrás do ALB) é a própria API
+	// que confere o token do Cognito; subir sem autenticação exige pedir explicitamente
+	autenticador, err := autenticacao.CarregarConfigurado()
+	if err != nil {
+		slog.Error("Erro ao configurar autenticacao", "erro", err.Error())
+		os.Exit(1)
+	}
+	if autenticador == nil {
+		if os.Getenv("AUTENTICACAO_DESLIGADA") != "true" {
+			slog.Error("COGNITO_USER_POOL_ID/COGNITO_CLIENT_ID nao configurados; defina AUTENTICACAO_DESLIGADA=true apenas em desenvolvimento")
+			os.Exit(1)
+		}
+		slog.Warn("SECURITY: AUTENTICACAO_DESLIGADA=true; rotas da API sem autenticacao")
+	}
 
-	if err := consumidor.IniciarConsumidor(db, handlers); err != nil {
-		slog.Error("ERRO CRÍTICO: Falha ao iniciar consumidor RabbitMQ", "erro", err.Error())
+	despachante, err := email.CarregarConfigurado(db, docs)
+	if err != nil {
+		slog.Error("Erro ao configurar envio de email", "erro", err.Error())
 		os.Exit(1)
 	}
-	slog.Info("Consumidor RabbitMQ iniciado com sucesso")
+	if despachante == nil {
+		slog.Warn("EMAIL_SMTP_HOST nao configurado; notas autorizadas nao serao enviadas por email")
+	}
+
+	handlers := &manipulador.Handlers{DB: db, Certificado: certificado, Sefaz: clienteSefaz, Armazenamento: docs, Links: links, Email: despachante}
+
+	// Monitor do autorizador: liga a contingência quando ele cai e reenvia as notas
+	// pendentes quando volta
+	ctxMonitor, pararMonitor := context.WithCancel(context.Background())
+	defer pararMonitor()
+	if monitor := contingencia.NovoMonitor(db, clienteSefaz); monitor != nil {
+		monitor.AoNormalizar = func(ctx context.Context) {
+			if _, err := handlers.ReconciliarContingencia(ctx); err != nil {
+				slog.Error("Falha ao reenviar notas pendentes da contingencia", "erro", err.Error())
+			}
+		}
+		handlers.Contingencia = monitor
+		monitor.Iniciar(ctxMonitor)
+	}
+
+	// Conexões RabbitMQ, reconectadas automaticamente: uma para publicar e outra para
+	// consumir, para que o flow control do broker sobre a publicação não segure os
+	// consumidores. Sem RABBITMQ_URL ficam nil e publicador e consumidores não sobem.
+	conexaoPublicacao := mensageria.NovaConexao("publicacao")
+	conexaoConsumo := mensageria.NovaConexao("consumo")
+	if conexaoPublicacao != nil && conexaoConsumo != nil {
+		conexaoPublicacao.Iniciar(ctxMonitor)
+		conexaoConsumo.Iniciar(ctxMonitor)
+	}
+
+	publicador.IniciarPublicador(ctxMonitor, db, conexaoPublicacao)
+	consumidor.IniciarConsumidor(ctxMonitor, db, handlers, conexaoConsumo)
+	consumidor.IniciarAutorizador(ctxMonitor, handlers, conexaoConsumo)
+
+	// Gera os DANFEs, papel da lambda-pdf no deploy serverless
+	consumidor.IniciarGeradorPDF(ctxMonitor, &impressao.Processador{DB: db, Armazenamento: docs}, conexaoConsumo)
+
+	// Envia o DANFE e o XML das notas autorizadas; a varredura repete os envios que falharam
+	if despachante != nil {
+		despachante.Iniciar(ctxMonitor)
+		consumidor.IniciarEmail(ctxMonitor, despachante, conexaoConsumo)
+	}
 
 	// Configurar GIN mode
 	if os.Getenv("ENVIRONMENT") == "production" {
@@ -75,19 +171,85 @@ func main() {
 	})
 
 	// Health check robusto
-	r.GET("/health", gin.WrapH(health.Handler(db)))
+	r.GET("/health", gin.WrapH(health.Handler(db, certificado, conexaoPublicacao, conexaoConsumo)))
 
+	publicas := r.Group("/api/v1")
+	{
+		publicas.GET("/health", gin.WrapH(health.Handler(db, certificado, conexaoPublicacao, conexaoConsumo)))
+		// O acesso é conferido pela assinatura do link
+		publicas.GET("/documentos/notas/:id/:documento", handlers.BaixarDocumentoAssinado)
+	}
+
+	// Demais rotas exigem o ID token do Cognito, como na API Gateway
 	v1 := r.Group("/api/v1")
+	if autenticador != nil {
+		v1.Use(autenticador.Middleware())
+	}
 	{
-		v1.GET("/health", gin.WrapH(health.Handler(db)))
 		v1.POST("/notas", handlers.CriarNota)
 		v1.GET("/notas", handlers.ListarNotas)
 		v1.GET("/notas/:id", handlers.BuscarNota)
+		v1.GET("/notas/chave/:chave", handlers.BuscarNotaPorChaveHTTP)
+		v1.GET("/notas/:id/xml", handlers.BaixarXML)
+		v1.GET("/notas/:id/pdf", handlers.BaixarPDF)
+		v1.POST("/notas/:id/links", handlers.GerarLinkDocumento)
+		v1.POST("/notas/:id/pdf/regenerar", handlers.RegenerarPDF)
+		v1.GET("/notas/:id/documentos", handlers.ListarDocumentos)
 		v1.PUT("/notas/:id/fechar", handlers.FecharNotaManual)
+		v1.POST("/notas/:id/autorizar", handlers.ReenviarAutorizacao)
+		v1.POST("/notas/:id/reabrir", handlers.ReabrirNota)
+		v1.POST("/notas/:id/cancelar", handlers.CancelarNota)
+		v1.POST("/notas/:id/correcoes", handlers.RegistrarCartaCorrecao)
+		v1.GET("/notas/:id/correcoes", handlers.ListarCartasCorrecao)
+		v1.GET("/notas/:id/correcoes/:sequencia/xml", handlers.BaixarXMLCartaCorrecao)
 		v1.POST("/notas/:id/itens", handlers.AdicionarItem)
+		v1.POST("/notas/:id/pagamentos", handlers.AdicionarPagamento)
 		v1.POST("/notas/:id/imprimir", handlers.ImprimirNota)
+		v1.POST("/notas/:id/reenviar-email", handlers.ReenviarEmail)
+		v1.GET("/notas/:id/emails", handlers.ListarEnviosEmail)
+		v1.POST("/emails/devolucoes", handlers.RegistrarDevolucaoEmail)
+
+		v1.POST("/inutilizacoes", handlers.CriarInutilizacao)
+		v1.GET("/inutilizacoes", handlers.ListarInutilizacoes)
+		v1.GET("/inutilizacoes/:id", handlers.BuscarInutilizacao)
+		v1.GET("/inutilizacoes/:id/xml", handlers.BaixarXMLInutilizacao)
+		v1.GET("/numeracao/lacunas", handlers.ListarLacunas)
+
+		v1.GET("/sefaz/status", handlers.StatusSefaz)
+
+		v1.GET("/admin/contingencia", handlers.StatusContingenciaHTTP)
+		v1.POST("/admin/contingencia", handlers.AtivarContingencia)
+		v1.DELETE("/admin/contingencia", handlers.EncerrarContingencia)
+
+		v1.GET("/admin/outbox", handlers.ListarEventosOutbox)
+		v1.GET("/admin/outbox/:id", handlers.BuscarEventoOutbox)
+		v1.POST("/admin/outbox/:id/reenfileirar", handlers.ReenfileirarEventoOutbox)
 
 		v1.GET("/solicitacoes-impressao/:id", handlers.ConsultarStatusImpressao)
+
+		v1.POST("/emitentes", handlers.CriarEmitente)
+		v1.GET("/emitentes", handlers.ListarEmitentes)
+		v1.GET("/emitentes/:id", handlers.BuscarEmitente)
+		v1.PUT("/emitentes/:id", handlers.AtualizarEmitente)
+		v1.DELETE("/emitentes/:id", handlers.ExcluirEmitente)
+
+		v1.POST("/clientes", handlers.CriarCliente)
+		v1.GET("/clientes", handlers.ListarClientes)
+		v1.GET("/clientes/:id", handlers.BuscarCliente)
+		v1.PUT("/clientes/:id", handlers.AtualizarCliente)
+		v1.DELETE("/clientes/:id", handlers.ExcluirCliente)
+
+		v1.POST("/regras-tributarias", handlers.CriarRegraTributaria)
+		v1.GET("/regras-tributarias", handlers.ListarRegrasTributarias)
+		v1.GET("/regras-tributarias/:id", handlers.BuscarRegraTributaria)
+		v1.PUT("/regras-tributarias/:id", handlers.AtualizarRegraTributaria)
+		v1.DELETE("/regras-tributarias/:id", handlers.ExcluirRegraTributaria)
+
+		v1.POST("/aliquotas-ibs-cbs", handlers.CriarAliquotaIBSCBS)
+		v1.GET("/aliquotas-ibs-cbs", handlers.ListarAliquotasIBSCBS)
+		v1.GET("/aliquotas-ibs-cbs/:id", handlers.BuscarAliquotaIBSCBS)
+		v1.PUT("/aliquotas-ibs-cbs/:id", handlers.AtualizarAliquotaIBSCBS)
+		v1.DELETE("/aliquotas-ibs-cbs/:id", handlers.ExcluirAliquotaIBSCBS)
 	}
 
 	// Servidor HTTP com graceful shutdown
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/config"
+	"servico-faturamento/internal/consumidor"
+	"servico-faturamento/internal/contingencia"
+	"servico-faturamento/internal/logger"
+	"servico-faturamento/internal/manipulador"
+	"servico-faturamento/internal/publicador"
+	"servico-faturamento/internal/sefaz"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Authorizer transmits closed notes to SEFAZ when Faturamento.AutorizacaoSolicitada
+// reaches EventBridge (published directly on close and again by the outbox processor)
+type Authorizer struct {
+	handlers *manipulador.Handlers
+}
+
+func main() {
+	logger.Init()
+	slog.Info("Initializing SEFAZ Authorization Lambda")
+
+	db, err := config.InicializarDB()
+	if err != nil {
+		slog.Error("Failed to initialize database", "error", err)
+		panic(err)
+	}
+
+	certificado, err := assinatura.CarregarConfigurado()
+	if err != nil {
+		slog.Error("Failed to load A1 certificate", "error", err)
+		panic(err)
+	}
+
+	clienteSefaz, err := sefaz.CarregarConfigurado(certificado)
+	if err != nil {
+		slog.Error("Failed to configure SEFAZ client", "error", err)
+		panic(err)
+	}
+	if clienteSefaz == nil {
+		slog.Warn("SEFAZ_URL not configured, authorization events will be ignored")
+	}
+
+	// Authorized nfeProc XMLs are archived only when ARMAZENAMENTO is set
+	docs, err := armazenamento.CarregarConfigurado(context.Background(), "")
+	if err != nil {
+		slog.Error("Failed to configure document storage", "error", err)
+		panic(err)
+	}
+
+	if err := publicador.InicializarEventBridge(); err != nil {
+		slog.Error("Failed to initialize EventBridge", "error", err)
+	}
+
+	authorizer := &Authorizer{
+		handlers: &manipulador.Handlers{
+			DB: db, Certificado: certificado, Sefaz: clienteSefaz, Armazenamento: docs,
+			// Sem o monitor periódico: a saída da contingência é pela rota administrativa
+			Contingencia: contingencia.NovoMonitor(db, clienteSefaz),
+		},
+	}
+	lambda.Start(authorizer.HandleRequest)
+}
+
+// HandleRequest returns an error only for retryable failures (SEFAZ or database
+// unavailable), so the asynchronous invocation retries them
+func (a *Authorizer) HandleRequest(ctx context.Context, event events.CloudWatchEvent) error {
+	_ = ctx
+	slog.Info("SEFAZ Authorization Lambda invoked", "detailType", event.DetailType)
+
+	if a.handlers.Sefaz == nil {
+		return nil
+	}
+	return consumidor.ProcessarAutorizacao(a.handlers, event.Detail)
+}
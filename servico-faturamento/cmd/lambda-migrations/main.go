@@ -24,8 +24,11 @@ func handler(ctx context.Context) (Response, error) {
 	db.Exec("DELETE FROM eventos_outbox")
 	db.Exec("DELETE FROM mensagens_processadas")
 	db.Exec("DELETE FROM solicitacoes_impressao")
+	db.Exec("DELETE FROM documentos")
+	db.Exec("DELETE FROM envios_email")
 	db.Exec("DELETE FROM itens_nota")
 	db.Exec("DELETE FROM notas_fiscais")
+	db.Exec("DELETE FROM sequencias_numeracao")
 
 	// Limpar estoque
 	db.Exec("SET search_path TO estoque")
@@ -38,7 +41,8 @@ func handler(ctx context.Context) (Response, error) {
 	db.Exec(`INSERT INTO estoque.produtos (id, nome, saldo, data_criacao, data_atualizacao) VALUES ('550e8400-e29b-41d4-a716-446655440000', 'Produto Teste - Notebook Dell', 100, NOW(), NOW())`)
 	
 	db.Exec("SET search_path TO faturamento")
-	db.Exec(`INSERT INTO faturamento.notas_fiscais (id, numero, status, data_criacao) VALUES ('a1b2c3d4-e5f6-4a5b-8c9d-0e1f2a3b4c5d', 'NFE-TESTE-001', 'ABERTA', NOW())`)
+	db.Exec(`INSERT INTO faturamento.notas_fiscais (id, numero, serie, status, data_criacao) VALUES ('a1b2c3d4-e5f6-4a5b-8c9d-0e1f2a3b4c5d', '1', 1, 'ABERTA', NOW())`)
+	db.Exec(`INSERT INTO faturamento.sequencias_numeracao (cnpj_emitente, modelo, serie, ultimo_numero, data_atualizacao) VALUES ('', '55', 1, 1, NOW())`)
 	db.Exec(`INSERT INTO faturamento.itens_nota (id, nota_id, produto_id, quantidade, preco_unitario) VALUES ('f6e5d4c3-b2a1-4c5d-8e9f-0a1b2c3d4e5f', 'a1b2c3d4-e5f6-4a5b-8c9d-0e1f2a3b4c5d', '550e8400-e29b-41d4-a716-446655440000', 2, 1500.00)`)
 
 	return Response{
@@ -3,8 +3,8 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
-	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"gorm.io/gorm"
@@ -18,6 +18,9 @@ import (
 // OutboxProcessor processa eventos pendentes na tabela outbox
 type OutboxProcessor struct {
 	db *gorm.DB
+	// id identifica o contêiner nas reservas; execuções sobrepostas do agendamento
+	// não publicam o mesmo evento
+	id string
 }
 
 func NewOutboxProcessor() (*OutboxProcessor, error) {
@@ -39,6 +42,7 @@ func NewOutboxProcessor() (*OutboxProcessor, error) {
 
 	return &OutboxProcessor{
 		db: db,
+		id: publicador.IdentificarPublicador(),
 	}, nil
 }
 
@@ -46,9 +50,10 @@ func NewOutboxProcessor() (*OutboxProcessor, error) {
 func (p *OutboxProcessor) HandleRequest(ctx context.Context) error {
 	slog.Info("Outbox processor triggered - processing pending events")
 
-	// Buscar eventos pendentes (não publicados)
-	var eventos []dominio.EventoOutbox
-	if err := p.db.Where("data_publicacao IS NULL").Order("id").Limit(50).Find(&eventos).Error; err != nil {
+	// Reservar eventos pendentes (não publicados, fora da espera entre tentativas e
+	// o mais antigo de cada agregado)
+	eventos, err := publicador.ReservarPendentes(p.db, p.id, 50)
+	if err != nil {
 		slog.Error("Failed to load pending events from outbox", "error", err)
 		return err
 	}
@@ -68,9 +73,8 @@ func (p *OutboxProcessor) HandleRequest(ctx context.Context) error {
 		// Deserializar payload (é uma string JSON, precisa virar objeto)
 		var payloadObj interface{}
 		if err := json.Unmarshal([]byte(evt.Payload), &payloadObj); err != nil {
-			slog.Error("Failed to unmarshal event payload",
-				"eventId", evt.ID,
-				"error", err)
+			// Payload ilegível não se corrige sozinho: o evento vai direto para MORTO
+			p.registrarFalha(&evt, fmt.Errorf("%w: %w", publicador.ErrEventoInvalido, err))
 			errorCount++
 			continue
 		}
@@ -78,19 +82,13 @@ func (p *OutboxProcessor) HandleRequest(ctx context.Context) error {
 		// Publicar evento no EventBridge
 		err := publicador.PublicarEvento(ctx, evt.TipoEvento, evt.IdAgregado.String(), payloadObj)
 		if err != nil {
-			slog.Error("Failed to publish event to EventBridge",
-				"eventId", evt.ID,
-				"eventType", evt.TipoEvento,
-				"error", err)
+			p.registrarFalha(&evt, err)
 			errorCount++
 			continue
 		}
 
 		// Marcar como publicado
-		now := time.Now()
-		if err := p.db.Model(&dominio.EventoOutbox{}).
-			Where("id = ?", evt.ID).
-			Update("data_publicacao", now).Error; err != nil {
+		if err := publicador.RegistrarPublicacao(p.db, &evt); err != nil {
 			slog.Error("Event published but failed to update data_publicacao",
 				"eventId", evt.ID,
 				"error", err)
@@ -113,6 +111,15 @@ func (p *OutboxProcessor) HandleRequest(ctx context.Context) error {
 	return nil
 }
 
+// registrarFalha grava a tentativa e a próxima publicação do evento (ou o estado MORTO)
+func (p *OutboxProcessor) registrarFalha(evt *dominio.EventoOutbox, falha error) {
+	if err := publicador.RegistrarFalha(p.db, evt, falha); err != nil {
+		slog.Error("Failed to record outbox event attempt",
+			"eventId", evt.ID,
+			"error", err)
+	}
+}
+
 func main() {
 	processor, err := NewOutboxProcessor()
 	if err != nil {
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"servico-faturamento/internal/manipulador"
+	"servico-faturamento/internal/sefaz"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+func autorizacaoErrorResponse(err error, origin string) events.APIGatewayProxyResponse {
+	status, corpo := manipulador.RespostaErroAutorizacao(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Error handling SEFAZ authorization", "error", err)
+	}
+	return jsonResponse(status, corpo, origin)
+}
+
+// handleReenviarAutorizacao atende POST /notas/:id/autorizar
+func (h *LambdaHandler) handleReenviarAutorizacao(ctx context.Context, notaID string, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	if err := h.handlers.ReenviarAutorizacaoDB(id); err != nil {
+		return autorizacaoErrorResponse(err, origin), nil
+	}
+	return jsonResponse(http.StatusAccepted, map[string]string{"mensagem": "Autorizacao solicitada", "notaId": id.String()}, origin), nil
+}
+
+// handleReabrirNota atende POST /notas/:id/reabrir
+func (h *LambdaHandler) handleReabrirNota(ctx context.Context, notaID string, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	nota, err := h.handlers.ReabrirNotaDB(id)
+	if err != nil {
+		return autorizacaoErrorResponse(err, origin), nil
+	}
+	return jsonResponse(http.StatusOK, nota, origin), nil
+}
+
+// handleStatusSefaz atende GET /sefaz/status
+func (h *LambdaHandler) handleStatusSefaz(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	if strings.Trim(request.Path, "/") != "api/v1/sefaz/status" {
+		return errorResponse(http.StatusNotFound, "Rota não encontrada", origin), nil
+	}
+	if request.HTTPMethod != http.MethodGet {
+		return errorResponse(http.StatusMethodNotAllowed, "Método não permitido", origin), nil
+	}
+
+	ret, err := h.handlers.StatusSefazDB(ctx)
+	if err != nil {
+		return autorizacaoErrorResponse(err, origin), nil
+	}
+	status := http.StatusOK
+	if ret.CStat != sefaz.CStatServicoEmOperacao {
+		status = http.StatusServiceUnavailable
+	}
+	return jsonResponse(status, ret, origin), nil
+}
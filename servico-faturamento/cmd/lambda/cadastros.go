@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/manipulador"
+)
+
+// cadastroErrorResponse usa o mesmo mapeamento de erros da API Gin
+func cadastroErrorResponse(err error, origin string) events.APIGatewayProxyResponse {
+	status, corpo := manipulador.RespostaErroCadastro(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Error handling cadastro", "error", err)
+	}
+	return jsonResponse(status, corpo, origin)
+}
+
+// cadastroID extrai o ID de /api/v1/<recurso>/<id>
+func cadastroID(path string) (*uuid.UUID, bool) {
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(pathParts) <= 3 {
+		return nil, true
+	}
+	id, err := uuid.Parse(pathParts[3])
+	if err != nil {
+		return nil, false
+	}
+	return &id, true
+}
+
+func (h *LambdaHandler) handleEmitentesRoutes(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, ok := cadastroID(request.Path)
+	if !ok {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	switch {
+	case request.HTTPMethod == "GET" && id == nil:
+		var emitentes []dominio.Emitente
+		query := h.handlers.DB.Order("razao_social")
+		if cnpj := request.QueryStringParameters["cnpj"]; cnpj != "" {
+			query = query.Where("cnpj = ?", dominio.SomenteDigitos(cnpj))
+		}
+		if err := query.Find(&emitentes).Error; err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, emitentes, origin), nil
+
+	case request.HTTPMethod == "GET":
+		var emitente dominio.Emitente
+		if err := h.handlers.DB.First(&emitente, "id = ?", *id).Error; err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, emitente, origin), nil
+
+	case request.HTTPMethod == "POST" && id == nil:
+		var emitente dominio.Emitente
+		if err := json.Unmarshal([]byte(request.Body), &emitente); err != nil {
+			return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+		}
+		if err := h.handlers.CriarEmitenteDB(&emitente); err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusCreated, emitente, origin), nil
+
+	case request.HTTPMethod == "PUT" && id != nil:
+		var dados dominio.Emitente
+		if err := json.Unmarshal([]byte(request.Body), &dados); err != nil {
+			return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+		}
+		emitente, err := h.handlers.AtualizarEmitenteDB(*id, dados)
+		if err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, emitente, origin), nil
+
+	case request.HTTPMethod == "DELETE" && id != nil:
+		if err := h.handlers.ExcluirEmitenteDB(*id); err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent, Headers: corsHeaders(origin)}, nil
+	}
+
+	return errorResponse(http.StatusMethodNotAllowed, "Method not allowed", origin), nil
+}
+
+func (h *LambdaHandler) handleClientesRoutes(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, ok := cadastroID(request.Path)
+	if !ok {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	switch {
+	case request.HTTPMethod == "GET" && id == nil:
+		var clientes []dominio.Cliente
+		query := h.handlers.DB.Order("nome")
+		if documento := request.QueryStringParameters["documento"]; documento != "" {
+			query = query.Where("documento = ?", dominio.SomenteDigitos(documento))
+		}
+		if nome := request.QueryStringParameters["nome"]; nome != "" {
+			query = query.Where("nome ILIKE ?", "%"+nome+"%")
+		}
+		if err := query.Find(&clientes).Error; err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, clientes, origin), nil
+
+	case request.HTTPMethod == "GET":
+		var cliente dominio.Cliente
+		if err := h.handlers.DB.First(&cliente, "id = ?", *id).Error; err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, cliente, origin), nil
+
+	case request.HTTPMethod == "POST" && id == nil:
+		var cliente dominio.Cliente
+		if err := json.Unmarshal([]byte(request.Body), &cliente); err != nil {
+			return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+		}
+		if err := h.handlers.CriarClienteDB(&cliente); err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusCreated, cliente, origin), nil
+
+	case request.HTTPMethod == "PUT" && id != nil:
+		var dados dominio.Cliente
+		if err := json.Unmarshal([]byte(request.Body), &dados); err != nil {
+			return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+		}
+		cliente, err := h.handlers.AtualizarClienteDB(*id, dados)
+		if err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, cliente, origin), nil
+
+	case request.HTTPMethod == "DELETE" && id != nil:
+		if err := h.handlers.ExcluirClienteDB(*id); err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent, Headers: corsHeaders(origin)}, nil
+	}
+
+	return errorResponse(http.StatusMethodNotAllowed, "Method not allowed", origin), nil
+}
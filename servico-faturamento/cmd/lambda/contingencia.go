@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"servico-faturamento/internal/contingencia"
+	"servico-faturamento/internal/health"
+	"servico-faturamento/internal/manipulador"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func contingenciaErrorResponse(err error, origin string) events.APIGatewayProxyResponse {
+	status, corpo := manipulador.RespostaErroContingencia(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Error handling contingency mode", "error", err)
+	}
+	return jsonResponse(status, corpo, origin)
+}
+
+// checkContingencia informa o modo de emissão no /health da Lambda
+func (h *LambdaHandler) checkContingencia() health.CheckResult {
+	vigente, err := contingencia.Atual(h.handlers.DB)
+	if err != nil {
+		return health.CheckResult{Status: "warn", Error: "falha ao consultar modo de emissao: " + err.Error()}
+	}
+	pendentes, err := contingencia.Pendentes(h.handlers.DB)
+	if err != nil {
+		return health.CheckResult{Status: "warn", Error: "falha ao contar notas pendentes: " + err.Error()}
+	}
+	return health.CheckContingencia(vigente, pendentes)
+}
+
+// handleContingencia atende GET, POST e DELETE /admin/contingencia
+func (h *LambdaHandler) handleContingencia(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	if strings.Trim(request.Path, "/") != "api/v1/admin/contingencia" {
+		return errorResponse(http.StatusNotFound, "Rota não encontrada", origin), nil
+	}
+
+	switch request.HTTPMethod {
+	case http.MethodGet:
+		status, err := h.handlers.StatusContingenciaDB()
+		if err != nil {
+			return contingenciaErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, status, origin), nil
+	case http.MethodPost:
+		var dados manipulador.DadosContingencia
+		if err := json.Unmarshal([]byte(request.Body), &dados); err != nil {
+			return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+		}
+		vigente, err := h.handlers.AtivarContingenciaDB(dados)
+		if err != nil {
+			return contingenciaErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusCreated, vigente, origin), nil
+	case http.MethodDelete:
+		encerrada, reenviadas, err := h.handlers.EncerrarContingenciaDB(ctx)
+		if err != nil {
+			return contingenciaErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, map[string]interface{}{"encerrada": encerrada, "notasReenviadas": reenviadas}, origin), nil
+	default:
+		return errorResponse(http.StatusMethodNotAllowed, "Método não permitido", origin), nil
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"servico-faturamento/internal/manipulador"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+func cartaCorrecaoErrorResponse(err error, origin string) events.APIGatewayProxyResponse {
+	status, corpo := manipulador.RespostaErroCartaCorrecao(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Error handling carta de correcao", "error", err)
+	}
+	return jsonResponse(status, corpo, origin)
+}
+
+func (h *LambdaHandler) handleRegistrarCartaCorrecao(ctx context.Context, notaID string, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	var req manipulador.DadosCartaCorrecao
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+	}
+
+	carta, err := h.handlers.RegistrarCartaCorrecaoDB(id, req.Correcao)
+	if err != nil {
+		return cartaCorrecaoErrorResponse(err, origin), nil
+	}
+	return jsonResponse(http.StatusCreated, carta, origin), nil
+}
+
+// handleGetCartasCorrecao atende /notas/:id/correcoes e /notas/:id/correcoes/:sequencia/xml
+func (h *LambdaHandler) handleGetCartasCorrecao(ctx context.Context, notaID string, resto []string, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	switch {
+	case len(resto) == 0:
+		cartas, err := h.handlers.ListarCartasCorrecaoDB(id)
+		if err != nil {
+			return cartaCorrecaoErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, cartas, origin), nil
+
+	case len(resto) == 2 && resto[1] == "xml":
+		sequencia, err := strconv.Atoi(resto[0])
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "Sequencia invalida", origin), nil
+		}
+		xmlEvento, err := h.handlers.XMLCartaCorrecaoDB(id, sequencia)
+		if err != nil {
+			return cartaCorrecaoErrorResponse(err, origin), nil
+		}
+		return xmlResponse(http.StatusOK, xmlEvento, origin), nil
+
+	default:
+		return errorResponse(http.StatusNotFound, "Rota não encontrada", origin), nil
+	}
+}
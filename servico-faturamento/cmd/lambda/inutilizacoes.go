@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"servico-faturamento/internal/manipulador"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+func inutilizacaoErrorResponse(err error, origin string) events.APIGatewayProxyResponse {
+	status, corpo := manipulador.RespostaErroInutilizacao(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Error handling inutilizacao", "error", err)
+	}
+	return jsonResponse(status, corpo, origin)
+}
+
+// handleInutilizacoesRoutes atende /inutilizacoes, /inutilizacoes/:id e /inutilizacoes/:id/xml
+func (h *LambdaHandler) handleInutilizacoesRoutes(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	pathParts := strings.Split(strings.Trim(request.Path, "/"), "/")
+
+	if len(pathParts) == 3 {
+		switch request.HTTPMethod {
+		case http.MethodPost:
+			var req manipulador.DadosInutilizacao
+			if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+				return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+			}
+			inut, err := h.handlers.CriarInutilizacaoDB(req)
+			if err != nil {
+				return inutilizacaoErrorResponse(err, origin), nil
+			}
+			return jsonResponse(http.StatusCreated, inut, origin), nil
+
+		case http.MethodGet:
+			q := request.QueryStringParameters
+			inutilizacoes, err := h.handlers.ListarInutilizacoesDB(q["cnpj"], q["modelo"], q["serie"])
+			if err != nil {
+				return inutilizacaoErrorResponse(err, origin), nil
+			}
+			return jsonResponse(http.StatusOK, inutilizacoes, origin), nil
+		}
+		return errorResponse(http.StatusMethodNotAllowed, "Método não permitido", origin), nil
+	}
+
+	if request.HTTPMethod != http.MethodGet || len(pathParts) > 5 || (len(pathParts) == 5 && pathParts[4] != "xml") {
+		return errorResponse(http.StatusNotFound, "Rota não encontrada", origin), nil
+	}
+
+	id, err := uuid.Parse(pathParts[3])
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+	inut, err := h.handlers.BuscarInutilizacaoDB(id)
+	if err != nil {
+		return inutilizacaoErrorResponse(err, origin), nil
+	}
+	if len(pathParts) == 5 {
+		return xmlResponse(http.StatusOK, []byte(inut.XML), origin), nil
+	}
+	return jsonResponse(http.StatusOK, inut, origin), nil
+}
+
+// handleLacunasNumeracao atende GET /numeracao/lacunas
+func (h *LambdaHandler) handleLacunasNumeracao(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	if request.HTTPMethod != http.MethodGet || strings.Trim(request.Path, "/") != "api/v1/numeracao/lacunas" {
+		return errorResponse(http.StatusNotFound, "Rota não encontrada", origin), nil
+	}
+
+	q := request.QueryStringParameters
+	emitenteID, serie, ok := manipulador.ParametrosSerie(q["emitenteId"], q["serie"])
+	if !ok {
+		return errorResponse(http.StatusBadRequest, "emitenteId ou serie invalidos", origin), nil
+	}
+	lacunas, err := h.handlers.LacunasDB(emitenteID, q["modelo"], serie)
+	if err != nil {
+		return inutilizacaoErrorResponse(err, origin), nil
+	}
+	return jsonResponse(http.StatusOK, lacunas, origin), nil
+}
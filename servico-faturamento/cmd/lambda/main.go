@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,11 +16,17 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 
 	// Importar packages do próprio serviço
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/assinatura"
 	appConfig "servico-faturamento/internal/config"
 	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/health"
+	"servico-faturamento/internal/linkassinado"
 	"servico-faturamento/internal/logger"
 	"servico-faturamento/internal/manipulador"
+	"servico-faturamento/internal/mensageria"
 	"servico-faturamento/internal/publicador"
+	"servico-faturamento/internal/sefaz"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -41,8 +48,35 @@ func NewLambdaHandler() (*LambdaHandler, error) {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
+	// Load A1 certificate used to sign generated XMLs
+	certificado, err := assinatura.CarregarConfigurado()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load A1 certificate: %w", err)
+	}
+	if certificado == nil {
+		slog.Warn("A1 certificate not configured, XMLs will be unsigned")
+	}
+
+	// SEFAZ client, used here only for the service status; authorization runs in lambda-autorizacao
+	clienteSefaz, err := sefaz.CarregarConfigurado(certificado)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure SEFAZ client: %w", err)
+	}
+
+	// Document storage is optional here: without ARMAZENAMENTO the XMLs stay only in the database
+	docs, err := armazenamento.CarregarConfigurado(context.Background(), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure document storage: %w", err)
+	}
+
+	// Signed download links; without LINKS_SEGREDO the links endpoint answers 503
+	links, err := linkassinado.CarregarConfigurado()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure signed links: %w", err)
+	}
+
 	// Initialize handlers
-	handlers := &manipulador.Handlers{DB: db}
+	handlers := &manipulador.Handlers{DB: db, Certificado: certificado, Sefaz: clienteSefaz, Armazenamento: docs, Links: links}
 
 	// Initialize EventBridge publisher (for serverless mode)
 	if err := publicador.InicializarEventBridge(); err != nil {
@@ -51,10 +85,11 @@ func NewLambdaHandler() (*LambdaHandler, error) {
 	}
 
 	// Initialize outbox publisher (RabbitMQ - disabled in serverless)
-	if err := publicador.IniciarPublicador(db); err != nil {
-		slog.Error("Failed to start outbox publisher", "error", err)
-		// Don't fail, just log
+	conexao := mensageria.NovaConexao("publicacao")
+	if conexao != nil {
+		conexao.Iniciar(context.Background())
 	}
+	publicador.IniciarPublicador(context.Background(), db, conexao)
 
 	return &LambdaHandler{
 		handlers: handlers,
@@ -84,8 +119,28 @@ func (h *LambdaHandler) HandleRequest(ctx context.Context, request events.APIGat
 	switch {
 	case strings.HasPrefix(request.Path, "/api/v1/notas"):
 		return h.handleNotasRoutes(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/documentos/notas"):
+		return h.handleDocumentoAssinado(ctx, request, origin)
 	case strings.HasPrefix(request.Path, "/api/v1/solicitacoes-impressao"):
 		return h.handleSolicitacoesRoutes(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/emitentes"):
+		return h.handleEmitentesRoutes(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/clientes"):
+		return h.handleClientesRoutes(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/regras-tributarias"):
+		return h.handleRegrasTributariasRoutes(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/aliquotas-ibs-cbs"):
+		return h.handleAliquotasIBSCBSRoutes(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/inutilizacoes"):
+		return h.handleInutilizacoesRoutes(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/numeracao"):
+		return h.handleLacunasNumeracao(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/sefaz"):
+		return h.handleStatusSefaz(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/admin/contingencia"):
+		return h.handleContingencia(ctx, request, origin)
+	case strings.HasPrefix(request.Path, "/api/v1/admin/outbox"):
+		return h.handleOutbox(ctx, request, origin)
 	default:
 		return events.APIGatewayProxyResponse{
 			StatusCode: http.StatusNotFound,
@@ -97,11 +152,22 @@ func (h *LambdaHandler) HandleRequest(ctx context.Context, request events.APIGat
 
 func (h *LambdaHandler) handleHealthCheck(ctx context.Context, origin string) (events.APIGatewayProxyResponse, error) {
 	_ = ctx
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusOK,
-		Headers:    corsHeaders(origin),
-		Body:       `{"status":"healthy","service":"faturamento","version":"1.0.0"}`,
-	}, nil
+	checks := map[string]health.CheckResult{
+		"certificado":  health.CheckCertificado(h.handlers.Certificado, time.Now(), assinatura.AlertaExpiracao()),
+		"contingencia": h.checkContingencia(),
+	}
+	status := "healthy"
+	for _, check := range checks {
+		if check.Status == "warn" {
+			status = "degraded"
+		}
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{
+		"status":  status,
+		"service": "faturamento",
+		"version": "1.0.0",
+		"checks":  checks,
+	}, origin), nil
 }
 
 func (h *LambdaHandler) handleNotasRoutes(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
@@ -117,18 +183,54 @@ func (h *LambdaHandler) handleNotasRoutes(ctx context.Context, request events.AP
 
 	switch request.HTTPMethod {
 	case "GET":
+		if notaID == "chave" {
+			return h.handleGetNotaPorChave(ctx, subresource, origin)
+		}
 		if notaID != "" && subresource == "" {
 			return h.handleGetNota(ctx, notaID, origin)
 		}
+		if notaID != "" && subresource == "xml" {
+			return h.handleGetNotaXML(ctx, notaID, origin)
+		}
+		if notaID != "" && subresource == "pdf" {
+			return h.handleGetDocumento(ctx, notaID, manipulador.DocumentoPDF, request.QueryStringParameters["versao"], origin)
+		}
+		if notaID != "" && subresource == "documentos" {
+			return h.handleListDocumentos(ctx, notaID, origin)
+		}
+		if notaID != "" && subresource == "correcoes" {
+			return h.handleGetCartasCorrecao(ctx, notaID, pathParts[5:], origin)
+		}
 		return h.handleListNotas(ctx, request, origin)
 
 	case "POST":
 		if notaID != "" && subresource == "itens" {
 			return h.handleAddItem(ctx, notaID, request, origin)
 		}
+		if notaID != "" && subresource == "pagamentos" {
+			return h.handleAddPagamento(ctx, notaID, request, origin)
+		}
 		if notaID != "" && subresource == "imprimir" {
 			return h.handleImprimirNota(ctx, notaID, request, origin)
 		}
+		if notaID != "" && subresource == "cancelar" {
+			return h.handleCancelarNota(ctx, notaID, request, origin)
+		}
+		if notaID != "" && subresource == "autorizar" {
+			return h.handleReenviarAutorizacao(ctx, notaID, origin)
+		}
+		if notaID != "" && subresource == "reabrir" {
+			return h.handleReabrirNota(ctx, notaID, origin)
+		}
+		if notaID != "" && subresource == "correcoes" && len(pathParts) == 5 {
+			return h.handleRegistrarCartaCorrecao(ctx, notaID, request, origin)
+		}
+		if notaID != "" && subresource == "links" {
+			return h.handleGerarLinkDocumento(ctx, notaID, request, origin)
+		}
+		if notaID != "" && subresource == "pdf" && len(pathParts) == 6 && pathParts[5] == "regenerar" {
+			return h.handleRegenerarPDF(ctx, notaID, request, origin)
+		}
 		if notaID == "" {
 			return h.handleCreateNota(ctx, request, origin)
 		}
@@ -152,14 +254,171 @@ func (h *LambdaHandler) handleGetNota(ctx context.Context, notaID string, origin
 	_ = ctx
 	var nota dominio.NotaFiscal
 
-	if err := h.handlers.DB.Preload("Itens").First(&nota, "id = ?", notaID).Error; err != nil {
+	if err := h.handlers.DB.Preload("Itens").Preload("Emitente").Preload("Cliente").Preload("Pagamentos").First(&nota, "id = ?", notaID).Error; err != nil {
 		slog.Error("Error getting nota", "error", err, "id", notaID)
 		return errorResponse(http.StatusNotFound, "Nota not found", origin), nil
 	}
 
+	totais := nota.CalcularTotais()
+	nota.Totais = &totais
+	return jsonResponse(http.StatusOK, nota, origin), nil
+}
+
+func (h *LambdaHandler) handleGetNotaPorChave(ctx context.Context, chave string, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	nota, err := h.handlers.BuscarNotaPorChave(chave)
+	if err != nil {
+		if errors.Is(err, dominio.ErrChaveAcessoInvalida) {
+			return errorResponse(http.StatusBadRequest, err.Error(), origin), nil
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errorResponse(http.StatusNotFound, "Nota nao encontrada", origin), nil
+		}
+		slog.Error("Error getting nota by chave", "error", err)
+		return errorResponse(http.StatusInternalServerError, "Falha ao buscar nota", origin), nil
+	}
+
 	return jsonResponse(http.StatusOK, nota, origin), nil
 }
 
+func (h *LambdaHandler) handleGetNotaXML(ctx context.Context, notaID string, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	xmlNFe, err := h.handlers.GerarXML(id)
+	if err != nil {
+		status, corpo := manipulador.RespostaErroXML(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Error generating nota XML", "error", err, "id", notaID)
+		}
+		return jsonResponse(status, corpo, origin), nil
+	}
+
+	return xmlResponse(http.StatusOK, xmlNFe, origin), nil
+}
+
+func (h *LambdaHandler) handleGetDocumento(ctx context.Context, notaID string, documento string, versaoParam string, origin string) (events.APIGatewayProxyResponse, error) {
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	versao, err := manipulador.LerVersaoDocumento(versaoParam)
+	if err != nil {
+		status, corpo := manipulador.RespostaErroDocumento(err)
+		return jsonResponse(status, corpo, origin), nil
+	}
+
+	doc, err := h.handlers.DocumentoNotaDB(ctx, id, documento, versao)
+	if err != nil {
+		status, corpo := manipulador.RespostaErroDocumento(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Error loading nota document", "error", err, "id", notaID, "documento", documento)
+		}
+		return jsonResponse(status, corpo, origin), nil
+	}
+
+	return documentResponse(doc, origin), nil
+}
+
+func (h *LambdaHandler) handleListDocumentos(ctx context.Context, notaID string, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	documentos, err := h.handlers.ListarDocumentosDB(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errorResponse(http.StatusNotFound, "Nota nao encontrada", origin), nil
+	}
+	if err != nil {
+		slog.Error("Error listing nota documents", "error", err, "id", notaID)
+		return errorResponse(http.StatusInternalServerError, "Falha ao listar documentos", origin), nil
+	}
+
+	return jsonResponse(http.StatusOK, documentos, origin), nil
+}
+
+func (h *LambdaHandler) handleRegenerarPDF(ctx context.Context, notaID string, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	var req manipulador.DadosRegeneracaoPDF
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return errorResponse(http.StatusBadRequest, "Invalid request body", origin), nil
+	}
+
+	regeneracao, err := h.handlers.RegenerarPDFDB(ctx, id, req.Motivo)
+	if err != nil {
+		status, corpo := manipulador.RespostaErroRegeneracao(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Error regenerating PDF", "error", err, "id", notaID)
+		}
+		return jsonResponse(status, corpo, origin), nil
+	}
+
+	status := http.StatusOK
+	if regeneracao.NovaVersao {
+		status = http.StatusCreated
+	}
+	return jsonResponse(status, regeneracao, origin), nil
+}
+
+func (h *LambdaHandler) handleGerarLinkDocumento(ctx context.Context, notaID string, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	var req manipulador.DadosLinkDocumento
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return errorResponse(http.StatusBadRequest, "Invalid request body", origin), nil
+	}
+
+	link, err := h.handlers.GerarLinkDocumentoDB(id, req.Documento)
+	if err != nil {
+		status, corpo := manipulador.RespostaErroDocumento(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Error generating signed link", "error", err, "id", notaID)
+		}
+		return jsonResponse(status, corpo, origin), nil
+	}
+
+	return jsonResponse(http.StatusCreated, link, origin), nil
+}
+
+// handleDocumentoAssinado serves GET /api/v1/documentos/notas/{id}/{documento}, a public
+// route (no authorizer) whose access is granted by the link signature
+func (h *LambdaHandler) handleDocumentoAssinado(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	if request.HTTPMethod != "GET" {
+		return errorResponse(http.StatusMethodNotAllowed, "Method not allowed", origin), nil
+	}
+	pathParts := strings.Split(strings.Trim(request.Path, "/"), "/")
+	if len(pathParts) != 6 {
+		return errorResponse(http.StatusNotFound, "Rota não encontrada", origin), nil
+	}
+	id, err := uuid.Parse(pathParts[4])
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	documento := pathParts[5]
+	expira := request.QueryStringParameters[linkassinado.ParamExpira]
+	assinatura := request.QueryStringParameters[linkassinado.ParamAssinatura]
+	if err := h.handlers.ConferirLinkDocumento(id, documento, expira, assinatura); err != nil {
+		status, corpo := manipulador.RespostaErroDocumento(err)
+		return jsonResponse(status, corpo, origin), nil
+	}
+	return h.handleGetDocumento(ctx, pathParts[4], documento, "", origin)
+}
+
 func (h *LambdaHandler) handleListNotas(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
 	_ = ctx
 	var notas []dominio.NotaFiscal
@@ -180,12 +439,11 @@ func (h *LambdaHandler) handleListNotas(ctx context.Context, request events.APIG
 
 func (h *LambdaHandler) handleCreateNota(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
 	var req struct {
-		Numero   string `json:"numero"`
-		Cliente  string `json:"cliente"`
+		manipulador.DadosNovaNota
 		Produtos []struct {
-			SKU            string  `json:"sku"`
-			Quantidade     int     `json:"quantidade"`
-			PrecoUnitario  float64 `json:"precoUnitario"`
+			SKU           string          `json:"sku"`
+			Quantidade    dominio.Decimal `json:"quantidade"`
+			PrecoUnitario dominio.Decimal `json:"precoUnitario"`
 		} `json:"produtos"`
 	}
 
@@ -193,25 +451,20 @@ func (h *LambdaHandler) handleCreateNota(ctx context.Context, request events.API
 		return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
 	}
 
-	if strings.TrimSpace(req.Numero) == "" {
-		return errorResponse(http.StatusBadRequest, "Numero obrigatório", origin), nil
-	}
-
-	nota := dominio.NotaFiscal{
-		Numero: req.Numero,
-		Status: dominio.StatusNotaAberta,
-	}
-
-	if err := h.handlers.DB.Create(&nota).Error; err != nil {
-		slog.Error("Error creating nota", "error", err)
-		return errorResponse(http.StatusInternalServerError, "Failed to create nota", origin), nil
+	nota, err := h.handlers.NovaNota(req.DadosNovaNota)
+	if err != nil {
+		status, corpo := manipulador.RespostaErroCriacao(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Error creating nota", "error", err)
+		}
+		return jsonResponse(status, corpo, origin), nil
 	}
 
 	// Se produtos foram enviados, publicar evento para reserva de estoque
 	if len(req.Produtos) > 0 {
 		type itemEvento struct {
-			SKU        string `json:"sku"`
-			Quantidade int    `json:"quantidade"`
+			SKU        string          `json:"sku"`
+			Quantidade dominio.Decimal `json:"quantidade"`
 		}
 
 		type payloadEvento struct {
@@ -252,7 +505,9 @@ func (h *LambdaHandler) handleUpdateNota(ctx context.Context, notaID string, req
 		return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
 	}
 
-	if err := h.handlers.DB.Model(&nota).Where("id = ?", notaID).Updates(&nota).Error; err != nil {
+	// Numeração e chave são atribuídas pelo servidor e não podem ser alteradas
+	if err := h.handlers.DB.Model(&nota).Where("id = ?", notaID).
+		Omit("numero", "cnpj_emitente", "modelo", "serie", "chave_acesso", "emitente_id").Updates(&nota).Error; err != nil {
 		slog.Error("Error updating nota", "error", err, "id", notaID)
 		return errorResponse(http.StatusInternalServerError, "Failed to update nota", origin), nil
 	}
@@ -268,6 +523,9 @@ func (h *LambdaHandler) handleFecharNota(ctx context.Context, notaID string, ori
 	}
 
 	if err := h.handlers.FecharNota(id); err != nil {
+		if status, corpo := manipulador.RespostaErroPagamento(err); status != http.StatusInternalServerError {
+			return jsonResponse(status, corpo, origin), nil
+		}
 		errMsg := err.Error()
 		if errMsg == "nota deve ter status ABERTA para ser fechada" {
 			return errorResponse(http.StatusBadRequest, "Nota ja esta fechada ou com status invalido", origin), nil
@@ -282,6 +540,30 @@ func (h *LambdaHandler) handleFecharNota(ctx context.Context, notaID string, ori
 	return jsonResponse(http.StatusOK, map[string]string{"mensagem": "Nota fechada com sucesso"}, origin), nil
 }
 
+func (h *LambdaHandler) handleCancelarNota(ctx context.Context, notaID string, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	var req manipulador.DadosCancelamento
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+	}
+
+	nota, err := h.handlers.CancelarNotaDB(id, req.Justificativa)
+	if err != nil {
+		status, corpo := manipulador.RespostaErroCancelamento(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Error cancelling nota", "error", err, "id", notaID)
+		}
+		return jsonResponse(status, corpo, origin), nil
+	}
+
+	return jsonResponse(http.StatusOK, nota, origin), nil
+}
+
 func (h *LambdaHandler) handleSolicitacoesRoutes(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
 	_ = ctx
 	if request.HTTPMethod != "GET" {
@@ -304,8 +586,8 @@ func (h *LambdaHandler) handleConsultarStatusImpressao(ctx context.Context, soli
 		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
 	}
 
-	var sol dominio.SolicitacaoImpressao
-	if err := h.handlers.DB.First(&sol, "id = ?", id).Error; err != nil {
+	sol, err := h.handlers.ConsultarSolicitacaoDB(id)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errorResponse(http.StatusNotFound, "Solicitacao nao encontrada", origin), nil
 		}
@@ -323,16 +605,21 @@ func (h *LambdaHandler) handleAddItem(ctx context.Context, notaID string, reques
 	}
 
 	var req struct {
-		ProdutoID     string  `json:"produtoId"`
-		Quantidade    int     `json:"quantidade"`
-		PrecoUnitario float64 `json:"precoUnitario"`
+		ProdutoID     string          `json:"produtoId"`
+		Quantidade    dominio.Decimal `json:"quantidade"`
+		PrecoUnitario dominio.Decimal `json:"precoUnitario"`
+		Descricao     string          `json:"descricao"`
+		NCM           string          `json:"ncm"`
+		CFOP          string          `json:"cfop"`
+		Unidade       string          `json:"unidade"`
+		Origem        string          `json:"origem"`
 	}
 
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
 		return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
 	}
 
-	if strings.TrimSpace(req.ProdutoID) == "" || req.Quantidade < 1 {
+	if strings.TrimSpace(req.ProdutoID) == "" || req.Quantidade.IsZero() {
 		return errorResponse(http.StatusBadRequest, "ProdutoId e Quantidade sao obrigatorios", origin), nil
 	}
 
@@ -341,8 +628,19 @@ func (h *LambdaHandler) handleAddItem(ctx context.Context, notaID string, reques
 		return errorResponse(http.StatusBadRequest, "ProdutoId invalido", origin), nil
 	}
 
-	if req.PrecoUnitario < 0 {
-		return errorResponse(http.StatusBadRequest, "Preco unitario invalido", origin), nil
+	item := dominio.ItemNota{
+		NotaID:        notaUUID,
+		ProdutoID:     prodID,
+		Quantidade:    req.Quantidade,
+		PrecoUnitario: req.PrecoUnitario,
+		Descricao:     req.Descricao,
+		NCM:           req.NCM,
+		CFOP:          req.CFOP,
+		Unidade:       req.Unidade,
+		Origem:        req.Origem,
+	}
+	if err := item.Validar(); err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error(), origin), nil
 	}
 
 	var nota dominio.NotaFiscal
@@ -357,13 +655,6 @@ func (h *LambdaHandler) handleAddItem(ctx context.Context, notaID string, reques
 		return errorResponse(http.StatusConflict, "Nota nao esta aberta", origin), nil
 	}
 
-	item := dominio.ItemNota{
-		NotaID:        notaUUID,
-		ProdutoID:     prodID,
-		Quantidade:    req.Quantidade,
-		PrecoUnitario: req.PrecoUnitario,
-	}
-
 	if err := h.handlers.DB.Create(&item).Error; err != nil {
 		return errorResponse(http.StatusInternalServerError, "Falha ao adicionar item", origin), nil
 	}
@@ -371,6 +662,30 @@ func (h *LambdaHandler) handleAddItem(ctx context.Context, notaID string, reques
 	return jsonResponse(http.StatusCreated, item, origin), nil
 }
 
+func (h *LambdaHandler) handleAddPagamento(ctx context.Context, notaID string, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	notaUUID, err := uuid.Parse(notaID)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, "Nota ID invalido", origin), nil
+	}
+
+	var req manipulador.DadosPagamento
+	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
+		return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+	}
+
+	pagamento, err := h.handlers.AdicionarPagamentoDB(notaUUID, req)
+	if err != nil {
+		status, corpo := manipulador.RespostaErroPagamento(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Error adding pagamento", "error", err, "id", notaID)
+		}
+		return jsonResponse(status, corpo, origin), nil
+	}
+
+	return jsonResponse(http.StatusCreated, pagamento, origin), nil
+}
+
 func (h *LambdaHandler) handleImprimirNota(ctx context.Context, notaID string, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
 	notaUUID, err := uuid.Parse(notaID)
 	if err != nil {
@@ -423,8 +738,8 @@ func (h *LambdaHandler) handleImprimirNota(ctx context.Context, notaID string, r
 		}
 
 		type itemEvento struct {
-			ProdutoID  string `json:"produtoId"`
-			Quantidade int    `json:"quantidade"`
+			ProdutoID  string          `json:"produtoId"`
+			Quantidade dominio.Decimal `json:"quantidade"`
 		}
 
 		type payloadEvento struct {
@@ -491,6 +806,35 @@ func jsonResponse(statusCode int, body interface{}, origin string) events.APIGat
 	}
 }
 
+func xmlResponse(statusCode int, body []byte, origin string) events.APIGatewayProxyResponse {
+	headers := corsHeaders(origin)
+	headers["Content-Type"] = "application/xml; charset=utf-8"
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers:    headers,
+		Body:       string(body),
+	}
+}
+
+// documentResponse returns the PDF base64-encoded; API Gateway turns it back into
+// binary because application/pdf is listed in binaryMediaTypes. XML goes as text
+func documentResponse(doc manipulador.DocumentoNota, origin string) events.APIGatewayProxyResponse {
+	headers := corsHeaders(origin)
+	headers["Content-Type"] = doc.Tipo
+	headers["Content-Disposition"] = manipulador.DisposicaoDocumento(doc)
+	headers["Cache-Control"] = "private, no-store"
+	resp := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       string(doc.Conteudo),
+	}
+	if doc.Tipo == armazenamento.TipoPDF {
+		resp.Body = base64.StdEncoding.EncodeToString(doc.Conteudo)
+		resp.IsBase64Encoded = true
+	}
+	return resp
+}
+
 func errorResponse(statusCode int, message string, origin string) events.APIGatewayProxyResponse {
 	body := map[string]string{
 		"erro":    message,
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"servico-faturamento/internal/manipulador"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func outboxErrorResponse(err error, origin string) events.APIGatewayProxyResponse {
+	status, corpo := manipulador.RespostaErroOutbox(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Error handling outbox administration", "error", err)
+	}
+	return jsonResponse(status, corpo, origin)
+}
+
+// handleOutbox atende GET /admin/outbox, GET /admin/outbox/{id} e
+// POST /admin/outbox/{id}/reenfileirar
+func (h *LambdaHandler) handleOutbox(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	pathParts := strings.Split(strings.Trim(request.Path, "/"), "/")
+	if len(pathParts) < 3 || pathParts[2] != "outbox" {
+		return errorResponse(http.StatusNotFound, "Rota não encontrada", origin), nil
+	}
+
+	switch {
+	case len(pathParts) == 3 && request.HTTPMethod == http.MethodGet:
+		params := request.QueryStringParameters
+		filtro, err := manipulador.LerFiltroOutbox(params["status"], params["tipoEvento"], params["limite"])
+		if err != nil {
+			return outboxErrorResponse(err, origin), nil
+		}
+		eventos, err := h.handlers.ListarEventosOutboxDB(ctx, filtro)
+		if err != nil {
+			return outboxErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, eventos, origin), nil
+
+	case len(pathParts) == 4 && request.HTTPMethod == http.MethodGet:
+		id, err := manipulador.LerIDEventoOutbox(pathParts[3])
+		if err != nil {
+			return outboxErrorResponse(err, origin), nil
+		}
+		evento, err := h.handlers.BuscarEventoOutboxDB(ctx, id)
+		if err != nil {
+			return outboxErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, evento, origin), nil
+
+	case len(pathParts) == 5 && pathParts[4] == "reenfileirar" && request.HTTPMethod == http.MethodPost:
+		id, err := manipulador.LerIDEventoOutbox(pathParts[3])
+		if err != nil {
+			return outboxErrorResponse(err, origin), nil
+		}
+		evento, err := h.handlers.ReenfileirarEventoOutboxDB(ctx, id)
+		if err != nil {
+			return outboxErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, evento, origin), nil
+
+	default:
+		return errorResponse(http.StatusNotFound, "Rota não encontrada", origin), nil
+	}
+}
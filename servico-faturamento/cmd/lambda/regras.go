@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func (h *LambdaHandler) handleRegrasTributariasRoutes(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, ok := cadastroID(request.Path)
+	if !ok {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	switch {
+	case request.HTTPMethod == "GET" && id == nil:
+		params := request.QueryStringParameters
+		regras, err := h.handlers.ListarRegrasTributariasDB(params["regime"], params["cfop"], params["ncm"])
+		if err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, regras, origin), nil
+
+	case request.HTTPMethod == "GET":
+		var regra dominio.RegraTributaria
+		if err := h.handlers.DB.First(&regra, "id = ?", *id).Error; err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, regra, origin), nil
+
+	case request.HTTPMethod == "POST" && id == nil:
+		var regra dominio.RegraTributaria
+		if err := json.Unmarshal([]byte(request.Body), &regra); err != nil {
+			return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+		}
+		if err := h.handlers.CriarRegraTributariaDB(&regra); err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusCreated, regra, origin), nil
+
+	case request.HTTPMethod == "PUT" && id != nil:
+		var dados dominio.RegraTributaria
+		if err := json.Unmarshal([]byte(request.Body), &dados); err != nil {
+			return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+		}
+		regra, err := h.handlers.AtualizarRegraTributariaDB(*id, dados)
+		if err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, regra, origin), nil
+
+	case request.HTTPMethod == "DELETE" && id != nil:
+		if err := h.handlers.ExcluirRegraTributariaDB(*id); err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent, Headers: corsHeaders(origin)}, nil
+	}
+
+	return errorResponse(http.StatusMethodNotAllowed, "Method not allowed", origin), nil
+}
+
+func (h *LambdaHandler) handleAliquotasIBSCBSRoutes(ctx context.Context, request events.APIGatewayProxyRequest, origin string) (events.APIGatewayProxyResponse, error) {
+	_ = ctx
+	id, ok := cadastroID(request.Path)
+	if !ok {
+		return errorResponse(http.StatusBadRequest, "ID invalido", origin), nil
+	}
+
+	switch {
+	case request.HTTPMethod == "GET" && id == nil:
+		var aliquotas []dominio.AliquotaIBSCBS
+		if err := h.handlers.DB.Order("vigencia_inicio DESC").Find(&aliquotas).Error; err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, aliquotas, origin), nil
+
+	case request.HTTPMethod == "GET":
+		var aliquota dominio.AliquotaIBSCBS
+		if err := h.handlers.DB.First(&aliquota, "id = ?", *id).Error; err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, aliquota, origin), nil
+
+	case request.HTTPMethod == "POST" && id == nil:
+		var aliquota dominio.AliquotaIBSCBS
+		if err := json.Unmarshal([]byte(request.Body), &aliquota); err != nil {
+			return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+		}
+		if err := h.handlers.CriarAliquotaIBSCBSDB(&aliquota); err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusCreated, aliquota, origin), nil
+
+	case request.HTTPMethod == "PUT" && id != nil:
+		var dados dominio.AliquotaIBSCBS
+		if err := json.Unmarshal([]byte(request.Body), &dados); err != nil {
+			return errorResponse(http.StatusBadRequest, "Invalid JSON", origin), nil
+		}
+		aliquota, err := h.handlers.AtualizarAliquotaIBSCBSDB(*id, dados)
+		if err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return jsonResponse(http.StatusOK, aliquota, origin), nil
+
+	case request.HTTPMethod == "DELETE" && id != nil:
+		if err := h.handlers.ExcluirAliquotaIBSCBSDB(*id); err != nil {
+			return cadastroErrorResponse(err, origin), nil
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusNoContent, Headers: corsHeaders(origin)}, nil
+	}
+
+	return errorResponse(http.StatusMethodNotAllowed, "Method not allowed", origin), nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/logger"
+	"servico-faturamento/internal/sefaz"
+)
+
+// Simulador local dos web services de autorização da SEFAZ (HTTP, sem mTLS).
+// Aponte o serviço para ele com SEFAZ_URL=http://localhost:8090, que também
+// registra o cancelamento (110111) das notas que autorizou; a SVC responde em
+// /svc (SEFAZ_SVC_URL), a recepção do EPEC do Ambiente Nacional em /an (SEFAZ_EPEC_URL)
+// e o autorizador da NFC-e em /nfce (SEFAZ_NFCE_URL).
+func main() {
+	logger.Init()
+
+	simulador := sefaz.NovoSimulador()
+	simulador.Assincrono = os.Getenv("SEFAZ_STUB_ASSINCRONO") == "true"
+	simulador.Indisponivel.Store(os.Getenv("SEFAZ_STUB_INDISPONIVEL") == "true")
+	for _, doc := range strings.Split(os.Getenv("SEFAZ_STUB_DENEGAR"), ",") {
+		if doc = dominio.SomenteDigitos(doc); doc != "" {
+			simulador.Denegar[doc] = true
+		}
+	}
+	svc := sefaz.NovoSimulador()
+	svc.Denegar = simulador.Denegar
+
+	mux := http.NewServeMux()
+	mux.Handle("/", simulador)
+	mux.Handle("/svc/", svc)
+	mux.Handle("/an/", sefaz.NovoSimulador())
+	nfce := sefaz.NovoSimulador()
+	nfce.Denegar = simulador.Denegar
+	mux.Handle("/nfce/", nfce)
+	// POST /simulador/indisponivel?ativo=true|false liga ou desliga a paralisação do
+	// autorizador normal, para exercitar a entrada e a saída da contingência
+	mux.HandleFunc("/simulador/indisponivel", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			ativo, err := strconv.ParseBool(r.URL.Query().Get("ativo"))
+			if err != nil {
+				http.Error(w, "informe ativo=true ou ativo=false", http.StatusBadRequest)
+				return
+			}
+			simulador.Indisponivel.Store(ativo)
+			slog.Info("Simulador SEFAZ: disponibilidade alterada", "indisponivel", ativo)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"indisponivel":` + strconv.FormatBool(simulador.Indisponivel.Load()) + "}\n"))
+	})
+
+	porta := os.Getenv("SEFAZ_STUB_PORTA")
+	if porta == "" {
+		porta = "8090"
+	}
+
+	slog.Info("Simulador SEFAZ iniciado", "porta", porta, "assincrono", simulador.Assincrono,
+		"denegados", len(simulador.Denegar), "indisponivel", simulador.Indisponivel.Load())
+	if err := http.ListenAndServe(":"+porta, mux); err != nil {
+		slog.Error("Erro ao iniciar simulador SEFAZ", "erro", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,129 @@
+// Package armazenamento guarda os documentos fiscais gerados pelo serviço (DANFE
+// em PDF, XML autorizado com o protocolo e XMLs de eventos) atrás de uma interface
+// única, com implementações em S3, em disco local e em memória.
+package armazenamento
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"servico-faturamento/internal/dominio"
+)
+
+var (
+	// ErrNaoEncontrado indica chave sem documento armazenado
+	ErrNaoEncontrado = errors.New("documento nao encontrado no armazenamento")
+	// ErrChaveInvalida indica chave vazia, absoluta ou que sai do prefixo (..)
+	ErrChaveInvalida = errors.New("chave de armazenamento invalida")
+	// ErrBackendInvalido indica valor de ARMAZENAMENTO desconhecido
+	ErrBackendInvalido = errors.New("ARMAZENAMENTO deve ser s3, local ou memoria")
+)
+
+// Tipos de conteúdo dos documentos guardados
+const (
+	TipoPDF = "application/pdf"
+	TipoXML = "application/xml"
+)
+
+// Backends aceitos em ARMAZENAMENTO
+const (
+	BackendS3      = "s3"
+	BackendLocal   = "local"
+	BackendMemoria = "memoria"
+)
+
+// Armazenamento guarda e devolve documentos por chave. As chaves usam "/" como
+// separador, independentemente do backend. Nenhum backend expõe os documentos
+// diretamente: quem guarda a referência guarda a chave, e o download passa pelos
+// endpoints do serviço ou por um link assinado.
+type Armazenamento interface {
+	Salvar(ctx context.Context, chave string, conteudo []byte, tipo string) error
+	Ler(ctx context.Context, chave string) ([]byte, error)
+}
+
+// validarChave recusa chaves que escapariam do diretório ou do prefixo do bucket
+func validarChave(chave string) error {
+	if chave == "" || strings.HasPrefix(chave, "/") || strings.Contains(chave, `\`) {
+		return ErrChaveInvalida
+	}
+	for _, parte := range strings.Split(chave, "/") {
+		if parte == "" || parte == "." || parte == ".." {
+			return ErrChaveInvalida
+		}
+	}
+	return nil
+}
+
+// prefixoNota agrupa os documentos da nota pelo mês de criação
+func prefixoNota(nota dominio.NotaFiscal) string {
+	return path.Join("notas-fiscais", nota.DataCriacao.Format("2006/01"), nota.ID.String())
+}
+
+// ChavePDF é a chave do DANFE das notas geradas antes do versionamento
+func ChavePDF(nota dominio.NotaFiscal) string {
+	return prefixoNota(nota) + ".pdf"
+}
+
+// ChavePDFVersao é a chave de uma versão do DANFE; cada versão é gravada uma única vez
+func ChavePDFVersao(nota dominio.NotaFiscal, versao int) string {
+	return fmt.Sprintf("%s-v%03d.pdf", prefixoNota(nota), versao)
+}
+
+// ChaveXMLAutorizado é a chave do nfeProc (NF-e assinada com o protocolo de autorização)
+func ChaveXMLAutorizado(nota dominio.NotaFiscal) string {
+	return prefixoNota(nota) + "-procNFe.xml"
+}
+
+// ChaveXMLCartaCorrecao é a chave do XML do evento de carta de correção
+func ChaveXMLCartaCorrecao(nota dominio.NotaFiscal, sequencia int) string {
+	return fmt.Sprintf("%s-cce-%02d.xml", prefixoNota(nota), sequencia)
+}
+
+// ChaveXMLCancelamento é a chave do XML do evento de cancelamento (110111)
+func ChaveXMLCancelamento(nota dominio.NotaFiscal) string {
+	return prefixoNota(nota) + "-canc.xml"
+}
+
+// ChaveXMLInutilizacao é a chave do pedido de inutilização, agrupado por emitente e ano
+func ChaveXMLInutilizacao(inut dominio.Inutilizacao) string {
+	return path.Join("inutilizacoes", inut.CNPJEmitente, inut.Ano, inut.ID.String()+".xml")
+}
+
+// CarregarConfigurado escolhe o backend por ARMAZENAMENTO, usando padrao quando a
+// variável não está definida. Com os dois vazios devolve nil, nil: os documentos
+// não são arquivados.
+//
+//   - local: ARMAZENAMENTO_DIRETORIO (padrão ./dados/documentos)
+//   - s3: ARMAZENAMENTO_BUCKET (ou PDF_BUCKET_NAME)
+//   - memoria: apenas para testes e desenvolvimento
+func CarregarConfigurado(ctx context.Context, padrao string) (Armazenamento, error) {
+	backend := strings.ToLower(strings.TrimSpace(os.Getenv("ARMAZENAMENTO")))
+	if backend == "" {
+		backend = padrao
+	}
+
+	switch backend {
+	case "":
+		return nil, nil
+	case BackendMemoria:
+		return NovoMemoria(), nil
+	case BackendLocal:
+		diretorio := os.Getenv("ARMAZENAMENTO_DIRETORIO")
+		if diretorio == "" {
+			diretorio = "./dados/documentos"
+		}
+		return NovoLocal(diretorio)
+	case BackendS3:
+		bucket := os.Getenv("ARMAZENAMENTO_BUCKET")
+		if bucket == "" {
+			bucket = os.Getenv("PDF_BUCKET_NAME")
+		}
+		return NovoS3Configurado(ctx, bucket)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrBackendInvalido, backend)
+	}
+}
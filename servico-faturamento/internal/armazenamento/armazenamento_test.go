@@ -0,0 +1,167 @@
+package armazenamento_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/dominio"
+
+	"github.com/google/uuid"
+)
+
+func TestBackends(t *testing.T) {
+	local, err := armazenamento.NovoLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	backends := map[string]armazenamento.Armazenamento{
+		"local":   local,
+		"memoria": armazenamento.NovoMemoria(),
+	}
+
+	for nome, a := range backends {
+		ctx := context.Background()
+
+		t.Run(nome+": deve devolver o conteudo salvo e sobrescrever a mesma chave", func(t *testing.T) {
+			chave := "notas-fiscais/2025/03/nota.pdf"
+			if err := a.Salvar(ctx, chave, []byte("v1"), armazenamento.TipoPDF); err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if err := a.Salvar(ctx, chave, []byte("v2"), armazenamento.TipoPDF); err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			conteudo, err := a.Ler(ctx, chave)
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if string(conteudo) != "v2" {
+				t.Errorf("esperava v2, obteve %q", conteudo)
+			}
+		})
+
+		t.Run(nome+": deve retornar ErrNaoEncontrado para chave inexistente", func(t *testing.T) {
+			if _, err := a.Ler(ctx, "notas-fiscais/nao-existe.pdf"); !errors.Is(err, armazenamento.ErrNaoEncontrado) {
+				t.Errorf("esperava ErrNaoEncontrado, obteve %v", err)
+			}
+		})
+
+		t.Run(nome+": deve recusar chaves que saem do diretorio", func(t *testing.T) {
+			for _, chave := range []string{"", "/etc/passwd", "../fora.xml", "a/../../fora.xml", "a//b.xml", `a\b.xml`} {
+				if err := a.Salvar(ctx, chave, []byte("x"), armazenamento.TipoXML); !errors.Is(err, armazenamento.ErrChaveInvalida) {
+					t.Errorf("%q: esperava ErrChaveInvalida, obteve %v", chave, err)
+				}
+			}
+		})
+	}
+
+	t.Run("local: deve gravar o arquivo no subdiretorio da chave sem deixar temporarios", func(t *testing.T) {
+		dir := t.TempDir()
+		l, err := armazenamento.NovoLocal(filepath.Join(dir, "documentos"))
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if err := l.Salvar(context.Background(), "inutilizacoes/25/x.xml", []byte("<inutNFe/>"), armazenamento.TipoXML); err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		entradas, err := os.ReadDir(filepath.Join(dir, "documentos", "inutilizacoes", "25"))
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if len(entradas) != 1 || entradas[0].Name() != "x.xml" {
+			t.Errorf("esperava apenas x.xml, obteve %v", entradas)
+		}
+	})
+
+	t.Run("memoria: deve guardar o tipo de conteudo", func(t *testing.T) {
+		m := armazenamento.NovoMemoria()
+		_ = m.Salvar(context.Background(), "a.pdf", []byte("%PDF"), armazenamento.TipoPDF)
+		if m.Tipo("a.pdf") != armazenamento.TipoPDF {
+			t.Errorf("tipo inesperado: %s", m.Tipo("a.pdf"))
+		}
+	})
+}
+
+func TestChaves(t *testing.T) {
+	nota := dominio.NotaFiscal{
+		ID:          uuid.MustParse("7f9c2d1e-0000-4000-8000-000000000001"),
+		DataCriacao: time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("deve agrupar os documentos da nota pelo mes de criacao", func(t *testing.T) {
+		casos := map[string]string{
+			armazenamento.ChavePDF(nota):                 "notas-fiscais/2025/03/7f9c2d1e-0000-4000-8000-000000000001.pdf",
+			armazenamento.ChavePDFVersao(nota, 3):        "notas-fiscais/2025/03/7f9c2d1e-0000-4000-8000-000000000001-v003.pdf",
+			armazenamento.ChaveXMLAutorizado(nota):       "notas-fiscais/2025/03/7f9c2d1e-0000-4000-8000-000000000001-procNFe.xml",
+			armazenamento.ChaveXMLCartaCorrecao(nota, 2): "notas-fiscais/2025/03/7f9c2d1e-0000-4000-8000-000000000001-cce-02.xml",
+		}
+		for got, esperado := range casos {
+			if got != esperado {
+				t.Errorf("esperava %s, obteve %s", esperado, got)
+			}
+		}
+	})
+
+	t.Run("deve agrupar inutilizacoes por emitente e ano", func(t *testing.T) {
+		inut := dominio.Inutilizacao{ID: nota.ID, CNPJEmitente: "11222333000181", Ano: "25"}
+		esperado := "inutilizacoes/11222333000181/25/7f9c2d1e-0000-4000-8000-000000000001.xml"
+		if got := armazenamento.ChaveXMLInutilizacao(inut); got != esperado {
+			t.Errorf("esperava %s, obteve %s", esperado, got)
+		}
+	})
+}
+
+func TestCarregarConfigurado(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("deve usar o padrao quando ARMAZENAMENTO nao esta definido", func(t *testing.T) {
+		t.Setenv("ARMAZENAMENTO", "")
+		t.Setenv("ARMAZENAMENTO_DIRETORIO", t.TempDir())
+		a, err := armazenamento.CarregarConfigurado(ctx, armazenamento.BackendLocal)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if _, ok := a.(*armazenamento.Local); !ok {
+			t.Errorf("esperava *Local, obteve %T", a)
+		}
+	})
+
+	t.Run("deve devolver nil sem backend configurado nem padrao", func(t *testing.T) {
+		t.Setenv("ARMAZENAMENTO", "")
+		a, err := armazenamento.CarregarConfigurado(ctx, "")
+		if err != nil || a != nil {
+			t.Errorf("esperava nil, nil; obteve %v, %v", a, err)
+		}
+	})
+
+	t.Run("deve respeitar ARMAZENAMENTO acima do padrao", func(t *testing.T) {
+		t.Setenv("ARMAZENAMENTO", "memoria")
+		a, err := armazenamento.CarregarConfigurado(ctx, armazenamento.BackendLocal)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if _, ok := a.(*armazenamento.Memoria); !ok {
+			t.Errorf("esperava *Memoria, obteve %T", a)
+		}
+	})
+
+	t.Run("deve exigir bucket no S3", func(t *testing.T) {
+		t.Setenv("ARMAZENAMENTO", "s3")
+		t.Setenv("ARMAZENAMENTO_BUCKET", "")
+		t.Setenv("PDF_BUCKET_NAME", "")
+		if _, err := armazenamento.CarregarConfigurado(ctx, ""); err == nil {
+			t.Error("esperava erro sem bucket")
+		}
+	})
+
+	t.Run("deve recusar backend desconhecido", func(t *testing.T) {
+		t.Setenv("ARMAZENAMENTO", "ftp")
+		if _, err := armazenamento.CarregarConfigurado(ctx, ""); !errors.Is(err, armazenamento.ErrBackendInvalido) {
+			t.Errorf("esperava ErrBackendInvalido, obteve %v", err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package armazenamento
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local guarda os documentos em disco, abaixo de Diretorio, espelhando as chaves
+// em subdiretórios. É o padrão da API em Docker Compose, com o diretório num volume.
+type Local struct {
+	Diretorio string
+}
+
+// NovoLocal cria o diretório raiz quando ele ainda não existe
+func NovoLocal(diretorio string) (*Local, error) {
+	if err := os.MkdirAll(diretorio, 0o750); err != nil {
+		return nil, fmt.Errorf("falha ao criar diretorio de armazenamento %s: %w", diretorio, err)
+	}
+	return &Local{Diretorio: diretorio}, nil
+}
+
+func (l *Local) caminho(chave string) (string, error) {
+	if err := validarChave(chave); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.Diretorio, filepath.FromSlash(chave)), nil
+}
+
+// Salvar grava num arquivo temporário e renomeia, para que um leitor nunca veja o
+// documento pela metade
+func (l *Local) Salvar(_ context.Context, chave string, conteudo []byte, _ string) error {
+	destino, err := l.caminho(chave)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(destino), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(destino), ".tmp-"+filepath.Base(destino)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(conteudo); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), destino)
+}
+
+func (l *Local) Ler(_ context.Context, chave string) ([]byte, error) {
+	origem, err := l.caminho(chave)
+	if err != nil {
+		return nil, err
+	}
+	conteudo, err := os.ReadFile(origem)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNaoEncontrado
+	}
+	return conteudo, err
+}
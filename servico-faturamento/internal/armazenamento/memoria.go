@@ -0,0 +1,52 @@
+package armazenamento
+
+import (
+	"context"
+	"sync"
+)
+
+// Memoria mantém os documentos num mapa do processo; serve a testes e ao
+// desenvolvimento, já que tudo se perde ao reiniciar
+type Memoria struct {
+	mu         sync.RWMutex
+	documentos map[string]documentoMemoria
+}
+
+type documentoMemoria struct {
+	conteudo []byte
+	tipo     string
+}
+
+func NovoMemoria() *Memoria {
+	return &Memoria{documentos: map[string]documentoMemoria{}}
+}
+
+func (m *Memoria) Salvar(_ context.Context, chave string, conteudo []byte, tipo string) error {
+	if err := validarChave(chave); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.documentos[chave] = documentoMemoria{conteudo: append([]byte(nil), conteudo...), tipo: tipo}
+	return nil
+}
+
+func (m *Memoria) Ler(_ context.Context, chave string) ([]byte, error) {
+	if err := validarChave(chave); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	doc, ok := m.documentos[chave]
+	if !ok {
+		return nil, ErrNaoEncontrado
+	}
+	return append([]byte(nil), doc.conteudo...), nil
+}
+
+// Tipo devolve o tipo de conteúdo informado no Salvar, vazio para chave inexistente
+func (m *Memoria) Tipo(chave string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.documentos[chave].tipo
+}
@@ -0,0 +1,66 @@
+package armazenamento
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 guarda os documentos num bucket privado; eles só saem pelos endpoints do
+// serviço e pelos links assinados
+type S3 struct {
+	Cliente *s3.Client
+	Bucket  string
+}
+
+// NovoS3Configurado cria o cliente com a configuração padrão da AWS (credenciais
+// da função Lambda ou das variáveis de ambiente)
+func NovoS3Configurado(ctx context.Context, bucket string) (*S3, error) {
+	if bucket == "" {
+		return nil, errors.New("ARMAZENAMENTO=s3 exige ARMAZENAMENTO_BUCKET ou PDF_BUCKET_NAME")
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar configuracao AWS: %w", err)
+	}
+	return &S3{Cliente: s3.NewFromConfig(cfg), Bucket: bucket}, nil
+}
+
+func (a *S3) Salvar(ctx context.Context, chave string, conteudo []byte, tipo string) error {
+	if err := validarChave(chave); err != nil {
+		return err
+	}
+	_, err := a.Cliente.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(a.Bucket),
+		Key:         aws.String(chave),
+		Body:        bytes.NewReader(conteudo),
+		ContentType: aws.String(tipo),
+	})
+	return err
+}
+
+func (a *S3) Ler(ctx context.Context, chave string) ([]byte, error) {
+	if err := validarChave(chave); err != nil {
+		return nil, err
+	}
+	saida, err := a.Cliente.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(a.Bucket),
+		Key:    aws.String(chave),
+	})
+	if err != nil {
+		var naoExiste *types.NoSuchKey
+		if errors.As(err, &naoExiste) {
+			return nil, ErrNaoEncontrado
+		}
+		return nil, err
+	}
+	defer saida.Body.Close()
+	return io.ReadAll(saida.Body)
+}
@@ -0,0 +1,311 @@
+package assinatura
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// no é o elemento da árvore mínima usada na canonicalização. Os nomes ficam com o
+// prefixo original (RawToken) para que as declarações de namespace sejam
+// reproduzidas como no documento.
+type no struct {
+	prefixo, local string
+	atributos      []xml.Attr
+	filhos         []interface{} // *no ou string (texto)
+	pai            *no
+	// inicioFim é o offset de "</elemento>" no documento, onde a assinatura é inserida
+	inicioFim int64
+}
+
+// lerArvore monta a árvore do documento, descartando comentários, instruções de
+// processamento e DOCTYPE, que não entram na forma canônica
+func lerArvore(documento []byte) (*no, error) {
+	dec := xml.NewDecoder(bytes.NewReader(documento))
+	var raiz, atual *no
+	for {
+		offset := dec.InputOffset()
+		token, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("XML invalido: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &no{prefixo: t.Name.Space, local: t.Name.Local, atributos: append([]xml.Attr(nil), t.Attr...), pai: atual}
+			if atual == nil {
+				if raiz != nil {
+					return nil, errors.New("XML invalido: mais de um elemento raiz")
+				}
+				raiz = n
+			} else {
+				atual.filhos = append(atual.filhos, n)
+			}
+			atual = n
+		case xml.EndElement:
+			if atual == nil || atual.prefixo != t.Name.Space || atual.local != t.Name.Local {
+				return nil, fmt.Errorf("XML invalido: fechamento inesperado de %s", t.Name.Local)
+			}
+			atual.inicioFim = offset
+			atual = atual.pai
+		case xml.CharData:
+			if atual != nil {
+				atual.filhos = append(atual.filhos, string(t))
+			}
+		}
+	}
+	if raiz == nil || atual != nil {
+		return nil, errors.New("XML invalido: documento incompleto")
+	}
+	return raiz, nil
+}
+
+// atributo devolve o valor do atributo sem prefixo
+func (n *no) atributo(nome string) (string, bool) {
+	for _, a := range n.atributos {
+		if a.Name.Space == "" && a.Name.Local == nome {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// declaracao informa se o atributo é uma declaração de namespace e o prefixo declarado
+func declaracao(a xml.Attr) (string, bool) {
+	switch {
+	case a.Name.Space == "" && a.Name.Local == "xmlns":
+		return "", true
+	case a.Name.Space == "xmlns":
+		return a.Name.Local, true
+	}
+	return "", false
+}
+
+// namespaces devolve as declarações em escopo no elemento, herdadas dos ancestrais
+func (n *no) namespaces() map[string]string {
+	var cadeia []*no
+	for p := n; p != nil; p = p.pai {
+		cadeia = append(cadeia, p)
+	}
+	escopo := map[string]string{"xml": "http://www.w3.org/XML/1998/namespace"}
+	for i := len(cadeia) - 1; i >= 0; i-- {
+		for _, a := range cadeia[i].atributos {
+			if prefixo, ok := declaracao(a); ok {
+				escopo[prefixo] = a.Value
+			}
+		}
+	}
+	return escopo
+}
+
+// namespace resolve o URI do elemento
+func (n *no) namespace() string {
+	return n.namespaces()[n.prefixo]
+}
+
+// filhosElemento lista os elementos filhos com o nome local informado
+func (n *no) filhosElemento(local string) []*no {
+	var encontrados []*no
+	for _, f := range n.filhos {
+		if e, ok := f.(*no); ok && e.local == local {
+			encontrados = append(encontrados, e)
+		}
+	}
+	return encontrados
+}
+
+// filho devolve o primeiro elemento filho com o nome local informado
+func (n *no) filho(local string) *no {
+	if encontrados := n.filhosElemento(local); len(encontrados) > 0 {
+		return encontrados[0]
+	}
+	return nil
+}
+
+// texto concatena o conteúdo textual direto do elemento
+func (n *no) texto() string {
+	var sb strings.Builder
+	for _, f := range n.filhos {
+		if s, ok := f.(string); ok {
+			sb.WriteString(s)
+		}
+	}
+	return sb.String()
+}
+
+// buscar percorre a árvore em profundidade e devolve os elementos aceitos pelo filtro
+func (n *no) buscar(filtro func(*no) bool) []*no {
+	var encontrados []*no
+	if filtro(n) {
+		encontrados = append(encontrados, n)
+	}
+	for _, f := range n.filhos {
+		if e, ok := f.(*no); ok {
+			encontrados = append(encontrados, e.buscar(filtro)...)
+		}
+	}
+	return encontrados
+}
+
+// canonizar serializa o elemento em Canonical XML 1.0 sem comentários
+// (http://www.w3.org/TR/2001/REC-xml-c14n-20010315). O elemento omitido não é
+// escrito, o que implementa a transformação enveloped-signature.
+func canonizar(n *no, omitido *no) []byte {
+	var buf bytes.Buffer
+	escopo := n.namespaces()
+	delete(escopo, "xml")
+	escreverCanonico(&buf, n, escopo, map[string]string{}, omitido)
+	return buf.Bytes()
+}
+
+func escreverCanonico(buf *bytes.Buffer, n *no, escopo, renderizados map[string]string, omitido *no) {
+	// Declarações novas ou alteradas em relação ao ancestral já escrito
+	var prefixos []string
+	for prefixo, uri := range escopo {
+		anterior, ok := renderizados[prefixo]
+		if ok && anterior == uri {
+			continue
+		}
+		if !ok && prefixo == "" && uri == "" {
+			continue
+		}
+		prefixos = append(prefixos, prefixo)
+	}
+	sort.Strings(prefixos)
+
+	type atributoCanonico struct {
+		uri, local, nome, valor string
+	}
+	var atributos []atributoCanonico
+	for _, a := range n.atributos {
+		if _, ok := declaracao(a); ok {
+			continue
+		}
+		nome, uri := a.Name.Local, ""
+		if a.Name.Space != "" {
+			nome = a.Name.Space + ":" + a.Name.Local
+			uri = escopo[a.Name.Space]
+			if a.Name.Space == "xml" {
+				uri = "http://www.w3.org/XML/1998/namespace"
+			}
+		}
+		atributos = append(atributos, atributoCanonico{uri, a.Name.Local, nome, a.Value})
+	}
+	sort.Slice(atributos, func(i, j int) bool {
+		if atributos[i].uri != atributos[j].uri {
+			return atributos[i].uri < atributos[j].uri
+		}
+		return atributos[i].local < atributos[j].local
+	})
+
+	nome := n.local
+	if n.prefixo != "" {
+		nome = n.prefixo + ":" + n.local
+	}
+	buf.WriteString("<" + nome)
+	proximos := renderizados
+	if len(prefixos) > 0 {
+		proximos = make(map[string]string, len(renderizados)+len(prefixos))
+		for k, v := range renderizados {
+			proximos[k] = v
+		}
+	}
+	for _, prefixo := range prefixos {
+		if prefixo == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + prefixo + `="`)
+		}
+		escaparAtributo(buf, escopo[prefixo])
+		buf.WriteString(`"`)
+		proximos[prefixo] = escopo[prefixo]
+	}
+	for _, a := range atributos {
+		buf.WriteString(" " + a.nome + `="`)
+		escaparAtributo(buf, a.valor)
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+
+	for _, f := range n.filhos {
+		switch filho := f.(type) {
+		case string:
+			escaparTexto(buf, filho)
+		case *no:
+			if filho == omitido {
+				continue
+			}
+			escreverCanonico(buf, filho, escopoFilho(escopo, filho), proximos, omitido)
+		}
+	}
+	buf.WriteString("</" + nome + ">")
+}
+
+// escopoFilho aplica as declarações do filho sobre o escopo do pai
+func escopoFilho(escopo map[string]string, filho *no) map[string]string {
+	var declarados bool
+	for _, a := range filho.atributos {
+		if _, ok := declaracao(a); ok {
+			declarados = true
+			break
+		}
+	}
+	if !declarados {
+		return escopo
+	}
+	novo := make(map[string]string, len(escopo)+1)
+	for k, v := range escopo {
+		novo[k] = v
+	}
+	for _, a := range filho.atributos {
+		if prefixo, ok := declaracao(a); ok {
+			novo[prefixo] = a.Value
+		}
+	}
+	return novo
+}
+
+func escaparTexto(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '>':
+			buf.WriteString("&gt;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
+
+func escaparAtributo(buf *bytes.Buffer, s string) {
+	for _, r := range s {
+		switch r {
+		case '&':
+			buf.WriteString("&amp;")
+		case '<':
+			buf.WriteString("&lt;")
+		case '"':
+			buf.WriteString("&quot;")
+		case '\t':
+			buf.WriteString("&#x9;")
+		case '\n':
+			buf.WriteString("&#xA;")
+		case '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package assinatura
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+var (
+	// ErrCertificadoInvalido indica .pfx ilegível, senha errada ou sem chave RSA
+	ErrCertificadoInvalido = errors.New("certificado A1 invalido")
+	// ErrCertificadoOutroEmitente indica certificado emitido para outra raiz de CNPJ
+	ErrCertificadoOutroEmitente = errors.New("CNPJ do certificado difere do CNPJ do emitente")
+)
+
+// AlertaExpiracaoPadrao é a antecedência com que o /health passa a avisar do vencimento
+const AlertaExpiracaoPadrao = 30 * 24 * time.Hour
+
+// oidCNPJ é o otherName do SAN em que a ICP-Brasil grava o CNPJ do titular e-CNPJ
+var oidCNPJ = asn1.ObjectIdentifier{2, 16, 76, 1, 3, 3}
+
+// Certificado é o certificado A1 do emitente com a chave usada nas assinaturas
+type Certificado struct {
+	X509   *x509.Certificate
+	Chave  *rsa.PrivateKey
+	Cadeia []*x509.Certificate
+}
+
+// CarregarPFX lê um arquivo PKCS#12 (.pfx/.p12). Só são suportados os algoritmos
+// legados (3DES/RC2) usados pelas autoridades certificadoras; arquivos exportados
+// com AES pelo OpenSSL 3 devem ser reexportados com -legacy.
+func CarregarPFX(dados []byte, senha string) (*Certificado, error) {
+	blocos, err := pkcs12.ToPEM(dados, senha)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCertificadoInvalido, err)
+	}
+
+	c := &Certificado{}
+	var certificados []*x509.Certificate
+	for _, bloco := range blocos {
+		switch bloco.Type {
+		case "PRIVATE KEY":
+			chave, err := x509.ParsePKCS1PrivateKey(bloco.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("%w: chave privada deve ser RSA: %v", ErrCertificadoInvalido, err)
+			}
+			c.Chave = chave
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(bloco.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrCertificadoInvalido, err)
+			}
+			certificados = append(certificados, cert)
+		}
+	}
+	if c.Chave == nil {
+		return nil, fmt.Errorf("%w: arquivo sem chave privada", ErrCertificadoInvalido)
+	}
+
+	// O arquivo pode trazer a cadeia da AC; o certificado do titular é o da chave
+	for _, cert := range certificados {
+		if pub, ok := cert.PublicKey.(*rsa.PublicKey); ok && pub.Equal(&c.Chave.PublicKey) {
+			c.X509 = cert
+		} else {
+			c.Cadeia = append(c.Cadeia, cert)
+		}
+	}
+	if c.X509 == nil {
+		return nil, fmt.Errorf("%w: nenhum certificado corresponde a chave privada", ErrCertificadoInvalido)
+	}
+	return c, nil
+}
+
+// CarregarConfigurado lê o certificado de NFE_CERTIFICADO_ARQUIVO ou, quando ele vem
+// de um secret, do conteúdo em base64 de NFE_CERTIFICADO_PFX_BASE64. Sem nenhum dos
+// dois retorna nil: os XMLs saem sem assinatura (apenas para desenvolvimento).
+func CarregarConfigurado() (*Certificado, error) {
+	senha := os.Getenv("NFE_CERTIFICADO_SENHA")
+
+	if conteudo := os.Getenv("NFE_CERTIFICADO_PFX_BASE64"); conteudo != "" {
+		dados, err := base64.StdEncoding.DecodeString(strings.TrimSpace(conteudo))
+		if err != nil {
+			return nil, fmt.Errorf("%w: NFE_CERTIFICADO_PFX_BASE64 nao e base64: %v", ErrCertificadoInvalido, err)
+		}
+		return CarregarPFX(dados, senha)
+	}
+
+	if caminho := os.Getenv("NFE_CERTIFICADO_ARQUIVO"); caminho != "" {
+		dados, err := os.ReadFile(caminho)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler certificado %s: %w", caminho, err)
+		}
+		return CarregarPFX(dados, senha)
+	}
+
+	return nil, nil
+}
+
+// AlertaExpiracao lê NFE_CERTIFICADO_ALERTA_DIAS, com padrão de 30 dias
+func AlertaExpiracao() time.Duration {
+	if valor := os.Getenv("NFE_CERTIFICADO_ALERTA_DIAS"); valor != "" {
+		if dias, err := strconv.Atoi(valor); err == nil && dias >= 0 {
+			return time.Duration(dias) * 24 * time.Hour
+		}
+	}
+	return AlertaExpiracaoPadrao
+}
+
+// CNPJ devolve o CNPJ do titular, lido do SAN (OID 2.16.76.1.3.3) ou do sufixo
+// ":CNPJ" do CN; vazio quando o certificado não identifica um e-CNPJ
+func (c *Certificado) CNPJ() string {
+	for _, ext := range c.X509.Extensions {
+		if ext.Id.Equal(asn1.ObjectIdentifier{2, 5, 29, 17}) {
+			if cnpj := cnpjDoSAN(ext.Value); cnpj != "" {
+				return cnpj
+			}
+		}
+	}
+
+	cn := c.X509.Subject.CommonName
+	if i := strings.LastIndex(cn, ":"); i >= 0 {
+		if sufixo := cn[i+1:]; len(sufixo) == 14 && somenteDigitos(sufixo) {
+			return sufixo
+		}
+	}
+	return ""
+}
+
+// ConferirEmitente exige que o certificado seja da mesma raiz (8 dígitos) do CNPJ do
+// emitente, como a SEFAZ confere na autorização. Certificados sem CNPJ são aceitos.
+func (c *Certificado) ConferirEmitente(cnpjEmitente string) error {
+	cnpj := c.CNPJ()
+	if cnpj == "" || len(cnpjEmitente) != 14 {
+		return nil
+	}
+	if cnpj[:8] != cnpjEmitente[:8] {
+		return fmt.Errorf("%w: certificado %s, emitente %s", ErrCertificadoOutroEmitente, cnpj, cnpjEmitente)
+	}
+	return nil
+}
+
+// DiasParaExpirar conta os dias inteiros até NotAfter; negativo depois do vencimento
+func (c *Certificado) DiasParaExpirar(agora time.Time) int {
+	return int(math.Floor(c.X509.NotAfter.Sub(agora).Hours() / 24))
+}
+
+// ExpiraEm indica se o certificado vence dentro da janela de alerta
+func (c *Certificado) ExpiraEm(agora time.Time, alerta time.Duration) bool {
+	return !agora.Add(alerta).Before(c.X509.NotAfter)
+}
+
+// cnpjDoSAN procura o otherName 2.16.76.1.3.3 na extensão subjectAltName
+func cnpjDoSAN(valor []byte) string {
+	var nomes asn1.RawValue
+	if _, err := asn1.Unmarshal(valor, &nomes); err != nil {
+		return ""
+	}
+
+	resto := nomes.Bytes
+	for len(resto) > 0 {
+		var nome asn1.RawValue
+		var err error
+		if resto, err = asn1.Unmarshal(resto, &nome); err != nil {
+			return ""
+		}
+		// otherName é [0] IMPLICIT SEQUENCE { type-id OID, value [0] EXPLICIT ANY }
+		if nome.Class != asn1.ClassContextSpecific || nome.Tag != 0 {
+			continue
+		}
+		var tipo asn1.ObjectIdentifier
+		conteudo, err := asn1.Unmarshal(nome.Bytes, &tipo)
+		if err != nil || !tipo.Equal(oidCNPJ) {
+			continue
+		}
+		var explicito asn1.RawValue
+		if _, err := asn1.Unmarshal(conteudo, &explicito); err != nil {
+			continue
+		}
+		var texto asn1.RawValue
+		if _, err := asn1.Unmarshal(explicito.Bytes, &texto); err != nil {
+			continue
+		}
+		if cnpj := string(texto.Bytes); len(cnpj) == 14 && somenteDigitos(cnpj) {
+			return cnpj
+		}
+	}
+	return ""
+}
+
+func somenteDigitos(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,175 @@
+package assinatura_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/assinatura"
+)
+
+// testdata/certificado-teste.pfx (senha 1234) é autoassinado, com o CNPJ 11222333000181
+// no SAN 2.16.76.1.3.3 e chave em 3DES, gerado com:
+//
+//	openssl req -x509 -newkey rsa:2048 -nodes -days 3650 -config ext.cnf ...
+//	openssl pkcs12 -export -keypbe PBE-SHA1-3DES -certpbe PBE-SHA1-3DES -macalg sha1 ...
+const senhaTeste = "1234"
+
+func certificadoTeste(t *testing.T) *assinatura.Certificado {
+	t.Helper()
+	dados, err := os.ReadFile("testdata/certificado-teste.pfx")
+	if err != nil {
+		t.Fatalf("falha ao ler certificado de teste: %v", err)
+	}
+	c, err := assinatura.CarregarPFX(dados, senhaTeste)
+	if err != nil {
+		t.Fatalf("falha ao carregar certificado de teste: %v", err)
+	}
+	return c
+}
+
+// certificadoGerado cria um certificado em memória com o CN e a validade informados
+func certificadoGerado(t *testing.T, cn string, validade time.Time) *assinatura.Certificado {
+	t.Helper()
+	chave, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("falha ao gerar chave: %v", err)
+	}
+	modelo := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    validade.AddDate(-1, 0, 0),
+		NotAfter:     validade,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, modelo, modelo, &chave.PublicKey, chave)
+	if err != nil {
+		t.Fatalf("falha ao gerar certificado: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &assinatura.Certificado{X509: cert, Chave: chave}
+}
+
+func TestCarregarPFX(t *testing.T) {
+	t.Run("deve carregar chave e certificado do titular", func(t *testing.T) {
+		c := certificadoTeste(t)
+		if c.Chave == nil || c.X509 == nil {
+			t.Fatal("esperava chave e certificado carregados")
+		}
+		if c.X509.Subject.CommonName != "EMPRESA TESTE LTDA" {
+			t.Errorf("CN inesperado: %s", c.X509.Subject.CommonName)
+		}
+	})
+
+	t.Run("deve ler o CNPJ do SAN ICP-Brasil", func(t *testing.T) {
+		if cnpj := certificadoTeste(t).CNPJ(); cnpj != "11222333000181" {
+			t.Errorf("esperava 11222333000181, obteve %q", cnpj)
+		}
+	})
+
+	t.Run("deve rejeitar senha errada", func(t *testing.T) {
+		dados, _ := os.ReadFile("testdata/certificado-teste.pfx")
+		if _, err := assinatura.CarregarPFX(dados, "errada"); !errors.Is(err, assinatura.ErrCertificadoInvalido) {
+			t.Errorf("esperava ErrCertificadoInvalido, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar arquivo que nao e PKCS#12", func(t *testing.T) {
+		if _, err := assinatura.CarregarPFX([]byte("nao e pfx"), senhaTeste); !errors.Is(err, assinatura.ErrCertificadoInvalido) {
+			t.Errorf("esperava ErrCertificadoInvalido, obteve: %v", err)
+		}
+	})
+}
+
+func TestCarregarConfigurado(t *testing.T) {
+	t.Run("deve retornar nil sem certificado configurado", func(t *testing.T) {
+		t.Setenv("NFE_CERTIFICADO_ARQUIVO", "")
+		t.Setenv("NFE_CERTIFICADO_PFX_BASE64", "")
+		c, err := assinatura.CarregarConfigurado()
+		if c != nil || err != nil {
+			t.Errorf("esperava nil, nil; obteve %v, %v", c, err)
+		}
+	})
+
+	t.Run("deve carregar do arquivo", func(t *testing.T) {
+		t.Setenv("NFE_CERTIFICADO_ARQUIVO", "testdata/certificado-teste.pfx")
+		t.Setenv("NFE_CERTIFICADO_PFX_BASE64", "")
+		t.Setenv("NFE_CERTIFICADO_SENHA", senhaTeste)
+		if c, err := assinatura.CarregarConfigurado(); err != nil || c == nil {
+			t.Errorf("esperava certificado, obteve erro: %v", err)
+		}
+	})
+
+	t.Run("deve carregar do secret em base64", func(t *testing.T) {
+		dados, _ := os.ReadFile("testdata/certificado-teste.pfx")
+		t.Setenv("NFE_CERTIFICADO_ARQUIVO", "")
+		t.Setenv("NFE_CERTIFICADO_PFX_BASE64", base64.StdEncoding.EncodeToString(dados))
+		t.Setenv("NFE_CERTIFICADO_SENHA", senhaTeste)
+		if c, err := assinatura.CarregarConfigurado(); err != nil || c == nil {
+			t.Errorf("esperava certificado, obteve erro: %v", err)
+		}
+	})
+}
+
+func TestCertificado_ConferirEmitente(t *testing.T) {
+	c := certificadoTeste(t)
+
+	t.Run("deve aceitar filial da mesma raiz de CNPJ", func(t *testing.T) {
+		if err := c.ConferirEmitente("11222333000262"); err != nil {
+			t.Errorf("esperava nil, obteve erro: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar outro emitente", func(t *testing.T) {
+		if err := c.ConferirEmitente("44555666000199"); !errors.Is(err, assinatura.ErrCertificadoOutroEmitente) {
+			t.Errorf("esperava ErrCertificadoOutroEmitente, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve ler o CNPJ do sufixo do CN", func(t *testing.T) {
+		gerado := certificadoGerado(t, "OUTRA EMPRESA LTDA:44555666000199", time.Now().AddDate(1, 0, 0))
+		if gerado.CNPJ() != "44555666000199" {
+			t.Errorf("esperava CNPJ do CN, obteve %q", gerado.CNPJ())
+		}
+	})
+
+	t.Run("deve aceitar certificado sem CNPJ", func(t *testing.T) {
+		gerado := certificadoGerado(t, "Sem CNPJ", time.Now().AddDate(1, 0, 0))
+		if err := gerado.ConferirEmitente("44555666000199"); err != nil {
+			t.Errorf("esperava nil, obteve erro: %v", err)
+		}
+	})
+}
+
+func TestCertificado_Expiracao(t *testing.T) {
+	vencimento := time.Date(2026, 6, 30, 12, 0, 0, 0, time.UTC)
+	c := certificadoGerado(t, "EMPRESA TESTE LTDA:11222333000181", vencimento)
+
+	casos := []struct {
+		nome   string
+		agora  time.Time
+		dias   int
+		alerta bool
+	}{
+		{"longe do vencimento", vencimento.AddDate(0, -3, 0), 92, false},
+		{"dentro da janela de alerta", vencimento.Add(-10 * 24 * time.Hour), 10, true},
+		{"no limite da janela", vencimento.Add(-assinatura.AlertaExpiracaoPadrao), 30, true},
+		{"vencido", vencimento.Add(36 * time.Hour), -2, true},
+	}
+	for _, caso := range casos {
+		t.Run("deve calcular "+caso.nome, func(t *testing.T) {
+			if dias := c.DiasParaExpirar(caso.agora); dias != caso.dias {
+				t.Errorf("esperava %d dias, obteve %d", caso.dias, dias)
+			}
+			if alerta := c.ExpiraEm(caso.agora, assinatura.AlertaExpiracaoPadrao); alerta != caso.alerta {
+				t.Errorf("esperava alerta %v, obteve %v", caso.alerta, alerta)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package assinatura
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	_ "crypto/sha256" // registra crypto.SHA256 para a verificação
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Algoritmos exigidos pelo Manual de Orientação do Contribuinte (assinatura enveloped)
+const (
+	NamespaceDSig          = "http://www.w3.org/2000/09/xmldsig#"
+	AlgoritmoC14N          = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	AlgoritmoRSA           = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	AlgoritmoSHA1          = "http://www.w3.org/2000/09/xmldsig#sha1"
+	TransformacaoEnveloped = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+
+	algoritmoRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algoritmoSHA256    = "http://www.w3.org/2001/04/xmlenc#sha256"
+)
+
+var (
+	// ErrElementoNaoEncontrado indica documento sem o elemento a assinar ou sem Id
+	ErrElementoNaoEncontrado = errors.New("elemento a assinar nao encontrado")
+	// ErrDocumentoSemAssinatura indica documento importado sem Signature
+	ErrDocumentoSemAssinatura = errors.New("documento sem assinatura digital")
+	// ErrAssinaturaInvalida indica assinatura que não confere com o documento
+	ErrAssinaturaInvalida = errors.New("assinatura digital invalida")
+)
+
+// marcadorValor ocupa o SignatureValue até o SignedInfo ser canonicalizado no documento
+const marcadorValor = "ASSINATURA-PENDENTE"
+
+// Assinar assina o elemento informado (infNFe, infEvento, infInut) pelo atributo Id e
+// insere o Signature como último filho do elemento pai, como no leiaute da SEFAZ.
+func (c *Certificado) Assinar(documento []byte, elemento string) ([]byte, error) {
+	alvo, id, err := localizar(documento, elemento)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha1.Sum(canonizar(alvo, nil))
+	assinatura := `<Signature xmlns="` + NamespaceDSig + `"><SignedInfo>` +
+		`<CanonicalizationMethod Algorithm="` + AlgoritmoC14N + `"/>` +
+		`<SignatureMethod Algorithm="` + AlgoritmoRSA + `"/>` +
+		`<Reference URI="#` + id + `"><Transforms>` +
+		`<Transform Algorithm="` + TransformacaoEnveloped + `"/>` +
+		`<Transform Algorithm="` + AlgoritmoC14N + `"/>` +
+		`</Transforms><DigestMethod Algorithm="` + AlgoritmoSHA1 + `"/>` +
+		`<DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</DigestValue></Reference></SignedInfo>` +
+		`<SignatureValue>` + marcadorValor + `</SignatureValue>` +
+		`<KeyInfo><X509Data><X509Certificate>` + base64.StdEncoding.EncodeToString(c.X509.Raw) +
+		`</X509Certificate></X509Data></KeyInfo></Signature>`
+
+	pos := alvo.pai.inicioFim
+	var assinado bytes.Buffer
+	assinado.Grow(len(documento) + len(assinatura) + 512)
+	assinado.Write(documento[:pos])
+	assinado.WriteString(assinatura)
+	assinado.Write(documento[pos:])
+
+	// O SignedInfo é canonicalizado já no documento final, com os namespaces herdados
+	raizAssinada, err := lerArvore(assinado.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	alvoAssinado := raizAssinada.buscar(func(n *no) bool {
+		valor, ok := n.atributo("Id")
+		return n.local == elemento && ok && valor == id
+	})
+	inseridas := alvoAssinado[0].pai.filhosElemento("Signature")
+	signedInfo := inseridas[len(inseridas)-1].filho("SignedInfo")
+	hash := sha1.Sum(canonizar(signedInfo, nil))
+	valor, err := rsa.SignPKCS1v15(rand.Reader, c.Chave, crypto.SHA1, hash[:])
+	if err != nil {
+		return nil, fmt.Errorf("falha ao assinar %s: %w", elemento, err)
+	}
+
+	return bytes.Replace(assinado.Bytes(), []byte(marcadorValor), []byte(base64.StdEncoding.EncodeToString(valor)), 1), nil
+}
+
+// DigestValue calcula o DigestValue que a assinatura do elemento terá, sem precisar
+// do certificado: o QR Code da NFC-e emitida offline leva o digest da nota
+func DigestValue(documento []byte, elemento string) (string, error) {
+	alvo, _, err := localizar(documento, elemento)
+	if err != nil {
+		return "", err
+	}
+	digest := sha1.Sum(canonizar(alvo, nil))
+	return base64.StdEncoding.EncodeToString(digest[:]), nil
+}
+
+// localizar encontra o único elemento a assinar e o seu atributo Id
+func localizar(documento []byte, elemento string) (*no, string, error) {
+	raiz, err := lerArvore(documento)
+	if err != nil {
+		return nil, "", err
+	}
+	alvos := raiz.buscar(func(n *no) bool { return n.local == elemento })
+	if len(alvos) != 1 || alvos[0].pai == nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrElementoNaoEncontrado, elemento)
+	}
+	id, ok := alvos[0].atributo("Id")
+	if !ok || id == "" {
+		return nil, "", fmt.Errorf("%w: %s sem atributo Id", ErrElementoNaoEncontrado, elemento)
+	}
+	return alvos[0], id, nil
+}
+
+// Verificar confere todas as assinaturas do documento (a do emitente e, em nfeProc,
+// a da SEFAZ no protocolo) e devolve o certificado da primeira. A cadeia até a raiz
+// ICP-Brasil não é validada.
+func Verificar(documento []byte) (*x509.Certificate, error) {
+	raiz, err := lerArvore(documento)
+	if err != nil {
+		return nil, err
+	}
+	assinaturas := raiz.buscar(func(n *no) bool {
+		return n.local == "Signature" && n.namespace() == NamespaceDSig
+	})
+	if len(assinaturas) == 0 {
+		return nil, ErrDocumentoSemAssinatura
+	}
+
+	var primeiro *x509.Certificate
+	for _, s := range assinaturas {
+		cert, err := verificarAssinatura(raiz, s)
+		if err != nil {
+			return nil, err
+		}
+		if primeiro == nil {
+			primeiro = cert
+		}
+	}
+	return primeiro, nil
+}
+
+func verificarAssinatura(raiz, assinatura *no) (*x509.Certificate, error) {
+	invalida := func(formato string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrAssinaturaInvalida, fmt.Sprintf(formato, args...))
+	}
+
+	signedInfo := assinatura.filho("SignedInfo")
+	if signedInfo == nil {
+		return nil, invalida("SignedInfo ausente")
+	}
+	if alg := algoritmo(signedInfo.filho("CanonicalizationMethod")); alg != AlgoritmoC14N {
+		return nil, invalida("canonicalizacao nao suportada %q", alg)
+	}
+	hashAssinatura, ok := hashes[algoritmo(signedInfo.filho("SignatureMethod"))]
+	if !ok {
+		return nil, invalida("SignatureMethod nao suportado %q", algoritmo(signedInfo.filho("SignatureMethod")))
+	}
+
+	referencias := signedInfo.filhosElemento("Reference")
+	if len(referencias) != 1 {
+		return nil, invalida("esperava uma Reference, encontrou %d", len(referencias))
+	}
+	ref := referencias[0]
+	uri, _ := ref.atributo("URI")
+	if !strings.HasPrefix(uri, "#") || len(uri) < 2 {
+		return nil, invalida("Reference URI %q nao aponta para um Id do documento", uri)
+	}
+	if transforms := ref.filho("Transforms"); transforms != nil {
+		for _, t := range transforms.filhosElemento("Transform") {
+			if alg := algoritmo(t); alg != TransformacaoEnveloped && alg != AlgoritmoC14N {
+				return nil, invalida("Transform nao suportada %q", alg)
+			}
+		}
+	}
+	hashDigest, ok := hashes[digestMethod(ref)]
+	if !ok {
+		return nil, invalida("DigestMethod nao suportado %q", digestMethod(ref))
+	}
+
+	alvos := raiz.buscar(func(n *no) bool {
+		id, ok := n.atributo("Id")
+		return ok && id == uri[1:]
+	})
+	if len(alvos) != 1 {
+		return nil, invalida("elemento %s nao encontrado", uri)
+	}
+	esperado, err := decodificar(ref.filho("DigestValue"))
+	if err != nil {
+		return nil, invalida("DigestValue: %v", err)
+	}
+	h := hashDigest.New()
+	h.Write(canonizar(alvos[0], assinatura))
+	if !bytes.Equal(h.Sum(nil), esperado) {
+		return nil, invalida("conteudo de %s foi alterado depois da assinatura", uri)
+	}
+
+	var certificado *no
+	if keyInfo := assinatura.filho("KeyInfo"); keyInfo != nil {
+		if dados := keyInfo.filho("X509Data"); dados != nil {
+			certificado = dados.filho("X509Certificate")
+		}
+	}
+	der, err := decodificar(certificado)
+	if err != nil {
+		return nil, invalida("X509Certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, invalida("X509Certificate: %v", err)
+	}
+	chave, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, invalida("certificado sem chave RSA")
+	}
+
+	valor, err := decodificar(assinatura.filho("SignatureValue"))
+	if err != nil {
+		return nil, invalida("SignatureValue: %v", err)
+	}
+	h = hashAssinatura.New()
+	h.Write(canonizar(signedInfo, nil))
+	if err := rsa.VerifyPKCS1v15(chave, hashAssinatura, h.Sum(nil), valor); err != nil {
+		return nil, invalida("SignatureValue nao confere com o certificado")
+	}
+	return cert, nil
+}
+
+// hashes aceita SHA-1, exigido pela SEFAZ, e SHA-256, usado por alguns emissores
+var hashes = map[string]crypto.Hash{
+	AlgoritmoRSA:       crypto.SHA1,
+	AlgoritmoSHA1:      crypto.SHA1,
+	algoritmoRSASHA256: crypto.SHA256,
+	algoritmoSHA256:    crypto.SHA256,
+}
+
+func algoritmo(n *no) string {
+	if n == nil {
+		return ""
+	}
+	alg, _ := n.atributo("Algorithm")
+	return alg
+}
+
+func digestMethod(ref *no) string {
+	return algoritmo(ref.filho("DigestMethod"))
+}
+
+// decodificar lê o base64 do elemento, ignorando quebras de linha
+func decodificar(n *no) ([]byte, error) {
+	if n == nil {
+		return nil, errors.New("elemento ausente")
+	}
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(n.texto()), ""))
+}
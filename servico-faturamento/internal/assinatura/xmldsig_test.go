@@ -0,0 +1,154 @@
+package assinatura_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"servico-faturamento/internal/assinatura"
+)
+
+const nfeTeste = `<?xml version="1.0" encoding="UTF-8"?>` +
+	`<NFe xmlns="http://www.portalfiscal.inf.br/nfe"><infNFe versao="4.00" Id="NFe35250311222333000181550010000000101000000107">` +
+	`<ide><cUF>35</cUF><natOp>Venda de mercadoria</natOp></ide>` +
+	`<emit><CNPJ>11222333000181</CNPJ><xNome>Empresa Teste &amp; Cia &lt;LTDA&gt;</xNome></emit>` +
+	`<det nItem="1"><prod><cProd>001</cProd><xProd>Produto</xProd></prod></det><infAdic></infAdic></infNFe></NFe>`
+
+func TestAssinar(t *testing.T) {
+	c := certificadoTeste(t)
+
+	t.Run("deve inserir Signature enveloped depois do infNFe", func(t *testing.T) {
+		assinado, err := c.Assinar([]byte(nfeTeste), "infNFe")
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		xmlAssinado := string(assinado)
+		for _, trecho := range []string{
+			`</infNFe><Signature xmlns="http://www.w3.org/2000/09/xmldsig#"><SignedInfo>`,
+			`<CanonicalizationMethod Algorithm="http://www.w3.org/TR/2001/REC-xml-c14n-20010315"/>`,
+			`<SignatureMethod Algorithm="http://www.w3.org/2000/09/xmldsig#rsa-sha1"/>`,
+			`<Reference URI="#NFe35250311222333000181550010000000101000000107">`,
+			`<Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/>`,
+			`<DigestMethod Algorithm="http://www.w3.org/2000/09/xmldsig#sha1"/>`,
+			`<X509Certificate>MII`,
+			`</Signature></NFe>`,
+		} {
+			if !strings.Contains(xmlAssinado, trecho) {
+				t.Errorf("XML sem o trecho %q:\n%s", trecho, xmlAssinado)
+			}
+		}
+		if !strings.HasPrefix(xmlAssinado, nfeTeste[:len(nfeTeste)-len("</NFe>")]) {
+			t.Error("esperava o documento original preservado antes da assinatura")
+		}
+	})
+
+	t.Run("deve exigir o elemento com Id", func(t *testing.T) {
+		if _, err := c.Assinar([]byte(nfeTeste), "infEvento"); !errors.Is(err, assinatura.ErrElementoNaoEncontrado) {
+			t.Errorf("esperava ErrElementoNaoEncontrado, obteve: %v", err)
+		}
+		semID := strings.Replace(nfeTeste, ` Id="NFe35250311222333000181550010000000101000000107"`, "", 1)
+		if _, err := c.Assinar([]byte(semID), "infNFe"); !errors.Is(err, assinatura.ErrElementoNaoEncontrado) {
+			t.Errorf("esperava ErrElementoNaoEncontrado, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve assinar evento dentro do envelope", func(t *testing.T) {
+		evento := `<evento xmlns="http://www.portalfiscal.inf.br/nfe" versao="1.00"><infEvento Id="ID1101103525031122233300018155001000000010100000010701">` +
+			`<cOrgao>35</cOrgao></infEvento></evento>`
+		assinado, err := c.Assinar([]byte(evento), "infEvento")
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if _, err := assinatura.Verificar(assinado); err != nil {
+			t.Errorf("esperava assinatura valida, obteve: %v", err)
+		}
+	})
+}
+
+func TestDigestValue(t *testing.T) {
+	t.Run("deve antecipar o DigestValue da assinatura", func(t *testing.T) {
+		digest, err := assinatura.DigestValue([]byte(nfeTeste), "infNFe")
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		assinado, err := certificadoTeste(t).Assinar([]byte(nfeTeste), "infNFe")
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if !strings.Contains(string(assinado), "<DigestValue>"+digest+"</DigestValue>") {
+			t.Errorf("DigestValue %s difere do gravado na assinatura", digest)
+		}
+	})
+
+	t.Run("deve exigir o elemento com Id", func(t *testing.T) {
+		if _, err := assinatura.DigestValue([]byte(nfeTeste), "infEvento"); !errors.Is(err, assinatura.ErrElementoNaoEncontrado) {
+			t.Errorf("esperava ErrElementoNaoEncontrado, obteve: %v", err)
+		}
+	})
+}
+
+func TestVerificar(t *testing.T) {
+	c := certificadoTeste(t)
+	assinado, err := c.Assinar([]byte(nfeTeste), "infNFe")
+	if err != nil {
+		t.Fatalf("falha ao assinar: %v", err)
+	}
+
+	t.Run("deve aceitar documento recem assinado e devolver o certificado", func(t *testing.T) {
+		cert, err := assinatura.Verificar(assinado)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if !cert.Equal(c.X509) {
+			t.Error("esperava o certificado do signatario")
+		}
+	})
+
+	t.Run("deve aceitar variacoes que a canonicalizacao elimina", func(t *testing.T) {
+		// aspas simples, ordem dos atributos, tag vazia abreviada e entidade numérica
+		variado := bytes.Replace(assinado, []byte(`<infNFe versao="4.00" Id="NFe35250311222333000181550010000000101000000107">`),
+			[]byte(`<infNFe Id='NFe35250311222333000181550010000000101000000107' versao='4.00'>`), 1)
+		variado = bytes.Replace(variado, []byte(`<natOp>Venda de mercadoria</natOp>`), []byte(`<natOp>Venda de&#32;mercadoria</natOp>`), 1)
+		variado = bytes.Replace(variado, []byte(`<infAdic></infAdic>`), []byte(`<infAdic/>`), 1)
+		if _, err := assinatura.Verificar(variado); err != nil {
+			t.Errorf("esperava assinatura valida, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve aceitar NF-e dentro do nfeProc", func(t *testing.T) {
+		semDeclaracao := bytes.TrimPrefix(assinado, []byte(`<?xml version="1.0" encoding="UTF-8"?>`))
+		proc := `<?xml version="1.0" encoding="UTF-8"?><nfeProc xmlns="http://www.portalfiscal.inf.br/nfe" versao="4.00">` +
+			string(semDeclaracao) + `<protNFe versao="4.00"><infProt><cStat>100</cStat></infProt></protNFe></nfeProc>`
+		if _, err := assinatura.Verificar([]byte(proc)); err != nil {
+			t.Errorf("esperava assinatura valida, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar conteudo alterado", func(t *testing.T) {
+		alterado := bytes.Replace(assinado, []byte("<cProd>001</cProd>"), []byte("<cProd>002</cProd>"), 1)
+		if _, err := assinatura.Verificar(alterado); !errors.Is(err, assinatura.ErrAssinaturaInvalida) {
+			t.Errorf("esperava ErrAssinaturaInvalida, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar SignedInfo alterado", func(t *testing.T) {
+		alterado := bytes.Replace(assinado, []byte(`<Transform Algorithm="http://www.w3.org/TR/2001/REC-xml-c14n-20010315"/>`), nil, 1)
+		if _, err := assinatura.Verificar(alterado); !errors.Is(err, assinatura.ErrAssinaturaInvalida) {
+			t.Errorf("esperava ErrAssinaturaInvalida, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar documento sem assinatura", func(t *testing.T) {
+		if _, err := assinatura.Verificar([]byte(nfeTeste)); !errors.Is(err, assinatura.ErrDocumentoSemAssinatura) {
+			t.Errorf("esperava ErrDocumentoSemAssinatura, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar XML malformado", func(t *testing.T) {
+		if _, err := assinatura.Verificar(assinado[:len(assinado)-6]); err == nil {
+			t.Error("esperava erro para XML incompleto")
+		}
+	})
+}
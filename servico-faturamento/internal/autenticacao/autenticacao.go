@@ -0,0 +1,212 @@
+// Package autenticacao confere os ID tokens do Cognito na API em container, com as
+// mesmas regras do autorizador da API Gateway (infra/lambda-authorizer): assinatura
+// RS256 com as chaves públicas do user pool, emissor, client id, token_use "id" e
+// prazo. No deploy serverless quem confere é o autorizador e este pacote não é usado.
+package autenticacao
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrTokenAusente indica requisição sem o cabeçalho Authorization: Bearer <token>
+	ErrTokenAusente = errors.New("token de autenticacao ausente")
+	// ErrTokenInvalido indica token malformado, com assinatura inválida ou de outro user pool
+	ErrTokenInvalido = errors.New("token de autenticacao invalido")
+	// ErrTokenExpirado indica token com assinatura válida e prazo vencido
+	ErrTokenExpirado = errors.New("token de autenticacao expirado")
+)
+
+// intervaloMinimoChaves limita a releitura das chaves quando chega um kid desconhecido,
+// para que tokens forjados não virem uma consulta ao Cognito por requisição
+const intervaloMinimoChaves = time.Minute
+
+// Identidade é o usuário do token conferido
+type Identidade struct {
+	Sub      string
+	Email    string
+	Username string
+	Grupos   []string
+}
+
+// Verificador confere os ID tokens de um user pool. URLChaves é o JWKS do pool e
+// Emissor o iss esperado; NovoVerificador os deriva do ID do pool.
+type Verificador struct {
+	ClientID  string
+	Emissor   string
+	URLChaves string
+	HTTP      *http.Client
+
+	mu            sync.Mutex
+	chaves        map[string]*rsa.PublicKey
+	ultimaLeitura time.Time
+}
+
+// NovoVerificador monta o verificador do user pool (formato <regiao>_<id>)
+func NovoVerificador(userPoolID, clientID string) (*Verificador, error) {
+	regiao, _, ok := strings.Cut(userPoolID, "_")
+	if !ok || regiao == "" || clientID == "" {
+		return nil, fmt.Errorf("user pool %q ou client id invalidos", userPoolID)
+	}
+	emissor := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", regiao, userPoolID)
+	return &Verificador{
+		ClientID:  clientID,
+		Emissor:   emissor,
+		URLChaves: emissor + "/.well-known/jwks.json",
+		HTTP:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// CarregarConfigurado lê COGNITO_USER_POOL_ID e COGNITO_CLIENT_ID. Sem os dois
+// devolve nil, nil; cabe a quem chama decidir se a API pode subir sem autenticação.
+func CarregarConfigurado() (*Verificador, error) {
+	userPoolID := os.Getenv("COGNITO_USER_POOL_ID")
+	clientID := os.Getenv("COGNITO_CLIENT_ID")
+	if userPoolID == "" && clientID == "" {
+		return nil, nil
+	}
+	if userPoolID == "" || clientID == "" {
+		return nil, errors.New("COGNITO_USER_POOL_ID e COGNITO_CLIENT_ID devem ser configurados juntos")
+	}
+	return NovoVerificador(userPoolID, clientID)
+}
+
+type cabecalhoJWT struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type claimsJWT struct {
+	Sub      string   `json:"sub"`
+	Iss      string   `json:"iss"`
+	Aud      string   `json:"aud"`
+	TokenUse string   `json:"token_use"`
+	Exp      int64    `json:"exp"`
+	Email    string   `json:"email"`
+	Username string   `json:"cognito:username"`
+	Grupos   []string `json:"cognito:groups"`
+}
+
+// Verificar confere a assinatura antes das claims, para que tokens forjados não
+// revelem se estão vencidos
+func (v *Verificador) Verificar(ctx context.Context, token string, agora time.Time) (Identidade, error) {
+	partes := strings.Split(token, ".")
+	if len(partes) != 3 {
+		return Identidade{}, ErrTokenInvalido
+	}
+	var cabecalho cabecalhoJWT
+	if err := decodificarParte(partes[0], &cabecalho); err != nil || cabecalho.Alg != "RS256" {
+		return Identidade{}, ErrTokenInvalido
+	}
+	assinatura, err := base64.RawURLEncoding.DecodeString(partes[2])
+	if err != nil {
+		return Identidade{}, ErrTokenInvalido
+	}
+	chave, err := v.chave(ctx, cabecalho.Kid, agora)
+	if err != nil {
+		return Identidade{}, err
+	}
+	resumo := sha256.Sum256([]byte(partes[0] + "." + partes[1]))
+	if rsa.VerifyPKCS1v15(chave, crypto.SHA256, resumo[:], assinatura) != nil {
+		return Identidade{}, ErrTokenInvalido
+	}
+
+	var claims claimsJWT
+	if err := decodificarParte(partes[1], &claims); err != nil {
+		return Identidade{}, ErrTokenInvalido
+	}
+	if claims.Iss != v.Emissor || claims.Aud != v.ClientID || claims.TokenUse != "id" || claims.Sub == "" {
+		return Identidade{}, ErrTokenInvalido
+	}
+	if !agora.Before(time.Unix(claims.Exp, 0)) {
+		return Identidade{}, ErrTokenExpirado
+	}
+	return Identidade{Sub: claims.Sub, Email: claims.Email, Username: claims.Username, Grupos: claims.Grupos}, nil
+}
+
+func decodificarParte(parte string, destino interface{}) error {
+	conteudo, err := base64.RawURLEncoding.DecodeString(parte)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(conteudo, destino)
+}
+
+// chave devolve a chave pública do kid, relendo o JWKS quando o kid é desconhecido
+// (rotação das chaves do pool)
+func (v *Verificador) chave(ctx context.Context, kid string, agora time.Time) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if chave, ok := v.chaves[kid]; ok {
+		return chave, nil
+	}
+	if v.chaves != nil && agora.Sub(v.ultimaLeitura) < intervaloMinimoChaves {
+		return nil, ErrTokenInvalido
+	}
+	chaves, err := v.lerChaves(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.chaves = chaves
+	v.ultimaLeitura = agora
+	if chave, ok := chaves[kid]; ok {
+		return chave, nil
+	}
+	return nil, ErrTokenInvalido
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func (v *Verificador) lerChaves(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.URLChaves, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao ler chaves do Cognito: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("falha ao ler chaves do Cognito: status %d", resp.StatusCode)
+	}
+
+	var conjunto jwks
+	if err := json.NewDecoder(resp.Body).Decode(&conjunto); err != nil {
+		return nil, fmt.Errorf("JWKS do Cognito invalido: %w", err)
+	}
+	chaves := make(map[string]*rsa.PublicKey, len(conjunto.Keys))
+	for _, k := range conjunto.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		chaves[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return chaves, nil
+}
@@ -0,0 +1,200 @@
+package autenticacao_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/autenticacao"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	userPoolID = "sa-east-1_Teste123"
+	clientID   = "cliente-teste"
+)
+
+// poolTeste publica o JWKS de uma chave RSA gerada para o teste e assina tokens com ela
+type poolTeste struct {
+	chave    *rsa.PrivateKey
+	kid      string
+	leituras int
+}
+
+func novoPoolTeste(t *testing.T) (*poolTeste, *autenticacao.Verificador) {
+	t.Helper()
+	chave, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("falha ao gerar chave: %v", err)
+	}
+	pool := &poolTeste{chave: chave, kid: "kid-1"}
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pool.leituras++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": pool.kid,
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(chave.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(chave.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(servidor.Close)
+
+	v, err := autenticacao.NovoVerificador(userPoolID, clientID)
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	v.URLChaves = servidor.URL
+	return pool, v
+}
+
+func (p *poolTeste) token(t *testing.T, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	codificar := func(v interface{}) string {
+		conteudo, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("falha ao serializar: %v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(conteudo)
+	}
+	assinado := codificar(map[string]string{"alg": "RS256", "kid": kid}) + "." + codificar(claims)
+	resumo := sha256.Sum256([]byte(assinado))
+	assinatura, err := rsa.SignPKCS1v15(rand.Reader, p.chave, crypto.SHA256, resumo[:])
+	if err != nil {
+		t.Fatalf("falha ao assinar: %v", err)
+	}
+	return assinado + "." + base64.RawURLEncoding.EncodeToString(assinatura)
+}
+
+func claimsValidas(agora time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":              "usuario-1",
+		"iss":              "https://cognito-idp.sa-east-1.amazonaws.com/" + userPoolID,
+		"aud":              clientID,
+		"token_use":        "id",
+		"exp":              agora.Add(time.Hour).Unix(),
+		"email":            "operador@exemplo.com",
+		"cognito:username": "operador",
+	}
+}
+
+func TestVerificador(t *testing.T) {
+	agora := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	t.Run("deve aceitar ID token do user pool dentro do prazo", func(t *testing.T) {
+		pool, v := novoPoolTeste(t)
+		identidade, err := v.Verificar(ctx, pool.token(t, pool.kid, claimsValidas(agora)), agora)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if identidade.Sub != "usuario-1" || identidade.Username != "operador" {
+			t.Errorf("identidade inesperada: %+v", identidade)
+		}
+	})
+
+	t.Run("deve recusar token vencido", func(t *testing.T) {
+		pool, v := novoPoolTeste(t)
+		claims := claimsValidas(agora)
+		claims["exp"] = agora.Unix()
+		if _, err := v.Verificar(ctx, pool.token(t, pool.kid, claims), agora); !errors.Is(err, autenticacao.ErrTokenExpirado) {
+			t.Errorf("esperava ErrTokenExpirado, obteve %v", err)
+		}
+	})
+
+	t.Run("deve recusar token de outro cliente, emissor ou uso", func(t *testing.T) {
+		pool, v := novoPoolTeste(t)
+		for campo, valor := range map[string]string{
+			"aud":       "outro-cliente",
+			"iss":       "https://cognito-idp.sa-east-1.amazonaws.com/sa-east-1_Outro",
+			"token_use": "access",
+		} {
+			claims := claimsValidas(agora)
+			claims[campo] = valor
+			if _, err := v.Verificar(ctx, pool.token(t, pool.kid, claims), agora); !errors.Is(err, autenticacao.ErrTokenInvalido) {
+				t.Errorf("%s: esperava ErrTokenInvalido, obteve %v", campo, err)
+			}
+		}
+	})
+
+	t.Run("deve recusar token adulterado", func(t *testing.T) {
+		pool, v := novoPoolTeste(t)
+		token := pool.token(t, pool.kid, claimsValidas(agora))
+		outro := pool.token(t, pool.kid, map[string]interface{}{"sub": "admin"})
+		adulterado := token[:len(token)-10] + outro[len(outro)-10:]
+		if _, err := v.Verificar(ctx, adulterado, agora); !errors.Is(err, autenticacao.ErrTokenInvalido) {
+			t.Errorf("esperava ErrTokenInvalido, obteve %v", err)
+		}
+	})
+
+	t.Run("deve reler as chaves no maximo uma vez por minuto para kid desconhecido", func(t *testing.T) {
+		pool, v := novoPoolTeste(t)
+		if _, err := v.Verificar(ctx, pool.token(t, pool.kid, claimsValidas(agora)), agora); err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := v.Verificar(ctx, pool.token(t, "kid-forjado", claimsValidas(agora)), agora); !errors.Is(err, autenticacao.ErrTokenInvalido) {
+				t.Errorf("esperava ErrTokenInvalido, obteve %v", err)
+			}
+		}
+		if pool.leituras != 1 {
+			t.Errorf("esperava 1 leitura do JWKS, obteve %d", pool.leituras)
+		}
+
+		// Rotação: a chave nova passa a valer depois do intervalo
+		pool.kid = "kid-2"
+		if _, err := v.Verificar(ctx, pool.token(t, "kid-2", claimsValidas(agora)), agora.Add(2*time.Minute)); err != nil {
+			t.Errorf("erro inesperado apos rotacao: %v", err)
+		}
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pool, v := novoPoolTeste(t)
+
+	r := gin.New()
+	r.GET("/notas/:id/pdf", v.Middleware(), func(c *gin.Context) {
+		identidade := c.MustGet(autenticacao.ChaveIdentidade).(autenticacao.Identidade)
+		c.String(http.StatusOK, identidade.Sub)
+	})
+
+	requisitar := func(autorizacao string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/notas/1/pdf", nil)
+		if autorizacao != "" {
+			req.Header.Set("Authorization", autorizacao)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("deve responder 401 sem token", func(t *testing.T) {
+		if w := requisitar(""); w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, esperava 401", w.Code)
+		}
+	})
+
+	t.Run("deve responder 401 com token invalido", func(t *testing.T) {
+		if w := requisitar("Bearer abc.def.ghi"); w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, esperava 401", w.Code)
+		}
+	})
+
+	t.Run("deve liberar a rota com token valido", func(t *testing.T) {
+		w := requisitar("Bearer " + pool.token(t, pool.kid, claimsValidas(time.Now())))
+		if w.Code != http.StatusOK || w.Body.String() != "usuario-1" {
+			t.Errorf("status = %d, corpo = %s", w.Code, w.Body.String())
+		}
+	})
+}
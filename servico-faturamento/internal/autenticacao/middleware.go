@@ -0,0 +1,41 @@
+package autenticacao
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChaveIdentidade guarda a Identidade do token no contexto do gin
+const ChaveIdentidade = "identidade"
+
+// Middleware exige um ID token válido no cabeçalho Authorization: Bearer <token>.
+// Token ausente, inválido ou vencido responde 401; falha ao ler as chaves do
+// Cognito responde 503.
+func (v *Verificador) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"erro": ErrTokenAusente.Error()})
+			return
+		}
+
+		identidade, err := v.Verificar(c.Request.Context(), strings.TrimSpace(token), time.Now())
+		switch {
+		case errors.Is(err, ErrTokenInvalido), errors.Is(err, ErrTokenExpirado):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"erro": err.Error()})
+			return
+		case err != nil:
+			slog.Error("Falha ao conferir token", "erro", err.Error())
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"erro": "autenticacao indisponivel"})
+			return
+		}
+
+		c.Set(ChaveIdentidade, identidade)
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+// Package codigobarras gera o código de barras Code-128 impresso no DANFE. Só
+// implementa o conjunto C, que codifica pares de dígitos e é o exigido para a
+// chave de acesso de 44 dígitos.
+package codigobarras
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrDigitos indica texto com caracteres que não são dígitos ou com quantidade
+// ímpar de dígitos, que o conjunto C não representa
+var ErrDigitos = errors.New("o conjunto C do Code-128 exige quantidade par de digitos")
+
+const (
+	inicioC = 105
+	parada  = 106
+)
+
+// padroes são as larguras alternadas de barra e espaço, em módulos, de cada
+// símbolo do Code-128 (0 a 102 dados, 103 a 105 início, 106 parada)
+var padroes = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+// Code128C codifica os dígitos e devolve os módulos do símbolo, da esquerda para
+// a direita (true = barra), sem a zona de silêncio
+func Code128C(digitos string) ([]bool, error) {
+	if digitos == "" || len(digitos)%2 != 0 || strings.Trim(digitos, "0123456789") != "" {
+		return nil, ErrDigitos
+	}
+
+	valores := []int{inicioC}
+	soma := inicioC
+	for i := 0; i < len(digitos); i += 2 {
+		v := int(digitos[i]-'0')*10 + int(digitos[i+1]-'0')
+		soma += v * (i/2 + 1)
+		valores = append(valores, v)
+	}
+	valores = append(valores, soma%103, parada)
+
+	var modulos []bool
+	for _, v := range valores {
+		for i, largura := range padroes[v] {
+			for n := 0; n < int(largura-'0'); n++ {
+				modulos = append(modulos, i%2 == 0)
+			}
+		}
+	}
+	return modulos, nil
+}
@@ -0,0 +1,64 @@
+package codigobarras_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"servico-faturamento/internal/codigobarras"
+)
+
+func texto(modulos []bool) string {
+	var b strings.Builder
+	for _, barra := range modulos {
+		if barra {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	return b.String()
+}
+
+func TestCode128C(t *testing.T) {
+	t.Run("deve codificar inicio C, pares, digito verificador e parada", func(t *testing.T) {
+		modulos, err := codigobarras.Code128C("1234")
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		// início C | 12 | 34 | verificador (105 + 12*1 + 34*2) % 103 = 82 | parada
+		esperado := "11010011100" + "10110011100" + "10001011000" + "10010011110" + "1100011101011"
+		if got := texto(modulos); got != esperado {
+			t.Errorf("modulos inesperados:\n%s\nesperava\n%s", got, esperado)
+		}
+	})
+
+	t.Run("deve codificar a chave de acesso em 22 simbolos de dados", func(t *testing.T) {
+		chave := "35250311222333000181550010000000101000000100"
+		modulos, err := codigobarras.Code128C(chave)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if len(modulos) != 11*24+13 {
+			t.Fatalf("esperava %d modulos, obteve %d", 11*24+13, len(modulos))
+		}
+		s := texto(modulos)
+		for i := 0; i < 11*24; i += 11 {
+			simbolo := s[i : i+11]
+			if simbolo[0] != '1' || simbolo[10] != '0' || strings.Count(simbolo, "01") != 2 {
+				t.Errorf("simbolo %d fora do padrao de 3 barras e 3 espacos: %s", i/11, simbolo)
+			}
+		}
+		if !strings.HasSuffix(s, "1100011101011") {
+			t.Error("esperava o padrao de parada no final")
+		}
+	})
+
+	t.Run("deve recusar quantidade impar ou caracteres que nao sao digitos", func(t *testing.T) {
+		for _, entrada := range []string{"", "123", "12a4", "12 4"} {
+			if _, err := codigobarras.Code128C(entrada); !errors.Is(err, codigobarras.ErrDigitos) {
+				t.Errorf("%q: esperava ErrDigitos, obteve %v", entrada, err)
+			}
+		}
+	})
+}
@@ -30,6 +30,8 @@ func InicializarDB() (*gorm.DB, error) {
 
 	config := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent), // usar slog
+		// Converte violações de unicidade em gorm.ErrDuplicatedKey (usado para responder 409)
+		TranslateError: true,
 	}
 
 	db, err := gorm.Open(postgres.Open(dsn), config)
@@ -78,10 +80,25 @@ func InicializarDB() (*gorm.DB, error) {
 
 	slog.Info("Conexão com PostgreSQL estabelecida")
 
+	if err := prepararMigracoes(db); err != nil {
+		return nil, err
+	}
+
 	err = db.AutoMigrate(
+		&dominio.SequenciaNumeracao{},
+		&dominio.Emitente{},
+		&dominio.Cliente{},
+		&dominio.RegraTributaria{},
+		&dominio.AliquotaIBSCBS{},
 		&dominio.NotaFiscal{},
 		&dominio.ItemNota{},
+		&dominio.PagamentoNota{},
+		&dominio.CartaCorrecao{},
+		&dominio.Inutilizacao{},
+		&dominio.Contingencia{},
 		&dominio.SolicitacaoImpressao{},
+		&dominio.Documento{},
+		&dominio.EnvioEmail{},
 		&dominio.EventoOutbox{},
 		&dominio.MensagemProcessada{},
 	)
@@ -89,11 +106,102 @@ func InicializarDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("erro ao executar migrations: %w", err)
 	}
 
+	if err := criarNotificacaoOutbox(db); err != nil {
+		return nil, err
+	}
+
 	slog.Info("Migrations aplicadas com sucesso")
 
 	return db, nil
 }
 
+// prepararMigracoes ajusta o que o AutoMigrate não sabe alterar sozinho
+func prepararMigracoes(db *gorm.DB) error {
+	// O número deixou de ser único globalmente e passou a ser único por emitente,
+	// modelo e série (idx_notas_numeracao). A constraint antiga pode ter sido criada
+	// pelo script de init ou pelo próprio GORM, cada um com um nome.
+	for _, constraint := range []string{"notas_fiscais_numero_key", "uni_notas_fiscais_numero"} {
+		sql := fmt.Sprintf("ALTER TABLE IF EXISTS notas_fiscais DROP CONSTRAINT IF EXISTS %s", constraint)
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("falha ao remover constraint %s: %w", constraint, err)
+		}
+	}
+
+	// Notas existentes ficam na série 1; o default é removido em seguida porque o
+	// GORM substituiria a série 0 pelo default da coluna ao inserir
+	for _, sql := range []string{
+		"ALTER TABLE IF EXISTS notas_fiscais ADD COLUMN IF NOT EXISTS serie INTEGER NOT NULL DEFAULT 1",
+		"ALTER TABLE IF EXISTS notas_fiscais ALTER COLUMN serie DROP DEFAULT",
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("falha ao migrar serie das notas: %w", err)
+		}
+	}
+
+	// O CHECK de status do script de init antigo não aceita os retornos da SEFAZ
+	// (AUTORIZADA, REJEITADA, DENEGADA); bancos novos já nascem com a lista completa
+	if err := db.Exec("ALTER TABLE IF EXISTS notas_fiscais DROP CONSTRAINT IF EXISTS notas_fiscais_status_check").Error; err != nil {
+		return fmt.Errorf("falha ao remover constraint de status: %w", err)
+	}
+
+	// O CHECK de tpEmis anterior à NFC-e não aceita a emissão offline (9)
+	if err := db.Exec("ALTER TABLE IF EXISTS notas_fiscais DROP CONSTRAINT IF EXISTS notas_fiscais_tipo_emissao_check").Error; err != nil {
+		return fmt.Errorf("falha ao remover constraint de tipo de emissao: %w", err)
+	}
+
+	migrador := db.Migrator()
+
+	// As solicitações de impressão guardavam a URL pública do PDF (base do
+	// armazenamento + chave); passam a guardar só a chave, extraída da URL
+	if migrador.HasTable(&dominio.SolicitacaoImpressao{}) && migrador.HasColumn(&dominio.SolicitacaoImpressao{}, "pdf_url") {
+		for _, sql := range []string{
+			"ALTER TABLE solicitacoes_impressao ADD COLUMN IF NOT EXISTS pdf_chave VARCHAR(255)",
+			"UPDATE solicitacoes_impressao SET pdf_chave = substring(pdf_url from 'notas-fiscais/.*$') WHERE pdf_chave IS NULL",
+			"ALTER TABLE solicitacoes_impressao DROP COLUMN pdf_url",
+		} {
+			if err := db.Exec(sql).Error; err != nil {
+				return fmt.Errorf("falha ao migrar pdf_url das solicitacoes: %w", err)
+			}
+		}
+	}
+
+	// Eventos do outbox anteriores ao controle de tentativas: os já publicados
+	// ficam PUBLICADO, os demais PENDENTE (o default da coluna)
+	if migrador.HasTable(&dominio.EventoOutbox{}) && !migrador.HasColumn(&dominio.EventoOutbox{}, "Status") {
+		for _, sql := range []string{
+			"ALTER TABLE eventos_outbox ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'PENDENTE'",
+			"UPDATE eventos_outbox SET status = 'PUBLICADO' WHERE data_publicacao IS NOT NULL",
+		} {
+			if err := db.Exec(sql).Error; err != nil {
+				return fmt.Errorf("falha ao migrar status do outbox: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// criarNotificacaoOutbox instala o trigger que dispara NOTIFY eventos_outbox a cada
+// INSERT no outbox; o publicador da API escuta o canal e publica sem esperar a
+// varredura. Uma notificação por comando, entregue no commit.
+func criarNotificacaoOutbox(db *gorm.DB) error {
+	for _, sql := range []string{
+		`CREATE OR REPLACE FUNCTION notificar_eventos_outbox() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_notify('eventos_outbox', '');
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql`,
+		`CREATE OR REPLACE TRIGGER trg_eventos_outbox_notificar
+			AFTER INSERT ON eventos_outbox
+			FOR EACH STATEMENT EXECUTE FUNCTION notificar_eventos_outbox()`,
+	} {
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("falha ao criar notificacao do outbox: %w", err)
+		}
+	}
+	return nil
+}
+
 func buildDSN() string {
 	// Prioridade 1: DATABASE_URL completo
 	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
@@ -0,0 +1,104 @@
+package consumidor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"servico-faturamento/internal/manipulador"
+	"servico-faturamento/internal/mensageria"
+	"servico-faturamento/internal/publicador"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/gorm"
+)
+
+const (
+	filaAutorizacao = "faturamento-autorizacao"
+	// esperaRetransmissao segura a mensagem antes de devolvê-la à fila quando o
+	// banco ou o serviço de destino está fora, para não transformar a
+	// indisponibilidade em laço de reentregas
+	esperaRetransmissao = 10 * time.Second
+	// prazoAutorizacao cobre a transmissão e as consultas do recibo de uma nota
+	prazoAutorizacao = 3 * time.Minute
+)
+
+// IniciarAutorizador consome Faturamento.AutorizacaoSolicitada, gravado no outbox
+// pelo fechamento, e transmite as notas à SEFAZ uma de cada vez
+func IniciarAutorizador(ctx context.Context, handlers *manipulador.Handlers, conexao *mensageria.Conexao) {
+	if conexao == nil {
+		slog.Info("RabbitMQ desabilitado, pulando inicialização do autorizador")
+		return
+	}
+	if handlers.Sefaz == nil {
+		slog.Warn("SEFAZ nao configurada (SEFAZ_URL); notas fechadas nao serao transmitidas")
+		return
+	}
+
+	conexao.Consumir(ctx, declararFila(filaAutorizacao, manipulador.EventoAutorizacaoSolicitada), func(msg amqp.Delivery) {
+		if err := ProcessarAutorizacao(handlers, msg.Body); err != nil {
+			devolverAoOutbox(handlers.DB, msg, err)
+		} else {
+			msg.Ack(false)
+		}
+	})
+
+	slog.Info("Autorizador SEFAZ iniciado, aguardando notas fechadas...")
+}
+
+// devolverAoOutbox troca a devolução da mensagem à fila pela nova tentativa do
+// evento no outbox (MessageId é o ID do evento), que tem espera crescente e
+// limite: uma nota que nunca passa fica MORTO e o autorizador segue com as outras.
+// Só com o banco fora a mensagem volta à fila.
+func devolverAoOutbox(db *gorm.DB, msg amqp.Delivery, falha error) {
+	eventoID, err := strconv.ParseInt(msg.MessageId, 10, 64)
+	if err != nil {
+		slog.Error("Falha ao autorizar nota de mensagem sem evento do outbox; descartando", "messageId", msg.MessageId, "erro", falha.Error())
+		msg.Nack(false, false)
+		return
+	}
+	if _, err := publicador.DevolverFalhaConsumo(db, eventoID, falha); err != nil {
+		slog.Warn("Falha ao devolver evento ao outbox; nova tentativa pela fila", "eventoId", eventoID, "erro", err.Error(), "espera", esperaRetransmissao)
+		time.Sleep(esperaRetransmissao)
+		msg.Nack(false, true)
+		return
+	}
+	msg.Ack(false)
+}
+
+// ProcessarAutorizacao transmite a nota do evento; também é usado pela Lambda de
+// autorização. Só devolve erro quando a transmissão deve ser repetida: eventos
+// inválidos e notas que não podem gerar XML são registrados no log e descartados.
+func ProcessarAutorizacao(handlers *manipulador.Handlers, body []byte) error {
+	var evento struct {
+		NotaID string `json:"notaId"`
+	}
+	if err := json.Unmarshal(body, &evento); err != nil {
+		slog.Error("Evento AutorizacaoSolicitada invalido; descartando", "erro", err.Error())
+		return nil
+	}
+	notaID, err := uuid.Parse(evento.NotaID)
+	if err != nil {
+		slog.Error("Evento AutorizacaoSolicitada com notaId invalido; descartando", "notaId", evento.NotaID)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), prazoAutorizacao)
+	defer cancel()
+
+	nota, err := handlers.AutorizarNota(ctx, notaID)
+	if errors.Is(err, manipulador.ErrAutorizacaoInviavel) {
+		slog.Error("Nota nao pode ser transmitida; descartando", "notaId", notaID, "erro", err.Error())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("falha ao autorizar nota %s: %w", notaID, err)
+	}
+	slog.Info("Autorizacao processada", "notaId", notaID, "status", nota.Status)
+	return nil
+}
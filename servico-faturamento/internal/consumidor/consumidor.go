@@ -1,15 +1,19 @@
 package consumidor
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
 	"time"
 
+	"servico-faturamento/internal/contingencia"
 	"servico-faturamento/internal/dominio"
 	"servico-faturamento/internal/manipulador"
+	"servico-faturamento/internal/mensageria"
+	"servico-faturamento/internal/nfe"
+	"servico-faturamento/internal/tributacao"
 
 	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -22,37 +26,35 @@ type Consumidor struct {
 	Handlers *manipulador.Handlers
 }
 
-func IniciarConsumidor(db *gorm.DB, handlers *manipulador.Handlers) error {
-	rabbitURL := os.Getenv("RABBITMQ_URL")
-
-	// Se RABBITMQ_URL estiver vazio ou "disabled", pula inicialização (para Lambda/EventBridge)
-	if rabbitURL == "" || rabbitURL == "disabled" {
+// IniciarConsumidor consome os eventos do estoque (Estoque.*) pela conexão de
+// consumo; a topologia é refeita e o consumo retomado a cada reconexão
+func IniciarConsumidor(ctx context.Context, db *gorm.DB, handlers *manipulador.Handlers, conexao *mensageria.Conexao) {
+	// Sem conexão (RABBITMQ_URL vazio ou "disabled"), pula inicialização (para Lambda/EventBridge)
+	if conexao == nil {
 		slog.Info("RabbitMQ desabilitado, pulando inicialização do consumidor")
-		return nil
+		return
 	}
 
-	var conn *amqp.Connection
-	var err error
-
-	for i := 0; i < 10; i++ {
-		conn, err = amqp.Dial(rabbitURL)
-		if err == nil {
-			break
-		}
-		slog.Warn("Tentativa de conexão RabbitMQ falhou", "tentativa", i+1, "erro", err.Error())
-		time.Sleep(3 * time.Second)
+	consumidor := &Consumidor{
+		DB:       db,
+		Handlers: handlers,
 	}
 
-	if err != nil {
-		return fmt.Errorf("falha ao conectar RabbitMQ apos retries: %w", err)
-	}
+	conexao.Consumir(ctx, declararEstoque, func(msg amqp.Delivery) {
+		err := consumidor.ProcessarMensagem(msg)
+		if err != nil {
+			slog.Error("Erro ao processar mensagem", "erro", err.Error())
+			msg.Nack(false, true)
+		} else {
+			msg.Ack(false)
+		}
+	})
 
-	ch, err := conn.Channel()
-	if err != nil {
-		return fmt.Errorf("falha ao abrir channel: %w", err)
-	}
+	slog.Info("Consumidor RabbitMQ iniciado, aguardando mensagens...")
+}
 
-	err = ch.ExchangeDeclare(
+func declararEstoque(ch *amqp.Channel) (string, error) {
+	err := ch.ExchangeDeclare(
 		"estoque-eventos",
 		"topic",
 		true,
@@ -62,7 +64,7 @@ func IniciarConsumidor(db *gorm.DB, handlers *manipulador.Handlers) error {
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("falha ao declarar exchange: %w", err)
+		return "", fmt.Errorf("falha ao declarar exchange: %w", err)
 	}
 
 	q, err := ch.QueueDeclare(
@@ -74,7 +76,7 @@ func IniciarConsumidor(db *gorm.DB, handlers *manipulador.Handlers) error {
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("falha ao declarar fila: %w", err)
+		return "", fmt.Errorf("falha ao declarar fila: %w", err)
 	}
 
 	err = ch.QueueBind(
@@ -85,7 +87,7 @@ func IniciarConsumidor(db *gorm.DB, handlers *manipulador.Handlers) error {
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("falha ao fazer bind Reservado: %w", err)
+		return "", fmt.Errorf("falha ao fazer bind Reservado: %w", err)
 	}
 
 	err = ch.QueueBind(
@@ -96,51 +98,32 @@ func IniciarConsumidor(db *gorm.DB, handlers *manipulador.Handlers) error {
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("falha ao fazer bind Rejeitada: %w", err)
+		return "", fmt.Errorf("falha ao fazer bind Rejeitada: %w", err)
 	}
 
-	err = ch.Qos(
-		1,
-		0,
-		false,
-	)
-	if err != nil {
-		return fmt.Errorf("falha ao configurar QoS: %w", err)
-	}
-
-	msgs, err := ch.Consume(
-		q.Name,
-		"",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		return fmt.Errorf("falha ao registrar consumer: %w", err)
-	}
+	return q.Name, nil
+}
 
-	slog.Info("Consumidor RabbitMQ iniciado, aguardando mensagens...")
+// declararFila declara o exchange faturamento-eventos e a fila ligada aos eventos,
+// topologia comum ao autorizador, ao gerador de PDF e ao envio de email
+func declararFila(fila string, eventos ...string) func(ch *amqp.Channel) (string, error) {
+	return func(ch *amqp.Channel) (string, error) {
+		if err := ch.ExchangeDeclare("faturamento-eventos", "topic", true, false, false, false, nil); err != nil {
+			return "", fmt.Errorf("falha ao declarar exchange: %w", err)
+		}
 
-	consumidor := &Consumidor{
-		DB:       db,
-		Handlers: handlers,
-	}
+		q, err := ch.QueueDeclare(fila, true, false, false, false, nil)
+		if err != nil {
+			return "", fmt.Errorf("falha ao declarar fila: %w", err)
+		}
 
-	go func() {
-		for msg := range msgs {
-			err := consumidor.ProcessarMensagem(msg)
-			if err != nil {
-				slog.Error("Erro ao processar mensagem", "erro", err.Error())
-				msg.Nack(false, true)
-			} else {
-				msg.Ack(false)
+		for _, evento := range eventos {
+			if err := ch.QueueBind(q.Name, evento, "faturamento-eventos", false, nil); err != nil {
+				return "", fmt.Errorf("falha ao fazer bind %s: %w", evento, err)
 			}
 		}
-	}()
-
-	return nil
+		return q.Name, nil
+	}
 }
 
 func (c *Consumidor) ProcessarMensagem(msg amqp.Delivery) error {
@@ -199,11 +182,11 @@ func (c *Consumidor) processarEstoqueReservado(tx *gorm.DB, body []byte) (bool,
 	var evento struct {
 		NotaID string `json:"notaId"`
 		Itens  []struct {
-			ProdutoID  string `json:"produtoId"`
-			Quantidade int    `json:"quantidade"`
+			ProdutoID  string          `json:"produtoId"`
+			Quantidade dominio.Decimal `json:"quantidade"`
 		} `json:"itens"`
-		ProdutoID  string `json:"produtoId"`
-		Quantidade int    `json:"quantidade"`
+		ProdutoID  string          `json:"produtoId"`
+		Quantidade dominio.Decimal `json:"quantidade"`
 	}
 
 	if err := json.Unmarshal(body, &evento); err != nil {
@@ -212,8 +195,8 @@ func (c *Consumidor) processarEstoqueReservado(tx *gorm.DB, body []byte) (bool,
 
 	if len(evento.Itens) == 0 && evento.ProdutoID != "" {
 		evento.Itens = append(evento.Itens, struct {
-			ProdutoID  string `json:"produtoId"`
-			Quantidade int    `json:"quantidade"`
+			ProdutoID  string          `json:"produtoId"`
+			Quantidade dominio.Decimal `json:"quantidade"`
 		}{
 			ProdutoID:  evento.ProdutoID,
 			Quantidade: evento.Quantidade,
@@ -234,7 +217,7 @@ func (c *Consumidor) processarEstoqueReservado(tx *gorm.DB, body []byte) (bool,
 
 	var nota dominio.NotaFiscal
 	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Preload("Itens").
+		Preload("Itens").Preload("Emitente").Preload("Cliente").Preload("Pagamentos").
 		First(&nota, "id = ?", notaID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			slog.Info("Nota nao encontrada; evento sera marcado como ignorado", "notaId", notaID)
@@ -260,7 +243,30 @@ func (c *Consumidor) processarEstoqueReservado(tx *gorm.DB, body []byte) (bool,
 		return false, fmt.Errorf("falha ao fechar nota: %w", err)
 	}
 
-	if err := tx.Save(&nota).Error; err != nil {
+	cfg := nfe.CarregarConfiguracao()
+	if err := tributacao.Aplicar(tx, &nota, nfe.OperacaoDaNota(&nota, cfg)); err != nil {
+		return false, err
+	}
+
+	if err := nota.ConferirPagamentos(); err != nil {
+		slog.Warn("Pagamentos nao cobrem a nota; marcando solicitacao como falha", "notaId", notaID, "erro", err)
+		if err := c.Handlers.MarcarFalha(notaID, err.Error()); err != nil {
+			slog.Warn("Falha ao marcar solicitacao como FALHOU", "notaId", notaID, "erro", err)
+		}
+		return false, nil
+	}
+
+	modo, err := contingencia.Atual(tx)
+	if err != nil {
+		return false, fmt.Errorf("falha ao consultar contingencia: %w", err)
+	}
+	nota.AplicarContingencia(modo)
+
+	if err := nfe.AtribuirChave(&nota, cfg); err != nil {
+		slog.Warn("Nota fechada sem chave de acesso", "notaId", notaID, "erro", err)
+	}
+
+	if err := tx.Omit(clause.Associations).Save(&nota).Error; err != nil {
 		return false, fmt.Errorf("falha ao salvar nota: %w", err)
 	}
 
@@ -274,6 +280,13 @@ func (c *Consumidor) processarEstoqueReservado(tx *gorm.DB, body []byte) (bool,
 		return false, fmt.Errorf("falha ao atualizar solicitacao: %w", err)
 	}
 
+	if err := manipulador.RegistrarNotaFechada(tx, &nota); err != nil {
+		return false, err
+	}
+	if err := c.Handlers.SolicitarAutorizacao(tx, &nota); err != nil {
+		return false, err
+	}
+
 	slog.Info("Nota fechada com sucesso", "notaId", notaID)
 	return true, nil
 }
@@ -0,0 +1,92 @@
+package consumidor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"servico-faturamento/internal/email"
+	"servico-faturamento/internal/manipulador"
+	"servico-faturamento/internal/mensageria"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/gorm"
+)
+
+const (
+	filaEmail = "faturamento-email"
+	// prazoEmail cobre o registro dos envios e a entrega de um lote da nota
+	prazoEmail = 5 * time.Minute
+)
+
+// eventosEmail são os eventos que disparam envios: a autorização da nota e o
+// reenvio manual
+var eventosEmail = []string{
+	manipulador.EventoNotaAutorizada,
+	manipulador.EventoEmailSolicitado,
+}
+
+// IniciarEmail consome a autorização e os reenvios das notas e entrega o DANFE e o
+// XML aos destinatários. Envios que falham ficam para a varredura do despachante.
+func IniciarEmail(ctx context.Context, despachante *email.Despachante, conexao *mensageria.Conexao) {
+	if conexao == nil {
+		slog.Info("RabbitMQ desabilitado, pulando inicialização do envio de email")
+		return
+	}
+
+	conexao.Consumir(ctx, declararFila(filaEmail, eventosEmail...), func(msg amqp.Delivery) {
+		if err := ProcessarEmail(despachante, msg.RoutingKey, msg.Body); err != nil {
+			slog.Warn("Falha ao processar evento de email; nova tentativa", "routing", msg.RoutingKey, "erro", err.Error(), "espera", esperaRetransmissao)
+			time.Sleep(esperaRetransmissao)
+			msg.Nack(false, true)
+		} else {
+			msg.Ack(false)
+		}
+	})
+
+	slog.Info("Envio de email iniciado, aguardando eventos das notas...")
+}
+
+// ProcessarEmail registra o envio da autorização, quando é o caso, e tenta os
+// envios pendentes da nota do evento. Só devolve erro de banco: a falha de cada
+// envio fica registrada nele e é repetida pela varredura do despachante.
+func ProcessarEmail(despachante *email.Despachante, tipoEvento string, body []byte) error {
+	var evento struct {
+		NotaID string `json:"notaId"`
+	}
+	if err := json.Unmarshal(body, &evento); err != nil {
+		slog.Error("Evento de email invalido; descartando", "erro", err.Error())
+		return nil
+	}
+	notaID, err := uuid.Parse(evento.NotaID)
+	if err != nil {
+		slog.Error("Evento de email com notaId invalido; descartando", "notaId", evento.NotaID)
+		return nil
+	}
+
+	if tipoEvento == manipulador.EventoNotaAutorizada {
+		registrado, err := despachante.RegistrarAutorizacao(notaID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.Error("Nota do evento de email nao encontrada; descartando", "notaId", notaID)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("falha ao registrar envio da nota %s: %w", notaID, err)
+		}
+		if !registrado {
+			slog.Debug("Nota autorizada sem envio de email", "notaId", notaID)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), prazoEmail)
+	defer cancel()
+
+	if _, err := despachante.EnviarPendentes(ctx, &notaID); err != nil {
+		return fmt.Errorf("falha ao enviar emails da nota %s: %w", notaID, err)
+	}
+	return nil
+}
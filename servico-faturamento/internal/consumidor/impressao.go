@@ -0,0 +1,88 @@
+package consumidor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"servico-faturamento/internal/impressao"
+	"servico-faturamento/internal/manipulador"
+	"servico-faturamento/internal/mensageria"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	filaPDF = "faturamento-pdf"
+	// prazoPDF cobre a leitura da nota, a montagem do DANFE e a gravação no armazenamento
+	prazoPDF = time.Minute
+)
+
+// eventosPDF são os eventos que geram (ou regeneram) o DANFE, os mesmos da regra
+// EventBridge da lambda-pdf
+var eventosPDF = []string{
+	"Faturamento.ImpressaoSolicitada",
+	manipulador.EventoNotaFechada,
+	manipulador.EventoNotaAutorizada,
+	manipulador.EventoCartaCorrecaoRegistrada,
+}
+
+// IniciarGeradorPDF é o equivalente da lambda-pdf fora da AWS: consome os eventos
+// da nota e grava o DANFE no armazenamento, concluindo a solicitação de impressão
+func IniciarGeradorPDF(ctx context.Context, processador *impressao.Processador, conexao *mensageria.Conexao) {
+	if conexao == nil {
+		slog.Info("RabbitMQ desabilitado, pulando inicialização do gerador de PDF")
+		return
+	}
+	if processador.Armazenamento == nil {
+		slog.Warn("Armazenamento nao configurado (ARMAZENAMENTO); PDFs nao serao gerados")
+		return
+	}
+
+	conexao.Consumir(ctx, declararFila(filaPDF, eventosPDF...), func(msg amqp.Delivery) {
+		if err := ProcessarPDF(processador, msg.RoutingKey, msg.Body); err != nil {
+			slog.Warn("Falha ao gerar PDF; nova tentativa", "routing", msg.RoutingKey, "erro", err.Error(), "espera", esperaRetransmissao)
+			time.Sleep(esperaRetransmissao)
+			msg.Nack(false, true)
+		} else {
+			msg.Ack(false)
+		}
+	})
+
+	slog.Info("Gerador de PDF iniciado, aguardando eventos das notas...")
+}
+
+// ProcessarPDF gera o DANFE da nota do evento tipoEvento. Só devolve erro quando a
+// geração deve ser repetida: eventos inválidos e notas cujo DANFE não pode ser
+// montado (a solicitação já fica como FALHOU) são registrados no log e descartados.
+func ProcessarPDF(processador *impressao.Processador, tipoEvento string, body []byte) error {
+	var evento struct {
+		NotaID string `json:"notaId"`
+	}
+	if err := json.Unmarshal(body, &evento); err != nil {
+		slog.Error("Evento de impressao invalido; descartando", "erro", err.Error())
+		return nil
+	}
+	notaID, err := uuid.Parse(evento.NotaID)
+	if err != nil {
+		slog.Error("Evento de impressao com notaId invalido; descartando", "notaId", evento.NotaID)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), prazoPDF)
+	defer cancel()
+
+	err = processador.Processar(ctx, notaID, tipoEvento)
+	if errors.Is(err, impressao.ErrDANFEInviavel) {
+		slog.Error("DANFE nao pode ser gerado; descartando", "notaId", notaID, "erro", err.Error())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("falha ao gerar PDF da nota %s: %w", notaID, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package contingencia
+
+import (
+	"errors"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+
+	"gorm.io/gorm"
+)
+
+// chaveTrava serializa, entre instâncias da API e Lambdas, a troca do modo de emissão
+const chaveTrava int64 = 0x6e666563 // "nfec"
+
+// Atual devolve a contingência vigente, ou nil quando a emissão é normal. Chamado
+// na transação do fechamento, define o tpEmis da nota.
+func Atual(db *gorm.DB) (*dominio.Contingencia, error) {
+	var c dominio.Contingencia
+	err := db.Where("data_fim IS NULL").Order("data_inicio DESC").First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Ativar encerra a contingência vigente, se houver, e abre a nova a partir do seu
+// início. Com automatica e uma contingência já ativa, mantém a vigente: a detecção
+// não sobrepõe a decisão do operador nem reabre o período a cada falha.
+func Ativar(db *gorm.DB, nova *dominio.Contingencia) (*dominio.Contingencia, error) {
+	var vigente *dominio.Contingencia
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := travar(tx); err != nil {
+			return err
+		}
+		atual, err := Atual(tx)
+		if err != nil {
+			return err
+		}
+		if atual != nil && nova.Automatica {
+			vigente = atual
+			return nil
+		}
+		if err := encerrarAbertas(tx, nova.DataInicio); err != nil {
+			return err
+		}
+		if err := tx.Create(nova).Error; err != nil {
+			return err
+		}
+		vigente = nova
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vigente, nil
+}
+
+// Encerrar volta a emissão ao modo normal e devolve a contingência encerrada
+func Encerrar(db *gorm.DB, agora time.Time) (*dominio.Contingencia, error) {
+	var encerrada *dominio.Contingencia
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := travar(tx); err != nil {
+			return err
+		}
+		atual, err := Atual(tx)
+		if err != nil {
+			return err
+		}
+		if atual == nil {
+			return dominio.ErrSemContingencia
+		}
+		if err := encerrarAbertas(tx, agora); err != nil {
+			return err
+		}
+		atual.DataFim = &agora
+		encerrada = atual
+		return nil
+	})
+	return encerrada, err
+}
+
+// Historico lista as contingências mais recentes primeiro
+func Historico(db *gorm.DB, limite int) ([]dominio.Contingencia, error) {
+	var lista []dominio.Contingencia
+	err := db.Order("data_inicio DESC").Limit(limite).Find(&lista).Error
+	return lista, err
+}
+
+// Pendentes conta as notas fechadas que aguardam transmissão ao autorizador: as
+// emitidas em EPEC e as que ficaram na fila durante a indisponibilidade
+func Pendentes(db *gorm.DB) (int64, error) {
+	var total int64
+	err := db.Model(&dominio.NotaFiscal{}).
+		Where("status = ? AND chave_acesso IS NOT NULL", dominio.StatusNotaFechada).
+		Count(&total).Error
+	return total, err
+}
+
+func travar(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", chaveTrava).Error
+}
+
+func encerrarAbertas(tx *gorm.DB, fim time.Time) error {
+	return tx.Model(&dominio.Contingencia{}).
+		Where("data_fim IS NULL").
+		Update("data_fim", fim).Error
+}
@@ -0,0 +1,199 @@
+package contingencia
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/sefaz"
+
+	"gorm.io/gorm"
+)
+
+// Detector acompanha os últimos resultados das chamadas ao autorizador normal e
+// aponta indisponibilidade quando a taxa de falhas da janela passa do limite
+type Detector struct {
+	Janela         int     // quantidade de resultados considerados
+	MinimoAmostras int     // abaixo disso não há decisão
+	TaxaFalha      float64 // fração de falhas (0 a 1) que caracteriza indisponibilidade
+
+	mu         sync.Mutex
+	resultados []bool // true = falha
+}
+
+// NovoDetector usa janela de 10 chamadas, mínimo de 3 e metade de falhas
+func NovoDetector() *Detector {
+	return &Detector{Janela: 10, MinimoAmostras: 3, TaxaFalha: 0.5}
+}
+
+// Registrar acrescenta um resultado à janela, descartando o mais antigo
+func (d *Detector) Registrar(falha bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resultados = append(d.resultados, falha)
+	if excesso := len(d.resultados) - d.Janela; excesso > 0 {
+		d.resultados = d.resultados[excesso:]
+	}
+}
+
+// Indisponivel informa se a janela atual caracteriza o autorizador como fora do ar
+func (d *Detector) Indisponivel() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.resultados) < d.MinimoAmostras {
+		return false
+	}
+	falhas := 0
+	for _, falha := range d.resultados {
+		if falha {
+			falhas++
+		}
+	}
+	return float64(falhas)/float64(len(d.resultados)) >= d.TaxaFalha
+}
+
+// Limpar descarta a janela, após a troca de modo
+func (d *Detector) Limpar() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resultados = nil
+}
+
+// Monitor liga a contingência automática quando o autorizador normal falha e a
+// desliga quando o status do serviço volta a 107. Contingências ativadas pelo
+// operador só são encerradas por ele.
+type Monitor struct {
+	DB        *gorm.DB
+	Sefaz     *sefaz.Cliente
+	UF        string
+	Modo      string // modo usado na ativação automática
+	Ativa     bool   // false desliga a ativação automática; a saída continua automática
+	Intervalo time.Duration
+	Detector  *Detector
+	// AoNormalizar é chamado quando a emissão volta ao modo normal, para transmitir
+	// as notas que aguardavam o autorizador
+	AoNormalizar func(ctx context.Context)
+	Agora        func() time.Time
+}
+
+// NovoMonitor lê a configuração das variáveis de ambiente. SEFAZ_CONTINGENCIA_MODO
+// escolhe entre a SVC da UF (padrão) e EPEC; SEFAZ_CONTINGENCIA_AUTOMATICA=false
+// deixa a ativação só para o operador. Sem cliente SEFAZ devolve nil.
+func NovoMonitor(db *gorm.DB, cliente *sefaz.Cliente) *Monitor {
+	if cliente == nil {
+		return nil
+	}
+	uf := strings.ToUpper(os.Getenv("EMITENTE_UF"))
+	modo := strings.ToUpper(os.Getenv("SEFAZ_CONTINGENCIA_MODO"))
+	if modo == "" {
+		modo = dominio.ModoSVCDaUF(uf)
+	}
+	intervalo := 60
+	if n, err := strconv.Atoi(os.Getenv("SEFAZ_MONITOR_INTERVALO_SEGUNDOS")); err == nil && n > 0 {
+		intervalo = n
+	}
+	return &Monitor{
+		DB:        db,
+		Sefaz:     cliente,
+		UF:        uf,
+		Modo:      modo,
+		Ativa:     os.Getenv("SEFAZ_CONTINGENCIA_AUTOMATICA") != "false",
+		Intervalo: time.Duration(intervalo) * time.Second,
+		Detector:  NovoDetector(),
+		Agora:     time.Now,
+	}
+}
+
+// RegistrarResultado recebe o resultado de uma transmissão ao autorizador normal.
+// Só a indisponibilidade conta como falha; rejeições são respostas do serviço.
+func (m *Monitor) RegistrarResultado(err error) {
+	switch {
+	case err == nil:
+		m.Detector.Registrar(false)
+	case errors.Is(err, sefaz.ErrSefazIndisponivel):
+		m.Detector.Registrar(true)
+		m.avaliar(err.Error())
+	}
+}
+
+// Verificar consulta o status do autorizador normal. Fora do ar, conta como falha
+// para a ativação; em operação, encerra a contingência automática vigente.
+func (m *Monitor) Verificar(ctx context.Context) {
+	ret, err := m.Sefaz.StatusServico(ctx)
+	if err == nil && ret.CStat != sefaz.CStatServicoEmOperacao {
+		err = fmt.Errorf("%w: status %d %s", sefaz.ErrSefazIndisponivel, ret.CStat, ret.XMotivo)
+	}
+	if err != nil {
+		m.RegistrarResultado(err)
+		return
+	}
+	m.Detector.Registrar(false)
+
+	atual, err := Atual(m.DB)
+	if err != nil {
+		slog.Error("Falha ao consultar contingencia vigente", "erro", err)
+		return
+	}
+	if atual == nil || !atual.Automatica {
+		return
+	}
+	if _, err := Encerrar(m.DB, m.Agora()); err != nil && !errors.Is(err, dominio.ErrSemContingencia) {
+		slog.Error("Falha ao encerrar contingencia automatica", "erro", err)
+		return
+	}
+	slog.Info("Autorizador normal em operacao; contingencia encerrada", "modo", atual.Modo, "inicio", atual.DataInicio)
+	if m.AoNormalizar != nil {
+		m.AoNormalizar(ctx)
+	}
+}
+
+// Iniciar verifica o autorizador a cada Intervalo até o contexto ser cancelado
+func (m *Monitor) Iniciar(ctx context.Context) {
+	slog.Info("Monitor da SEFAZ iniciado", "intervalo", m.Intervalo, "modoContingencia", m.Modo, "automatica", m.Ativa)
+	go func() {
+		ticker := time.NewTicker(m.Intervalo)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				verificacao, cancel := context.WithTimeout(ctx, m.Sefaz.Configuracao().Timeout)
+				m.Verificar(verificacao)
+				cancel()
+			}
+		}
+	}()
+}
+
+// avaliar ativa a contingência automática quando o detector aponta indisponibilidade
+func (m *Monitor) avaliar(motivo string) {
+	if !m.Ativa || !m.Detector.Indisponivel() {
+		return
+	}
+	justificativa := "Ativacao automatica: autorizador da UF indisponivel (" + motivo + ")"
+	if runas := []rune(justificativa); len(runas) > dominio.JustificativaMaxima {
+		justificativa = string(runas[:dominio.JustificativaMaxima-1]) + ")"
+	}
+	nova, err := dominio.NovaContingencia(m.Modo, justificativa, m.UF, true, m.Agora())
+	if err != nil {
+		slog.Error("Contingencia automatica mal configurada (SEFAZ_CONTINGENCIA_MODO)", "modo", m.Modo, "uf", m.UF, "erro", err)
+		return
+	}
+	vigente, err := Ativar(m.DB, &nova)
+	if err != nil {
+		slog.Error("Falha ao ativar contingencia automatica", "erro", err)
+		return
+	}
+	m.Detector.Limpar()
+	if vigente == &nova {
+		slog.Warn("Autorizador da UF indisponivel; contingencia ativada", "modo", vigente.Modo, "motivo", motivo)
+	}
+}
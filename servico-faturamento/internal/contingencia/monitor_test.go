@@ -0,0 +1,41 @@
+package contingencia_test
+
+import (
+	"testing"
+
+	"servico-faturamento/internal/contingencia"
+)
+
+func TestDetector(t *testing.T) {
+	t.Run("deve aguardar o minimo de amostras", func(t *testing.T) {
+		d := contingencia.NovoDetector()
+		d.Registrar(true)
+		d.Registrar(true)
+		if d.Indisponivel() {
+			t.Error("esperava sem decisao com duas amostras")
+		}
+		d.Registrar(true)
+		if !d.Indisponivel() {
+			t.Error("esperava indisponibilidade com tres falhas")
+		}
+	})
+
+	t.Run("deve considerar apenas a janela mais recente", func(t *testing.T) {
+		d := &contingencia.Detector{Janela: 4, MinimoAmostras: 2, TaxaFalha: 0.5}
+		for _, falha := range []bool{true, true, true, false, false, false} {
+			d.Registrar(falha)
+		}
+		if d.Indisponivel() {
+			t.Error("esperava disponivel com uma falha nas quatro ultimas chamadas")
+		}
+		d.Registrar(true)
+		d.Registrar(true)
+		if !d.Indisponivel() {
+			t.Error("esperava indisponivel com metade de falhas na janela")
+		}
+		d.Limpar()
+		if d.Indisponivel() {
+			t.Error("esperava janela vazia apos Limpar")
+		}
+	})
+}
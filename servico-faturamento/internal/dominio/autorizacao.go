@@ -0,0 +1,151 @@
+package dominio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Códigos de situação (cStat) do protocolo da NF-e usados na classificação do retorno
+const (
+	CStatAutorizada          = 100
+	CStatAutorizadaForaPrazo = 150
+	CStatDuplicidade         = 204
+)
+
+// cStatDenegacao são os motivos de uso denegado: a numeração é consumida e a nota
+// não pode ser cancelada nem reenviada
+var cStatDenegacao = map[int]bool{110: true, 301: true, 302: true, 303: true}
+
+var (
+	// ErrNotaNaoAutorizavel indica retorno da SEFAZ para nota que não aguarda autorização
+	ErrNotaNaoAutorizavel = errors.New("apenas nota fechada aguarda autorizacao")
+	// ErrNotaNaoReabrivel indica nota que não foi rejeitada ou cuja chave já tem EPEC registrado
+	ErrNotaNaoReabrivel = errors.New("apenas nota rejeitada pela SEFAZ, sem EPEC registrado, pode ser reaberta")
+)
+
+// ResultadoAutorizacao é o protocolo (protNFe) devolvido pela SEFAZ para a nota
+type ResultadoAutorizacao struct {
+	CStat           int
+	XMotivo         string
+	Protocolo       string
+	DataRecebimento time.Time
+	// XML é o nfeProc (NF-e assinada com o protocolo) nas notas autorizadas ou denegadas
+	XML string
+}
+
+// StatusDoRetorno classifica o cStat do protocolo em AUTORIZADA, DENEGADA ou REJEITADA
+func StatusDoRetorno(cStat int) string {
+	switch {
+	case cStat == CStatAutorizada || cStat == CStatAutorizadaForaPrazo:
+		return StatusNotaAutorizada
+	case cStatDenegacao[cStat]:
+		return StatusNotaDenegada
+	default:
+		return StatusNotaRejeitada
+	}
+}
+
+// Autorizada informa se a SEFAZ autorizou a nota e o protocolo foi gravado: só
+// então a nota aceita carta de correção, que a SEFAZ rejeita para nota que não
+// autorizou
+func (n *NotaFiscal) Autorizada() bool {
+	return n.Status == StatusNotaAutorizada && n.ProtocoloAutorizacao != nil
+}
+
+// AutorizacaoConflitante informa se a nota cancelada no banco recebeu protocolo
+// de autorização: ela vale na SEFAZ até o evento de cancelamento
+func (n *NotaFiscal) AutorizacaoConflitante() bool {
+	return n.Status == StatusNotaCancelada && n.ProtocoloAutorizacao != nil
+}
+
+// RegistrarAutorizacao grava o protocolo da SEFAZ na nota fechada e devolve o novo
+// status. O retorno é definitivo, então a nota deixa de aguardar o autorizador.
+func (n *NotaFiscal) RegistrarAutorizacao(r ResultadoAutorizacao) (string, error) {
+	if n.Status != StatusNotaFechada {
+		return "", fmt.Errorf("%w: status %s", ErrNotaNaoAutorizavel, n.Status)
+	}
+
+	status := StatusDoRetorno(r.CStat)
+	n.Status = status
+	n.AutorizacaoPendente = false
+	n.registrarRetorno(r)
+	return status, nil
+}
+
+// RegistrarAutorizacaoConflitante guarda o protocolo de autorização que chegou
+// para a nota já cancelada no banco. O protocolo não pode ser descartado: a nota
+// vale na SEFAZ e só deixa de valer com o evento 110111. O status continua
+// CANCELADA; devolve false quando o retorno não é uma autorização ou a nota não
+// está cancelada.
+func (n *NotaFiscal) RegistrarAutorizacaoConflitante(r ResultadoAutorizacao) bool {
+	if n.Status != StatusNotaCancelada || n.ProtocoloAutorizacao != nil || StatusDoRetorno(r.CStat) != StatusNotaAutorizada {
+		return false
+	}
+	n.AutorizacaoPendente = false
+	n.registrarRetorno(r)
+	return true
+}
+
+// PrepararReenvio devolve a nota rejeitada para FECHADA, com a mesma chave, para
+// ser transmitida de novo depois de corrigido o cadastro do emitente ou do
+// destinatário: o XML é gerado outra vez na transmissão. A rejeição não consome o
+// número na SEFAZ.
+func (n *NotaFiscal) PrepararReenvio() error {
+	if n.Status != StatusNotaRejeitada {
+		return fmt.Errorf("%w: status %s", ErrNotaNaoAutorizavel, n.Status)
+	}
+	n.Status = StatusNotaFechada
+	n.CStat = nil
+	n.XMotivo = nil
+	// O reenvio monta o documento de novo, com o cadastro corrigido
+	n.XMLFechamento = nil
+	return nil
+}
+
+// Reabrir devolve a nota rejeitada para ABERTA, para corrigir itens ou pagamentos.
+// O novo fechamento recalcula os tributos e gera outra chave com o mesmo número.
+// A nota com EPEC registrado fica presa à chave do evento e só pode ser reenviada.
+func (n *NotaFiscal) Reabrir() error {
+	if n.Status != StatusNotaRejeitada || n.ProtocoloEPEC != nil {
+		return fmt.Errorf("%w: status %s", ErrNotaNaoReabrivel, n.Status)
+	}
+	n.Status = StatusNotaAberta
+	n.DataFechada = nil
+	n.ChaveAcesso = nil
+	n.XMLFechamento = nil
+	n.CStat = nil
+	n.XMotivo = nil
+	n.TipoEmissao = TipoEmissaoNormal
+	n.DataContingencia = nil
+	n.JustificativaContingencia = nil
+	return nil
+}
+
+// registrarRetorno copia o protocolo para a nota; a rejeição só grava o cStat e o motivo
+func (n *NotaFiscal) registrarRetorno(r ResultadoAutorizacao) {
+	motivo := strings.TrimSpace(r.XMotivo)
+	if runas := []rune(motivo); len(runas) > 255 {
+		motivo = string(runas[:255])
+	}
+	cStat := r.CStat
+	n.CStat = &cStat
+	n.XMotivo = &motivo
+
+	if StatusDoRetorno(r.CStat) == StatusNotaRejeitada {
+		return
+	}
+	if r.Protocolo != "" {
+		protocolo := r.Protocolo
+		n.ProtocoloAutorizacao = &protocolo
+	}
+	if !r.DataRecebimento.IsZero() {
+		data := r.DataRecebimento
+		n.DataAutorizacao = &data
+	}
+	if r.XML != "" {
+		xml := r.XML
+		n.XMLAutorizado = &xml
+	}
+}
@@ -0,0 +1,354 @@
+package dominio_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func TestStatusDoRetorno(t *testing.T) {
+	casos := []struct {
+		cStat    int
+		esperado string
+	}{
+		{100, dominio.StatusNotaAutorizada},
+		{150, dominio.StatusNotaAutorizada},
+		{110, dominio.StatusNotaDenegada},
+		{301, dominio.StatusNotaDenegada},
+		{302, dominio.StatusNotaDenegada},
+		{303, dominio.StatusNotaDenegada},
+		{204, dominio.StatusNotaRejeitada},
+		{225, dominio.StatusNotaRejeitada},
+		{539, dominio.StatusNotaRejeitada},
+	}
+	for _, c := range casos {
+		if obtido := dominio.StatusDoRetorno(c.cStat); obtido != c.esperado {
+			t.Errorf("cStat %d: esperava %s, obteve %s", c.cStat, c.esperado, obtido)
+		}
+	}
+}
+
+func TestNotaFiscal_RegistrarAutorizacao(t *testing.T) {
+	fechada := time.Date(2026, 3, 10, 9, 0, 0, 0, dominio.FusoBrasilia)
+	recebimento := fechada.Add(5 * time.Minute)
+
+	t.Run("deve registrar protocolo e nfeProc da nota autorizada", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		status, err := nota.RegistrarAutorizacao(dominio.ResultadoAutorizacao{
+			CStat: 100, XMotivo: "Autorizado o uso da NF-e", Protocolo: "135260000000001",
+			DataRecebimento: recebimento, XML: "<nfeProc/>",
+		})
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if status != dominio.StatusNotaAutorizada || nota.Status != dominio.StatusNotaAutorizada {
+			t.Errorf("esperava AUTORIZADA, obteve %s / %s", status, nota.Status)
+		}
+		if nota.ProtocoloAutorizacao == nil || *nota.ProtocoloAutorizacao != "135260000000001" {
+			t.Errorf("protocolo inesperado: %v", nota.ProtocoloAutorizacao)
+		}
+		if nota.DataAutorizacao == nil || !nota.DataAutorizacao.Equal(recebimento) {
+			t.Errorf("esperava DataAutorizacao %v, obteve %v", recebimento, nota.DataAutorizacao)
+		}
+		if nota.XMLAutorizado == nil || *nota.XMLAutorizado != "<nfeProc/>" {
+			t.Errorf("esperava nfeProc gravado, obteve %v", nota.XMLAutorizado)
+		}
+		if nota.CStat == nil || *nota.CStat != 100 {
+			t.Errorf("esperava cStat 100, obteve %v", nota.CStat)
+		}
+	})
+
+	t.Run("deve registrar denegacao com protocolo", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		status, err := nota.RegistrarAutorizacao(dominio.ResultadoAutorizacao{
+			CStat: 302, XMotivo: "Uso Denegado: Irregularidade fiscal do destinatario", Protocolo: "135260000000002",
+			DataRecebimento: recebimento, XML: "<nfeProc/>",
+		})
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if status != dominio.StatusNotaDenegada {
+			t.Errorf("esperava DENEGADA, obteve %s", status)
+		}
+		if nota.ProtocoloAutorizacao == nil {
+			t.Error("esperava protocolo da denegacao gravado")
+		}
+	})
+
+	t.Run("deve registrar rejeicao sem protocolo nem XML", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		status, err := nota.RegistrarAutorizacao(dominio.ResultadoAutorizacao{
+			CStat: 539, XMotivo: "  Rejeicao: Duplicidade de NF-e com diferenca na Chave de Acesso  ",
+			DataRecebimento: recebimento,
+		})
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if status != dominio.StatusNotaRejeitada {
+			t.Errorf("esperava REJEITADA, obteve %s", status)
+		}
+		if nota.ProtocoloAutorizacao != nil || nota.DataAutorizacao != nil || nota.XMLAutorizado != nil {
+			t.Error("rejeicao nao deveria gravar protocolo, data nem XML")
+		}
+		if nota.XMotivo == nil || *nota.XMotivo != "Rejeicao: Duplicidade de NF-e com diferenca na Chave de Acesso" {
+			t.Errorf("esperava xMotivo sem espacos nas pontas, obteve %v", nota.XMotivo)
+		}
+	})
+
+	t.Run("deve liberar a nota pendente com o retorno definitivo", func(t *testing.T) {
+		for _, cStat := range []int{100, 302, 539} {
+			nota := notaFechadaEm(fechada)
+			nota.AutorizacaoPendente = true
+			if _, err := nota.RegistrarAutorizacao(dominio.ResultadoAutorizacao{CStat: cStat}); err != nil {
+				t.Fatalf("cStat %d: esperava nil, obteve erro: %v", cStat, err)
+			}
+			if nota.AutorizacaoPendente {
+				t.Errorf("cStat %d: nota continua pendente", cStat)
+			}
+		}
+	})
+
+	t.Run("deve truncar xMotivo em 255 caracteres", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		if _, err := nota.RegistrarAutorizacao(dominio.ResultadoAutorizacao{CStat: 999, XMotivo: strings.Repeat("é", 300)}); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if n := len([]rune(*nota.XMotivo)); n != 255 {
+			t.Errorf("esperava 255 caracteres, obteve %d", n)
+		}
+	})
+
+	t.Run("deve exigir nota fechada", func(t *testing.T) {
+		for _, status := range []string{dominio.StatusNotaAberta, dominio.StatusNotaAutorizada, dominio.StatusNotaCancelada} {
+			nota := notaFechadaEm(fechada)
+			nota.Status = status
+			_, err := nota.RegistrarAutorizacao(dominio.ResultadoAutorizacao{CStat: 100})
+			if !errors.Is(err, dominio.ErrNotaNaoAutorizavel) {
+				t.Errorf("status %s: esperava ErrNotaNaoAutorizavel, obteve: %v", status, err)
+			}
+		}
+	})
+}
+
+func TestNotaFiscal_CorrecaoDaRejeicao(t *testing.T) {
+	fechada := time.Date(2026, 3, 10, 9, 0, 0, 0, dominio.FusoBrasilia)
+	chave := "35260311222333000181550010000000101000000105"
+
+	notaRejeitada := func() *dominio.NotaFiscal {
+		nota := notaFechadaEm(fechada)
+		nota.ChaveAcesso = &chave
+		documento := "<NFe/>"
+		nota.XMLFechamento = &documento
+		if _, err := nota.RegistrarAutorizacao(dominio.ResultadoAutorizacao{CStat: 225, XMotivo: "Rejeicao: Falha no Schema XML"}); err != nil {
+			t.Fatalf("RegistrarAutorizacao() erro = %v", err)
+		}
+		return nota
+	}
+
+	t.Run("deve devolver a nota rejeitada para FECHADA com a mesma chave", func(t *testing.T) {
+		nota := notaRejeitada()
+		if err := nota.PrepararReenvio(); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if nota.Status != dominio.StatusNotaFechada || nota.ChaveAcesso == nil || *nota.ChaveAcesso != chave {
+			t.Errorf("esperava FECHADA com a chave original, obteve %s %v", nota.Status, nota.ChaveAcesso)
+		}
+		if nota.CStat != nil || nota.XMotivo != nil {
+			t.Errorf("retorno anterior mantido: %v %v", nota.CStat, nota.XMotivo)
+		}
+		if nota.XMLFechamento != nil {
+			t.Error("o reenvio deve montar o documento de novo, com o cadastro corrigido")
+		}
+	})
+
+	t.Run("deve reabrir a nota rejeitada sem chave nem fechamento", func(t *testing.T) {
+		nota := notaRejeitada()
+		nota.TipoEmissao = dominio.TipoEmissaoSVCAN
+		if err := nota.Reabrir(); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if nota.Status != dominio.StatusNotaAberta || nota.ChaveAcesso != nil || nota.DataFechada != nil || nota.CStat != nil || nota.XMLFechamento != nil {
+			t.Errorf("nota reaberta inconsistente: %+v", nota)
+		}
+		if nota.Numero != "10" || nota.TipoEmissao != dominio.TipoEmissaoNormal {
+			t.Errorf("esperava manter o numero com emissao normal, obteve %s %s", nota.Numero, nota.TipoEmissao)
+		}
+		if err := nota.Fechar(); err == nil {
+			t.Error("nota reaberta sem itens nao deveria fechar")
+		}
+	})
+
+	t.Run("deve recusar reabrir nota com EPEC ou fora de REJEITADA", func(t *testing.T) {
+		comEPEC := notaRejeitada()
+		protocolo := "891250000000001"
+		comEPEC.ProtocoloEPEC = &protocolo
+		if err := comEPEC.Reabrir(); !errors.Is(err, dominio.ErrNotaNaoReabrivel) {
+			t.Errorf("esperava ErrNotaNaoReabrivel com EPEC, obteve: %v", err)
+		}
+		for _, status := range []string{dominio.StatusNotaFechada, dominio.StatusNotaAutorizada, dominio.StatusNotaDenegada} {
+			nota := notaFechadaEm(fechada)
+			nota.Status = status
+			if err := nota.Reabrir(); !errors.Is(err, dominio.ErrNotaNaoReabrivel) {
+				t.Errorf("status %s: esperava ErrNotaNaoReabrivel, obteve: %v", status, err)
+			}
+			if err := nota.PrepararReenvio(); !errors.Is(err, dominio.ErrNotaNaoAutorizavel) {
+				t.Errorf("status %s: esperava ErrNotaNaoAutorizavel, obteve: %v", status, err)
+			}
+		}
+	})
+}
+
+func TestNotaFiscal_RegistrarAutorizacaoConflitante(t *testing.T) {
+	fechada := time.Date(2026, 3, 10, 9, 0, 0, 0, dominio.FusoBrasilia)
+	autorizacao := dominio.ResultadoAutorizacao{
+		CStat: 100, XMotivo: "Autorizado o uso da NF-e", Protocolo: "135260000000001",
+		DataRecebimento: fechada.Add(time.Hour), XML: "<nfeProc/>",
+	}
+
+	notaCancelada := func() *dominio.NotaFiscal {
+		nota := notaFechadaEm(fechada)
+		if err := nota.Cancelar("Pedido cancelado pelo cliente", fechada.Add(time.Minute), dominio.PrazoCancelamentoPadrao); err != nil {
+			t.Fatalf("Cancelar() erro = %v", err)
+		}
+		return nota
+	}
+
+	t.Run("deve guardar o protocolo da nota cancelada no banco", func(t *testing.T) {
+		nota := notaCancelada()
+		if !nota.RegistrarAutorizacaoConflitante(autorizacao) {
+			t.Fatal("esperava conflito registrado")
+		}
+		if nota.Status != dominio.StatusNotaCancelada {
+			t.Errorf("esperava continuar CANCELADA, obteve %s", nota.Status)
+		}
+		if nota.ProtocoloAutorizacao == nil || *nota.ProtocoloAutorizacao != autorizacao.Protocolo || nota.XMLAutorizado == nil {
+			t.Errorf("protocolo ou XML nao gravados: %v / %v", nota.ProtocoloAutorizacao, nota.XMLAutorizado)
+		}
+		if !nota.AutorizacaoConflitante() {
+			t.Error("esperava nota marcada como autorizacao conflitante")
+		}
+	})
+
+	t.Run("deve ignorar rejeicao, nota nao cancelada e protocolo ja gravado", func(t *testing.T) {
+		rejeicao := dominio.ResultadoAutorizacao{CStat: 539, XMotivo: "Rejeicao"}
+		if nota := notaCancelada(); nota.RegistrarAutorizacaoConflitante(rejeicao) || nota.CStat != nil {
+			t.Error("rejeicao nao deveria alterar a nota cancelada")
+		}
+		if nota := notaFechadaEm(fechada); nota.RegistrarAutorizacaoConflitante(autorizacao) {
+			t.Error("nota fechada nao e conflito")
+		}
+		nota := notaCancelada()
+		nota.RegistrarAutorizacaoConflitante(autorizacao)
+		outra := autorizacao
+		outra.Protocolo = "135260000000009"
+		if nota.RegistrarAutorizacaoConflitante(outra) || *nota.ProtocoloAutorizacao != autorizacao.Protocolo {
+			t.Error("segunda entrega nao deveria trocar o protocolo")
+		}
+	})
+}
+
+func TestNotaFiscal_CancelarAutorizada(t *testing.T) {
+	fechada := time.Date(2026, 3, 10, 9, 0, 0, 0, dominio.FusoBrasilia)
+	autorizada := fechada.Add(6 * time.Hour)
+
+	notaAutorizada := func() *dominio.NotaFiscal {
+		nota := notaFechadaEm(fechada)
+		nota.Status = dominio.StatusNotaAutorizada
+		nota.DataAutorizacao = &autorizada
+		return nota
+	}
+
+	t.Run("deve recusar cancelamento local de nota autorizada", func(t *testing.T) {
+		nota := notaAutorizada()
+		err := nota.Cancelar("Pedido cancelado pelo cliente", autorizada.Add(time.Hour), 24*time.Hour)
+		if !errors.Is(err, dominio.ErrCancelamentoExigeEvento) || !errors.Is(err, dominio.ErrNotaNaoCancelavel) {
+			t.Fatalf("esperava ErrCancelamentoExigeEvento, obteve: %v", err)
+		}
+		if nota.Status != dominio.StatusNotaAutorizada || nota.DataCancelamento != nil {
+			t.Errorf("nota autorizada alterada: status %s, cancelamento %v", nota.Status, nota.DataCancelamento)
+		}
+	})
+
+	t.Run("deve preparar o evento 110111 da nota autorizada dentro do prazo", func(t *testing.T) {
+		nota := notaAutorizada()
+		chave := "35250311222333000181550010000000101000000105"
+		protocolo := "135260000000001"
+		nota.ChaveAcesso, nota.ProtocoloAutorizacao = &chave, &protocolo
+		if !nota.ExigeEventoCancelamento() {
+			t.Fatal("nota autorizada deveria exigir o evento de cancelamento")
+		}
+
+		justificativa, err := nota.PrepararCancelamento("  Pedido cancelado pelo cliente ", autorizada.Add(time.Hour), 24*time.Hour)
+		if err != nil || justificativa != "Pedido cancelado pelo cliente" {
+			t.Fatalf("esperava justificativa normalizada, obteve %q %v", justificativa, err)
+		}
+		if _, err := nota.PrepararCancelamento("Pedido cancelado pelo cliente", autorizada.Add(25*time.Hour), 24*time.Hour); !errors.Is(err, dominio.ErrPrazoCancelamentoExpirado) {
+			t.Errorf("esperava ErrPrazoCancelamentoExpirado, obteve: %v", err)
+		}
+		if nota.Status != dominio.StatusNotaAutorizada {
+			t.Errorf("preparar nao deveria alterar a nota, status %s", nota.Status)
+		}
+	})
+
+	t.Run("deve cancelar a nota autorizada so com o protocolo do evento", func(t *testing.T) {
+		nota := notaAutorizada()
+		protocolo := "135260000000001"
+		nota.ProtocoloAutorizacao = &protocolo
+		registro := autorizada.Add(time.Hour)
+
+		if err := nota.RegistrarCancelamento("Pedido cancelado pelo cliente", "", registro); !errors.Is(err, dominio.ErrNotaNaoCancelavel) {
+			t.Fatalf("esperava recusa sem protocolo, obteve: %v", err)
+		}
+		if err := nota.RegistrarCancelamento("Pedido cancelado pelo cliente", "135260000000002", registro); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if nota.Status != dominio.StatusNotaCancelada || nota.ProtocoloCancelamento == nil || *nota.ProtocoloCancelamento != "135260000000002" {
+			t.Errorf("cancelamento nao registrado: status %s, protocolo %v", nota.Status, nota.ProtocoloCancelamento)
+		}
+		if nota.DataCancelamento == nil || !nota.DataCancelamento.Equal(registro) {
+			t.Errorf("esperava data do registro, obteve %v", nota.DataCancelamento)
+		}
+		if nota.ExigeEventoCancelamento() {
+			t.Error("nota com protocolo de cancelamento nao exige novo evento")
+		}
+	})
+
+	t.Run("deve exigir o evento da nota cancelada no banco e autorizada depois", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		if err := nota.Cancelar("Pedido cancelado pelo cliente", fechada.Add(time.Minute), 24*time.Hour); err != nil {
+			t.Fatalf("Cancelar() erro = %v", err)
+		}
+		cancelada := *nota.DataCancelamento
+		nota.RegistrarAutorizacaoConflitante(dominio.ResultadoAutorizacao{CStat: 100, Protocolo: "135260000000001", DataRecebimento: autorizada})
+		if !nota.ExigeEventoCancelamento() {
+			t.Fatal("autorizacao conflitante deveria exigir o evento de cancelamento")
+		}
+
+		// Fora do prazo local a SEFAZ decide: a nota ja estava cancelada no banco
+		chave := "35250311222333000181550010000000101000000105"
+		nota.ChaveAcesso = &chave
+		if _, err := nota.PrepararCancelamento("Pedido cancelado pelo cliente", autorizada.Add(48*time.Hour), 24*time.Hour); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if err := nota.RegistrarCancelamento("Outra justificativa qualquer", "135260000000002", autorizada.Add(48*time.Hour)); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if !nota.DataCancelamento.Equal(cancelada) || *nota.JustificativaCancelamento != "Pedido cancelado pelo cliente" {
+			t.Errorf("esperava manter o cancelamento do banco, obteve %v %q", nota.DataCancelamento, *nota.JustificativaCancelamento)
+		}
+	})
+
+	t.Run("deve rejeitar cancelamento de nota rejeitada ou denegada", func(t *testing.T) {
+		for _, status := range []string{dominio.StatusNotaRejeitada, dominio.StatusNotaDenegada} {
+			nota := notaAutorizada()
+			nota.Status = status
+			err := nota.Cancelar("Pedido cancelado pelo cliente", autorizada, 24*time.Hour)
+			if !errors.Is(err, dominio.ErrNotaNaoCancelavel) {
+				t.Errorf("status %s: esperava ErrNotaNaoCancelavel, obteve: %v", status, err)
+			}
+		}
+	})
+}
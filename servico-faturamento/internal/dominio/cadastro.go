@@ -0,0 +1,267 @@
+package dominio
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Regimes tributários do emitente (CRT)
+const (
+	CRTSimplesNacional      = "1"
+	CRTSimplesExcessoSublim = "2"
+	CRTRegimeNormal         = "3"
+	CRTMicroempreendedorMEI = "4"
+)
+
+// Indicador da IE do destinatário (indIEDest)
+const (
+	IndicadorIEContribuinte    = "1"
+	IndicadorIEIsento          = "2"
+	IndicadorIENaoContribuinte = "9"
+)
+
+// ErrosCadastro lista os campos inválidos de um emitente ou cliente
+type ErrosCadastro []string
+
+func (e ErrosCadastro) Error() string {
+	return "cadastro invalido: " + strings.Join(e, "; ")
+}
+
+func (e *ErrosCadastro) add(formato string, args ...interface{}) {
+	*e = append(*e, fmt.Sprintf(formato, args...))
+}
+
+func (e *ErrosCadastro) texto(campo, valor string, min, max int, obrigatorio bool) {
+	n := utf8.RuneCountInString(valor)
+	if n == 0 && !obrigatorio {
+		return
+	}
+	if n < min || n > max {
+		e.add("%s deve ter entre %d e %d caracteres", campo, min, max)
+	}
+}
+
+// Endereco segue o TEndereco da NF-e; apenas endereços no Brasil são aceitos
+type Endereco struct {
+	Logradouro      string `gorm:"size:60;not null" json:"logradouro"`
+	Numero          string `gorm:"column:numero_endereco;size:60;not null" json:"numero"`
+	Complemento     string `gorm:"size:60" json:"complemento,omitempty"`
+	Bairro          string `gorm:"size:60;not null" json:"bairro"`
+	CodigoMunicipio string `gorm:"size:7;not null" json:"codigoMunicipio"` // código IBGE
+	Municipio       string `gorm:"size:60;not null" json:"municipio"`
+	UF              string `gorm:"column:uf;size:2;not null" json:"uf"`
+	CEP             string `gorm:"column:cep;size:8" json:"cep,omitempty"`
+	Telefone        string `gorm:"size:14" json:"telefone,omitempty"`
+}
+
+func (e *Endereco) normalizar() {
+	e.Logradouro = strings.TrimSpace(e.Logradouro)
+	e.Numero = strings.TrimSpace(e.Numero)
+	e.Complemento = strings.TrimSpace(e.Complemento)
+	e.Bairro = strings.TrimSpace(e.Bairro)
+	e.CodigoMunicipio = SomenteDigitos(e.CodigoMunicipio)
+	e.Municipio = strings.TrimSpace(e.Municipio)
+	e.UF = strings.ToUpper(strings.TrimSpace(e.UF))
+	e.CEP = SomenteDigitos(e.CEP)
+	e.Telefone = SomenteDigitos(e.Telefone)
+}
+
+func (e Endereco) validar(erros *ErrosCadastro) {
+	erros.texto("logradouro", e.Logradouro, 2, 60, true)
+	erros.texto("numero", e.Numero, 1, 60, true)
+	erros.texto("complemento", e.Complemento, 1, 60, false)
+	erros.texto("bairro", e.Bairro, 2, 60, true)
+	erros.texto("municipio", e.Municipio, 2, 60, true)
+
+	cUF, ok := CodigoUF(e.UF)
+	if !ok {
+		erros.add("uf %q inexistente", e.UF)
+	}
+	// Os dois primeiros dígitos do código IBGE do município são o código da UF
+	if len(e.CodigoMunicipio) != 7 {
+		erros.add("codigoMunicipio deve ter os 7 digitos do codigo IBGE")
+	} else if ok && e.CodigoMunicipio[:2] != cUF {
+		erros.add("codigoMunicipio %s nao pertence a UF %s", e.CodigoMunicipio, e.UF)
+	}
+	if e.CEP != "" && len(e.CEP) != 8 {
+		erros.add("cep deve ter 8 digitos")
+	}
+	if e.Telefone != "" && (len(e.Telefone) < 6 || len(e.Telefone) > 14) {
+		erros.add("telefone deve ter entre 6 e 14 digitos")
+	}
+}
+
+// Emitente é a empresa que emite as notas; o CNPJ identifica as séries de numeração
+// e por isso não pode ser alterado depois do cadastro
+type Emitente struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	CNPJ            string    `gorm:"column:cnpj;size:14;uniqueIndex;not null" json:"cnpj"`
+	RazaoSocial     string    `gorm:"size:60;not null" json:"razaoSocial"`
+	NomeFantasia    string    `gorm:"size:60" json:"nomeFantasia,omitempty"`
+	IE              string    `gorm:"column:ie;size:14;not null" json:"ie"`
+	CRT             string    `gorm:"column:crt;size:1;not null" json:"crt"`
+	Endereco        Endereco  `gorm:"embedded" json:"endereco"`
+	DataCriacao     time.Time `gorm:"not null" json:"dataCriacao"`
+	DataAtualizacao time.Time `gorm:"not null" json:"dataAtualizacao"`
+}
+
+func (e *Emitente) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.DataCriacao.IsZero() {
+		e.DataCriacao = time.Now()
+	}
+	return nil
+}
+
+func (e *Emitente) BeforeSave(tx *gorm.DB) error {
+	e.DataAtualizacao = time.Now()
+	return nil
+}
+
+// Validar normaliza e confere CNPJ, IE conforme a UF, regime e endereço
+func (e *Emitente) Validar() error {
+	e.CNPJ = SomenteDigitos(e.CNPJ)
+	e.RazaoSocial = strings.TrimSpace(e.RazaoSocial)
+	e.NomeFantasia = strings.TrimSpace(e.NomeFantasia)
+	e.IE = normalizarIE(e.IE)
+	e.Endereco.normalizar()
+
+	var erros ErrosCadastro
+	if !ValidarCNPJ(e.CNPJ) {
+		erros.add("cnpj invalido")
+	}
+	erros.texto("razaoSocial", e.RazaoSocial, 2, 60, true)
+	erros.texto("nomeFantasia", e.NomeFantasia, 1, 60, false)
+	switch {
+	case e.IE == "":
+		erros.add("ie obrigatoria para o emitente")
+	case e.IE != IEIsento && !ValidarIE(e.Endereco.UF, e.IE):
+		erros.add("ie %s invalida para a UF %s", e.IE, e.Endereco.UF)
+	}
+	switch e.CRT {
+	case CRTSimplesNacional, CRTSimplesExcessoSublim, CRTRegimeNormal, CRTMicroempreendedorMEI:
+	default:
+		erros.add("crt deve ser 1, 2, 3 ou 4")
+	}
+	e.Endereco.validar(&erros)
+
+	if len(erros) > 0 {
+		return erros
+	}
+	return nil
+}
+
+func (Emitente) TableName() string {
+	return "emitentes"
+}
+
+// Cliente é o destinatário da nota, pessoa jurídica (CNPJ) ou física (CPF)
+type Cliente struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	Documento       string    `gorm:"size:14;uniqueIndex;not null" json:"documento"` // CNPJ ou CPF
+	Nome            string    `gorm:"size:60;not null" json:"nome"`
+	IndicadorIE     string    `gorm:"column:indicador_ie;size:1;not null" json:"indicadorIE"`
+	IE              string    `gorm:"column:ie;size:14" json:"ie,omitempty"`
+	Email           string    `gorm:"size:60" json:"email,omitempty"`
+	Endereco        Endereco  `gorm:"embedded" json:"endereco"`
+	DataCriacao     time.Time `gorm:"not null" json:"dataCriacao"`
+	DataAtualizacao time.Time `gorm:"not null" json:"dataAtualizacao"`
+}
+
+func (c *Cliente) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	if c.DataCriacao.IsZero() {
+		c.DataCriacao = time.Now()
+	}
+	return nil
+}
+
+func (c *Cliente) BeforeSave(tx *gorm.DB) error {
+	c.DataAtualizacao = time.Now()
+	return nil
+}
+
+// PessoaJuridica indica se o documento do cliente é um CNPJ
+func (c *Cliente) PessoaJuridica() bool {
+	return len(c.Documento) == 14
+}
+
+// Validar normaliza e confere documento, indicador e IE conforme a UF, e endereço
+func (c *Cliente) Validar() error {
+	c.Documento = SomenteDigitos(c.Documento)
+	c.Nome = strings.TrimSpace(c.Nome)
+	c.IE = normalizarIE(c.IE)
+	c.Email = strings.TrimSpace(c.Email)
+	c.Endereco.normalizar()
+	if c.IndicadorIE == "" {
+		c.IndicadorIE = IndicadorIENaoContribuinte
+	}
+
+	var erros ErrosCadastro
+	switch len(c.Documento) {
+	case 14:
+		if !ValidarCNPJ(c.Documento) {
+			erros.add("documento: cnpj invalido")
+		}
+	case 11:
+		if !ValidarCPF(c.Documento) {
+			erros.add("documento: cpf invalido")
+		}
+	default:
+		erros.add("documento deve ser um CNPJ (14 digitos) ou CPF (11 digitos)")
+	}
+	erros.texto("nome", c.Nome, 2, 60, true)
+
+	// Regras de indIEDest do leiaute: isento não informa IE e contribuinte é obrigado a informar
+	switch c.IndicadorIE {
+	case IndicadorIEContribuinte:
+		if c.IE == "" || c.IE == IEIsento || !ValidarIE(c.Endereco.UF, c.IE) {
+			erros.add("ie %q invalida para a UF %s", c.IE, c.Endereco.UF)
+		}
+	case IndicadorIEIsento:
+		if c.IE != "" && c.IE != IEIsento {
+			erros.add("cliente isento nao deve informar ie")
+		}
+		c.IE = ""
+	case IndicadorIENaoContribuinte:
+		if c.IE != "" && (c.IE == IEIsento || !ValidarIE(c.Endereco.UF, c.IE)) {
+			erros.add("ie %q invalida para a UF %s", c.IE, c.Endereco.UF)
+		}
+	default:
+		erros.add("indicadorIE deve ser 1 (contribuinte), 2 (isento) ou 9 (nao contribuinte)")
+	}
+
+	if c.Email != "" {
+		if _, err := mail.ParseAddress(c.Email); err != nil || utf8.RuneCountInString(c.Email) > 60 {
+			erros.add("email invalido")
+		}
+	}
+	c.Endereco.validar(&erros)
+
+	if len(erros) > 0 {
+		return erros
+	}
+	return nil
+}
+
+func (Cliente) TableName() string {
+	return "clientes"
+}
+
+func normalizarIE(ie string) string {
+	ie = strings.TrimSpace(ie)
+	if strings.EqualFold(ie, IEIsento) {
+		return IEIsento
+	}
+	return SomenteDigitos(ie)
+}
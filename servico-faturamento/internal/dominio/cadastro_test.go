@@ -0,0 +1,206 @@
+package dominio_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func enderecoSP() dominio.Endereco {
+	return dominio.Endereco{
+		Logradouro:      "Avenida Paulista",
+		Numero:          "1000",
+		Bairro:          "Bela Vista",
+		CodigoMunicipio: "3550308",
+		Municipio:       "Sao Paulo",
+		UF:              "sp",
+		CEP:             "01310-100",
+	}
+}
+
+func TestValidarDocumentos(t *testing.T) {
+	t.Run("deve validar digitos de CNPJ e CPF", func(t *testing.T) {
+		if !dominio.ValidarCNPJ("11222333000181") {
+			t.Error("esperava CNPJ 11222333000181 valido")
+		}
+		if !dominio.ValidarCPF("52998224725") {
+			t.Error("esperava CPF 52998224725 valido")
+		}
+	})
+
+	t.Run("deve rejeitar DV incorreto e sequencias repetidas", func(t *testing.T) {
+		for _, cnpj := range []string{"11222333000182", "00000000000000", "1122233300018"} {
+			if dominio.ValidarCNPJ(cnpj) {
+				t.Errorf("esperava CNPJ %s invalido", cnpj)
+			}
+		}
+		for _, cpf := range []string{"52998224724", "11111111111", "5299822472a"} {
+			if dominio.ValidarCPF(cpf) {
+				t.Errorf("esperava CPF %s invalido", cpf)
+			}
+		}
+	})
+}
+
+func TestValidarIE(t *testing.T) {
+	// Exemplos das rotinas de conferência publicadas pelo Sintegra
+	validas := map[string][]string{
+		"AC": {"0100482300112"},
+		"AL": {"240000048"},
+		"AP": {"030123459"},
+		"BA": {"12345663", "100000306"},
+		"CE": {"060000015"},
+		"DF": {"0730000100109"},
+		"GO": {"109876547"},
+		"MA": {"120000385"},
+		"MG": {"0623079040081"},
+		"MT": {"00130000019"},
+		"PA": {"159999995"},
+		"PB": {"060000015"},
+		"PE": {"032141840", "18100100000049"},
+		"PI": {"193016567"},
+		"PR": {"1234567850"},
+		"RN": {"200400401", "2000400400"},
+		"RO": {"00000000625213"},
+		"RR": {"240066281"},
+		"RS": {"2243658792"},
+		"SC": {"251040852"},
+		"SE": {"271234563"},
+		"SP": {"110042490114"},
+		"TO": {"29010227836"},
+	}
+
+	t.Run("deve aceitar inscricoes validas de cada UF", func(t *testing.T) {
+		for uf, inscricoes := range validas {
+			for _, ie := range inscricoes {
+				if !dominio.ValidarIE(uf, ie) {
+					t.Errorf("esperava IE %s valida para %s", ie, uf)
+				}
+			}
+		}
+	})
+
+	t.Run("deve rejeitar DV alterado, UF errada e UF inexistente", func(t *testing.T) {
+		casos := []struct{ uf, ie string }{
+			{"SP", "110042490115"},
+			{"MG", "0623079040082"},
+			{"RS", "2243658793"},
+			{"PR", "110042490114"},
+			{"XX", "110042490114"},
+		}
+		for _, caso := range casos {
+			if dominio.ValidarIE(caso.uf, caso.ie) {
+				t.Errorf("esperava IE %s invalida para %s", caso.ie, caso.uf)
+			}
+		}
+	})
+
+	t.Run("deve aceitar ISENTO em qualquer UF", func(t *testing.T) {
+		if !dominio.ValidarIE("RJ", "isento") {
+			t.Error("esperava ISENTO aceito")
+		}
+	})
+}
+
+func TestEmitenteValidar(t *testing.T) {
+	t.Run("deve normalizar e aceitar emitente completo", func(t *testing.T) {
+		emitente := dominio.Emitente{
+			CNPJ:        "11.222.333/0001-81",
+			RazaoSocial: "Empresa Teste LTDA",
+			IE:          "110.042.490.114",
+			CRT:         dominio.CRTSimplesNacional,
+			Endereco:    enderecoSP(),
+		}
+
+		if err := emitente.Validar(); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if emitente.CNPJ != "11222333000181" || emitente.IE != "110042490114" {
+			t.Errorf("esperava documentos sem pontuacao, obteve CNPJ %s IE %s", emitente.CNPJ, emitente.IE)
+		}
+		if emitente.Endereco.UF != "SP" || emitente.Endereco.CEP != "01310100" {
+			t.Errorf("esperava UF e CEP normalizados, obteve %s %s", emitente.Endereco.UF, emitente.Endereco.CEP)
+		}
+	})
+
+	t.Run("deve listar todos os campos invalidos", func(t *testing.T) {
+		endereco := enderecoSP()
+		endereco.CodigoMunicipio = "3304557" // Rio de Janeiro
+		emitente := dominio.Emitente{
+			CNPJ:        "11222333000182",
+			RazaoSocial: "Empresa Teste LTDA",
+			IE:          "2243658792",
+			CRT:         "5",
+			Endereco:    endereco,
+		}
+
+		err := emitente.Validar()
+
+		var erros dominio.ErrosCadastro
+		if !errors.As(err, &erros) {
+			t.Fatalf("esperava ErrosCadastro, obteve %v", err)
+		}
+		mensagem := err.Error()
+		for _, trecho := range []string{"cnpj", "ie 2243658792", "crt", "codigoMunicipio 3304557"} {
+			if !strings.Contains(mensagem, trecho) {
+				t.Errorf("esperava erro contendo %q, obteve: %s", trecho, mensagem)
+			}
+		}
+	})
+}
+
+func TestClienteValidar(t *testing.T) {
+	t.Run("deve aceitar pessoa fisica nao contribuinte sem IE", func(t *testing.T) {
+		cliente := dominio.Cliente{
+			Documento: "529.982.247-25",
+			Nome:      "Maria da Silva",
+			Email:     "maria@example.com",
+			Endereco:  enderecoSP(),
+		}
+
+		if err := cliente.Validar(); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if cliente.PessoaJuridica() {
+			t.Error("esperava pessoa fisica")
+		}
+		if cliente.IndicadorIE != dominio.IndicadorIENaoContribuinte {
+			t.Errorf("esperava indicador 9 por padrao, obteve %s", cliente.IndicadorIE)
+		}
+	})
+
+	t.Run("deve exigir IE valida de contribuinte", func(t *testing.T) {
+		cliente := dominio.Cliente{
+			Documento:   "11222333000181",
+			Nome:        "Cliente PJ LTDA",
+			IndicadorIE: dominio.IndicadorIEContribuinte,
+			Endereco:    enderecoSP(),
+		}
+
+		if err := cliente.Validar(); err == nil {
+			t.Fatal("esperava erro para contribuinte sem IE")
+		}
+
+		cliente.IE = "110042490114"
+		if err := cliente.Validar(); err != nil {
+			t.Errorf("esperava nil com IE valida, obteve erro: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar documento e email invalidos", func(t *testing.T) {
+		cliente := dominio.Cliente{
+			Documento: "123",
+			Nome:      "Cliente",
+			Email:     "sem-arroba",
+			Endereco:  enderecoSP(),
+		}
+
+		err := cliente.Validar()
+
+		if err == nil || !strings.Contains(err.Error(), "documento") || !strings.Contains(err.Error(), "email") {
+			t.Errorf("esperava erros de documento e email, obteve %v", err)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package dominio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Parâmetros do evento de cancelamento (tpEvento 110111, leiaute 1.00): limites do
+// xJust e prazo padrão da SEFAZ
+const (
+	TipoEventoCancelamento = "110111"
+	DescEventoCancelamento = "Cancelamento"
+
+	JustificativaMinima     = 15
+	JustificativaMaxima     = 255
+	PrazoCancelamentoPadrao = 24 * time.Hour
+)
+
+var (
+	// ErrNotaNaoCancelavel indica nota que não está fechada
+	ErrNotaNaoCancelavel = errors.New("apenas nota fechada pode ser cancelada")
+	// ErrCancelamentoExigeEvento indica nota autorizada: ela continua válida na SEFAZ
+	// até o evento de cancelamento (110111) e não é cancelada só no banco
+	ErrCancelamentoExigeEvento = fmt.Errorf("%w: nota autorizada so pode ser cancelada pelo evento 110111 na SEFAZ", ErrNotaNaoCancelavel)
+	// ErrAutorizacaoPendente indica nota fechada já entregue ao autorizador: a SEFAZ
+	// pode autorizá-la depois do cancelamento no banco
+	ErrAutorizacaoPendente = fmt.Errorf("%w: nota aguarda o retorno da SEFAZ", ErrNotaNaoCancelavel)
+	// ErrPrazoCancelamentoExpirado indica que a janela de cancelamento já passou
+	ErrPrazoCancelamentoExpirado = errors.New("prazo de cancelamento expirado")
+	// ErrJustificativaInvalida indica xJust fora de 15 a 255 caracteres
+	ErrJustificativaInvalida = fmt.Errorf("justificativa deve ter de %d a %d caracteres", JustificativaMinima, JustificativaMaxima)
+)
+
+// NormalizarJustificativa remove espaços das pontas e confere o tamanho do xJust
+func NormalizarJustificativa(justificativa string) (string, error) {
+	justificativa = strings.TrimSpace(justificativa)
+	if n := utf8.RuneCountInString(justificativa); n < JustificativaMinima || n > JustificativaMaxima {
+		return "", ErrJustificativaInvalida
+	}
+	return justificativa, nil
+}
+
+// PrazoCancelamento devolve o instante limite para cancelar a nota, contado da
+// autorização pela SEFAZ ou, enquanto ela não chega, do fechamento
+func (n *NotaFiscal) PrazoCancelamento(prazo time.Duration) (time.Time, bool) {
+	if n.DataAutorizacao != nil {
+		return n.DataAutorizacao.Add(prazo), true
+	}
+	if n.DataFechada == nil {
+		return time.Time{}, false
+	}
+	return n.DataFechada.Add(prazo), true
+}
+
+// Cancelar passa a nota fechada, ainda não autorizada, para CANCELADA, desde que a
+// justificativa seja válida e o cancelamento ocorra dentro do prazo. A nota
+// autorizada, ou que a SEFAZ ainda pode autorizar, não é cancelada só no banco:
+// sem o evento 110111 (PrepararCancelamento e RegistrarCancelamento) ela seguiria
+// válida na SEFAZ.
+func (n *NotaFiscal) Cancelar(justificativa string, agora time.Time, prazo time.Duration) error {
+	switch n.Status {
+	case StatusNotaFechada:
+		if n.AutorizacaoPendente {
+			return ErrAutorizacaoPendente
+		}
+	case StatusNotaAutorizada:
+		return ErrCancelamentoExigeEvento
+	default:
+		return ErrNotaNaoCancelavel
+	}
+	justificativa, err := NormalizarJustificativa(justificativa)
+	if err != nil {
+		return err
+	}
+	if err := n.conferirPrazo(agora, prazo); err != nil {
+		return err
+	}
+
+	n.Status = StatusNotaCancelada
+	n.DataCancelamento = &agora
+	n.JustificativaCancelamento = &justificativa
+	return nil
+}
+
+// conferirPrazo recusa o cancelamento depois do limite da nota
+func (n *NotaFiscal) conferirPrazo(agora time.Time, prazo time.Duration) error {
+	limite, ok := n.PrazoCancelamento(prazo)
+	if !ok {
+		return ErrNotaNaoCancelavel
+	}
+	if agora.After(limite) {
+		return fmt.Errorf("%w: limite era %s", ErrPrazoCancelamentoExpirado, limite.In(FusoBrasilia).Format("02/01/2006 15:04"))
+	}
+	return nil
+}
+
+// ExigeEventoCancelamento informa se o cancelamento da nota depende do evento
+// 110111: a nota autorizada e a cancelada no banco que a SEFAZ autorizou depois
+func (n *NotaFiscal) ExigeEventoCancelamento() bool {
+	return n.Autorizada() || (n.AutorizacaoConflitante() && n.ProtocoloCancelamento == nil)
+}
+
+// PrepararCancelamento confere o pedido de cancelamento pelo evento 110111 e
+// devolve o xJust. O prazo conta da autorização; a nota com autorização
+// conflitante já foi cancelada no banco dentro do prazo, e o evento só regulariza
+// a situação na SEFAZ, que decide se ainda o aceita.
+func (n *NotaFiscal) PrepararCancelamento(justificativa string, agora time.Time, prazo time.Duration) (string, error) {
+	if !n.ExigeEventoCancelamento() || n.ChaveAcesso == nil {
+		return "", ErrNotaNaoCancelavel
+	}
+	justificativa, err := NormalizarJustificativa(justificativa)
+	if err != nil {
+		return "", err
+	}
+	if n.Status == StatusNotaAutorizada {
+		if err := n.conferirPrazo(agora, prazo); err != nil {
+			return "", err
+		}
+	}
+	return justificativa, nil
+}
+
+// RegistrarCancelamento grava o protocolo do evento 110111 aceito pela SEFAZ e
+// passa a nota para CANCELADA. A nota com autorização conflitante mantém a data e
+// a justificativa do cancelamento feito no banco.
+func (n *NotaFiscal) RegistrarCancelamento(justificativa, protocolo string, registro time.Time) error {
+	if !n.ExigeEventoCancelamento() {
+		return ErrNotaNaoCancelavel
+	}
+	if protocolo == "" {
+		return fmt.Errorf("%w: evento 110111 sem protocolo da SEFAZ", ErrNotaNaoCancelavel)
+	}
+
+	n.Status = StatusNotaCancelada
+	n.ProtocoloCancelamento = &protocolo
+	if n.DataCancelamento == nil {
+		n.DataCancelamento = &registro
+		n.JustificativaCancelamento = &justificativa
+	}
+	return nil
+}
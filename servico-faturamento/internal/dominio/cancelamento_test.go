@@ -0,0 +1,106 @@
+package dominio_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func notaFechadaEm(fechada time.Time) *dominio.NotaFiscal {
+	return &dominio.NotaFiscal{Numero: "10", Status: dominio.StatusNotaFechada, DataFechada: &fechada}
+}
+
+func TestNotaFiscal_Cancelar(t *testing.T) {
+	fechada := time.Date(2026, 3, 10, 9, 0, 0, 0, dominio.FusoBrasilia)
+	justificativa := "Pedido cancelado pelo cliente"
+
+	t.Run("deve cancelar nota fechada dentro do prazo", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		agora := fechada.Add(2 * time.Hour)
+
+		if err := nota.Cancelar("  "+justificativa+"  ", agora, dominio.PrazoCancelamentoPadrao); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if nota.Status != dominio.StatusNotaCancelada {
+			t.Errorf("esperava status CANCELADA, obteve: %s", nota.Status)
+		}
+		if nota.DataCancelamento == nil || !nota.DataCancelamento.Equal(agora) {
+			t.Errorf("esperava DataCancelamento %v, obteve %v", agora, nota.DataCancelamento)
+		}
+		if nota.JustificativaCancelamento == nil || *nota.JustificativaCancelamento != justificativa {
+			t.Errorf("esperava justificativa sem espacos nas pontas, obteve %v", nota.JustificativaCancelamento)
+		}
+	})
+
+	t.Run("deve aceitar cancelamento no limite exato do prazo", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		if err := nota.Cancelar(justificativa, fechada.Add(24*time.Hour), 24*time.Hour); err != nil {
+			t.Errorf("esperava nil, obteve erro: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar cancelamento fora do prazo", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		err := nota.Cancelar(justificativa, fechada.Add(24*time.Hour+time.Second), 24*time.Hour)
+		if !errors.Is(err, dominio.ErrPrazoCancelamentoExpirado) {
+			t.Errorf("esperava ErrPrazoCancelamentoExpirado, obteve: %v", err)
+		}
+		if nota.Status != dominio.StatusNotaFechada {
+			t.Errorf("status nao deveria mudar, obteve: %s", nota.Status)
+		}
+	})
+
+	t.Run("deve respeitar prazo configurado maior", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		if err := nota.Cancelar(justificativa, fechada.Add(100*time.Hour), 168*time.Hour); err != nil {
+			t.Errorf("esperava nil, obteve erro: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar nota aberta ou ja cancelada", func(t *testing.T) {
+		for _, status := range []string{dominio.StatusNotaAberta, dominio.StatusNotaCancelada} {
+			nota := notaFechadaEm(fechada)
+			nota.Status = status
+			err := nota.Cancelar(justificativa, fechada, dominio.PrazoCancelamentoPadrao)
+			if !errors.Is(err, dominio.ErrNotaNaoCancelavel) {
+				t.Errorf("status %s: esperava ErrNotaNaoCancelavel, obteve: %v", status, err)
+			}
+		}
+	})
+
+	t.Run("deve recusar nota pendente de autorizacao na SEFAZ", func(t *testing.T) {
+		nota := notaFechadaEm(fechada)
+		nota.AutorizacaoPendente = true
+		err := nota.Cancelar(justificativa, fechada.Add(time.Hour), dominio.PrazoCancelamentoPadrao)
+		if !errors.Is(err, dominio.ErrAutorizacaoPendente) || !errors.Is(err, dominio.ErrNotaNaoCancelavel) {
+			t.Fatalf("esperava ErrAutorizacaoPendente, obteve: %v", err)
+		}
+		if nota.Status != dominio.StatusNotaFechada || nota.DataCancelamento != nil {
+			t.Errorf("nota pendente alterada: status %s, cancelamento %v", nota.Status, nota.DataCancelamento)
+		}
+	})
+
+	t.Run("deve exigir justificativa de 15 a 255 caracteres", func(t *testing.T) {
+		casos := map[string]bool{
+			"curta demais":                        false,
+			"   quatorze ch   ":                   false,
+			"quinze letras!":                      false,
+			strings.Repeat("a", 15):               true,
+			strings.Repeat("ç", 255):              true,
+			strings.Repeat("a", 256):              false,
+			"Erro na digitação do valor unitário": true,
+		}
+		for entrada, valida := range casos {
+			_, err := dominio.NormalizarJustificativa(entrada)
+			if valida && err != nil {
+				t.Errorf("%q: esperava valida, obteve erro: %v", entrada, err)
+			}
+			if !valida && !errors.Is(err, dominio.ErrJustificativaInvalida) {
+				t.Errorf("%q: esperava ErrJustificativaInvalida, obteve: %v", entrada, err)
+			}
+		}
+	})
+}
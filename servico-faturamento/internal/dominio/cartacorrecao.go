@@ -0,0 +1,104 @@
+package dominio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Parâmetros do evento Carta de Correção (tpEvento 110110, leiaute CC-e 1.00)
+const (
+	TipoEventoCartaCorrecao = "110110"
+	DescEventoCartaCorrecao = "Carta de Correcao"
+	MaxCartasCorrecao       = 20
+	CorrecaoMinima          = 15
+	CorrecaoMaxima          = 1000
+
+	// CondicaoUsoCartaCorrecao é o texto fixo exigido em xCondUso pelo schema do evento
+	CondicaoUsoCartaCorrecao = "A Carta de Correcao e disciplinada pelo paragrafo 1o-A do art. 7o do Convenio S/N, de 15 de dezembro de 1970 e pode ser utilizada para regularizacao de erro ocorrido na emissao de documento fiscal, desde que o erro nao esteja relacionado com: I - as variaveis que determinam o valor do imposto tais como: base de calculo, aliquota, diferenca de preco, quantidade, valor da operacao ou da prestacao; II - a correcao de dados cadastrais que implique mudanca do remetente ou do destinatario; III - a data de emissao ou de saida."
+)
+
+var (
+	// ErrNotaNaoCorrigivel indica nota sem autorização da SEFAZ, sem protocolo ou sem chave de acesso
+	ErrNotaNaoCorrigivel = errors.New("carta de correcao exige nota autorizada, com protocolo e chave de acesso")
+	// ErrLimiteCartasCorrecao indica que a nota já recebeu as 20 correções permitidas
+	ErrLimiteCartasCorrecao = fmt.Errorf("nota ja possui o limite de %d cartas de correcao", MaxCartasCorrecao)
+	// ErrCorrecaoInvalida indica xCorrecao fora de 15 a 1000 caracteres
+	ErrCorrecaoInvalida = fmt.Errorf("correcao deve ter de %d a %d caracteres", CorrecaoMinima, CorrecaoMaxima)
+)
+
+// CartaCorrecao é uma CC-e registrada para a nota. As cartas são sequenciais
+// (nSeqEvento de 1 a 20) e cada uma substitui a anterior, por isso o texto de
+// uma nova carta deve repetir todas as correções ainda válidas.
+type CartaCorrecao struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	NotaID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_cartas_correcao_sequencia,priority:1" json:"notaId"`
+	Sequencia  int       `gorm:"not null;uniqueIndex:idx_cartas_correcao_sequencia,priority:2" json:"sequencia"`
+	Correcao   string    `gorm:"size:1000;not null" json:"correcao"`
+	DataEvento time.Time `gorm:"not null" json:"dataEvento"`
+	XML        string    `gorm:"column:xml;type:text;not null" json:"-"`
+
+	// Vigente marca a carta de maior sequência, a única que vale; não é persistido
+	Vigente bool `gorm:"-" json:"vigente"`
+}
+
+func (CartaCorrecao) TableName() string {
+	return "cartas_correcao"
+}
+
+func (c *CartaCorrecao) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// NormalizarCorrecao junta as linhas e os espaços repetidos do texto, que no
+// xCorrecao não pode ter quebras, e confere o tamanho
+func NormalizarCorrecao(correcao string) (string, error) {
+	correcao = strings.Join(strings.Fields(correcao), " ")
+	if n := utf8.RuneCountInString(correcao); n < CorrecaoMinima || n > CorrecaoMaxima {
+		return "", ErrCorrecaoInvalida
+	}
+	return correcao, nil
+}
+
+// NovaCartaCorrecao prepara a próxima carta da nota; registradas é a quantidade
+// de cartas que a nota já recebeu
+func (n *NotaFiscal) NovaCartaCorrecao(registradas int, correcao string, agora time.Time) (CartaCorrecao, error) {
+	if !n.Autorizada() || n.ChaveAcesso == nil {
+		return CartaCorrecao{}, ErrNotaNaoCorrigivel
+	}
+	if registradas >= MaxCartasCorrecao {
+		return CartaCorrecao{}, ErrLimiteCartasCorrecao
+	}
+	correcao, err := NormalizarCorrecao(correcao)
+	if err != nil {
+		return CartaCorrecao{}, err
+	}
+	return CartaCorrecao{
+		NotaID:     n.ID,
+		Sequencia:  registradas + 1,
+		Correcao:   correcao,
+		DataEvento: agora,
+	}, nil
+}
+
+// MarcarCartaVigente marca como vigente a carta de maior sequência
+func MarcarCartaVigente(cartas []CartaCorrecao) {
+	vigente := -1
+	for i := range cartas {
+		cartas[i].Vigente = false
+		if vigente < 0 || cartas[i].Sequencia > cartas[vigente].Sequencia {
+			vigente = i
+		}
+	}
+	if vigente >= 0 {
+		cartas[vigente].Vigente = true
+	}
+}
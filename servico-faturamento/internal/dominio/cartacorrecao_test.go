@@ -0,0 +1,92 @@
+package dominio_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+
+	"github.com/google/uuid"
+)
+
+func TestNotaFiscal_NovaCartaCorrecao(t *testing.T) {
+	agora := time.Date(2026, 3, 11, 10, 0, 0, 0, dominio.FusoBrasilia)
+	chave := "35260311222333000181550010000000101000000010"
+	protocolo := "135260000000001"
+	nota := func() *dominio.NotaFiscal {
+		n := notaFechadaEm(agora.Add(-48 * time.Hour))
+		n.ID = uuid.New()
+		n.ChaveAcesso = &chave
+		n.Status = dominio.StatusNotaAutorizada
+		n.ProtocoloAutorizacao = &protocolo
+		return n
+	}
+
+	t.Run("deve numerar a proxima sequencia e normalizar o texto", func(t *testing.T) {
+		carta, err := nota().NovaCartaCorrecao(2, "Endereco de entrega:\n  Rua das Flores,   100", agora)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if carta.Sequencia != 3 {
+			t.Errorf("esperava sequencia 3, obteve %d", carta.Sequencia)
+		}
+		if carta.Correcao != "Endereco de entrega: Rua das Flores, 100" {
+			t.Errorf("texto nao normalizado: %q", carta.Correcao)
+		}
+	})
+
+	t.Run("deve limitar a 20 cartas por nota", func(t *testing.T) {
+		if _, err := nota().NovaCartaCorrecao(19, "Vigesima correcao da nota", agora); err != nil {
+			t.Errorf("vigesima carta deveria ser aceita: %v", err)
+		}
+		if _, err := nota().NovaCartaCorrecao(20, "Vigesima primeira correcao", agora); !errors.Is(err, dominio.ErrLimiteCartasCorrecao) {
+			t.Errorf("esperava ErrLimiteCartasCorrecao, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve exigir nota autorizada com protocolo e chave", func(t *testing.T) {
+		semChave := nota()
+		semChave.ChaveAcesso = nil
+		fechada := nota()
+		fechada.Status = dominio.StatusNotaFechada
+		fechada.ProtocoloAutorizacao = nil
+		semProtocolo := nota()
+		semProtocolo.ProtocoloAutorizacao = nil
+		cancelada := nota()
+		cancelada.Status = dominio.StatusNotaCancelada
+
+		for _, n := range []*dominio.NotaFiscal{semChave, fechada, semProtocolo, cancelada} {
+			if _, err := n.NovaCartaCorrecao(0, "Correcao qualquer valida", agora); !errors.Is(err, dominio.ErrNotaNaoCorrigivel) {
+				t.Errorf("esperava ErrNotaNaoCorrigivel, obteve: %v", err)
+			}
+		}
+	})
+
+	t.Run("deve exigir correcao de 15 a 1000 caracteres", func(t *testing.T) {
+		for _, texto := range []string{"curta", "  \n\t  ", strings.Repeat("a", 1001)} {
+			if _, err := nota().NovaCartaCorrecao(0, texto, agora); !errors.Is(err, dominio.ErrCorrecaoInvalida) {
+				t.Errorf("%.20q: esperava ErrCorrecaoInvalida, obteve: %v", texto, err)
+			}
+		}
+	})
+}
+
+func TestMarcarCartaVigente(t *testing.T) {
+	t.Run("deve marcar apenas a carta de maior sequencia", func(t *testing.T) {
+		cartas := []dominio.CartaCorrecao{{Sequencia: 2}, {Sequencia: 3, Vigente: false}, {Sequencia: 1, Vigente: true}}
+
+		dominio.MarcarCartaVigente(cartas)
+
+		for _, c := range cartas {
+			if c.Vigente != (c.Sequencia == 3) {
+				t.Errorf("sequencia %d: vigente=%v", c.Sequencia, c.Vigente)
+			}
+		}
+	})
+
+	t.Run("deve aceitar lista vazia", func(t *testing.T) {
+		dominio.MarcarCartaVigente(nil)
+	})
+}
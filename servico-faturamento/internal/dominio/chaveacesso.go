@@ -0,0 +1,150 @@
+package dominio
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// TamanhoChaveAcesso é o número de dígitos da chave de acesso da NF-e/NFC-e
+const TamanhoChaveAcesso = 44
+
+// ErrChaveAcessoInvalida indica chave com formato, componentes ou DV incorretos
+var ErrChaveAcessoInvalida = errors.New("chave de acesso invalida")
+
+// ComponentesChave são os campos que formam a chave de acesso (MOC, item 2.2.6.1)
+type ComponentesChave struct {
+	CUF            string    // código IBGE da UF do emitente
+	Emissao        time.Time // fornece o AAMM
+	CNPJ           string    // CNPJ do emitente (14 dígitos)
+	Modelo         string    // 55 (NF-e) ou 65 (NFC-e)
+	Serie          int       // 0 a 999
+	Numero         int64     // 1 a 999999999
+	TipoEmissao    string    // tpEmis (1 = normal)
+	CodigoNumerico string    // cNF (8 dígitos)
+}
+
+// MontarChaveAcesso compõe os 43 dígitos da chave e acrescenta o DV módulo 11
+func MontarChaveAcesso(c ComponentesChave) (string, error) {
+	switch {
+	case len(c.CUF) != 2 || !apenasDigitos(c.CUF):
+		return "", fmt.Errorf("%w: cUF %q", ErrChaveAcessoInvalida, c.CUF)
+	case len(c.CNPJ) != 14 || !apenasDigitos(c.CNPJ):
+		return "", fmt.Errorf("%w: CNPJ %q", ErrChaveAcessoInvalida, c.CNPJ)
+	case c.Modelo != "55" && c.Modelo != "65":
+		return "", fmt.Errorf("%w: modelo %q", ErrChaveAcessoInvalida, c.Modelo)
+	case c.Serie < 0 || c.Serie > 999:
+		return "", fmt.Errorf("%w: serie %d", ErrChaveAcessoInvalida, c.Serie)
+	case c.Numero < 1 || c.Numero > 999999999:
+		return "", fmt.Errorf("%w: numero %d", ErrChaveAcessoInvalida, c.Numero)
+	case len(c.TipoEmissao) != 1 || !apenasDigitos(c.TipoEmissao):
+		return "", fmt.Errorf("%w: tpEmis %q", ErrChaveAcessoInvalida, c.TipoEmissao)
+	case len(c.CodigoNumerico) != 8 || !apenasDigitos(c.CodigoNumerico):
+		return "", fmt.Errorf("%w: cNF %q", ErrChaveAcessoInvalida, c.CodigoNumerico)
+	}
+
+	base := fmt.Sprintf("%s%s%s%s%03d%09d%s%s",
+		c.CUF, c.Emissao.Format("0601"), c.CNPJ, c.Modelo, c.Serie, c.Numero, c.TipoEmissao, c.CodigoNumerico)
+	return base + DigitoModulo11(base), nil
+}
+
+// ValidarChaveAcesso confere tamanho, cUF, mês, modelo e dígito verificador
+func ValidarChaveAcesso(chave string) error {
+	_, err := DecomporChaveAcesso(chave)
+	return err
+}
+
+// DecomporChaveAcesso valida a chave e devolve os seus componentes
+func DecomporChaveAcesso(chave string) (ComponentesChave, error) {
+	if len(chave) != TamanhoChaveAcesso || !apenasDigitos(chave) {
+		return ComponentesChave{}, fmt.Errorf("%w: deve ter %d digitos", ErrChaveAcessoInvalida, TamanhoChaveAcesso)
+	}
+	if DigitoModulo11(chave[:43]) != chave[43:] {
+		return ComponentesChave{}, fmt.Errorf("%w: digito verificador nao confere", ErrChaveAcessoInvalida)
+	}
+
+	cUF := chave[0:2]
+	if !codigoUFValido(cUF) {
+		return ComponentesChave{}, fmt.Errorf("%w: cUF %s inexistente", ErrChaveAcessoInvalida, cUF)
+	}
+
+	emissao, err := time.Parse("0601", chave[2:6])
+	if err != nil {
+		return ComponentesChave{}, fmt.Errorf("%w: AAMM %s", ErrChaveAcessoInvalida, chave[2:6])
+	}
+
+	modelo := chave[20:22]
+	if modelo != "55" && modelo != "65" {
+		return ComponentesChave{}, fmt.Errorf("%w: modelo %s", ErrChaveAcessoInvalida, modelo)
+	}
+
+	serie, _ := strconv.Atoi(chave[22:25])
+	numero, _ := strconv.ParseInt(chave[25:34], 10, 64)
+	if numero == 0 {
+		return ComponentesChave{}, fmt.Errorf("%w: numero zerado", ErrChaveAcessoInvalida)
+	}
+
+	return ComponentesChave{
+		CUF:            cUF,
+		Emissao:        emissao,
+		CNPJ:           chave[6:20],
+		Modelo:         modelo,
+		Serie:          serie,
+		Numero:         numero,
+		TipoEmissao:    chave[34:35],
+		CodigoNumerico: chave[35:43],
+	}, nil
+}
+
+// DigitoModulo11 calcula o DV com pesos 2 a 9 aplicados da direita para a esquerda;
+// restos 0 e 1 resultam em DV 0
+func DigitoModulo11(base string) string {
+	soma, peso := 0, 2
+	for i := len(base) - 1; i >= 0; i-- {
+		soma += int(base[i]-'0') * peso
+		peso++
+		if peso > 9 {
+			peso = 2
+		}
+	}
+	dv := 11 - soma%11
+	if dv >= 10 {
+		dv = 0
+	}
+	return strconv.Itoa(dv)
+}
+
+// GerarCodigoNumerico sorteia o cNF, evitando que repita o número da nota
+// (vedado pela regra de validação 540 da SEFAZ)
+func GerarCodigoNumerico(numero int64) (string, error) {
+	for {
+		n, err := rand.Int(rand.Reader, big.NewInt(100000000))
+		if err != nil {
+			return "", fmt.Errorf("falha ao gerar cNF: %w", err)
+		}
+		if n.Int64() != numero%100000000 {
+			return fmt.Sprintf("%08d", n.Int64()), nil
+		}
+	}
+}
+
+func codigoUFValido(cUF string) bool {
+	for _, codigo := range codigosUF {
+		if codigo == cUF {
+			return true
+		}
+	}
+	return false
+}
+
+func apenasDigitos(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,134 @@
+package dominio_test
+
+import (
+	"errors"
+	"servico-faturamento/internal/dominio"
+	"testing"
+	"time"
+)
+
+func componentesTeste() dominio.ComponentesChave {
+	return dominio.ComponentesChave{
+		CUF:            "35",
+		Emissao:        time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		CNPJ:           "11222333000181",
+		Modelo:         "55",
+		Serie:          1,
+		Numero:         123,
+		TipoEmissao:    "1",
+		CodigoNumerico: "12345678",
+	}
+}
+
+func TestMontarChaveAcesso(t *testing.T) {
+	t.Run("deve compor chave de 44 digitos com DV modulo 11", func(t *testing.T) {
+		chave, err := dominio.MontarChaveAcesso(componentesTeste())
+
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		esperado := "3525031122233300018155001000000123112345678"
+		if chave[:43] != esperado {
+			t.Errorf("esperava base %s, obteve %s", esperado, chave[:43])
+		}
+		if len(chave) != dominio.TamanhoChaveAcesso {
+			t.Errorf("esperava %d digitos, obteve %d", dominio.TamanhoChaveAcesso, len(chave))
+		}
+	})
+
+	t.Run("deve rejeitar componentes fora do leiaute", func(t *testing.T) {
+		c := componentesTeste()
+		c.Modelo = "57"
+
+		_, err := dominio.MontarChaveAcesso(c)
+
+		if !errors.Is(err, dominio.ErrChaveAcessoInvalida) {
+			t.Errorf("esperava ErrChaveAcessoInvalida, obteve %v", err)
+		}
+	})
+}
+
+func TestDigitoModulo11(t *testing.T) {
+	// Chaves publicadas no Manual de Orientação do Contribuinte
+	casos := map[string]string{
+		"3508059999909091027055001000000001518005127": "3",
+		"5206043300991100250655012000000780026730161": "5",
+		// resto 0 ou 1 resulta em DV 0
+		"3525031122233300018155001000000123112345678": "0",
+	}
+
+	for base, esperado := range casos {
+		if dv := dominio.DigitoModulo11(base); dv != esperado {
+			t.Errorf("base %s: esperava DV %s, obteve %s", base, esperado, dv)
+		}
+	}
+}
+
+func TestDecomporChaveAcesso(t *testing.T) {
+	chave, _ := dominio.MontarChaveAcesso(componentesTeste())
+
+	t.Run("deve devolver os componentes da chave valida", func(t *testing.T) {
+		c, err := dominio.DecomporChaveAcesso(chave)
+
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if c.CNPJ != "11222333000181" || c.Serie != 1 || c.Numero != 123 || c.CodigoNumerico != "12345678" {
+			t.Errorf("componentes inesperados: %+v", c)
+		}
+	})
+
+	comDV := func(base string) string { return base + dominio.DigitoModulo11(base) }
+	dvTrocado := chave[:43] + string('0'+(chave[43]-'0'+1)%10)
+
+	invalidas := map[string]string{
+		"tamanho":             chave[:43],
+		"digito verificador":  dvTrocado,
+		"caractere invalido":  "A" + chave[1:],
+		"cUF inexistente":     comDV("99" + chave[2:43]),
+		"mes invalido":        comDV(chave[:4] + "13" + chave[6:43]),
+		"modelo desconhecido": comDV(chave[:20] + "57" + chave[22:43]),
+	}
+
+	for nome, chaveInvalida := range invalidas {
+		t.Run("deve rejeitar "+nome, func(t *testing.T) {
+			err := dominio.ValidarChaveAcesso(chaveInvalida)
+
+			if !errors.Is(err, dominio.ErrChaveAcessoInvalida) {
+				t.Errorf("esperava ErrChaveAcessoInvalida, obteve %v", err)
+			}
+		})
+	}
+}
+
+func TestNotaFiscal_AtribuirChaveAcesso(t *testing.T) {
+	t.Run("deve rejeitar nota aberta", func(t *testing.T) {
+		nota := &dominio.NotaFiscal{Numero: "NF-001", Status: dominio.StatusNotaAberta}
+
+		if err := nota.AtribuirChaveAcesso(componentesTeste()); err == nil {
+			t.Error("esperava erro ao atribuir chave em nota aberta")
+		}
+	})
+
+	t.Run("deve usar o AAMM de Brasilia e sortear cNF diferente do numero", func(t *testing.T) {
+		// 01/04 às 01h UTC ainda é 31/03 em Brasília
+		fechada := time.Date(2025, 4, 1, 1, 0, 0, 0, time.UTC)
+		nota := &dominio.NotaFiscal{Numero: "NF-123", Status: dominio.StatusNotaFechada, DataFechada: &fechada}
+
+		if err := nota.AtribuirChaveAcesso(componentesTeste()); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		chave := *nota.ChaveAcesso
+		if chave[2:6] != "2503" {
+			t.Errorf("esperava AAMM 2503, obteve %s", chave[2:6])
+		}
+		if chave[35:43] == "00000123" {
+			t.Error("cNF nao pode repetir o numero da nota")
+		}
+		if err := dominio.ValidarChaveAcesso(chave); err != nil {
+			t.Errorf("chave gerada invalida: %v", err)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package dominio
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tipos de emissão (tpEmis) usados pelo serviço; o tpEmis faz parte da chave de acesso
+const (
+	TipoEmissaoNormal = "1"
+	TipoEmissaoEPEC   = "4"
+	TipoEmissaoSVCAN  = "6"
+	TipoEmissaoSVCRS  = "7"
+	// TipoEmissaoOffline é a contingência da NFC-e, que não tem SVC nem EPEC
+	TipoEmissaoOffline = "9"
+)
+
+// Modos de emissão. Em SVC a nota é autorizada pela SEFAZ Virtual de Contingência
+// da UF; em EPEC só o evento prévio é registrado no Ambiente Nacional e a nota
+// aguarda a volta do autorizador para ser transmitida.
+const (
+	ModoNormal = "NORMAL"
+	ModoSVCAN  = "SVC-AN"
+	ModoSVCRS  = "SVC-RS"
+	ModoEPEC   = "EPEC"
+)
+
+// Evento prévio de emissão em contingência (EPEC)
+const (
+	TipoEventoEPEC = "110140"
+	DescEventoEPEC = "EPEC"
+	// COrgaoAmbienteNacional recebe os eventos EPEC de todas as UFs
+	COrgaoAmbienteNacional = "91"
+	// PrazoTransmissaoEPEC é o limite para transmitir a nota depois do EPEC
+	PrazoTransmissaoEPEC = 168 * time.Hour
+)
+
+var (
+	// ErrModoContingenciaInvalido indica modo fora de SVC-AN, SVC-RS e EPEC
+	ErrModoContingenciaInvalido = errors.New("modo de contingencia invalido (use SVC-AN, SVC-RS ou EPEC)")
+	// ErrSVCIncompativel indica SVC que não atende a UF do emitente
+	ErrSVCIncompativel = errors.New("SVC nao atende a UF do emitente")
+	// ErrSemContingencia indica pedido de encerramento sem contingência ativa
+	ErrSemContingencia = errors.New("emissao ja esta em modo normal")
+)
+
+// ufsSVCRS são as UFs atendidas pela SVC-RS; as demais usam a SVC-AN
+var ufsSVCRS = map[string]bool{
+	"AM": true, "BA": true, "GO": true, "MA": true, "MS": true, "MT": true, "PE": true, "PR": true,
+}
+
+var tipoEmissaoDoModo = map[string]string{
+	ModoNormal: TipoEmissaoNormal,
+	ModoEPEC:   TipoEmissaoEPEC,
+	ModoSVCAN:  TipoEmissaoSVCAN,
+	ModoSVCRS:  TipoEmissaoSVCRS,
+}
+
+// Contingencia registra um período de emissão fora do autorizador normal. O período
+// sem DataFim é o vigente; sem nenhum aberto a emissão é normal.
+type Contingencia struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	Modo          string     `gorm:"size:10;not null" json:"modo"`
+	Justificativa string     `gorm:"size:255;not null" json:"justificativa"`
+	Automatica    bool       `gorm:"not null;default:false" json:"automatica"`
+	DataInicio    time.Time  `gorm:"not null;index" json:"dataInicio"`
+	DataFim       *time.Time `json:"dataFim,omitempty"`
+}
+
+func (Contingencia) TableName() string {
+	return "contingencias"
+}
+
+func (c *Contingencia) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// ModoSVCDaUF devolve a SVC que atende a UF do emitente
+func ModoSVCDaUF(uf string) string {
+	if ufsSVCRS[uf] {
+		return ModoSVCRS
+	}
+	return ModoSVCAN
+}
+
+// NovaContingencia valida o modo para a UF do emitente e a justificativa (xJust)
+func NovaContingencia(modo, justificativa, uf string, automatica bool, inicio time.Time) (Contingencia, error) {
+	switch modo {
+	case ModoEPEC:
+	case ModoSVCAN, ModoSVCRS:
+		if esperado := ModoSVCDaUF(uf); modo != esperado {
+			return Contingencia{}, fmt.Errorf("%w: %s usa %s", ErrSVCIncompativel, uf, esperado)
+		}
+	default:
+		return Contingencia{}, fmt.Errorf("%w: %q", ErrModoContingenciaInvalido, modo)
+	}
+
+	justificativa, err := NormalizarJustificativa(justificativa)
+	if err != nil {
+		return Contingencia{}, err
+	}
+	return Contingencia{
+		Modo:          modo,
+		Justificativa: justificativa,
+		Automatica:    automatica,
+		DataInicio:    inicio,
+	}, nil
+}
+
+// TipoEmissao devolve o tpEmis das notas fechadas durante a contingência
+func (c Contingencia) TipoEmissao() string {
+	return tipoEmissaoDoModo[c.Modo]
+}
+
+// AplicarContingencia define o tpEmis da nota antes da geração da chave. Sem
+// contingência, ou em EPEC para nota sem destinatário (o evento exige o grupo
+// dest), a emissão é normal e a nota aguarda o autorizador da UF. A NFC-e em
+// qualquer contingência é emitida offline e transmitida quando ela acabar.
+func (n *NotaFiscal) AplicarContingencia(c *Contingencia) {
+	n.TipoEmissao = TipoEmissaoNormal
+	n.DataContingencia = nil
+	n.JustificativaContingencia = nil
+	if c == nil || (c.Modo == ModoEPEC && n.Cliente == nil && !n.NFCe()) {
+		return
+	}
+
+	inicio, justificativa := c.DataInicio, c.Justificativa
+	n.TipoEmissao = c.TipoEmissao()
+	if n.NFCe() {
+		n.TipoEmissao = TipoEmissaoOffline
+	}
+	n.DataContingencia = &inicio
+	n.JustificativaContingencia = &justificativa
+}
+
+// TipoEmissaoEfetivo trata como normal as notas fechadas antes do registro do tpEmis
+func (n *NotaFiscal) TipoEmissaoEfetivo() string {
+	if n.TipoEmissao == "" {
+		return TipoEmissaoNormal
+	}
+	return n.TipoEmissao
+}
+
+// EmContingencia informa se a nota foi emitida fora do autorizador normal
+func (n *NotaFiscal) EmContingencia() bool {
+	return n.TipoEmissaoEfetivo() != TipoEmissaoNormal
+}
+
+// RegistrarEPEC grava o protocolo do evento prévio na nota fechada em EPEC
+func (n *NotaFiscal) RegistrarEPEC(protocolo string, registro time.Time) error {
+	if n.Status != StatusNotaFechada || n.TipoEmissaoEfetivo() != TipoEmissaoEPEC {
+		return fmt.Errorf("%w: EPEC exige nota fechada com tpEmis 4", ErrNotaNaoAutorizavel)
+	}
+	n.ProtocoloEPEC = &protocolo
+	n.DataEPEC = &registro
+	return nil
+}
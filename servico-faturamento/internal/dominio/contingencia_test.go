@@ -0,0 +1,96 @@
+package dominio_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func TestNovaContingencia(t *testing.T) {
+	inicio := time.Date(2026, 3, 10, 13, 0, 0, 0, dominio.FusoBrasilia)
+	justificativa := "SEFAZ autorizadora fora do ar desde 13h"
+
+	t.Run("deve aceitar a SVC que atende a UF", func(t *testing.T) {
+		c, err := dominio.NovaContingencia(dominio.ModoSVCAN, justificativa, "SP", false, inicio)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if c.TipoEmissao() != dominio.TipoEmissaoSVCAN {
+			t.Errorf("esperava tpEmis 6, obteve %s", c.TipoEmissao())
+		}
+		if c, _ := dominio.NovaContingencia(dominio.ModoSVCRS, justificativa, "PR", false, inicio); c.TipoEmissao() != dominio.TipoEmissaoSVCRS {
+			t.Errorf("esperava tpEmis 7 para PR, obteve %s", c.TipoEmissao())
+		}
+	})
+
+	t.Run("deve rejeitar SVC de outra UF", func(t *testing.T) {
+		_, err := dominio.NovaContingencia(dominio.ModoSVCRS, justificativa, "SP", false, inicio)
+		if !errors.Is(err, dominio.ErrSVCIncompativel) {
+			t.Errorf("esperava ErrSVCIncompativel, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar modo desconhecido e justificativa curta", func(t *testing.T) {
+		if _, err := dominio.NovaContingencia(dominio.ModoNormal, justificativa, "SP", false, inicio); !errors.Is(err, dominio.ErrModoContingenciaInvalido) {
+			t.Errorf("esperava ErrModoContingenciaInvalido, obteve: %v", err)
+		}
+		if _, err := dominio.NovaContingencia(dominio.ModoEPEC, "fora do ar", "SP", false, inicio); !errors.Is(err, dominio.ErrJustificativaInvalida) {
+			t.Errorf("esperava ErrJustificativaInvalida, obteve: %v", err)
+		}
+	})
+}
+
+func TestNotaFiscal_AplicarContingencia(t *testing.T) {
+	inicio := time.Date(2026, 3, 10, 13, 0, 0, 0, dominio.FusoBrasilia)
+	svc := &dominio.Contingencia{Modo: dominio.ModoSVCAN, Justificativa: "SEFAZ-SP fora do ar desde 13h", DataInicio: inicio}
+	epec := &dominio.Contingencia{Modo: dominio.ModoEPEC, Justificativa: "SEFAZ-SP fora do ar desde 13h", DataInicio: inicio}
+
+	t.Run("deve registrar tpEmis, dhCont e xJust da contingencia", func(t *testing.T) {
+		nota := notaFechadaEm(inicio.Add(time.Hour))
+		nota.AplicarContingencia(svc)
+
+		if nota.TipoEmissao != dominio.TipoEmissaoSVCAN || !nota.EmContingencia() {
+			t.Errorf("esperava tpEmis 6, obteve %s", nota.TipoEmissao)
+		}
+		if nota.DataContingencia == nil || !nota.DataContingencia.Equal(inicio) {
+			t.Errorf("esperava dhCont %v, obteve %v", inicio, nota.DataContingencia)
+		}
+		if nota.JustificativaContingencia == nil || *nota.JustificativaContingencia != svc.Justificativa {
+			t.Errorf("esperava xJust da contingencia, obteve %v", nota.JustificativaContingencia)
+		}
+	})
+
+	t.Run("deve emitir normal sem contingencia ou em EPEC sem destinatario", func(t *testing.T) {
+		nota := notaFechadaEm(inicio)
+		nota.AplicarContingencia(svc)
+		nota.AplicarContingencia(nil)
+		if nota.EmContingencia() || nota.DataContingencia != nil || nota.JustificativaContingencia != nil {
+			t.Errorf("esperava emissao normal, obteve tpEmis %s", nota.TipoEmissao)
+		}
+
+		nota.AplicarContingencia(epec)
+		if nota.TipoEmissao != dominio.TipoEmissaoNormal {
+			t.Errorf("esperava tpEmis 1 para EPEC sem destinatario, obteve %s", nota.TipoEmissao)
+		}
+	})
+
+	t.Run("deve registrar o EPEC apenas em nota fechada com tpEmis 4", func(t *testing.T) {
+		nota := notaFechadaEm(inicio)
+		nota.Cliente = &dominio.Cliente{Documento: "52998224725"}
+		nota.AplicarContingencia(epec)
+
+		if err := nota.RegistrarEPEC("891260000000001", inicio.Add(time.Minute)); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if nota.ProtocoloEPEC == nil || *nota.ProtocoloEPEC != "891260000000001" {
+			t.Errorf("esperava protocolo do EPEC, obteve %v", nota.ProtocoloEPEC)
+		}
+
+		normal := notaFechadaEm(inicio)
+		if err := normal.RegistrarEPEC("891260000000002", inicio); !errors.Is(err, dominio.ErrNotaNaoAutorizavel) {
+			t.Errorf("esperava ErrNotaNaoAutorizavel, obteve: %v", err)
+		}
+	})
+}
@@ -0,0 +1,296 @@
+package dominio
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// CasasDecimais é a precisão de Decimal, a mesma de qCom e vUnCom usada pela nota
+const CasasDecimais = 4
+
+const escalaDecimal = 10000
+
+// digitosInteirosMax limita a parte inteira para o valor escalado caber em int64
+const digitosInteirosMax = 14
+
+var ErrDecimalInvalido = errors.New("decimal invalido")
+
+// Decimal é um número de ponto fixo com 4 casas decimais para valores e
+// quantidades. É serializado como string no JSON ("10.5") e como numeric no
+// banco, sem passar por float64 em nenhum momento.
+type Decimal struct {
+	v int64 // valor × 10^4
+}
+
+// DecimalDeInteiro converte um inteiro, como uma quantidade em unidades
+func DecimalDeInteiro(n int64) Decimal {
+	return Decimal{v: n * escalaDecimal}
+}
+
+// ParseDecimal aceita sinal opcional, parte inteira e até 4 casas após o ponto
+func ParseDecimal(s string) (Decimal, error) {
+	texto := strings.TrimSpace(s)
+	negativo := false
+	switch {
+	case strings.HasPrefix(texto, "-"):
+		negativo = true
+		texto = texto[1:]
+	case strings.HasPrefix(texto, "+"):
+		texto = texto[1:]
+	}
+
+	inteiro, fracao, temPonto := strings.Cut(texto, ".")
+	if inteiro == "" || !apenasDigitos(inteiro) || (temPonto && (fracao == "" || !apenasDigitos(fracao))) {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrDecimalInvalido, s)
+	}
+	if len(fracao) > CasasDecimais {
+		return Decimal{}, fmt.Errorf("%w: %q tem mais de %d casas decimais", ErrDecimalInvalido, s, CasasDecimais)
+	}
+	if len(strings.TrimLeft(inteiro, "0")) > digitosInteirosMax {
+		return Decimal{}, fmt.Errorf("%w: %q excede %d digitos inteiros", ErrDecimalInvalido, s, digitosInteirosMax)
+	}
+
+	v, err := strconv.ParseInt(inteiro+fracao+strings.Repeat("0", CasasDecimais-len(fracao)), 10, 64)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrDecimalInvalido, s)
+	}
+	if negativo {
+		v = -v
+	}
+	return Decimal{v: v}, nil
+}
+
+// MustParseDecimal é ParseDecimal para literais conhecidos; entra em pânico se inválido
+func MustParseDecimal(s string) Decimal {
+	d, err := ParseDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{v: d.v + o.v}
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	return Decimal{v: d.v - o.v}
+}
+
+// Mul multiplica com o produto exato arredondado para 4 casas. Entra em pânico
+// se o resultado não couber em Decimal; use MulVerificado com valores não validados.
+func (d Decimal) Mul(o Decimal) Decimal {
+	return d.MulArredondado(o, CasasDecimais)
+}
+
+// MulArredondado multiplica arredondando o produto exato uma única vez para o
+// número de casas informado, evitando o duplo arredondamento de Mul + Arredondar
+func (d Decimal) MulArredondado(o Decimal, casas int) Decimal {
+	r, ok := d.multiplicar(o, 1, casas)
+	if !ok {
+		panic(fmt.Sprintf("decimal: estouro ao multiplicar %s por %s", d, o))
+	}
+	return r
+}
+
+// MulVerificado multiplica e indica se o resultado coube em Decimal
+func (d Decimal) MulVerificado(o Decimal) (Decimal, bool) {
+	return d.multiplicar(o, 1, CasasDecimais)
+}
+
+// Percentual calcula d × p / 100 com um único arredondamento, como o valor de
+// um tributo a partir da base e da alíquota
+func (d Decimal) Percentual(p Decimal, casas int) Decimal {
+	r, ok := d.multiplicar(p, 100, casas)
+	if !ok {
+		panic(fmt.Sprintf("decimal: estouro ao aplicar %s%% sobre %s", p, d))
+	}
+	return r
+}
+
+// multiplicar divide o produto exato (escala 10^8) pelo divisor e o reduz para
+// o número de casas pedido
+func (d Decimal) multiplicar(o Decimal, divisor int64, casas int) (Decimal, bool) {
+	if casas > CasasDecimais {
+		casas = CasasDecimais
+	}
+	if casas < 0 {
+		casas = 0
+	}
+	fator := potencia10(CasasDecimais - casas)
+	produto := new(big.Int).Mul(big.NewInt(d.v), big.NewInt(o.v))
+	r := dividirArredondando(produto, escalaDecimal*divisor*fator)
+	r.Mul(r, big.NewInt(fator))
+	if !r.IsInt64() {
+		return Decimal{}, false
+	}
+	return Decimal{v: r.Int64()}, true
+}
+
+// Arredondar reduz para o número de casas informado seguindo a ABNT NBR 5891,
+// regra adotada pela SEFAZ: o 5 seguido apenas de zeros arredonda para o par
+func (d Decimal) Arredondar(casas int) Decimal {
+	if casas >= CasasDecimais {
+		return d
+	}
+	if casas < 0 {
+		casas = 0
+	}
+	fator := potencia10(CasasDecimais - casas)
+	r := dividirArredondando(big.NewInt(d.v), fator)
+	return Decimal{v: r.Int64() * fator}
+}
+
+// Cmp retorna -1, 0 ou 1 conforme d seja menor, igual ou maior que o
+func (d Decimal) Cmp(o Decimal) int {
+	switch {
+	case d.v < o.v:
+		return -1
+	case d.v > o.v:
+		return 1
+	}
+	return 0
+}
+
+func (d Decimal) IsZero() bool {
+	return d.v == 0
+}
+
+// Sinal retorna -1, 0 ou 1
+func (d Decimal) Sinal() int {
+	return d.Cmp(Decimal{})
+}
+
+// Inteiro retorna a parte inteira e se o valor não tem casas decimais
+func (d Decimal) Inteiro() (int64, bool) {
+	return d.v / escalaDecimal, d.v%escalaDecimal == 0
+}
+
+// String usa a menor representação exata, sem zeros à direita ("2", "10.5")
+func (d Decimal) String() string {
+	return d.FormatarMinimo(0)
+}
+
+// Formatar arredonda e escreve exatamente o número de casas informado ("10.50")
+func (d Decimal) Formatar(casas int) string {
+	if casas > CasasDecimais {
+		return d.formatar(CasasDecimais) + strings.Repeat("0", casas-CasasDecimais)
+	}
+	return d.Arredondar(casas).formatar(casas)
+}
+
+// FormatarMinimo escreve ao menos o número de casas informado, mais as casas
+// significativas até 4, sem arredondar ("10.50", "0.1234")
+func (d Decimal) FormatarMinimo(casas int) string {
+	if casas > CasasDecimais {
+		casas = CasasDecimais
+	}
+	for n := casas; n < CasasDecimais; n++ {
+		if d.Arredondar(n) == d {
+			return d.formatar(n)
+		}
+	}
+	return d.formatar(CasasDecimais)
+}
+
+func (d Decimal) formatar(casas int) string {
+	v := d.v
+	sinal := ""
+	if v < 0 {
+		sinal = "-"
+		v = -v
+	}
+	inteiro := strconv.FormatInt(v/escalaDecimal, 10)
+	if casas <= 0 {
+		return sinal + inteiro
+	}
+	fracao := fmt.Sprintf("%04d", v%escalaDecimal)[:casas]
+	return sinal + inteiro + "." + fracao
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON aceita string ou número JSON, para clientes anteriores à mudança
+func (d *Decimal) UnmarshalJSON(dados []byte) error {
+	dados = bytes.TrimSpace(dados)
+	if bytes.Equal(dados, []byte("null")) {
+		return nil
+	}
+	texto := string(dados)
+	if strings.HasPrefix(texto, `"`) {
+		if err := json.Unmarshal(dados, &texto); err != nil {
+			return err
+		}
+	}
+	v, err := ParseDecimal(texto)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// Value grava o valor como texto, convertido sem perda para numeric pelo Postgres
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *Decimal) Scan(src interface{}) error {
+	var texto string
+	switch v := src.(type) {
+	case nil:
+		*d = Decimal{}
+		return nil
+	case []byte:
+		texto = string(v)
+	case string:
+		texto = v
+	case int64:
+		*d = DecimalDeInteiro(v)
+		return nil
+	case float64:
+		texto = strconv.FormatFloat(v, 'f', CasasDecimais, 64)
+	default:
+		return fmt.Errorf("%w: tipo %T nao suportado", ErrDecimalInvalido, src)
+	}
+
+	v, err := ParseDecimal(texto)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+// dividirArredondando divide n por divisor aplicando o arredondamento para o par
+func dividirArredondando(n *big.Int, divisor int64) *big.Int {
+	d := big.NewInt(divisor)
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	dobro := new(big.Int).Abs(r)
+	dobro.Lsh(dobro, 1)
+	switch dobro.Cmp(d) {
+	case 1:
+		q.Add(q, big.NewInt(int64(n.Sign())))
+	case 0:
+		if q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(int64(n.Sign())))
+		}
+	}
+	return q
+}
+
+func potencia10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,165 @@
+package dominio_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func TestParseDecimal(t *testing.T) {
+	t.Run("deve aceitar inteiros, fracoes e sinal", func(t *testing.T) {
+		casos := map[string]string{
+			"10":       "10",
+			"10.50":    "10.5",
+			"0.0001":   "0.0001",
+			"-3.2":     "-3.2",
+			" 007.10 ": "7.1",
+		}
+		for entrada, esperado := range casos {
+			d, err := dominio.ParseDecimal(entrada)
+			if err != nil {
+				t.Errorf("esperava %q valido, obteve erro: %v", entrada, err)
+				continue
+			}
+			if d.String() != esperado {
+				t.Errorf("esperava %q para %q, obteve %q", esperado, entrada, d.String())
+			}
+		}
+	})
+
+	t.Run("deve rejeitar mais de 4 casas e formatos invalidos", func(t *testing.T) {
+		for _, entrada := range []string{"1.23456", "", ".5", "5.", "1,5", "1e3", "999999999999999"} {
+			if _, err := dominio.ParseDecimal(entrada); !errors.Is(err, dominio.ErrDecimalInvalido) {
+				t.Errorf("esperava ErrDecimalInvalido para %q, obteve %v", entrada, err)
+			}
+		}
+	})
+}
+
+func TestDecimalArredondar(t *testing.T) {
+	t.Run("deve seguir a ABNT NBR 5891", func(t *testing.T) {
+		casos := []struct{ valor, esperado string }{
+			{"2.344", "2.34"},
+			{"2.346", "2.35"},
+			{"2.345", "2.34"},  // 5 seguido de zeros com anterior par: mantém
+			{"2.355", "2.36"},  // 5 seguido de zeros com anterior ímpar: sobe
+			{"2.3451", "2.35"}, // 5 seguido de algarismo diferente de zero: sobe
+			{"-2.355", "-2.36"},
+		}
+		for _, caso := range casos {
+			obtido := dominio.MustParseDecimal(caso.valor).Formatar(2)
+			if obtido != caso.esperado {
+				t.Errorf("esperava %s arredondado para %s, obteve %s", caso.valor, caso.esperado, obtido)
+			}
+		}
+	})
+
+	t.Run("deve formatar com casas fixas ou minimas", func(t *testing.T) {
+		d := dominio.MustParseDecimal("10.5")
+		if d.Formatar(2) != "10.50" || d.Formatar(4) != "10.5000" || d.FormatarMinimo(2) != "10.50" {
+			t.Errorf("formatacao inesperada: %s %s %s", d.Formatar(2), d.Formatar(4), d.FormatarMinimo(2))
+		}
+		if preco := dominio.MustParseDecimal("0.1234"); preco.FormatarMinimo(2) != "0.1234" {
+			t.Errorf("esperava 0.1234 preservado, obteve %s", preco.FormatarMinimo(2))
+		}
+	})
+}
+
+func TestDecimalMul(t *testing.T) {
+	t.Run("deve multiplicar sem deriva de ponto flutuante", func(t *testing.T) {
+		var total dominio.Decimal
+		preco := dominio.MustParseDecimal("0.1")
+		for i := 0; i < 1000; i++ {
+			total = total.Add(preco.Mul(dominio.DecimalDeInteiro(3)))
+		}
+		if total != dominio.DecimalDeInteiro(300) {
+			t.Errorf("esperava 300, obteve %s", total)
+		}
+	})
+
+	t.Run("deve indicar estouro", func(t *testing.T) {
+		grande := dominio.MustParseDecimal("99999999999.9999")
+		if _, ok := grande.MulVerificado(grande); ok {
+			t.Error("esperava estouro ao multiplicar valores maximos")
+		}
+	})
+}
+
+func TestDecimalJSON(t *testing.T) {
+	t.Run("deve serializar como string", func(t *testing.T) {
+		dados, err := json.Marshal(struct {
+			Valor dominio.Decimal `json:"valor"`
+		}{dominio.MustParseDecimal("1500.25")})
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if string(dados) != `{"valor":"1500.25"}` {
+			t.Errorf("JSON inesperado: %s", dados)
+		}
+	})
+
+	t.Run("deve aceitar string e numero", func(t *testing.T) {
+		var item struct {
+			Quantidade    dominio.Decimal `json:"quantidade"`
+			PrecoUnitario dominio.Decimal `json:"precoUnitario"`
+		}
+		if err := json.Unmarshal([]byte(`{"quantidade":2.5,"precoUnitario":"3.99"}`), &item); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if item.Quantidade.String() != "2.5" || item.PrecoUnitario.String() != "3.99" {
+			t.Errorf("valores inesperados: %s %s", item.Quantidade, item.PrecoUnitario)
+		}
+		if err := json.Unmarshal([]byte(`{"quantidade":"1.00001"}`), &item); err == nil {
+			t.Error("esperava erro para mais de 4 casas decimais")
+		}
+	})
+}
+
+func TestDecimalScan(t *testing.T) {
+	t.Run("deve ler numeric do banco como texto", func(t *testing.T) {
+		var d dominio.Decimal
+		if err := d.Scan("1500.2500"); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if d.String() != "1500.25" {
+			t.Errorf("esperava 1500.25, obteve %s", d)
+		}
+		valor, _ := d.Value()
+		if valor != "1500.25" {
+			t.Errorf("esperava Value 1500.25, obteve %v", valor)
+		}
+	})
+}
+
+func TestItemNota_Validar(t *testing.T) {
+	t.Run("deve aceitar quantidade fracionada e arredondar o subtotal", func(t *testing.T) {
+		item := dominio.ItemNota{
+			Quantidade:    dominio.MustParseDecimal("2.5"),
+			PrecoUnitario: dominio.MustParseDecimal("3.99"),
+			Unidade:       "KG",
+		}
+
+		if err := item.Validar(); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		// 2.5 × 3.99 = 9.975, arredondado para o par
+		if subtotal := item.CalcularSubtotal(); subtotal.Formatar(2) != "9.98" {
+			t.Errorf("esperava subtotal 9.98, obteve %s", subtotal)
+		}
+	})
+
+	t.Run("deve rejeitar quantidade zero, preco negativo e subtotal acima do leiaute", func(t *testing.T) {
+		itens := []dominio.ItemNota{
+			{Quantidade: dominio.Decimal{}, PrecoUnitario: dominio.DecimalDeInteiro(1)},
+			{Quantidade: dominio.DecimalDeInteiro(1), PrecoUnitario: dominio.MustParseDecimal("-0.01")},
+			{Quantidade: dominio.MustParseDecimal("99999999999"), PrecoUnitario: dominio.MustParseDecimal("99999999999")},
+		}
+		for _, item := range itens {
+			if err := item.Validar(); err == nil {
+				t.Errorf("esperava erro para quantidade %s e preco %s", item.Quantidade, item.PrecoUnitario)
+			}
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package dominio
+
+import "strings"
+
+// SomenteDigitos remove pontuação de CNPJ, CPF, IE, CEP e telefone
+func SomenteDigitos(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// ValidarCNPJ confere tamanho e os dois dígitos verificadores módulo 11
+func ValidarCNPJ(cnpj string) bool {
+	if len(cnpj) != 14 || !apenasDigitos(cnpj) || repetido(cnpj) {
+		return false
+	}
+	pesos := []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	return dvModulo11(cnpj[:12], pesos[1:]) == int(cnpj[12]-'0') &&
+		dvModulo11(cnpj[:13], pesos) == int(cnpj[13]-'0')
+}
+
+// ValidarCPF confere tamanho e os dois dígitos verificadores módulo 11
+func ValidarCPF(cpf string) bool {
+	if len(cpf) != 11 || !apenasDigitos(cpf) || repetido(cpf) {
+		return false
+	}
+	pesos := []int{11, 10, 9, 8, 7, 6, 5, 4, 3, 2}
+	return dvModulo11(cpf[:9], pesos[1:]) == int(cpf[9]-'0') &&
+		dvModulo11(cpf[:10], pesos) == int(cpf[10]-'0')
+}
+
+// somaPonderada multiplica cada dígito pelo peso da mesma posição
+func somaPonderada(digitos string, pesos []int) int {
+	soma := 0
+	for i, p := range pesos {
+		soma += int(digitos[i]-'0') * p
+	}
+	return soma
+}
+
+// dvModulo11 é a regra mais comum: restos 0 e 1 resultam em DV 0
+func dvModulo11(digitos string, pesos []int) int {
+	resto := somaPonderada(digitos, pesos) % 11
+	if resto < 2 {
+		return 0
+	}
+	return 11 - resto
+}
+
+// pesosDecrescentes gera os pesos n, n-1, ..., 2
+func pesosDecrescentes(n int) []int {
+	pesos := make([]int, 0, n-1)
+	for p := n; p >= 2; p-- {
+		pesos = append(pesos, p)
+	}
+	return pesos
+}
+
+// repetido rejeita sequências como 00000000000 e 11111111111, que passam no DV
+func repetido(s string) bool {
+	return strings.Count(s, s[:1]) == len(s)
+}
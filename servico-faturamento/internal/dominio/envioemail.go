@@ -0,0 +1,107 @@
+package dominio
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Situação de cada envio do DANFE e do XML por email
+const (
+	StatusEmailPendente  = "PENDENTE"
+	StatusEmailEnviado   = "ENVIADO"
+	StatusEmailFalhou    = "FALHOU"    // tentativas esgotadas
+	StatusEmailDevolvido = "DEVOLVIDO" // recusado pelo servidor do destinatário (bounce)
+)
+
+// Origem do envio: a autorização da nota ou o reenvio pedido na API
+const (
+	OrigemEmailAutorizacao = "AUTORIZACAO"
+	OrigemEmailReenvio     = "REENVIO"
+)
+
+const (
+	// MaxTentativasEmail limita as tentativas de um envio antes de marcá-lo FALHOU
+	MaxTentativasEmail = 6
+	esperaInicialEmail = time.Minute
+	esperaMaximaEmail  = time.Hour
+)
+
+// EnvioEmail é a entrega dos documentos da nota a um destinatário. Cada
+// destinatário tem o próprio registro, com as tentativas e a devolução.
+type EnvioEmail struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	NotaID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"notaId"`
+	Destinatario     string     `gorm:"size:254;not null" json:"destinatario"`
+	Origem           string     `gorm:"size:20;not null" json:"origem"`
+	Status           string     `gorm:"size:20;not null;index:idx_envios_email_pendentes,priority:1" json:"status"`
+	Tentativas       int        `gorm:"not null;default:0" json:"tentativas"`
+	ProximaTentativa time.Time  `gorm:"not null;index:idx_envios_email_pendentes,priority:2" json:"proximaTentativa"`
+	UltimoErro       *string    `json:"ultimoErro,omitempty"`
+	MessageID        *string    `gorm:"column:message_id;size:255;index" json:"messageId,omitempty"`
+	DataEnvio        *time.Time `json:"dataEnvio,omitempty"`
+	DataDevolucao    *time.Time `json:"dataDevolucao,omitempty"`
+	MotivoDevolucao  *string    `json:"motivoDevolucao,omitempty"`
+	DataCriacao      time.Time  `gorm:"not null" json:"dataCriacao"`
+}
+
+func (e *EnvioEmail) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.DataCriacao.IsZero() {
+		e.DataCriacao = time.Now()
+	}
+	if e.Status == "" {
+		e.Status = StatusEmailPendente
+	}
+	if e.ProximaTentativa.IsZero() {
+		e.ProximaTentativa = e.DataCriacao
+	}
+	return nil
+}
+
+func (EnvioEmail) TableName() string {
+	return "envios_email"
+}
+
+// EsperaReenvioEmail é o intervalo até a próxima tentativa depois de tentativas
+// falhas: dobra a cada falha, de 1 minuto até o teto de 1 hora
+func EsperaReenvioEmail(tentativas int) time.Duration {
+	espera := esperaInicialEmail
+	for i := 1; i < tentativas && espera < esperaMaximaEmail; i++ {
+		espera *= 2
+	}
+	if espera > esperaMaximaEmail {
+		return esperaMaximaEmail
+	}
+	return espera
+}
+
+// RegistrarEnvio marca o envio como aceito pelo servidor SMTP
+func (e *EnvioEmail) RegistrarEnvio(messageID string, agora time.Time) {
+	e.Status = StatusEmailEnviado
+	e.MessageID = &messageID
+	e.DataEnvio = &agora
+	e.UltimoErro = nil
+}
+
+// RegistrarFalha agenda uma nova tentativa com espera crescente; esgotadas as
+// tentativas (já contadas em Tentativas) o envio fica FALHOU
+func (e *EnvioEmail) RegistrarFalha(erro string, agora time.Time) {
+	e.UltimoErro = &erro
+	if e.Tentativas >= MaxTentativasEmail {
+		e.Status = StatusEmailFalhou
+		return
+	}
+	e.Status = StatusEmailPendente
+	e.ProximaTentativa = agora.Add(EsperaReenvioEmail(e.Tentativas))
+}
+
+// RegistrarDevolucao marca o destinatário como recusado; não há nova tentativa
+func (e *EnvioEmail) RegistrarDevolucao(motivo string, agora time.Time) {
+	e.Status = StatusEmailDevolvido
+	e.MotivoDevolucao = &motivo
+	e.DataDevolucao = &agora
+}
@@ -0,0 +1,66 @@
+package dominio_test
+
+import (
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func TestEsperaReenvioEmail(t *testing.T) {
+	t.Run("deve dobrar a espera a cada falha ate o teto de 1 hora", func(t *testing.T) {
+		casos := map[int]time.Duration{
+			1:  time.Minute,
+			2:  2 * time.Minute,
+			3:  4 * time.Minute,
+			6:  32 * time.Minute,
+			7:  time.Hour,
+			30: time.Hour,
+		}
+		for tentativas, esperado := range casos {
+			if got := dominio.EsperaReenvioEmail(tentativas); got != esperado {
+				t.Errorf("tentativa %d: esperava %s, obteve %s", tentativas, esperado, got)
+			}
+		}
+	})
+}
+
+func TestEnvioEmail(t *testing.T) {
+	agora := time.Date(2026, 3, 10, 13, 0, 0, 0, dominio.FusoBrasilia)
+
+	t.Run("deve agendar nova tentativa enquanto houver tentativas", func(t *testing.T) {
+		envio := dominio.EnvioEmail{Status: dominio.StatusEmailPendente, Tentativas: 2}
+		envio.RegistrarFalha("421 servico indisponivel", agora)
+		if envio.Status != dominio.StatusEmailPendente {
+			t.Errorf("esperava PENDENTE, obteve %s", envio.Status)
+		}
+		if !envio.ProximaTentativa.Equal(agora.Add(2 * time.Minute)) {
+			t.Errorf("esperava nova tentativa em 2 minutos, obteve %s", envio.ProximaTentativa)
+		}
+		if envio.UltimoErro == nil || *envio.UltimoErro != "421 servico indisponivel" {
+			t.Errorf("esperava o erro registrado, obteve %v", envio.UltimoErro)
+		}
+	})
+
+	t.Run("deve falhar quando as tentativas se esgotam", func(t *testing.T) {
+		envio := dominio.EnvioEmail{Status: dominio.StatusEmailPendente, Tentativas: dominio.MaxTentativasEmail}
+		envio.RegistrarFalha("timeout", agora)
+		if envio.Status != dominio.StatusEmailFalhou {
+			t.Errorf("esperava FALHOU, obteve %s", envio.Status)
+		}
+	})
+
+	t.Run("deve registrar envio e devolucao", func(t *testing.T) {
+		envio := dominio.EnvioEmail{Status: dominio.StatusEmailPendente, Tentativas: 1}
+		envio.RegistrarFalha("timeout", agora)
+		envio.RegistrarEnvio("<abc@empresa.com.br>", agora)
+		if envio.Status != dominio.StatusEmailEnviado || envio.UltimoErro != nil || *envio.MessageID != "<abc@empresa.com.br>" {
+			t.Errorf("envio nao registrado: %+v", envio)
+		}
+
+		envio.RegistrarDevolucao("550 mailbox unavailable", agora.Add(time.Hour))
+		if envio.Status != dominio.StatusEmailDevolvido || *envio.MotivoDevolucao != "550 mailbox unavailable" {
+			t.Errorf("devolucao nao registrada: %+v", envio)
+		}
+	})
+}
@@ -1,18 +1,47 @@
 package dominio
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// Situação do evento no outbox
+const (
+	StatusOutboxPendente  = "PENDENTE"
+	StatusOutboxPublicado = "PUBLICADO"
+	StatusOutboxMorto     = "MORTO" // tentativas esgotadas ou evento impublicável; só volta reenfileirado
+)
+
+const (
+	// MaxTentativasOutbox limita as publicações falhas antes de o evento ficar MORTO
+	MaxTentativasOutbox = 10
+	esperaInicialOutbox = 15 * time.Second
+	esperaMaximaOutbox  = time.Hour
+)
+
+// ErrEventoNaoMorto indica reenfileiramento de evento que não está MORTO
+var ErrEventoNaoMorto = errors.New("somente eventos MORTO podem ser reenfileirados")
+
 type EventoOutbox struct {
 	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
 	TipoEvento     string     `gorm:"not null" json:"tipoEvento"`
-	IdAgregado     uuid.UUID  `gorm:"type:uuid;not null" json:"idAgregado"`
+	IdAgregado     uuid.UUID  `gorm:"type:uuid;not null;index:idx_eventos_outbox_agregado" json:"idAgregado"`
 	Payload        string     `gorm:"type:jsonb;not null" json:"payload"`
 	DataOcorrencia time.Time  `gorm:"not null" json:"dataOcorrencia"`
 	DataPublicacao *time.Time `json:"dataPublicacao,omitempty"`
+	Status         string     `gorm:"size:20;not null;default:PENDENTE;index" json:"status"`
+	Tentativas     int        `gorm:"not null;default:0" json:"tentativas"`
+	UltimoErro     *string    `gorm:"type:text" json:"ultimoErro,omitempty"`
+	// ProximaTentativa adia a publicação depois de uma falha; nil publica no próximo ciclo
+	ProximaTentativa *time.Time `json:"proximaTentativa,omitempty"`
+	DataMorte        *time.Time `json:"dataMorte,omitempty"`
+	// ReservadoPor e ReservadoAte são a reserva do publicador que está entregando o
+	// evento; vencida a reserva (publicador parado no meio), outro pode assumi-lo
+	ReservadoPor *string    `gorm:"size:100" json:"reservadoPor,omitempty"`
+	ReservadoAte *time.Time `json:"reservadoAte,omitempty"`
 }
 
 type MensagemProcessada struct {
@@ -24,6 +53,78 @@ func (EventoOutbox) TableName() string {
 	return "eventos_outbox"
 }
 
+func (e *EventoOutbox) BeforeCreate(tx *gorm.DB) error {
+	if e.Status == "" {
+		e.Status = StatusOutboxPendente
+	}
+	return nil
+}
+
+// EsperaReenvioOutbox é o intervalo até a próxima publicação depois de tentativas
+// falhas: dobra a cada falha, de 15 segundos até o teto de 1 hora
+func EsperaReenvioOutbox(tentativas int) time.Duration {
+	espera := esperaInicialOutbox
+	for i := 1; i < tentativas && espera < esperaMaximaOutbox; i++ {
+		espera *= 2
+	}
+	if espera > esperaMaximaOutbox {
+		return esperaMaximaOutbox
+	}
+	return espera
+}
+
+// RegistrarPublicacao marca o evento como entregue ao broker
+func (e *EventoOutbox) RegistrarPublicacao(agora time.Time) {
+	e.Status = StatusOutboxPublicado
+	e.DataPublicacao = &agora
+	e.ProximaTentativa = nil
+	e.liberarReserva()
+}
+
+// RegistrarFalha conta a tentativa e agenda a próxima com espera crescente. Falha
+// definitiva (evento impublicável) ou tentativas esgotadas deixam o evento MORTO.
+func (e *EventoOutbox) RegistrarFalha(erro string, definitiva bool, agora time.Time) {
+	e.Tentativas++
+	e.UltimoErro = &erro
+	e.liberarReserva()
+	if definitiva || e.Tentativas >= MaxTentativasOutbox {
+		e.Status = StatusOutboxMorto
+		e.DataMorte = &agora
+		e.ProximaTentativa = nil
+		return
+	}
+	proxima := agora.Add(EsperaReenvioOutbox(e.Tentativas))
+	e.ProximaTentativa = &proxima
+}
+
+// RegistrarFalhaConsumo devolve à publicação o evento já publicado que o
+// consumidor não conseguiu processar, com a mesma espera crescente e o mesmo
+// limite das falhas de publicação: esgotadas as tentativas, o evento fica MORTO
+func (e *EventoOutbox) RegistrarFalhaConsumo(erro string, agora time.Time) {
+	e.Status = StatusOutboxPendente
+	e.DataPublicacao = nil
+	e.RegistrarFalha(erro, false, agora)
+}
+
+// Reenfileirar devolve o evento MORTO à publicação com as tentativas zeradas; o
+// último erro fica como histórico
+func (e *EventoOutbox) Reenfileirar() error {
+	if e.Status != StatusOutboxMorto {
+		return ErrEventoNaoMorto
+	}
+	e.Status = StatusOutboxPendente
+	e.Tentativas = 0
+	e.ProximaTentativa = nil
+	e.DataMorte = nil
+	e.liberarReserva()
+	return nil
+}
+
+func (e *EventoOutbox) liberarReserva() {
+	e.ReservadoPor = nil
+	e.ReservadoAte = nil
+}
+
 func (MensagemProcessada) TableName() string {
 	return "mensagens_processadas"
 }
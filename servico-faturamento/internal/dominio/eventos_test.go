@@ -0,0 +1,132 @@
+package dominio_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func TestEsperaReenvioOutbox(t *testing.T) {
+	t.Run("deve dobrar a espera a cada falha ate o teto de 1 hora", func(t *testing.T) {
+		casos := map[int]time.Duration{
+			1:  15 * time.Second,
+			2:  30 * time.Second,
+			5:  4 * time.Minute,
+			8:  32 * time.Minute,
+			9:  time.Hour,
+			50: time.Hour,
+		}
+		for tentativas, esperado := range casos {
+			if got := dominio.EsperaReenvioOutbox(tentativas); got != esperado {
+				t.Errorf("tentativa %d: esperava %s, obteve %s", tentativas, esperado, got)
+			}
+		}
+	})
+}
+
+func TestEventoOutbox(t *testing.T) {
+	agora := time.Date(2026, 3, 10, 13, 0, 0, 0, dominio.FusoBrasilia)
+
+	t.Run("deve agendar nova publicacao depois de falha temporaria", func(t *testing.T) {
+		evento := dominio.EventoOutbox{Status: dominio.StatusOutboxPendente, Tentativas: 1}
+		evento.RegistrarFalha("channel/connection is not open", false, agora)
+		if evento.Status != dominio.StatusOutboxPendente || evento.Tentativas != 2 {
+			t.Fatalf("esperava PENDENTE com 2 tentativas, obteve %s com %d", evento.Status, evento.Tentativas)
+		}
+		if evento.ProximaTentativa == nil || !evento.ProximaTentativa.Equal(agora.Add(30*time.Second)) {
+			t.Errorf("esperava nova tentativa em 30s, obteve %v", evento.ProximaTentativa)
+		}
+		if evento.UltimoErro == nil || *evento.UltimoErro != "channel/connection is not open" {
+			t.Errorf("ultimo erro nao registrado: %v", evento.UltimoErro)
+		}
+	})
+
+	t.Run("deve marcar MORTO ao esgotar as tentativas", func(t *testing.T) {
+		evento := dominio.EventoOutbox{Status: dominio.StatusOutboxPendente, Tentativas: dominio.MaxTentativasOutbox - 1}
+		evento.RegistrarFalha("timeout", false, agora)
+		if evento.Status != dominio.StatusOutboxMorto {
+			t.Fatalf("esperava MORTO, obteve %s", evento.Status)
+		}
+		if evento.ProximaTentativa != nil || evento.DataMorte == nil || !evento.DataMorte.Equal(agora) {
+			t.Errorf("evento morto com proxima tentativa %v e data %v", evento.ProximaTentativa, evento.DataMorte)
+		}
+	})
+
+	t.Run("deve marcar MORTO na primeira falha definitiva", func(t *testing.T) {
+		evento := dominio.EventoOutbox{Status: dominio.StatusOutboxPendente}
+		evento.RegistrarFalha("payload invalido", true, agora)
+		if evento.Status != dominio.StatusOutboxMorto || evento.Tentativas != 1 {
+			t.Errorf("esperava MORTO com 1 tentativa, obteve %s com %d", evento.Status, evento.Tentativas)
+		}
+	})
+
+	t.Run("deve devolver a publicacao o evento que o consumidor nao processou", func(t *testing.T) {
+		publicado := agora.Add(-time.Minute)
+		evento := dominio.EventoOutbox{Status: dominio.StatusOutboxPublicado, DataPublicacao: &publicado}
+		evento.RegistrarFalhaConsumo("SEFAZ indisponivel", agora)
+		if evento.Status != dominio.StatusOutboxPendente || evento.DataPublicacao != nil || evento.Tentativas != 1 {
+			t.Fatalf("esperava PENDENTE sem publicacao e com 1 tentativa, obteve %+v", evento)
+		}
+		if evento.ProximaTentativa == nil || !evento.ProximaTentativa.Equal(agora.Add(15*time.Second)) {
+			t.Errorf("esperava nova tentativa em 15s, obteve %v", evento.ProximaTentativa)
+		}
+	})
+
+	t.Run("deve marcar MORTO o evento que o consumidor falhou em todas as tentativas", func(t *testing.T) {
+		evento := dominio.EventoOutbox{Status: dominio.StatusOutboxPublicado, Tentativas: dominio.MaxTentativasOutbox - 1}
+		evento.RegistrarFalhaConsumo("SEFAZ indisponivel", agora)
+		if evento.Status != dominio.StatusOutboxMorto || evento.DataMorte == nil {
+			t.Errorf("esperava MORTO, obteve %+v", evento)
+		}
+	})
+
+	t.Run("deve reenfileirar evento morto mantendo o ultimo erro", func(t *testing.T) {
+		evento := dominio.EventoOutbox{Status: dominio.StatusOutboxPendente}
+		evento.RegistrarFalha("payload invalido", true, agora)
+		if err := evento.Reenfileirar(); err != nil {
+			t.Fatalf("Reenfileirar() erro = %v", err)
+		}
+		if evento.Status != dominio.StatusOutboxPendente || evento.Tentativas != 0 || evento.DataMorte != nil || evento.ProximaTentativa != nil {
+			t.Errorf("evento reenfileirado inconsistente: %+v", evento)
+		}
+		if evento.UltimoErro == nil {
+			t.Error("esperava manter o ultimo erro como historico")
+		}
+	})
+
+	t.Run("deve recusar reenfileirar evento que nao esta morto", func(t *testing.T) {
+		for _, status := range []string{dominio.StatusOutboxPendente, dominio.StatusOutboxPublicado} {
+			evento := dominio.EventoOutbox{Status: status}
+			if err := evento.Reenfileirar(); !errors.Is(err, dominio.ErrEventoNaoMorto) {
+				t.Errorf("status %s: esperava ErrEventoNaoMorto, obteve %v", status, err)
+			}
+		}
+	})
+
+	t.Run("deve registrar a publicacao", func(t *testing.T) {
+		proxima := agora.Add(time.Minute)
+		evento := dominio.EventoOutbox{Status: dominio.StatusOutboxPendente, Tentativas: 3, ProximaTentativa: &proxima}
+		evento.RegistrarPublicacao(agora)
+		if evento.Status != dominio.StatusOutboxPublicado || evento.DataPublicacao == nil || evento.ProximaTentativa != nil {
+			t.Errorf("publicacao nao registrada: %+v", evento)
+		}
+	})
+
+	t.Run("deve liberar a reserva ao registrar o resultado", func(t *testing.T) {
+		publicador, ate := "api-1", agora.Add(2*time.Minute)
+		registros := map[string]func(*dominio.EventoOutbox){
+			"publicacao": func(e *dominio.EventoOutbox) { e.RegistrarPublicacao(agora) },
+			"falha":      func(e *dominio.EventoOutbox) { e.RegistrarFalha("timeout", false, agora) },
+			"morte":      func(e *dominio.EventoOutbox) { e.RegistrarFalha("payload invalido", true, agora) },
+		}
+		for nome, registrar := range registros {
+			evento := dominio.EventoOutbox{Status: dominio.StatusOutboxPendente, ReservadoPor: &publicador, ReservadoAte: &ate}
+			registrar(&evento)
+			if evento.ReservadoPor != nil || evento.ReservadoAte != nil {
+				t.Errorf("%s: reserva mantida: %v ate %v", nome, evento.ReservadoPor, evento.ReservadoAte)
+			}
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package dominio
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Classificação padrão do IBS/CBS: tributação integral (CST 000, cClassTrib 000001)
+const (
+	CSTIBSCBSPadrao  = "000"
+	CClassTribPadrao = "000001"
+)
+
+// CSTs de IBS/CBS que o motor sabe calcular: 000 com as alíquotas do período e
+// 410 (imunidade e não incidência), informado sem valores
+var (
+	cstIBSCBSTributado = map[string]bool{"000": true}
+	cstIBSCBSSemValor  = map[string]bool{"410": true}
+)
+
+// AliquotaIBSCBS define as alíquotas de IBS (estadual e municipal) e CBS de um
+// período. Na fase de teste de 2026 são 0,1% de IBS estadual e 0,9% de CBS; a
+// tabela permite acompanhar a transição sem deploy.
+type AliquotaIBSCBS struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	Descricao      string     `gorm:"size:120;not null" json:"descricao"`
+	VigenciaInicio time.Time  `gorm:"not null;index" json:"vigenciaInicio"`
+	VigenciaFim    *time.Time `json:"vigenciaFim,omitempty"` // exclusivo
+	PIBSUF         Decimal    `gorm:"column:p_ibs_uf;type:numeric(7,4);not null;default:0" json:"pIBSUF"`
+	PIBSMun        Decimal    `gorm:"column:p_ibs_mun;type:numeric(7,4);not null;default:0" json:"pIBSMun"`
+	PCBS           Decimal    `gorm:"column:p_cbs;type:numeric(7,4);not null;default:0" json:"pCBS"`
+
+	DataCriacao     time.Time `gorm:"not null" json:"dataCriacao"`
+	DataAtualizacao time.Time `gorm:"not null" json:"dataAtualizacao"`
+}
+
+func (a *AliquotaIBSCBS) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.DataCriacao.IsZero() {
+		a.DataCriacao = time.Now()
+	}
+	return nil
+}
+
+func (a *AliquotaIBSCBS) BeforeSave(tx *gorm.DB) error {
+	a.DataAtualizacao = time.Now()
+	return nil
+}
+
+func (AliquotaIBSCBS) TableName() string {
+	return "aliquotas_ibs_cbs"
+}
+
+// Validar confere a vigência e as alíquotas do período
+func (a *AliquotaIBSCBS) Validar() error {
+	var erros ErrosCadastro
+	erros.texto("descricao", a.Descricao, 2, 120, true)
+	if a.VigenciaInicio.IsZero() {
+		erros.add("vigenciaInicio obrigatoria")
+	}
+	if a.VigenciaFim != nil && !a.VigenciaFim.After(a.VigenciaInicio) {
+		erros.add("vigenciaFim deve ser posterior a vigenciaInicio")
+	}
+	for _, aliquota := range []struct {
+		campo string
+		valor Decimal
+	}{{"pIBSUF", a.PIBSUF}, {"pIBSMun", a.PIBSMun}, {"pCBS", a.PCBS}} {
+		if aliquota.valor.Sinal() < 0 || aliquota.valor.Cmp(cemPorCento) > 0 {
+			erros.add("%s deve estar entre 0 e 100", aliquota.campo)
+		}
+	}
+
+	if len(erros) > 0 {
+		return erros
+	}
+	return nil
+}
+
+// Vigente indica se o período cobre a data
+func (a *AliquotaIBSCBS) Vigente(data time.Time) bool {
+	return !data.Before(a.VigenciaInicio) && (a.VigenciaFim == nil || data.Before(*a.VigenciaFim))
+}
+
+// SelecionarAliquotaIBSCBS devolve o período vigente na data com início mais recente, ou nil
+func SelecionarAliquotaIBSCBS(periodos []AliquotaIBSCBS, data time.Time) *AliquotaIBSCBS {
+	var escolhido *AliquotaIBSCBS
+	for i := range periodos {
+		p := &periodos[i]
+		if p.Vigente(data) && (escolhido == nil || p.VigenciaInicio.After(escolhido.VigenciaInicio)) {
+			escolhido = p
+		}
+	}
+	return escolhido
+}
+
+// validarClassificacaoIBSCBS confere o CST e o cClassTrib da regra; o cClassTrib
+// começa pelo CST a que pertence
+func validarClassificacaoIBSCBS(erros *ErrosCadastro, cst, cClassTrib string) {
+	if cst != "" && !cstIBSCBSTributado[cst] && !cstIBSCBSSemValor[cst] {
+		erros.add("cstIBSCBS %q nao suportado (use 000 ou 410)", cst)
+	}
+	if cClassTrib == "" {
+		if cstIBSCBSSemValor[cst] {
+			erros.add("cstIBSCBS %s exige cClassTrib", cst)
+		}
+		return
+	}
+	if len(cClassTrib) != 6 || !apenasDigitos(cClassTrib) {
+		erros.add("cClassTrib deve ter 6 digitos")
+		return
+	}
+	if cst == "" {
+		cst = CSTIBSCBSPadrao
+	}
+	if cClassTrib[:3] != cst {
+		erros.add("cClassTrib %s nao pertence ao cstIBSCBS %s", cClassTrib, cst)
+	}
+}
+
+// calcularIBSCBS preenche os grupos de IBS e CBS do item. A base é o valor do
+// produto sem ICMS, PIS e COFINS, como na transição prevista pela LC 214/2025;
+// o Simples Nacional fica de fora enquanto o destaque for opcional ao regime.
+func calcularIBSCBS(t *TributosItem, vProd Decimal, r RegraTributaria, op OperacaoTributaria) {
+	if op.AliquotaIBSCBS == nil || op.Regime == RegimeSimplesNacional {
+		return
+	}
+
+	t.CSTIBSCBS, t.CClassTrib = r.CSTIBSCBS, r.CClassTrib
+	if t.CSTIBSCBS == "" {
+		t.CSTIBSCBS = CSTIBSCBSPadrao
+	}
+	if t.CClassTrib == "" {
+		t.CClassTrib = CClassTribPadrao
+	}
+	if !cstIBSCBSTributado[t.CSTIBSCBS] {
+		return
+	}
+
+	aliquotas := op.AliquotaIBSCBS
+	base := vProd.Sub(t.VICMS).Sub(t.VPIS).Sub(t.VCOFINS)
+	t.VBCIBSCBS = base
+	t.PIBSUF, t.VIBSUF = aliquotas.PIBSUF, base.Percentual(aliquotas.PIBSUF, 2)
+	t.PIBSMun, t.VIBSMun = aliquotas.PIBSMun, base.Percentual(aliquotas.PIBSMun, 2)
+	t.PCBS, t.VCBS = aliquotas.PCBS, base.Percentual(aliquotas.PCBS, 2)
+}
+
+// IBSCBSTributado indica item com o grupo gIBSCBS (base, alíquotas e valores)
+func (t TributosItem) IBSCBSTributado() bool {
+	return cstIBSCBSTributado[t.CSTIBSCBS]
+}
@@ -0,0 +1,125 @@
+package dominio_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func periodoTeste2026() dominio.AliquotaIBSCBS {
+	fim := time.Date(2027, 1, 1, 0, 0, 0, 0, dominio.FusoBrasilia)
+	return dominio.AliquotaIBSCBS{
+		Descricao:      "Fase de teste 2026",
+		VigenciaInicio: time.Date(2026, 1, 1, 0, 0, 0, 0, dominio.FusoBrasilia),
+		VigenciaFim:    &fim,
+		PIBSUF:         dominio.MustParseDecimal("0.1"),
+		PCBS:           dominio.MustParseDecimal("0.9"),
+	}
+}
+
+func TestSelecionarAliquotaIBSCBS(t *testing.T) {
+	t.Run("deve escolher o periodo vigente com inicio mais recente", func(t *testing.T) {
+		teste := periodoTeste2026()
+		revisao := periodoTeste2026()
+		revisao.Descricao = "Revisao julho"
+		revisao.VigenciaInicio = time.Date(2026, 7, 1, 0, 0, 0, 0, dominio.FusoBrasilia)
+		periodos := []dominio.AliquotaIBSCBS{teste, revisao}
+
+		if p := dominio.SelecionarAliquotaIBSCBS(periodos, time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)); p == nil || p.Descricao != teste.Descricao {
+			t.Errorf("esperava %s em marco, obteve %+v", teste.Descricao, p)
+		}
+		if p := dominio.SelecionarAliquotaIBSCBS(periodos, time.Date(2026, 8, 1, 12, 0, 0, 0, time.UTC)); p == nil || p.Descricao != revisao.Descricao {
+			t.Errorf("esperava %s em agosto, obteve %+v", revisao.Descricao, p)
+		}
+		if p := dominio.SelecionarAliquotaIBSCBS(periodos, time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC)); p != nil {
+			t.Errorf("esperava nenhum periodo em 2025, obteve %s", p.Descricao)
+		}
+	})
+}
+
+func TestCalcularIBSCBS(t *testing.T) {
+	periodo := periodoTeste2026()
+	op := operacaoNormal()
+	op.Data = time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	op.AliquotaIBSCBS = &periodo
+
+	t.Run("deve calcular IBS e CBS sobre o valor sem ICMS, PIS e COFINS", func(t *testing.T) {
+		regra := dominio.RegraTributaria{
+			CSTICMS: "00", PICMS: dominio.MustParseDecimal("18"),
+			CSTPIS: "01", PPIS: dominio.MustParseDecimal("1.65"),
+			CSTCOFINS: "01", PCOFINS: dominio.MustParseDecimal("7.6"),
+		}
+
+		tributos := dominio.CalcularTributosItem(itemTributacao("1000"), &regra, op)
+
+		// 1000 - 180 (ICMS) - 13.53 (PIS) - 62.32 (COFINS)
+		if tributos.VBCIBSCBS.Formatar(2) != "744.15" {
+			t.Fatalf("esperava base 744.15, obteve %s", tributos.VBCIBSCBS)
+		}
+		if tributos.VIBSUF.Formatar(2) != "0.74" || !tributos.VIBSMun.IsZero() || tributos.VCBS.Formatar(2) != "6.70" {
+			t.Errorf("valores inesperados: IBS UF %s IBS Mun %s CBS %s", tributos.VIBSUF, tributos.VIBSMun, tributos.VCBS)
+		}
+		if tributos.CSTIBSCBS != "000" || tributos.CClassTrib != "000001" {
+			t.Errorf("esperava classificacao padrao 000/000001, obteve %s/%s", tributos.CSTIBSCBS, tributos.CClassTrib)
+		}
+	})
+
+	t.Run("deve somar IBS e CBS nos totais sem alterar o vNF", func(t *testing.T) {
+		nota := dominio.NotaFiscal{Itens: []dominio.ItemNota{itemTributacao("100"), itemTributacao("50")}}
+
+		nota.CalcularTributos(nil, op)
+		totais := nota.CalcularTotais()
+
+		if totais.VBCIBSCBS.Formatar(2) != "150.00" || totais.VIBS.Formatar(2) != "0.15" || totais.VCBS.Formatar(2) != "1.35" {
+			t.Errorf("totais inesperados: vBC %s vIBS %s vCBS %s", totais.VBCIBSCBS, totais.VIBS, totais.VCBS)
+		}
+		if totais.VNF.Formatar(2) != "150.00" {
+			t.Errorf("esperava vNF 150.00, obteve %s", totais.VNF)
+		}
+	})
+
+	t.Run("deve informar CST 410 sem valores", func(t *testing.T) {
+		regra := dominio.RegraTributaria{CSTIBSCBS: "410", CClassTrib: "410001"}
+
+		tributos := dominio.CalcularTributosItem(itemTributacao("100"), &regra, op)
+
+		if tributos.CSTIBSCBS != "410" || tributos.IBSCBSTributado() || !tributos.VBCIBSCBS.IsZero() {
+			t.Errorf("esperava CST 410 sem base, obteve %+v", tributos)
+		}
+	})
+
+	t.Run("deve dispensar o grupo sem periodo vigente ou no Simples Nacional", func(t *testing.T) {
+		semPeriodo := op
+		semPeriodo.AliquotaIBSCBS = nil
+		simples := op
+		simples.Regime = dominio.RegimeSimplesNacional
+
+		for _, operacao := range []dominio.OperacaoTributaria{semPeriodo, simples} {
+			if tributos := dominio.CalcularTributosItem(itemTributacao("100"), nil, operacao); tributos.CSTIBSCBS != "" {
+				t.Errorf("esperava item sem IBS/CBS, obteve CST %s", tributos.CSTIBSCBS)
+			}
+		}
+	})
+}
+
+func TestAliquotaIBSCBS_Validar(t *testing.T) {
+	t.Run("deve exigir vigencia e aliquotas entre 0 e 100", func(t *testing.T) {
+		periodo := dominio.AliquotaIBSCBS{Descricao: "Invalido", PCBS: dominio.MustParseDecimal("-1")}
+
+		err := periodo.Validar()
+
+		if err == nil || !strings.Contains(err.Error(), "vigenciaInicio") || !strings.Contains(err.Error(), "pCBS") {
+			t.Errorf("esperava erros de vigenciaInicio e pCBS, obteve %v", err)
+		}
+	})
+
+	t.Run("deve conferir o cClassTrib da regra contra o CST", func(t *testing.T) {
+		regra := dominio.RegraTributaria{Descricao: "Imunidade", CSTIBSCBS: "410", CClassTrib: "000001"}
+
+		if err := regra.Validar(); err == nil || !strings.Contains(err.Error(), "cClassTrib") {
+			t.Errorf("esperava erro de cClassTrib, obteve %v", err)
+		}
+	})
+}
@@ -0,0 +1,292 @@
+package dominio
+
+import (
+	"strconv"
+	"strings"
+)
+
+// IEIsento é o valor aceito no lugar da IE por contribuintes dispensados de inscrição
+const IEIsento = "ISENTO"
+
+// ValidarIE aplica o tamanho, o prefixo e o cálculo de DV da inscrição estadual
+// de cada UF, conforme as rotinas publicadas pelo Sintegra
+func ValidarIE(uf, ie string) bool {
+	if strings.EqualFold(ie, IEIsento) {
+		return true
+	}
+	if !apenasDigitos(ie) {
+		return false
+	}
+	regra, ok := regrasIE[strings.ToUpper(uf)]
+	if !ok {
+		return false
+	}
+	return regra(ie)
+}
+
+var regrasIE = map[string]func(string) bool{
+	"AC": ieAC, "AL": ieAL, "AP": ieAP, "AM": ieAM, "BA": ieBA,
+	"CE": ieModulo11(9, ""), "DF": ieDF, "ES": ieModulo11(9, ""), "GO": ieGO,
+	"MA": ieModulo11(9, "12"), "MT": ieMT, "MS": ieMS, "MG": ieMG, "PA": ieModulo11(9, "15"),
+	"PB": ieModulo11(9, ""), "PR": iePR, "PE": iePE, "PI": ieModulo11(9, "19"),
+	"RJ": ieRJ, "RN": ieRN, "RS": ieRS, "RO": ieRO, "RR": ieRR,
+	"SC": ieModulo11(9, ""), "SP": ieSP, "SE": ieModulo11(9, ""), "TO": ieTO,
+}
+
+// ieModulo11 cobre as UFs com pesos n..2 sobre os dígitos antes do DV e
+// DV = 11 - resto, com 10 e 11 resultando em 0
+func ieModulo11(tamanho int, prefixo string) func(string) bool {
+	return func(ie string) bool {
+		if len(ie) != tamanho || !strings.HasPrefix(ie, prefixo) {
+			return false
+		}
+		n := tamanho - 1
+		return dvModulo11(ie[:n], pesosDecrescentes(tamanho)) == digito(ie, n)
+	}
+}
+
+func digito(s string, i int) int {
+	return int(s[i] - '0')
+}
+
+// ieAC e ieDF: 13 dígitos com dois DVs
+func ieAC(ie string) bool {
+	return len(ie) == 13 && strings.HasPrefix(ie, "01") && doisDVsTreze(ie)
+}
+
+func ieDF(ie string) bool {
+	return len(ie) == 13 && (strings.HasPrefix(ie, "07") || strings.HasPrefix(ie, "08")) && doisDVsTreze(ie)
+}
+
+func doisDVsTreze(ie string) bool {
+	pesos := []int{5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2}
+	return dvModulo11(ie[:11], pesos[1:]) == digito(ie, 11) &&
+		dvModulo11(ie[:12], pesos) == digito(ie, 12)
+}
+
+func ieAL(ie string) bool {
+	if len(ie) != 9 || !strings.HasPrefix(ie, "24") || !strings.ContainsRune("03578", rune(ie[2])) {
+		return false
+	}
+	dv := somaPonderada(ie[:8], pesosDecrescentes(9)) * 10 % 11
+	if dv == 10 {
+		dv = 0
+	}
+	return dv == digito(ie, 8)
+}
+
+func ieAP(ie string) bool {
+	if len(ie) != 9 || !strings.HasPrefix(ie, "03") {
+		return false
+	}
+	numero, _ := strconv.Atoi(ie[:8])
+	p, d := 0, 0
+	switch {
+	case numero <= 3017000:
+		p, d = 5, 0
+	case numero <= 3019022:
+		p, d = 9, 1
+	}
+	dv := 11 - (p+somaPonderada(ie[:8], pesosDecrescentes(9)))%11
+	switch dv {
+	case 10:
+		dv = 0
+	case 11:
+		dv = d
+	}
+	return dv == digito(ie, 8)
+}
+
+// ieAM: com soma menor que 11 o DV é a diferença para 11
+func ieAM(ie string) bool {
+	if len(ie) != 9 {
+		return false
+	}
+	soma := somaPonderada(ie[:8], pesosDecrescentes(9))
+	if soma < 11 {
+		return 11-soma == digito(ie, 8)
+	}
+	return dvModulo11(ie[:8], pesosDecrescentes(9)) == digito(ie, 8)
+}
+
+// ieBA: 8 ou 9 dígitos; o segundo DV é calculado primeiro e o módulo (10 ou 11)
+// depende do primeiro dígito (8 posições) ou do segundo (9 posições)
+func ieBA(ie string) bool {
+	if len(ie) != 8 && len(ie) != 9 {
+		return false
+	}
+	base := ie[:len(ie)-2]
+	chave := ie[0]
+	if len(ie) == 9 {
+		chave = ie[1]
+	}
+	modulo := 10
+	if strings.ContainsRune("679", rune(chave)) {
+		modulo = 11
+	}
+
+	dv := func(digitos string) int {
+		soma := somaPonderada(digitos, pesosDecrescentes(len(digitos)+1))
+		resto := soma % modulo
+		if modulo == 10 {
+			if resto == 0 {
+				return 0
+			}
+			return 10 - resto
+		}
+		if resto < 2 {
+			return 0
+		}
+		return 11 - resto
+	}
+
+	dv2 := dv(base)
+	dv1 := dv(base + strconv.Itoa(dv2))
+	return dv1 == digito(ie, len(ie)-2) && dv2 == digito(ie, len(ie)-1)
+}
+
+func ieGO(ie string) bool {
+	if len(ie) != 9 || !strings.ContainsAny(ie[:1], "12") {
+		return false
+	}
+	prefixo := ie[:2]
+	if prefixo != "10" && prefixo != "11" && prefixo != "15" && ie[0] != '2' {
+		return false
+	}
+	resto := somaPonderada(ie[:8], pesosDecrescentes(9)) % 11
+	numero, _ := strconv.Atoi(ie[:8])
+	dv := 11 - resto
+	switch resto {
+	case 0:
+		dv = 0
+	case 1:
+		dv = 0
+		if numero >= 10103105 && numero <= 10119997 {
+			dv = 1
+		}
+	}
+	return dv == digito(ie, 8)
+}
+
+func ieMT(ie string) bool {
+	if len(ie) > 11 || len(ie) < 9 {
+		return false
+	}
+	ie = strings.Repeat("0", 11-len(ie)) + ie
+	return dvModulo11(ie[:10], []int{3, 2, 9, 8, 7, 6, 5, 4, 3, 2}) == digito(ie, 10)
+}
+
+func ieMS(ie string) bool {
+	if len(ie) != 9 || (!strings.HasPrefix(ie, "28") && !strings.HasPrefix(ie, "50")) {
+		return false
+	}
+	return dvModulo11(ie[:8], pesosDecrescentes(9)) == digito(ie, 8)
+}
+
+// ieMG: o primeiro DV insere 0 após o código do município e soma os algarismos
+// dos produtos pelos pesos 1 e 2 alternados
+func ieMG(ie string) bool {
+	if len(ie) != 13 {
+		return false
+	}
+	base := ie[:3] + "0" + ie[3:11]
+	soma := 0
+	for i := range base {
+		produto := digito(base, i) * (1 + i%2)
+		soma += produto/10 + produto%10
+	}
+	dv1 := (10 - soma%10) % 10
+	if dv1 != digito(ie, 11) {
+		return false
+	}
+	return dvModulo11(ie[:12], []int{3, 2, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2}) == digito(ie, 12)
+}
+
+func iePR(ie string) bool {
+	if len(ie) != 10 {
+		return false
+	}
+	pesos := []int{4, 3, 2, 7, 6, 5, 4, 3, 2}
+	return dvModulo11(ie[:8], pesos[1:]) == digito(ie, 8) &&
+		dvModulo11(ie[:9], pesos) == digito(ie, 9)
+}
+
+// iePE aceita a inscrição do e-Fisco (9 dígitos) e o antigo CACEPE (14 dígitos)
+func iePE(ie string) bool {
+	switch len(ie) {
+	case 9:
+		return dvModulo11(ie[:7], pesosDecrescentes(8)) == digito(ie, 7) &&
+			dvModulo11(ie[:8], pesosDecrescentes(9)) == digito(ie, 8)
+	case 14:
+		dv := 11 - somaPonderada(ie[:13], []int{5, 4, 3, 2, 1, 9, 8, 7, 6, 5, 4, 3, 2})%11
+		if dv > 9 {
+			dv -= 10
+		}
+		return dv == digito(ie, 13)
+	}
+	return false
+}
+
+func ieRJ(ie string) bool {
+	return len(ie) == 8 && dvModulo11(ie[:7], []int{2, 7, 6, 5, 4, 3, 2}) == digito(ie, 7)
+}
+
+func ieRN(ie string) bool {
+	if (len(ie) != 9 && len(ie) != 10) || !strings.HasPrefix(ie, "20") {
+		return false
+	}
+	n := len(ie) - 1
+	dv := somaPonderada(ie[:n], pesosDecrescentes(len(ie))) * 10 % 11
+	if dv == 10 {
+		dv = 0
+	}
+	return dv == digito(ie, n)
+}
+
+func ieRS(ie string) bool {
+	return len(ie) == 10 && dvModulo11(ie[:9], []int{2, 9, 8, 7, 6, 5, 4, 3, 2}) == digito(ie, 9)
+}
+
+func ieRO(ie string) bool {
+	if len(ie) != 14 {
+		return false
+	}
+	dv := 11 - somaPonderada(ie[:13], []int{6, 5, 4, 3, 2, 9, 8, 7, 6, 5, 4, 3, 2})%11
+	if dv > 9 {
+		dv -= 10
+	}
+	return dv == digito(ie, 13)
+}
+
+func ieRR(ie string) bool {
+	if len(ie) != 9 || !strings.HasPrefix(ie, "24") {
+		return false
+	}
+	return somaPonderada(ie[:8], []int{1, 2, 3, 4, 5, 6, 7, 8})%9 == digito(ie, 8)
+}
+
+// ieSP cobre comércio e indústria (12 dígitos); os DVs são o último algarismo do resto
+func ieSP(ie string) bool {
+	if len(ie) != 12 {
+		return false
+	}
+	dv1 := somaPonderada(ie[:8], []int{1, 3, 4, 5, 6, 7, 8, 10}) % 11 % 10
+	dv2 := somaPonderada(ie[:11], []int{3, 2, 10, 9, 8, 7, 6, 5, 4, 3, 2}) % 11 % 10
+	return dv1 == digito(ie, 8) && dv2 == digito(ie, 11)
+}
+
+// ieTO aceita o formato atual (9 dígitos) e o antigo (11 dígitos, com a
+// categoria 01, 02, 03 ou 99 nas posições 3 e 4, fora do cálculo)
+func ieTO(ie string) bool {
+	switch len(ie) {
+	case 9:
+		return dvModulo11(ie[:8], pesosDecrescentes(9)) == digito(ie, 8)
+	case 11:
+		switch ie[2:4] {
+		case "01", "02", "03", "99":
+			base := ie[:2] + ie[4:10]
+			return dvModulo11(base, pesosDecrescentes(9)) == digito(ie, 10)
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package dominio
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Inutilizacao registra uma faixa de nNF descartada na SEFAZ (inutNFe). Os números
+// da faixa nunca são alocados pela numeração e deixam de aparecer como lacuna.
+type Inutilizacao struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	EmitenteID    *uuid.UUID `gorm:"type:uuid;index" json:"emitenteId,omitempty"`
+	CNPJEmitente  string     `gorm:"column:cnpj_emitente;size:14;not null;index:idx_inutilizacoes_serie,priority:1" json:"cnpjEmitente"`
+	Modelo        string     `gorm:"size:2;not null;index:idx_inutilizacoes_serie,priority:2" json:"modelo"`
+	Serie         int        `gorm:"not null;index:idx_inutilizacoes_serie,priority:3" json:"serie"`
+	NumeroInicial int64      `gorm:"not null" json:"numeroInicial"`
+	NumeroFinal   int64      `gorm:"not null" json:"numeroFinal"`
+	Ano           string     `gorm:"size:2;not null" json:"ano"` // AA do pedido, ano do registro
+	Justificativa string     `gorm:"size:255;not null" json:"justificativa"`
+	DataRegistro  time.Time  `gorm:"not null" json:"dataRegistro"`
+	XML           string     `gorm:"column:xml;type:text;not null" json:"-"`
+}
+
+func (Inutilizacao) TableName() string {
+	return "inutilizacoes"
+}
+
+func (i *Inutilizacao) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	if i.DataRegistro.IsZero() {
+		i.DataRegistro = time.Now()
+	}
+	if i.Ano == "" {
+		i.Ano = i.DataRegistro.In(FusoBrasilia).Format("06")
+	}
+	return nil
+}
+
+// Validar confere modelo, série, faixa e justificativa do pedido
+func (i *Inutilizacao) Validar() error {
+	i.CNPJEmitente = SomenteDigitos(i.CNPJEmitente)
+	i.Justificativa = strings.Join(strings.Fields(i.Justificativa), " ")
+
+	var erros ErrosCadastro
+	if !ValidarCNPJ(i.CNPJEmitente) {
+		erros.add("cnpjEmitente invalido")
+	}
+	if i.Modelo != "55" && i.Modelo != "65" {
+		erros.add("modelo deve ser 55 ou 65")
+	}
+	if i.Serie < 0 || i.Serie > 999 {
+		erros.add("serie deve estar entre 0 e 999")
+	}
+	if i.NumeroInicial < 1 || i.NumeroFinal > NumeroMaximoNF || i.NumeroInicial > i.NumeroFinal {
+		erros.add("faixa deve ter numeroInicial <= numeroFinal, entre 1 e %d", NumeroMaximoNF)
+	}
+	erros.texto("justificativa", i.Justificativa, JustificativaMinima, JustificativaMaxima, true)
+
+	if len(erros) > 0 {
+		return erros
+	}
+	return nil
+}
+
+// Contem indica se o número está na faixa inutilizada
+func (i *Inutilizacao) Contem(numero int64) bool {
+	return numero >= i.NumeroInicial && numero <= i.NumeroFinal
+}
+
+// FaixaNumeracao é um intervalo fechado de nNF
+type FaixaNumeracao struct {
+	Inicio     int64 `json:"inicio"`
+	Fim        int64 `json:"fim"`
+	Quantidade int64 `json:"quantidade"`
+}
+
+// CalcularLacunas devolve os números de 1 a ultimo fora de todas as faixas ocupadas
+// (notas emitidas e inutilizações), que precisam ser inutilizados. As faixas devem
+// vir ordenadas pelo início e podem se sobrepor.
+func CalcularLacunas(ocupadas []FaixaNumeracao, ultimo int64) []FaixaNumeracao {
+	lacunas := []FaixaNumeracao{}
+	proximo := int64(1)
+	for _, f := range ocupadas {
+		if proximo > ultimo {
+			break
+		}
+		if f.Inicio > proximo {
+			fim := f.Inicio - 1
+			if fim > ultimo {
+				fim = ultimo
+			}
+			lacunas = append(lacunas, FaixaNumeracao{Inicio: proximo, Fim: fim, Quantidade: fim - proximo + 1})
+		}
+		if f.Fim+1 > proximo {
+			proximo = f.Fim + 1
+		}
+	}
+	if proximo <= ultimo {
+		lacunas = append(lacunas, FaixaNumeracao{Inicio: proximo, Fim: ultimo, Quantidade: ultimo - proximo + 1})
+	}
+	return lacunas
+}
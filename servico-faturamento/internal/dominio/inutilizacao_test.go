@@ -0,0 +1,95 @@
+package dominio_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func inutilizacaoValida() dominio.Inutilizacao {
+	return dominio.Inutilizacao{
+		CNPJEmitente:  "11.222.333/0001-81",
+		Modelo:        "55",
+		Serie:         1,
+		NumeroInicial: 10,
+		NumeroFinal:   12,
+		Justificativa: "Falha na emissao   do sistema legado",
+	}
+}
+
+func TestInutilizacao_Validar(t *testing.T) {
+	t.Run("deve aceitar faixa valida e normalizar CNPJ e justificativa", func(t *testing.T) {
+		inut := inutilizacaoValida()
+		if err := inut.Validar(); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if inut.CNPJEmitente != "11222333000181" {
+			t.Errorf("esperava CNPJ so com digitos, obteve %s", inut.CNPJEmitente)
+		}
+		if inut.Justificativa != "Falha na emissao do sistema legado" {
+			t.Errorf("esperava espacos normalizados, obteve %q", inut.Justificativa)
+		}
+	})
+
+	t.Run("deve aceitar faixa de um unico numero", func(t *testing.T) {
+		inut := inutilizacaoValida()
+		inut.NumeroFinal = inut.NumeroInicial
+		if err := inut.Validar(); err != nil {
+			t.Errorf("esperava nil, obteve erro: %v", err)
+		}
+	})
+
+	casos := []struct {
+		nome   string
+		ajusta func(*dominio.Inutilizacao)
+	}{
+		{"CNPJ invalido", func(i *dominio.Inutilizacao) { i.CNPJEmitente = "11222333000180" }},
+		{"modelo desconhecido", func(i *dominio.Inutilizacao) { i.Modelo = "57" }},
+		{"serie acima de 999", func(i *dominio.Inutilizacao) { i.Serie = 1000 }},
+		{"numero inicial zero", func(i *dominio.Inutilizacao) { i.NumeroInicial = 0 }},
+		{"faixa invertida", func(i *dominio.Inutilizacao) { i.NumeroInicial, i.NumeroFinal = 12, 10 }},
+		{"numero final acima do maximo", func(i *dominio.Inutilizacao) { i.NumeroFinal = dominio.NumeroMaximoNF + 1 }},
+		{"justificativa curta", func(i *dominio.Inutilizacao) { i.Justificativa = "erro" }},
+	}
+	for _, c := range casos {
+		t.Run("deve rejeitar "+c.nome, func(t *testing.T) {
+			inut := inutilizacaoValida()
+			c.ajusta(&inut)
+			var erros dominio.ErrosCadastro
+			if err := inut.Validar(); !errors.As(err, &erros) || len(erros) != 1 {
+				t.Errorf("esperava um erro de cadastro, obteve: %v", err)
+			}
+		})
+	}
+}
+
+func TestCalcularLacunas(t *testing.T) {
+	faixa := func(inicio, fim int64) dominio.FaixaNumeracao {
+		return dominio.FaixaNumeracao{Inicio: inicio, Fim: fim, Quantidade: fim - inicio + 1}
+	}
+
+	casos := []struct {
+		nome     string
+		ocupadas []dominio.FaixaNumeracao
+		ultimo   int64
+		esperado []dominio.FaixaNumeracao
+	}{
+		{"serie sem lacunas", []dominio.FaixaNumeracao{faixa(1, 1), faixa(2, 2), faixa(3, 5)}, 5, []dominio.FaixaNumeracao{}},
+		{"serie sem numeros alocados", nil, 0, []dominio.FaixaNumeracao{}},
+		{"lacunas no inicio e no meio", []dominio.FaixaNumeracao{faixa(3, 3), faixa(4, 4), faixa(8, 8)}, 8,
+			[]dominio.FaixaNumeracao{faixa(1, 2), faixa(5, 7)}},
+		{"faixas sobrepostas", []dominio.FaixaNumeracao{faixa(1, 6), faixa(4, 4), faixa(5, 9), faixa(11, 11)}, 11,
+			[]dominio.FaixaNumeracao{faixa(10, 10)}},
+		{"numeros ate o ultimo alocado sem nota", []dominio.FaixaNumeracao{faixa(1, 2)}, 4, []dominio.FaixaNumeracao{faixa(3, 4)}},
+		{"ignora numeros acima do ultimo", []dominio.FaixaNumeracao{faixa(1, 1), faixa(9, 9)}, 5, []dominio.FaixaNumeracao{faixa(2, 5)}},
+	}
+	for _, c := range casos {
+		t.Run("deve calcular "+c.nome, func(t *testing.T) {
+			if obtido := dominio.CalcularLacunas(c.ocupadas, c.ultimo); !reflect.DeepEqual(obtido, c.esperado) {
+				t.Errorf("esperava %v, obteve %v", c.esperado, obtido)
+			}
+		})
+	}
+}
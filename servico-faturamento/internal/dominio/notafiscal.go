@@ -2,33 +2,98 @@ package dominio
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// FusoBrasilia é o fuso das datas fiscais (Brasília não tem horário de verão desde 2019)
+var FusoBrasilia = time.FixedZone("BRT", -3*60*60)
+
 // Constantes de status da nota fiscal
 const (
-	StatusNotaAberta  = "ABERTA"
-	StatusNotaFechada = "FECHADA"
+	StatusNotaAberta    = "ABERTA"
+	StatusNotaFechada   = "FECHADA"
+	StatusNotaCancelada = "CANCELADA"
+
+	// Retorno da SEFAZ para a nota fechada
+	StatusNotaAutorizada = "AUTORIZADA"
+	StatusNotaRejeitada  = "REJEITADA"
+	StatusNotaDenegada   = "DENEGADA"
 )
 
+// NotaFiscal é numerada pelo servidor: Numero é o nNF alocado na sequência de
+// (CNPJEmitente, Modelo, Serie), exceto em notas importadas de outro sistema
 type NotaFiscal struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
-	Numero      string    `gorm:"unique;not null" json:"numero"`
-	Status      string    `gorm:"not null" json:"status"` // ABERTA, FECHADA
-	DataCriacao time.Time `gorm:"not null" json:"dataCriacao"`
-	DataFechada *time.Time `json:"dataFechada,omitempty"`
-	Itens       []ItemNota `gorm:"foreignKey:NotaID" json:"itens,omitempty"`
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	Numero       string     `gorm:"not null;uniqueIndex:idx_notas_numeracao,priority:4" json:"numero"`
+	CNPJEmitente string     `gorm:"column:cnpj_emitente;size:14;not null;default:'';uniqueIndex:idx_notas_numeracao,priority:1" json:"cnpjEmitente"`
+	Modelo       string     `gorm:"size:2;not null;default:55;uniqueIndex:idx_notas_numeracao,priority:2" json:"modelo"`
+	Serie        int        `gorm:"not null;uniqueIndex:idx_notas_numeracao,priority:3" json:"serie"`
+	EmitenteID   *uuid.UUID `gorm:"type:uuid;index" json:"emitenteId,omitempty"`
+	Emitente     *Emitente  `gorm:"foreignKey:EmitenteID" json:"emitente,omitempty"`
+	ClienteID    *uuid.UUID `gorm:"type:uuid;index" json:"clienteId,omitempty"`
+	Cliente      *Cliente   `gorm:"foreignKey:ClienteID" json:"cliente,omitempty"`
+	Status       string     `gorm:"not null" json:"status"` // ABERTA, FECHADA, AUTORIZADA, REJEITADA, DENEGADA, CANCELADA
+	DataCriacao  time.Time  `gorm:"not null" json:"dataCriacao"`
+	DataFechada  *time.Time `json:"dataFechada,omitempty"`
+	ChaveAcesso  *string    `gorm:"size:44;uniqueIndex" json:"chaveAcesso,omitempty"`
+	Itens        []ItemNota `gorm:"foreignKey:NotaID" json:"itens,omitempty"`
+
+	// Pagamentos formam o grupo pag; obrigatórios na NFC-e (modelo 65)
+	Pagamentos []PagamentoNota `gorm:"foreignKey:NotaID" json:"pagamentos,omitempty"`
+
+	// Protocolo da SEFAZ; XMLAutorizado é o nfeProc das notas autorizadas ou denegadas
+	ProtocoloAutorizacao *string    `gorm:"size:15" json:"protocoloAutorizacao,omitempty"`
+	CStat                *int       `gorm:"column:c_stat" json:"cStat,omitempty"`
+	XMotivo              *string    `gorm:"column:x_motivo;size:255" json:"xMotivo,omitempty"`
+	DataAutorizacao      *time.Time `json:"dataAutorizacao,omitempty"`
+	XMLAutorizado        *string    `gorm:"column:xml_autorizado;type:text" json:"-"`
+	// XMLFechamento é a NF-e sem assinatura montada no fechamento, com os cadastros
+	// daquele momento: é o documento transmitido e o impresso no DANFE até o protocolo
+	XMLFechamento *string `gorm:"column:xml_fechamento;type:text" json:"-"`
+	// AutorizacaoPendente marca a nota entregue ao autorizador: até o retorno
+	// definitivo da SEFAZ ela pode ser autorizada a qualquer momento e não é
+	// cancelada só no banco
+	AutorizacaoPendente bool `gorm:"not null;default:false" json:"autorizacaoPendente"`
+
+	// Emissão em contingência: tpEmis da chave, dhCont/xJust da ide e protocolo do EPEC
+	TipoEmissao               string     `gorm:"size:1;not null;default:'1'" json:"tipoEmissao"`
+	DataContingencia          *time.Time `json:"dataContingencia,omitempty"`
+	JustificativaContingencia *string    `gorm:"size:255" json:"justificativaContingencia,omitempty"`
+	ProtocoloEPEC             *string    `gorm:"column:protocolo_epec;size:15" json:"protocoloEpec,omitempty"`
+	DataEPEC                  *time.Time `gorm:"column:data_epec" json:"dataEpec,omitempty"`
+
+	// Cancelamento; a nota autorizada só é cancelada com o protocolo do evento 110111,
+	// cujo XML assinado fica em XMLCancelamento
+	DataCancelamento          *time.Time `json:"dataCancelamento,omitempty"`
+	JustificativaCancelamento *string    `gorm:"size:255" json:"justificativaCancelamento,omitempty"`
+	ProtocoloCancelamento     *string    `gorm:"size:15" json:"protocoloCancelamento,omitempty"`
+	XMLCancelamento           *string    `gorm:"column:xml_cancelamento;type:text" json:"-"`
+
+	CartasCorrecao []CartaCorrecao `gorm:"foreignKey:NotaID" json:"cartasCorrecao,omitempty"`
+
+	// Totais é preenchido na consulta da nota a partir dos itens; não é persistido
+	Totais *TotaisNota `gorm:"-" json:"totais,omitempty"`
 }
 
 type ItemNota struct {
-	ID            uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
-	NotaID        uuid.UUID  `gorm:"type:uuid;not null" json:"notaId"`
-	ProdutoID     uuid.UUID  `gorm:"type:uuid;not null" json:"produtoId"`
-	Quantidade    int        `gorm:"not null" json:"quantidade"`
-	PrecoUnitario float64    `gorm:"type:decimal(10,2);not null" json:"precoUnitario"`
+	ID            uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	NotaID        uuid.UUID `gorm:"type:uuid;not null" json:"notaId"`
+	ProdutoID     uuid.UUID `gorm:"type:uuid;not null" json:"produtoId"`
+	Quantidade    Decimal   `gorm:"type:numeric(15,4);not null" json:"quantidade"`
+	PrecoUnitario Decimal   `gorm:"type:numeric(15,4);not null" json:"precoUnitario"`
+	Descricao     string    `gorm:"size:120" json:"descricao,omitempty"`
+	NCM           string    `gorm:"column:ncm;size:8" json:"ncm,omitempty"`
+	CFOP          string    `gorm:"column:cfop;size:4" json:"cfop,omitempty"`
+	Unidade       string    `gorm:"size:6;default:UN" json:"unidade,omitempty"`
+	Origem        string    `gorm:"size:1;not null;default:'0'" json:"origem,omitempty"` // origem da mercadoria (tabela A do CST)
+
+	Tributos TributosItem `gorm:"embedded" json:"tributos"`
 }
 
 func (n *NotaFiscal) BeforeCreate(tx *gorm.DB) error {
@@ -41,6 +106,9 @@ func (n *NotaFiscal) BeforeCreate(tx *gorm.DB) error {
 	if n.Status == "" {
 		n.Status = "ABERTA"
 	}
+	if n.TipoEmissao == "" {
+		n.TipoEmissao = TipoEmissaoNormal
+	}
 	return nil
 }
 
@@ -64,18 +132,83 @@ func (n *NotaFiscal) Fechar() error {
 	return nil
 }
 
-// CalcularTotal retorna o valor total da nota somando todos os itens
-func (n *NotaFiscal) CalcularTotal() float64 {
-	var total float64
-	for _, item := range n.Itens {
-		total += item.CalcularSubtotal()
+// NumeroNF extrai o nNF (1 a 999999999) dos dígitos do número da nota
+func (n *NotaFiscal) NumeroNF() (int64, error) {
+	digitos := strings.TrimLeft(strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, n.Numero), "0")
+	if digitos == "" || len(digitos) > 9 {
+		return 0, fmt.Errorf("numero da nota %q nao e compativel com nNF (1 a 999999999)", n.Numero)
+	}
+	return strconv.ParseInt(digitos, 10, 64)
+}
+
+// AtribuirChaveAcesso sorteia o cNF e compõe a chave de acesso da nota fechada.
+// O AAMM da chave segue a data de fechamento no fuso de Brasília, a mesma do dhEmi.
+func (n *NotaFiscal) AtribuirChaveAcesso(c ComponentesChave) error {
+	if n.Status != StatusNotaFechada || n.DataFechada == nil {
+		return errors.New("chave de acesso so pode ser gerada para nota fechada")
+	}
+
+	numero, err := n.NumeroNF()
+	if err != nil {
+		return err
+	}
+
+	cNF, err := GerarCodigoNumerico(numero)
+	if err != nil {
+		return err
+	}
+
+	c.Numero = numero
+	c.CodigoNumerico = cNF
+	c.Emissao = n.DataFechada.In(FusoBrasilia)
+
+	chave, err := MontarChaveAcesso(c)
+	if err != nil {
+		return err
 	}
-	return total
+	n.ChaveAcesso = &chave
+	return nil
+}
+
+// CalcularTotal retorna o valor total da nota (vNF): os subtotais já
+// arredondados dos itens mais o IPI calculado no fechamento
+func (n *NotaFiscal) CalcularTotal() Decimal {
+	return n.CalcularTotais().VNF
 }
 
 // CalcularSubtotal retorna o valor do item (quantidade × preço unitário)
-func (i *ItemNota) CalcularSubtotal() float64 {
-	return float64(i.Quantidade) * i.PrecoUnitario
+// arredondado para centavos, o vProd do item
+func (i *ItemNota) CalcularSubtotal() Decimal {
+	return i.Quantidade.MulArredondado(i.PrecoUnitario, 2)
+}
+
+// Limites do leiaute para qCom/vUnCom (11 inteiros) e vProd (13 inteiros e 2 casas)
+var (
+	quantidadeMaxima    = MustParseDecimal("99999999999.9999")
+	precoUnitarioMaximo = MustParseDecimal("99999999999.9999")
+	subtotalMaximo      = MustParseDecimal("9999999999999.99")
+)
+
+// Validar confere quantidade positiva, preço não negativo e os limites do leiaute
+func (i *ItemNota) Validar() error {
+	if i.Quantidade.Sinal() <= 0 || i.Quantidade.Cmp(quantidadeMaxima) > 0 {
+		return fmt.Errorf("quantidade deve ser maior que zero e no maximo %s", quantidadeMaxima)
+	}
+	if i.PrecoUnitario.Sinal() < 0 || i.PrecoUnitario.Cmp(precoUnitarioMaximo) > 0 {
+		return fmt.Errorf("precoUnitario deve estar entre 0 e %s", precoUnitarioMaximo)
+	}
+	if subtotal, ok := i.Quantidade.MulVerificado(i.PrecoUnitario); !ok || subtotal.Cmp(subtotalMaximo) > 0 {
+		return fmt.Errorf("subtotal do item excede %s", subtotalMaximo)
+	}
+	if i.Origem != "" && (len(i.Origem) != 1 || i.Origem[0] < '0' || i.Origem[0] > '8') {
+		return fmt.Errorf("origem deve ser de 0 a 8")
+	}
+	return nil
 }
 
 func (n *NotaFiscal) TableName() string {
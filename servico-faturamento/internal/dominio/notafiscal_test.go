@@ -19,8 +19,8 @@ func TestNotaFiscal_Fechar(t *testing.T) {
 				{
 					ID:            uuid.New(),
 					ProdutoID:     uuid.New(),
-					Quantidade:    5,
-					PrecoUnitario: 100.0,
+					Quantidade:    dominio.DecimalDeInteiro(5),
+					PrecoUnitario: dominio.MustParseDecimal("100.0"),
 				},
 			},
 		}
@@ -77,35 +77,35 @@ func TestNotaFiscal_CalcularTotal(t *testing.T) {
 		Itens: []dominio.ItemNota{
 			{
 				ID:            uuid.New(),
-				Quantidade:    2,
-				PrecoUnitario: 50.0,
+				Quantidade:    dominio.DecimalDeInteiro(2),
+				PrecoUnitario: dominio.MustParseDecimal("50.0"),
 			},
 			{
 				ID:            uuid.New(),
-				Quantidade:    3,
-				PrecoUnitario: 30.0,
+				Quantidade:    dominio.DecimalDeInteiro(3),
+				PrecoUnitario: dominio.MustParseDecimal("30.0"),
 			},
 		},
 	}
 
 	total := nota.CalcularTotal()
-	esperado := 190.0 // (2*50) + (3*30)
+	esperado := dominio.MustParseDecimal("190") // (2*50) + (3*30)
 
 	if total != esperado {
-		t.Errorf("esperava total %.2f, obteve %.2f", esperado, total)
+		t.Errorf("esperava total %s, obteve %s", esperado, total)
 	}
 }
 
 func TestItemNota_CalcularSubtotal(t *testing.T) {
 	item := dominio.ItemNota{
-		Quantidade:    5,
-		PrecoUnitario: 100.50,
+		Quantidade:    dominio.DecimalDeInteiro(5),
+		PrecoUnitario: dominio.MustParseDecimal("100.50"),
 	}
 
 	subtotal := item.CalcularSubtotal()
-	esperado := 502.5
+	esperado := dominio.MustParseDecimal("502.5")
 
 	if subtotal != esperado {
-		t.Errorf("esperava subtotal %.2f, obteve %.2f", esperado, subtotal)
+		t.Errorf("esperava subtotal %s, obteve %s", esperado, subtotal)
 	}
 }
\ No newline at end of file
@@ -0,0 +1,21 @@
+package dominio
+
+import "time"
+
+// NumeroMaximoNF é o maior nNF aceito pelo leiaute (9 dígitos)
+const NumeroMaximoNF = 999999999
+
+// SequenciaNumeracao guarda o último número emitido por emitente, modelo e série.
+// A linha é travada (SELECT ... FOR UPDATE) durante a alocação, então o número só
+// é consumido se a transação que criou a nota for confirmada.
+type SequenciaNumeracao struct {
+	CNPJEmitente    string    `gorm:"column:cnpj_emitente;size:14;primaryKey" json:"cnpjEmitente"`
+	Modelo          string    `gorm:"size:2;primaryKey" json:"modelo"`
+	Serie           int       `gorm:"primaryKey;autoIncrement:false" json:"serie"`
+	UltimoNumero    int64     `gorm:"not null;default:0" json:"ultimoNumero"`
+	DataAtualizacao time.Time `gorm:"not null" json:"dataAtualizacao"`
+}
+
+func (SequenciaNumeracao) TableName() string {
+	return "sequencias_numeracao"
+}
@@ -0,0 +1,176 @@
+package dominio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Modelos de documento emitidos pelo serviço
+const (
+	ModeloNFe  = "55"
+	ModeloNFCe = "65"
+)
+
+// Formas de pagamento (tPag) aceitas no grupo detPag
+const (
+	FormaDinheiro        = "01"
+	FormaCheque          = "02"
+	FormaCartaoCredito   = "03"
+	FormaCartaoDebito    = "04"
+	FormaCreditoLoja     = "05"
+	FormaValeAlimentacao = "10"
+	FormaValeRefeicao    = "11"
+	FormaValePresente    = "12"
+	FormaValeCombustivel = "13"
+	FormaBoleto          = "15"
+	FormaDeposito        = "16"
+	FormaPIX             = "17"
+	FormaTransferencia   = "18"
+	FormaFidelidade      = "19"
+	FormaPIXEstatico     = "20"
+	FormaSemPagamento    = "90"
+	FormaOutros          = "99"
+)
+
+var formasPagamento = map[string]string{
+	FormaDinheiro:        "Dinheiro",
+	FormaCheque:          "Cheque",
+	FormaCartaoCredito:   "Cartao de Credito",
+	FormaCartaoDebito:    "Cartao de Debito",
+	FormaCreditoLoja:     "Credito Loja",
+	FormaValeAlimentacao: "Vale Alimentacao",
+	FormaValeRefeicao:    "Vale Refeicao",
+	FormaValePresente:    "Vale Presente",
+	FormaValeCombustivel: "Vale Combustivel",
+	FormaBoleto:          "Boleto Bancario",
+	FormaDeposito:        "Deposito Bancario",
+	FormaPIX:             "PIX Dinamico",
+	FormaTransferencia:   "Transferencia bancaria, Carteira Digital",
+	FormaFidelidade:      "Programa de fidelidade, Cashback, Credito Virtual",
+	FormaPIXEstatico:     "PIX Estatico",
+	FormaSemPagamento:    "Sem pagamento",
+	FormaOutros:          "Outros",
+}
+
+// formasCartao exigem o grupo card (bandeira e autorização) na NFC-e
+var formasCartao = map[string]bool{FormaCartaoCredito: true, FormaCartaoDebito: true, FormaPIX: true}
+
+var (
+	// ErrPagamentoObrigatorio indica NFC-e fechada sem nenhuma forma de pagamento
+	ErrPagamentoObrigatorio = errors.New("NFC-e exige ao menos uma forma de pagamento")
+	// ErrPagamentoInsuficiente indica pagamentos que não cobrem o valor da nota
+	ErrPagamentoInsuficiente = errors.New("pagamentos nao cobrem o valor da nota")
+	// ErrPagamentoInvalido indica forma, valor ou descrição fora do leiaute do detPag
+	ErrPagamentoInvalido = errors.New("pagamento invalido")
+)
+
+// PagamentoNota é uma forma de pagamento da nota (detPag). Na NFC-e o grupo é
+// obrigatório e a soma pode passar do vNF: a diferença é o troco (vTroco).
+type PagamentoNota struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	NotaID      uuid.UUID `gorm:"type:uuid;not null;index:idx_pagamentos_nota_id" json:"notaId"`
+	Forma       string    `gorm:"size:2;not null" json:"forma"`
+	Descricao   string    `gorm:"size:60" json:"descricao,omitempty"`
+	Valor       Decimal   `gorm:"type:numeric(15,2);not null" json:"valor"`
+	Bandeira    string    `gorm:"size:2" json:"bandeira,omitempty"`
+	Autorizacao string    `gorm:"size:128" json:"autorizacao,omitempty"`
+}
+
+func (PagamentoNota) TableName() string {
+	return "pagamentos_nota"
+}
+
+func (p *PagamentoNota) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// Validar confere a forma, o valor com centavos e a descrição exigida em "99 - Outros"
+func (p *PagamentoNota) Validar() error {
+	if _, ok := formasPagamento[p.Forma]; !ok {
+		return fmt.Errorf("%w: forma %q desconhecida", ErrPagamentoInvalido, p.Forma)
+	}
+	if p.Valor.Sinal() < 0 || p.Valor.Cmp(subtotalMaximo) > 0 || p.Valor.Arredondar(2).Cmp(p.Valor) != 0 {
+		return fmt.Errorf("%w: valor deve estar entre 0 e %s com ate 2 casas", ErrPagamentoInvalido, subtotalMaximo)
+	}
+	if p.Forma == FormaSemPagamento && !p.Valor.IsZero() {
+		return fmt.Errorf("%w: forma 90 (sem pagamento) exige valor zero", ErrPagamentoInvalido)
+	}
+	p.Descricao = strings.TrimSpace(p.Descricao)
+	if n := utf8.RuneCountInString(p.Descricao); p.Forma == FormaOutros && (n < 2 || n > 60) {
+		return fmt.Errorf("%w: forma 99 (outros) exige descricao de 2 a 60 caracteres", ErrPagamentoInvalido)
+	}
+	if p.Bandeira != "" && (len(p.Bandeira) != 2 || !apenasDigitos(p.Bandeira)) {
+		return fmt.Errorf("%w: bandeira deve ter 2 digitos (tBand)", ErrPagamentoInvalido)
+	}
+	return nil
+}
+
+// DescricaoForma devolve o nome da forma para o DANFE, ou a descrição informada em "99"
+func (p PagamentoNota) DescricaoForma() string {
+	if p.Descricao != "" {
+		return p.Descricao
+	}
+	return formasPagamento[p.Forma]
+}
+
+// Cartao informa se o pagamento leva o grupo card
+func (p PagamentoNota) Cartao() bool {
+	return formasCartao[p.Forma]
+}
+
+// NFCe informa se a nota é um cupom fiscal eletrônico (modelo 65)
+func (n *NotaFiscal) NFCe() bool {
+	return n.Modelo == ModeloNFCe
+}
+
+// TotalPago soma os valores das formas de pagamento
+func (n *NotaFiscal) TotalPago() Decimal {
+	var total Decimal
+	for _, p := range n.Pagamentos {
+		total = total.Add(p.Valor)
+	}
+	return total
+}
+
+// Troco é o que os pagamentos excedem o valor da nota (vTroco)
+func (n *NotaFiscal) Troco() Decimal {
+	if troco := n.TotalPago().Sub(n.CalcularTotal()); troco.Sinal() > 0 {
+		return troco
+	}
+	return Decimal{}
+}
+
+// ConferirPagamentos valida o grupo pag no fechamento. Na NFC-e há sempre venda
+// presencial: ao menos um pagamento, nunca "90 - sem pagamento", cobrindo o vNF.
+// Na NF-e os pagamentos são opcionais; sem eles a nota sai com "99 - Outros".
+func (n *NotaFiscal) ConferirPagamentos() error {
+	if len(n.Pagamentos) == 0 {
+		if n.NFCe() {
+			return ErrPagamentoObrigatorio
+		}
+		return nil
+	}
+	for _, p := range n.Pagamentos {
+		if n.NFCe() && p.Forma == FormaSemPagamento {
+			return fmt.Errorf("%w: NFC-e nao aceita forma 90 (sem pagamento)", ErrPagamentoInvalido)
+		}
+		if p.Forma == FormaSemPagamento && len(n.Pagamentos) > 1 {
+			return fmt.Errorf("%w: forma 90 (sem pagamento) nao combina com outras formas", ErrPagamentoInvalido)
+		}
+	}
+	if len(n.Pagamentos) == 1 && n.Pagamentos[0].Forma == FormaSemPagamento {
+		return nil
+	}
+	if pago, total := n.TotalPago(), n.CalcularTotal(); pago.Cmp(total) < 0 {
+		return fmt.Errorf("%w: pago %s, total %s", ErrPagamentoInsuficiente, pago.Formatar(2), total.Formatar(2))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package dominio_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+func notaNFCe(pagamentos ...dominio.PagamentoNota) *dominio.NotaFiscal {
+	return &dominio.NotaFiscal{
+		Modelo: dominio.ModeloNFCe,
+		Itens: []dominio.ItemNota{
+			{Quantidade: dominio.DecimalDeInteiro(2), PrecoUnitario: dominio.MustParseDecimal("10.25")},
+		},
+		Pagamentos: pagamentos,
+	}
+}
+
+func TestPagamentoNota_Validar(t *testing.T) {
+	t.Run("deve aceitar forma conhecida com centavos", func(t *testing.T) {
+		p := dominio.PagamentoNota{Forma: dominio.FormaPIX, Valor: dominio.MustParseDecimal("20.50")}
+		if err := p.Validar(); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if !p.Cartao() || p.DescricaoForma() != "PIX Dinamico" {
+			t.Errorf("esperava PIX com grupo card, obteve %q", p.DescricaoForma())
+		}
+	})
+
+	t.Run("deve rejeitar forma, valor e descricao invalidos", func(t *testing.T) {
+		casos := []dominio.PagamentoNota{
+			{Forma: "07", Valor: dominio.DecimalDeInteiro(1)},
+			{Forma: dominio.FormaDinheiro, Valor: dominio.MustParseDecimal("-1")},
+			{Forma: dominio.FormaDinheiro, Valor: dominio.MustParseDecimal("1.005")},
+			{Forma: dominio.FormaSemPagamento, Valor: dominio.DecimalDeInteiro(1)},
+			{Forma: dominio.FormaOutros, Valor: dominio.DecimalDeInteiro(1)},
+			{Forma: dominio.FormaCartaoCredito, Valor: dominio.DecimalDeInteiro(1), Bandeira: "A1"},
+		}
+		for _, p := range casos {
+			if err := p.Validar(); !errors.Is(err, dominio.ErrPagamentoInvalido) {
+				t.Errorf("forma %s valor %s: esperava ErrPagamentoInvalido, obteve %v", p.Forma, p.Valor, err)
+			}
+		}
+	})
+}
+
+func TestNotaFiscal_ConferirPagamentos(t *testing.T) {
+	dinheiro := func(valor string) dominio.PagamentoNota {
+		return dominio.PagamentoNota{Forma: dominio.FormaDinheiro, Valor: dominio.MustParseDecimal(valor)}
+	}
+
+	t.Run("deve exigir pagamento na NFC-e e nao na NF-e", func(t *testing.T) {
+		if err := notaNFCe().ConferirPagamentos(); !errors.Is(err, dominio.ErrPagamentoObrigatorio) {
+			t.Errorf("esperava ErrPagamentoObrigatorio, obteve %v", err)
+		}
+		nfe := notaNFCe()
+		nfe.Modelo = dominio.ModeloNFe
+		if err := nfe.ConferirPagamentos(); err != nil {
+			t.Errorf("esperava NF-e sem pagamentos valida, obteve %v", err)
+		}
+	})
+
+	t.Run("deve calcular o troco quando o pago excede o total", func(t *testing.T) {
+		nota := notaNFCe(dinheiro("15.00"), dominio.PagamentoNota{Forma: dominio.FormaCartaoDebito, Valor: dominio.MustParseDecimal("10.00")})
+		if err := nota.ConferirPagamentos(); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if nota.Troco().Formatar(2) != "4.50" {
+			t.Errorf("esperava troco 4.50, obteve %s", nota.Troco().Formatar(2))
+		}
+	})
+
+	t.Run("deve rejeitar pagamento insuficiente e forma 90 na NFC-e", func(t *testing.T) {
+		if err := notaNFCe(dinheiro("20.49")).ConferirPagamentos(); !errors.Is(err, dominio.ErrPagamentoInsuficiente) {
+			t.Errorf("esperava ErrPagamentoInsuficiente, obteve %v", err)
+		}
+		semPagamento := dominio.PagamentoNota{Forma: dominio.FormaSemPagamento}
+		if err := notaNFCe(semPagamento).ConferirPagamentos(); !errors.Is(err, dominio.ErrPagamentoInvalido) {
+			t.Errorf("esperava ErrPagamentoInvalido, obteve %v", err)
+		}
+	})
+}
+
+func TestNotaFiscal_AplicarContingenciaNFCe(t *testing.T) {
+	inicio := time.Date(2026, 3, 10, 13, 0, 0, 0, dominio.FusoBrasilia)
+
+	t.Run("deve emitir NFC-e offline em qualquer contingencia", func(t *testing.T) {
+		for _, modo := range []string{dominio.ModoSVCAN, dominio.ModoEPEC} {
+			nota := notaFechadaEm(inicio)
+			nota.Modelo = dominio.ModeloNFCe
+			nota.AplicarContingencia(&dominio.Contingencia{Modo: modo, Justificativa: "SEFAZ-SP fora do ar desde 13h", DataInicio: inicio})
+			if nota.TipoEmissao != dominio.TipoEmissaoOffline || nota.DataContingencia == nil {
+				t.Errorf("%s: esperava tpEmis 9 com dhCont, obteve %s", modo, nota.TipoEmissao)
+			}
+		}
+	})
+}
@@ -8,11 +8,14 @@ import (
 )
 
 type SolicitacaoImpressao struct {
-	ID                uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
-	NotaID            uuid.UUID  `gorm:"type:uuid;not null" json:"notaId"`
-	Status            string     `gorm:"not null" json:"status"` // PENDENTE, CONCLUIDA, FALHOU
-	MensagemErro      *string    `json:"mensagemErro,omitempty"`
-	PdfURL            *string    `json:"pdfUrl,omitempty"`
+	ID           uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	NotaID       uuid.UUID `gorm:"type:uuid;not null" json:"notaId"`
+	Status       string    `gorm:"not null" json:"status"` // PENDENTE, CONCLUIDA, FALHOU
+	MensagemErro *string   `json:"mensagemErro,omitempty"`
+	// PdfChave é a chave no armazenamento da versão do DANFE entregue; PdfURL é
+	// montado na consulta, com o endpoint do serviço ou um link assinado
+	PdfChave          *string    `gorm:"column:pdf_chave;size:255" json:"-"`
+	PdfURL            *string    `gorm:"-" json:"pdfUrl,omitempty"`
 	ChaveIdempotencia string     `gorm:"unique" json:"chaveIdempotencia"`
 	DataCriacao       time.Time  `gorm:"not null" json:"dataCriacao"`
 	DataConclusao     *time.Time `json:"dataConclusao,omitempty"`
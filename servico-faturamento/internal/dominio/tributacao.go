@@ -0,0 +1,423 @@
+package dominio
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Regimes usados na seleção de regras: CRT 1 e 4 tributam pelo Simples Nacional
+// (CSOSN), CRT 2 e 3 pelo regime normal (CST)
+const (
+	RegimeSimplesNacional = "SIMPLES"
+	RegimeNormal          = "NORMAL"
+)
+
+// Tributação aplicada quando nenhuma regra atende o item: sem destaque de ICMS
+// e PIS/COFINS não tributados, como as notas emitidas antes do motor tributário
+const (
+	CSTICMSPadrao = "41"
+	CSOSNPadrao   = "102"
+	CSTPISPadrao  = "07"
+	CEnqIPIPadrao = "999"
+)
+
+// CSTs e CSOSNs que o motor sabe calcular
+var (
+	cstICMSTributado = map[string]bool{"00": true, "20": true}
+	cstICMSSemDebito = map[string]bool{"40": true, "41": true, "50": true}
+	csosnComCredito  = map[string]bool{"101": true}
+	csosnSemCredito  = map[string]bool{"102": true, "103": true, "300": true, "400": true}
+	cstIPITributado  = map[string]bool{"00": true, "49": true, "50": true, "99": true}
+	cstIPINaoTrib    = map[string]bool{"01": true, "02": true, "03": true, "04": true, "05": true, "51": true, "52": true, "53": true, "54": true, "55": true}
+	cstPISAliquota   = map[string]bool{"01": true, "02": true}
+	cstPISNaoTrib    = map[string]bool{"04": true, "05": true, "06": true, "07": true, "08": true, "09": true}
+	cstPISOutras     = map[string]bool{"49": true, "99": true}
+)
+
+var cemPorCento = DecimalDeInteiro(100)
+
+// RegimeDoCRT converte o CRT do emitente no regime usado pelas regras
+func RegimeDoCRT(crt string) string {
+	if crt == CRTSimplesNacional || crt == CRTMicroempreendedorMEI {
+		return RegimeSimplesNacional
+	}
+	return RegimeNormal
+}
+
+// RegraTributaria define CST/CSOSN e alíquotas para os itens que atendem aos
+// critérios. Critérios vazios valem para qualquer valor; entre as regras que
+// atendem o item vence a mais específica. As regras ficam no banco para o
+// fiscal atualizar alíquotas pela API, sem deploy.
+type RegraTributaria struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	Descricao string    `gorm:"size:120;not null" json:"descricao"`
+
+	// Critérios
+	Regime         string     `gorm:"size:7" json:"regime,omitempty"`
+	CFOP           string     `gorm:"column:cfop;size:4;index" json:"cfop,omitempty"`
+	NCM            string     `gorm:"column:ncm;size:8;index" json:"ncm,omitempty"` // prefixo: capítulo, posição ou NCM completo
+	Origem         string     `gorm:"size:1" json:"origem,omitempty"`
+	UFDestino      string     `gorm:"column:uf_destino;size:2" json:"ufDestino,omitempty"`
+	VigenciaInicio *time.Time `json:"vigenciaInicio,omitempty"`
+	VigenciaFim    *time.Time `json:"vigenciaFim,omitempty"` // exclusivo
+
+	// ICMS: CST no regime normal, CSOSN no Simples Nacional
+	CSTICMS    string  `gorm:"column:cst_icms;size:2" json:"cstICMS,omitempty"`
+	CSOSN      string  `gorm:"column:csosn;size:3" json:"csosn,omitempty"`
+	PICMS      Decimal `gorm:"column:p_icms;type:numeric(7,4);not null;default:0" json:"pICMS"`
+	PRedBCICMS Decimal `gorm:"column:p_red_bc_icms;type:numeric(7,4);not null;default:0" json:"pRedBC"`
+	PCredSN    Decimal `gorm:"column:p_cred_sn;type:numeric(7,4);not null;default:0" json:"pCredSN"`
+
+	// IPI: sem CST o item não leva o grupo IPI
+	CSTIPI  string  `gorm:"column:cst_ipi;size:2" json:"cstIPI,omitempty"`
+	CEnqIPI string  `gorm:"column:c_enq_ipi;size:3" json:"cEnq,omitempty"`
+	PIPI    Decimal `gorm:"column:p_ipi;type:numeric(7,4);not null;default:0" json:"pIPI"`
+
+	// PIS e COFINS
+	CSTPIS    string  `gorm:"column:cst_pis;size:2" json:"cstPIS,omitempty"`
+	PPIS      Decimal `gorm:"column:p_pis;type:numeric(7,4);not null;default:0" json:"pPIS"`
+	CSTCOFINS string  `gorm:"column:cst_cofins;size:2" json:"cstCOFINS,omitempty"`
+	PCOFINS   Decimal `gorm:"column:p_cofins;type:numeric(7,4);not null;default:0" json:"pCOFINS"`
+
+	// IBS e CBS: as alíquotas vêm do período (AliquotaIBSCBS); a regra só classifica
+	CSTIBSCBS  string `gorm:"column:cst_ibs_cbs;size:3" json:"cstIBSCBS,omitempty"`
+	CClassTrib string `gorm:"column:c_class_trib;size:6" json:"cClassTrib,omitempty"`
+
+	DataCriacao     time.Time `gorm:"not null" json:"dataCriacao"`
+	DataAtualizacao time.Time `gorm:"not null" json:"dataAtualizacao"`
+}
+
+func (r *RegraTributaria) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	if r.DataCriacao.IsZero() {
+		r.DataCriacao = time.Now()
+	}
+	return nil
+}
+
+func (r *RegraTributaria) BeforeSave(tx *gorm.DB) error {
+	r.DataAtualizacao = time.Now()
+	return nil
+}
+
+func (RegraTributaria) TableName() string {
+	return "regras_tributarias"
+}
+
+// Validar normaliza os critérios e confere os códigos e alíquotas suportados
+func (r *RegraTributaria) Validar() error {
+	r.Descricao = strings.TrimSpace(r.Descricao)
+	r.Regime = strings.ToUpper(strings.TrimSpace(r.Regime))
+	r.UFDestino = strings.ToUpper(strings.TrimSpace(r.UFDestino))
+	r.NCM = SomenteDigitos(r.NCM)
+	if r.CSTIPI != "" && r.CEnqIPI == "" {
+		r.CEnqIPI = CEnqIPIPadrao
+	}
+
+	var erros ErrosCadastro
+	erros.texto("descricao", r.Descricao, 2, 120, true)
+	if r.Regime != "" && r.Regime != RegimeSimplesNacional && r.Regime != RegimeNormal {
+		erros.add("regime deve ser %s ou %s", RegimeSimplesNacional, RegimeNormal)
+	}
+	if r.CFOP != "" && (len(r.CFOP) != 4 || !apenasDigitos(r.CFOP)) {
+		erros.add("cfop deve ter 4 digitos")
+	}
+	if len(r.NCM) == 1 {
+		erros.add("ncm deve ter de 2 a 8 digitos")
+	}
+	if r.Origem != "" && (len(r.Origem) != 1 || r.Origem[0] < '0' || r.Origem[0] > '8') {
+		erros.add("origem deve ser de 0 a 8")
+	}
+	if _, ok := CodigoUF(r.UFDestino); r.UFDestino != "" && !ok {
+		erros.add("ufDestino %q inexistente", r.UFDestino)
+	}
+	if r.VigenciaInicio != nil && r.VigenciaFim != nil && !r.VigenciaFim.After(*r.VigenciaInicio) {
+		erros.add("vigenciaFim deve ser posterior a vigenciaInicio")
+	}
+
+	if r.CSTICMS != "" && !cstICMSTributado[r.CSTICMS] && !cstICMSSemDebito[r.CSTICMS] {
+		erros.add("cstICMS %q nao suportado (use 00, 20, 40, 41 ou 50)", r.CSTICMS)
+	}
+	if r.CSTICMS == "20" && r.PRedBCICMS.Sinal() <= 0 {
+		erros.add("cstICMS 20 exige pRedBC")
+	}
+	if r.CSOSN != "" && !csosnComCredito[r.CSOSN] && !csosnSemCredito[r.CSOSN] {
+		erros.add("csosn %q nao suportado (use 101, 102, 103, 300 ou 400)", r.CSOSN)
+	}
+	if r.CSOSN == "101" && r.PCredSN.Sinal() <= 0 {
+		erros.add("csosn 101 exige pCredSN")
+	}
+	if r.CSTIPI != "" && !cstIPITributado[r.CSTIPI] && !cstIPINaoTrib[r.CSTIPI] {
+		erros.add("cstIPI %q nao suportado", r.CSTIPI)
+	}
+	if r.CEnqIPI != "" && (len(r.CEnqIPI) != 3 || !apenasDigitos(r.CEnqIPI)) {
+		erros.add("cEnq deve ter 3 digitos")
+	}
+	for _, cst := range []struct{ campo, valor string }{{"cstPIS", r.CSTPIS}, {"cstCOFINS", r.CSTCOFINS}} {
+		if cst.valor != "" && !cstPISAliquota[cst.valor] && !cstPISNaoTrib[cst.valor] && !cstPISOutras[cst.valor] {
+			erros.add("%s %q nao suportado", cst.campo, cst.valor)
+		}
+	}
+	validarClassificacaoIBSCBS(&erros, r.CSTIBSCBS, r.CClassTrib)
+	for _, aliquota := range []struct {
+		campo string
+		valor Decimal
+	}{
+		{"pICMS", r.PICMS}, {"pRedBC", r.PRedBCICMS}, {"pCredSN", r.PCredSN},
+		{"pIPI", r.PIPI}, {"pPIS", r.PPIS}, {"pCOFINS", r.PCOFINS},
+	} {
+		if aliquota.valor.Sinal() < 0 || aliquota.valor.Cmp(cemPorCento) > 0 {
+			erros.add("%s deve estar entre 0 e 100", aliquota.campo)
+		}
+	}
+
+	if len(erros) > 0 {
+		return erros
+	}
+	return nil
+}
+
+// atende indica se a regra vale para o item na operação
+func (r *RegraTributaria) atende(item ItemNota, op OperacaoTributaria) bool {
+	return (r.Regime == "" || r.Regime == op.Regime) &&
+		(r.CFOP == "" || r.CFOP == item.CFOP) &&
+		(r.NCM == "" || strings.HasPrefix(item.NCM, r.NCM)) &&
+		(r.Origem == "" || r.Origem == item.Origem) &&
+		(r.UFDestino == "" || r.UFDestino == op.UFDestino) &&
+		(r.VigenciaInicio == nil || !op.Data.Before(*r.VigenciaInicio)) &&
+		(r.VigenciaFim == nil || op.Data.Before(*r.VigenciaFim))
+}
+
+// especificidade ordena as regras: mais critérios preenchidos, depois o
+// prefixo de NCM mais longo
+func (r *RegraTributaria) especificidade() (int, int) {
+	criterios := 0
+	for _, c := range []string{r.Regime, r.CFOP, r.NCM, r.Origem, r.UFDestino} {
+		if c != "" {
+			criterios++
+		}
+	}
+	return criterios, len(r.NCM)
+}
+
+// OperacaoTributaria reúne os dados da nota que influenciam a tributação
+type OperacaoTributaria struct {
+	Regime          string // RegimeSimplesNacional ou RegimeNormal
+	UFDestino       string
+	ConsumidorFinal bool            // destinatário não contribuinte: o IPI integra a base do ICMS
+	Data            time.Time       // emissão, para a vigência das regras
+	AliquotaIBSCBS  *AliquotaIBSCBS // período vigente; nil dispensa os grupos de IBS e CBS
+}
+
+// SelecionarRegra devolve a regra mais específica que atende o item, ou nil.
+// Em empate vence a de vigência mais recente.
+func SelecionarRegra(regras []RegraTributaria, item ItemNota, op OperacaoTributaria) *RegraTributaria {
+	var escolhida *RegraTributaria
+	for i := range regras {
+		r := &regras[i]
+		if !r.atende(item, op) {
+			continue
+		}
+		if escolhida == nil || maisEspecifica(r, escolhida) {
+			escolhida = r
+		}
+	}
+	return escolhida
+}
+
+func maisEspecifica(a, b *RegraTributaria) bool {
+	ca, na := a.especificidade()
+	cb, nb := b.especificidade()
+	if ca != cb {
+		return ca > cb
+	}
+	if na != nb {
+		return na > nb
+	}
+	inicioA, inicioB := time.Time{}, time.Time{}
+	if a.VigenciaInicio != nil {
+		inicioA = *a.VigenciaInicio
+	}
+	if b.VigenciaInicio != nil {
+		inicioB = *b.VigenciaInicio
+	}
+	return inicioA.After(inicioB)
+}
+
+// TributosItem guarda o resultado do motor no fechamento da nota, para que XML e
+// DANFE não mudem quando as regras forem alteradas depois
+type TributosItem struct {
+	RegraTributariaID *uuid.UUID `gorm:"type:uuid" json:"regraTributariaId,omitempty"`
+
+	CSTICMS     string  `gorm:"column:cst_icms;size:3" json:"cstICMS,omitempty"` // CST ou CSOSN
+	VBCICMS     Decimal `gorm:"column:vbc_icms;type:numeric(15,4);not null;default:0" json:"vBC"`
+	PRedBCICMS  Decimal `gorm:"column:p_red_bc_icms;type:numeric(7,4);not null;default:0" json:"pRedBC"`
+	PICMS       Decimal `gorm:"column:p_icms;type:numeric(7,4);not null;default:0" json:"pICMS"`
+	VICMS       Decimal `gorm:"column:v_icms;type:numeric(15,4);not null;default:0" json:"vICMS"`
+	PCredSN     Decimal `gorm:"column:p_cred_sn;type:numeric(7,4);not null;default:0" json:"pCredSN"`
+	VCredICMSSN Decimal `gorm:"column:v_cred_icms_sn;type:numeric(15,4);not null;default:0" json:"vCredICMSSN"`
+
+	CSTIPI  string  `gorm:"column:cst_ipi;size:2" json:"cstIPI,omitempty"`
+	CEnqIPI string  `gorm:"column:c_enq_ipi;size:3" json:"cEnq,omitempty"`
+	VBCIPI  Decimal `gorm:"column:vbc_ipi;type:numeric(15,4);not null;default:0" json:"vBCIPI"`
+	PIPI    Decimal `gorm:"column:p_ipi;type:numeric(7,4);not null;default:0" json:"pIPI"`
+	VIPI    Decimal `gorm:"column:v_ipi;type:numeric(15,4);not null;default:0" json:"vIPI"`
+
+	CSTPIS    string  `gorm:"column:cst_pis;size:2" json:"cstPIS,omitempty"`
+	VBCPIS    Decimal `gorm:"column:vbc_pis;type:numeric(15,4);not null;default:0" json:"vBCPIS"`
+	PPIS      Decimal `gorm:"column:p_pis;type:numeric(7,4);not null;default:0" json:"pPIS"`
+	VPIS      Decimal `gorm:"column:v_pis;type:numeric(15,4);not null;default:0" json:"vPIS"`
+	CSTCOFINS string  `gorm:"column:cst_cofins;size:2" json:"cstCOFINS,omitempty"`
+	VBCCOFINS Decimal `gorm:"column:vbc_cofins;type:numeric(15,4);not null;default:0" json:"vBCCOFINS"`
+	PCOFINS   Decimal `gorm:"column:p_cofins;type:numeric(7,4);not null;default:0" json:"pCOFINS"`
+	VCOFINS   Decimal `gorm:"column:v_cofins;type:numeric(15,4);not null;default:0" json:"vCOFINS"`
+
+	CSTIBSCBS  string  `gorm:"column:cst_ibs_cbs;size:3" json:"cstIBSCBS,omitempty"`
+	CClassTrib string  `gorm:"column:c_class_trib;size:6" json:"cClassTrib,omitempty"`
+	VBCIBSCBS  Decimal `gorm:"column:vbc_ibs_cbs;type:numeric(15,4);not null;default:0" json:"vBCIBSCBS"`
+	PIBSUF     Decimal `gorm:"column:p_ibs_uf;type:numeric(7,4);not null;default:0" json:"pIBSUF"`
+	VIBSUF     Decimal `gorm:"column:v_ibs_uf;type:numeric(15,4);not null;default:0" json:"vIBSUF"`
+	PIBSMun    Decimal `gorm:"column:p_ibs_mun;type:numeric(7,4);not null;default:0" json:"pIBSMun"`
+	VIBSMun    Decimal `gorm:"column:v_ibs_mun;type:numeric(15,4);not null;default:0" json:"vIBSMun"`
+	PCBS       Decimal `gorm:"column:p_cbs;type:numeric(7,4);not null;default:0" json:"pCBS"`
+	VCBS       Decimal `gorm:"column:v_cbs;type:numeric(15,4);not null;default:0" json:"vCBS"`
+}
+
+// ICMSTributado indica CST com destaque de ICMS (grupos ICMS00 e ICMS20)
+func (t TributosItem) ICMSTributado() bool {
+	return cstICMSTributado[t.CSTICMS]
+}
+
+// IPITributado indica CST de IPI com base e alíquota (grupo IPITrib)
+func (t TributosItem) IPITributado() bool {
+	return cstIPITributado[t.CSTIPI]
+}
+
+// PISComAliquota indica CST com base e alíquota, tanto em PIS quanto em COFINS
+func PISComAliquota(cst string) bool {
+	return cstPISAliquota[cst] || cstPISOutras[cst]
+}
+
+// CalcularTributosItem aplica a regra (ou a tributação padrão, se nil) ao item.
+// Todos os valores são arredondados para centavos em um único passo.
+func CalcularTributosItem(item ItemNota, regra *RegraTributaria, op OperacaoTributaria) TributosItem {
+	var r RegraTributaria
+	var t TributosItem
+	if regra != nil {
+		r = *regra
+		id := regra.ID
+		t.RegraTributariaID = &id
+	}
+	vProd := item.CalcularSubtotal()
+
+	// IPI primeiro: na venda a não contribuinte ele integra a base do ICMS
+	switch {
+	case cstIPITributado[r.CSTIPI]:
+		t.CSTIPI, t.CEnqIPI = r.CSTIPI, r.CEnqIPI
+		t.VBCIPI, t.PIPI = vProd, r.PIPI
+		t.VIPI = vProd.Percentual(r.PIPI, 2)
+	case cstIPINaoTrib[r.CSTIPI]:
+		t.CSTIPI, t.CEnqIPI = r.CSTIPI, r.CEnqIPI
+	}
+
+	if op.Regime == RegimeSimplesNacional {
+		t.CSTICMS = r.CSOSN
+		if t.CSTICMS == "" {
+			t.CSTICMS = CSOSNPadrao
+		}
+		if csosnComCredito[t.CSTICMS] {
+			t.PCredSN = r.PCredSN
+			t.VCredICMSSN = vProd.Percentual(r.PCredSN, 2)
+		}
+	} else {
+		t.CSTICMS = r.CSTICMS
+		if t.CSTICMS == "" {
+			t.CSTICMS = CSTICMSPadrao
+		}
+		if cstICMSTributado[t.CSTICMS] {
+			base := vProd
+			if op.ConsumidorFinal {
+				base = base.Add(t.VIPI)
+			}
+			if t.CSTICMS == "20" {
+				t.PRedBCICMS = r.PRedBCICMS
+				base = base.Percentual(cemPorCento.Sub(r.PRedBCICMS), 2)
+			}
+			t.VBCICMS, t.PICMS = base, r.PICMS
+			t.VICMS = base.Percentual(r.PICMS, 2)
+		}
+	}
+
+	// O ICMS destacado não compõe a base de PIS/COFINS (STF, Tema 69)
+	baseContribuicoes := vProd.Sub(t.VICMS)
+	t.CSTPIS, t.VBCPIS, t.PPIS, t.VPIS = contribuicao(r.CSTPIS, r.PPIS, baseContribuicoes)
+	t.CSTCOFINS, t.VBCCOFINS, t.PCOFINS, t.VCOFINS = contribuicao(r.CSTCOFINS, r.PCOFINS, baseContribuicoes)
+
+	calcularIBSCBS(&t, vProd, r, op)
+
+	return t
+}
+
+func contribuicao(cst string, aliquota, base Decimal) (string, Decimal, Decimal, Decimal) {
+	if cst == "" {
+		cst = CSTPISPadrao
+	}
+	if !PISComAliquota(cst) {
+		return cst, Decimal{}, Decimal{}, Decimal{}
+	}
+	return cst, base, aliquota, base.Percentual(aliquota, 2)
+}
+
+// CalcularTributos seleciona a regra de cada item e grava o resultado em Tributos
+func (n *NotaFiscal) CalcularTributos(regras []RegraTributaria, op OperacaoTributaria) {
+	for i := range n.Itens {
+		item := &n.Itens[i]
+		item.Tributos = CalcularTributosItem(*item, SelecionarRegra(regras, *item, op), op)
+	}
+}
+
+// TotaisNota consolida os valores do grupo ICMSTot
+type TotaisNota struct {
+	VProd       Decimal `json:"vProd"`
+	VBC         Decimal `json:"vBC"`
+	VICMS       Decimal `json:"vICMS"`
+	VIPI        Decimal `json:"vIPI"`
+	VPIS        Decimal `json:"vPIS"`
+	VCOFINS     Decimal `json:"vCOFINS"`
+	VCredICMSSN Decimal `json:"vCredICMSSN"`
+	VNF         Decimal `json:"vNF"`
+
+	VBCIBSCBS Decimal `json:"vBCIBSCBS"`
+	VIBSUF    Decimal `json:"vIBSUF"`
+	VIBSMun   Decimal `json:"vIBSMun"`
+	VIBS      Decimal `json:"vIBS"`
+	VCBS      Decimal `json:"vCBS"`
+}
+
+// CalcularTotais soma os subtotais e os tributos já calculados dos itens. ICMS,
+// PIS e COFINS estão embutidos no preço; só o IPI é somado ao valor da nota.
+// IBS e CBS são apenas informativos na fase de teste e não alteram o vNF.
+func (n *NotaFiscal) CalcularTotais() TotaisNota {
+	var t TotaisNota
+	for _, item := range n.Itens {
+		t.VProd = t.VProd.Add(item.CalcularSubtotal())
+		t.VBC = t.VBC.Add(item.Tributos.VBCICMS)
+		t.VICMS = t.VICMS.Add(item.Tributos.VICMS)
+		t.VIPI = t.VIPI.Add(item.Tributos.VIPI)
+		t.VPIS = t.VPIS.Add(item.Tributos.VPIS)
+		t.VCOFINS = t.VCOFINS.Add(item.Tributos.VCOFINS)
+		t.VCredICMSSN = t.VCredICMSSN.Add(item.Tributos.VCredICMSSN)
+		t.VBCIBSCBS = t.VBCIBSCBS.Add(item.Tributos.VBCIBSCBS)
+		t.VIBSUF = t.VIBSUF.Add(item.Tributos.VIBSUF)
+		t.VIBSMun = t.VIBSMun.Add(item.Tributos.VIBSMun)
+		t.VCBS = t.VCBS.Add(item.Tributos.VCBS)
+	}
+	t.VIBS = t.VIBSUF.Add(t.VIBSMun)
+	t.VNF = t.VProd.Add(t.VIPI)
+	return t
+}
@@ -0,0 +1,229 @@
+package dominio_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+
+	"github.com/google/uuid"
+)
+
+func itemTributacao(preco string) dominio.ItemNota {
+	return dominio.ItemNota{
+		Quantidade:    dominio.DecimalDeInteiro(1),
+		PrecoUnitario: dominio.MustParseDecimal(preco),
+		NCM:           "96081000",
+		CFOP:          "5102",
+		Origem:        "0",
+	}
+}
+
+func operacaoNormal() dominio.OperacaoTributaria {
+	return dominio.OperacaoTributaria{
+		Regime:    dominio.RegimeNormal,
+		UFDestino: "SP",
+		Data:      time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSelecionarRegra(t *testing.T) {
+	item := itemTributacao("100")
+	op := operacaoNormal()
+
+	t.Run("deve preferir a regra com mais criterios e o NCM mais longo", func(t *testing.T) {
+		regras := []dominio.RegraTributaria{
+			{Descricao: "geral"},
+			{Descricao: "capitulo 96", NCM: "96"},
+			{Descricao: "posicao 9608", NCM: "9608"},
+			{Descricao: "cfop", CFOP: "5102"},
+			{Descricao: "outro ncm com cfop", CFOP: "5102", NCM: "8471"},
+		}
+
+		regra := dominio.SelecionarRegra(regras, item, op)
+
+		if regra == nil || regra.Descricao != "posicao 9608" {
+			t.Fatalf("esperava posicao 9608, obteve %+v", regra)
+		}
+
+		regras = append(regras, dominio.RegraTributaria{Descricao: "cfop e capitulo", CFOP: "5102", NCM: "96"})
+		if regra := dominio.SelecionarRegra(regras, item, op); regra.Descricao != "cfop e capitulo" {
+			t.Errorf("esperava cfop e capitulo, obteve %s", regra.Descricao)
+		}
+	})
+
+	t.Run("deve respeitar a vigencia e preferir a mais recente", func(t *testing.T) {
+		inicio2024 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		inicio2025 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		fim2025 := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+		regras := []dominio.RegraTributaria{
+			{Descricao: "2024", CFOP: "5102", VigenciaInicio: &inicio2024},
+			{Descricao: "encerrada", CFOP: "5102", VigenciaInicio: &inicio2025, VigenciaFim: &fim2025},
+		}
+
+		if regra := dominio.SelecionarRegra(regras, item, op); regra == nil || regra.Descricao != "2024" {
+			t.Fatalf("esperava regra de 2024, obteve %+v", regra)
+		}
+
+		regras[1].VigenciaFim = nil
+		if regra := dominio.SelecionarRegra(regras, item, op); regra.Descricao != "encerrada" {
+			t.Errorf("esperava a vigencia mais recente, obteve %s", regra.Descricao)
+		}
+	})
+
+	t.Run("deve ignorar regras de outro regime ou UF", func(t *testing.T) {
+		regras := []dominio.RegraTributaria{
+			{Descricao: "simples", Regime: dominio.RegimeSimplesNacional},
+			{Descricao: "RJ", UFDestino: "RJ"},
+		}
+
+		if regra := dominio.SelecionarRegra(regras, item, op); regra != nil {
+			t.Errorf("esperava nenhuma regra, obteve %s", regra.Descricao)
+		}
+	})
+}
+
+func TestCalcularTributosItem(t *testing.T) {
+	regraNormal := dominio.RegraTributaria{
+		ID:        uuid.New(),
+		CSTICMS:   "00",
+		PICMS:     dominio.MustParseDecimal("18"),
+		CSTPIS:    "01",
+		PPIS:      dominio.MustParseDecimal("1.65"),
+		CSTCOFINS: "01",
+		PCOFINS:   dominio.MustParseDecimal("7.6"),
+	}
+
+	t.Run("deve calcular ICMS e excluir o ICMS da base de PIS e COFINS", func(t *testing.T) {
+		tributos := dominio.CalcularTributosItem(itemTributacao("100"), &regraNormal, operacaoNormal())
+
+		if tributos.CSTICMS != "00" || tributos.VBCICMS.Formatar(2) != "100.00" || tributos.VICMS.Formatar(2) != "18.00" {
+			t.Errorf("ICMS inesperado: CST %s vBC %s vICMS %s", tributos.CSTICMS, tributos.VBCICMS, tributos.VICMS)
+		}
+		if tributos.VBCPIS.Formatar(2) != "82.00" || tributos.VPIS.Formatar(2) != "1.35" {
+			t.Errorf("PIS inesperado: vBC %s vPIS %s", tributos.VBCPIS, tributos.VPIS)
+		}
+		// 82 × 7.6% = 6.232
+		if tributos.VCOFINS.Formatar(2) != "6.23" {
+			t.Errorf("esperava COFINS 6.23, obteve %s", tributos.VCOFINS)
+		}
+		if tributos.RegraTributariaID == nil || *tributos.RegraTributariaID != regraNormal.ID {
+			t.Error("esperava a regra aplicada registrada no item")
+		}
+	})
+
+	t.Run("deve reduzir a base no CST 20", func(t *testing.T) {
+		regra := regraNormal
+		regra.CSTICMS = "20"
+		regra.PRedBCICMS = dominio.MustParseDecimal("33.33")
+
+		tributos := dominio.CalcularTributosItem(itemTributacao("100"), &regra, operacaoNormal())
+
+		if tributos.VBCICMS.Formatar(2) != "66.67" || tributos.VICMS.Formatar(2) != "12.00" {
+			t.Errorf("esperava vBC 66.67 e vICMS 12.00, obteve %s e %s", tributos.VBCICMS, tributos.VICMS)
+		}
+	})
+
+	t.Run("deve incluir o IPI na base do ICMS para consumidor final", func(t *testing.T) {
+		regra := regraNormal
+		regra.CSTIPI, regra.CEnqIPI, regra.PIPI = "50", "999", dominio.MustParseDecimal("10")
+		op := operacaoNormal()
+
+		contribuinte := dominio.CalcularTributosItem(itemTributacao("100"), &regra, op)
+		op.ConsumidorFinal = true
+		consumidor := dominio.CalcularTributosItem(itemTributacao("100"), &regra, op)
+
+		if contribuinte.VIPI.Formatar(2) != "10.00" || contribuinte.VBCICMS.Formatar(2) != "100.00" {
+			t.Errorf("contribuinte: esperava vIPI 10.00 e vBC 100.00, obteve %s e %s", contribuinte.VIPI, contribuinte.VBCICMS)
+		}
+		if consumidor.VBCICMS.Formatar(2) != "110.00" || consumidor.VICMS.Formatar(2) != "19.80" {
+			t.Errorf("consumidor final: esperava vBC 110.00 e vICMS 19.80, obteve %s e %s", consumidor.VBCICMS, consumidor.VICMS)
+		}
+	})
+
+	t.Run("deve calcular o credito do CSOSN 101 no Simples Nacional", func(t *testing.T) {
+		regra := dominio.RegraTributaria{CSOSN: "101", PCredSN: dominio.MustParseDecimal("2.56"), CSTICMS: "00", PICMS: dominio.MustParseDecimal("18")}
+		op := operacaoNormal()
+		op.Regime = dominio.RegimeSimplesNacional
+
+		tributos := dominio.CalcularTributosItem(itemTributacao("250"), &regra, op)
+
+		if tributos.CSTICMS != "101" || tributos.VCredICMSSN.Formatar(2) != "6.40" {
+			t.Errorf("esperava CSOSN 101 com credito 6.40, obteve %s %s", tributos.CSTICMS, tributos.VCredICMSSN)
+		}
+		if !tributos.VICMS.IsZero() {
+			t.Errorf("Simples Nacional nao destaca ICMS, obteve %s", tributos.VICMS)
+		}
+	})
+
+	t.Run("deve aplicar a tributacao padrao sem regra", func(t *testing.T) {
+		normal := dominio.CalcularTributosItem(itemTributacao("100"), nil, operacaoNormal())
+		op := operacaoNormal()
+		op.Regime = dominio.RegimeSimplesNacional
+		simples := dominio.CalcularTributosItem(itemTributacao("100"), nil, op)
+
+		if normal.CSTICMS != "41" || simples.CSTICMS != "102" || normal.CSTPIS != "07" || normal.CSTCOFINS != "07" {
+			t.Errorf("padrao inesperado: normal %s simples %s PIS %s COFINS %s", normal.CSTICMS, simples.CSTICMS, normal.CSTPIS, normal.CSTCOFINS)
+		}
+		if normal.RegraTributariaID != nil {
+			t.Error("esperava item sem regra registrada")
+		}
+	})
+}
+
+func TestNotaFiscal_CalcularTotais(t *testing.T) {
+	t.Run("deve somar tributos e acrescentar o IPI ao vNF", func(t *testing.T) {
+		regra := dominio.RegraTributaria{
+			CSTICMS: "00", PICMS: dominio.MustParseDecimal("18"),
+			CSTIPI: "50", CEnqIPI: "999", PIPI: dominio.MustParseDecimal("5"),
+		}
+		nota := dominio.NotaFiscal{Itens: []dominio.ItemNota{itemTributacao("100"), itemTributacao("33.33")}}
+
+		nota.CalcularTributos([]dominio.RegraTributaria{regra}, operacaoNormal())
+		totais := nota.CalcularTotais()
+
+		if totais.VProd.Formatar(2) != "133.33" || totais.VICMS.Formatar(2) != "24.00" || totais.VIPI.Formatar(2) != "6.67" {
+			t.Errorf("totais inesperados: vProd %s vICMS %s vIPI %s", totais.VProd, totais.VICMS, totais.VIPI)
+		}
+		if totais.VNF.Formatar(2) != "140.00" || nota.CalcularTotal() != totais.VNF {
+			t.Errorf("esperava vNF 140.00, obteve %s", totais.VNF)
+		}
+	})
+}
+
+func TestRegraTributaria_Validar(t *testing.T) {
+	t.Run("deve normalizar criterios e preencher o cEnq padrao", func(t *testing.T) {
+		regra := dominio.RegraTributaria{Descricao: "IPI", Regime: "normal", NCM: "9608.10", CSTIPI: "50", PIPI: dominio.MustParseDecimal("5")}
+
+		if err := regra.Validar(); err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if regra.Regime != dominio.RegimeNormal || regra.NCM != "960810" || regra.CEnqIPI != "999" {
+			t.Errorf("normalizacao inesperada: %s %s %s", regra.Regime, regra.NCM, regra.CEnqIPI)
+		}
+	})
+
+	t.Run("deve listar codigos e aliquotas invalidos", func(t *testing.T) {
+		regra := dominio.RegraTributaria{
+			Descricao: "Invalida",
+			CSTICMS:   "20",
+			CSOSN:     "900",
+			CSTPIS:    "03",
+			PICMS:     dominio.MustParseDecimal("120"),
+		}
+
+		err := regra.Validar()
+
+		var erros dominio.ErrosCadastro
+		if !errors.As(err, &erros) {
+			t.Fatalf("esperava ErrosCadastro, obteve %v", err)
+		}
+		for _, trecho := range []string{"pRedBC", "csosn", "cstPIS", "pICMS"} {
+			if !strings.Contains(err.Error(), trecho) {
+				t.Errorf("esperava erro contendo %q, obteve: %s", trecho, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package dominio
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TipoDocumentoDANFE identifica o PDF do DANFE entre os documentos da nota
+const TipoDocumentoDANFE = "DANFE"
+
+// Documento é uma versão gerada de um documento da nota. As versões são imutáveis:
+// cada geração com conteúdo novo grava outro arquivo no armazenamento, com o hash
+// SHA-256 e a versão do leiaute, e as anteriores ficam como histórico auditável.
+type Documento struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	NotaID       uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_documentos_versao,priority:1" json:"notaId"`
+	Tipo         string    `gorm:"size:20;not null;uniqueIndex:idx_documentos_versao,priority:2" json:"tipo"`
+	Versao       int       `gorm:"not null;uniqueIndex:idx_documentos_versao,priority:3" json:"versao"`
+	VersaoLayout string    `gorm:"size:30;not null" json:"versaoLayout"`
+	HashSHA256   string    `gorm:"column:hash_sha256;size:64;not null" json:"hashSha256"`
+	Tamanho      int       `gorm:"not null" json:"tamanho"`
+	Chave        string    `gorm:"size:255;not null" json:"chave"`
+	Motivo       string    `gorm:"size:255;not null" json:"motivo"`
+	DataCriacao  time.Time `gorm:"not null" json:"dataCriacao"`
+}
+
+func (d *Documento) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	if d.DataCriacao.IsZero() {
+		d.DataCriacao = time.Now()
+	}
+	return nil
+}
+
+func (Documento) TableName() string {
+	return "documentos"
+}
@@ -0,0 +1,254 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"strings"
+	"time"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/impressao"
+	"servico-faturamento/internal/nfe"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// IntervaloVarredura é o intervalo em que os envios com nova tentativa vencida são retomados
+	IntervaloVarredura = 30 * time.Second
+	// reservaEnvio afasta a próxima tentativa enquanto o envio está em andamento,
+	// para que o consumidor e a varredura não enviem o mesmo registro
+	reservaEnvio = 5 * time.Minute
+	// prazoEnvio cobre a montagem dos anexos e a conversa SMTP de um envio
+	prazoEnvio = 2 * time.Minute
+	loteEnvios = 50
+)
+
+// Despachante envia os registros pendentes de envios_email
+type Despachante struct {
+	DB            *gorm.DB
+	Armazenamento armazenamento.Armazenamento
+	Transporte    Transporte
+	Remetente     string
+}
+
+// Iniciar retoma periodicamente os envios cuja próxima tentativa venceu
+func (d *Despachante) Iniciar(ctx context.Context) {
+	slog.Info("Despachante de email iniciado", "intervalo", IntervaloVarredura)
+	go func() {
+		ticker := time.NewTicker(IntervaloVarredura)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := d.EnviarPendentes(ctx, nil); err != nil {
+					slog.Error("Falha ao carregar envios de email pendentes", "erro", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// RegistrarAutorizacao cria o envio da nota autorizada ao email do cadastro do
+// cliente. É idempotente: a reentrega do evento de autorização não duplica o
+// envio. Nota sem cliente ou cliente sem email não gera envio.
+func (d *Despachante) RegistrarAutorizacao(notaID uuid.UUID) (bool, error) {
+	registrado := false
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		var nota dominio.NotaFiscal
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Cliente").First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+		if nota.Status != dominio.StatusNotaAutorizada || nota.Cliente == nil || nota.Cliente.Email == "" {
+			return nil
+		}
+
+		var existentes int64
+		if err := tx.Model(&dominio.EnvioEmail{}).
+			Where("nota_id = ? AND origem = ?", notaID, dominio.OrigemEmailAutorizacao).
+			Count(&existentes).Error; err != nil {
+			return err
+		}
+		if existentes > 0 {
+			return nil
+		}
+
+		registrado = true
+		return tx.Create(&dominio.EnvioEmail{
+			NotaID:       notaID,
+			Destinatario: nota.Cliente.Email,
+			Origem:       dominio.OrigemEmailAutorizacao,
+		}).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return registrado, nil
+}
+
+// EnviarPendentes tenta os envios pendentes com tentativa vencida, só os da nota
+// quando notaID é informado, e devolve quantos foram aceitos pelo servidor. O erro
+// é de banco; as falhas de cada envio ficam registradas nele.
+func (d *Despachante) EnviarPendentes(ctx context.Context, notaID *uuid.UUID) (int, error) {
+	consulta := d.DB.Model(&dominio.EnvioEmail{}).
+		Where("status = ? AND proxima_tentativa <= ?", dominio.StatusEmailPendente, time.Now())
+	if notaID != nil {
+		consulta = consulta.Where("nota_id = ?", *notaID)
+	}
+	var ids []uuid.UUID
+	if err := consulta.Order("proxima_tentativa").Limit(loteEnvios).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	enviados := 0
+	for _, id := range ids {
+		envio, err := d.enviar(ctx, id)
+		if err != nil {
+			return enviados, err
+		}
+		if envio != nil && envio.Status == dominio.StatusEmailEnviado {
+			enviados++
+		}
+	}
+	return enviados, nil
+}
+
+// enviar reserva o envio, conta a tentativa e registra o resultado; devolve nil
+// quando outro processo reservou o registro antes
+func (d *Despachante) enviar(ctx context.Context, id uuid.UUID) (*dominio.EnvioEmail, error) {
+	agora := time.Now()
+	reserva := d.DB.Model(&dominio.EnvioEmail{}).
+		Where("id = ? AND status = ? AND proxima_tentativa <= ?", id, dominio.StatusEmailPendente, agora).
+		Updates(map[string]interface{}{
+			"tentativas":        gorm.Expr("tentativas + 1"),
+			"proxima_tentativa": agora.Add(reservaEnvio),
+		})
+	if reserva.Error != nil {
+		return nil, reserva.Error
+	}
+	if reserva.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	var envio dominio.EnvioEmail
+	if err := d.DB.First(&envio, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	ctxEnvio, cancel := context.WithTimeout(ctx, prazoEnvio)
+	defer cancel()
+	msg, err := d.Montar(ctxEnvio, envio)
+	if err == nil {
+		err = d.Transporte.Enviar(ctxEnvio, msg)
+	}
+
+	agora = time.Now()
+	switch {
+	case err == nil:
+		envio.RegistrarEnvio(msg.MessageID, agora)
+		slog.Info("Email da nota enviado", "notaId", envio.NotaID, "envioId", envio.ID, "destinatario", envio.Destinatario)
+	case errors.Is(err, ErrDestinatarioRecusado):
+		envio.RegistrarDevolucao(err.Error(), agora)
+		slog.Warn("Email da nota devolvido", "notaId", envio.NotaID, "envioId", envio.ID, "destinatario", envio.Destinatario, "erro", err.Error())
+	case errors.Is(err, ErrEnvioInviavel):
+		mensagem := err.Error()
+		envio.Status, envio.UltimoErro = dominio.StatusEmailFalhou, &mensagem
+		slog.Error("Email da nota nao pode ser enviado", "notaId", envio.NotaID, "envioId", envio.ID, "erro", mensagem)
+	default:
+		envio.RegistrarFalha(err.Error(), agora)
+		slog.Warn("Falha ao enviar email da nota", "notaId", envio.NotaID, "envioId", envio.ID, "tentativa", envio.Tentativas,
+			"status", envio.Status, "proximaTentativa", envio.ProximaTentativa, "erro", err.Error())
+	}
+
+	if err := d.DB.Save(&envio).Error; err != nil {
+		return nil, fmt.Errorf("falha ao registrar resultado do envio %s: %w", envio.ID, err)
+	}
+	return &envio, nil
+}
+
+// Montar prepara a mensagem do envio com o DANFE vigente e o XML autorizado
+// anexados. O DANFE passa pelo versionamento do PDF: se o gerador de PDF ainda não
+// gravou a versão da nota autorizada, ela é gravada aqui, com o mesmo hash que o
+// gerador produziria.
+func (d *Despachante) Montar(ctx context.Context, envio dominio.EnvioEmail) (Mensagem, error) {
+	var nota dominio.NotaFiscal
+	if err := d.DB.First(&nota, "id = ?", envio.NotaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Mensagem{}, fmt.Errorf("%w: nota nao encontrada", ErrEnvioInviavel)
+		}
+		return Mensagem{}, err
+	}
+	if nota.Status != dominio.StatusNotaAutorizada || nota.XMLAutorizado == nil {
+		return Mensagem{}, fmt.Errorf("%w: nota %s", ErrEnvioInviavel, strings.ToLower(nota.Status))
+	}
+
+	xmlAutorizado, err := d.Armazenamento.Ler(ctx, armazenamento.ChaveXMLAutorizado(nota))
+	if errors.Is(err, armazenamento.ErrNaoEncontrado) {
+		// O arquivamento do nfeProc é best-effort; o banco guarda o mesmo XML
+		xmlAutorizado, err = []byte(*nota.XMLAutorizado), nil
+	}
+	if err != nil {
+		return Mensagem{}, err
+	}
+	doc, err := nfe.LerNFe(xmlAutorizado)
+	if err != nil {
+		return Mensagem{}, fmt.Errorf("%w: %w", ErrEnvioInviavel, err)
+	}
+
+	processador := &impressao.Processador{DB: d.DB, Armazenamento: d.Armazenamento}
+	danfe, _, err := processador.Regenerar(ctx, nota.ID, "envio por email")
+	if errors.Is(err, impressao.ErrDANFEInviavel) {
+		return Mensagem{}, fmt.Errorf("%w: %w", ErrEnvioInviavel, err)
+	}
+	if err != nil {
+		return Mensagem{}, err
+	}
+	pdf, err := d.Armazenamento.Ler(ctx, danfe.Chave)
+	if err != nil {
+		return Mensagem{}, err
+	}
+
+	protocolo := ""
+	if nota.ProtocoloAutorizacao != nil {
+		protocolo = *nota.ProtocoloAutorizacao
+	}
+	dados := DadosDoDocumento(doc, protocolo, envio.Origem == dominio.OrigemEmailReenvio)
+	assunto, texto, html, err := Renderizar(dados)
+	if err != nil {
+		return Mensagem{}, fmt.Errorf("%w: %w", ErrEnvioInviavel, err)
+	}
+
+	return Mensagem{
+		De:        d.Remetente,
+		Para:      envio.Destinatario,
+		Assunto:   assunto,
+		Texto:     texto,
+		HTML:      html,
+		MessageID: d.messageID(envio),
+		Data:      time.Now(),
+		Anexos: []Anexo{
+			{Nome: dados.ChaveAcesso + ".pdf", Tipo: armazenamento.TipoPDF, Conteudo: pdf},
+			{Nome: dados.ChaveAcesso + "-procNFe.xml", Tipo: armazenamento.TipoXML, Conteudo: xmlAutorizado},
+		},
+	}, nil
+}
+
+// messageID identifica a mensagem de cada tentativa pelo envio; as devoluções
+// informadas depois são localizadas por ele
+func (d *Despachante) messageID(envio dominio.EnvioEmail) string {
+	dominioRemetente := "localhost"
+	if endereco, err := mail.ParseAddress(d.Remetente); err == nil {
+		if _, depois, ok := strings.Cut(endereco.Address, "@"); ok {
+			dominioRemetente = depois
+		}
+	}
+	return fmt.Sprintf("<%s.%d@%s>", envio.ID, envio.Tentativas, dominioRemetente)
+}
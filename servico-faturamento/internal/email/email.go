@@ -0,0 +1,201 @@
+// Package email envia ao destinatário o DANFE e o XML das notas autorizadas. Cada
+// destinatário tem um registro em envios_email, tentado pelo Despachante com
+// espera crescente entre as falhas; a entrega sai por SMTP (MailHog no compose).
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"servico-faturamento/internal/armazenamento"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDestinatarioRecusado indica recusa permanente (5xx) do servidor SMTP: o
+	// envio é registrado como devolvido e não é repetido
+	ErrDestinatarioRecusado = errors.New("destinatario recusado pelo servidor de email")
+	// ErrEnvioInviavel indica envio que nenhuma nova tentativa resolve, como o da nota cancelada
+	ErrEnvioInviavel = errors.New("envio de email inviavel")
+)
+
+// Anexo é um arquivo da mensagem
+type Anexo struct {
+	Nome     string
+	Tipo     string
+	Conteudo []byte
+}
+
+// Mensagem é o email de um destinatário, com texto e HTML alternativos
+type Mensagem struct {
+	De        string
+	Para      string
+	Assunto   string
+	Texto     string
+	HTML      string
+	Anexos    []Anexo
+	MessageID string
+	Data      time.Time
+}
+
+// Transporte entrega a mensagem; erros com ErrDestinatarioRecusado são definitivos
+type Transporte interface {
+	Enviar(ctx context.Context, msg Mensagem) error
+}
+
+// MIME monta a mensagem em multipart/mixed: o corpo multipart/alternative (texto e
+// HTML em quoted-printable) seguido dos anexos em base64
+func (m Mensagem) MIME() ([]byte, error) {
+	de, err := mail.ParseAddress(m.De)
+	if err != nil {
+		return nil, fmt.Errorf("remetente invalido: %w", err)
+	}
+	para, err := mail.ParseAddress(m.Para)
+	if err != nil {
+		return nil, fmt.Errorf("destinatario invalido: %w", err)
+	}
+
+	var corpo bytes.Buffer
+	alternativo := multipart.NewWriter(&corpo)
+	for _, parte := range []struct{ tipo, conteudo string }{
+		{"text/plain; charset=utf-8", m.Texto},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		w, err := alternativo.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {parte.tipo},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(parte.conteudo)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := alternativo.Close(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	misto := multipart.NewWriter(&buf)
+	cabecalhos := []string{
+		"From: " + de.String(),
+		"To: " + para.String(),
+		"Subject: " + mime.QEncoding.Encode("utf-8", m.Assunto),
+		"Date: " + m.Data.Format(time.RFC1123Z),
+		"Message-ID: " + m.MessageID,
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + misto.Boundary(),
+	}
+	buf.WriteString(strings.Join(cabecalhos, "\r\n") + "\r\n\r\n")
+
+	w, err := misto.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + alternativo.Boundary()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(corpo.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for _, anexo := range m.Anexos {
+		w, err := misto.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(anexo.Tipo, map[string]string{"name": anexo.Nome})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": anexo.Nome})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := escreverBase64(w, anexo.Conteudo); err != nil {
+			return nil, err
+		}
+	}
+	if err := misto.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// escreverBase64 quebra o base64 em linhas de 76 caracteres (RFC 2045)
+func escreverBase64(w interface{ Write([]byte) (int, error) }, conteudo []byte) error {
+	codificado := base64.StdEncoding.EncodeToString(conteudo)
+	for len(codificado) > 76 {
+		if _, err := w.Write([]byte(codificado[:76] + "\r\n")); err != nil {
+			return err
+		}
+		codificado = codificado[76:]
+	}
+	_, err := w.Write([]byte(codificado + "\r\n"))
+	return err
+}
+
+// CarregarConfigurado monta o despachante a partir das variáveis de ambiente. Sem
+// EMAIL_SMTP_HOST devolve nil, nil: as notas não são enviadas por email.
+//
+//   - EMAIL_SMTP_PORTA (padrão 587), EMAIL_SMTP_USUARIO e EMAIL_SMTP_SENHA
+//   - EMAIL_SMTP_SEGURANCA: starttls (padrão, quando o servidor oferece), tls ou nenhuma
+//   - EMAIL_REMETENTE: endereço do From, como "Faturamento <nfe@empresa.com.br>"
+func CarregarConfigurado(db *gorm.DB, docs armazenamento.Armazenamento) (*Despachante, error) {
+	host := strings.TrimSpace(os.Getenv("EMAIL_SMTP_HOST"))
+	if host == "" {
+		return nil, nil
+	}
+	if docs == nil {
+		return nil, errors.New("envio de email exige o armazenamento de documentos (ARMAZENAMENTO)")
+	}
+
+	porta := 587
+	if valor := os.Getenv("EMAIL_SMTP_PORTA"); valor != "" {
+		p, err := strconv.Atoi(valor)
+		if err != nil || p <= 0 || p > 65535 {
+			return nil, fmt.Errorf("EMAIL_SMTP_PORTA invalida: %q", valor)
+		}
+		porta = p
+	}
+
+	seguranca := strings.ToLower(os.Getenv("EMAIL_SMTP_SEGURANCA"))
+	switch seguranca {
+	case "":
+		seguranca = SegurancaSTARTTLS
+	case SegurancaSTARTTLS, SegurancaTLS, SegurancaNenhuma:
+	default:
+		return nil, fmt.Errorf("EMAIL_SMTP_SEGURANCA invalida: %q (use starttls, tls ou nenhuma)", seguranca)
+	}
+
+	remetente := os.Getenv("EMAIL_REMETENTE")
+	if _, err := mail.ParseAddress(remetente); err != nil {
+		return nil, fmt.Errorf("EMAIL_REMETENTE invalido: %w", err)
+	}
+
+	return &Despachante{
+		DB:            db,
+		Armazenamento: docs,
+		Remetente:     remetente,
+		Transporte: &SMTP{
+			Host:      host,
+			Porta:     porta,
+			Usuario:   os.Getenv("EMAIL_SMTP_USUARIO"),
+			Senha:     os.Getenv("EMAIL_SMTP_SENHA"),
+			Seguranca: seguranca,
+		},
+	}, nil
+}
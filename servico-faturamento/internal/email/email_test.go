@@ -0,0 +1,260 @@
+package email_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/email"
+	"servico-faturamento/internal/nfe"
+)
+
+func novaMensagem() email.Mensagem {
+	return email.Mensagem{
+		De:        "Faturamento <nfe@empresa.com.br>",
+		Para:      "cliente@example.com",
+		Assunto:   "NF-e nº 123 - Comércio Ação",
+		Texto:     "Olá, segue a nota.",
+		HTML:      "<p>Olá, segue a nota.</p>",
+		MessageID: "<envio.1@empresa.com.br>",
+		Data:      time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC),
+		Anexos: []email.Anexo{
+			{Nome: "35260311222333000181550010000001231000001230.pdf", Tipo: "application/pdf", Conteudo: bytes.Repeat([]byte("%PDF"), 100)},
+			{Nome: "35260311222333000181550010000001231000001230-procNFe.xml", Tipo: "application/xml", Conteudo: []byte("<nfeProc/>")},
+		},
+	}
+}
+
+func TestMensagemMIME(t *testing.T) {
+	t.Run("deve montar cabeçalhos, corpo alternativo e anexos legíveis", func(t *testing.T) {
+		conteudo, err := novaMensagem().MIME()
+		if err != nil {
+			t.Fatalf("MIME() erro = %v", err)
+		}
+		msg, err := mail.ReadMessage(bytes.NewReader(conteudo))
+		if err != nil {
+			t.Fatalf("mensagem ilegível: %v", err)
+		}
+
+		assunto, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		if err != nil || assunto != "NF-e nº 123 - Comércio Ação" {
+			t.Errorf("Subject = %q (%v)", assunto, err)
+		}
+		if got := msg.Header.Get("Message-ID"); got != "<envio.1@empresa.com.br>" {
+			t.Errorf("Message-ID = %q", got)
+		}
+
+		tipo, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+		if err != nil || tipo != "multipart/mixed" {
+			t.Fatalf("Content-Type = %q (%v)", tipo, err)
+		}
+		partes := multipart.NewReader(msg.Body, params["boundary"])
+
+		corpo, err := partes.NextPart()
+		if err != nil {
+			t.Fatalf("sem corpo: %v", err)
+		}
+		if tipo, _, _ := mime.ParseMediaType(corpo.Header.Get("Content-Type")); tipo != "multipart/alternative" {
+			t.Errorf("corpo = %q, esperado multipart/alternative", tipo)
+		}
+
+		anexos := map[string][]byte{}
+		for {
+			parte, err := partes.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("parte ilegível: %v", err)
+			}
+			// NextPart decodifica só quoted-printable; o base64 dos anexos fica a cargo do leitor
+			dados, _ := io.ReadAll(parte)
+			anexos[parte.FileName()] = dados
+		}
+		if len(anexos) != 2 {
+			t.Fatalf("anexos = %d, esperado 2", len(anexos))
+		}
+		for _, anexo := range novaMensagem().Anexos {
+			codificado, ok := anexos[anexo.Nome]
+			if !ok {
+				t.Errorf("anexo %s ausente", anexo.Nome)
+				continue
+			}
+			if strings.Contains(string(codificado), string(anexo.Conteudo)) {
+				t.Errorf("anexo %s sem codificação base64", anexo.Nome)
+			}
+		}
+	})
+
+	t.Run("deve recusar destinatário inválido", func(t *testing.T) {
+		msg := novaMensagem()
+		msg.Para = "sem-arroba"
+		if _, err := msg.MIME(); err == nil {
+			t.Error("esperado erro para destinatário inválido")
+		}
+	})
+}
+
+func novoDocumento(modelo string) *nfe.NFe {
+	return &nfe.NFe{InfNFe: nfe.InfNFe{
+		ID:    "NFe35260311222333000181550010000001231000001230",
+		Ide:   nfe.Ide{Mod: modelo, Serie: "1", NNF: "123", DhEmi: "2026-03-10T11:00:00-03:00"},
+		Emit:  nfe.Emit{XNome: "Comércio & Cia"},
+		Dest:  &nfe.Dest{XNome: "Maria Silva"},
+		Total: nfe.Total{ICMSTot: nfe.ICMSTot{VNF: "1234567.8"}},
+	}}
+}
+
+func TestRenderizar(t *testing.T) {
+	t.Run("deve preencher o modelo com os dados da NF-e autorizada", func(t *testing.T) {
+		dados := email.DadosDoDocumento(novoDocumento(nfe.ModeloNFe), "135260000000001", false)
+		assunto, texto, html, err := email.Renderizar(dados)
+		if err != nil {
+			t.Fatalf("Renderizar() erro = %v", err)
+		}
+
+		if assunto != "NF-e nº 123 - Comércio & Cia" {
+			t.Errorf("assunto = %q", assunto)
+		}
+		for _, esperado := range []string{
+			"Maria Silva", "foi autorizada", "R$ 1.234.567,80", "10/03/2026",
+			"35260311222333000181550010000001231000001230", "135260000000001",
+		} {
+			if !strings.Contains(texto, esperado) {
+				t.Errorf("texto sem %q:\n%s", esperado, texto)
+			}
+		}
+		if !strings.Contains(html, "Comércio &amp; Cia") {
+			t.Errorf("HTML sem o emitente escapado:\n%s", html)
+		}
+	})
+
+	t.Run("deve identificar o reenvio e a NFC-e", func(t *testing.T) {
+		dados := email.DadosDoDocumento(novoDocumento(nfe.ModeloNFCe), "135260000000001", true)
+		assunto, texto, _, err := email.Renderizar(dados)
+		if err != nil {
+			t.Fatalf("Renderizar() erro = %v", err)
+		}
+		if assunto != "Reenvio: NFC-e nº 123 - Comércio & Cia" {
+			t.Errorf("assunto = %q", assunto)
+		}
+		if !strings.Contains(texto, "reenviamos") {
+			t.Errorf("texto sem o aviso de reenvio:\n%s", texto)
+		}
+	})
+}
+
+// servidorSMTP atende uma conversa SMTP respondendo respostaRcpt ao RCPT TO e
+// devolve pelo canal o conteúdo recebido no DATA
+func servidorSMTP(t *testing.T, respostaRcpt string) (int, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	recebido := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		responder := func(linha string) { conn.Write([]byte(linha + "\r\n")) }
+
+		responder("220 teste ESMTP")
+		for {
+			linha, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			comando := strings.ToUpper(strings.TrimSpace(linha))
+			switch {
+			case strings.HasPrefix(comando, "EHLO"):
+				responder("250 teste")
+			case strings.HasPrefix(comando, "RCPT"):
+				responder(respostaRcpt)
+			case comando == "DATA":
+				responder("354 envie")
+				var dados strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					dados.WriteString(l)
+				}
+				recebido <- dados.String()
+				responder("250 aceita")
+			case comando == "QUIT":
+				responder("221 tchau")
+				return
+			default:
+				responder("250 ok")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, recebido
+}
+
+func TestSMTPEnviar(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("deve entregar a mensagem ao servidor", func(t *testing.T) {
+		porta, recebido := servidorSMTP(t, "250 destinatario ok")
+		transporte := &email.SMTP{Host: "127.0.0.1", Porta: porta, Seguranca: email.SegurancaNenhuma}
+
+		if err := transporte.Enviar(ctx, novaMensagem()); err != nil {
+			t.Fatalf("Enviar() erro = %v", err)
+		}
+		if dados := <-recebido; !strings.Contains(dados, "Message-ID: <envio.1@empresa.com.br>") {
+			t.Errorf("mensagem recebida sem Message-ID:\n%s", dados)
+		}
+	})
+
+	t.Run("deve tratar recusa 5xx como devolução", func(t *testing.T) {
+		porta, _ := servidorSMTP(t, "550 5.1.1 mailbox unavailable")
+		transporte := &email.SMTP{Host: "127.0.0.1", Porta: porta, Seguranca: email.SegurancaNenhuma}
+
+		err := transporte.Enviar(ctx, novaMensagem())
+		if !errors.Is(err, email.ErrDestinatarioRecusado) {
+			t.Errorf("erro = %v, esperado ErrDestinatarioRecusado", err)
+		}
+	})
+
+	t.Run("deve tratar resposta 4xx como falha temporária", func(t *testing.T) {
+		porta, _ := servidorSMTP(t, "451 4.7.1 tente mais tarde")
+		transporte := &email.SMTP{Host: "127.0.0.1", Porta: porta, Seguranca: email.SegurancaNenhuma}
+
+		err := transporte.Enviar(ctx, novaMensagem())
+		if err == nil || errors.Is(err, email.ErrDestinatarioRecusado) {
+			t.Errorf("erro = %v, esperado falha temporária", err)
+		}
+	})
+
+	t.Run("deve falhar sem servidor", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		porta := ln.Addr().(*net.TCPAddr).Port
+		ln.Close()
+		transporte := &email.SMTP{Host: "127.0.0.1", Porta: porta, Seguranca: email.SegurancaNenhuma}
+
+		if err := transporte.Enviar(ctx, novaMensagem()); err == nil || errors.Is(err, email.ErrDestinatarioRecusado) {
+			t.Errorf("erro = %v, esperado falha de conexão", err)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"servico-faturamento/internal/nfe"
+)
+
+//go:embed modelos
+var arquivosModelos embed.FS
+
+var (
+	modeloAssunto = texttemplate.Must(texttemplate.New("assunto").Parse(
+		`{{if .Reenvio}}Reenvio: {{end}}{{.Documento}} nº {{.Numero}} - {{.Emitente}}`))
+	modeloTexto = texttemplate.Must(texttemplate.ParseFS(arquivosModelos, "modelos/nota.txt"))
+	modeloHTML  = htmltemplate.Must(htmltemplate.ParseFS(arquivosModelos, "modelos/nota.html"))
+)
+
+// DadosModelo são os campos da nota impressos no email
+type DadosModelo struct {
+	Documento    string // NF-e ou NFC-e
+	Numero       string
+	Serie        string
+	ChaveAcesso  string
+	Protocolo    string
+	Emitente     string
+	Destinatario string
+	Valor        string // R$ 1.234,56
+	DataEmissao  string // dd/mm/aaaa
+	Reenvio      bool
+}
+
+// DadosDoDocumento lê os campos do email do XML autorizado, o mesmo anexado
+func DadosDoDocumento(doc *nfe.NFe, protocolo string, reenvio bool) DadosModelo {
+	inf := doc.InfNFe
+	dados := DadosModelo{
+		Documento:   "NF-e",
+		Numero:      inf.Ide.NNF,
+		Serie:       inf.Ide.Serie,
+		ChaveAcesso: strings.TrimPrefix(inf.ID, "NFe"),
+		Protocolo:   protocolo,
+		Emitente:    inf.Emit.XNome,
+		Valor:       formatarReais(inf.Total.ICMSTot.VNF),
+		DataEmissao: inf.Ide.DhEmi,
+		Reenvio:     reenvio,
+	}
+	if inf.Ide.Mod == nfe.ModeloNFCe {
+		dados.Documento = "NFC-e"
+	}
+	if inf.Dest != nil {
+		dados.Destinatario = inf.Dest.XNome
+	}
+	if t, err := time.Parse(time.RFC3339, inf.Ide.DhEmi); err == nil {
+		dados.DataEmissao = t.Format("02/01/2006")
+	}
+	return dados
+}
+
+// Renderizar monta o assunto e os corpos em texto e HTML
+func Renderizar(dados DadosModelo) (assunto, texto, html string, err error) {
+	var buf bytes.Buffer
+	if err := modeloAssunto.Execute(&buf, dados); err != nil {
+		return "", "", "", err
+	}
+	assunto = buf.String()
+
+	buf.Reset()
+	if err := modeloTexto.Execute(&buf, dados); err != nil {
+		return "", "", "", err
+	}
+	texto = buf.String()
+
+	buf.Reset()
+	if err := modeloHTML.Execute(&buf, dados); err != nil {
+		return "", "", "", err
+	}
+	return assunto, texto, buf.String(), nil
+}
+
+// formatarReais imprime o valor do XML (1234.56) como R$ 1.234,56
+func formatarReais(valor string) string {
+	inteiro, fracao, _ := strings.Cut(valor, ".")
+	var b strings.Builder
+	for i, c := range inteiro {
+		if i > 0 && (len(inteiro)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(c)
+	}
+	return "R$ " + b.String() + "," + (fracao + "00")[:2]
+}
@@ -0,0 +1,108 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// Segurança da conexão SMTP
+const (
+	SegurancaSTARTTLS = "starttls" // STARTTLS quando o servidor oferece
+	SegurancaTLS      = "tls"      // TLS desde a conexão (porta 465)
+	SegurancaNenhuma  = "nenhuma"  // texto puro, como no MailHog
+)
+
+// prazoConexaoSMTP limita a conexão quando o contexto não tem prazo
+const prazoConexaoSMTP = 30 * time.Second
+
+// SMTP entrega as mensagens a um servidor SMTP
+type SMTP struct {
+	Host      string
+	Porta     int
+	Usuario   string
+	Senha     string
+	Seguranca string
+}
+
+// Enviar transmite a mensagem. Recusa 5xx do destinatário ou do conteúdo devolve
+// ErrDestinatarioRecusado; falhas de conexão e respostas 4xx podem ser repetidas.
+func (s *SMTP) Enviar(ctx context.Context, msg Mensagem) error {
+	conteudo, err := msg.MIME()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrEnvioInviavel, err)
+	}
+	de, _ := mail.ParseAddress(msg.De)
+	para, _ := mail.ParseAddress(msg.Para)
+
+	endereco := net.JoinHostPort(s.Host, strconv.Itoa(s.Porta))
+	dialer := &net.Dialer{Timeout: prazoConexaoSMTP}
+	var conn net.Conn
+	if s.Seguranca == SegurancaTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: s.Host}}).DialContext(ctx, "tcp", endereco)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", endereco)
+	}
+	if err != nil {
+		return fmt.Errorf("falha ao conectar ao servidor SMTP: %w", err)
+	}
+	prazo, ok := ctx.Deadline()
+	if !ok {
+		prazo = time.Now().Add(prazoConexaoSMTP)
+	}
+	conn.SetDeadline(prazo)
+
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("falha na saudacao SMTP: %w", err)
+	}
+	defer c.Close()
+
+	if s.Seguranca == SegurancaSTARTTLS {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: s.Host}); err != nil {
+				return fmt.Errorf("falha no STARTTLS: %w", err)
+			}
+		}
+	}
+	if s.Usuario != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Usuario, s.Senha, s.Host)); err != nil {
+			return fmt.Errorf("falha na autenticacao SMTP: %w", err)
+		}
+	}
+
+	if err := c.Mail(de.Address); err != nil {
+		return fmt.Errorf("remetente recusado: %w", err)
+	}
+	if err := c.Rcpt(para.Address); err != nil {
+		return recusa(err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return recusa(err)
+	}
+	if _, err := w.Write(conteudo); err != nil {
+		return fmt.Errorf("falha ao transmitir mensagem: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return recusa(err)
+	}
+	return c.Quit()
+}
+
+// recusa separa as respostas 5xx, definitivas, das falhas temporárias
+func recusa(err error) error {
+	var resposta *textproto.Error
+	if errors.As(err, &resposta) && resposta.Code >= 500 {
+		return fmt.Errorf("%w: %d %s", ErrDestinatarioRecusado, resposta.Code, resposta.Msg)
+	}
+	return fmt.Errorf("falha no envio SMTP: %w", err)
+}
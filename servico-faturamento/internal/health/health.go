@@ -5,9 +5,14 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
-	amqp "github.com/rabbitmq/amqp091-go"
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/contingencia"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/mensageria"
+
 	"gorm.io/gorm"
 )
 
@@ -21,15 +26,22 @@ type HealthResponse struct {
 }
 
 type CheckResult struct {
-	Status    string  `json:"status"`
-	LatencyMs int64   `json:"latency_ms,omitempty"`
-	Error     string  `json:"error,omitempty"`
+	Status        string `json:"status"`
+	LatencyMs     int64  `json:"latency_ms,omitempty"`
+	Error         string `json:"error,omitempty"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
+	DaysRemaining *int   `json:"days_remaining,omitempty"`
+	Mode          string `json:"mode,omitempty"`
+	Since         string `json:"since,omitempty"`
+	Pending       *int64 `json:"pending,omitempty"`
+	Reconnects    int    `json:"reconnects,omitempty"`
 }
 
 var startTime = time.Now()
 
-// Handler retorna HTTP handler para /health endpoint
-func Handler(db *gorm.DB) http.HandlerFunc {
+// Handler retorna HTTP handler para /health endpoint; certificado pode ser nil e
+// conexoes são as conexões RabbitMQ mantidas pelo serviço (nil quando desabilitado)
+func Handler(db *gorm.DB, certificado *assinatura.Certificado, conexoes ...*mensageria.Conexao) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
 		defer cancel()
@@ -40,15 +52,24 @@ func Handler(db *gorm.DB) http.HandlerFunc {
 		checks["database"] = checkDatabase(ctx, db)
 
 		// Check RabbitMQ
-		checks["rabbitmq"] = checkRabbitMQ(ctx)
+		checks["rabbitmq"] = checkRabbitMQ(conexoes)
+
+		// Check certificado A1 (aviso antes do vencimento)
+		checks["certificado"] = CheckCertificado(certificado, time.Now(), assinatura.AlertaExpiracao())
 
-		// Determinar status geral
+		// Check modo de emissão (contingência SVC/EPEC)
+		checks["contingencia"] = checkContingencia(db)
+
+		// Determinar status geral; avisos não derrubam a instância
 		overallStatus := "healthy"
 		for _, check := range checks {
 			if check.Status == "fail" {
 				overallStatus = "unhealthy"
 				break
 			}
+			if check.Status == "warn" {
+				overallStatus = "degraded"
+			}
 		}
 
 		response := HealthResponse{
@@ -92,47 +113,95 @@ func checkDatabase(ctx context.Context, db *gorm.DB) CheckResult {
 	}
 }
 
-func checkRabbitMQ(ctx context.Context) CheckResult {
-	rabbitURL := os.Getenv("RABBITMQ_URL")
+// CheckCertificado informa a validade do certificado de assinatura. Vencido ou perto
+// do vencimento retorna "warn": a emissão para, mas reiniciar a instância não resolve.
+func CheckCertificado(certificado *assinatura.Certificado, agora time.Time, alerta time.Duration) CheckResult {
+	if certificado == nil {
+		return CheckResult{Status: "ok", Error: "certificado A1 nao configurado (XML sem assinatura)"}
+	}
+
+	dias := certificado.DiasParaExpirar(agora)
+	result := CheckResult{
+		Status:        "ok",
+		ExpiresAt:     certificado.X509.NotAfter.UTC().Format(time.RFC3339),
+		DaysRemaining: &dias,
+	}
+	switch {
+	case !agora.Before(certificado.X509.NotAfter):
+		result.Status = "warn"
+		result.Error = "certificado A1 vencido"
+	case certificado.ExpiraEm(agora, alerta):
+		result.Status = "warn"
+		result.Error = "certificado A1 vence em breve"
+	}
+	return result
+}
+
+func checkContingencia(db *gorm.DB) CheckResult {
+	vigente, err := contingencia.Atual(db)
+	if err != nil {
+		return CheckResult{Status: "warn", Error: "falha ao consultar modo de emissao: " + err.Error()}
+	}
+	pendentes, err := contingencia.Pendentes(db)
+	if err != nil {
+		return CheckResult{Status: "warn", Error: "falha ao contar notas pendentes: " + err.Error()}
+	}
+	return CheckContingencia(vigente, pendentes)
+}
 
+// CheckContingencia informa o modo de emissão e as notas fechadas que aguardam o
+// autorizador. Em contingência retorna "warn": a emissão continua, fora do normal.
+func CheckContingencia(vigente *dominio.Contingencia, pendentes int64) CheckResult {
+	result := CheckResult{Status: "ok", Mode: dominio.ModoNormal, Pending: &pendentes}
+	if vigente != nil {
+		result.Status = "warn"
+		result.Mode = vigente.Modo
+		result.Since = vigente.DataInicio.UTC().Format(time.RFC3339)
+		result.Error = "emissao em contingencia: " + vigente.Justificativa
+	}
+	return result
+}
+
+func checkRabbitMQ(conexoes []*mensageria.Conexao) CheckResult {
+	estados := make([]mensageria.Estado, 0, len(conexoes))
+	for _, conexao := range conexoes {
+		if conexao != nil {
+			estados = append(estados, conexao.Estado())
+		}
+	}
+	return CheckRabbitMQ(estados)
+}
+
+// CheckRabbitMQ informa o estado das conexões mantidas pelo serviço, sem abrir
+// conexão nova. Conexão caída retorna "warn": a reconexão é automática e reiniciar
+// a instância não resolve.
+func CheckRabbitMQ(estados []mensageria.Estado) CheckResult {
 	// Se RabbitMQ estiver desabilitado (Lambda/EventBridge), retorna ok com aviso
-	if rabbitURL == "" || rabbitURL == "disabled" {
+	if len(estados) == 0 {
 		return CheckResult{
 			Status: "ok",
 			Error:  "rabbitmq disabled (using EventBridge)",
 		}
 	}
 
-	checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	start := time.Now()
-
-	// Channel para resultado assíncrono
-	resultChan := make(chan error, 1)
-
-	go func() {
-		conn, err := amqp.Dial(rabbitURL)
-		if err != nil {
-			resultChan <- err
-			return
+	result := CheckResult{Status: "ok"}
+	var caidas []string
+	for _, estado := range estados {
+		result.Reconnects += estado.Reconexoes
+		if estado.Conectada {
+			continue
 		}
-		conn.Close()
-		resultChan <- nil
-	}()
-
-	select {
-	case <-checkCtx.Done():
-		return CheckResult{Status: "fail", Error: "timeout connecting to RabbitMQ"}
-	case err := <-resultChan:
-		if err != nil {
-			return CheckResult{Status: "fail", Error: err.Error()}
-		}
-		return CheckResult{
-			Status:    "ok",
-			LatencyMs: time.Since(start).Milliseconds(),
+		caida := "conexao " + estado.Nome + " desconectada desde " + estado.Desde.UTC().Format(time.RFC3339)
+		if estado.UltimoErro != "" {
+			caida += ": " + estado.UltimoErro
 		}
+		caidas = append(caidas, caida)
+	}
+	if len(caidas) > 0 {
+		result.Status = "warn"
+		result.Error = strings.Join(caidas, "; ")
 	}
+	return result
 }
 
 func getEnv(key, defaultValue string) string {
@@ -0,0 +1,62 @@
+package health_test
+
+import (
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/health"
+	"servico-faturamento/internal/mensageria"
+)
+
+func TestCheckRabbitMQ(t *testing.T) {
+	queda := time.Date(2025, 3, 10, 14, 30, 0, 0, time.FixedZone("BRT", -3*60*60))
+
+	t.Run("deve informar o RabbitMQ desabilitado sem conexoes", func(t *testing.T) {
+		result := health.CheckRabbitMQ(nil)
+		if result.Status != "ok" || result.Error != "rabbitmq disabled (using EventBridge)" {
+			t.Errorf("resultado inesperado: %+v", result)
+		}
+	})
+
+	t.Run("deve ficar ok com as conexoes de pe e somar as reconexoes", func(t *testing.T) {
+		result := health.CheckRabbitMQ([]mensageria.Estado{
+			{Nome: "publicacao", Conectada: true, Desde: queda, Reconexoes: 2},
+			{Nome: "consumo", Conectada: true, Desde: queda, Reconexoes: 1},
+		})
+		if result.Status != "ok" || result.Error != "" {
+			t.Errorf("resultado inesperado: %+v", result)
+		}
+		if result.Reconnects != 3 {
+			t.Errorf("reconnects = %d, esperava 3", result.Reconnects)
+		}
+	})
+
+	t.Run("deve avisar a conexao caida com o momento da queda e o ultimo erro", func(t *testing.T) {
+		result := health.CheckRabbitMQ([]mensageria.Estado{
+			{Nome: "publicacao", Conectada: true, Desde: queda, Reconexoes: 1},
+			{Nome: "consumo", Desde: queda, UltimoErro: "Exception (501) Reason: \"EOF\"", Reconexoes: 1},
+		})
+		if result.Status != "warn" {
+			t.Errorf("status = %s, esperava warn", result.Status)
+		}
+		esperado := "conexao consumo desconectada desde 2025-03-10T17:30:00Z: Exception (501) Reason: \"EOF\""
+		if result.Error != esperado {
+			t.Errorf("error = %q, esperava %q", result.Error, esperado)
+		}
+		if result.Reconnects != 2 {
+			t.Errorf("reconnects = %d, esperava 2", result.Reconnects)
+		}
+	})
+
+	t.Run("deve listar todas as conexoes caidas", func(t *testing.T) {
+		result := health.CheckRabbitMQ([]mensageria.Estado{
+			{Nome: "publicacao", Desde: queda},
+			{Nome: "consumo", Desde: queda, UltimoErro: "dial tcp: connection refused"},
+		})
+		esperado := "conexao publicacao desconectada desde 2025-03-10T17:30:00Z; " +
+			"conexao consumo desconectada desde 2025-03-10T17:30:00Z: dial tcp: connection refused"
+		if result.Status != "warn" || result.Error != esperado {
+			t.Errorf("resultado inesperado: %+v", result)
+		}
+	})
+}
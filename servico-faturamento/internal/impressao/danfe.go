@@ -0,0 +1,655 @@
+package impressao
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"servico-faturamento/internal/codigobarras"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Medidas do DANFE retrato em A4 (MOC, Anexo II), em milímetros
+const (
+	margemDANFE     = 5.0
+	larguraDANFE    = 200.0
+	alturaA4        = 297.0
+	alturaLinha     = 7.0
+	alturaTitulo    = 3.5
+	alturaAdicional = 30.0
+)
+
+// coluna da tabela de produtos: cabeçalho em duas linhas, largura e alinhamento
+type coluna struct {
+	titulo      [2]string
+	largura     float64
+	alinhamento string
+}
+
+var colunasProdutos = []coluna{
+	{[2]string{"CÓDIGO", "PRODUTO"}, 22, "L"},
+	{[2]string{"DESCRIÇÃO DO PRODUTO / SERVIÇO", ""}, 45, "L"},
+	{[2]string{"NCM/SH", ""}, 13, "C"},
+	{[2]string{"O/CST", ""}, 9, "C"},
+	{[2]string{"CFOP", ""}, 9, "C"},
+	{[2]string{"UN", ""}, 8, "C"},
+	{[2]string{"QUANT.", ""}, 14, "R"},
+	{[2]string{"VALOR", "UNIT."}, 15, "R"},
+	{[2]string{"VALOR", "TOTAL"}, 15, "R"},
+	{[2]string{"B.CÁLC.", "ICMS"}, 13, "R"},
+	{[2]string{"VALOR", "ICMS"}, 11, "R"},
+	{[2]string{"VALOR", "IPI"}, 10, "R"},
+	{[2]string{"ALÍQ.", "ICMS"}, 8, "R"},
+	{[2]string{"ALÍQ.", "IPI"}, 8, "R"},
+}
+
+var modalidadesFrete = map[string]string{
+	"0": "0-Por conta do Emit.",
+	"1": "1-Por conta do Dest.",
+	"2": "2-Por conta de Terceiros",
+	"3": "3-Próprio Remetente",
+	"4": "4-Próprio Destinatário",
+	"9": "9-Sem Transporte",
+}
+
+// danfe imprime o DANFE da NF-e a partir do documento emitido; a nota traz o que
+// fica fora do XML (protocolo, EPEC e situação)
+type danfe struct {
+	pdf  *gofpdf.Fpdf
+	tr   func(string) string
+	doc  *nfe.NFe
+	nota dominio.NotaFiscal
+	// barras é o Code-128C da chave de acesso, repetido no cabeçalho de cada folha
+	barras []bool
+	folhas int
+}
+
+// gerarDANFE monta o DANFE retrato: canhoto, emitente, destinatário, cálculo do
+// imposto, transporte, produtos (continuados nas folhas seguintes) e dados adicionais
+func gerarDANFE(doc *nfe.NFe, nota dominio.NotaFiscal) (*gofpdf.Fpdf, error) {
+	barras, err := codigobarras.Code128C(chaveDoDocumento(doc))
+	if err != nil {
+		return nil, fmt.Errorf("falha ao gerar codigo de barras da chave: %w", err)
+	}
+
+	// A primeira passada só conta as folhas do "FOLHA n/total"; o anexo de CC-e,
+	// acrescentado depois, não entra na conta
+	rascunho := novoDANFE(doc, nota, barras, 0)
+	rascunho.desenhar()
+	d := novoDANFE(doc, nota, barras, rascunho.pdf.PageNo())
+	d.desenhar()
+	return d.pdf, nil
+}
+
+func novoDANFE(doc *nfe.NFe, nota dominio.NotaFiscal, barras []bool, folhas int) *danfe {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margemDANFE, margemDANFE, margemDANFE)
+	pdf.SetAutoPageBreak(false, 0)
+	return &danfe{pdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor(""), doc: doc, nota: nota, barras: barras, folhas: folhas}
+}
+
+func (d *danfe) desenhar() {
+	d.novaFolha()
+	y := d.canhoto(margemDANFE)
+	y = d.cabecalho(y)
+	y = d.destinatario(y)
+	y = d.calculoImposto(y)
+	y = d.transporte(y)
+	d.dadosAdicionais(alturaA4 - margemDANFE - alturaTitulo - alturaAdicional)
+	d.produtos(y, alturaA4-margemDANFE-alturaTitulo-alturaAdicional-1)
+}
+
+func chaveDoDocumento(doc *nfe.NFe) string {
+	return strings.TrimPrefix(doc.InfNFe.ID, "NFe")
+}
+
+// novaFolha abre a página com a marca d'água da situação da nota, que fica por
+// baixo dos quadros
+func (d *danfe) novaFolha() {
+	d.pdf.AddPage()
+	marca := d.marcaDagua()
+	if marca == "" {
+		return
+	}
+	d.pdf.SetFont("Arial", "B", 42)
+	d.pdf.SetTextColor(215, 215, 215)
+	d.pdf.TransformBegin()
+	d.pdf.TransformRotate(35, 105, 170)
+	largura := d.pdf.GetStringWidth(d.tr(marca))
+	d.pdf.Text(105-largura/2, 170, d.tr(marca))
+	d.pdf.TransformEnd()
+	d.pdf.SetTextColor(0, 0, 0)
+}
+
+// marcaDagua indica, na ordem de precedência, a situação que impede o uso do DANFE
+func (d *danfe) marcaDagua() string {
+	switch {
+	case d.nota.Status == dominio.StatusNotaCancelada:
+		return "NF-e CANCELADA"
+	case d.nota.Status == dominio.StatusNotaDenegada:
+		return "USO DENEGADO"
+	case d.doc.InfNFe.Ide.TpAmb == "2":
+		return "SEM VALOR FISCAL"
+	case d.nota.ProtocoloAutorizacao == nil && d.nota.ProtocoloEPEC == nil:
+		return "AGUARDANDO AUTORIZAÇÃO"
+	}
+	return ""
+}
+
+// campo desenha um quadro com o rótulo no alto e o valor embaixo, reduzido até caber
+func (d *danfe) campo(x, y, w, h float64, rotulo, valor, alinhamento string) {
+	d.pdf.Rect(x, y, w, h, "D")
+	d.pdf.SetFont("Arial", "", 5)
+	d.pdf.SetXY(x+0.5, y+0.3)
+	d.pdf.CellFormat(w-1, 2.2, d.tr(rotulo), "", 0, "L", false, 0, "")
+	if valor == "" {
+		return
+	}
+	tamanho := 8.0
+	d.pdf.SetFont("Arial", "", tamanho)
+	for tamanho > 5 && d.pdf.GetStringWidth(d.tr(valor)) > w-1 {
+		tamanho -= 0.5
+		d.pdf.SetFontSize(tamanho)
+	}
+	d.pdf.SetXY(x+0.5, y+h-4.2)
+	d.pdf.CellFormat(w-1, 4, d.tr(valor), "", 0, alinhamento, false, 0, "")
+}
+
+// campoLinha é um quadro de uma linha do DANFE
+type campoLinha struct {
+	rotulo, valor string
+	largura       float64
+	alinhamento   string
+}
+
+// linhaCampos distribui os campos lado a lado a partir da margem esquerda
+func (d *danfe) linhaCampos(y float64, campos ...campoLinha) {
+	x := margemDANFE
+	for _, c := range campos {
+		d.campo(x, y, c.largura, alturaLinha, c.rotulo, c.valor, c.alinhamento)
+		x += c.largura
+	}
+}
+
+func (d *danfe) titulo(y float64, texto string) float64 {
+	d.pdf.SetFont("Arial", "B", 6)
+	d.pdf.SetXY(margemDANFE, y)
+	d.pdf.CellFormat(larguraDANFE, alturaTitulo, d.tr(texto), "", 0, "L", false, 0, "")
+	return y + alturaTitulo
+}
+
+// canhoto é o comprovante de entrega, destacável, que só sai na primeira folha
+func (d *danfe) canhoto(y float64) float64 {
+	inf := d.doc.InfNFe
+	destino := ""
+	if inf.Dest != nil {
+		destino = " DESTINATÁRIO: " + inf.Dest.XNome
+		if e := inf.Dest.EnderDest; e != nil {
+			destino += fmt.Sprintf(" - %s, %s - %s - %s/%s", e.XLgr, e.Nro, e.XBairro, e.XMun, e.UF)
+		}
+	}
+	texto := fmt.Sprintf("RECEBEMOS DE %s OS PRODUTOS E/OU SERVIÇOS CONSTANTES DA NOTA FISCAL ELETRÔNICA INDICADA ABAIXO. EMISSÃO: %s VALOR TOTAL: R$ %s%s",
+		inf.Emit.XNome, formatarData(inf.Ide.DhEmi, "02/01/2006"), formatarNumero(inf.Total.ICMSTot.VNF), destino)
+
+	d.pdf.Rect(margemDANFE, y, 160, 8, "D")
+	d.pdf.SetFont("Arial", "", 5.5)
+	d.pdf.SetXY(margemDANFE+0.5, y+0.5)
+	d.pdf.MultiCell(159, 2.4, d.tr(texto), "", "L", false)
+	d.campo(margemDANFE, y+8, 40, 8, "DATA DE RECEBIMENTO", "", "L")
+	d.campo(margemDANFE+40, y+8, 120, 8, "IDENTIFICAÇÃO E ASSINATURA DO RECEBEDOR", "", "L")
+
+	d.pdf.Rect(margemDANFE+160, y, 40, 16, "D")
+	d.pdf.SetXY(margemDANFE+160, y+2)
+	d.pdf.SetFont("Arial", "B", 11)
+	d.pdf.CellFormat(40, 5, "NF-e", "", 2, "C", false, 0, "")
+	d.pdf.SetFont("Arial", "B", 8)
+	d.pdf.CellFormat(40, 4, d.tr("Nº "+formatarNumeroNota(inf.Ide.NNF)), "", 2, "C", false, 0, "")
+	d.pdf.CellFormat(40, 4, d.tr("Série "+fmt.Sprintf("%03s", inf.Ide.Serie)), "", 2, "C", false, 0, "")
+
+	corte := y + 18
+	d.pdf.SetDashPattern([]float64{1, 1}, 0)
+	d.pdf.Line(margemDANFE, corte, margemDANFE+larguraDANFE, corte)
+	d.pdf.SetDashPattern([]float64{}, 0)
+	return corte + 2
+}
+
+// cabecalho repete em todas as folhas o emitente, o quadro DANFE, o código de
+// barras da chave, a natureza da operação e o protocolo
+func (d *danfe) cabecalho(y float64) float64 {
+	inf := d.doc.InfNFe
+	emit := inf.Emit
+	const altura = 32.0
+
+	// Emitente
+	d.pdf.Rect(margemDANFE, y, 80, altura, "D")
+	d.pdf.SetFont("Arial", "", 5)
+	d.pdf.SetXY(margemDANFE+0.5, y+0.3)
+	d.pdf.CellFormat(79, 2.2, d.tr("IDENTIFICAÇÃO DO EMITENTE"), "", 2, "L", false, 0, "")
+	d.pdf.SetXY(margemDANFE+1, y+5)
+	d.pdf.SetFont("Arial", "B", 9)
+	d.pdf.MultiCell(78, 4, d.tr(emit.XNome), "", "C", false)
+	d.pdf.SetFont("Arial", "", 7)
+	end := emit.EnderEmit
+	logradouro := end.XLgr + ", " + end.Nro
+	if end.XCpl != "" {
+		logradouro += " - " + end.XCpl
+	}
+	for _, linha := range []string{
+		logradouro,
+		end.XBairro + " - CEP " + formatarCEP(end.CEP),
+		end.XMun + " - " + end.UF + foneFormatado(end.Fone),
+	} {
+		d.pdf.SetX(margemDANFE + 1)
+		d.pdf.CellFormat(78, 3.5, d.tr(linha), "", 2, "C", false, 0, "")
+	}
+
+	// Quadro DANFE
+	x := margemDANFE + 80
+	d.pdf.Rect(x, y, 34, altura, "D")
+	d.pdf.SetXY(x, y+1)
+	d.pdf.SetFont("Arial", "B", 12)
+	d.pdf.CellFormat(34, 5, "DANFE", "", 2, "C", false, 0, "")
+	d.pdf.SetFont("Arial", "", 6)
+	d.pdf.MultiCell(34, 2.6, d.tr("Documento Auxiliar da Nota Fiscal Eletrônica"), "", "C", false)
+	d.pdf.SetXY(x+3, y+13)
+	d.pdf.CellFormat(20, 3, "0 - ENTRADA", "", 2, "L", false, 0, "")
+	d.pdf.CellFormat(20, 3, d.tr("1 - SAÍDA"), "", 0, "L", false, 0, "")
+	d.pdf.Rect(x+24, y+13, 6, 6, "D")
+	d.pdf.SetXY(x+24, y+13)
+	d.pdf.SetFont("Arial", "B", 10)
+	d.pdf.CellFormat(6, 6, inf.Ide.TpNF, "", 0, "C", false, 0, "")
+	d.pdf.SetXY(x, y+20)
+	d.pdf.SetFont("Arial", "B", 8)
+	d.pdf.CellFormat(34, 3.6, d.tr("Nº "+formatarNumeroNota(inf.Ide.NNF)), "", 2, "C", false, 0, "")
+	d.pdf.CellFormat(34, 3.6, d.tr("SÉRIE "+fmt.Sprintf("%03s", inf.Ide.Serie)), "", 2, "C", false, 0, "")
+	d.pdf.CellFormat(34, 3.6, fmt.Sprintf("FOLHA %d/%d", d.pdf.PageNo(), d.folhas), "", 2, "C", false, 0, "")
+
+	// Código de barras e chave de acesso
+	x += 34
+	d.pdf.Rect(x, y, 86, 13, "D")
+	d.desenharBarras(x+3, y+1.5, 80, 10)
+	d.campo(x, y+13, 86, alturaLinha, "CHAVE DE ACESSO", agruparChave(chaveDoDocumento(d.doc)), "C")
+	d.pdf.Rect(x, y+20, 86, altura-20, "D")
+	d.pdf.SetXY(x+1, y+22)
+	d.pdf.SetFont("Arial", "", 7)
+	d.pdf.MultiCell(84, 3.2, d.tr(d.textoConsulta()), "", "C", false)
+
+	y += altura
+	rotulo, protocolo := d.protocolo()
+	d.linhaCampos(y,
+		campoLinha{"NATUREZA DA OPERAÇÃO", inf.Ide.NatOp, 114, "L"},
+		campoLinha{rotulo, protocolo, 86, "C"},
+	)
+	y += alturaLinha
+	d.linhaCampos(y,
+		campoLinha{"INSCRIÇÃO ESTADUAL", emit.IE, 66, "L"},
+		campoLinha{"INSC. ESTADUAL DO SUBST. TRIBUT.", "", 67, "L"},
+		campoLinha{"CNPJ", formatarCNPJ(emit.CNPJ), 67, "L"},
+	)
+	return y + alturaLinha + 1
+}
+
+// textoConsulta é o aviso do quadro da chave; em contingência explica a emissão
+func (d *danfe) textoConsulta() string {
+	switch d.doc.InfNFe.Ide.TpEmis {
+	case dominio.TipoEmissaoEPEC:
+		return "DANFE impresso em contingência - EPEC regularmente recebido pela Receita Federal do Brasil"
+	case dominio.TipoEmissaoSVCAN, dominio.TipoEmissaoSVCRS:
+		return "DANFE emitido em contingência (SVC). Consulta de autenticidade no portal nacional da NF-e www.nfe.fazenda.gov.br/portal"
+	}
+	return "Consulta de autenticidade no portal nacional da NF-e www.nfe.fazenda.gov.br/portal ou no site da Sefaz Autorizadora"
+}
+
+// protocolo devolve o rótulo e o valor do quadro de protocolo: o de autorização
+// de uso ou, enquanto a nota em EPEC aguarda o autorizador, o do evento
+func (d *danfe) protocolo() (string, string) {
+	switch {
+	case d.nota.ProtocoloAutorizacao != nil:
+		valor := *d.nota.ProtocoloAutorizacao
+		if d.nota.DataAutorizacao != nil {
+			valor += " - " + d.nota.DataAutorizacao.In(dominio.FusoBrasilia).Format("02/01/2006 15:04:05")
+		}
+		rotulo := "PROTOCOLO DE AUTORIZAÇÃO DE USO"
+		if d.nota.Status == dominio.StatusNotaDenegada {
+			rotulo = "PROTOCOLO DE DENEGAÇÃO DE USO"
+		}
+		return rotulo, valor
+	case d.nota.ProtocoloEPEC != nil:
+		valor := *d.nota.ProtocoloEPEC
+		if d.nota.DataEPEC != nil {
+			valor += " - " + d.nota.DataEPEC.In(dominio.FusoBrasilia).Format("02/01/2006 15:04:05")
+		}
+		return "PROTOCOLO DO EPEC", valor
+	}
+	return "PROTOCOLO DE AUTORIZAÇÃO DE USO", ""
+}
+
+// desenharBarras imprime os módulos do Code-128C esticados na largura do quadro
+func (d *danfe) desenharBarras(x, y, largura, altura float64) {
+	modulo := largura / float64(len(d.barras))
+	d.pdf.SetFillColor(0, 0, 0)
+	for i := 0; i < len(d.barras); {
+		if !d.barras[i] {
+			i++
+			continue
+		}
+		inicio := i
+		for i < len(d.barras) && d.barras[i] {
+			i++
+		}
+		d.pdf.Rect(x+float64(inicio)*modulo, y, float64(i-inicio)*modulo, altura, "F")
+	}
+}
+
+func (d *danfe) destinatario(y float64) float64 {
+	y = d.titulo(y, "DESTINATÁRIO / REMETENTE")
+	dest := d.doc.InfNFe.Dest
+	if dest == nil {
+		dest = &nfe.Dest{}
+	}
+	documento := formatarCNPJ(dest.CNPJ)
+	if dest.CPF != "" {
+		documento = formatarCPF(dest.CPF)
+	}
+	end := nfe.Endereco{}
+	if dest.EnderDest != nil {
+		end = *dest.EnderDest
+	}
+	logradouro := strings.Trim(end.XLgr+", "+end.Nro, ", ")
+	if end.XCpl != "" {
+		logradouro += " - " + end.XCpl
+	}
+	dhEmi := d.doc.InfNFe.Ide.DhEmi
+
+	d.linhaCampos(y,
+		campoLinha{"NOME / RAZÃO SOCIAL", dest.XNome, 120, "L"},
+		campoLinha{"CNPJ / CPF", documento, 45, "C"},
+		campoLinha{"DATA DA EMISSÃO", formatarData(dhEmi, "02/01/2006"), 35, "C"},
+	)
+	y += alturaLinha
+	d.linhaCampos(y,
+		campoLinha{"ENDEREÇO", logradouro, 90, "L"},
+		campoLinha{"BAIRRO / DISTRITO", end.XBairro, 45, "L"},
+		campoLinha{"CEP", formatarCEP(end.CEP), 30, "C"},
+		campoLinha{"DATA DA SAÍDA/ENTRADA", "", 35, "C"},
+	)
+	y += alturaLinha
+	d.linhaCampos(y,
+		campoLinha{"MUNICÍPIO", end.XMun, 70, "L"},
+		campoLinha{"FONE / FAX", end.Fone, 35, "C"},
+		campoLinha{"UF", end.UF, 10, "C"},
+		campoLinha{"INSCRIÇÃO ESTADUAL", dest.IE, 50, "C"},
+		campoLinha{"HORA DA SAÍDA/ENTRADA", "", 35, "C"},
+	)
+	return y + alturaLinha + 1
+}
+
+func (d *danfe) calculoImposto(y float64) float64 {
+	y = d.titulo(y, "CÁLCULO DO IMPOSTO")
+	tot := d.doc.InfNFe.Total.ICMSTot
+	const w7, w6 = larguraDANFE / 7, larguraDANFE / 6
+	d.linhaCampos(y,
+		campoLinha{"BASE DE CÁLC. DO ICMS", formatarNumero(tot.VBC), w7, "R"},
+		campoLinha{"VALOR DO ICMS", formatarNumero(tot.VICMS), w7, "R"},
+		campoLinha{"BASE DE CÁLC. ICMS S.T.", formatarNumero(tot.VBCST), w7, "R"},
+		campoLinha{"VALOR DO ICMS SUBST.", formatarNumero(tot.VST), w7, "R"},
+		campoLinha{"VALOR DO PIS", formatarNumero(tot.VPIS), w7, "R"},
+		campoLinha{"VALOR DA COFINS", formatarNumero(tot.VCOFINS), w7, "R"},
+		campoLinha{"V. TOTAL PRODUTOS", formatarNumero(tot.VProd), w7, "R"},
+	)
+	y += alturaLinha
+	d.linhaCampos(y,
+		campoLinha{"VALOR DO FRETE", formatarNumero(tot.VFrete), w6, "R"},
+		campoLinha{"VALOR DO SEGURO", formatarNumero(tot.VSeg), w6, "R"},
+		campoLinha{"DESCONTO", formatarNumero(tot.VDesc), w6, "R"},
+		campoLinha{"OUTRAS DESPESAS", formatarNumero(tot.VOutro), w6, "R"},
+		campoLinha{"VALOR TOTAL DO IPI", formatarNumero(tot.VIPI), w6, "R"},
+		campoLinha{"VALOR TOTAL DA NOTA", formatarNumero(tot.VNF), w6, "R"},
+	)
+	y += alturaLinha
+
+	// Reforma Tributária: IBS e CBS destacados quando a nota tem o grupo W03
+	if ibs := d.doc.InfNFe.Total.IBSCBSTot; ibs != nil {
+		const w4 = larguraDANFE / 4
+		d.linhaCampos(y,
+			campoLinha{"BASE DE CÁLC. IBS/CBS", formatarNumero(ibs.VBCIBSCBS), w4, "R"},
+			campoLinha{"VALOR DO IBS UF", formatarNumero(ibs.GIBS.GIBSUF.VIBSUF), w4, "R"},
+			campoLinha{"VALOR DO IBS MUNICIPAL", formatarNumero(ibs.GIBS.GIBSMun.VIBSMun), w4, "R"},
+			campoLinha{"VALOR DA CBS", formatarNumero(ibs.GCBS.VCBS), w4, "R"},
+		)
+		y += alturaLinha
+	}
+	return y + 1
+}
+
+func (d *danfe) transporte(y float64) float64 {
+	y = d.titulo(y, "TRANSPORTADOR / VOLUMES TRANSPORTADOS")
+	modFrete := d.doc.InfNFe.Transp.ModFrete
+	if texto, ok := modalidadesFrete[modFrete]; ok {
+		modFrete = texto
+	}
+	d.linhaCampos(y,
+		campoLinha{"NOME / RAZÃO SOCIAL", "", 60, "L"},
+		campoLinha{"FRETE POR CONTA", modFrete, 35, "C"},
+		campoLinha{"CÓDIGO ANTT", "", 25, "C"},
+		campoLinha{"PLACA DO VEÍCULO", "", 25, "C"},
+		campoLinha{"UF", "", 10, "C"},
+		campoLinha{"CNPJ / CPF", "", 45, "C"},
+	)
+	y += alturaLinha
+	d.linhaCampos(y,
+		campoLinha{"ENDEREÇO", "", 85, "L"},
+		campoLinha{"MUNICÍPIO", "", 50, "L"},
+		campoLinha{"UF", "", 10, "C"},
+		campoLinha{"INSCRIÇÃO ESTADUAL", "", 55, "C"},
+	)
+	y += alturaLinha
+	d.linhaCampos(y,
+		campoLinha{"QUANTIDADE", "", 25, "R"},
+		campoLinha{"ESPÉCIE", "", 30, "L"},
+		campoLinha{"MARCA", "", 30, "L"},
+		campoLinha{"NUMERAÇÃO", "", 35, "L"},
+		campoLinha{"PESO BRUTO", "", 40, "R"},
+		campoLinha{"PESO LÍQUIDO", "", 40, "R"},
+	)
+	return y + alturaLinha + 1
+}
+
+// produtos imprime os itens até o limite da folha e continua nas seguintes, que
+// repetem o cabeçalho do DANFE e o da tabela
+func (d *danfe) produtos(y, limite float64) {
+	y = d.cabecalhoProdutos(y)
+	topo := y
+	const entrelinha = 2.6
+	for _, det := range d.doc.InfNFe.Det {
+		celulas := d.celulasItem(det)
+		d.pdf.SetFont("Arial", "", 6)
+		linhas := make([][]string, len(celulas))
+		altura := 0.0
+		for i, texto := range celulas {
+			for _, l := range d.pdf.SplitLines([]byte(d.tr(texto)), colunasProdutos[i].largura-1) {
+				linhas[i] = append(linhas[i], string(l))
+			}
+			altura = max(altura, float64(len(linhas[i]))*entrelinha+1)
+		}
+
+		if y+altura > limite {
+			d.fecharTabela(topo, y)
+			d.novaFolha()
+			y = d.cabecalhoProdutos(d.cabecalho(margemDANFE))
+			topo = y
+			limite = alturaA4 - margemDANFE
+		}
+
+		x := margemDANFE
+		for i, col := range colunasProdutos {
+			for n, l := range linhas[i] {
+				d.pdf.SetXY(x+0.5, y+0.5+float64(n)*entrelinha)
+				d.pdf.CellFormat(col.largura-1, entrelinha, l, "", 0, col.alinhamento, false, 0, "")
+			}
+			x += col.largura
+		}
+		y += altura
+		d.pdf.SetDrawColor(190, 190, 190)
+		d.pdf.Line(margemDANFE, y, margemDANFE+larguraDANFE, y)
+		d.pdf.SetDrawColor(0, 0, 0)
+	}
+	d.fecharTabela(topo, y)
+}
+
+// fecharTabela traça as divisões das colunas e a moldura dos itens da folha
+func (d *danfe) fecharTabela(topo, base float64) {
+	x := margemDANFE
+	for _, col := range colunasProdutos {
+		d.pdf.Line(x, topo, x, base)
+		x += col.largura
+	}
+	d.pdf.Line(x, topo, x, base)
+	d.pdf.Line(margemDANFE, base, x, base)
+}
+
+func (d *danfe) cabecalhoProdutos(y float64) float64 {
+	y = d.titulo(y, "DADOS DOS PRODUTOS / SERVIÇOS")
+	d.pdf.SetFont("Arial", "", 5)
+	x := margemDANFE
+	for _, col := range colunasProdutos {
+		d.pdf.Rect(x, y, col.largura, 6, "D")
+		for n, texto := range col.titulo {
+			d.pdf.SetXY(x, y+0.5+float64(n)*2.4)
+			d.pdf.CellFormat(col.largura, 2.4, d.tr(texto), "", 0, "C", false, 0, "")
+		}
+		x += col.largura
+	}
+	return y + 6
+}
+
+// celulasItem monta o texto de cada coluna do item a partir dos grupos do XML
+func (d *danfe) celulasItem(det nfe.Det) []string {
+	prod := det.Prod
+	descricao := prod.XProd
+	if det.InfAdProd != "" {
+		descricao += " " + det.InfAdProd
+	}
+
+	var cst, vBC, pICMS, vICMS string
+	icms := det.Imposto.ICMS
+	switch {
+	case icms.ICMS00 != nil:
+		cst, vBC, pICMS, vICMS = icms.ICMS00.Orig+icms.ICMS00.CST, icms.ICMS00.VBC, icms.ICMS00.PICMS, icms.ICMS00.VICMS
+	case icms.ICMS20 != nil:
+		cst, vBC, pICMS, vICMS = icms.ICMS20.Orig+icms.ICMS20.CST, icms.ICMS20.VBC, icms.ICMS20.PICMS, icms.ICMS20.VICMS
+	case icms.ICMS40 != nil:
+		cst = icms.ICMS40.Orig + icms.ICMS40.CST
+	case icms.ICMSSN101 != nil:
+		cst = icms.ICMSSN101.Orig + icms.ICMSSN101.CSOSN
+	case icms.ICMSSN102 != nil:
+		cst = icms.ICMSSN102.Orig + icms.ICMSSN102.CSOSN
+	}
+	var vIPI, pIPI string
+	if ipi := det.Imposto.IPI; ipi != nil && ipi.IPITrib != nil {
+		vIPI, pIPI = ipi.IPITrib.VIPI, ipi.IPITrib.PIPI
+	}
+
+	return []string{
+		prod.CProd, descricao, prod.NCM, cst, prod.CFOP, prod.UCom,
+		formatarNumero(prod.QCom), formatarNumero(prod.VUnCom), formatarNumero(prod.VProd),
+		formatarNumero(vBC), formatarNumero(vICMS), formatarNumero(vIPI),
+		formatarNumero(pICMS), formatarNumero(pIPI),
+	}
+}
+
+// dadosAdicionais ocupa o rodapé da primeira folha com as informações
+// complementares e as mensagens de contingência e de homologação
+func (d *danfe) dadosAdicionais(y float64) {
+	y = d.titulo(y, "DADOS ADICIONAIS")
+	d.campo(margemDANFE, y, 130, alturaAdicional, "INFORMAÇÕES COMPLEMENTARES", "", "L")
+	d.campo(margemDANFE+130, y, 70, alturaAdicional, "RESERVADO AO FISCO", "", "L")
+
+	d.pdf.SetFont("Arial", "", 6)
+	d.pdf.SetXY(margemDANFE+0.5, y+3)
+	d.pdf.MultiCell(129, 2.6, d.tr(strings.Join(d.informacoesComplementares(), "\n")), "", "L", false)
+	if fisco := d.doc.InfNFe.InfAdic; fisco != nil && fisco.InfAdFisco != "" {
+		d.pdf.SetXY(margemDANFE+130.5, y+3)
+		d.pdf.MultiCell(69, 2.6, d.tr(fisco.InfAdFisco), "", "L", false)
+	}
+}
+
+func (d *danfe) informacoesComplementares() []string {
+	ide := d.doc.InfNFe.Ide
+	var linhas []string
+	if ide.TpAmb == "2" {
+		linhas = append(linhas, "NF-E EMITIDA EM AMBIENTE DE HOMOLOGAÇÃO - SEM VALOR FISCAL")
+	}
+	if ide.TpEmis != dominio.TipoEmissaoNormal {
+		linhas = append(linhas, fmt.Sprintf("DANFE EM CONTINGÊNCIA, IMPRESSO EM DECORRÊNCIA DE PROBLEMAS TÉCNICOS. Entrada em contingência: %s. Justificativa: %s",
+			formatarData(ide.DhCont, "02/01/2006 15:04:05"), ide.XJust))
+	}
+	if inf := d.doc.InfNFe.InfAdic; inf != nil && inf.InfCpl != "" {
+		linhas = append(linhas, inf.InfCpl)
+	}
+	return linhas
+}
+
+// formatarNumero troca o ponto decimal do XML pela vírgula e separa os milhares
+func formatarNumero(valor string) string {
+	if valor == "" {
+		return ""
+	}
+	inteiro, fracao, temFracao := strings.Cut(valor, ".")
+	sinal := ""
+	if strings.HasPrefix(inteiro, "-") {
+		sinal, inteiro = "-", inteiro[1:]
+	}
+	var b strings.Builder
+	for i, c := range inteiro {
+		if i > 0 && (len(inteiro)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(c)
+	}
+	if temFracao {
+		return sinal + b.String() + "," + fracao
+	}
+	return sinal + b.String()
+}
+
+// formatarNumeroNota imprime o nNF com 9 dígitos em grupos de 3 (000.000.010)
+func formatarNumeroNota(nNF string) string {
+	n := fmt.Sprintf("%09s", nNF)
+	if len(n) != 9 {
+		return nNF
+	}
+	return n[:3] + "." + n[3:6] + "." + n[6:]
+}
+
+func formatarData(dataHora, leiaute string) string {
+	t, err := time.Parse(time.RFC3339, dataHora)
+	if err != nil {
+		return dataHora
+	}
+	return t.Format(leiaute)
+}
+
+func formatarCPF(cpf string) string {
+	if len(cpf) != 11 {
+		return cpf
+	}
+	return cpf[:3] + "." + cpf[3:6] + "." + cpf[6:9] + "-" + cpf[9:]
+}
+
+func formatarCEP(cep string) string {
+	if len(cep) != 8 {
+		return cep
+	}
+	return cep[:5] + "-" + cep[5:]
+}
+
+func foneFormatado(fone string) string {
+	if fone == "" {
+		return ""
+	}
+	return " - Fone: " + fone
+}
@@ -0,0 +1,139 @@
+// Package impressao gera o DANFE das notas: o retrato A4 da NF-e, com o anexo das
+// cartas de correção, e o simplificado de 80mm da NFC-e. É usado pela Lambda
+// lambda-pdf e pelo worker RabbitMQ da API.
+package impressao
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Versões dos leiautes, gravadas com cada PDF na tabela documentos. Toda mudança
+// no desenho do DANFE deve trocar a versão, para que a regeneração das notas
+// antigas fique registrada como correção de leiaute.
+const (
+	VersaoLayoutDANFE     = "danfe-nfe-1"
+	VersaoLayoutDANFENFCe = "danfe-nfce-1"
+)
+
+// ErrNotaAberta indica nota ainda sem chave de acesso; o DANFE sai no fechamento
+var ErrNotaAberta = errors.New("nota ainda nao fechada")
+
+// VersaoLayout é a versão do leiaute usado no DANFE da nota
+func VersaoLayout(nota dominio.NotaFiscal) string {
+	if nota.Modelo == nfe.ModeloNFCe {
+		return VersaoLayoutDANFENFCe
+	}
+	return VersaoLayoutDANFE
+}
+
+// Gerar imprime o DANFE da nota: a NFC-e (modelo 65) sai na bobina de 80mm e a NF-e
+// no retrato A4. A nota precisa vir com itens, emitente, cliente, pagamentos e
+// cartas de correção carregados. O resultado é determinístico: a mesma nota gera
+// os mesmos bytes, e só uma mudança na nota ou no leiaute muda o hash do PDF.
+func Gerar(nota dominio.NotaFiscal) ([]byte, error) {
+	var pdf *gofpdf.Fpdf
+	if nota.Modelo == nfe.ModeloNFCe {
+		var err error
+		if pdf, err = gerarDANFENFCe(nota); err != nil {
+			return nil, err
+		}
+	} else {
+		doc, err := documentoDaNota(nota)
+		if err != nil {
+			return nil, err
+		}
+		if pdf, err = gerarDANFE(doc, nota); err != nil {
+			return nil, err
+		}
+		if len(nota.CartasCorrecao) > 0 {
+			adicionarAnexoCartasCorrecao(pdf, nota)
+		}
+	}
+
+	// Sem as datas fixas o gofpdf grava o horário da geração nos metadados, e sem a
+	// ordenação o catálogo de fontes segue a ordem (aleatória) de um map
+	referencia := nota.DataCriacao
+	if nota.DataFechada != nil {
+		referencia = *nota.DataFechada
+	}
+	pdf.SetCreationDate(referencia)
+	pdf.SetModificationDate(referencia)
+	pdf.SetCatalogSort(true)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// documentoDaNota devolve a NF-e que o DANFE representa: o XML do nfeProc quando
+// a nota já passou pela SEFAZ ou, antes disso, o documento gravado no fechamento,
+// para que a reimpressão não traga cadastros alterados depois dele
+func documentoDaNota(nota dominio.NotaFiscal) (*nfe.NFe, error) {
+	if nota.XMLAutorizado != nil {
+		return nfe.LerNFe([]byte(*nota.XMLAutorizado))
+	}
+	if nota.Status == dominio.StatusNotaAberta || nota.ChaveAcesso == nil {
+		return nil, ErrNotaAberta
+	}
+	if nota.XMLFechamento != nil {
+		return nfe.LerNFe([]byte(*nota.XMLFechamento))
+	}
+	// Nota fechada antes do documento ser gravado: é montado como no fechamento
+	fechada := nota
+	fechada.Status = dominio.StatusNotaFechada
+	return nfe.Gerar(fechada, nfe.CarregarConfiguracao())
+}
+
+// adicionarAnexoCartasCorrecao imprime a CC-e vigente (a de maior sequência, que
+// substitui as anteriores) e o histórico das cartas substituídas
+func adicionarAnexoCartasCorrecao(pdf *gofpdf.Fpdf, nota dominio.NotaFiscal) {
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	dominio.MarcarCartaVigente(nota.CartasCorrecao)
+
+	pdf.SetMargins(10, 10, 10)
+	pdf.SetAutoPageBreak(true, 10)
+	pdf.AddPage()
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(0, 10, "ANEXO - CARTA DE CORRECAO ELETRONICA")
+	pdf.Ln(12)
+
+	if nota.ChaveAcesso != nil {
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(40, 6, "Chave de Acesso:")
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(0, 6, *nota.ChaveAcesso)
+		pdf.Ln(8)
+	}
+
+	for i := len(nota.CartasCorrecao) - 1; i >= 0; i-- {
+		carta := nota.CartasCorrecao[i]
+		titulo := fmt.Sprintf("Sequencia %d - %s", carta.Sequencia, carta.DataEvento.In(dominio.FusoBrasilia).Format("02/01/2006 15:04:05"))
+		if carta.Vigente {
+			titulo += " (vigente)"
+			pdf.SetFont("Arial", "B", 11)
+		} else {
+			titulo += " (substituida)"
+			pdf.SetFont("Arial", "I", 10)
+		}
+		pdf.Cell(0, 7, titulo)
+		pdf.Ln(7)
+		pdf.SetFont("Arial", "", 10)
+		pdf.MultiCell(0, 5, tr(carta.Correcao), "1", "L", false)
+		pdf.Ln(4)
+	}
+
+	pdf.SetFont("Arial", "B", 9)
+	pdf.Cell(0, 5, "Condicao de uso:")
+	pdf.Ln(5)
+	pdf.SetFont("Arial", "", 8)
+	pdf.MultiCell(0, 4, dominio.CondicaoUsoCartaCorrecao, "", "L", false)
+}
@@ -0,0 +1,187 @@
+package impressao_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/impressao"
+	"servico-faturamento/internal/nfe"
+
+	"github.com/google/uuid"
+)
+
+func configurarEmitente(t *testing.T) {
+	t.Helper()
+	t.Setenv("EMITENTE_CNPJ", "11.222.333/0001-81")
+	t.Setenv("EMITENTE_RAZAO_SOCIAL", "Empresa Teste LTDA")
+	t.Setenv("EMITENTE_LOGRADOURO", "Rua das Flores")
+	t.Setenv("EMITENTE_NUMERO", "100")
+	t.Setenv("EMITENTE_BAIRRO", "Centro")
+	t.Setenv("EMITENTE_COD_MUNICIPIO", "3550308")
+	t.Setenv("EMITENTE_MUNICIPIO", "Sao Paulo")
+	t.Setenv("EMITENTE_UF", "SP")
+	t.Setenv("EMITENTE_CEP", "01001-000")
+	t.Setenv("EMITENTE_IE", "111222333444")
+	t.Setenv("NFE_SERIE", "1")
+}
+
+func notaFechadaTeste(t *testing.T) dominio.NotaFiscal {
+	t.Helper()
+	fechada := time.Date(2025, 3, 10, 14, 30, 0, 0, time.UTC)
+	nota := dominio.NotaFiscal{
+		ID:          uuid.MustParse("a1b2c3d4-e5f6-4a5b-8c9d-0e1f2a3b4c5d"),
+		Numero:      "123",
+		Modelo:      nfe.ModeloNFe,
+		Serie:       1,
+		Status:      dominio.StatusNotaFechada,
+		DataCriacao: fechada.Add(-time.Hour),
+		DataFechada: &fechada,
+		Itens: []dominio.ItemNota{
+			{
+				ID:            uuid.New(),
+				ProdutoID:     uuid.New(),
+				Quantidade:    dominio.DecimalDeInteiro(2),
+				PrecoUnitario: dominio.MustParseDecimal("10.50"),
+				Descricao:     "Caneta esferografica azul",
+				NCM:           "96081000",
+				CFOP:          "5102",
+			},
+		},
+	}
+	if err := nfe.AtribuirChave(&nota, nfe.CarregarConfiguracao()); err != nil {
+		t.Fatalf("falha ao atribuir chave: %v", err)
+	}
+	return nota
+}
+
+func TestGerar(t *testing.T) {
+	t.Run("deve gerar o DANFE da nota fechada", func(t *testing.T) {
+		configurarEmitente(t)
+		pdf, err := impressao.Gerar(notaFechadaTeste(t))
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if !bytes.HasPrefix(pdf, []byte("%PDF")) {
+			t.Errorf("documento gerado nao e um PDF")
+		}
+	})
+
+	t.Run("deve gerar o mesmo PDF para a mesma nota", func(t *testing.T) {
+		configurarEmitente(t)
+		nota := notaFechadaTeste(t)
+		primeiro, err := impressao.Gerar(nota)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		time.Sleep(1100 * time.Millisecond)
+		segundo, err := impressao.Gerar(nota)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if !bytes.Equal(primeiro, segundo) {
+			t.Error("duas geracoes da mesma nota devem ter o mesmo conteudo (e o mesmo hash)")
+		}
+	})
+
+	t.Run("deve gerar o mesmo DANFE NFC-e para a mesma nota", func(t *testing.T) {
+		configurarEmitente(t)
+		t.Setenv("NFCE_SERIE", "2")
+		t.Setenv("NFCE_CSC_ID", "000001")
+		t.Setenv("NFCE_CSC", "0123456789ABCDEF0123456789ABCDEF")
+		nota := notaFechadaTeste(t)
+		nota.Modelo = nfe.ModeloNFCe
+		nota.Serie = 2
+		nota.Pagamentos = []dominio.PagamentoNota{{Forma: dominio.FormaDinheiro, Valor: dominio.MustParseDecimal("21.00")}}
+		if err := nfe.AtribuirChave(&nota, nfe.CarregarConfiguracao()); err != nil {
+			t.Fatalf("falha ao atribuir chave: %v", err)
+		}
+
+		primeiro, err := impressao.Gerar(nota)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		time.Sleep(1100 * time.Millisecond)
+		segundo, err := impressao.Gerar(nota)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if !bytes.Equal(primeiro, segundo) {
+			t.Error("duas geracoes da mesma NFC-e devem ter o mesmo conteudo (e o mesmo hash)")
+		}
+		if impressao.VersaoLayout(nota) != impressao.VersaoLayoutDANFENFCe {
+			t.Errorf("esperava leiaute %s, obteve %s", impressao.VersaoLayoutDANFENFCe, impressao.VersaoLayout(nota))
+		}
+	})
+
+	t.Run("deve anexar as cartas de correcao ao DANFE", func(t *testing.T) {
+		configurarEmitente(t)
+		nota := notaFechadaTeste(t)
+		semCarta, err := impressao.Gerar(nota)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+
+		nota.CartasCorrecao = []dominio.CartaCorrecao{
+			{Sequencia: 1, Correcao: "Corrigir a descricao do produto", DataEvento: *nota.DataFechada},
+		}
+		comCarta, err := impressao.Gerar(nota)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if len(comCarta) <= len(semCarta) {
+			t.Errorf("esperava anexo da CC-e no PDF (%d bytes sem carta, %d com carta)", len(semCarta), len(comCarta))
+		}
+	})
+
+	t.Run("deve imprimir o documento do fechamento mesmo com o cadastro alterado", func(t *testing.T) {
+		configurarEmitente(t)
+		nota := notaFechadaTeste(t)
+		doc, err := nfe.Gerar(nota, nfe.CarregarConfiguracao())
+		if err != nil {
+			t.Fatalf("Gerar() erro = %v", err)
+		}
+		xmlNFe, err := nfe.Serializar(doc)
+		if err != nil {
+			t.Fatalf("Serializar() erro = %v", err)
+		}
+		nota.Status = dominio.StatusNotaRejeitada
+		original, err := impressao.Gerar(nota)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		documento := string(xmlNFe)
+		nota.XMLFechamento = &documento
+
+		t.Setenv("EMITENTE_RAZAO_SOCIAL", "Outra Razao Social LTDA")
+		semRetrato := nota
+		semRetrato.XMLFechamento = nil
+		atual, err := impressao.Gerar(semRetrato)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if bytes.Equal(original, atual) {
+			t.Fatal("a troca da razao social deveria mudar o DANFE montado com o cadastro atual")
+		}
+
+		reimpresso, err := impressao.Gerar(nota)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if !bytes.Equal(original, reimpresso) {
+			t.Error("a reimpressao deve usar o documento do fechamento, nao o cadastro atual")
+		}
+	})
+
+	t.Run("deve recusar nota aberta", func(t *testing.T) {
+		configurarEmitente(t)
+		nota := notaFechadaTeste(t)
+		nota.Status = dominio.StatusNotaAberta
+		nota.ChaveAcesso = nil
+		if _, err := impressao.Gerar(nota); !errors.Is(err, impressao.ErrNotaAberta) {
+			t.Errorf("esperava ErrNotaAberta, obteve %v", err)
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package impressao
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+	"servico-faturamento/internal/qrcode"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Bobina de 80mm: a área útil fica em 72mm e a altura da página acompanha o conteúdo
+const (
+	larguraBobina = 80.0
+	margemBobina  = 4.0
+	larguraUtil   = larguraBobina - 2*margemBobina
+	ladoQRCode    = 35.0
+)
+
+// danfeNFCe reúne o que o DANFE NFC-e imprime além da própria nota
+type danfeNFCe struct {
+	nota     dominio.NotaFiscal
+	emit     nfe.Emit
+	dest     *nfe.Dest
+	supl     *nfe.InfNFeSupl
+	simbolo  *qrcode.Simbolo
+	ambiente string
+}
+
+// gerarDANFENFCe monta o DANFE NFC-e simplificado (leiaute do Manual do DANFE
+// NFC-e) em bobina de 80mm, com o QR Code do infNFeSupl do documento emitido
+func gerarDANFENFCe(nota dominio.NotaFiscal) (*gofpdf.Fpdf, error) {
+	doc, err := documentoDaNota(nota)
+	if err != nil {
+		return nil, err
+	}
+	if doc.InfNFeSupl == nil {
+		return nil, errors.New("NFC-e sem infNFeSupl")
+	}
+	danfe := danfeNFCe{nota: nota, emit: doc.InfNFe.Emit, dest: doc.InfNFe.Dest, supl: doc.InfNFeSupl, ambiente: doc.InfNFe.Ide.TpAmb}
+
+	simbolo, err := qrcode.Codificar(danfe.supl.QRCode.Texto)
+	if err != nil {
+		return nil, fmt.Errorf("falha ao gerar QR Code: %w", err)
+	}
+	danfe.simbolo = simbolo
+
+	// Primeira passada só mede a altura; a segunda imprime na página do tamanho exato
+	rascunho := novaBobina(1000)
+	altura := danfe.desenhar(rascunho) + margemBobina
+
+	pdf := novaBobina(altura)
+	danfe.desenhar(pdf)
+	return pdf, nil
+}
+
+func novaBobina(altura float64) *gofpdf.Fpdf {
+	pdf := gofpdf.NewCustom(&gofpdf.InitType{
+		UnitStr: "mm",
+		Size:    gofpdf.SizeType{Wd: larguraBobina, Ht: altura},
+	})
+	pdf.SetMargins(margemBobina, margemBobina, margemBobina)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	return pdf
+}
+
+// desenhar imprime as divisões do DANFE NFC-e e devolve a altura ocupada
+func (d danfeNFCe) desenhar(pdf *gofpdf.Fpdf) float64 {
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	centro := func(estilo string, tamanho float64, texto string) {
+		pdf.SetFont("Arial", estilo, tamanho)
+		pdf.MultiCell(larguraUtil, tamanho*0.45, tr(texto), "", "C", false)
+	}
+	linha := func() {
+		pdf.Ln(1)
+		y := pdf.GetY()
+		pdf.Line(margemBobina, y, larguraBobina-margemBobina, y)
+		pdf.Ln(1)
+	}
+
+	// Divisão I: emitente
+	end := d.emit.EnderEmit
+	centro("B", 8, d.emit.XNome)
+	centro("", 7, fmt.Sprintf("CNPJ: %s  IE: %s", formatarCNPJ(d.emit.CNPJ), d.emit.IE))
+	centro("", 7, fmt.Sprintf("%s, %s - %s - %s/%s", end.XLgr, end.Nro, end.XBairro, end.XMun, end.UF))
+	linha()
+	centro("B", 7, "Documento Auxiliar da Nota Fiscal de Consumidor Eletrônica")
+	d.avisos(pdf, centro)
+	linha()
+
+	// Divisão II: itens
+	colunas := []float64{12, 28, 9, 7, 8, 8}
+	pdf.SetFont("Arial", "B", 6)
+	for i, titulo := range []string{"Código", "Descrição", "Qtde", "UN", "Vl Unit", "Vl Total"} {
+		alinhamento := "L"
+		if i >= 2 {
+			alinhamento = "R"
+		}
+		pdf.CellFormat(colunas[i], 3.5, tr(titulo), "", 0, alinhamento, false, 0, "")
+	}
+	pdf.Ln(3.5)
+	pdf.SetFont("Arial", "", 6)
+	for _, item := range d.nota.Itens {
+		unidade := item.Unidade
+		if unidade == "" {
+			unidade = "UN"
+		}
+		pdf.CellFormat(colunas[0], 3, item.ProdutoID.String()[:8], "", 0, "L", false, 0, "")
+		pdf.CellFormat(colunas[1], 3, tr(truncar(item.Descricao, 24)), "", 0, "L", false, 0, "")
+		pdf.CellFormat(colunas[2], 3, item.Quantidade.FormatarMinimo(0), "", 0, "R", false, 0, "")
+		pdf.CellFormat(colunas[3], 3, unidade, "", 0, "R", false, 0, "")
+		pdf.CellFormat(colunas[4], 3, item.PrecoUnitario.Formatar(2), "", 0, "R", false, 0, "")
+		pdf.CellFormat(colunas[5], 3, item.CalcularSubtotal().Formatar(2), "", 1, "R", false, 0, "")
+	}
+	linha()
+
+	// Divisão III: totais e pagamento
+	totais := d.nota.CalcularTotais()
+	valor := func(estilo, rotulo, texto string) {
+		pdf.SetFont("Arial", estilo, 7)
+		pdf.CellFormat(larguraUtil-20, 3.5, tr(rotulo), "", 0, "L", false, 0, "")
+		pdf.CellFormat(20, 3.5, texto, "", 1, "R", false, 0, "")
+	}
+	valor("", "Qtde. total de itens", fmt.Sprintf("%d", len(d.nota.Itens)))
+	valor("", "Valor total R$", totais.VProd.Formatar(2))
+	valor("B", "Valor a Pagar R$", totais.VNF.Formatar(2))
+	valor("B", "FORMA PAGAMENTO", "VALOR PAGO R$")
+	if len(d.nota.Pagamentos) == 0 {
+		valor("", "Outros", totais.VNF.Formatar(2))
+	}
+	for _, p := range d.nota.Pagamentos {
+		valor("", p.DescricaoForma(), p.Valor.Formatar(2))
+	}
+	if troco := d.nota.Troco(); !troco.IsZero() {
+		valor("", "Troco R$", troco.Formatar(2))
+	}
+	if tributos := totais.VICMS.Add(totais.VIPI).Add(totais.VPIS).Add(totais.VCOFINS); !tributos.IsZero() {
+		valor("", "Tributos Totais Incidentes (Lei 12.741/2012)", tributos.Formatar(2))
+	}
+	linha()
+
+	// Divisão IV: consulta pela chave
+	centro("B", 7, "Consulte pela Chave de Acesso em")
+	centro("", 7, d.supl.URLChave)
+	centro("", 7, agruparChave(*d.nota.ChaveAcesso))
+	linha()
+
+	// Divisão V: consumidor
+	if c := d.dest; c != nil && (c.CNPJ != "" || c.CPF != "") {
+		rotulo, documento := "CPF", c.CPF
+		if c.CNPJ != "" {
+			rotulo, documento = "CNPJ", c.CNPJ
+		}
+		centro("B", 7, fmt.Sprintf("CONSUMIDOR - %s %s", rotulo, documento))
+		centro("", 7, c.XNome)
+	} else {
+		centro("B", 7, "CONSUMIDOR NÃO IDENTIFICADO")
+	}
+	linha()
+
+	// Divisão VI: identificação e protocolo
+	emissao := d.nota.DataCriacao
+	if d.nota.DataFechada != nil {
+		emissao = *d.nota.DataFechada
+	}
+	centro("B", 7, fmt.Sprintf("NFC-e nº %s  Série %03d  %s", d.nota.Numero, d.nota.Serie,
+		emissao.In(dominio.FusoBrasilia).Format("02/01/2006 15:04:05")))
+	if d.nota.ProtocoloAutorizacao != nil {
+		centro("B", 7, "Protocolo de autorização: "+*d.nota.ProtocoloAutorizacao)
+		if d.nota.DataAutorizacao != nil {
+			centro("B", 7, "Data de autorização: "+d.nota.DataAutorizacao.In(dominio.FusoBrasilia).Format("02/01/2006 15:04:05"))
+		}
+	}
+
+	// Divisão VII: QR Code centralizado, desenhado módulo a módulo
+	pdf.Ln(2)
+	modulo := ladoQRCode / float64(d.simbolo.Tamanho)
+	x0, y0 := (larguraBobina-ladoQRCode)/2, pdf.GetY()
+	pdf.SetFillColor(0, 0, 0)
+	for l := 0; l < d.simbolo.Tamanho; l++ {
+		for c := 0; c < d.simbolo.Tamanho; c++ {
+			if d.simbolo.Escuro(l, c) {
+				pdf.Rect(x0+float64(c)*modulo, y0+float64(l)*modulo, modulo, modulo, "F")
+			}
+		}
+	}
+	pdf.SetY(y0 + ladoQRCode + 2)
+	d.avisos(pdf, centro)
+	return pdf.GetY()
+}
+
+// avisos imprime as mensagens obrigatórias de homologação e de contingência offline
+func (d danfeNFCe) avisos(pdf *gofpdf.Fpdf, centro func(estilo string, tamanho float64, texto string)) {
+	if d.ambiente == "2" {
+		centro("B", 7, "EMITIDA EM AMBIENTE DE HOMOLOGAÇÃO - SEM VALOR FISCAL")
+	}
+	if d.nota.TipoEmissao == dominio.TipoEmissaoOffline {
+		centro("B", 7, "EMITIDA EM CONTINGÊNCIA")
+		if d.nota.ProtocoloAutorizacao == nil {
+			centro("B", 7, "Pendente de autorização")
+		}
+	}
+}
+
+// agruparChave separa a chave de acesso em blocos de 4 dígitos
+func agruparChave(chave string) string {
+	var blocos []string
+	for i := 0; i < len(chave); i += 4 {
+		blocos = append(blocos, chave[i:min(i+4, len(chave))])
+	}
+	return strings.Join(blocos, " ")
+}
+
+func formatarCNPJ(cnpj string) string {
+	if len(cnpj) != 14 {
+		return cnpj
+	}
+	return cnpj[:2] + "." + cnpj[2:5] + "." + cnpj[5:8] + "/" + cnpj[8:12] + "-" + cnpj[12:]
+}
+
+func truncar(texto string, limite int) string {
+	if r := []rune(texto); len(r) > limite {
+		return string(r[:limite])
+	}
+	return texto
+}
@@ -0,0 +1,193 @@
+package impressao
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/dominio"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrDANFEInviavel indica nota cujo DANFE não pôde ser montado; a solicitação já
+// foi marcada como FALHOU e repetir o evento não resolve
+var ErrDANFEInviavel = errors.New("DANFE nao pode ser gerado")
+
+// Processador gera o DANFE das notas, grava cada conteúdo novo como uma versão
+// imutável no armazenamento e na tabela documentos e conclui as solicitações de
+// impressão pendentes com a chave da versão
+type Processador struct {
+	DB            *gorm.DB
+	Armazenamento armazenamento.Armazenamento
+}
+
+// Processar atende um evento da nota (impressão solicitada, fechamento, autorização
+// ou carta de correção); motivo é o tipo do evento, registrado na versão gerada.
+// Notas ainda abertas são ignoradas: o DANFE precisa da chave de acesso e sai no
+// fechamento. Devolve erro quando o evento deve ser repetido; nesse caso a
+// solicitação continua PENDENTE.
+func (p *Processador) Processar(ctx context.Context, notaID uuid.UUID, motivo string) error {
+	slog.Info("Processing PDF generation", "notaId", notaID, "motivo", motivo)
+
+	nota, err := p.carregarNota(notaID)
+	if err != nil {
+		slog.Error("Nota not found", "error", err, "notaId", notaID)
+		return err
+	}
+
+	// Verificar se nota tem itens
+	if len(nota.Itens) == 0 {
+		slog.Warn("Nota has no items, skipping PDF generation", "notaId", notaID)
+		p.marcarFalha(notaID, "Nota sem itens não pode gerar PDF")
+		return nil
+	}
+
+	// A impressão é pedida com a nota aberta; o DANFE precisa da chave de acesso e
+	// é gerado nos eventos de fechamento e de autorização
+	if nota.Status == dominio.StatusNotaAberta || nota.ChaveAcesso == nil {
+		slog.Info("Nota not closed yet, DANFE will be generated on NotaFechada", "notaId", notaID)
+		return nil
+	}
+
+	pdfBytes, err := Gerar(nota)
+	if err != nil {
+		slog.Error("Failed to generate PDF", "error", err, "notaId", notaID)
+		p.marcarFalha(notaID, fmt.Sprintf("Falha ao gerar PDF: %v", err))
+		return fmt.Errorf("%w: %w", ErrDANFEInviavel, err)
+	}
+
+	doc, _, err := p.publicar(ctx, nota, pdfBytes, motivo)
+	if err != nil {
+		slog.Error("Failed to store PDF", "error", err, "notaId", notaID)
+		return err
+	}
+
+	slog.Info("PDF generated successfully", "notaId", notaID, "versao", doc.Versao, "hash", doc.HashSHA256)
+	return nil
+}
+
+// Regenerar gera o DANFE de novo fora dos eventos, por exemplo depois de uma
+// correção no leiaute. Só grava uma nova versão quando o conteúdo mudou: nova
+// indica se foi o caso; senão o documento devolvido é a versão vigente.
+func (p *Processador) Regenerar(ctx context.Context, notaID uuid.UUID, motivo string) (doc dominio.Documento, nova bool, err error) {
+	nota, err := p.carregarNota(notaID)
+	if err != nil {
+		return doc, false, err
+	}
+	if len(nota.Itens) == 0 {
+		return doc, false, fmt.Errorf("%w: nota sem itens", ErrDANFEInviavel)
+	}
+	if nota.Status == dominio.StatusNotaAberta || nota.ChaveAcesso == nil {
+		return doc, false, ErrNotaAberta
+	}
+
+	pdfBytes, err := Gerar(nota)
+	if err != nil {
+		return doc, false, fmt.Errorf("%w: %w", ErrDANFEInviavel, err)
+	}
+
+	doc, nova, err = p.publicar(ctx, nota, pdfBytes, motivo)
+	if err != nil {
+		return doc, false, err
+	}
+	slog.Info("PDF regenerated", "notaId", notaID, "versao", doc.Versao, "novaVersao", nova, "motivo", motivo)
+	return doc, nova, nil
+}
+
+func (p *Processador) carregarNota(notaID uuid.UUID) (dominio.NotaFiscal, error) {
+	var nota dominio.NotaFiscal
+	err := p.DB.Preload("Itens").Preload("Emitente").Preload("Cliente").Preload("Pagamentos").Preload("CartasCorrecao", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sequencia")
+	}).First(&nota, "id = ?", notaID).Error
+	return nota, err
+}
+
+// publicar grava o PDF como nova versão, a menos que a versão vigente já tenha o
+// mesmo hash (evento repetido ou nota sem mudanças), e conclui as solicitações
+// pendentes com a chave da versão vigente
+func (p *Processador) publicar(ctx context.Context, nota dominio.NotaFiscal, pdf []byte, motivo string) (dominio.Documento, bool, error) {
+	soma := sha256.Sum256(pdf)
+	hash := hex.EncodeToString(soma[:])
+
+	var doc dominio.Documento
+	nova := false
+	err := p.DB.Transaction(func(tx *gorm.DB) error {
+		// O lock da nota serializa as gerações concorrentes (a lambda-pdf recebe
+		// eventos em paralelo), para que cada versão seja a anterior + 1
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			First(&dominio.NotaFiscal{}, "id = ?", nota.ID).Error; err != nil {
+			return err
+		}
+
+		var vigente dominio.Documento
+		err := tx.Where("nota_id = ? AND tipo = ?", nota.ID, dominio.TipoDocumentoDANFE).
+			Order("versao DESC").First(&vigente).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && vigente.HashSHA256 == hash {
+			doc = vigente
+			return nil
+		}
+
+		doc = dominio.Documento{
+			NotaID:       nota.ID,
+			Tipo:         dominio.TipoDocumentoDANFE,
+			Versao:       vigente.Versao + 1,
+			VersaoLayout: VersaoLayout(nota),
+			HashSHA256:   hash,
+			Tamanho:      len(pdf),
+			Chave:        armazenamento.ChavePDFVersao(nota, vigente.Versao+1),
+			Motivo:       motivo,
+		}
+		// O arquivo vai antes do registro: sem o commit, a mesma chave é regravada
+		// na próxima tentativa e nenhuma versão registrada é sobrescrita
+		if err := p.Armazenamento.Salvar(ctx, doc.Chave, pdf, armazenamento.TipoPDF); err != nil {
+			return fmt.Errorf("falha ao salvar PDF: %w", err)
+		}
+		if err := tx.Create(&doc).Error; err != nil {
+			return fmt.Errorf("falha ao registrar versao do PDF: %w", err)
+		}
+		nova = true
+		return nil
+	})
+	if err != nil {
+		return doc, false, err
+	}
+
+	if err := p.concluirSolicitacoes(nota.ID, doc.Chave); err != nil {
+		return doc, nova, fmt.Errorf("falha ao concluir solicitacoes de impressao: %w", err)
+	}
+	return doc, nova, nil
+}
+
+// concluirSolicitacoes entrega a chave do PDF às solicitações que ainda não têm
+// PDF. O fechamento da nota já marca as pendentes como CONCLUIDA, sem chave, então
+// a seleção é pela pdf_chave e não pelo status; as que já têm chave guardam a da
+// versão que receberam. A chave não é um endereço: a URL é montada na consulta.
+func (p *Processador) concluirSolicitacoes(notaID uuid.UUID, chave string) error {
+	return p.DB.Model(&dominio.SolicitacaoImpressao{}).
+		Where("nota_id = ? AND pdf_chave IS NULL AND status <> ?", notaID, "FALHOU").
+		Updates(map[string]interface{}{
+			"status":         "CONCLUIDA",
+			"pdf_chave":      chave,
+			"data_conclusao": gorm.Expr("COALESCE(data_conclusao, ?)", time.Now()),
+		}).Error
+}
+
+func (p *Processador) marcarFalha(notaID uuid.UUID, mensagem string) {
+	p.DB.Model(&dominio.SolicitacaoImpressao{}).
+		Where("nota_id = ? AND status = ?", notaID, "PENDENTE").
+		Updates(map[string]interface{}{
+			"status":        "FALHOU",
+			"mensagem_erro": mensagem,
+		})
+}
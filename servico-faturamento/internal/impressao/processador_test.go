@@ -0,0 +1,89 @@
+package impressao_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/impressao"
+	"servico-faturamento/internal/manipulador"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// bancoTeste cria um banco SQLite descartável com as tabelas do fechamento e da
+// impressão
+func bancoTeste(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "faturamento.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("falha ao abrir banco de teste: %v", err)
+	}
+	err = db.AutoMigrate(
+		&dominio.Emitente{},
+		&dominio.Cliente{},
+		&dominio.RegraTributaria{},
+		&dominio.AliquotaIBSCBS{},
+		&dominio.NotaFiscal{},
+		&dominio.ItemNota{},
+		&dominio.PagamentoNota{},
+		&dominio.CartaCorrecao{},
+		&dominio.Contingencia{},
+		&dominio.SolicitacaoImpressao{},
+		&dominio.Documento{},
+		&dominio.EventoOutbox{},
+	)
+	if err != nil {
+		t.Fatalf("falha ao criar tabelas: %v", err)
+	}
+	return db
+}
+
+func TestProcessadorNotaFechada(t *testing.T) {
+	t.Run("deve gravar a chave do PDF na solicitacao concluida no fechamento", func(t *testing.T) {
+		configurarEmitente(t)
+		db := bancoTeste(t)
+
+		nota := notaFechadaTeste(t)
+		nota.Status = dominio.StatusNotaAberta
+		nota.DataFechada = nil
+		nota.ChaveAcesso = nil
+		if err := db.Create(&nota).Error; err != nil {
+			t.Fatalf("falha ao criar nota: %v", err)
+		}
+		solicitacao := dominio.SolicitacaoImpressao{NotaID: nota.ID, ChaveIdempotencia: "impressao-teste"}
+		if err := db.Create(&solicitacao).Error; err != nil {
+			t.Fatalf("falha ao criar solicitacao: %v", err)
+		}
+
+		handlers := &manipulador.Handlers{DB: db}
+		if err := handlers.FecharNota(nota.ID); err != nil {
+			t.Fatalf("FecharNota() erro = %v", err)
+		}
+
+		processador := &impressao.Processador{DB: db, Armazenamento: armazenamento.NovoMemoria()}
+		if err := processador.Processar(context.Background(), nota.ID, manipulador.EventoNotaFechada); err != nil {
+			t.Fatalf("Processar() erro = %v", err)
+		}
+
+		var atual dominio.SolicitacaoImpressao
+		if err := db.First(&atual, "id = ?", solicitacao.ID).Error; err != nil {
+			t.Fatalf("falha ao recarregar solicitacao: %v", err)
+		}
+		if atual.Status != "CONCLUIDA" {
+			t.Errorf("status = %s, esperava CONCLUIDA", atual.Status)
+		}
+		if chave := armazenamento.ChavePDFVersao(nota, 1); atual.PdfChave == nil || *atual.PdfChave != chave {
+			t.Errorf("pdf_chave = %v, esperava %s", atual.PdfChave, chave)
+		}
+		if atual.DataConclusao == nil {
+			t.Error("solicitacao sem data de conclusao")
+		}
+	})
+}
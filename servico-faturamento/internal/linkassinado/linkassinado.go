@@ -0,0 +1,104 @@
+// Package linkassinado gera e confere links de download com prazo de validade. A
+// assinatura é um HMAC-SHA256 do caminho e do vencimento com um segredo do serviço,
+// que confere o link ele mesmo; por isso vale para qualquer backend de armazenamento.
+package linkassinado
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrLinkInvalido indica link sem parâmetros, adulterado ou assinado com outro segredo
+	ErrLinkInvalido = errors.New("link de download invalido")
+	// ErrLinkExpirado indica link com assinatura válida e prazo vencido
+	ErrLinkExpirado = errors.New("link de download expirado")
+	// ErrLinksDesabilitados indica serviço sem LINKS_SEGREDO
+	ErrLinksDesabilitados = errors.New("links assinados nao configurados (LINKS_SEGREDO)")
+)
+
+// Parâmetros de consulta acrescentados ao caminho
+const (
+	ParamExpira     = "expira"
+	ParamAssinatura = "assinatura"
+)
+
+// tamanhoMinimoSegredo evita segredos curtos o bastante para força bruta
+const tamanhoMinimoSegredo = 32
+
+// Assinador emite e confere os links. URLBase é o endereço público da API, somado
+// aos caminhos; vazio devolve links relativos.
+type Assinador struct {
+	segredo  []byte
+	Validade time.Duration
+	URLBase  string
+}
+
+func NovoAssinador(segredo []byte, validade time.Duration, urlBase string) (*Assinador, error) {
+	if len(segredo) < tamanhoMinimoSegredo {
+		return nil, fmt.Errorf("segredo dos links deve ter ao menos %d bytes", tamanhoMinimoSegredo)
+	}
+	if validade <= 0 {
+		return nil, errors.New("validade dos links deve ser positiva")
+	}
+	return &Assinador{segredo: segredo, Validade: validade, URLBase: strings.TrimRight(urlBase, "/")}, nil
+}
+
+// CarregarConfigurado lê LINKS_SEGREDO, LINKS_VALIDADE_MINUTOS (padrão 15) e
+// LINKS_URL_BASE. Sem segredo devolve nil, nil: a emissão de links fica desligada.
+func CarregarConfigurado() (*Assinador, error) {
+	segredo := os.Getenv("LINKS_SEGREDO")
+	if segredo == "" {
+		return nil, nil
+	}
+	validade := 15 * time.Minute
+	if v := os.Getenv("LINKS_VALIDADE_MINUTOS"); v != "" {
+		minutos, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("LINKS_VALIDADE_MINUTOS invalido: %w", err)
+		}
+		validade = time.Duration(minutos) * time.Minute
+	}
+	return NovoAssinador([]byte(segredo), validade, os.Getenv("LINKS_URL_BASE"))
+}
+
+func (a *Assinador) assinar(caminho string, expira int64) string {
+	mac := hmac.New(sha256.New, a.segredo)
+	mac.Write([]byte(caminho))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatInt(expira, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Gerar devolve o link do caminho (que deve começar com "/") e o seu vencimento
+func (a *Assinador) Gerar(caminho string, agora time.Time) (string, time.Time) {
+	expira := agora.Add(a.Validade).Truncate(time.Second)
+	consulta := url.Values{}
+	consulta.Set(ParamExpira, strconv.FormatInt(expira.Unix(), 10))
+	consulta.Set(ParamAssinatura, a.assinar(caminho, expira.Unix()))
+	return a.URLBase + caminho + "?" + consulta.Encode(), expira
+}
+
+// Conferir valida a assinatura antes do prazo, para que links adulterados não
+// revelem se estão vencidos
+func (a *Assinador) Conferir(caminho, expira, assinatura string, agora time.Time) error {
+	segundos, err := strconv.ParseInt(expira, 10, 64)
+	if err != nil || assinatura == "" {
+		return ErrLinkInvalido
+	}
+	if !hmac.Equal([]byte(assinatura), []byte(a.assinar(caminho, segundos))) {
+		return ErrLinkInvalido
+	}
+	if !agora.Before(time.Unix(segundos, 0)) {
+		return ErrLinkExpirado
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package linkassinado_test
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/linkassinado"
+)
+
+const segredo = "0123456789abcdef0123456789abcdef"
+
+func parametros(t *testing.T, link string) (string, string, string) {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("link invalido: %v", err)
+	}
+	return u.Path, u.Query().Get(linkassinado.ParamExpira), u.Query().Get(linkassinado.ParamAssinatura)
+}
+
+func TestAssinador(t *testing.T) {
+	a, err := linkassinado.NovoAssinador([]byte(segredo), 15*time.Minute, "https://api.exemplo.com/")
+	if err != nil {
+		t.Fatalf("erro inesperado: %v", err)
+	}
+	agora := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	caminho := "/api/v1/documentos/notas/7f9c2d1e-0000-4000-8000-000000000001/pdf"
+
+	t.Run("deve aceitar o link gerado dentro do prazo", func(t *testing.T) {
+		link, expira := a.Gerar(caminho, agora)
+		if !strings.HasPrefix(link, "https://api.exemplo.com"+caminho+"?") {
+			t.Errorf("link inesperado: %s", link)
+		}
+		if !expira.Equal(agora.Add(15 * time.Minute)) {
+			t.Errorf("vencimento inesperado: %v", expira)
+		}
+		p, e, s := parametros(t, link)
+		if err := a.Conferir(p, e, s, agora.Add(14*time.Minute)); err != nil {
+			t.Errorf("erro inesperado: %v", err)
+		}
+	})
+
+	t.Run("deve recusar link vencido", func(t *testing.T) {
+		link, _ := a.Gerar(caminho, agora)
+		p, e, s := parametros(t, link)
+		if err := a.Conferir(p, e, s, agora.Add(15*time.Minute)); !errors.Is(err, linkassinado.ErrLinkExpirado) {
+			t.Errorf("esperava ErrLinkExpirado, obteve %v", err)
+		}
+	})
+
+	t.Run("deve recusar link de outro documento, prazo estendido ou outro segredo", func(t *testing.T) {
+		link, _ := a.Gerar(caminho, agora)
+		p, e, s := parametros(t, link)
+
+		if err := a.Conferir(strings.Replace(p, "/pdf", "/xml", 1), e, s, agora); !errors.Is(err, linkassinado.ErrLinkInvalido) {
+			t.Errorf("outro documento: esperava ErrLinkInvalido, obteve %v", err)
+		}
+		estendido := e[:len(e)-1] + "9"
+		if err := a.Conferir(p, estendido, s, agora); !errors.Is(err, linkassinado.ErrLinkInvalido) {
+			t.Errorf("prazo estendido: esperava ErrLinkInvalido, obteve %v", err)
+		}
+		outro, _ := linkassinado.NovoAssinador([]byte(strings.ToUpper(segredo)), time.Hour, "")
+		if err := outro.Conferir(p, e, s, agora); !errors.Is(err, linkassinado.ErrLinkInvalido) {
+			t.Errorf("outro segredo: esperava ErrLinkInvalido, obteve %v", err)
+		}
+		if err := a.Conferir(p, "", "", agora); !errors.Is(err, linkassinado.ErrLinkInvalido) {
+			t.Errorf("sem parametros: esperava ErrLinkInvalido, obteve %v", err)
+		}
+	})
+
+	t.Run("deve exigir segredo longo", func(t *testing.T) {
+		if _, err := linkassinado.NovoAssinador([]byte("curto"), time.Minute, ""); err == nil {
+			t.Error("esperava erro para segredo curto")
+		}
+	})
+}
+
+func TestCarregarConfigurado(t *testing.T) {
+	t.Run("deve desligar os links sem LINKS_SEGREDO", func(t *testing.T) {
+		t.Setenv("LINKS_SEGREDO", "")
+		a, err := linkassinado.CarregarConfigurado()
+		if err != nil || a != nil {
+			t.Errorf("esperava nil, nil; obteve %v, %v", a, err)
+		}
+	})
+
+	t.Run("deve ler validade e URL base", func(t *testing.T) {
+		t.Setenv("LINKS_SEGREDO", segredo)
+		t.Setenv("LINKS_VALIDADE_MINUTOS", "5")
+		t.Setenv("LINKS_URL_BASE", "http://localhost:8080")
+		a, err := linkassinado.CarregarConfigurado()
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if a.Validade != 5*time.Minute || a.URLBase != "http://localhost:8080" {
+			t.Errorf("configuracao inesperada: %+v", a)
+		}
+	})
+}
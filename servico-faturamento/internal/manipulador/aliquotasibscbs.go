@@ -0,0 +1,136 @@
+package manipulador
+
+import (
+	"net/http"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CriarAliquotaIBSCBSDB valida e grava um novo período de alíquotas
+func (h *Handlers) CriarAliquotaIBSCBSDB(a *dominio.AliquotaIBSCBS) error {
+	a.ID = uuid.Nil
+	if err := a.Validar(); err != nil {
+		return err
+	}
+	return h.DB.Create(a).Error
+}
+
+// AtualizarAliquotaIBSCBSDB substitui o período; notas já fechadas mantêm os valores calculados
+func (h *Handlers) AtualizarAliquotaIBSCBSDB(id uuid.UUID, dados dominio.AliquotaIBSCBS) (dominio.AliquotaIBSCBS, error) {
+	var atual dominio.AliquotaIBSCBS
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&atual, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := dados.Validar(); err != nil {
+			return err
+		}
+		dados.DataAtualizacao = time.Now()
+		if err := tx.Model(&atual).Select("*").Omit(camposProtegidos...).Updates(&dados).Error; err != nil {
+			return err
+		}
+		return tx.First(&atual, "id = ?", id).Error
+	})
+	return atual, err
+}
+
+// ExcluirAliquotaIBSCBSDB remove o período
+func (h *Handlers) ExcluirAliquotaIBSCBSDB(id uuid.UUID) error {
+	resultado := h.DB.Delete(&dominio.AliquotaIBSCBS{}, "id = ?", id)
+	if resultado.Error != nil {
+		return resultado.Error
+	}
+	if resultado.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CriarAliquotaIBSCBS - POST /api/v1/aliquotas-ibs-cbs
+func (h *Handlers) CriarAliquotaIBSCBS(c *gin.Context) {
+	var aliquota dominio.AliquotaIBSCBS
+	if err := c.ShouldBindJSON(&aliquota); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	if err := h.CriarAliquotaIBSCBSDB(&aliquota); err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, aliquota)
+}
+
+// ListarAliquotasIBSCBS - GET /api/v1/aliquotas-ibs-cbs
+func (h *Handlers) ListarAliquotasIBSCBS(c *gin.Context) {
+	var aliquotas []dominio.AliquotaIBSCBS
+	if err := h.DB.Order("vigencia_inicio DESC").Find(&aliquotas).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Falha ao listar aliquotas de IBS/CBS"})
+		return
+	}
+
+	c.JSON(http.StatusOK, aliquotas)
+}
+
+// BuscarAliquotaIBSCBS - GET /api/v1/aliquotas-ibs-cbs/:id
+func (h *Handlers) BuscarAliquotaIBSCBS(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var aliquota dominio.AliquotaIBSCBS
+	if err := h.DB.First(&aliquota, "id = ?", id).Error; err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, aliquota)
+}
+
+// AtualizarAliquotaIBSCBS - PUT /api/v1/aliquotas-ibs-cbs/:id
+func (h *Handlers) AtualizarAliquotaIBSCBS(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var dados dominio.AliquotaIBSCBS
+	if err := c.ShouldBindJSON(&dados); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	aliquota, err := h.AtualizarAliquotaIBSCBSDB(id, dados)
+	if err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, aliquota)
+}
+
+// ExcluirAliquotaIBSCBS - DELETE /api/v1/aliquotas-ibs-cbs/:id
+func (h *Handlers) ExcluirAliquotaIBSCBS(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	if err := h.ExcluirAliquotaIBSCBSDB(id); err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,455 @@
+package manipulador
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+	"servico-faturamento/internal/publicador"
+	"servico-faturamento/internal/sefaz"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Eventos do ciclo de autorização na SEFAZ. AutorizacaoSolicitada é gravado no
+// fechamento e consumido pelo autorizador; os demais registram o retorno.
+const (
+	EventoAutorizacaoSolicitada = "Faturamento.AutorizacaoSolicitada"
+	EventoNotaAutorizada        = "Faturamento.NotaAutorizada"
+	EventoNotaRejeitada         = "Faturamento.NotaRejeitada"
+	EventoNotaDenegada          = "Faturamento.NotaDenegada"
+	// EventoAutorizacaoConflitante registra a autorização que a SEFAZ deu a uma nota
+	// já cancelada no banco: ela vale no autorizador até o evento 110111
+	EventoAutorizacaoConflitante = "Faturamento.AutorizacaoConflitante"
+)
+
+// EventoNotaFechada é gravado no fechamento e pede o DANFE da nota, que já tem a
+// chave de acesso (lambda-pdf ou worker de PDF da API)
+const EventoNotaFechada = "Faturamento.NotaFechada"
+
+var eventoDoRetorno = map[string]string{
+	dominio.StatusNotaAutorizada: EventoNotaAutorizada,
+	dominio.StatusNotaRejeitada:  EventoNotaRejeitada,
+	dominio.StatusNotaDenegada:   EventoNotaDenegada,
+}
+
+// ErrAutorizacaoInviavel indica nota cujo XML não pode ser montado; repetir a
+// transmissão não resolve e o evento deve ser descartado
+var ErrAutorizacaoInviavel = errors.New("nota nao pode ser transmitida")
+
+type payloadRetornoSefaz struct {
+	NotaID          string     `json:"notaId"`
+	ChaveAcesso     string     `json:"chaveAcesso,omitempty"`
+	Status          string     `json:"status"`
+	CStat           int        `json:"cStat"`
+	XMotivo         string     `json:"xMotivo"`
+	Protocolo       string     `json:"protocolo,omitempty"`
+	DataAutorizacao *time.Time `json:"dataAutorizacao,omitempty"`
+}
+
+// payloadAutorizacao identifica a nota nos eventos NotaFechada e AutorizacaoSolicitada
+func payloadAutorizacao(nota *dominio.NotaFiscal) map[string]string {
+	payload := map[string]string{"notaId": nota.ID.String()}
+	if nota.ChaveAcesso != nil {
+		payload["chaveAcesso"] = *nota.ChaveAcesso
+	}
+	return payload
+}
+
+// SolicitarAutorizacao grava no outbox, na transação do fechamento, o pedido de
+// transmissão da nota. A chamada à SEFAZ acontece depois, no consumidor do evento,
+// para que o fechamento não dependa do tempo de resposta do autorizador. Com a
+// SEFAZ configurada a nota fica marcada como pendente de autorização, o que
+// impede o cancelamento no banco até o retorno do autorizador.
+func (h *Handlers) SolicitarAutorizacao(tx *gorm.DB, nota *dominio.NotaFiscal) error {
+	if h.Sefaz != nil && !nota.AutorizacaoPendente {
+		nota.AutorizacaoPendente = true
+		if err := tx.Model(nota).Update("autorizacao_pendente", true).Error; err != nil {
+			return err
+		}
+	}
+	return gravarEventoNota(tx, EventoAutorizacaoSolicitada, nota)
+}
+
+// RegistrarNotaFechada grava no outbox, na transação do fechamento, o evento que
+// pede a geração do DANFE
+func RegistrarNotaFechada(tx *gorm.DB, nota *dominio.NotaFiscal) error {
+	return gravarEventoNota(tx, EventoNotaFechada, nota)
+}
+
+func gravarEventoNota(tx *gorm.DB, tipoEvento string, nota *dominio.NotaFiscal) error {
+	payloadJSON, err := json.Marshal(payloadAutorizacao(nota))
+	if err != nil {
+		return fmt.Errorf("falha ao serializar payload: %w", err)
+	}
+	if err := tx.Create(&dominio.EventoOutbox{
+		TipoEvento:     tipoEvento,
+		IdAgregado:     nota.ID,
+		Payload:        string(payloadJSON),
+		DataOcorrencia: time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("falha ao criar evento outbox: %w", err)
+	}
+	return nil
+}
+
+// ReenviarAutorizacaoDB grava um novo pedido de transmissão para a nota ainda
+// fechada, para quando o evento original se perdeu ou a SEFAZ estava desligada.
+// A nota rejeitada volta a FECHADA com a mesma chave e é transmitida de novo,
+// depois da correção do cadastro que motivou a rejeição.
+func (h *Handlers) ReenviarAutorizacaoDB(notaID uuid.UUID) error {
+	var nota dominio.NotaFiscal
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+		if nota.Status == dominio.StatusNotaRejeitada {
+			if err := nota.PrepararReenvio(); err != nil {
+				return err
+			}
+			if err := tx.Model(&nota).Updates(map[string]interface{}{
+				"status":         nota.Status,
+				"c_stat":         nota.CStat,
+				"x_motivo":       nota.XMotivo,
+				"xml_fechamento": nota.XMLFechamento,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		if nota.Status != dominio.StatusNotaFechada {
+			return fmt.Errorf("%w: status %s", dominio.ErrNotaNaoAutorizavel, nota.Status)
+		}
+		return h.SolicitarAutorizacao(tx, &nota)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := publicador.PublicarEvento(context.Background(), EventoAutorizacaoSolicitada, notaID.String(), payloadAutorizacao(&nota)); err != nil {
+		slog.Warn("Failed to publish AutorizacaoSolicitada event to EventBridge", "error", err, "notaId", notaID)
+	}
+	return nil
+}
+
+// ReabrirNotaDB devolve a nota rejeitada pela SEFAZ para ABERTA, mantendo o
+// número. Itens e pagamentos voltam a aceitar alterações, e o próximo fechamento
+// gera a nova chave e pede de novo a autorização.
+func (h *Handlers) ReabrirNotaDB(notaID uuid.UUID) (dominio.NotaFiscal, error) {
+	var nota dominio.NotaFiscal
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+		motivo := nota.XMotivo
+		if err := nota.Reabrir(); err != nil {
+			return err
+		}
+		if err := tx.Model(&nota).Updates(map[string]interface{}{
+			"status":                     nota.Status,
+			"data_fechada":               nota.DataFechada,
+			"chave_acesso":               nota.ChaveAcesso,
+			"xml_fechamento":             nota.XMLFechamento,
+			"c_stat":                     nota.CStat,
+			"x_motivo":                   nota.XMotivo,
+			"tipo_emissao":               nota.TipoEmissao,
+			"data_contingencia":          nota.DataContingencia,
+			"justificativa_contingencia": nota.JustificativaContingencia,
+		}).Error; err != nil {
+			return err
+		}
+		slog.Info("Nota rejeitada reaberta para correcao", "notaId", notaID, "numero", nota.Numero, "xMotivo", motivo)
+		return nil
+	})
+	return nota, err
+}
+
+// AutorizarNota gera o XML assinado da nota fechada, transmite à SEFAZ e grava o
+// retorno. Notas que já saíram de FECHADA são devolvidas sem nova transmissão, o
+// que torna seguro reprocessar o evento. O tpEmis escolhe o autorizador: a SVC
+// para 6 e 7; para 4 o EPEC é registrado antes. Notas normais e EPEC ficam em
+// FECHADA enquanto houver contingência e são reenviadas na reconciliação. A NFC-e
+// vai ao autorizador próprio do modelo 65; a emitida offline (tpEmis 9) aguarda
+// o fim da contingência como as normais.
+func (h *Handlers) AutorizarNota(ctx context.Context, notaID uuid.UUID) (dominio.NotaFiscal, error) {
+	if h.Sefaz == nil {
+		return dominio.NotaFiscal{}, sefaz.ErrSefazDesabilitada
+	}
+
+	nota, err := h.reservarTransmissao(notaID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nota, fmt.Errorf("%w: %w", ErrAutorizacaoInviavel, err)
+		}
+		return nota, err
+	}
+	if nota.Status != dominio.StatusNotaFechada {
+		slog.Info("Nota fora de FECHADA; autorizacao ignorada", "notaId", notaID, "status", nota.Status)
+		return nota, nil
+	}
+
+	// GerarXML atribui a chave de notas fechadas antes da configuração do emitente
+	xmlNFe, err := h.GerarXML(notaID)
+	if err != nil {
+		if status, _ := RespostaErroXML(err); status != http.StatusInternalServerError {
+			h.liberarAutorizacao(notaID)
+			return nota, fmt.Errorf("%w: %w", ErrAutorizacaoInviavel, err)
+		}
+		return nota, err
+	}
+	if err := h.DB.First(&nota, "id = ?", notaID).Error; err != nil {
+		return nota, err
+	}
+	if nota.ChaveAcesso == nil {
+		h.liberarAutorizacao(notaID)
+		return nota, fmt.Errorf("%w: %w", ErrAutorizacaoInviavel, nfe.ErrNotaSemChave)
+	}
+
+	autorizador := h.Sefaz
+	if nota.NFCe() {
+		if autorizador, err = h.Sefaz.NFCe(); err != nil {
+			return nota, err
+		}
+	}
+	switch nota.TipoEmissaoEfetivo() {
+	case dominio.TipoEmissaoSVCAN, dominio.TipoEmissaoSVCRS:
+		if autorizador, err = h.Sefaz.SVC(); err != nil {
+			return nota, err
+		}
+	case dominio.TipoEmissaoEPEC:
+		if nota.ProtocoloEPEC == nil {
+			if nota, err = h.registrarEPEC(ctx, notaID); err != nil {
+				return nota, err
+			}
+		}
+		fallthrough
+	default:
+		aguarda, err := h.aguardaAutorizador()
+		if err != nil {
+			return nota, err
+		}
+		if aguarda {
+			slog.Info("Contingencia ativa; nota aguarda o autorizador normal", "notaId", notaID, "tpEmis", nota.TipoEmissaoEfetivo())
+			return nota, nil
+		}
+	}
+
+	inicio := time.Now()
+	prot, err := autorizador.Transmitir(ctx, xmlNFe, *nota.ChaveAcesso)
+	if h.Contingencia != nil && autorizador == h.Sefaz {
+		h.Contingencia.RegistrarResultado(err)
+	}
+	if err != nil {
+		return nota, err
+	}
+	slog.Info("Retorno da SEFAZ", "notaId", notaID, "cStat", prot.InfProt.CStat, "xMotivo", prot.InfProt.XMotivo,
+		"duracaoMs", time.Since(inicio).Milliseconds())
+
+	resultado, err := prot.Resultado(xmlNFe)
+	if err != nil {
+		return nota, err
+	}
+	return h.registrarRetornoSefaz(notaID, resultado)
+}
+
+// reservarTransmissao lê a nota com a linha travada e, se ela segue FECHADA, a
+// marca como pendente de autorização antes de qualquer envio à SEFAZ. O
+// cancelamento trava a mesma linha e recusa a nota marcada, então a nota não é
+// cancelada no banco com a transmissão em curso.
+func (h *Handlers) reservarTransmissao(notaID uuid.UUID) (dominio.NotaFiscal, error) {
+	var nota dominio.NotaFiscal
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+		if nota.Status != dominio.StatusNotaFechada || nota.AutorizacaoPendente {
+			return nil
+		}
+		nota.AutorizacaoPendente = true
+		return tx.Model(&nota).Update("autorizacao_pendente", true).Error
+	})
+	return nota, err
+}
+
+// liberarAutorizacao desfaz a marca da nota cujo XML não pode ser montado: ela
+// não chegou à SEFAZ, o pedido é descartado e a nota volta a poder ser cancelada
+func (h *Handlers) liberarAutorizacao(notaID uuid.UUID) {
+	if err := h.DB.Model(&dominio.NotaFiscal{}).
+		Where("id = ? AND status = ?", notaID, dominio.StatusNotaFechada).
+		Update("autorizacao_pendente", false).Error; err != nil {
+		slog.Error("Falha ao liberar nota pendente de autorizacao", "notaId", notaID, "erro", err)
+	}
+}
+
+// registrarRetornoSefaz grava o protocolo e o evento do retorno na mesma transação.
+// Autorização que chega para nota já cancelada no banco não é descartada: o
+// protocolo é gravado e o conflito vira o evento AutorizacaoConflitante.
+func (h *Handlers) registrarRetornoSefaz(notaID uuid.UUID, resultado dominio.ResultadoAutorizacao) (dominio.NotaFiscal, error) {
+	var nota dominio.NotaFiscal
+	var payload payloadRetornoSefaz
+	var tipoEvento string
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+
+		switch {
+		case nota.Status == dominio.StatusNotaFechada:
+			status, err := nota.RegistrarAutorizacao(resultado)
+			if err != nil {
+				return err
+			}
+			tipoEvento = eventoDoRetorno[status]
+		case nota.RegistrarAutorizacaoConflitante(resultado):
+			tipoEvento = EventoAutorizacaoConflitante
+		default:
+			// Outra entrega do mesmo evento já registrou o retorno
+			return nil
+		}
+
+		if err := tx.Model(&nota).Updates(map[string]interface{}{
+			"status":                nota.Status,
+			"autorizacao_pendente":  nota.AutorizacaoPendente,
+			"protocolo_autorizacao": nota.ProtocoloAutorizacao,
+			"c_stat":                nota.CStat,
+			"x_motivo":              nota.XMotivo,
+			"data_autorizacao":      nota.DataAutorizacao,
+			"xml_autorizado":        nota.XMLAutorizado,
+		}).Error; err != nil {
+			return err
+		}
+
+		payload = payloadRetornoSefaz{
+			NotaID:          notaID.String(),
+			Status:          nota.Status,
+			CStat:           resultado.CStat,
+			XMotivo:         *nota.XMotivo,
+			Protocolo:       resultado.Protocolo,
+			DataAutorizacao: nota.DataAutorizacao,
+		}
+		if nota.ChaveAcesso != nil {
+			payload.ChaveAcesso = *nota.ChaveAcesso
+		}
+		payloadJSON, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("falha ao serializar payload: %w", err)
+		}
+		if err := tx.Create(&dominio.EventoOutbox{
+			TipoEvento:     tipoEvento,
+			IdAgregado:     notaID,
+			Payload:        string(payloadJSON),
+			DataOcorrencia: time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("falha ao criar evento outbox: %w", err)
+		}
+		return nil
+	})
+	if err != nil || tipoEvento == "" {
+		return nota, err
+	}
+
+	if tipoEvento == EventoAutorizacaoConflitante {
+		slog.Error("SEFAZ autorizou nota cancelada no banco; protocolo gravado, a nota vale ate o evento 110111",
+			"notaId", notaID, "cStat", payload.CStat, "protocolo", payload.Protocolo)
+	} else {
+		slog.Info("Retorno da SEFAZ registrado", "notaId", notaID, "status", payload.Status, "cStat", payload.CStat, "protocolo", payload.Protocolo)
+	}
+	if nota.XMLAutorizado != nil {
+		h.arquivarXML(armazenamento.ChaveXMLAutorizado(nota), *nota.XMLAutorizado)
+	}
+	if err := publicador.PublicarEvento(context.Background(), tipoEvento, notaID.String(), payload); err != nil {
+		slog.Warn("Failed to publish SEFAZ result event to EventBridge", "error", err, "notaId", notaID, "tipoEvento", tipoEvento)
+	}
+	return nota, nil
+}
+
+// StatusSefazDB consulta o status do serviço de autorização da UF do emitente
+func (h *Handlers) StatusSefazDB(ctx context.Context) (*sefaz.RetConsStatServ, error) {
+	if h.Sefaz == nil {
+		return nil, sefaz.ErrSefazDesabilitada
+	}
+	return h.Sefaz.StatusServico(ctx)
+}
+
+// RespostaErroAutorizacao traduz erros da autorização e da consulta à SEFAZ para status HTTP e corpo de resposta
+func RespostaErroAutorizacao(err error) (int, map[string]interface{}) {
+	var fault *sefaz.FaultSOAP
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"}
+	case errors.Is(err, dominio.ErrNotaNaoAutorizavel), errors.Is(err, dominio.ErrNotaNaoReabrivel):
+		return http.StatusConflict, gin.H{"erro": err.Error()}
+	case errors.Is(err, sefaz.ErrSefazDesabilitada), errors.Is(err, sefaz.ErrSefazIndisponivel),
+		errors.Is(err, sefaz.ErrNFCeDesabilitada):
+		return http.StatusServiceUnavailable, gin.H{"erro": err.Error()}
+	case errors.As(err, &fault), errors.Is(err, sefaz.ErrRespostaInvalida):
+		return http.StatusBadGateway, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha na comunicacao com a SEFAZ"}
+	}
+}
+
+func responderErroAutorizacao(c *gin.Context, err error) {
+	status, corpo := RespostaErroAutorizacao(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Falha na autorizacao", "erro", err)
+	}
+	c.JSON(status, corpo)
+}
+
+// ReenviarAutorizacao - POST /api/v1/notas/:id/autorizar
+func (h *Handlers) ReenviarAutorizacao(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	if err := h.ReenviarAutorizacaoDB(id); err != nil {
+		responderErroAutorizacao(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"mensagem": "Autorizacao solicitada", "notaId": id})
+}
+
+// ReabrirNota - POST /api/v1/notas/:id/reabrir
+func (h *Handlers) ReabrirNota(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	nota, err := h.ReabrirNotaDB(id)
+	if err != nil {
+		responderErroAutorizacao(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, nota)
+}
+
+// StatusSefaz - GET /api/v1/sefaz/status
+func (h *Handlers) StatusSefaz(c *gin.Context) {
+	ret, err := h.StatusSefazDB(c.Request.Context())
+	if err != nil {
+		responderErroAutorizacao(c, err)
+		return
+	}
+
+	status := http.StatusOK
+	if ret.CStat != sefaz.CStatServicoEmOperacao {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, ret)
+}
@@ -0,0 +1,339 @@
+package manipulador
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCadastroEmUso impede excluir emitente ou cliente referenciado por notas
+	ErrCadastroEmUso = errors.New("cadastro vinculado a notas fiscais")
+	// ErrCNPJEmitenteImutavel protege as séries de numeração, que são chaveadas pelo CNPJ
+	ErrCNPJEmitenteImutavel = errors.New("cnpj do emitente nao pode ser alterado")
+	// ErrEmitenteNaoEncontrado indica emitenteId inexistente na criação da nota
+	ErrEmitenteNaoEncontrado = errors.New("emitente nao encontrado")
+	// ErrClienteNaoEncontrado indica clienteId ou documento inexistente na criação da nota
+	ErrClienteNaoEncontrado = errors.New("cliente nao encontrado")
+)
+
+// camposProtegidos não são sobrescritos na atualização completa (PUT); com
+// Select("*") os campos vazios do corpo limpam os valores anteriores
+var camposProtegidos = []string{"id", "data_criacao"}
+
+// CriarEmitenteDB valida e grava um novo emitente
+func (h *Handlers) CriarEmitenteDB(e *dominio.Emitente) error {
+	e.ID = uuid.Nil
+	if err := e.Validar(); err != nil {
+		return err
+	}
+	return h.DB.Create(e).Error
+}
+
+// AtualizarEmitenteDB substitui os dados do emitente, exceto o CNPJ
+func (h *Handlers) AtualizarEmitenteDB(id uuid.UUID, dados dominio.Emitente) (dominio.Emitente, error) {
+	var atual dominio.Emitente
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&atual, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := dados.Validar(); err != nil {
+			return err
+		}
+		if dados.CNPJ != atual.CNPJ {
+			return ErrCNPJEmitenteImutavel
+		}
+		dados.DataAtualizacao = time.Now()
+		if err := tx.Model(&atual).Select("*").Omit(camposProtegidos...).Updates(&dados).Error; err != nil {
+			return err
+		}
+		return tx.First(&atual, "id = ?", id).Error
+	})
+	return atual, err
+}
+
+// ExcluirEmitenteDB remove o emitente que ainda não emitiu notas
+func (h *Handlers) ExcluirEmitenteDB(id uuid.UUID) error {
+	return h.excluirCadastro(&dominio.Emitente{}, id, "emitente_id")
+}
+
+// CriarClienteDB valida e grava um novo cliente
+func (h *Handlers) CriarClienteDB(c *dominio.Cliente) error {
+	c.ID = uuid.Nil
+	if err := c.Validar(); err != nil {
+		return err
+	}
+	return h.DB.Create(c).Error
+}
+
+// AtualizarClienteDB substitui os dados do cliente
+func (h *Handlers) AtualizarClienteDB(id uuid.UUID, dados dominio.Cliente) (dominio.Cliente, error) {
+	var atual dominio.Cliente
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&atual, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := dados.Validar(); err != nil {
+			return err
+		}
+		dados.DataAtualizacao = time.Now()
+		if err := tx.Model(&atual).Select("*").Omit(camposProtegidos...).Updates(&dados).Error; err != nil {
+			return err
+		}
+		return tx.First(&atual, "id = ?", id).Error
+	})
+	return atual, err
+}
+
+// ExcluirClienteDB remove o cliente que não é destinatário de nenhuma nota
+func (h *Handlers) ExcluirClienteDB(id uuid.UUID) error {
+	return h.excluirCadastro(&dominio.Cliente{}, id, "cliente_id")
+}
+
+func (h *Handlers) excluirCadastro(modelo interface{}, id uuid.UUID, colunaNota string) error {
+	return h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(modelo, "id = ?", id).Error; err != nil {
+			return err
+		}
+		var notas int64
+		if err := tx.Model(&dominio.NotaFiscal{}).Where(colunaNota+" = ?", id).Count(&notas).Error; err != nil {
+			return err
+		}
+		if notas > 0 {
+			return ErrCadastroEmUso
+		}
+		return tx.Delete(modelo).Error
+	})
+}
+
+// BuscarClientePorReferencia aceita o ID ou o CPF/CNPJ (com ou sem pontuação) do cliente
+func (h *Handlers) BuscarClientePorReferencia(referencia string) (dominio.Cliente, error) {
+	var cliente dominio.Cliente
+	query := h.DB
+	if id, err := uuid.Parse(referencia); err == nil {
+		query = query.Where("id = ?", id)
+	} else {
+		query = query.Where("documento = ?", dominio.SomenteDigitos(referencia))
+	}
+	if err := query.First(&cliente).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cliente, ErrClienteNaoEncontrado
+		}
+		return cliente, err
+	}
+	return cliente, nil
+}
+
+// RespostaErroCadastro traduz erros de emitentes e clientes para status HTTP e corpo de resposta
+func RespostaErroCadastro(err error) (int, map[string]interface{}) {
+	var errosCadastro dominio.ErrosCadastro
+	switch {
+	case errors.As(err, &errosCadastro):
+		return http.StatusUnprocessableEntity, gin.H{"erro": "Cadastro invalido", "detalhes": []string(errosCadastro)}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Cadastro nao encontrado"}
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return http.StatusConflict, gin.H{"erro": "Documento ja cadastrado"}
+	case errors.Is(err, ErrCadastroEmUso), errors.Is(err, gorm.ErrForeignKeyViolated):
+		return http.StatusConflict, gin.H{"erro": ErrCadastroEmUso.Error()}
+	case errors.Is(err, ErrCNPJEmitenteImutavel):
+		return http.StatusConflict, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao processar cadastro"}
+	}
+}
+
+func responderErroCadastro(c *gin.Context, err error) {
+	status, corpo := RespostaErroCadastro(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Falha no cadastro", "path", c.FullPath(), "erro", err)
+	}
+	c.JSON(status, corpo)
+}
+
+// CriarEmitente - POST /api/v1/emitentes
+func (h *Handlers) CriarEmitente(c *gin.Context) {
+	var emitente dominio.Emitente
+	if err := c.ShouldBindJSON(&emitente); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	if err := h.CriarEmitenteDB(&emitente); err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, emitente)
+}
+
+// ListarEmitentes - GET /api/v1/emitentes
+func (h *Handlers) ListarEmitentes(c *gin.Context) {
+	var emitentes []dominio.Emitente
+	query := h.DB.Order("razao_social")
+	if cnpj := c.Query("cnpj"); cnpj != "" {
+		query = query.Where("cnpj = ?", dominio.SomenteDigitos(cnpj))
+	}
+
+	if err := query.Find(&emitentes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Falha ao listar emitentes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, emitentes)
+}
+
+// BuscarEmitente - GET /api/v1/emitentes/:id
+func (h *Handlers) BuscarEmitente(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var emitente dominio.Emitente
+	if err := h.DB.First(&emitente, "id = ?", id).Error; err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, emitente)
+}
+
+// AtualizarEmitente - PUT /api/v1/emitentes/:id
+func (h *Handlers) AtualizarEmitente(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var dados dominio.Emitente
+	if err := c.ShouldBindJSON(&dados); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	emitente, err := h.AtualizarEmitenteDB(id, dados)
+	if err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, emitente)
+}
+
+// ExcluirEmitente - DELETE /api/v1/emitentes/:id
+func (h *Handlers) ExcluirEmitente(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	if err := h.ExcluirEmitenteDB(id); err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// CriarCliente - POST /api/v1/clientes
+func (h *Handlers) CriarCliente(c *gin.Context) {
+	var cliente dominio.Cliente
+	if err := c.ShouldBindJSON(&cliente); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	if err := h.CriarClienteDB(&cliente); err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, cliente)
+}
+
+// ListarClientes - GET /api/v1/clientes (query params: ?documento= e ?nome=)
+func (h *Handlers) ListarClientes(c *gin.Context) {
+	var clientes []dominio.Cliente
+	query := h.DB.Order("nome")
+	if documento := c.Query("documento"); documento != "" {
+		query = query.Where("documento = ?", dominio.SomenteDigitos(documento))
+	}
+	if nome := c.Query("nome"); nome != "" {
+		query = query.Where("nome ILIKE ?", "%"+nome+"%")
+	}
+
+	if err := query.Find(&clientes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Falha ao listar clientes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, clientes)
+}
+
+// BuscarCliente - GET /api/v1/clientes/:id
+func (h *Handlers) BuscarCliente(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var cliente dominio.Cliente
+	if err := h.DB.First(&cliente, "id = ?", id).Error; err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cliente)
+}
+
+// AtualizarCliente - PUT /api/v1/clientes/:id
+func (h *Handlers) AtualizarCliente(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var dados dominio.Cliente
+	if err := c.ShouldBindJSON(&dados); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	cliente, err := h.AtualizarClienteDB(id, dados)
+	if err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cliente)
+}
+
+// ExcluirCliente - DELETE /api/v1/clientes/:id
+func (h *Handlers) ExcluirCliente(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	if err := h.ExcluirClienteDB(id); err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
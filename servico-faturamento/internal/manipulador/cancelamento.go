@@ -0,0 +1,295 @@
+package manipulador
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+	"servico-faturamento/internal/publicador"
+	"servico-faturamento/internal/sefaz"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventoNotaCancelada é publicado para o estoque devolver o que reservou para a nota
+const EventoNotaCancelada = "Faturamento.NotaCancelada"
+
+// DadosCancelamento é o corpo de POST /api/v1/notas/:id/cancelar
+type DadosCancelamento struct {
+	Justificativa string `json:"justificativa"`
+}
+
+type itemNotaCancelada struct {
+	ProdutoID  string          `json:"produtoId"`
+	Quantidade dominio.Decimal `json:"quantidade"`
+}
+
+type payloadNotaCancelada struct {
+	NotaID           string              `json:"notaId"`
+	ChaveAcesso      string              `json:"chaveAcesso,omitempty"`
+	Protocolo        string              `json:"protocolo,omitempty"`
+	Justificativa    string              `json:"justificativa"`
+	DataCancelamento time.Time           `json:"dataCancelamento"`
+	Itens            []itemNotaCancelada `json:"itens"`
+}
+
+// CancelarNotaDB cancela a nota dentro do prazo configurado. A nota fechada, ainda
+// fora do autorizador, é cancelada no banco; a autorizada só depois do protocolo do
+// evento 110111 (cancelarNaSefaz). Na mesma transação grava o evento
+// Faturamento.NotaCancelada no outbox e encerra as solicitações de impressão que
+// ainda estiverem pendentes.
+func (h *Handlers) CancelarNotaDB(notaID uuid.UUID, justificativa string) (dominio.NotaFiscal, error) {
+	cfg := nfe.CarregarConfiguracao()
+
+	var nota dominio.NotaFiscal
+	if err := h.DB.First(&nota, "id = ?", notaID).Error; err != nil {
+		return dominio.NotaFiscal{}, err
+	}
+	if nota.ExigeEventoCancelamento() {
+		return h.cancelarNaSefaz(context.Background(), notaID, justificativa, cfg)
+	}
+
+	var payload payloadNotaCancelada
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Itens").
+			First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+
+		if err := nota.Cancelar(justificativa, time.Now(), cfg.PrazoCancelamento); err != nil {
+			return err
+		}
+
+		var err error
+		payload, err = gravarCancelamento(tx, &nota)
+		return err
+	})
+	if err != nil {
+		return dominio.NotaFiscal{}, err
+	}
+
+	h.publicarCancelamento(&nota, payload)
+	return nota, nil
+}
+
+// cancelarNaSefaz gera, assina e registra no autorizador o evento 110111 da nota
+// autorizada e só então a passa para CANCELADA, com o protocolo do evento. A nota
+// que a SEFAZ autorizou depois de cancelada no banco recebe apenas o protocolo: o
+// estoque já foi avisado no primeiro cancelamento.
+func (h *Handlers) cancelarNaSefaz(ctx context.Context, notaID uuid.UUID, justificativa string, cfg nfe.Configuracao) (dominio.NotaFiscal, error) {
+	var nota dominio.NotaFiscal
+	if err := h.DB.First(&nota, "id = ?", notaID).Error; err != nil {
+		return nota, err
+	}
+	justificativa, err := nota.PrepararCancelamento(justificativa, time.Now(), cfg.PrazoCancelamento)
+	if err != nil {
+		return nota, err
+	}
+	if h.Sefaz == nil {
+		return nota, fmt.Errorf("%w: %w", dominio.ErrCancelamentoExigeEvento, sefaz.ErrSefazDesabilitada)
+	}
+	autorizador := h.Sefaz
+	if nota.NFCe() {
+		if autorizador, err = h.Sefaz.NFCe(); err != nil {
+			return nota, err
+		}
+	}
+
+	ev, err := nfe.GerarEventoCancelamento(nota, justificativa, time.Now(), cfg)
+	if err != nil {
+		return nota, err
+	}
+	xmlEvento, err := nfe.SerializarEvento(ev)
+	if err != nil {
+		return nota, err
+	}
+	if xmlEvento, err = h.assinarXML(xmlEvento, "infEvento", ev.InfEvento.CNPJ); err != nil {
+		return nota, err
+	}
+
+	reg, err := autorizador.RegistrarEvento(ctx, xmlEvento)
+	if err != nil {
+		return nota, err
+	}
+	registro := time.Now()
+	if data, err := time.Parse(time.RFC3339, reg.DhRegEvento); err == nil {
+		registro = data
+	}
+
+	var payload payloadNotaCancelada
+	conflitante := false
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Itens").
+			First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+		if nota.ProtocoloCancelamento != nil {
+			// Outro pedido já gravou o mesmo registro
+			return nil
+		}
+
+		conflitante = nota.Status == dominio.StatusNotaCancelada
+		if err := nota.RegistrarCancelamento(justificativa, reg.NProt, registro); err != nil {
+			return err
+		}
+		documento := string(xmlEvento)
+		nota.XMLCancelamento = &documento
+
+		if conflitante {
+			return tx.Model(&nota).Updates(map[string]interface{}{
+				"protocolo_cancelamento": nota.ProtocoloCancelamento,
+				"xml_cancelamento":       nota.XMLCancelamento,
+			}).Error
+		}
+		payload, err = gravarCancelamento(tx, &nota)
+		return err
+	})
+	if err != nil {
+		return nota, err
+	}
+
+	slog.Info("Cancelamento registrado na SEFAZ", "notaId", notaID, "protocolo", reg.NProt, "cStat", reg.CStat)
+	h.arquivarXML(armazenamento.ChaveXMLCancelamento(nota), string(xmlEvento))
+	if !conflitante && payload.NotaID != "" {
+		h.publicarCancelamento(&nota, payload)
+	} else {
+		totais := nota.CalcularTotais()
+		nota.Totais = &totais
+	}
+	return nota, nil
+}
+
+// gravarCancelamento persiste a nota cancelada, encerra as impressões pendentes e
+// grava o evento NotaCancelada no outbox, na transação do cancelamento
+func gravarCancelamento(tx *gorm.DB, nota *dominio.NotaFiscal) (payloadNotaCancelada, error) {
+	if err := tx.Model(nota).Updates(map[string]interface{}{
+		"status":                     nota.Status,
+		"data_cancelamento":          nota.DataCancelamento,
+		"justificativa_cancelamento": nota.JustificativaCancelamento,
+		"protocolo_cancelamento":     nota.ProtocoloCancelamento,
+		"xml_cancelamento":           nota.XMLCancelamento,
+	}).Error; err != nil {
+		return payloadNotaCancelada{}, err
+	}
+
+	if err := tx.Model(&dominio.SolicitacaoImpressao{}).
+		Where("nota_id = ? AND status = ?", nota.ID, "PENDENTE").
+		Updates(map[string]interface{}{
+			"status":         "FALHOU",
+			"mensagem_erro":  "Nota cancelada",
+			"data_conclusao": nota.DataCancelamento,
+		}).Error; err != nil {
+		return payloadNotaCancelada{}, err
+	}
+
+	payload := payloadNotaCancelada{
+		NotaID:           nota.ID.String(),
+		Justificativa:    *nota.JustificativaCancelamento,
+		DataCancelamento: *nota.DataCancelamento,
+		Itens:            make([]itemNotaCancelada, 0, len(nota.Itens)),
+	}
+	if nota.ChaveAcesso != nil {
+		payload.ChaveAcesso = *nota.ChaveAcesso
+	}
+	if nota.ProtocoloCancelamento != nil {
+		payload.Protocolo = *nota.ProtocoloCancelamento
+	}
+	for _, item := range nota.Itens {
+		payload.Itens = append(payload.Itens, itemNotaCancelada{
+			ProdutoID:  item.ProdutoID.String(),
+			Quantidade: item.Quantidade,
+		})
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return payloadNotaCancelada{}, fmt.Errorf("falha ao serializar payload: %w", err)
+	}
+
+	if err := tx.Create(&dominio.EventoOutbox{
+		TipoEvento:     EventoNotaCancelada,
+		IdAgregado:     nota.ID,
+		Payload:        string(payloadJSON),
+		DataOcorrencia: *nota.DataCancelamento,
+	}).Error; err != nil {
+		return payloadNotaCancelada{}, fmt.Errorf("falha ao criar evento outbox: %w", err)
+	}
+	return payload, nil
+}
+
+// publicarCancelamento avisa o EventBridge depois do commit e completa os totais da resposta
+func (h *Handlers) publicarCancelamento(nota *dominio.NotaFiscal, payload payloadNotaCancelada) {
+	slog.Info("Nota cancelada", "notaId", nota.ID, "tipoEvento", EventoNotaCancelada)
+
+	// Publicar diretamente no EventBridge (serverless mode); o outbox garante a entrega
+	if err := publicador.PublicarEvento(context.Background(), EventoNotaCancelada, nota.ID.String(), payload); err != nil {
+		slog.Warn("Failed to publish NotaCancelada event to EventBridge", "error", err, "notaId", nota.ID)
+	}
+
+	totais := nota.CalcularTotais()
+	nota.Totais = &totais
+}
+
+// RespostaErroCancelamento traduz os erros do cancelamento e do registro do evento
+// 110111 na SEFAZ para status HTTP e corpo JSON
+func RespostaErroCancelamento(err error) (int, map[string]interface{}) {
+	var errosValidacao nfe.ErrosValidacao
+	var fault *sefaz.FaultSOAP
+	switch {
+	case errors.Is(err, dominio.ErrJustificativaInvalida), errors.Is(err, assinatura.ErrCertificadoOutroEmitente):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	case errors.As(err, &errosValidacao):
+		return http.StatusUnprocessableEntity, gin.H{"erro": "Evento nao atende ao leiaute do cancelamento", "detalhes": []string(errosValidacao)}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"}
+	case errors.Is(err, dominio.ErrNotaNaoCancelavel), errors.Is(err, dominio.ErrPrazoCancelamentoExpirado),
+		errors.Is(err, sefaz.ErrEventoRejeitado):
+		return http.StatusConflict, gin.H{"erro": err.Error()}
+	case errors.Is(err, sefaz.ErrSefazIndisponivel), errors.Is(err, sefaz.ErrNFCeDesabilitada):
+		return http.StatusServiceUnavailable, gin.H{"erro": err.Error()}
+	case errors.As(err, &fault), errors.Is(err, sefaz.ErrRespostaInvalida):
+		return http.StatusBadGateway, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao cancelar nota"}
+	}
+}
+
+// CancelarNota - POST /api/v1/notas/:id/cancelar
+func (h *Handlers) CancelarNota(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var req DadosCancelamento
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	nota, err := h.CancelarNotaDB(notaID, req.Justificativa)
+	if err != nil {
+		status, corpo := RespostaErroCancelamento(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Falha ao cancelar nota", "notaId", notaID, "erro", err)
+		}
+		c.JSON(status, corpo)
+		return
+	}
+
+	c.JSON(http.StatusOK, nota)
+}
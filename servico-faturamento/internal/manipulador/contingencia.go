@@ -0,0 +1,292 @@
+package manipulador
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"servico-faturamento/internal/contingencia"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+	"servico-faturamento/internal/publicador"
+	"servico-faturamento/internal/sefaz"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventoEPECRegistrado informa o registro do evento prévio da nota emitida em EPEC,
+// a partir do qual o DANFE pode acompanhar a mercadoria
+const EventoEPECRegistrado = "Faturamento.EPECRegistrado"
+
+// limiteReconciliacao limita as notas reenviadas por chamada; o restante segue na próxima
+const limiteReconciliacao = 1000
+
+// DadosContingencia é o corpo de POST /api/v1/admin/contingencia
+type DadosContingencia struct {
+	Modo          string `json:"modo"`
+	Justificativa string `json:"justificativa"`
+}
+
+// StatusContingencia é a resposta de GET /api/v1/admin/contingencia
+type StatusContingencia struct {
+	Modo      string                 `json:"modo"`
+	Vigente   *dominio.Contingencia  `json:"vigente,omitempty"`
+	Pendentes int64                  `json:"pendentes"`
+	Historico []dominio.Contingencia `json:"historico"`
+}
+
+type payloadEPEC struct {
+	NotaID      string    `json:"notaId"`
+	ChaveAcesso string    `json:"chaveAcesso"`
+	Protocolo   string    `json:"protocolo,omitempty"`
+	DataEvento  time.Time `json:"dataEvento"`
+}
+
+// aguardaAutorizador informa se a nota deve esperar a volta do autorizador normal:
+// notas normais e EPEC só são transmitidas a ele fora da contingência
+func (h *Handlers) aguardaAutorizador() (bool, error) {
+	vigente, err := contingencia.Atual(h.DB)
+	return vigente != nil, err
+}
+
+// registrarEPEC gera, assina e envia ao Ambiente Nacional o evento prévio da nota
+// fechada em EPEC, e grava o protocolo na nota com o evento EPECRegistrado
+func (h *Handlers) registrarEPEC(ctx context.Context, notaID uuid.UUID) (dominio.NotaFiscal, error) {
+	var nota dominio.NotaFiscal
+	if err := h.DB.Preload("Itens").Preload("Emitente").Preload("Cliente").Preload("Pagamentos").First(&nota, "id = ?", notaID).Error; err != nil {
+		return nota, err
+	}
+
+	// O EPEC declara os valores do documento do fechamento, o mesmo transmitido depois
+	var doc *nfe.NFe
+	var err error
+	if nota.XMLFechamento != nil {
+		doc, err = nfe.LerNFe([]byte(*nota.XMLFechamento))
+	} else {
+		doc, err = nfe.Gerar(nota, nfe.CarregarConfiguracao())
+	}
+	if err != nil {
+		return nota, fmt.Errorf("%w: %w", ErrAutorizacaoInviavel, err)
+	}
+	ev, err := nfe.GerarEventoEPEC(doc, time.Now())
+	if err != nil {
+		return nota, fmt.Errorf("%w: %w", ErrAutorizacaoInviavel, err)
+	}
+	xmlEvento, err := nfe.SerializarEvento(ev)
+	if err != nil {
+		return nota, err
+	}
+	if xmlEvento, err = h.assinarXML(xmlEvento, "infEvento", doc.InfNFe.Emit.CNPJ); err != nil {
+		return nota, fmt.Errorf("%w: %w", ErrAutorizacaoInviavel, err)
+	}
+
+	reg, err := h.Sefaz.RegistrarEPEC(ctx, xmlEvento)
+	if errors.Is(err, sefaz.ErrEventoRejeitado) {
+		return nota, fmt.Errorf("%w: %w", ErrAutorizacaoInviavel, err)
+	}
+	if err != nil {
+		return nota, err
+	}
+	registro := time.Now()
+	if data, err := time.Parse(time.RFC3339, reg.DhRegEvento); err == nil {
+		registro = data
+	}
+
+	var payload payloadEPEC
+	registrado := false
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+		if nota.ProtocoloEPEC != nil {
+			// Outra entrega do mesmo evento já registrou o EPEC
+			return nil
+		}
+		if err := nota.RegistrarEPEC(reg.NProt, registro); err != nil {
+			return err
+		}
+		if err := tx.Model(&nota).Updates(map[string]interface{}{
+			"protocolo_epec": nota.ProtocoloEPEC,
+			"data_epec":      nota.DataEPEC,
+		}).Error; err != nil {
+			return err
+		}
+
+		payload = payloadEPEC{NotaID: notaID.String(), ChaveAcesso: *nota.ChaveAcesso, Protocolo: reg.NProt, DataEvento: registro}
+		payloadJSON, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("falha ao serializar payload: %w", err)
+		}
+		if err := tx.Create(&dominio.EventoOutbox{
+			TipoEvento:     EventoEPECRegistrado,
+			IdAgregado:     notaID,
+			Payload:        string(payloadJSON),
+			DataOcorrencia: time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("falha ao criar evento outbox: %w", err)
+		}
+		registrado = true
+		return nil
+	})
+	if err != nil || !registrado {
+		return nota, err
+	}
+
+	slog.Info("EPEC registrado no Ambiente Nacional", "notaId", notaID, "protocolo", reg.NProt, "cStat", reg.CStat)
+	if err := publicador.PublicarEvento(context.Background(), EventoEPECRegistrado, notaID.String(), payload); err != nil {
+		slog.Warn("Failed to publish EPECRegistrado event to EventBridge", "error", err, "notaId", notaID)
+	}
+	return nota, nil
+}
+
+// ReconciliarContingencia pede de novo a autorização das notas fechadas que
+// aguardavam o autorizador normal: as emitidas em EPEC e as que ficaram na fila
+// durante a indisponibilidade. Chamado quando a emissão volta ao modo normal.
+func (h *Handlers) ReconciliarContingencia(ctx context.Context) (int, error) {
+	var ids []uuid.UUID
+	if err := h.DB.WithContext(ctx).Model(&dominio.NotaFiscal{}).
+		Where("status = ? AND chave_acesso IS NOT NULL", dominio.StatusNotaFechada).
+		Order("data_fechada").Limit(limiteReconciliacao).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	reenviadas := 0
+	for _, id := range ids {
+		if err := h.ReenviarAutorizacaoDB(id); err != nil {
+			if errors.Is(err, dominio.ErrNotaNaoAutorizavel) {
+				continue
+			}
+			return reenviadas, err
+		}
+		reenviadas++
+	}
+	slog.Info("Notas pendentes reenviadas ao autorizador", "quantidade", reenviadas)
+	return reenviadas, nil
+}
+
+// StatusContingenciaDB devolve o modo de emissão vigente, as notas pendentes e o histórico
+func (h *Handlers) StatusContingenciaDB() (StatusContingencia, error) {
+	status := StatusContingencia{Modo: dominio.ModoNormal}
+	vigente, err := contingencia.Atual(h.DB)
+	if err != nil {
+		return status, err
+	}
+	if vigente != nil {
+		status.Modo, status.Vigente = vigente.Modo, vigente
+	}
+	if status.Pendentes, err = contingencia.Pendentes(h.DB); err != nil {
+		return status, err
+	}
+	status.Historico, err = contingencia.Historico(h.DB, 20)
+	return status, err
+}
+
+// AtivarContingenciaDB põe as próximas notas no modo pedido pelo operador. O modo
+// precisa ter endereço configurado: SVC com SEFAZ_SVC_URL e EPEC com SEFAZ_EPEC_URL.
+func (h *Handlers) AtivarContingenciaDB(dados DadosContingencia) (*dominio.Contingencia, error) {
+	nova, err := dominio.NovaContingencia(dados.Modo, dados.Justificativa,
+		nfe.CarregarConfiguracao().Emitente.EnderEmit.UF, false, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if h.Sefaz == nil {
+		return nil, sefaz.ErrSefazDesabilitada
+	}
+	switch cfg := h.Sefaz.Configuracao(); {
+	case nova.Modo == dominio.ModoEPEC && cfg.URLEPEC == "":
+		return nil, sefaz.ErrEPECDesabilitado
+	case nova.Modo != dominio.ModoEPEC && !cfg.HabilitadaSVC():
+		return nil, sefaz.ErrSVCDesabilitada
+	}
+
+	vigente, err := contingencia.Ativar(h.DB, &nova)
+	if err != nil {
+		return nil, err
+	}
+	slog.Warn("Contingencia ativada pelo operador", "modo", vigente.Modo, "justificativa", vigente.Justificativa)
+	return vigente, nil
+}
+
+// EncerrarContingenciaDB volta a emissão ao modo normal e reenvia as notas pendentes
+func (h *Handlers) EncerrarContingenciaDB(ctx context.Context) (*dominio.Contingencia, int, error) {
+	encerrada, err := contingencia.Encerrar(h.DB, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+	slog.Info("Contingencia encerrada pelo operador", "modo", encerrada.Modo, "inicio", encerrada.DataInicio)
+	if h.Contingencia != nil {
+		h.Contingencia.Detector.Limpar()
+	}
+	reenviadas, err := h.ReconciliarContingencia(ctx)
+	return encerrada, reenviadas, err
+}
+
+// RespostaErroContingencia traduz erros da troca do modo de emissão para status HTTP e corpo de resposta
+func RespostaErroContingencia(err error) (int, map[string]interface{}) {
+	switch {
+	case errors.Is(err, dominio.ErrModoContingenciaInvalido),
+		errors.Is(err, dominio.ErrSVCIncompativel),
+		errors.Is(err, dominio.ErrJustificativaInvalida):
+		return http.StatusBadRequest, gin.H{"erro": err.Error()}
+	case errors.Is(err, dominio.ErrSemContingencia):
+		return http.StatusConflict, gin.H{"erro": err.Error()}
+	case errors.Is(err, sefaz.ErrSefazDesabilitada),
+		errors.Is(err, sefaz.ErrSVCDesabilitada),
+		errors.Is(err, sefaz.ErrEPECDesabilitado):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao alterar modo de emissao"}
+	}
+}
+
+func responderErroContingencia(c *gin.Context, err error) {
+	status, corpo := RespostaErroContingencia(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Falha na contingencia", "erro", err)
+	}
+	c.JSON(status, corpo)
+}
+
+// StatusContingenciaHTTP - GET /api/v1/admin/contingencia
+func (h *Handlers) StatusContingenciaHTTP(c *gin.Context) {
+	status, err := h.StatusContingenciaDB()
+	if err != nil {
+		responderErroContingencia(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// AtivarContingencia - POST /api/v1/admin/contingencia
+func (h *Handlers) AtivarContingencia(c *gin.Context) {
+	var dados DadosContingencia
+	if err := c.ShouldBindJSON(&dados); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	vigente, err := h.AtivarContingenciaDB(dados)
+	if err != nil {
+		responderErroContingencia(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, vigente)
+}
+
+// EncerrarContingencia - DELETE /api/v1/admin/contingencia
+func (h *Handlers) EncerrarContingencia(c *gin.Context) {
+	encerrada, reenviadas, err := h.EncerrarContingenciaDB(c.Request.Context())
+	if err != nil {
+		responderErroContingencia(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"encerrada": encerrada, "notasReenviadas": reenviadas})
+}
@@ -0,0 +1,228 @@
+package manipulador
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+	"servico-faturamento/internal/publicador"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventoCartaCorrecaoRegistrada avisa que a nota recebeu uma CC-e (e o PDF deve ser refeito)
+const EventoCartaCorrecaoRegistrada = "Faturamento.CartaCorrecaoRegistrada"
+
+// DadosCartaCorrecao é o corpo de POST /api/v1/notas/:id/correcoes
+type DadosCartaCorrecao struct {
+	Correcao string `json:"correcao"`
+}
+
+type payloadCartaCorrecao struct {
+	NotaID      string    `json:"notaId"`
+	ChaveAcesso string    `json:"chaveAcesso"`
+	Sequencia   int       `json:"sequencia"`
+	Correcao    string    `json:"correcao"`
+	DataEvento  time.Time `json:"dataEvento"`
+}
+
+// RegistrarCartaCorrecaoDB grava a próxima CC-e da nota com o XML do evento 110110.
+// A nota fica bloqueada durante a transação para que duas cartas simultâneas não
+// disputem o mesmo nSeqEvento; o evento do outbox é gravado na mesma transação.
+func (h *Handlers) RegistrarCartaCorrecaoDB(notaID uuid.UUID, correcao string) (dominio.CartaCorrecao, error) {
+	cfg := nfe.CarregarConfiguracao()
+
+	var nota dominio.NotaFiscal
+	var carta dominio.CartaCorrecao
+	var payload payloadCartaCorrecao
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+
+		var registradas int64
+		if err := tx.Model(&dominio.CartaCorrecao{}).Where("nota_id = ?", notaID).Count(&registradas).Error; err != nil {
+			return err
+		}
+
+		var err error
+		carta, err = nota.NovaCartaCorrecao(int(registradas), correcao, time.Now())
+		if err != nil {
+			return err
+		}
+
+		evento, err := nfe.GerarEventoCartaCorrecao(nota, carta, cfg)
+		if err != nil {
+			return err
+		}
+		xmlEvento, err := nfe.SerializarEvento(evento)
+		if err != nil {
+			return err
+		}
+		if xmlEvento, err = h.assinarXML(xmlEvento, "infEvento", evento.InfEvento.CNPJ); err != nil {
+			return err
+		}
+		carta.XML = string(xmlEvento)
+
+		if err := tx.Create(&carta).Error; err != nil {
+			return err
+		}
+
+		payload = payloadCartaCorrecao{
+			NotaID:      notaID.String(),
+			ChaveAcesso: *nota.ChaveAcesso,
+			Sequencia:   carta.Sequencia,
+			Correcao:    carta.Correcao,
+			DataEvento:  carta.DataEvento,
+		}
+		payloadJSON, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("falha ao serializar payload: %w", err)
+		}
+
+		if err := tx.Create(&dominio.EventoOutbox{
+			TipoEvento:     EventoCartaCorrecaoRegistrada,
+			IdAgregado:     notaID,
+			Payload:        string(payloadJSON),
+			DataOcorrencia: carta.DataEvento,
+		}).Error; err != nil {
+			return fmt.Errorf("falha ao criar evento outbox: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return dominio.CartaCorrecao{}, err
+	}
+
+	slog.Info("Carta de correcao registrada", "notaId", notaID, "sequencia", carta.Sequencia)
+	h.arquivarXML(armazenamento.ChaveXMLCartaCorrecao(nota, carta.Sequencia), carta.XML)
+
+	// Publicar diretamente no EventBridge (serverless mode); o outbox garante a entrega
+	if err := publicador.PublicarEvento(context.Background(), EventoCartaCorrecaoRegistrada, notaID.String(), payload); err != nil {
+		slog.Warn("Failed to publish CartaCorrecaoRegistrada event to EventBridge", "error", err, "notaId", notaID)
+	}
+
+	carta.Vigente = true
+	return carta, nil
+}
+
+// ListarCartasCorrecaoDB devolve as cartas da nota em ordem de sequência, com a última marcada como vigente
+func (h *Handlers) ListarCartasCorrecaoDB(notaID uuid.UUID) ([]dominio.CartaCorrecao, error) {
+	var nota dominio.NotaFiscal
+	if err := h.DB.Select("id").First(&nota, "id = ?", notaID).Error; err != nil {
+		return nil, err
+	}
+
+	cartas := []dominio.CartaCorrecao{}
+	if err := h.DB.Where("nota_id = ?", notaID).Order("sequencia").Find(&cartas).Error; err != nil {
+		return nil, err
+	}
+	dominio.MarcarCartaVigente(cartas)
+	return cartas, nil
+}
+
+// XMLCartaCorrecaoDB devolve o XML do evento gravado no registro da carta
+func (h *Handlers) XMLCartaCorrecaoDB(notaID uuid.UUID, sequencia int) ([]byte, error) {
+	var carta dominio.CartaCorrecao
+	if err := h.DB.First(&carta, "nota_id = ? AND sequencia = ?", notaID, sequencia).Error; err != nil {
+		return nil, err
+	}
+	return []byte(carta.XML), nil
+}
+
+// RespostaErroCartaCorrecao traduz os erros da CC-e para status HTTP e corpo JSON
+func RespostaErroCartaCorrecao(err error) (int, map[string]interface{}) {
+	var errosValidacao nfe.ErrosValidacao
+	switch {
+	case errors.Is(err, dominio.ErrCorrecaoInvalida), errors.Is(err, assinatura.ErrCertificadoOutroEmitente):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	case errors.As(err, &errosValidacao):
+		return http.StatusUnprocessableEntity, gin.H{"erro": "Evento nao atende ao leiaute da CC-e", "detalhes": []string(errosValidacao)}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Nota ou carta de correcao nao encontrada"}
+	case errors.Is(err, dominio.ErrNotaNaoCorrigivel), errors.Is(err, dominio.ErrLimiteCartasCorrecao),
+		errors.Is(err, dominio.ErrChaveAcessoInvalida), errors.Is(err, gorm.ErrDuplicatedKey):
+		return http.StatusConflict, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao processar carta de correcao"}
+	}
+}
+
+func responderErroCartaCorrecao(c *gin.Context, err error) {
+	status, corpo := RespostaErroCartaCorrecao(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Falha na carta de correcao", "path", c.FullPath(), "erro", err)
+	}
+	c.JSON(status, corpo)
+}
+
+// RegistrarCartaCorrecao - POST /api/v1/notas/:id/correcoes
+func (h *Handlers) RegistrarCartaCorrecao(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var req DadosCartaCorrecao
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	carta, err := h.RegistrarCartaCorrecaoDB(notaID, req.Correcao)
+	if err != nil {
+		responderErroCartaCorrecao(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, carta)
+}
+
+// ListarCartasCorrecao - GET /api/v1/notas/:id/correcoes
+func (h *Handlers) ListarCartasCorrecao(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	cartas, err := h.ListarCartasCorrecaoDB(notaID)
+	if err != nil {
+		responderErroCartaCorrecao(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, cartas)
+}
+
+// BaixarXMLCartaCorrecao - GET /api/v1/notas/:id/correcoes/:sequencia/xml
+func (h *Handlers) BaixarXMLCartaCorrecao(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+	sequencia, err := strconv.Atoi(c.Param("sequencia"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "Sequencia invalida"})
+		return
+	}
+
+	xmlEvento, err := h.XMLCartaCorrecaoDB(notaID, sequencia)
+	if err != nil {
+		responderErroCartaCorrecao(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", xmlEvento)
+}
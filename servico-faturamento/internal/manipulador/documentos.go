@@ -0,0 +1,418 @@
+package manipulador
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/impressao"
+	"servico-faturamento/internal/linkassinado"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Documentos da nota entregues pelo serviço, diretamente ou por link assinado
+const (
+	DocumentoPDF = "pdf"
+	DocumentoXML = "xml"
+)
+
+var (
+	// ErrDocumentoInvalido indica documento diferente de pdf e xml
+	ErrDocumentoInvalido = errors.New("documento deve ser pdf ou xml")
+	// ErrPDFNaoGerado indica nota cujo DANFE ainda não chegou ao armazenamento
+	ErrPDFNaoGerado = errors.New("PDF da nota ainda nao foi gerado; solicite a impressao")
+	// ErrArmazenamentoDesabilitado indica serviço sem ARMAZENAMENTO, que não guarda os PDFs
+	ErrArmazenamentoDesabilitado = errors.New("armazenamento de documentos nao configurado (ARMAZENAMENTO)")
+	// ErrVersaoInvalida indica parâmetro versao que não é um inteiro positivo
+	ErrVersaoInvalida = errors.New("versao deve ser um inteiro positivo")
+	// ErrVersaoNaoEncontrada indica versão do PDF que não foi gerada para a nota
+	ErrVersaoNaoEncontrada = errors.New("versao do PDF nao encontrada")
+	// ErrMotivoRegeneracaoInvalido indica motivo ausente ou com mais de 255 caracteres
+	ErrMotivoRegeneracaoInvalido = fmt.Errorf("motivo da regeneracao deve ter de 1 a %d caracteres", tamanhoMaximoMotivo)
+)
+
+// tamanhoMaximoMotivo acompanha a coluna documentos.motivo
+const tamanhoMaximoMotivo = 255
+
+// DocumentoNota é o arquivo entregue com o tipo de conteúdo e o nome sugerido
+type DocumentoNota struct {
+	Conteudo    []byte
+	Tipo        string
+	NomeArquivo string
+}
+
+// LinkDocumento é a resposta de POST /api/v1/notas/:id/links
+type LinkDocumento struct {
+	URL      string    `json:"url"`
+	ExpiraEm time.Time `json:"expiraEm"`
+}
+
+// DadosLinkDocumento é o corpo de POST /api/v1/notas/:id/links
+type DadosLinkDocumento struct {
+	Documento string `json:"documento"`
+}
+
+// DadosRegeneracaoPDF é o corpo de POST /api/v1/notas/:id/pdf/regenerar
+type DadosRegeneracaoPDF struct {
+	Motivo string `json:"motivo"`
+}
+
+// RegeneracaoPDF é a resposta da regeneração: a versão vigente do DANFE e se ela
+// acabou de ser criada (conteúdo igual ao da versão anterior não gera versão)
+type RegeneracaoPDF struct {
+	Documento  dominio.Documento `json:"documento"`
+	NovaVersao bool              `json:"novaVersao"`
+}
+
+// CaminhoDocumentoAssinado é a rota pública que entrega o documento do link assinado
+func CaminhoDocumentoAssinado(notaID uuid.UUID, documento string) string {
+	return fmt.Sprintf("/api/v1/documentos/notas/%s/%s", notaID, documento)
+}
+
+// CaminhoPDFNota é a rota autenticada do DANFE; versao 0 é a vigente
+func CaminhoPDFNota(notaID uuid.UUID, versao int) string {
+	if versao == 0 {
+		return fmt.Sprintf("/api/v1/notas/%s/pdf", notaID)
+	}
+	return fmt.Sprintf("/api/v1/notas/%s/pdf?versao=%d", notaID, versao)
+}
+
+// LerVersaoDocumento interpreta o parâmetro versao da consulta; vazio é a versão vigente (0)
+func LerVersaoDocumento(valor string) (int, error) {
+	if valor == "" {
+		return 0, nil
+	}
+	versao, err := strconv.Atoi(valor)
+	if err != nil || versao < 1 {
+		return 0, ErrVersaoInvalida
+	}
+	return versao, nil
+}
+
+// DocumentoNotaDB carrega o PDF do armazenamento ou monta o XML da nota. versao
+// escolhe uma versão do PDF; 0 é a vigente e o XML não é versionado.
+func (h *Handlers) DocumentoNotaDB(ctx context.Context, notaID uuid.UUID, documento string, versao int) (DocumentoNota, error) {
+	if documento != DocumentoPDF && documento != DocumentoXML {
+		return DocumentoNota{}, ErrDocumentoInvalido
+	}
+
+	var nota dominio.NotaFiscal
+	if err := h.DB.Select("id", "data_criacao", "chave_acesso").First(&nota, "id = ?", notaID).Error; err != nil {
+		return DocumentoNota{}, err
+	}
+	nome := notaID.String()
+	if nota.ChaveAcesso != nil {
+		nome = *nota.ChaveAcesso
+	}
+
+	if documento == DocumentoXML {
+		xmlNFe, err := h.GerarXML(notaID)
+		if err != nil {
+			return DocumentoNota{}, err
+		}
+		return DocumentoNota{Conteudo: xmlNFe, Tipo: "application/xml; charset=utf-8", NomeArquivo: nome + "-nfe.xml"}, nil
+	}
+
+	if h.Armazenamento == nil {
+		return DocumentoNota{}, ErrArmazenamentoDesabilitado
+	}
+	chave, err := h.chavePDF(nota, versao)
+	if err != nil {
+		return DocumentoNota{}, err
+	}
+	pdf, err := h.Armazenamento.Ler(ctx, chave)
+	if errors.Is(err, armazenamento.ErrNaoEncontrado) {
+		return DocumentoNota{}, ErrPDFNaoGerado
+	}
+	if err != nil {
+		return DocumentoNota{}, err
+	}
+	if versao > 0 {
+		nome = fmt.Sprintf("%s-v%d", nome, versao)
+	}
+	return DocumentoNota{Conteudo: pdf, Tipo: armazenamento.TipoPDF, NomeArquivo: nome + ".pdf"}, nil
+}
+
+// chavePDF localiza a versão pedida do DANFE (0 é a vigente). Nota sem versões
+// registradas teve o PDF gerado antes do versionamento, na chave sem versão.
+func (h *Handlers) chavePDF(nota dominio.NotaFiscal, versao int) (string, error) {
+	consulta := h.DB.Where("nota_id = ? AND tipo = ?", nota.ID, dominio.TipoDocumentoDANFE)
+	if versao > 0 {
+		consulta = consulta.Where("versao = ?", versao)
+	}
+	var doc dominio.Documento
+	err := consulta.Order("versao DESC").First(&doc).Error
+	switch {
+	case err == nil:
+		return doc.Chave, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return "", err
+	case versao > 0:
+		return "", ErrVersaoNaoEncontrada
+	default:
+		return armazenamento.ChavePDF(nota), nil
+	}
+}
+
+// ListarDocumentosDB devolve o histórico das versões do DANFE, da primeira à vigente
+func (h *Handlers) ListarDocumentosDB(notaID uuid.UUID) ([]dominio.Documento, error) {
+	var nota dominio.NotaFiscal
+	if err := h.DB.Select("id").First(&nota, "id = ?", notaID).Error; err != nil {
+		return nil, err
+	}
+	documentos := []dominio.Documento{}
+	err := h.DB.Where("nota_id = ?", notaID).Order("tipo, versao").Find(&documentos).Error
+	return documentos, err
+}
+
+// RegenerarPDFDB gera o DANFE de novo, por exemplo depois de uma correção no
+// leiaute. O conteúdo novo vira a próxima versão; as anteriores continuam no
+// armazenamento e no histórico com o motivo de cada geração.
+func (h *Handlers) RegenerarPDFDB(ctx context.Context, notaID uuid.UUID, motivo string) (RegeneracaoPDF, error) {
+	motivo = strings.TrimSpace(motivo)
+	if motivo == "" || utf8.RuneCountInString(motivo) > tamanhoMaximoMotivo {
+		return RegeneracaoPDF{}, ErrMotivoRegeneracaoInvalido
+	}
+	if h.Armazenamento == nil {
+		return RegeneracaoPDF{}, ErrArmazenamentoDesabilitado
+	}
+
+	processador := &impressao.Processador{DB: h.DB, Armazenamento: h.Armazenamento}
+	doc, nova, err := processador.Regenerar(ctx, notaID, motivo)
+	if err != nil {
+		return RegeneracaoPDF{}, err
+	}
+	return RegeneracaoPDF{Documento: doc, NovaVersao: nova}, nil
+}
+
+// GerarLinkDocumentoDB emite o link assinado do documento de uma nota existente
+func (h *Handlers) GerarLinkDocumentoDB(notaID uuid.UUID, documento string) (LinkDocumento, error) {
+	if h.Links == nil {
+		return LinkDocumento{}, linkassinado.ErrLinksDesabilitados
+	}
+	if documento != DocumentoPDF && documento != DocumentoXML {
+		return LinkDocumento{}, ErrDocumentoInvalido
+	}
+	var nota dominio.NotaFiscal
+	if err := h.DB.Select("id").First(&nota, "id = ?", notaID).Error; err != nil {
+		return LinkDocumento{}, err
+	}
+
+	url, expira := h.Links.Gerar(CaminhoDocumentoAssinado(notaID, documento), time.Now())
+	return LinkDocumento{URL: url, ExpiraEm: expira}, nil
+}
+
+// ConferirLinkDocumento valida os parâmetros expira e assinatura do link
+func (h *Handlers) ConferirLinkDocumento(notaID uuid.UUID, documento, expira, assinatura string) error {
+	if h.Links == nil {
+		return linkassinado.ErrLinksDesabilitados
+	}
+	return h.Links.Conferir(CaminhoDocumentoAssinado(notaID, documento), expira, assinatura, time.Now())
+}
+
+// ConsultarSolicitacaoDB carrega a solicitação de impressão. A solicitação guarda
+// só a chave do PDF entregue; pdfUrl é um link assinado, com links configurados,
+// ou o endpoint autenticado da versão entregue.
+func (h *Handlers) ConsultarSolicitacaoDB(id uuid.UUID) (dominio.SolicitacaoImpressao, error) {
+	var sol dominio.SolicitacaoImpressao
+	if err := h.DB.First(&sol, "id = ?", id).Error; err != nil {
+		return sol, err
+	}
+	if sol.Status != "CONCLUIDA" || sol.PdfChave == nil {
+		return sol, nil
+	}
+	if h.Links != nil {
+		url, _ := h.Links.Gerar(CaminhoDocumentoAssinado(sol.NotaID, DocumentoPDF), time.Now())
+		sol.PdfURL = &url
+		return sol, nil
+	}
+	var doc dominio.Documento
+	err := h.DB.Select("versao").Where("nota_id = ? AND chave = ?", sol.NotaID, *sol.PdfChave).First(&doc).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return sol, err
+	}
+	url := CaminhoPDFNota(sol.NotaID, doc.Versao)
+	sol.PdfURL = &url
+	return sol, nil
+}
+
+// RespostaErroDocumento traduz os erros da entrega de documentos para status HTTP e corpo JSON
+func RespostaErroDocumento(err error) (int, map[string]interface{}) {
+	switch {
+	case errors.Is(err, ErrDocumentoInvalido), errors.Is(err, ErrVersaoInvalida):
+		return http.StatusBadRequest, gin.H{"erro": err.Error()}
+	case errors.Is(err, linkassinado.ErrLinkInvalido), errors.Is(err, linkassinado.ErrLinkExpirado):
+		return http.StatusForbidden, gin.H{"erro": err.Error()}
+	case errors.Is(err, ErrPDFNaoGerado), errors.Is(err, ErrVersaoNaoEncontrada):
+		return http.StatusNotFound, gin.H{"erro": err.Error()}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"}
+	case errors.Is(err, linkassinado.ErrLinksDesabilitados), errors.Is(err, ErrArmazenamentoDesabilitado):
+		return http.StatusServiceUnavailable, gin.H{"erro": err.Error()}
+	default:
+		// Erros da montagem do XML seguem o mapeamento de GET /notas/:id/xml
+		return RespostaErroXML(err)
+	}
+}
+
+// RespostaErroRegeneracao traduz os erros da regeneração do PDF para status HTTP e corpo JSON
+func RespostaErroRegeneracao(err error) (int, map[string]interface{}) {
+	switch {
+	case errors.Is(err, ErrMotivoRegeneracaoInvalido), errors.Is(err, impressao.ErrDANFEInviavel):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	case errors.Is(err, impressao.ErrNotaAberta):
+		return http.StatusConflict, gin.H{"erro": "Nota precisa estar fechada para gerar o PDF"}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"}
+	case errors.Is(err, ErrArmazenamentoDesabilitado):
+		return http.StatusServiceUnavailable, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao regenerar PDF"}
+	}
+}
+
+func responderErroDocumento(c *gin.Context, notaID uuid.UUID, err error) {
+	status, corpo := RespostaErroDocumento(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Falha ao entregar documento da nota", "notaId", notaID, "path", c.FullPath(), "erro", err)
+	}
+	c.JSON(status, corpo)
+}
+
+// DisposicaoDocumento é o Content-Disposition da entrega: o PDF abre no navegador
+// e o XML é baixado
+func DisposicaoDocumento(doc DocumentoNota) string {
+	modo := "attachment"
+	if doc.Tipo == armazenamento.TipoPDF {
+		modo = "inline"
+	}
+	return fmt.Sprintf(`%s; filename="%s"`, modo, doc.NomeArquivo)
+}
+
+func (h *Handlers) entregarDocumento(c *gin.Context, notaID uuid.UUID, documento string, versao int) {
+	doc, err := h.DocumentoNotaDB(c.Request.Context(), notaID, documento, versao)
+	if err != nil {
+		responderErroDocumento(c, notaID, err)
+		return
+	}
+	c.Header("Content-Disposition", DisposicaoDocumento(doc))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, doc.Tipo, doc.Conteudo)
+}
+
+// BaixarPDF - GET /api/v1/notas/:id/pdf?versao=
+func (h *Handlers) BaixarPDF(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+	versao, err := LerVersaoDocumento(c.Query("versao"))
+	if err != nil {
+		responderErroDocumento(c, notaID, err)
+		return
+	}
+	h.entregarDocumento(c, notaID, DocumentoPDF, versao)
+}
+
+// ListarDocumentos - GET /api/v1/notas/:id/documentos
+func (h *Handlers) ListarDocumentos(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	documentos, err := h.ListarDocumentosDB(notaID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"})
+		return
+	}
+	if err != nil {
+		slog.Error("Falha ao listar documentos da nota", "notaId", notaID, "erro", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Falha ao listar documentos"})
+		return
+	}
+	c.JSON(http.StatusOK, documentos)
+}
+
+// RegenerarPDF - POST /api/v1/notas/:id/pdf/regenerar
+func (h *Handlers) RegenerarPDF(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var req DadosRegeneracaoPDF
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	regeneracao, err := h.RegenerarPDFDB(c.Request.Context(), notaID, req.Motivo)
+	if err != nil {
+		status, corpo := RespostaErroRegeneracao(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Falha ao regenerar PDF", "notaId", notaID, "erro", err)
+		}
+		c.JSON(status, corpo)
+		return
+	}
+
+	status := http.StatusOK
+	if regeneracao.NovaVersao {
+		status = http.StatusCreated
+	}
+	c.JSON(status, regeneracao)
+}
+
+// GerarLinkDocumento - POST /api/v1/notas/:id/links
+func (h *Handlers) GerarLinkDocumento(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var req DadosLinkDocumento
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	link, err := h.GerarLinkDocumentoDB(notaID, req.Documento)
+	if err != nil {
+		responderErroDocumento(c, notaID, err)
+		return
+	}
+	c.JSON(http.StatusCreated, link)
+}
+
+// BaixarDocumentoAssinado - GET /api/v1/documentos/notas/:id/:documento?expira=&assinatura=
+// Rota sem autenticação: o acesso é garantido pela assinatura do link
+func (h *Handlers) BaixarDocumentoAssinado(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	documento := c.Param("documento")
+	if err := h.ConferirLinkDocumento(notaID, documento, c.Query(linkassinado.ParamExpira), c.Query(linkassinado.ParamAssinatura)); err != nil {
+		responderErroDocumento(c, notaID, err)
+		return
+	}
+	h.entregarDocumento(c, notaID, documento, 0)
+}
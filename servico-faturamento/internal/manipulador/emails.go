@@ -0,0 +1,277 @@
+package manipulador
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventoEmailSolicitado é gravado com os envios do reenvio e aciona o despachante;
+// os envios da autorização partem do próprio EventoNotaAutorizada
+const EventoEmailSolicitado = "Faturamento.EmailSolicitado"
+
+// maxDestinatariosEmail limita os endereços de um reenvio
+const maxDestinatariosEmail = 10
+
+var (
+	// ErrEmailDesabilitado indica serviço sem EMAIL_SMTP_HOST
+	ErrEmailDesabilitado = errors.New("envio de email nao configurado (EMAIL_SMTP_HOST)")
+	// ErrEmailNotaNaoAutorizada indica reenvio de nota que não está autorizada
+	ErrEmailNotaNaoAutorizada = errors.New("somente notas autorizadas sao enviadas por email")
+	// ErrSemDestinatario indica nota sem email do cliente e sem destinatários no pedido
+	ErrSemDestinatario = errors.New("nota sem destinatario: cliente sem email e nenhum destinatario informado")
+	// ErrDestinatarioInvalido indica endereço de email inválido ou destinatários demais
+	ErrDestinatarioInvalido = fmt.Errorf("destinatarios devem ser de 1 a %d emails validos", maxDestinatariosEmail)
+	// ErrEnvioNaoEncontrado indica devolução de mensagem que o serviço não enviou
+	ErrEnvioNaoEncontrado = errors.New("envio de email nao encontrado")
+)
+
+// DadosReenvioEmail é o corpo (opcional) de POST /api/v1/notas/:id/reenviar-email;
+// sem destinatários vale o email do cliente da nota
+type DadosReenvioEmail struct {
+	Destinatarios []string `json:"destinatarios"`
+}
+
+// DadosDevolucaoEmail é o corpo de POST /api/v1/emails/devolucoes, com o Message-ID
+// da mensagem devolvida (DSN do servidor ou webhook do provedor)
+type DadosDevolucaoEmail struct {
+	MessageID    string `json:"messageId"`
+	Destinatario string `json:"destinatario"`
+	Motivo       string `json:"motivo"`
+}
+
+// registrarReenvios cria um envio por destinatário e grava no outbox, na mesma
+// transação, o evento que aciona o despachante
+func registrarReenvios(tx *gorm.DB, nota *dominio.NotaFiscal, destinatarios []string) ([]dominio.EnvioEmail, error) {
+	envios := make([]dominio.EnvioEmail, 0, len(destinatarios))
+	for _, destinatario := range destinatarios {
+		envios = append(envios, dominio.EnvioEmail{NotaID: nota.ID, Destinatario: destinatario, Origem: dominio.OrigemEmailReenvio})
+	}
+	if err := tx.Create(&envios).Error; err != nil {
+		return nil, fmt.Errorf("falha ao registrar envios de email: %w", err)
+	}
+	if err := gravarEventoNota(tx, EventoEmailSolicitado, nota); err != nil {
+		return nil, err
+	}
+	return envios, nil
+}
+
+// emailDoCliente é o destinatário padrão: o email do cadastro do cliente da nota
+func emailDoCliente(tx *gorm.DB, nota *dominio.NotaFiscal) ([]string, error) {
+	if nota.ClienteID == nil {
+		return nil, nil
+	}
+	var cliente dominio.Cliente
+	if err := tx.Select("id", "email").First(&cliente, "id = ?", *nota.ClienteID).Error; err != nil {
+		return nil, err
+	}
+	if cliente.Email == "" {
+		return nil, nil
+	}
+	return []string{cliente.Email}, nil
+}
+
+// normalizarDestinatarios valida os endereços e remove os repetidos
+func normalizarDestinatarios(destinatarios []string) ([]string, error) {
+	if len(destinatarios) > maxDestinatariosEmail {
+		return nil, ErrDestinatarioInvalido
+	}
+	vistos := map[string]bool{}
+	normalizados := make([]string, 0, len(destinatarios))
+	for _, d := range destinatarios {
+		endereco, err := mail.ParseAddress(strings.TrimSpace(d))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrDestinatarioInvalido, d)
+		}
+		chave := strings.ToLower(endereco.Address)
+		if !vistos[chave] {
+			vistos[chave] = true
+			normalizados = append(normalizados, endereco.Address)
+		}
+	}
+	return normalizados, nil
+}
+
+// ReenviarEmailDB registra novos envios do DANFE e do XML da nota autorizada
+func (h *Handlers) ReenviarEmailDB(notaID uuid.UUID, destinatarios []string) ([]dominio.EnvioEmail, error) {
+	if h.Email == nil {
+		return nil, ErrEmailDesabilitado
+	}
+	destinatarios, err := normalizarDestinatarios(destinatarios)
+	if err != nil {
+		return nil, err
+	}
+
+	var envios []dominio.EnvioEmail
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var nota dominio.NotaFiscal
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+		if nota.Status != dominio.StatusNotaAutorizada {
+			return ErrEmailNotaNaoAutorizada
+		}
+		if len(destinatarios) == 0 {
+			if destinatarios, err = emailDoCliente(tx, &nota); err != nil {
+				return err
+			}
+		}
+		if len(destinatarios) == 0 {
+			return ErrSemDestinatario
+		}
+
+		envios, err = registrarReenvios(tx, &nota, destinatarios)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Reenvio de email registrado", "notaId", notaID, "destinatarios", len(envios))
+	return envios, nil
+}
+
+// ListarEnviosEmailDB devolve os envios de email da nota, do mais antigo ao mais recente
+func (h *Handlers) ListarEnviosEmailDB(notaID uuid.UUID) ([]dominio.EnvioEmail, error) {
+	var nota dominio.NotaFiscal
+	if err := h.DB.Select("id").First(&nota, "id = ?", notaID).Error; err != nil {
+		return nil, err
+	}
+	envios := []dominio.EnvioEmail{}
+	err := h.DB.Where("nota_id = ?", notaID).Order("data_criacao").Find(&envios).Error
+	return envios, err
+}
+
+// RegistrarDevolucaoEmailDB marca como devolvido o envio da mensagem informada.
+// Devolução repetida da mesma mensagem não altera o registro.
+func (h *Handlers) RegistrarDevolucaoEmailDB(dados DadosDevolucaoEmail) (dominio.EnvioEmail, error) {
+	var envio dominio.EnvioEmail
+	messageID := strings.TrimSpace(dados.MessageID)
+	if messageID == "" {
+		return envio, ErrEnvioNaoEncontrado
+	}
+	if !strings.HasPrefix(messageID, "<") {
+		messageID = "<" + messageID + ">"
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		consulta := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("message_id = ?", messageID)
+		if dados.Destinatario != "" {
+			consulta = consulta.Where("LOWER(destinatario) = ?", strings.ToLower(strings.TrimSpace(dados.Destinatario)))
+		}
+		if err := consulta.First(&envio).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEnvioNaoEncontrado
+			}
+			return err
+		}
+		if envio.Status == dominio.StatusEmailDevolvido {
+			return nil
+		}
+
+		motivo := strings.TrimSpace(dados.Motivo)
+		if motivo == "" {
+			motivo = "devolvido sem motivo informado"
+		}
+		envio.RegistrarDevolucao(motivo, time.Now())
+		return tx.Save(&envio).Error
+	})
+	if err != nil {
+		return envio, err
+	}
+	slog.Warn("Devolucao de email registrada", "notaId", envio.NotaID, "envioId", envio.ID, "destinatario", envio.Destinatario)
+	return envio, nil
+}
+
+// RespostaErroEmail traduz os erros dos envios de email para status HTTP e corpo JSON
+func RespostaErroEmail(err error) (int, map[string]interface{}) {
+	switch {
+	case errors.Is(err, ErrDestinatarioInvalido), errors.Is(err, ErrSemDestinatario):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	case errors.Is(err, ErrEmailNotaNaoAutorizada):
+		return http.StatusConflict, gin.H{"erro": err.Error()}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"}
+	case errors.Is(err, ErrEnvioNaoEncontrado):
+		return http.StatusNotFound, gin.H{"erro": err.Error()}
+	case errors.Is(err, ErrEmailDesabilitado):
+		return http.StatusServiceUnavailable, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao processar envio de email"}
+	}
+}
+
+func responderErroEmail(c *gin.Context, err error) {
+	status, corpo := RespostaErroEmail(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Falha no envio de email", "path", c.FullPath(), "erro", err)
+	}
+	c.JSON(status, corpo)
+}
+
+// ReenviarEmail - POST /api/v1/notas/:id/reenviar-email
+func (h *Handlers) ReenviarEmail(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var req DadosReenvioEmail
+	if c.Request.ContentLength != 0 {
+		if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+			return
+		}
+	}
+
+	envios, err := h.ReenviarEmailDB(notaID, req.Destinatarios)
+	if err != nil {
+		responderErroEmail(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, envios)
+}
+
+// ListarEnviosEmail - GET /api/v1/notas/:id/emails
+func (h *Handlers) ListarEnviosEmail(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	envios, err := h.ListarEnviosEmailDB(notaID)
+	if err != nil {
+		responderErroEmail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, envios)
+}
+
+// RegistrarDevolucaoEmail - POST /api/v1/emails/devolucoes
+func (h *Handlers) RegistrarDevolucaoEmail(c *gin.Context) {
+	var req DadosDevolucaoEmail
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	envio, err := h.RegistrarDevolucaoEmailDB(req)
+	if err != nil {
+		responderErroEmail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, envio)
+}
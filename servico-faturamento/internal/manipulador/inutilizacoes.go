@@ -0,0 +1,273 @@
+package manipulador
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+	"servico-faturamento/internal/numeracao"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DadosInutilizacao é o corpo de POST /api/v1/inutilizacoes; sem emitente, modelo
+// ou série valem os mesmos padrões da criação de notas
+type DadosInutilizacao struct {
+	EmitenteID    *uuid.UUID `json:"emitenteId"`
+	Modelo        string     `json:"modelo"`
+	Serie         *int       `json:"serie"`
+	NumeroInicial int64      `json:"numeroInicial"`
+	NumeroFinal   int64      `json:"numeroFinal"`
+	Justificativa string     `json:"justificativa"`
+}
+
+// serieDoEmitente resolve CNPJ, UF, modelo e série como em NovaNota
+func (h *Handlers) serieDoEmitente(emitenteID *uuid.UUID, modelo string, serie *int) (numeracao.Serie, *dominio.Emitente, nfe.Configuracao, error) {
+	cfg := nfe.CarregarConfiguracao()
+	s := numeracao.Serie{CNPJEmitente: cfg.Emitente.CNPJ, Modelo: modelo}
+	if s.Modelo == "" {
+		s.Modelo = nfe.ModeloNFe
+	}
+
+	emitente, err := h.resolverEmitente(emitenteID, cfg.Emitente.CNPJ)
+	if err != nil {
+		return s, nil, cfg, err
+	}
+	if emitente != nil {
+		s.CNPJEmitente = emitente.CNPJ
+	}
+
+	if serie != nil {
+		s.Serie = *serie
+	} else {
+		s.Serie, err = cfg.SerieEmissao()
+	}
+	return s, emitente, cfg, err
+}
+
+// CriarInutilizacaoDB registra a faixa inutilizada com o XML do pedido à SEFAZ
+func (h *Handlers) CriarInutilizacaoDB(dados DadosInutilizacao) (dominio.Inutilizacao, error) {
+	s, emitente, cfg, err := h.serieDoEmitente(dados.EmitenteID, dados.Modelo, dados.Serie)
+	if err != nil {
+		return dominio.Inutilizacao{}, err
+	}
+
+	agora := time.Now()
+	inut := dominio.Inutilizacao{
+		CNPJEmitente:  s.CNPJEmitente,
+		Modelo:        s.Modelo,
+		Serie:         s.Serie,
+		NumeroInicial: dados.NumeroInicial,
+		NumeroFinal:   dados.NumeroFinal,
+		Ano:           agora.In(dominio.FusoBrasilia).Format("06"),
+		Justificativa: dados.Justificativa,
+		DataRegistro:  agora,
+	}
+	if emitente != nil {
+		inut.EmitenteID = &emitente.ID
+	}
+	if err := inut.Validar(); err != nil {
+		return inut, err
+	}
+
+	uf := cfg.Emitente.EnderEmit.UF
+	if emitente != nil {
+		uf = nfe.MontarEmit(*emitente).EnderEmit.UF
+	}
+	doc, err := nfe.GerarInutilizacao(inut, uf, cfg)
+	if err != nil {
+		return inut, err
+	}
+	xmlPedido, err := nfe.SerializarInutilizacao(doc)
+	if err != nil {
+		return inut, err
+	}
+	if xmlPedido, err = h.assinarXML(xmlPedido, "infInut", inut.CNPJEmitente); err != nil {
+		return inut, err
+	}
+	inut.XML = string(xmlPedido)
+
+	if err := h.DB.Transaction(func(tx *gorm.DB) error {
+		return numeracao.Inutilizar(tx, &inut, agora)
+	}); err != nil {
+		return inut, err
+	}
+
+	slog.Info("Faixa de numeracao inutilizada", "inutilizacaoId", inut.ID, "modelo", inut.Modelo, "serie", inut.Serie,
+		"numeroInicial", inut.NumeroInicial, "numeroFinal", inut.NumeroFinal)
+	h.arquivarXML(armazenamento.ChaveXMLInutilizacao(inut), inut.XML)
+	return inut, nil
+}
+
+// ListarInutilizacoesDB lista as faixas inutilizadas, das mais recentes para as mais antigas
+func (h *Handlers) ListarInutilizacoesDB(cnpj, modelo, serie string) ([]dominio.Inutilizacao, error) {
+	query := h.DB.Order("data_registro DESC")
+	if cnpj != "" {
+		query = query.Where("cnpj_emitente = ?", dominio.SomenteDigitos(cnpj))
+	}
+	if modelo != "" {
+		query = query.Where("modelo = ?", modelo)
+	}
+	if serie != "" {
+		n, err := strconv.Atoi(serie)
+		if err != nil {
+			return nil, numeracao.ErrSerieInvalida
+		}
+		query = query.Where("serie = ?", n)
+	}
+
+	inutilizacoes := []dominio.Inutilizacao{}
+	if err := query.Find(&inutilizacoes).Error; err != nil {
+		return nil, err
+	}
+	return inutilizacoes, nil
+}
+
+// BuscarInutilizacaoDB carrega uma inutilização pelo ID
+func (h *Handlers) BuscarInutilizacaoDB(id uuid.UUID) (dominio.Inutilizacao, error) {
+	var inut dominio.Inutilizacao
+	err := h.DB.First(&inut, "id = ?", id).Error
+	return inut, err
+}
+
+// LacunasDB lista os números da série que ainda precisam ser inutilizados
+func (h *Handlers) LacunasDB(emitenteID *uuid.UUID, modelo string, serie *int) ([]dominio.FaixaNumeracao, error) {
+	s, _, _, err := h.serieDoEmitente(emitenteID, modelo, serie)
+	if err != nil {
+		return nil, err
+	}
+	return numeracao.Lacunas(h.DB, s, time.Now())
+}
+
+// RespostaErroInutilizacao traduz os erros da inutilização para status HTTP e corpo JSON
+func RespostaErroInutilizacao(err error) (int, map[string]interface{}) {
+	var errosCadastro dominio.ErrosCadastro
+	var errosValidacao nfe.ErrosValidacao
+	switch {
+	case errors.As(err, &errosCadastro):
+		return http.StatusUnprocessableEntity, gin.H{"erro": "Inutilizacao invalida", "detalhes": []string(errosCadastro)}
+	case errors.As(err, &errosValidacao):
+		return http.StatusUnprocessableEntity, gin.H{"erro": "Pedido nao atende ao leiaute da inutilizacao", "detalhes": []string(errosValidacao)}
+	case errors.Is(err, numeracao.ErrSerieInvalida):
+		return http.StatusBadRequest, gin.H{"erro": err.Error()}
+	case errors.Is(err, ErrEmitenteNaoEncontrado), errors.Is(err, assinatura.ErrCertificadoOutroEmitente):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Inutilizacao nao encontrada"}
+	case errors.Is(err, numeracao.ErrFaixaJaInutilizada), errors.Is(err, numeracao.ErrFaixaComNotas):
+		return http.StatusConflict, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao processar inutilizacao"}
+	}
+}
+
+func responderErroInutilizacao(c *gin.Context, err error) {
+	status, corpo := RespostaErroInutilizacao(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Falha na inutilizacao", "path", c.FullPath(), "erro", err)
+	}
+	c.JSON(status, corpo)
+}
+
+// CriarInutilizacao - POST /api/v1/inutilizacoes
+func (h *Handlers) CriarInutilizacao(c *gin.Context) {
+	var req DadosInutilizacao
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	inut, err := h.CriarInutilizacaoDB(req)
+	if err != nil {
+		responderErroInutilizacao(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, inut)
+}
+
+// ListarInutilizacoes - GET /api/v1/inutilizacoes
+func (h *Handlers) ListarInutilizacoes(c *gin.Context) {
+	inutilizacoes, err := h.ListarInutilizacoesDB(c.Query("cnpj"), c.Query("modelo"), c.Query("serie"))
+	if err != nil {
+		responderErroInutilizacao(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, inutilizacoes)
+}
+
+// BuscarInutilizacao - GET /api/v1/inutilizacoes/:id
+func (h *Handlers) BuscarInutilizacao(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	inut, err := h.BuscarInutilizacaoDB(id)
+	if err != nil {
+		responderErroInutilizacao(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, inut)
+}
+
+// BaixarXMLInutilizacao - GET /api/v1/inutilizacoes/:id/xml
+func (h *Handlers) BaixarXMLInutilizacao(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	inut, err := h.BuscarInutilizacaoDB(id)
+	if err != nil {
+		responderErroInutilizacao(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", []byte(inut.XML))
+}
+
+// ListarLacunas - GET /api/v1/numeracao/lacunas?emitenteId=&modelo=&serie=
+func (h *Handlers) ListarLacunas(c *gin.Context) {
+	emitenteID, serie, ok := ParametrosSerie(c.Query("emitenteId"), c.Query("serie"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "emitenteId ou serie invalidos"})
+		return
+	}
+
+	lacunas, err := h.LacunasDB(emitenteID, c.Query("modelo"), serie)
+	if err != nil {
+		responderErroInutilizacao(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, lacunas)
+}
+
+// ParametrosSerie converte os filtros opcionais emitenteId e serie da query string
+func ParametrosSerie(emitente, serie string) (*uuid.UUID, *int, bool) {
+	var emitenteID *uuid.UUID
+	if emitente != "" {
+		id, err := uuid.Parse(emitente)
+		if err != nil {
+			return nil, nil, false
+		}
+		emitenteID = &id
+	}
+	var numeroSerie *int
+	if serie != "" {
+		n, err := strconv.Atoi(serie)
+		if err != nil {
+			return nil, nil, false
+		}
+		numeroSerie = &n
+	}
+	return emitenteID, numeroSerie, true
+}
@@ -0,0 +1,179 @@
+package manipulador
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GerarXML assina o XML NF-e 4.00 de uma nota fechada. Notas que já passaram pela
+// SEFAZ devolvem o nfeProc guardado com o protocolo; as demais, o documento gravado
+// no fechamento, que é montado aqui só para as notas fechadas sem ele.
+func (h *Handlers) GerarXML(notaID uuid.UUID) ([]byte, error) {
+	var nota dominio.NotaFiscal
+	if err := h.DB.Preload("Itens").Preload("Emitente").Preload("Cliente").Preload("Pagamentos").
+		First(&nota, "id = ?", notaID).Error; err != nil {
+		return nil, err
+	}
+	if nota.XMLAutorizado != nil {
+		return []byte(*nota.XMLAutorizado), nil
+	}
+
+	if nota.XMLFechamento == nil {
+		cfg := nfe.CarregarConfiguracao()
+		if nota.Status == dominio.StatusNotaFechada && nota.ChaveAcesso == nil {
+			if err := h.atribuirChavePendente(&nota, cfg); err != nil {
+				return nil, err
+			}
+		}
+		xmlNFe, err := montarXMLFechamento(nota, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if nota.Status != dominio.StatusNotaFechada {
+			nota.XMLFechamento = xmlNFe
+		} else if nota.XMLFechamento, err = h.gravarXMLFechamento(nota.ID, xmlNFe); err != nil {
+			return nil, err
+		}
+	}
+
+	doc, err := nfe.LerNFe([]byte(*nota.XMLFechamento))
+	if err != nil {
+		return nil, err
+	}
+	return h.assinarXML([]byte(*nota.XMLFechamento), "infNFe", doc.InfNFe.Emit.CNPJ)
+}
+
+// montarXMLFechamento gera e valida a NF-e da nota com os cadastros atuais
+func montarXMLFechamento(nota dominio.NotaFiscal, cfg nfe.Configuracao) (*string, error) {
+	doc, err := nfe.Gerar(nota, cfg)
+	if err != nil {
+		return nil, err
+	}
+	xmlNFe, err := nfe.Serializar(doc)
+	if err != nil {
+		return nil, err
+	}
+	documento := string(xmlNFe)
+	return &documento, nil
+}
+
+// gravarXMLFechamento guarda o documento da nota fechada sem ele. Com duas
+// transmissões em paralelo vale o primeiro gravado, que é o devolvido.
+func (h *Handlers) gravarXMLFechamento(notaID uuid.UUID, xmlNFe *string) (*string, error) {
+	if err := h.DB.Model(&dominio.NotaFiscal{}).
+		Where("id = ? AND xml_fechamento IS NULL", notaID).
+		Update("xml_fechamento", *xmlNFe).Error; err != nil {
+		return nil, err
+	}
+	var nota dominio.NotaFiscal
+	if err := h.DB.Select("id", "xml_fechamento").First(&nota, "id = ?", notaID).Error; err != nil {
+		return nil, err
+	}
+	if nota.XMLFechamento == nil {
+		return xmlNFe, nil
+	}
+	return nota.XMLFechamento, nil
+}
+
+// atribuirChavePendente gera e persiste a chave de notas fechadas antes da configuração do emitente
+func (h *Handlers) atribuirChavePendente(nota *dominio.NotaFiscal, cfg nfe.Configuracao) error {
+	return h.DB.Transaction(func(tx *gorm.DB) error {
+		var atual dominio.NotaFiscal
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Emitente").
+			First(&atual, "id = ?", nota.ID).Error; err != nil {
+			return err
+		}
+
+		if atual.ChaveAcesso == nil {
+			if err := nfe.AtribuirChave(&atual, cfg); err != nil {
+				return err
+			}
+			if err := tx.Model(&atual).Update("chave_acesso", atual.ChaveAcesso).Error; err != nil {
+				return err
+			}
+		}
+
+		nota.ChaveAcesso = atual.ChaveAcesso
+		return nil
+	})
+}
+
+// BuscarNotaPorChave valida a chave de acesso e carrega a nota correspondente
+func (h *Handlers) BuscarNotaPorChave(chave string) (dominio.NotaFiscal, error) {
+	var nota dominio.NotaFiscal
+	if err := dominio.ValidarChaveAcesso(chave); err != nil {
+		return nota, err
+	}
+
+	err := h.DB.Preload("Itens").Preload("Emitente").Preload("Cliente").Preload("Pagamentos").First(&nota, "chave_acesso = ?", chave).Error
+	return nota, err
+}
+
+// BuscarNotaPorChaveHTTP - GET /api/v1/notas/chave/:chave
+func (h *Handlers) BuscarNotaPorChaveHTTP(c *gin.Context) {
+	nota, err := h.BuscarNotaPorChave(c.Param("chave"))
+	if err != nil {
+		switch {
+		case errors.Is(err, dominio.ErrChaveAcessoInvalida):
+			c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"erro": "Falha ao buscar nota"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, nota)
+}
+
+// BaixarXML - GET /api/v1/notas/:id/xml
+func (h *Handlers) BaixarXML(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	xmlNFe, err := h.GerarXML(id)
+	if err != nil {
+		status, corpo := RespostaErroXML(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Falha ao gerar XML da nota", "notaId", id, "erro", err)
+		}
+		c.JSON(status, corpo)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", xmlNFe)
+}
+
+// RespostaErroXML traduz erros da geração do XML para status HTTP e corpo de resposta
+func RespostaErroXML(err error) (int, map[string]interface{}) {
+	var errosValidacao nfe.ErrosValidacao
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"}
+	case errors.Is(err, nfe.ErrNotaNaoFechada):
+		return http.StatusConflict, gin.H{"erro": "Nota precisa estar fechada para gerar o XML"}
+	case errors.Is(err, dominio.ErrChaveAcessoInvalida):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	case errors.As(err, &errosValidacao):
+		return http.StatusUnprocessableEntity, gin.H{"erro": "NF-e nao atende ao leiaute 4.00", "detalhes": []string(errosValidacao)}
+	case errors.Is(err, assinatura.ErrCertificadoOutroEmitente),
+		errors.Is(err, nfe.ErrCSCNaoConfigurado), errors.Is(err, nfe.ErrUFSemNFCe):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao gerar XML"}
+	}
+}
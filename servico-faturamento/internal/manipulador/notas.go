@@ -5,13 +5,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"regexp"
+	"strconv"
 	"time"
 
+	"servico-faturamento/internal/armazenamento"
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/contingencia"
 	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/email"
+	"servico-faturamento/internal/linkassinado"
+	"servico-faturamento/internal/nfe"
+	"servico-faturamento/internal/numeracao"
 	"servico-faturamento/internal/publicador"
+	"servico-faturamento/internal/sefaz"
+	"servico-faturamento/internal/tributacao"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -29,25 +40,251 @@ func validateIdempotencyKey(key string) bool {
 
 type Handlers struct {
 	DB *gorm.DB
+	// Certificado assina os XMLs gerados; nil deixa os documentos sem assinatura
+	Certificado *assinatura.Certificado
+	// Sefaz transmite as notas fechadas; nil deixa as notas em FECHADA
+	Sefaz *sefaz.Cliente
+	// Contingencia recebe o resultado das transmissões ao autorizador normal para
+	// detectar indisponibilidade; nil desliga a ativação automática
+	Contingencia *contingencia.Monitor
+	// Armazenamento arquiva o XML autorizado e os XMLs de eventos; nil os mantém
+	// apenas no banco
+	Armazenamento armazenamento.Armazenamento
+	// Links assina os links de download com validade; nil desliga a emissão
+	Links *linkassinado.Assinador
+	// Email envia o DANFE e o XML das notas autorizadas ao destinatário; nil desliga
+	// os envios
+	Email *email.Despachante
 }
 
-func (h *Handlers) CriarNota(c *gin.Context) {
-	var req struct {
-		Numero string `json:"numero" binding:"required"`
+var (
+	// ErrNumeroInformado indica número enviado pelo cliente fora do modo de importação
+	ErrNumeroInformado = errors.New("numero e atribuido pelo servidor; envie importacao=true para registrar nota emitida em outro sistema")
+	// ErrXMLImportado indica XML de nota importada sem assinatura válida ou divergente do pedido
+	ErrXMLImportado = errors.New("XML da nota importada rejeitado")
+	// ErrModeloInvalido indica modelo diferente de 55 (NF-e) e 65 (NFC-e)
+	ErrModeloInvalido = errors.New("modelo deve ser 55 (NF-e) ou 65 (NFC-e)")
+)
+
+// DadosNovaNota são os campos aceitos na criação de notas pela API e pela Lambda
+type DadosNovaNota struct {
+	// Modelo escolhe entre NF-e (55, padrão) e NFC-e (65)
+	Modelo string `json:"modelo" binding:"omitempty,oneof=55 65"`
+	// Serie sobrepõe NFE_SERIE (ou NFCE_SERIE na NFC-e) quando informada
+	Serie *int `json:"serie" binding:"omitempty,min=0,max=999"`
+	// Importacao habilita o envio de Numero, para notas já emitidas em outro sistema
+	Importacao bool   `json:"importacao"`
+	Numero     string `json:"numero"`
+	// XML é o NFe ou nfeProc assinado da nota importada; a assinatura é conferida e
+	// o número, quando omitido, vem do nNF
+	XML string `json:"xml"`
+	// EmitenteID escolhe o emitente; sem ele vale o cadastro com o CNPJ de EMITENTE_CNPJ
+	EmitenteID *uuid.UUID `json:"emitenteId"`
+	ClienteID  *uuid.UUID `json:"clienteId"`
+	// Cliente aceita o ID ou o CPF/CNPJ do destinatário, como enviado pela Lambda
+	Cliente string `json:"cliente"`
+}
+
+// NovaNota cria a nota aberta com o próximo número da série do emitente. A alocação
+// e a gravação ocorrem na mesma transação, então falhas não deixam lacunas.
+func (h *Handlers) NovaNota(dados DadosNovaNota) (dominio.NotaFiscal, error) {
+	cfg := nfe.CarregarConfiguracao()
+
+	nota := dominio.NotaFiscal{
+		CNPJEmitente: cfg.Emitente.CNPJ,
+		Modelo:       nfe.ModeloNFe,
+		Status:       dominio.StatusNotaAberta,
+	}
+	switch dados.Modelo {
+	case "", nfe.ModeloNFe:
+	case nfe.ModeloNFCe:
+		nota.Modelo = nfe.ModeloNFCe
+	default:
+		return nota, ErrModeloInvalido
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+	emitente, err := h.resolverEmitente(dados.EmitenteID, cfg.Emitente.CNPJ)
+	if err != nil {
+		return nota, err
+	}
+	if emitente != nil {
+		nota.EmitenteID = &emitente.ID
+		nota.CNPJEmitente = emitente.CNPJ
+	}
+
+	var cliente *dominio.Cliente
+	referencia := dados.Cliente
+	if dados.ClienteID != nil {
+		referencia = dados.ClienteID.String()
+	}
+	if referencia != "" {
+		encontrado, err := h.BuscarClientePorReferencia(referencia)
+		if err != nil {
+			return nota, err
+		}
+		cliente = &encontrado
+		nota.ClienteID = &cliente.ID
+	}
+
+	if dados.Serie != nil {
+		nota.Serie = *dados.Serie
+	} else {
+		serie, err := cfg.SerieDoModelo(nota.Modelo)
+		if err != nil {
+			return nota, err
+		}
+		nota.Serie = serie
+	}
+
+	if (dados.Numero != "" || dados.XML != "") && !dados.Importacao {
+		return nota, ErrNumeroInformado
+	}
+	if dados.XML != "" {
+		numero, err := conferirXMLImportado([]byte(dados.XML), nota, dados.Numero)
+		if err != nil {
+			return nota, err
+		}
+		dados.Numero = numero
+	}
+
+	serie := numeracao.Serie{CNPJEmitente: nota.CNPJEmitente, Modelo: nota.Modelo, Serie: nota.Serie}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		var numero int64
+		var err error
+		if dados.Importacao {
+			numero, err = numeracao.RegistrarImportado(tx, serie, dados.Numero)
+		} else {
+			numero, err = numeracao.Proximo(tx, serie)
+		}
+		if err != nil {
+			return err
+		}
+
+		nota.Numero = strconv.FormatInt(numero, 10)
+		return tx.Create(&nota).Error
+	})
+	if err != nil {
+		return nota, err
+	}
+
+	nota.Emitente = emitente
+	nota.Cliente = cliente
+	slog.Info("Nota criada", "notaId", nota.ID, "numero", nota.Numero, "serie", nota.Serie, "importacao", dados.Importacao)
+	return nota, nil
+}
+
+// conferirXMLImportado valida a assinatura do XML e confere emitente, modelo, série e
+// número com a nota a registrar; devolve o número do XML quando o pedido não traz um
+func conferirXMLImportado(documento []byte, nota dominio.NotaFiscal, numero string) (string, error) {
+	cert, err := assinatura.Verificar(documento)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrXMLImportado, err)
+	}
+
+	ident, err := nfe.IdentificarNFe(documento)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrXMLImportado, err)
+	}
+	if ident.CNPJEmitente != nota.CNPJEmitente {
+		return "", fmt.Errorf("%w: emitente do XML %s difere de %s", ErrXMLImportado, ident.CNPJEmitente, nota.CNPJEmitente)
+	}
+	if err := (&assinatura.Certificado{X509: cert}).ConferirEmitente(ident.CNPJEmitente); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrXMLImportado, err)
+	}
+	if ident.Modelo != nota.Modelo || ident.Serie != strconv.Itoa(nota.Serie) {
+		return "", fmt.Errorf("%w: modelo/serie do XML %s/%s diferem de %s/%d", ErrXMLImportado, ident.Modelo, ident.Serie, nota.Modelo, nota.Serie)
+	}
+	if numero != "" && numero != ident.Numero {
+		return "", fmt.Errorf("%w: numero do XML %s difere de %s", ErrXMLImportado, ident.Numero, numero)
+	}
+	return ident.Numero, nil
+}
+
+// assinarXML assina o elemento do documento com o certificado configurado, conferindo
+// antes se ele pertence ao emitente
+func (h *Handlers) assinarXML(documento []byte, elemento, cnpjEmitente string) ([]byte, error) {
+	if h.Certificado == nil {
+		return documento, nil
+	}
+	if err := h.Certificado.ConferirEmitente(cnpjEmitente); err != nil {
+		return nil, err
+	}
+	return h.Certificado.Assinar(documento, elemento)
+}
+
+// arquivarXML copia para o armazenamento um XML já gravado no banco. Roda depois do
+// commit: falhas só geram alerta, já que o banco continua sendo a fonte do documento
+func (h *Handlers) arquivarXML(chave, documento string) {
+	if h.Armazenamento == nil || documento == "" {
 		return
 	}
+	if err := h.Armazenamento.Salvar(context.Background(), chave, []byte(documento), armazenamento.TipoXML); err != nil {
+		slog.Warn("Falha ao arquivar XML", "chave", chave, "erro", err.Error())
+	}
+}
 
-	nota := dominio.NotaFiscal{
-		Numero: req.Numero,
-		Status: dominio.StatusNotaAberta,
+// resolverEmitente carrega o emitente pedido ou, sem ID, o cadastro do CNPJ configurado;
+// sem cadastro a nota usa apenas os dados do ambiente (EMITENTE_*)
+func (h *Handlers) resolverEmitente(id *uuid.UUID, cnpjPadrao string) (*dominio.Emitente, error) {
+	var emitente dominio.Emitente
+	query := h.DB
+	switch {
+	case id != nil:
+		query = query.Where("id = ?", *id)
+	case cnpjPadrao != "":
+		query = query.Where("cnpj = ?", cnpjPadrao)
+	default:
+		return nil, nil
+	}
+
+	if err := query.First(&emitente).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if id != nil {
+			return nil, ErrEmitenteNaoEncontrado
+		}
+		return nil, nil
 	}
+	return &emitente, nil
+}
 
-	if err := h.DB.Create(&nota).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Falha ao criar nota"})
+// RespostaErroCriacao traduz erros da numeração e dos cadastros para status HTTP e corpo de resposta
+func RespostaErroCriacao(err error) (int, map[string]interface{}) {
+	switch {
+	case errors.Is(err, ErrNumeroInformado), errors.Is(err, ErrModeloInvalido),
+		errors.Is(err, numeracao.ErrNumeroInvalido),
+		errors.Is(err, numeracao.ErrSerieInvalida):
+		return http.StatusBadRequest, gin.H{"erro": err.Error()}
+	case errors.Is(err, ErrEmitenteNaoEncontrado), errors.Is(err, ErrClienteNaoEncontrado),
+		errors.Is(err, ErrXMLImportado):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return http.StatusConflict, gin.H{"erro": "Numero ja utilizado nesta serie"}
+	case errors.Is(err, numeracao.ErrNumeracaoEsgotada), errors.Is(err, numeracao.ErrNumeroInutilizado):
+		return http.StatusConflict, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao criar nota"}
+	}
+}
+
+func (h *Handlers) CriarNota(c *gin.Context) {
+	var req DadosNovaNota
+
+	// Corpo vazio equivale a numeração automática na série padrão
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	nota, err := h.NovaNota(req)
+	if err != nil {
+		status, corpo := RespostaErroCriacao(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Falha ao criar nota", "erro", err)
+		}
+		c.JSON(status, corpo)
 		return
 	}
 
@@ -79,7 +316,7 @@ func (h *Handlers) BuscarNota(c *gin.Context) {
 	}
 
 	var nota dominio.NotaFiscal
-	if err := h.DB.Preload("Itens").First(&nota, "id = ?", id).Error; err != nil {
+	if err := h.DB.Preload("Itens").Preload("Emitente").Preload("Cliente").Preload("Pagamentos").First(&nota, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"})
 			return
@@ -88,6 +325,8 @@ func (h *Handlers) BuscarNota(c *gin.Context) {
 		return
 	}
 
+	totais := nota.CalcularTotais()
+	nota.Totais = &totais
 	c.JSON(http.StatusOK, nota)
 }
 
@@ -99,9 +338,14 @@ func (h *Handlers) AdicionarItem(c *gin.Context) {
 	}
 
 	var req struct {
-		ProdutoID     string  `json:"produtoId" binding:"required"`
-		Quantidade    int     `json:"quantidade" binding:"required,min=1"`
-		PrecoUnitario float64 `json:"precoUnitario" binding:"required,min=0"`
+		ProdutoID     string          `json:"produtoId" binding:"required"`
+		Quantidade    dominio.Decimal `json:"quantidade"`
+		PrecoUnitario dominio.Decimal `json:"precoUnitario"`
+		Descricao     string          `json:"descricao" binding:"max=120"`
+		NCM           string          `json:"ncm" binding:"omitempty,len=8,numeric"`
+		CFOP          string          `json:"cfop" binding:"omitempty,len=4,numeric"`
+		Unidade       string          `json:"unidade" binding:"max=6"`
+		Origem        string          `json:"origem" binding:"omitempty,len=1"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -115,6 +359,22 @@ func (h *Handlers) AdicionarItem(c *gin.Context) {
 		return
 	}
 
+	item := dominio.ItemNota{
+		NotaID:        notaID,
+		ProdutoID:     prodID,
+		Quantidade:    req.Quantidade,
+		PrecoUnitario: req.PrecoUnitario,
+		Descricao:     req.Descricao,
+		NCM:           req.NCM,
+		CFOP:          req.CFOP,
+		Unidade:       req.Unidade,
+		Origem:        req.Origem,
+	}
+	if err := item.Validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
 	var nota dominio.NotaFiscal
 	if err := h.DB.First(&nota, "id = ?", notaID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -130,13 +390,6 @@ func (h *Handlers) AdicionarItem(c *gin.Context) {
 		return
 	}
 
-	item := dominio.ItemNota{
-		NotaID:        notaID,
-		ProdutoID:     prodID,
-		Quantidade:    req.Quantidade,
-		PrecoUnitario: req.PrecoUnitario,
-	}
-
 	if err := h.DB.Create(&item).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Falha ao adicionar item"})
 		return
@@ -211,8 +464,8 @@ func (h *Handlers) ImprimirNota(c *gin.Context) {
 		}
 
 		type itemEvento struct {
-			ProdutoID  string `json:"produtoId"`
-			Quantidade int    `json:"quantidade"`
+			ProdutoID  string          `json:"produtoId"`
+			Quantidade dominio.Decimal `json:"quantidade"`
 		}
 
 		type payloadEvento struct {
@@ -281,8 +534,8 @@ func (h *Handlers) ConsultarStatusImpressao(c *gin.Context) {
 		return
 	}
 
-	var sol dominio.SolicitacaoImpressao
-	if err := h.DB.First(&sol, "id = ?", id).Error; err != nil {
+	sol, err := h.ConsultarSolicitacaoDB(id)
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"erro": "Solicitacao nao encontrada"})
 			return
@@ -303,6 +556,10 @@ func (h *Handlers) FecharNotaManual(c *gin.Context) {
 	}
 
 	if err := h.fecharNotaInterno(id); err != nil {
+		if status, corpo := RespostaErroPagamento(err); status != http.StatusInternalServerError {
+			c.JSON(status, corpo)
+			return
+		}
 		if err.Error() == "nota deve ter status ABERTA para ser fechada" {
 			c.JSON(http.StatusBadRequest, gin.H{"erro": "Nota ja esta fechada ou com status invalido"})
 			return
@@ -324,10 +581,10 @@ func (h *Handlers) FecharNota(notaID uuid.UUID) error {
 }
 
 func (h *Handlers) fecharNotaInterno(notaID uuid.UUID) error {
-	return h.DB.Transaction(func(tx *gorm.DB) error {
-		var nota dominio.NotaFiscal
+	var nota dominio.NotaFiscal
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Preload("Itens").
+			Preload("Itens").Preload("Emitente").Preload("Cliente").Preload("Pagamentos").
 			First(&nota, "id = ?", notaID).Error; err != nil {
 			return err
 		}
@@ -336,7 +593,32 @@ func (h *Handlers) fecharNotaInterno(notaID uuid.UUID) error {
 			return err
 		}
 
-		if err := tx.Save(&nota).Error; err != nil {
+		cfg := nfe.CarregarConfiguracao()
+		if err := tributacao.Aplicar(tx, &nota, nfe.OperacaoDaNota(&nota, cfg)); err != nil {
+			return err
+		}
+
+		// Conferido depois dos tributos: o IPI entra no vNF que os pagamentos cobrem
+		if err := nota.ConferirPagamentos(); err != nil {
+			return err
+		}
+
+		// O tpEmis entra na chave: a nota fechada em contingência sai no modo vigente
+		modo, err := contingencia.Atual(tx)
+		if err != nil {
+			return err
+		}
+		nota.AplicarContingencia(modo)
+
+		if err := nfe.AtribuirChave(&nota, cfg); err != nil {
+			// A chave é gerada depois, na primeira consulta do XML, quando o emitente estiver configurado
+			slog.Warn("Nota fechada sem chave de acesso", "notaId", notaID, "erro", err)
+		} else if nota.XMLFechamento, err = montarXMLFechamento(nota, cfg); err != nil {
+			// Sem o retrato a nota segue fechada; a falha volta na transmissão
+			slog.Warn("Nota fechada sem XML do fechamento", "notaId", notaID, "erro", err)
+		}
+
+		if err := tx.Omit(clause.Associations).Save(&nota).Error; err != nil {
 			return err
 		}
 
@@ -350,15 +632,26 @@ func (h *Handlers) fecharNotaInterno(notaID uuid.UUID) error {
 			return err
 		}
 
-		// Publicar evento EventBridge para gerar PDF
-		payload := map[string]string{"notaId": notaID.String()}
-		if err := publicador.PublicarEvento(context.Background(), "Faturamento.NotaFechada", notaID.String(), payload); err != nil {
-			slog.Warn("Failed to publish NotaFechada event to EventBridge", "error", err, "notaId", notaID)
-			// Não falhar a transação por causa disso
+		if err := RegistrarNotaFechada(tx, &nota); err != nil {
+			return err
 		}
-
-		return nil
+		return h.SolicitarAutorizacao(tx, &nota)
 	})
+	if err != nil {
+		return err
+	}
+
+	// Publicado só depois do commit: antes dele o consumidor ainda leria a nota
+	// ABERTA e um rollback deixaria o evento sem nota fechada. Uma falha aqui não
+	// perde nada, o outbox já tem os dois eventos.
+	payload := payloadAutorizacao(&nota)
+	if err := publicador.PublicarEvento(context.Background(), EventoNotaFechada, notaID.String(), payload); err != nil {
+		slog.Warn("Failed to publish NotaFechada event to EventBridge", "error", err, "notaId", notaID)
+	}
+	if err := publicador.PublicarEvento(context.Background(), EventoAutorizacaoSolicitada, notaID.String(), payload); err != nil {
+		slog.Warn("Failed to publish AutorizacaoSolicitada event to EventBridge", "error", err, "notaId", notaID)
+	}
+	return nil
 }
 
 func (h *Handlers) MarcarFalha(notaID uuid.UUID, motivo string) error {
@@ -0,0 +1,188 @@
+package manipulador
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/publicador"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	limitePadraoOutbox = 50
+	limiteMaximoOutbox = 500
+)
+
+var (
+	// ErrFiltroOutboxInvalido indica status ou limite inválido na listagem do outbox
+	ErrFiltroOutboxInvalido = fmt.Errorf("status deve ser PENDENTE, PUBLICADO ou MORTO e limite de 1 a %d", limiteMaximoOutbox)
+	// ErrEventoOutboxNaoEncontrado indica evento inexistente no outbox
+	ErrEventoOutboxNaoEncontrado = errors.New("evento do outbox nao encontrado")
+)
+
+// FiltroOutbox são os parâmetros de GET /api/v1/admin/outbox; sem status lista os MORTO
+type FiltroOutbox struct {
+	Status     string
+	TipoEvento string
+	Limite     int
+}
+
+// LerFiltroOutbox valida os parâmetros da listagem do outbox
+func LerFiltroOutbox(status, tipoEvento, limite string) (FiltroOutbox, error) {
+	filtro := FiltroOutbox{Status: dominio.StatusOutboxMorto, TipoEvento: tipoEvento, Limite: limitePadraoOutbox}
+	switch status {
+	case "":
+	case dominio.StatusOutboxPendente, dominio.StatusOutboxPublicado, dominio.StatusOutboxMorto:
+		filtro.Status = status
+	default:
+		return filtro, ErrFiltroOutboxInvalido
+	}
+	if limite != "" {
+		n, err := strconv.Atoi(limite)
+		if err != nil || n < 1 || n > limiteMaximoOutbox {
+			return filtro, ErrFiltroOutboxInvalido
+		}
+		filtro.Limite = n
+	}
+	return filtro, nil
+}
+
+// LerIDEventoOutbox converte o :id da rota no ID do evento
+func LerIDEventoOutbox(valor string) (int64, error) {
+	id, err := strconv.ParseInt(valor, 10, 64)
+	if err != nil || id < 1 {
+		return 0, ErrEventoOutboxNaoEncontrado
+	}
+	return id, nil
+}
+
+// ListarEventosOutboxDB lista os eventos do outbox no status do filtro, do mais recente ao mais antigo
+func (h *Handlers) ListarEventosOutboxDB(ctx context.Context, filtro FiltroOutbox) ([]dominio.EventoOutbox, error) {
+	consulta := h.DB.WithContext(ctx).Where("status = ?", filtro.Status)
+	if filtro.TipoEvento != "" {
+		consulta = consulta.Where("tipo_evento = ?", filtro.TipoEvento)
+	}
+	eventos := []dominio.EventoOutbox{}
+	err := consulta.Order("id DESC").Limit(filtro.Limite).Find(&eventos).Error
+	return eventos, err
+}
+
+// BuscarEventoOutboxDB devolve o evento com o payload, as tentativas e o último erro
+func (h *Handlers) BuscarEventoOutboxDB(ctx context.Context, id int64) (dominio.EventoOutbox, error) {
+	var evento dominio.EventoOutbox
+	err := h.DB.WithContext(ctx).First(&evento, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return evento, ErrEventoOutboxNaoEncontrado
+	}
+	return evento, err
+}
+
+// ReenfileirarEventoOutboxDB devolve o evento MORTO à publicação, com as tentativas
+// zeradas; deve ser usado depois de corrigida a causa da falha
+func (h *Handlers) ReenfileirarEventoOutboxDB(ctx context.Context, id int64) (dominio.EventoOutbox, error) {
+	var evento dominio.EventoOutbox
+	err := h.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&evento, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEventoOutboxNaoEncontrado
+			}
+			return err
+		}
+		if err := evento.Reenfileirar(); err != nil {
+			return err
+		}
+		if err := tx.Model(&evento).Updates(map[string]interface{}{
+			"status":            evento.Status,
+			"tentativas":        evento.Tentativas,
+			"proxima_tentativa": evento.ProximaTentativa,
+			"data_morte":        evento.DataMorte,
+		}).Error; err != nil {
+			return err
+		}
+		// O trigger só notifica INSERT; o reenfileirado acorda o publicador no commit
+		return publicador.NotificarOutbox(tx)
+	})
+	if err != nil {
+		return evento, err
+	}
+	slog.Info("Evento do outbox reenfileirado", "eventoId", evento.ID, "tipoEvento", evento.TipoEvento)
+	return evento, nil
+}
+
+// RespostaErroOutbox traduz os erros da administração do outbox para status HTTP e corpo JSON
+func RespostaErroOutbox(err error) (int, map[string]interface{}) {
+	switch {
+	case errors.Is(err, ErrFiltroOutboxInvalido):
+		return http.StatusBadRequest, gin.H{"erro": err.Error()}
+	case errors.Is(err, ErrEventoOutboxNaoEncontrado):
+		return http.StatusNotFound, gin.H{"erro": err.Error()}
+	case errors.Is(err, dominio.ErrEventoNaoMorto):
+		return http.StatusConflict, gin.H{"erro": err.Error()}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao consultar outbox"}
+	}
+}
+
+func responderErroOutbox(c *gin.Context, err error) {
+	status, corpo := RespostaErroOutbox(err)
+	if status == http.StatusInternalServerError {
+		slog.Error("Falha na administracao do outbox", "path", c.FullPath(), "erro", err)
+	}
+	c.JSON(status, corpo)
+}
+
+// ListarEventosOutbox - GET /api/v1/admin/outbox?status=MORTO&tipoEvento=&limite=
+func (h *Handlers) ListarEventosOutbox(c *gin.Context) {
+	filtro, err := LerFiltroOutbox(c.Query("status"), c.Query("tipoEvento"), c.Query("limite"))
+	if err != nil {
+		responderErroOutbox(c, err)
+		return
+	}
+
+	eventos, err := h.ListarEventosOutboxDB(c.Request.Context(), filtro)
+	if err != nil {
+		responderErroOutbox(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, eventos)
+}
+
+// BuscarEventoOutbox - GET /api/v1/admin/outbox/:id
+func (h *Handlers) BuscarEventoOutbox(c *gin.Context) {
+	id, err := LerIDEventoOutbox(c.Param("id"))
+	if err != nil {
+		responderErroOutbox(c, err)
+		return
+	}
+
+	evento, err := h.BuscarEventoOutboxDB(c.Request.Context(), id)
+	if err != nil {
+		responderErroOutbox(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, evento)
+}
+
+// ReenfileirarEventoOutbox - POST /api/v1/admin/outbox/:id/reenfileirar
+func (h *Handlers) ReenfileirarEventoOutbox(c *gin.Context) {
+	id, err := LerIDEventoOutbox(c.Param("id"))
+	if err != nil {
+		responderErroOutbox(c, err)
+		return
+	}
+
+	evento, err := h.ReenfileirarEventoOutboxDB(c.Request.Context(), id)
+	if err != nil {
+		responderErroOutbox(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, evento)
+}
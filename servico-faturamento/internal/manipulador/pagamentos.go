@@ -0,0 +1,99 @@
+package manipulador
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"servico-faturamento/internal/dominio"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotaNaoAberta indica alteração em nota que já foi fechada
+var ErrNotaNaoAberta = errors.New("nota nao esta aberta")
+
+// DadosPagamento é o corpo de POST /api/v1/notas/:id/pagamentos
+type DadosPagamento struct {
+	// Forma é o tPag (01 dinheiro, 03 crédito, 04 débito, 17 PIX, 99 outros...)
+	Forma     string          `json:"forma"`
+	Descricao string          `json:"descricao"`
+	Valor     dominio.Decimal `json:"valor"`
+	// Bandeira (tBand) e Autorizacao (cAut) identificam a operação com cartão
+	Bandeira    string `json:"bandeira"`
+	Autorizacao string `json:"autorizacao"`
+}
+
+// AdicionarPagamentoDB registra uma forma de pagamento na nota aberta. A soma é
+// conferida no fechamento, quando o vNF já inclui os tributos.
+func (h *Handlers) AdicionarPagamentoDB(notaID uuid.UUID, dados DadosPagamento) (dominio.PagamentoNota, error) {
+	pagamento := dominio.PagamentoNota{
+		NotaID:      notaID,
+		Forma:       dados.Forma,
+		Descricao:   dados.Descricao,
+		Valor:       dados.Valor,
+		Bandeira:    dados.Bandeira,
+		Autorizacao: dados.Autorizacao,
+	}
+	if err := pagamento.Validar(); err != nil {
+		return pagamento, err
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var nota dominio.NotaFiscal
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&nota, "id = ?", notaID).Error; err != nil {
+			return err
+		}
+		if nota.Status != dominio.StatusNotaAberta {
+			return ErrNotaNaoAberta
+		}
+		return tx.Create(&pagamento).Error
+	})
+	return pagamento, err
+}
+
+// RespostaErroPagamento traduz os erros do grupo de pagamentos, no registro e no
+// fechamento da nota, para status HTTP e corpo JSON
+func RespostaErroPagamento(err error) (int, map[string]interface{}) {
+	switch {
+	case errors.Is(err, dominio.ErrPagamentoInvalido):
+		return http.StatusBadRequest, gin.H{"erro": err.Error()}
+	case errors.Is(err, dominio.ErrPagamentoObrigatorio), errors.Is(err, dominio.ErrPagamentoInsuficiente):
+		return http.StatusUnprocessableEntity, gin.H{"erro": err.Error()}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound, gin.H{"erro": "Nota nao encontrada"}
+	case errors.Is(err, ErrNotaNaoAberta):
+		return http.StatusConflict, gin.H{"erro": "Nota nao esta aberta"}
+	default:
+		return http.StatusInternalServerError, gin.H{"erro": "Falha ao registrar pagamento"}
+	}
+}
+
+// AdicionarPagamento - POST /api/v1/notas/:id/pagamentos
+func (h *Handlers) AdicionarPagamento(c *gin.Context) {
+	notaID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var req DadosPagamento
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	pagamento, err := h.AdicionarPagamentoDB(notaID, req)
+	if err != nil {
+		status, corpo := RespostaErroPagamento(err)
+		if status == http.StatusInternalServerError {
+			slog.Error("Falha ao registrar pagamento", "notaId", notaID, "erro", err)
+		}
+		c.JSON(status, corpo)
+		return
+	}
+	c.JSON(http.StatusCreated, pagamento)
+}
@@ -0,0 +1,154 @@
+package manipulador
+
+import (
+	"net/http"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CriarRegraTributariaDB valida e grava uma nova regra tributária
+func (h *Handlers) CriarRegraTributariaDB(r *dominio.RegraTributaria) error {
+	r.ID = uuid.Nil
+	if err := r.Validar(); err != nil {
+		return err
+	}
+	return h.DB.Create(r).Error
+}
+
+// AtualizarRegraTributariaDB substitui a regra. Notas já fechadas mantêm os
+// tributos calculados; a alteração vale para os próximos fechamentos.
+func (h *Handlers) AtualizarRegraTributariaDB(id uuid.UUID, dados dominio.RegraTributaria) (dominio.RegraTributaria, error) {
+	var atual dominio.RegraTributaria
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&atual, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := dados.Validar(); err != nil {
+			return err
+		}
+		dados.DataAtualizacao = time.Now()
+		if err := tx.Model(&atual).Select("*").Omit(camposProtegidos...).Updates(&dados).Error; err != nil {
+			return err
+		}
+		return tx.First(&atual, "id = ?", id).Error
+	})
+	return atual, err
+}
+
+// ExcluirRegraTributariaDB remove a regra; os itens que a usaram guardam os valores calculados
+func (h *Handlers) ExcluirRegraTributariaDB(id uuid.UUID) error {
+	resultado := h.DB.Delete(&dominio.RegraTributaria{}, "id = ?", id)
+	if resultado.Error != nil {
+		return resultado.Error
+	}
+	if resultado.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListarRegrasTributariasDB aplica os filtros opcionais de regime, CFOP e NCM
+func (h *Handlers) ListarRegrasTributariasDB(regime, cfop, ncm string) ([]dominio.RegraTributaria, error) {
+	var regras []dominio.RegraTributaria
+	query := h.DB.Order("descricao")
+	if regime != "" {
+		query = query.Where("regime = ?", regime)
+	}
+	if cfop != "" {
+		query = query.Where("cfop = ?", cfop)
+	}
+	if ncm != "" {
+		query = query.Where("ncm = ?", dominio.SomenteDigitos(ncm))
+	}
+	err := query.Find(&regras).Error
+	return regras, err
+}
+
+// CriarRegraTributaria - POST /api/v1/regras-tributarias
+func (h *Handlers) CriarRegraTributaria(c *gin.Context) {
+	var regra dominio.RegraTributaria
+	if err := c.ShouldBindJSON(&regra); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	if err := h.CriarRegraTributariaDB(&regra); err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, regra)
+}
+
+// ListarRegrasTributarias - GET /api/v1/regras-tributarias (query params: ?regime=, ?cfop= e ?ncm=)
+func (h *Handlers) ListarRegrasTributarias(c *gin.Context) {
+	regras, err := h.ListarRegrasTributariasDB(c.Query("regime"), c.Query("cfop"), c.Query("ncm"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"erro": "Falha ao listar regras tributarias"})
+		return
+	}
+
+	c.JSON(http.StatusOK, regras)
+}
+
+// BuscarRegraTributaria - GET /api/v1/regras-tributarias/:id
+func (h *Handlers) BuscarRegraTributaria(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var regra dominio.RegraTributaria
+	if err := h.DB.First(&regra, "id = ?", id).Error; err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, regra)
+}
+
+// AtualizarRegraTributaria - PUT /api/v1/regras-tributarias/:id
+func (h *Handlers) AtualizarRegraTributaria(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	var dados dominio.RegraTributaria
+	if err := c.ShouldBindJSON(&dados); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": err.Error()})
+		return
+	}
+
+	regra, err := h.AtualizarRegraTributariaDB(id, dados)
+	if err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, regra)
+}
+
+// ExcluirRegraTributaria - DELETE /api/v1/regras-tributarias/:id
+func (h *Handlers) ExcluirRegraTributaria(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"erro": "ID invalido"})
+		return
+	}
+
+	if err := h.ExcluirRegraTributariaDB(id); err != nil {
+		responderErroCadastro(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
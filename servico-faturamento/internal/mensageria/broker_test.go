@@ -0,0 +1,152 @@
+package mensageria_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+)
+
+// brokerFalso fala o mínimo do AMQP 0-9-1 para o amqp091-go: o handshake, abertura
+// e fechamento de channel e as respostas de declare, bind, QoS e consume. Derrubar
+// fecha os sockets abertos, o que dispara o NotifyClose do cliente.
+type brokerFalso struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	conns   []net.Conn
+	recusar bool
+}
+
+func novoBrokerFalso(t *testing.T) *brokerFalso {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("falha ao abrir broker falso: %v", err)
+	}
+	b := &brokerFalso{ln: ln}
+	go b.aceitar()
+	t.Cleanup(func() {
+		ln.Close()
+		b.derrubar(false)
+	})
+	return b
+}
+
+func (b *brokerFalso) URL() string {
+	return "amqp://guest:guest@" + b.ln.Addr().String() + "/"
+}
+
+// derrubar fecha as conexões abertas; com recusar, as seguintes são fechadas logo
+// ao chegar, até liberar ser chamado
+func (b *brokerFalso) derrubar(recusar bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, conn := range b.conns {
+		conn.Close()
+	}
+	b.conns, b.recusar = nil, recusar
+}
+
+func (b *brokerFalso) liberar() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recusar = false
+}
+
+func (b *brokerFalso) aceitar() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		if b.recusar {
+			conn.Close()
+		} else {
+			b.conns = append(b.conns, conn)
+			go atenderAMQP(conn)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// atenderAMQP responde cada método do cliente; os argumentos recebidos são ignorados
+func atenderAMQP(conn net.Conn) {
+	defer conn.Close()
+
+	cabecalho := make([]byte, 8) // "AMQP" 0 0 9 1
+	if _, err := io.ReadFull(conn, cabecalho); err != nil {
+		return
+	}
+	// connection.start: versão 0-9, propriedades vazias, PLAIN, en_US
+	inicio := append([]byte{0, 9, 0, 0, 0, 0}, textoLongo("PLAIN")...)
+	if escreverMetodo(conn, 0, 10, 10, append(inicio, textoLongo("en_US")...)) != nil {
+		return
+	}
+
+	for {
+		quadro := make([]byte, 7)
+		if _, err := io.ReadFull(conn, quadro); err != nil {
+			return
+		}
+		carga := make([]byte, binary.BigEndian.Uint32(quadro[3:])+1) // inclui o frame-end
+		if _, err := io.ReadFull(conn, carga); err != nil {
+			return
+		}
+		if quadro[0] != 1 { // só frames de método; heartbeats são ignorados
+			continue
+		}
+
+		canal := binary.BigEndian.Uint16(quadro[1:])
+		classe, metodo := binary.BigEndian.Uint16(carga), binary.BigEndian.Uint16(carga[2:])
+		var err error
+		switch [2]uint16{classe, metodo} {
+		case [2]uint16{10, 11}: // connection.start-ok -> tune sem limite de canais e sem heartbeat
+			err = escreverMetodo(conn, 0, 10, 30, []byte{0, 0, 0, 2, 0, 0, 0, 0})
+		case [2]uint16{10, 40}: // connection.open
+			err = escreverMetodo(conn, 0, 10, 41, textoCurto(""))
+		case [2]uint16{10, 50}: // connection.close
+			escreverMetodo(conn, 0, 10, 51, nil)
+			return
+		case [2]uint16{20, 10}: // channel.open
+			err = escreverMetodo(conn, canal, 20, 11, textoLongo(""))
+		case [2]uint16{20, 40}: // channel.close
+			err = escreverMetodo(conn, canal, 20, 41, nil)
+		case [2]uint16{40, 10}: // exchange.declare
+			err = escreverMetodo(conn, canal, 40, 11, nil)
+		case [2]uint16{50, 10}: // queue.declare
+			err = escreverMetodo(conn, canal, 50, 11, append(textoCurto("fila-teste"), 0, 0, 0, 0, 0, 0, 0, 0))
+		case [2]uint16{50, 20}: // queue.bind
+			err = escreverMetodo(conn, canal, 50, 21, nil)
+		case [2]uint16{60, 10}: // basic.qos
+			err = escreverMetodo(conn, canal, 60, 11, nil)
+		case [2]uint16{60, 20}: // basic.consume
+			err = escreverMetodo(conn, canal, 60, 21, textoCurto("consumidor-teste"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func escreverMetodo(w io.Writer, canal, classe, metodo uint16, argumentos []byte) error {
+	carga := binary.BigEndian.AppendUint16(nil, classe)
+	carga = binary.BigEndian.AppendUint16(carga, metodo)
+	carga = append(carga, argumentos...)
+
+	quadro := append([]byte{1}, binary.BigEndian.AppendUint16(nil, canal)...)
+	quadro = binary.BigEndian.AppendUint32(quadro, uint32(len(carga)))
+	quadro = append(append(quadro, carga...), 0xCE)
+	_, err := w.Write(quadro)
+	return err
+}
+
+func textoCurto(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func textoLongo(s string) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(s))), s...)
+}
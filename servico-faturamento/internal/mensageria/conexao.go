@@ -0,0 +1,236 @@
+package mensageria
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	esperaReconexaoMinima = time.Second
+	esperaReconexaoMaxima = 30 * time.Second
+	// esperaConsumidor separa as tentativas de refazer a topologia e o consumo
+	// quando o channel é recusado ou fecha com a conexão de pé
+	esperaConsumidor = 5 * time.Second
+)
+
+// EsperaReconexao é o intervalo antes da tentativa de conexão seguinte à falha de
+// número tentativa (1 para a primeira): dobra a partir de 1s até o teto de 30s
+func EsperaReconexao(tentativa int) time.Duration {
+	espera := esperaReconexaoMinima
+	for i := 1; i < tentativa && espera < esperaReconexaoMaxima; i++ {
+		espera *= 2
+	}
+	if espera > esperaReconexaoMaxima {
+		return esperaReconexaoMaxima
+	}
+	return espera
+}
+
+// Estado é a situação da conexão informada ao /health
+type Estado struct {
+	Nome      string
+	Conectada bool
+	// Desde é o início da conexão atual ou, desconectada, o momento da queda
+	Desde      time.Time
+	UltimoErro string
+	Reconexoes int
+}
+
+// Conexao mantém a conexão com o RabbitMQ de um papel do serviço (publicação ou
+// consumo): acompanha o NotifyClose, reconecta com espera crescente e devolve
+// channels da conexão vigente. Cada consumidor refaz a própria topologia a cada
+// nova conexão (Consumir).
+type Conexao struct {
+	Nome string
+	url  string
+
+	mu         sync.RWMutex
+	conn       *amqp.Connection
+	conectada  chan struct{} // fechado enquanto há conexão; recriado na queda
+	desde      time.Time
+	ultimoErro string
+	reconexoes int
+	jaConectou bool
+}
+
+// NovaConexao lê RABBITMQ_URL; vazio ou "disabled" (Lambda/EventBridge) devolve nil
+func NovaConexao(nome string) *Conexao {
+	url := os.Getenv("RABBITMQ_URL")
+	if url == "" || url == "disabled" {
+		return nil
+	}
+	return &Conexao{Nome: nome, url: url, conectada: make(chan struct{}), desde: time.Now()}
+}
+
+// Iniciar conecta em segundo plano e reconecta a cada queda até o contexto ser
+// cancelado, quando fecha a conexão
+func (c *Conexao) Iniciar(ctx context.Context) {
+	go c.manter(ctx)
+}
+
+func (c *Conexao) manter(ctx context.Context) {
+	tentativa := 0
+	for {
+		conn, err := amqp.Dial(c.url)
+		if err != nil {
+			tentativa++
+			c.registrarFalha(err)
+			espera := EsperaReconexao(tentativa)
+			slog.Warn("Falha ao conectar RabbitMQ", "conexao", c.Nome, "tentativa", tentativa, "espera", espera, "erro", err)
+			if !esperar(ctx, espera) {
+				return
+			}
+			continue
+		}
+
+		tentativa = 0
+		fechada := conn.NotifyClose(make(chan *amqp.Error, 1))
+		c.registrarConexao(conn)
+		slog.Info("Conectado ao RabbitMQ", "conexao", c.Nome)
+
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return
+		case erro := <-fechada:
+			motivo := "conexao fechada"
+			if erro != nil {
+				motivo = erro.Error()
+			}
+			c.registrarQueda(motivo)
+			slog.Error("Conexao com o RabbitMQ perdida; reconectando", "conexao", c.Nome, "erro", motivo)
+		}
+	}
+}
+
+// Canal abre um channel na conexão vigente, esperando a reconexão se ela caiu;
+// só falha com o contexto cancelado
+func (c *Conexao) Canal(ctx context.Context) (*amqp.Channel, error) {
+	for {
+		c.mu.RLock()
+		conn, conectada := c.conn, c.conectada
+		c.mu.RUnlock()
+
+		if conn != nil {
+			ch, err := conn.Channel()
+			if err == nil {
+				return ch, nil
+			}
+			// A conexão caiu e o NotifyClose ainda não trocou o estado
+			if !esperar(ctx, esperaReconexaoMinima) {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-conectada:
+		}
+	}
+}
+
+// Consumir mantém um consumidor em segundo plano: a cada conexão abre um channel,
+// refaz a topologia com declarar (que devolve a fila), aplica QoS 1 e entrega as
+// mensagens a processar, que faz o ack ou o nack. Quando o channel ou a conexão
+// cai, o consumo recomeça na conexão seguinte.
+func (c *Conexao) Consumir(ctx context.Context, declarar func(ch *amqp.Channel) (string, error), processar func(msg amqp.Delivery)) {
+	go func() {
+		for {
+			ch, err := c.Canal(ctx)
+			if err != nil {
+				return
+			}
+
+			fila, msgs, err := consumir(ch, declarar)
+			if err != nil {
+				ch.Close()
+				slog.Error("Falha ao preparar consumidor RabbitMQ; nova tentativa", "conexao", c.Nome, "erro", err, "espera", esperaConsumidor)
+				if !esperar(ctx, esperaConsumidor) {
+					return
+				}
+				continue
+			}
+
+			slog.Info("Consumidor RabbitMQ ativo", "conexao", c.Nome, "fila", fila)
+			for msg := range msgs {
+				processar(msg)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Consumidor RabbitMQ interrompido; retomando", "conexao", c.Nome, "fila", fila)
+			if !esperar(ctx, esperaReconexaoMinima) {
+				return
+			}
+		}
+	}()
+}
+
+func consumir(ch *amqp.Channel, declarar func(ch *amqp.Channel) (string, error)) (string, <-chan amqp.Delivery, error) {
+	fila, err := declarar(ch)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := ch.Qos(1, 0, false); err != nil {
+		return fila, nil, fmt.Errorf("falha ao configurar QoS: %w", err)
+	}
+	msgs, err := ch.Consume(fila, "", false, false, false, false, nil)
+	if err != nil {
+		return fila, nil, fmt.Errorf("falha ao registrar consumer: %w", err)
+	}
+	return fila, msgs, nil
+}
+
+// Estado devolve a situação atual da conexão, sem abrir conexão nova
+func (c *Conexao) Estado() Estado {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Estado{
+		Nome:       c.Nome,
+		Conectada:  c.conn != nil,
+		Desde:      c.desde,
+		UltimoErro: c.ultimoErro,
+		Reconexoes: c.reconexoes,
+	}
+}
+
+func (c *Conexao) registrarConexao(conn *amqp.Connection) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.jaConectou {
+		c.reconexoes++
+	}
+	c.conn, c.desde, c.ultimoErro, c.jaConectou = conn, time.Now(), "", true
+	close(c.conectada)
+}
+
+func (c *Conexao) registrarQueda(motivo string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn, c.desde, c.ultimoErro = nil, time.Now(), motivo
+	c.conectada = make(chan struct{})
+}
+
+func (c *Conexao) registrarFalha(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ultimoErro = err.Error()
+}
+
+// esperar dorme por espera; devolve false se o contexto foi cancelado antes
+func esperar(ctx context.Context, espera time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(espera):
+		return true
+	}
+}
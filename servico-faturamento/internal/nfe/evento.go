@@ -0,0 +1,297 @@
+package nfe
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+// VersaoEvento é a versão do leiaute de eventos da NF-e (e do detEvento da CC-e e do cancelamento)
+const VersaoEvento = "1.00"
+
+// Evento é o elemento raiz de um evento da NF-e (TEvento)
+type Evento struct {
+	XMLName   xml.Name  `xml:"http://www.portalfiscal.inf.br/nfe evento"`
+	Versao    string    `xml:"versao,attr"`
+	InfEvento InfEvento `xml:"infEvento"`
+}
+
+// InfEvento identifica o evento e a NF-e a que ele se refere
+type InfEvento struct {
+	ID         string    `xml:"Id,attr"`
+	COrgao     string    `xml:"cOrgao"`
+	TpAmb      string    `xml:"tpAmb"`
+	CNPJ       string    `xml:"CNPJ"`
+	ChNFe      string    `xml:"chNFe"`
+	DhEvento   string    `xml:"dhEvento"`
+	TpEvento   string    `xml:"tpEvento"`
+	NSeqEvento string    `xml:"nSeqEvento"`
+	VerEvento  string    `xml:"verEvento"`
+	DetEvento  DetEvento `xml:"detEvento"`
+}
+
+// DetEvento traz os campos específicos do tipo de evento
+type DetEvento struct {
+	Versao     string `xml:"versao,attr"`
+	DescEvento string `xml:"descEvento"`
+
+	// Cancelamento (110111): protocolo de autorização da nota e justificativa
+	NProt string `xml:"nProt,omitempty"`
+	XJust string `xml:"xJust,omitempty"`
+
+	// Carta de correção (110110)
+	XCorrecao string `xml:"xCorrecao,omitempty"`
+	XCondUso  string `xml:"xCondUso,omitempty"`
+
+	// EPEC (110140): resumo da nota emitida em contingência
+	COrgaoAutor string    `xml:"cOrgaoAutor,omitempty"`
+	TpAutor     string    `xml:"tpAutor,omitempty"`
+	VerAplic    string    `xml:"verAplic,omitempty"`
+	DhEmi       string    `xml:"dhEmi,omitempty"`
+	TpNF        string    `xml:"tpNF,omitempty"`
+	IE          string    `xml:"IE,omitempty"`
+	Dest        *DestEPEC `xml:"dest,omitempty"`
+}
+
+// DestEPEC identifica o destinatário e os valores da nota no EPEC
+type DestEPEC struct {
+	UF    string `xml:"UF"`
+	CNPJ  string `xml:"CNPJ,omitempty"`
+	CPF   string `xml:"CPF,omitempty"`
+	IE    string `xml:"IE,omitempty"`
+	VNF   string `xml:"vNF"`
+	VICMS string `xml:"vICMS"`
+	VST   string `xml:"vST"`
+}
+
+var (
+	padraoIDEvento   = regexp.MustCompile(`^ID[0-9]{52}$`)
+	padraoChaveNFe   = regexp.MustCompile(`^[0-9]{44}$`)
+	padraoNSeqEvento = regexp.MustCompile(`^([1-9]|1[0-9]|20)$`)
+	padraoProtocolo  = regexp.MustCompile(`^[0-9]{15}$`)
+)
+
+// GerarEventoCartaCorrecao monta o evento 110110 da carta. O órgão e o CNPJ saem da
+// chave de acesso da nota, e o dhEvento é a data de registro da carta em Brasília.
+func GerarEventoCartaCorrecao(nota dominio.NotaFiscal, carta dominio.CartaCorrecao, cfg Configuracao) (*Evento, error) {
+	if nota.ChaveAcesso == nil {
+		return nil, ErrNotaSemChave
+	}
+	chave, err := dominio.DecomporChaveAcesso(*nota.ChaveAcesso)
+	if err != nil {
+		return nil, err
+	}
+
+	ev := &Evento{
+		Versao: VersaoEvento,
+		InfEvento: InfEvento{
+			ID:         fmt.Sprintf("ID%s%s%02d", dominio.TipoEventoCartaCorrecao, *nota.ChaveAcesso, carta.Sequencia),
+			COrgao:     chave.CUF,
+			TpAmb:      cfg.Ambiente,
+			CNPJ:       chave.CNPJ,
+			ChNFe:      *nota.ChaveAcesso,
+			DhEvento:   carta.DataEvento.In(dominio.FusoBrasilia).Format("2006-01-02T15:04:05-07:00"),
+			TpEvento:   dominio.TipoEventoCartaCorrecao,
+			NSeqEvento: fmt.Sprint(carta.Sequencia),
+			VerEvento:  VersaoEvento,
+			DetEvento: DetEvento{
+				Versao:     VersaoEvento,
+				DescEvento: dominio.DescEventoCartaCorrecao,
+				XCorrecao:  carta.Correcao,
+				XCondUso:   dominio.CondicaoUsoCartaCorrecao,
+			},
+		},
+	}
+
+	if err := ValidarEvento(ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// GerarEventoCancelamento monta o evento 110111 da nota autorizada, com o protocolo
+// de autorização no nProt. O órgão e o CNPJ saem da chave de acesso; a nota tem um
+// único cancelamento, sempre com nSeqEvento 1.
+func GerarEventoCancelamento(nota dominio.NotaFiscal, justificativa string, dhEvento time.Time, cfg Configuracao) (*Evento, error) {
+	if nota.ChaveAcesso == nil {
+		return nil, ErrNotaSemChave
+	}
+	if nota.ProtocoloAutorizacao == nil {
+		return nil, ErrosValidacao{"detEvento/nProt: nota sem protocolo de autorizacao"}
+	}
+	chave, err := dominio.DecomporChaveAcesso(*nota.ChaveAcesso)
+	if err != nil {
+		return nil, err
+	}
+
+	ev := &Evento{
+		Versao: VersaoEvento,
+		InfEvento: InfEvento{
+			ID:         "ID" + dominio.TipoEventoCancelamento + *nota.ChaveAcesso + "01",
+			COrgao:     chave.CUF,
+			TpAmb:      cfg.Ambiente,
+			CNPJ:       chave.CNPJ,
+			ChNFe:      *nota.ChaveAcesso,
+			DhEvento:   dhEvento.In(dominio.FusoBrasilia).Format("2006-01-02T15:04:05-07:00"),
+			TpEvento:   dominio.TipoEventoCancelamento,
+			NSeqEvento: "1",
+			VerEvento:  VersaoEvento,
+			DetEvento: DetEvento{
+				Versao:     VersaoEvento,
+				DescEvento: dominio.DescEventoCancelamento,
+				NProt:      *nota.ProtocoloAutorizacao,
+				XJust:      justificativa,
+			},
+		},
+	}
+
+	if err := ValidarEvento(ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// GerarEventoEPEC monta o evento prévio de emissão em contingência a partir da NF-e
+// já gerada com tpEmis 4. O evento vai ao Ambiente Nacional (cOrgao 91) e o
+// dhEvento é o instante do envio em Brasília.
+func GerarEventoEPEC(doc *NFe, dhEvento time.Time) (*Evento, error) {
+	inf := doc.InfNFe
+	chave := strings.TrimPrefix(inf.ID, "NFe")
+	if inf.Ide.TpEmis != dominio.TipoEmissaoEPEC {
+		return nil, fmt.Errorf("EPEC exige NF-e com tpEmis 4, obteve %q", inf.Ide.TpEmis)
+	}
+	if inf.Dest == nil {
+		return nil, ErrosValidacao{"detEvento/dest: EPEC exige destinatario"}
+	}
+
+	dest := &DestEPEC{
+		CNPJ:  inf.Dest.CNPJ,
+		CPF:   inf.Dest.CPF,
+		VNF:   inf.Total.ICMSTot.VNF,
+		VICMS: inf.Total.ICMSTot.VICMS,
+		VST:   inf.Total.ICMSTot.VST,
+	}
+	if inf.Dest.EnderDest != nil {
+		dest.UF = inf.Dest.EnderDest.UF
+	}
+	if inf.Dest.IndIEDest == "1" {
+		dest.IE = inf.Dest.IE
+	}
+
+	ev := &Evento{
+		Versao: VersaoEvento,
+		InfEvento: InfEvento{
+			ID:         "ID" + dominio.TipoEventoEPEC + chave + "01",
+			COrgao:     dominio.COrgaoAmbienteNacional,
+			TpAmb:      inf.Ide.TpAmb,
+			CNPJ:       inf.Emit.CNPJ,
+			ChNFe:      chave,
+			DhEvento:   dhEvento.In(dominio.FusoBrasilia).Format("2006-01-02T15:04:05-07:00"),
+			TpEvento:   dominio.TipoEventoEPEC,
+			NSeqEvento: "1",
+			VerEvento:  VersaoEvento,
+			DetEvento: DetEvento{
+				Versao:      VersaoEvento,
+				DescEvento:  dominio.DescEventoEPEC,
+				COrgaoAutor: inf.Ide.CUF,
+				TpAutor:     "1", // emitente
+				VerAplic:    inf.Ide.VerProc,
+				DhEmi:       inf.Ide.DhEmi,
+				TpNF:        inf.Ide.TpNF,
+				IE:          inf.Emit.IE,
+				Dest:        dest,
+			},
+		},
+	}
+
+	if err := ValidarEvento(ev); err != nil {
+		return nil, err
+	}
+	return ev, nil
+}
+
+// ValidarEvento confere o evento contra o schema do tipo: envCCe_v1.00.xsd para a
+// CC-e, envEventoCancNFe_v1.00.xsd para o cancelamento e envEPEC_v1.00.xsd para o EPEC
+func ValidarEvento(ev *Evento) error {
+	v := &validador{}
+	inf := ev.InfEvento
+
+	v.igual("evento/versao", ev.Versao, VersaoEvento)
+	v.padrao("infEvento/Id", inf.ID, padraoIDEvento)
+	v.padrao("infEvento/cOrgao", inf.COrgao, padraoCUF)
+	v.enum("infEvento/tpAmb", inf.TpAmb, "12")
+	v.padrao("infEvento/CNPJ", inf.CNPJ, padraoCNPJ)
+	v.padrao("infEvento/chNFe", inf.ChNFe, padraoChaveNFe)
+	v.padrao("infEvento/dhEvento", inf.DhEvento, padraoDataHora)
+	v.padrao("infEvento/nSeqEvento", inf.NSeqEvento, padraoNSeqEvento)
+	v.igual("infEvento/verEvento", inf.VerEvento, VersaoEvento)
+	seq, _ := strconv.Atoi(inf.NSeqEvento)
+	if id := fmt.Sprintf("ID%s%s%02d", inf.TpEvento, inf.ChNFe, seq); inf.ID != id {
+		v.erros = append(v.erros, fmt.Sprintf("infEvento/Id: esperado %q", id))
+	}
+
+	det := inf.DetEvento
+	v.igual("detEvento/versao", det.Versao, VersaoEvento)
+	switch inf.TpEvento {
+	case dominio.TipoEventoCartaCorrecao:
+		v.igual("detEvento/descEvento", det.DescEvento, dominio.DescEventoCartaCorrecao)
+		v.texto("detEvento/xCorrecao", det.XCorrecao, dominio.CorrecaoMinima, dominio.CorrecaoMaxima)
+		v.igual("detEvento/xCondUso", det.XCondUso, dominio.CondicaoUsoCartaCorrecao)
+	case dominio.TipoEventoCancelamento:
+		v.igual("infEvento/nSeqEvento", inf.NSeqEvento, "1")
+		v.igual("detEvento/descEvento", det.DescEvento, dominio.DescEventoCancelamento)
+		v.padrao("detEvento/nProt", det.NProt, padraoProtocolo)
+		v.texto("detEvento/xJust", det.XJust, dominio.JustificativaMinima, dominio.JustificativaMaxima)
+	case dominio.TipoEventoEPEC:
+		v.igual("infEvento/cOrgao", inf.COrgao, dominio.COrgaoAmbienteNacional)
+		v.igual("infEvento/nSeqEvento", inf.NSeqEvento, "1")
+		v.igual("detEvento/descEvento", det.DescEvento, dominio.DescEventoEPEC)
+		v.padrao("detEvento/cOrgaoAutor", det.COrgaoAutor, padraoCUF)
+		v.enum("detEvento/tpAutor", det.TpAutor, "1")
+		v.texto("detEvento/verAplic", det.VerAplic, 1, 20)
+		v.padrao("detEvento/dhEmi", det.DhEmi, padraoDataHora)
+		v.enum("detEvento/tpNF", det.TpNF, "01")
+		v.padrao("detEvento/IE", det.IE, padraoIE)
+		if det.Dest == nil {
+			v.erros = append(v.erros, "detEvento/dest: EPEC exige destinatario")
+			break
+		}
+		v.padrao("dest/UF", det.Dest.UF, padraoUF)
+		if (det.Dest.CNPJ == "") == (det.Dest.CPF == "") {
+			v.erros = append(v.erros, "dest: informe CNPJ ou CPF")
+		} else if det.Dest.CNPJ != "" {
+			v.padrao("dest/CNPJ", det.Dest.CNPJ, padraoCNPJ)
+		} else {
+			v.padrao("dest/CPF", det.Dest.CPF, padraoCPF)
+		}
+		if det.Dest.IE != "" {
+			v.padrao("dest/IE", det.Dest.IE, padraoIEDest)
+		}
+		v.padrao("dest/vNF", det.Dest.VNF, padraoDec1302)
+		v.padrao("dest/vICMS", det.Dest.VICMS, padraoDec1302)
+		v.padrao("dest/vST", det.Dest.VST, padraoDec1302)
+	default:
+		v.erros = append(v.erros, fmt.Sprintf("infEvento/tpEvento: tipo %q nao suportado", inf.TpEvento))
+	}
+
+	if len(v.erros) > 0 {
+		return v.erros
+	}
+	return nil
+}
+
+// SerializarEvento gera o XML do evento sem indentação
+func SerializarEvento(ev *Evento) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header[:len(xml.Header)-1])
+	if err := xml.NewEncoder(&buf).Encode(ev); err != nil {
+		return nil, fmt.Errorf("falha ao serializar evento: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,205 @@
+package nfe_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+)
+
+// notaAutorizadaTeste é a nota fechada de teste já autorizada pela SEFAZ, a única
+// que aceita carta de correção e evento de cancelamento
+func notaAutorizadaTeste(t *testing.T) dominio.NotaFiscal {
+	t.Helper()
+	nota := notaFechadaTeste(t)
+	protocolo := "135250000000001"
+	nota.Status = dominio.StatusNotaAutorizada
+	nota.ProtocoloAutorizacao = &protocolo
+	return nota
+}
+
+func TestGerarEventoCartaCorrecao(t *testing.T) {
+	registro := time.Date(2025, 3, 11, 13, 0, 0, 0, time.UTC)
+
+	t.Run("deve gerar evento 110110 valido", func(t *testing.T) {
+		nota := notaAutorizadaTeste(t)
+		carta, err := nota.NovaCartaCorrecao(2, "Endereco de entrega: Rua das Flores, 100", registro)
+		if err != nil {
+			t.Fatalf("falha ao preparar carta: %v", err)
+		}
+
+		ev, err := nfe.GerarEventoCartaCorrecao(nota, carta, configuracaoTeste())
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		inf := ev.InfEvento
+		if inf.ID != "ID110110"+*nota.ChaveAcesso+"03" {
+			t.Errorf("Id inesperado: %s", inf.ID)
+		}
+		if inf.COrgao != "35" || inf.CNPJ != "11222333000181" || inf.TpAmb != "2" {
+			t.Errorf("cOrgao/CNPJ/tpAmb inesperados: %s %s %s", inf.COrgao, inf.CNPJ, inf.TpAmb)
+		}
+		if inf.NSeqEvento != "3" {
+			t.Errorf("esperava nSeqEvento 3, obteve %s", inf.NSeqEvento)
+		}
+		if inf.DhEvento != "2025-03-11T10:00:00-03:00" {
+			t.Errorf("esperava dhEvento no fuso de Brasilia, obteve %s", inf.DhEvento)
+		}
+
+		xmlEvento, err := nfe.SerializarEvento(ev)
+		if err != nil {
+			t.Fatalf("falha ao serializar: %v", err)
+		}
+		for _, trecho := range []string{
+			`<evento xmlns="http://www.portalfiscal.inf.br/nfe" versao="1.00">`,
+			`<detEvento versao="1.00"><descEvento>Carta de Correcao</descEvento><xCorrecao>Endereco de entrega: Rua das Flores, 100</xCorrecao><xCondUso>A Carta de Correcao e disciplinada`,
+		} {
+			if !strings.Contains(string(xmlEvento), trecho) {
+				t.Errorf("XML sem o trecho %q:\n%s", trecho, xmlEvento)
+			}
+		}
+	})
+
+	t.Run("deve exigir chave de acesso", func(t *testing.T) {
+		nota := notaAutorizadaTeste(t)
+		carta, _ := nota.NovaCartaCorrecao(0, "Endereco de entrega: Rua das Flores, 100", registro)
+		nota.ChaveAcesso = nil
+
+		if _, err := nfe.GerarEventoCartaCorrecao(nota, carta, configuracaoTeste()); !errors.Is(err, nfe.ErrNotaSemChave) {
+			t.Errorf("esperava ErrNotaSemChave, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar evento fora do leiaute", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+		carta := dominio.CartaCorrecao{Sequencia: 21, Correcao: " texto com espaco ", DataEvento: registro}
+
+		_, err := nfe.GerarEventoCartaCorrecao(nota, carta, configuracaoTeste())
+		var erros nfe.ErrosValidacao
+		if !errors.As(err, &erros) {
+			t.Fatalf("esperava ErrosValidacao, obteve: %v", err)
+		}
+		if len(erros) != 2 {
+			t.Errorf("esperava erros em nSeqEvento e xCorrecao, obteve: %v", erros)
+		}
+	})
+}
+
+func TestGerarEventoCancelamento(t *testing.T) {
+	registro := time.Date(2025, 3, 11, 13, 0, 0, 0, time.UTC)
+
+	t.Run("deve gerar evento 110111 com o protocolo da autorizacao", func(t *testing.T) {
+		nota := notaAutorizadaTeste(t)
+		ev, err := nfe.GerarEventoCancelamento(nota, "Pedido cancelado pelo cliente", registro, configuracaoTeste())
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		inf := ev.InfEvento
+		if inf.ID != "ID110111"+*nota.ChaveAcesso+"01" || inf.NSeqEvento != "1" {
+			t.Errorf("Id/nSeqEvento inesperados: %s %s", inf.ID, inf.NSeqEvento)
+		}
+		if inf.COrgao != "35" || inf.CNPJ != "11222333000181" {
+			t.Errorf("cOrgao/CNPJ inesperados: %s %s", inf.COrgao, inf.CNPJ)
+		}
+
+		xmlEvento, err := nfe.SerializarEvento(ev)
+		if err != nil {
+			t.Fatalf("falha ao serializar: %v", err)
+		}
+		trecho := `<detEvento versao="1.00"><descEvento>Cancelamento</descEvento><nProt>135250000000001</nProt><xJust>Pedido cancelado pelo cliente</xJust></detEvento>`
+		if !strings.Contains(string(xmlEvento), trecho) {
+			t.Errorf("XML sem o trecho %q:\n%s", trecho, xmlEvento)
+		}
+	})
+
+	t.Run("deve exigir protocolo de autorizacao", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+		var erros nfe.ErrosValidacao
+		if _, err := nfe.GerarEventoCancelamento(nota, "Pedido cancelado pelo cliente", registro, configuracaoTeste()); !errors.As(err, &erros) {
+			t.Errorf("esperava ErrosValidacao, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar justificativa fora do leiaute", func(t *testing.T) {
+		_, err := nfe.GerarEventoCancelamento(notaAutorizadaTeste(t), "curta", registro, configuracaoTeste())
+		var erros nfe.ErrosValidacao
+		if !errors.As(err, &erros) || len(erros) != 1 {
+			t.Errorf("esperava erro no xJust, obteve: %v", err)
+		}
+	})
+}
+
+func TestGerarEventoEPEC(t *testing.T) {
+	envio := time.Date(2025, 3, 10, 18, 0, 0, 0, time.UTC)
+
+	// notaEPECTeste fecha a nota em EPEC para um destinatário em outra UF
+	notaEPECTeste := func(t *testing.T) dominio.NotaFiscal {
+		nota := notaFechadaTeste(t)
+		nota.Cliente = &dominio.Cliente{
+			Documento:   "52998224725",
+			Nome:        "Maria da Silva",
+			IndicadorIE: dominio.IndicadorIENaoContribuinte,
+			Endereco: dominio.Endereco{
+				Logradouro: "Rua da Praia", Numero: "50", Bairro: "Centro Historico",
+				CodigoMunicipio: "4314902", Municipio: "Porto Alegre", UF: "RS", CEP: "90010000",
+			},
+		}
+		nota.AplicarContingencia(&dominio.Contingencia{Modo: dominio.ModoEPEC, Justificativa: "SEFAZ-SP fora do ar desde 13h", DataInicio: envio.Add(-time.Hour)})
+		nota.ChaveAcesso = nil
+		if err := nfe.AtribuirChave(&nota, configuracaoTeste()); err != nil {
+			t.Fatalf("falha ao atribuir chave: %v", err)
+		}
+		return nota
+	}
+
+	t.Run("deve gerar evento 110140 para o Ambiente Nacional", func(t *testing.T) {
+		nota := notaEPECTeste(t)
+		doc, err := nfe.Gerar(nota, configuracaoTeste())
+		if err != nil {
+			t.Fatalf("falha ao gerar NF-e: %v", err)
+		}
+		if doc.InfNFe.Ide.TpEmis != "4" || doc.InfNFe.Ide.XJust != "SEFAZ-SP fora do ar desde 13h" {
+			t.Fatalf("esperava ide com tpEmis 4 e xJust, obteve %s %q", doc.InfNFe.Ide.TpEmis, doc.InfNFe.Ide.XJust)
+		}
+
+		ev, err := nfe.GerarEventoEPEC(doc, envio)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		inf := ev.InfEvento
+		if inf.ID != "ID110140"+*nota.ChaveAcesso+"01" {
+			t.Errorf("Id inesperado: %s", inf.ID)
+		}
+		if inf.COrgao != "91" || inf.DetEvento.COrgaoAutor != "35" {
+			t.Errorf("esperava cOrgao 91 e cOrgaoAutor 35, obteve %s %s", inf.COrgao, inf.DetEvento.COrgaoAutor)
+		}
+		dest := inf.DetEvento.Dest
+		if dest == nil || dest.UF != "RS" || dest.CPF != "52998224725" || dest.IE != "" || dest.VNF != doc.InfNFe.Total.ICMSTot.VNF {
+			t.Errorf("dest inesperado: %+v", dest)
+		}
+
+		xmlEvento, err := nfe.SerializarEvento(ev)
+		if err != nil {
+			t.Fatalf("falha ao serializar: %v", err)
+		}
+		if !strings.Contains(string(xmlEvento), `<detEvento versao="1.00"><descEvento>EPEC</descEvento><cOrgaoAutor>35</cOrgaoAutor><tpAutor>1</tpAutor>`) {
+			t.Errorf("detEvento inesperado:\n%s", xmlEvento)
+		}
+	})
+
+	t.Run("deve exigir nota emitida com tpEmis 4", func(t *testing.T) {
+		doc, err := nfe.Gerar(notaFechadaTeste(t), configuracaoTeste())
+		if err != nil {
+			t.Fatalf("falha ao gerar NF-e: %v", err)
+		}
+		if _, err := nfe.GerarEventoEPEC(doc, envio); err == nil {
+			t.Error("esperava erro para nota com emissao normal")
+		}
+	})
+}
@@ -0,0 +1,4 @@
+package nfe
+
+// TiposXSD expõe aos testes os padrões copiados dos XSDs oficiais
+var TiposXSD = tiposXSD
@@ -0,0 +1,504 @@
+package nfe
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+// ErrNotaNaoFechada indica que apenas notas fechadas podem gerar o documento fiscal
+var ErrNotaNaoFechada = errors.New("nota precisa estar fechada para gerar a NF-e")
+
+// ErrNotaSemChave indica nota fechada que ainda não recebeu chave de acesso
+var ErrNotaSemChave = errors.New("nota fechada sem chave de acesso")
+
+// Configuracao reúne os dados do emitente e os parâmetros de emissão
+type Configuracao struct {
+	Emitente         Emit
+	Serie            string
+	Ambiente         string // 1 = produção, 2 = homologação
+	NaturezaOperacao string
+
+	// PrazoCancelamento é a janela, contada do fechamento, em que a nota pode ser cancelada
+	PrazoCancelamento time.Duration
+
+	// NFC-e (modelo 65): série própria e o Código de Segurança do Contribuinte
+	// (CSC) com o seu identificador, que assinam o QR Code. As URLs substituem
+	// as da tabela da UF.
+	SerieNFCe   string
+	IDTokenCSC  string
+	CSC         string
+	URLQRCode   string
+	URLConsulta string
+}
+
+// CarregarConfiguracao lê os dados do emitente das variáveis de ambiente
+func CarregarConfiguracao() Configuracao {
+	return Configuracao{
+		Emitente: Emit{
+			CNPJ:  somenteDigitos(os.Getenv("EMITENTE_CNPJ")),
+			XNome: os.Getenv("EMITENTE_RAZAO_SOCIAL"),
+			XFant: os.Getenv("EMITENTE_NOME_FANTASIA"),
+			EnderEmit: Endereco{
+				XLgr:    os.Getenv("EMITENTE_LOGRADOURO"),
+				Nro:     getEnv("EMITENTE_NUMERO", "S/N"),
+				XBairro: os.Getenv("EMITENTE_BAIRRO"),
+				CMun:    os.Getenv("EMITENTE_COD_MUNICIPIO"),
+				XMun:    os.Getenv("EMITENTE_MUNICIPIO"),
+				UF:      strings.ToUpper(os.Getenv("EMITENTE_UF")),
+				CEP:     somenteDigitos(os.Getenv("EMITENTE_CEP")),
+				CPais:   "1058",
+				XPais:   "BRASIL",
+			},
+			IE:  somenteDigitos(os.Getenv("EMITENTE_IE")),
+			CRT: getEnv("EMITENTE_CRT", "1"),
+		},
+		Serie:            getEnv("NFE_SERIE", "1"),
+		Ambiente:         getEnv("NFE_AMBIENTE", "2"),
+		NaturezaOperacao: getEnv("NFE_NATUREZA_OPERACAO", "VENDA DE MERCADORIA"),
+
+		PrazoCancelamento: prazoCancelamento(os.Getenv("NFE_PRAZO_CANCELAMENTO_HORAS")),
+
+		SerieNFCe:   getEnv("NFCE_SERIE", "1"),
+		IDTokenCSC:  somenteDigitos(os.Getenv("NFCE_CSC_ID")),
+		CSC:         os.Getenv("NFCE_CSC"),
+		URLQRCode:   os.Getenv("NFCE_URL_QRCODE"),
+		URLConsulta: os.Getenv("NFCE_URL_CONSULTA"),
+	}
+}
+
+// prazoCancelamento lê o prazo em horas; valores ausentes ou inválidos usam as 24h da SEFAZ
+func prazoCancelamento(horas string) time.Duration {
+	h, err := strconv.Atoi(horas)
+	if err != nil || h <= 0 {
+		return dominio.PrazoCancelamentoPadrao
+	}
+	return time.Duration(h) * time.Hour
+}
+
+// SerieEmissao devolve a série configurada para novas NF-e
+func (cfg Configuracao) SerieEmissao() (int, error) {
+	return cfg.SerieDoModelo(ModeloNFe)
+}
+
+// SerieDoModelo devolve a série configurada para novas notas do modelo
+func (cfg Configuracao) SerieDoModelo(modelo string) (int, error) {
+	texto := cfg.Serie
+	if modelo == ModeloNFCe {
+		texto = cfg.SerieNFCe
+	}
+	serie, err := strconv.Atoi(texto)
+	if err != nil || serie < 0 || serie > 999 {
+		return 0, fmt.Errorf("serie invalida: %q", texto)
+	}
+	return serie, nil
+}
+
+// AtribuirChave gera a chave de acesso da nota fechada com o modelo, a série e o tpEmis
+// com que ela foi numerada e fechada. A UF vem do emitente carregado na nota (Preload("Emitente")) ou,
+// na falta dele, do emitente configurado por variáveis de ambiente.
+func AtribuirChave(nota *dominio.NotaFiscal, cfg Configuracao) error {
+	emit := emitenteDaNota(nota, cfg)
+	cUF, ok := dominio.CodigoUF(emit.EnderEmit.UF)
+	if !ok {
+		return fmt.Errorf("UF do emitente invalida: %q", emit.EnderEmit.UF)
+	}
+
+	cnpj := nota.CNPJEmitente
+	if cnpj == "" {
+		cnpj = emit.CNPJ
+	}
+	modelo := nota.Modelo
+	if modelo == "" {
+		modelo = ModeloNFe
+	}
+
+	return nota.AtribuirChaveAcesso(dominio.ComponentesChave{
+		CUF:         cUF,
+		CNPJ:        cnpj,
+		Modelo:      modelo,
+		Serie:       nota.Serie,
+		TipoEmissao: nota.TipoEmissaoEfetivo(),
+	})
+}
+
+// Gerar monta a NF-e de uma nota fechada e valida o resultado contra o leiaute 4.00
+func Gerar(nota dominio.NotaFiscal, cfg Configuracao) (*NFe, error) {
+	if nota.Status != dominio.StatusNotaFechada || nota.DataFechada == nil {
+		return nil, ErrNotaNaoFechada
+	}
+	if nota.ChaveAcesso == nil {
+		return nil, ErrNotaSemChave
+	}
+
+	chave, err := dominio.DecomporChaveAcesso(*nota.ChaveAcesso)
+	if err != nil {
+		return nil, err
+	}
+
+	emissao := nota.DataFechada.In(dominio.FusoBrasilia)
+	emit := emitenteDaNota(&nota, cfg)
+
+	op := OperacaoDaNota(&nota, cfg)
+	idDest, indFinal := "1", "0"
+	if op.UFDestino != emit.EnderEmit.UF {
+		idDest = "2"
+	}
+	if op.ConsumidorFinal {
+		indFinal = "1"
+	}
+	tpImp, indPres := "1", "9"
+	var dest *Dest
+	if nota.Cliente != nil {
+		dest = MontarDest(*nota.Cliente, cfg.Ambiente)
+	}
+	if nota.NFCe() {
+		// NFC-e: venda presencial a consumidor final, DANFE NFC-e e consumidor opcional
+		tpImp, indPres = "4", "1"
+		if nota.Cliente != nil {
+			dest = MontarDestNFCe(*nota.Cliente, cfg.Ambiente)
+		}
+	}
+
+	// Notas fechadas antes do motor tributário não têm tributos gravados e saem
+	// com a tributação padrão do regime; a cópia evita alterar os itens do chamador
+	nota.Itens = append([]dominio.ItemNota(nil), nota.Itens...)
+	for i := range nota.Itens {
+		if nota.Itens[i].Tributos.CSTICMS == "" {
+			nota.Itens[i].Tributos = dominio.CalcularTributosItem(nota.Itens[i], nil, op)
+		}
+	}
+
+	doc := &NFe{
+		InfNFe: InfNFe{
+			Versao: VersaoLayout,
+			ID:     "NFe" + *nota.ChaveAcesso,
+			Ide: Ide{
+				CUF:      chave.CUF,
+				CNF:      chave.CodigoNumerico,
+				NatOp:    cfg.NaturezaOperacao,
+				Mod:      chave.Modelo,
+				Serie:    strconv.Itoa(chave.Serie),
+				NNF:      strconv.FormatInt(chave.Numero, 10),
+				DhEmi:    emissao.Format(time.RFC3339),
+				TpNF:     "1",
+				IdDest:   idDest,
+				CMunFG:   emit.EnderEmit.CMun,
+				TpImp:    tpImp,
+				TpEmis:   chave.TipoEmissao,
+				CDV:      (*nota.ChaveAcesso)[43:],
+				TpAmb:    cfg.Ambiente,
+				FinNFe:   "1",
+				IndFinal: indFinal,
+				IndPres:  indPres,
+				ProcEmi:  "0",
+				VerProc:  "faturamento-1.0",
+			},
+			Emit:   emit,
+			Dest:   dest,
+			Transp: Transp{ModFrete: "9"},
+			InfAdic: &InfAdic{
+				InfCpl: "Referencia interna: " + nota.ID.String(),
+			},
+		},
+	}
+
+	for i, item := range nota.Itens {
+		doc.InfNFe.Det = append(doc.InfNFe.Det, montarDet(i+1, item))
+	}
+
+	totais := nota.CalcularTotais()
+	doc.InfNFe.Total.ICMSTot = ICMSTot{
+		VBC: valor(totais.VBC), VICMS: valor(totais.VICMS), VICMSDeson: zero, VFCP: zero,
+		VBCST: zero, VST: zero, VFCPST: zero, VFCPSTRet: zero,
+		VProd:  valor(totais.VProd),
+		VFrete: zero, VSeg: zero, VDesc: zero, VII: zero,
+		VIPI: valor(totais.VIPI), VIPIDevol: zero, VPIS: valor(totais.VPIS), VCOFINS: valor(totais.VCOFINS), VOutro: zero,
+		VNF: valor(totais.VNF),
+	}
+	if comIBSCBS(doc.InfNFe.Det) {
+		doc.InfNFe.Total.IBSCBSTot = montarIBSCBSTot(totais)
+	}
+	doc.InfNFe.Pag = montarPag(nota.Pagamentos, totais.VNF, nota.Troco())
+
+	if chave.TipoEmissao != dominio.TipoEmissaoNormal {
+		if nota.DataContingencia != nil {
+			doc.InfNFe.Ide.DhCont = nota.DataContingencia.In(dominio.FusoBrasilia).Format(time.RFC3339)
+		}
+		if nota.JustificativaContingencia != nil {
+			doc.InfNFe.Ide.XJust = *nota.JustificativaContingencia
+		}
+	}
+
+	if nota.NFCe() {
+		supl, err := MontarInfNFeSupl(doc, cfg)
+		if err != nil {
+			return nil, err
+		}
+		doc.InfNFeSupl = supl
+	}
+
+	if err := Validar(doc); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// OperacaoDaNota reúne os dados da nota usados na seleção das regras tributárias.
+// Sem cliente a operação é interna e com contribuinte; o cliente não contribuinte
+// do ICMS é tratado como consumidor final, como em ide/indFinal.
+func OperacaoDaNota(nota *dominio.NotaFiscal, cfg Configuracao) dominio.OperacaoTributaria {
+	emit := emitenteDaNota(nota, cfg)
+	op := dominio.OperacaoTributaria{
+		Regime:    dominio.RegimeDoCRT(emit.CRT),
+		UFDestino: emit.EnderEmit.UF,
+		Data:      time.Now(),
+	}
+	if nota.DataFechada != nil {
+		op.Data = *nota.DataFechada
+	}
+	if nota.NFCe() {
+		// A NFC-e é sempre operação interna com consumidor final
+		op.ConsumidorFinal = true
+		return op
+	}
+	if nota.Cliente != nil {
+		if nota.Cliente.Endereco.UF != "" {
+			op.UFDestino = nota.Cliente.Endereco.UF
+		}
+		op.ConsumidorFinal = nota.Cliente.IndicadorIE == dominio.IndicadorIENaoContribuinte
+	}
+	return op
+}
+
+// xNomeHomologacao substitui o nome do destinatário em homologação (rejeição 598)
+const xNomeHomologacao = "NF-E EMITIDA EM AMBIENTE DE HOMOLOGACAO - SEM VALOR FISCAL"
+
+// MontarEmit converte o emitente cadastrado no grupo emit
+func MontarEmit(e dominio.Emitente) Emit {
+	return Emit{
+		CNPJ:      e.CNPJ,
+		XNome:     e.RazaoSocial,
+		XFant:     e.NomeFantasia,
+		EnderEmit: montarEndereco(e.Endereco),
+		IE:        e.IE,
+		CRT:       e.CRT,
+	}
+}
+
+// MontarDest converte o cliente no grupo dest
+func MontarDest(c dominio.Cliente, ambiente string) *Dest {
+	endereco := montarEndereco(c.Endereco)
+	dest := &Dest{
+		XNome:     c.Nome,
+		EnderDest: &endereco,
+		IndIEDest: c.IndicadorIE,
+		IE:        c.IE,
+		Email:     c.Email,
+	}
+	if c.PessoaJuridica() {
+		dest.CNPJ = c.Documento
+	} else {
+		dest.CPF = c.Documento
+	}
+	if ambiente == "2" {
+		dest.XNome = xNomeHomologacao
+	}
+	return dest
+}
+
+func montarEndereco(e dominio.Endereco) Endereco {
+	return Endereco{
+		XLgr:    e.Logradouro,
+		Nro:     e.Numero,
+		XCpl:    e.Complemento,
+		XBairro: e.Bairro,
+		CMun:    e.CodigoMunicipio,
+		XMun:    e.Municipio,
+		UF:      e.UF,
+		CEP:     e.CEP,
+		CPais:   "1058",
+		XPais:   "BRASIL",
+		Fone:    e.Telefone,
+	}
+}
+
+// emitenteDaNota prefere o emitente vinculado à nota ao configurado no ambiente
+func emitenteDaNota(nota *dominio.NotaFiscal, cfg Configuracao) Emit {
+	if nota.Emitente != nil {
+		return MontarEmit(*nota.Emitente)
+	}
+	return cfg.Emitente
+}
+
+// Serializar gera o XML da NF-e sem indentação, como exigido pela SEFAZ
+func Serializar(doc *NFe) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header[:len(xml.Header)-1])
+	if err := xml.NewEncoder(&buf).Encode(doc); err != nil {
+		return nil, fmt.Errorf("falha ao serializar NF-e: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+const zero = "0.00"
+
+func montarDet(nItem int, item dominio.ItemNota) Det {
+	unidade := item.Unidade
+	if unidade == "" {
+		unidade = "UN"
+	}
+	origem := item.Origem
+	if origem == "" {
+		origem = "0"
+	}
+
+	quantidade := item.Quantidade.String()
+	valorUnitario := item.PrecoUnitario.FormatarMinimo(2)
+	det := Det{
+		NItem: nItem,
+		Prod: Prod{
+			CProd:    item.ProdutoID.String(),
+			CEAN:     "SEM GTIN",
+			XProd:    item.Descricao,
+			NCM:      item.NCM,
+			CFOP:     item.CFOP,
+			UCom:     unidade,
+			QCom:     quantidade,
+			VUnCom:   valorUnitario,
+			VProd:    valor(item.CalcularSubtotal()),
+			CEANTrib: "SEM GTIN",
+			UTrib:    unidade,
+			QTrib:    quantidade,
+			VUnTrib:  valorUnitario,
+			IndTot:   "1",
+		},
+	}
+
+	t := item.Tributos
+	switch {
+	case t.CSTICMS == "00":
+		det.Imposto.ICMS.ICMS00 = &ICMS00{
+			Orig: origem, CST: t.CSTICMS, ModBC: modBCValorOperacao,
+			VBC: valor(t.VBCICMS), PICMS: percentual(t.PICMS), VICMS: valor(t.VICMS),
+		}
+	case t.CSTICMS == "20":
+		det.Imposto.ICMS.ICMS20 = &ICMS20{
+			Orig: origem, CST: t.CSTICMS, ModBC: modBCValorOperacao, PRedBC: percentual(t.PRedBCICMS),
+			VBC: valor(t.VBCICMS), PICMS: percentual(t.PICMS), VICMS: valor(t.VICMS),
+		}
+	case t.CSTICMS == "101":
+		det.Imposto.ICMS.ICMSSN101 = &ICMSSN101{
+			Orig: origem, CSOSN: t.CSTICMS, PCredSN: percentual(t.PCredSN), VCredICMSSN: valor(t.VCredICMSSN),
+		}
+	case len(t.CSTICMS) == 3:
+		det.Imposto.ICMS.ICMSSN102 = &ICMSSN102{Orig: origem, CSOSN: t.CSTICMS}
+	default:
+		det.Imposto.ICMS.ICMS40 = &ICMS40{Orig: origem, CST: t.CSTICMS}
+	}
+
+	if t.CSTIPI != "" {
+		ipi := &IPI{CEnq: t.CEnqIPI}
+		if t.IPITributado() {
+			ipi.IPITrib = &IPITrib{CST: t.CSTIPI, VBC: valor(t.VBCIPI), PIPI: percentual(t.PIPI), VIPI: valor(t.VIPI)}
+		} else {
+			ipi.IPINT = &TributoNT{CST: t.CSTIPI}
+		}
+		det.Imposto.IPI = ipi
+	}
+
+	switch {
+	case !dominio.PISComAliquota(t.CSTPIS):
+		det.Imposto.PIS.PISNT = &TributoNT{CST: t.CSTPIS}
+	case padraoCSTPISAliq.MatchString(t.CSTPIS):
+		det.Imposto.PIS.PISAliq = &PISAliq{CST: t.CSTPIS, VBC: valor(t.VBCPIS), PPIS: percentual(t.PPIS), VPIS: valor(t.VPIS)}
+	default:
+		det.Imposto.PIS.PISOutr = &PISAliq{CST: t.CSTPIS, VBC: valor(t.VBCPIS), PPIS: percentual(t.PPIS), VPIS: valor(t.VPIS)}
+	}
+	switch {
+	case !dominio.PISComAliquota(t.CSTCOFINS):
+		det.Imposto.COFINS.COFINSNT = &TributoNT{CST: t.CSTCOFINS}
+	case padraoCSTPISAliq.MatchString(t.CSTCOFINS):
+		det.Imposto.COFINS.COFINSAliq = &COFINSAliq{CST: t.CSTCOFINS, VBC: valor(t.VBCCOFINS), PCOFINS: percentual(t.PCOFINS), VCOFINS: valor(t.VCOFINS)}
+	default:
+		det.Imposto.COFINS.COFINSOutr = &COFINSAliq{CST: t.CSTCOFINS, VBC: valor(t.VBCCOFINS), PCOFINS: percentual(t.PCOFINS), VCOFINS: valor(t.VCOFINS)}
+	}
+
+	// Itens fechados sem período de IBS/CBS cadastrado (ou do Simples Nacional) não levam o grupo
+	if t.CSTIBSCBS != "" {
+		ibscbs := &IBSCBS{CST: t.CSTIBSCBS, CClassTrib: t.CClassTrib}
+		if t.IBSCBSTributado() {
+			ibscbs.GIBSCBS = &GIBSCBS{
+				VBC:     valor(t.VBCIBSCBS),
+				GIBSUF:  GIBSUF{PIBSUF: percentual(t.PIBSUF), VIBSUF: valor(t.VIBSUF)},
+				GIBSMun: GIBSMun{PIBSMun: percentual(t.PIBSMun), VIBSMun: valor(t.VIBSMun)},
+				VIBS:    valor(t.VIBSUF.Add(t.VIBSMun)),
+				GCBS:    GCBS{PCBS: percentual(t.PCBS), VCBS: valor(t.VCBS)},
+			}
+		}
+		det.Imposto.IBSCBS = ibscbs
+	}
+
+	return det
+}
+
+func comIBSCBS(itens []Det) bool {
+	for _, det := range itens {
+		if det.Imposto.IBSCBS != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func montarIBSCBSTot(totais dominio.TotaisNota) *IBSCBSTot {
+	return &IBSCBSTot{
+		VBCIBSCBS: valor(totais.VBCIBSCBS),
+		GIBS: GIBSTot{
+			GIBSUF:           GIBSUFTot{VDif: zero, VDevTrib: zero, VIBSUF: valor(totais.VIBSUF)},
+			GIBSMun:          GIBSMunTot{VDif: zero, VDevTrib: zero, VIBSMun: valor(totais.VIBSMun)},
+			VIBS:             valor(totais.VIBS),
+			VCredPres:        zero,
+			VCredPresCondSus: zero,
+		},
+		GCBS: GCBSTot{VDif: zero, VDevTrib: zero, VCBS: valor(totais.VCBS), VCredPres: zero, VCredPresCondSus: zero},
+	}
+}
+
+// modBCValorOperacao é a modalidade de base de cálculo do ICMS pelo valor da operação
+const modBCValorOperacao = "3"
+
+// valor formata valores monetários do leiaute (TDec_1302) com duas casas
+func valor(v dominio.Decimal) string {
+	return v.Formatar(2)
+}
+
+// percentual formata alíquotas do leiaute (TDec_0302a04) com ao menos duas casas
+func percentual(v dominio.Decimal) string {
+	return v.FormatarMinimo(2)
+}
+
+func somenteDigitos(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
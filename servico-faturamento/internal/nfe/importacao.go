@@ -0,0 +1,82 @@
+package nfe
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// IdentificacaoNFe traz os campos do infNFe usados para conferir uma nota importada
+type IdentificacaoNFe struct {
+	Modelo       string
+	Serie        string
+	Numero       string
+	CNPJEmitente string
+}
+
+type infNFeImportada struct {
+	Ide struct {
+		Mod   string `xml:"mod"`
+		Serie string `xml:"serie"`
+		NNF   string `xml:"nNF"`
+	} `xml:"ide"`
+	Emit struct {
+		CNPJ string `xml:"CNPJ"`
+	} `xml:"emit"`
+}
+
+// IdentificarNFe lê modelo, série, número e emitente de um XML NFe ou nfeProc
+func IdentificarNFe(documento []byte) (IdentificacaoNFe, error) {
+	var doc struct {
+		XMLName xml.Name
+		InfNFe  infNFeImportada `xml:"infNFe"`
+		NFe     struct {
+			InfNFe infNFeImportada `xml:"infNFe"`
+		} `xml:"NFe"`
+	}
+	if err := xml.Unmarshal(documento, &doc); err != nil {
+		return IdentificacaoNFe{}, fmt.Errorf("XML da NF-e invalido: %w", err)
+	}
+
+	inf := doc.InfNFe
+	switch doc.XMLName.Local {
+	case "nfeProc":
+		inf = doc.NFe.InfNFe
+	case "NFe":
+	default:
+		return IdentificacaoNFe{}, fmt.Errorf("XML da NF-e invalido: raiz %s, esperava NFe ou nfeProc", doc.XMLName.Local)
+	}
+	return IdentificacaoNFe{
+		Modelo:       inf.Ide.Mod,
+		Serie:        inf.Ide.Serie,
+		Numero:       inf.Ide.NNF,
+		CNPJEmitente: inf.Emit.CNPJ,
+	}, nil
+}
+
+// LerNFe lê de volta o documento de um XML NFe ou nfeProc já emitido, para
+// reimprimir o DANFE com o conteúdo que foi à SEFAZ
+func LerNFe(documento []byte) (*NFe, error) {
+	var proc struct {
+		XMLName xml.Name
+		NFe     *NFe `xml:"NFe"`
+	}
+	if err := xml.Unmarshal(documento, &proc); err != nil {
+		return nil, fmt.Errorf("XML da NF-e invalido: %w", err)
+	}
+
+	switch proc.XMLName.Local {
+	case "nfeProc":
+		if proc.NFe == nil {
+			return nil, fmt.Errorf("XML da NF-e invalido: nfeProc sem NFe")
+		}
+		return proc.NFe, nil
+	case "NFe":
+		var doc NFe
+		if err := xml.Unmarshal(documento, &doc); err != nil {
+			return nil, fmt.Errorf("XML da NF-e invalido: %w", err)
+		}
+		return &doc, nil
+	default:
+		return nil, fmt.Errorf("XML da NF-e invalido: raiz %s, esperava NFe ou nfeProc", proc.XMLName.Local)
+	}
+}
@@ -0,0 +1,90 @@
+package nfe_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"servico-faturamento/internal/dominio"
+
+	"servico-faturamento/internal/nfe"
+)
+
+func TestIdentificarNFe(t *testing.T) {
+	nfeXML := `<NFe xmlns="http://www.portalfiscal.inf.br/nfe"><infNFe versao="4.00" Id="NFe35250311222333000181550010000015001000015007">` +
+		`<ide><cUF>35</cUF><mod>55</mod><serie>1</serie><nNF>1500</nNF></ide><emit><CNPJ>11222333000181</CNPJ></emit></infNFe></NFe>`
+	esperado := nfe.IdentificacaoNFe{Modelo: "55", Serie: "1", Numero: "1500", CNPJEmitente: "11222333000181"}
+
+	t.Run("deve ler a NF-e avulsa", func(t *testing.T) {
+		ident, err := nfe.IdentificarNFe([]byte(nfeXML))
+		if err != nil || ident != esperado {
+			t.Errorf("esperava %+v, obteve %+v (erro %v)", esperado, ident, err)
+		}
+	})
+
+	t.Run("deve ler a NF-e dentro do nfeProc", func(t *testing.T) {
+		proc := `<nfeProc xmlns="http://www.portalfiscal.inf.br/nfe" versao="4.00">` + nfeXML + `<protNFe versao="4.00"></protNFe></nfeProc>`
+		ident, err := nfe.IdentificarNFe([]byte(proc))
+		if err != nil || ident != esperado {
+			t.Errorf("esperava %+v, obteve %+v (erro %v)", esperado, ident, err)
+		}
+	})
+
+	t.Run("deve rejeitar outro documento", func(t *testing.T) {
+		if _, err := nfe.IdentificarNFe([]byte(`<evento versao="1.00"></evento>`)); err == nil {
+			t.Error("esperava erro para raiz diferente de NFe")
+		}
+	})
+}
+
+func TestLerNFe(t *testing.T) {
+	serializar := func(t *testing.T, doc *nfe.NFe) string {
+		t.Helper()
+		xmlNFe, err := nfe.Serializar(doc)
+		if err != nil {
+			t.Fatalf("erro ao serializar: %v", err)
+		}
+		return string(xmlNFe)
+	}
+
+	t.Run("deve ler de volta a NF-e gerada e a de dentro do nfeProc", func(t *testing.T) {
+		doc, err := nfe.Gerar(notaFechadaTeste(t), configuracaoTeste())
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		xmlNFe := serializar(t, doc)
+		_, corpo, _ := strings.Cut(xmlNFe, "?>")
+		proc := `<nfeProc xmlns="http://www.portalfiscal.inf.br/nfe" versao="4.00">` + corpo + `<protNFe versao="4.00"></protNFe></nfeProc>`
+
+		for _, documento := range []string{xmlNFe, proc} {
+			lido, err := nfe.LerNFe([]byte(documento))
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			lido.XMLName = doc.XMLName
+			if !reflect.DeepEqual(lido, doc) {
+				t.Errorf("documento lido difere do gerado:\n%+v\n%+v", lido.InfNFe, doc.InfNFe)
+			}
+		}
+	})
+
+	t.Run("deve preservar o QR Code da NFC-e", func(t *testing.T) {
+		doc, err := nfe.Gerar(notaNFCeTeste(t, dominio.TipoEmissaoNormal), configuracaoNFCe())
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		lido, err := nfe.LerNFe([]byte(serializar(t, doc)))
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if lido.InfNFeSupl == nil || *lido.InfNFeSupl != *doc.InfNFeSupl {
+			t.Errorf("infNFeSupl inesperado: %+v, esperava %+v", lido.InfNFeSupl, doc.InfNFeSupl)
+		}
+	})
+
+	t.Run("deve rejeitar outro documento", func(t *testing.T) {
+		if _, err := nfe.LerNFe([]byte(`<evento versao="1.00"></evento>`)); err == nil {
+			t.Error("esperava erro para raiz diferente de NFe")
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package nfe
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+
+	"servico-faturamento/internal/dominio"
+)
+
+// InutNFe é o pedido de inutilização de numeração (TInutNFe)
+type InutNFe struct {
+	XMLName xml.Name `xml:"http://www.portalfiscal.inf.br/nfe inutNFe"`
+	Versao  string   `xml:"versao,attr"`
+	InfInut InfInut  `xml:"infInut"`
+}
+
+// InfInut traz a faixa a inutilizar; o Id concatena os campos do pedido
+type InfInut struct {
+	ID     string `xml:"Id,attr"`
+	TpAmb  string `xml:"tpAmb"`
+	XServ  string `xml:"xServ"`
+	CUF    string `xml:"cUF"`
+	Ano    string `xml:"ano"`
+	CNPJ   string `xml:"CNPJ"`
+	Mod    string `xml:"mod"`
+	Serie  string `xml:"serie"`
+	NNFIni string `xml:"nNFIni"`
+	NNFFin string `xml:"nNFFin"`
+	XJust  string `xml:"xJust"`
+}
+
+var (
+	padraoIDInut = regexp.MustCompile(`^ID[0-9]{41}$`)
+	padraoAno    = regexp.MustCompile(`^[0-9]{2}$`)
+)
+
+// GerarInutilizacao monta o pedido de inutilização da faixa; uf é a UF do emitente
+func GerarInutilizacao(inut dominio.Inutilizacao, uf string, cfg Configuracao) (*InutNFe, error) {
+	cUF, ok := dominio.CodigoUF(uf)
+	if !ok {
+		return nil, fmt.Errorf("UF do emitente invalida: %q", uf)
+	}
+
+	doc := &InutNFe{
+		Versao: VersaoLayout,
+		InfInut: InfInut{
+			ID: fmt.Sprintf("ID%s%s%s%s%03d%09d%09d",
+				cUF, inut.Ano, inut.CNPJEmitente, inut.Modelo, inut.Serie, inut.NumeroInicial, inut.NumeroFinal),
+			TpAmb:  cfg.Ambiente,
+			XServ:  "INUTILIZAR",
+			CUF:    cUF,
+			Ano:    inut.Ano,
+			CNPJ:   inut.CNPJEmitente,
+			Mod:    inut.Modelo,
+			Serie:  fmt.Sprint(inut.Serie),
+			NNFIni: fmt.Sprint(inut.NumeroInicial),
+			NNFFin: fmt.Sprint(inut.NumeroFinal),
+			XJust:  inut.Justificativa,
+		},
+	}
+
+	if err := ValidarInutilizacao(doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ValidarInutilizacao confere o pedido contra o schema inutNFe_v4.00.xsd
+func ValidarInutilizacao(doc *InutNFe) error {
+	v := &validador{}
+	inf := doc.InfInut
+
+	v.igual("inutNFe/versao", doc.Versao, VersaoLayout)
+	v.padrao("infInut/Id", inf.ID, padraoIDInut)
+	v.enum("infInut/tpAmb", inf.TpAmb, "12")
+	v.igual("infInut/xServ", inf.XServ, "INUTILIZAR")
+	v.padrao("infInut/cUF", inf.CUF, padraoCUF)
+	v.padrao("infInut/ano", inf.Ano, padraoAno)
+	v.padrao("infInut/CNPJ", inf.CNPJ, padraoCNPJ)
+	v.padrao("infInut/mod", inf.Mod, padraoMod)
+	v.padrao("infInut/serie", inf.Serie, padraoSerie)
+	v.padrao("infInut/nNFIni", inf.NNFIni, padraoNNF)
+	v.padrao("infInut/nNFFin", inf.NNFFin, padraoNNF)
+	v.texto("infInut/xJust", inf.XJust, dominio.JustificativaMinima, dominio.JustificativaMaxima)
+
+	if len(v.erros) > 0 {
+		return v.erros
+	}
+	return nil
+}
+
+// SerializarInutilizacao gera o XML do pedido sem indentação
+func SerializarInutilizacao(doc *InutNFe) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header[:len(xml.Header)-1])
+	if err := xml.NewEncoder(&buf).Encode(doc); err != nil {
+		return nil, fmt.Errorf("falha ao serializar inutilizacao: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,72 @@
+package nfe_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+)
+
+func inutilizacaoTeste() dominio.Inutilizacao {
+	return dominio.Inutilizacao{
+		CNPJEmitente:  "11222333000181",
+		Modelo:        "55",
+		Serie:         1,
+		NumeroInicial: 10,
+		NumeroFinal:   12,
+		Ano:           "25",
+		Justificativa: "Falha na emissao do sistema legado",
+	}
+}
+
+func TestGerarInutilizacao(t *testing.T) {
+	t.Run("deve gerar pedido inutNFe valido", func(t *testing.T) {
+		doc, err := nfe.GerarInutilizacao(inutilizacaoTeste(), "SP", configuracaoTeste())
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		esperado := "ID" + "35" + "25" + "11222333000181" + "55" + "001" + "000000010" + "000000012"
+		if doc.InfInut.ID != esperado || len(doc.InfInut.ID) != 43 {
+			t.Errorf("Id inesperado: %s", doc.InfInut.ID)
+		}
+
+		xmlPedido, err := nfe.SerializarInutilizacao(doc)
+		if err != nil {
+			t.Fatalf("falha ao serializar: %v", err)
+		}
+		for _, trecho := range []string{
+			`<inutNFe xmlns="http://www.portalfiscal.inf.br/nfe" versao="4.00">`,
+			`<tpAmb>2</tpAmb><xServ>INUTILIZAR</xServ><cUF>35</cUF><ano>25</ano><CNPJ>11222333000181</CNPJ><mod>55</mod><serie>1</serie><nNFIni>10</nNFIni><nNFFin>12</nNFFin>`,
+			`<xJust>Falha na emissao do sistema legado</xJust>`,
+		} {
+			if !strings.Contains(string(xmlPedido), trecho) {
+				t.Errorf("XML sem o trecho %q:\n%s", trecho, xmlPedido)
+			}
+		}
+	})
+
+	t.Run("deve rejeitar UF desconhecida", func(t *testing.T) {
+		if _, err := nfe.GerarInutilizacao(inutilizacaoTeste(), "XX", configuracaoTeste()); err == nil {
+			t.Error("esperava erro para UF invalida")
+		}
+	})
+
+	t.Run("deve apontar campos fora do leiaute", func(t *testing.T) {
+		inut := inutilizacaoTeste()
+		inut.Ano = "2025"
+		inut.Justificativa = "curta"
+
+		_, err := nfe.GerarInutilizacao(inut, "SP", configuracaoTeste())
+		var erros nfe.ErrosValidacao
+		if !errors.As(err, &erros) {
+			t.Fatalf("esperava ErrosValidacao, obteve: %v", err)
+		}
+		// Id com ano de 4 dígitos também sai do padrão
+		if len(erros) != 3 {
+			t.Errorf("esperava 3 erros (Id, ano, xJust), obteve %d: %v", len(erros), erros)
+		}
+	})
+}
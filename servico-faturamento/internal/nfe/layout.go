@@ -0,0 +1,377 @@
+package nfe
+
+import "encoding/xml"
+
+// Constantes do leiaute NF-e 4.00 (MOC 7.0)
+const (
+	Namespace    = "http://www.portalfiscal.inf.br/nfe"
+	VersaoLayout = "4.00"
+	ModeloNFe    = "55"
+	ModeloNFCe   = "65"
+)
+
+// NFe é o elemento raiz do documento fiscal (TNFe)
+type NFe struct {
+	XMLName xml.Name `xml:"http://www.portalfiscal.inf.br/nfe NFe"`
+	InfNFe  InfNFe   `xml:"infNFe"`
+	// InfNFeSupl leva o QR Code da NFC-e; fica fora da assinatura, que cobre só o infNFe
+	InfNFeSupl *InfNFeSupl `xml:"infNFeSupl,omitempty"`
+}
+
+// InfNFeSupl contém as informações suplementares da NFC-e (grupo ZX01)
+type InfNFeSupl struct {
+	QRCode   CDATA  `xml:"qrCode"`
+	URLChave string `xml:"urlChave"`
+}
+
+// CDATA serializa o texto em uma seção CDATA, como a SEFAZ publica o qrCode
+type CDATA struct {
+	Texto string `xml:",cdata"`
+}
+
+// InfNFe agrupa as informações da nota (grupo A)
+type InfNFe struct {
+	Versao  string   `xml:"versao,attr"`
+	ID      string   `xml:"Id,attr"`
+	Ide     Ide      `xml:"ide"`
+	Emit    Emit     `xml:"emit"`
+	Dest    *Dest    `xml:"dest,omitempty"`
+	Det     []Det    `xml:"det"`
+	Total   Total    `xml:"total"`
+	Transp  Transp   `xml:"transp"`
+	Pag     Pag      `xml:"pag"`
+	InfAdic *InfAdic `xml:"infAdic,omitempty"`
+}
+
+// Ide identifica a NF-e (grupo B)
+type Ide struct {
+	CUF      string `xml:"cUF"`
+	CNF      string `xml:"cNF"`
+	NatOp    string `xml:"natOp"`
+	Mod      string `xml:"mod"`
+	Serie    string `xml:"serie"`
+	NNF      string `xml:"nNF"`
+	DhEmi    string `xml:"dhEmi"`
+	TpNF     string `xml:"tpNF"`
+	IdDest   string `xml:"idDest"`
+	CMunFG   string `xml:"cMunFG"`
+	TpImp    string `xml:"tpImp"`
+	TpEmis   string `xml:"tpEmis"`
+	CDV      string `xml:"cDV"`
+	TpAmb    string `xml:"tpAmb"`
+	FinNFe   string `xml:"finNFe"`
+	IndFinal string `xml:"indFinal"`
+	IndPres  string `xml:"indPres"`
+	ProcEmi  string `xml:"procEmi"`
+	VerProc  string `xml:"verProc"`
+	// Entrada em contingência, obrigatórios quando tpEmis não é 1
+	DhCont string `xml:"dhCont,omitempty"`
+	XJust  string `xml:"xJust,omitempty"`
+}
+
+// Endereco corresponde ao TEndereco/TEnderEmi
+type Endereco struct {
+	XLgr    string `xml:"xLgr"`
+	Nro     string `xml:"nro"`
+	XCpl    string `xml:"xCpl,omitempty"`
+	XBairro string `xml:"xBairro"`
+	CMun    string `xml:"cMun"`
+	XMun    string `xml:"xMun"`
+	UF      string `xml:"UF"`
+	CEP     string `xml:"CEP,omitempty"`
+	CPais   string `xml:"cPais,omitempty"`
+	XPais   string `xml:"xPais,omitempty"`
+	Fone    string `xml:"fone,omitempty"`
+}
+
+// Emit identifica o emitente (grupo C)
+type Emit struct {
+	CNPJ      string   `xml:"CNPJ"`
+	XNome     string   `xml:"xNome"`
+	XFant     string   `xml:"xFant,omitempty"`
+	EnderEmit Endereco `xml:"enderEmit"`
+	IE        string   `xml:"IE"`
+	CRT       string   `xml:"CRT"`
+}
+
+// Dest identifica o destinatário (grupo E)
+type Dest struct {
+	CNPJ      string    `xml:"CNPJ,omitempty"`
+	CPF       string    `xml:"CPF,omitempty"`
+	XNome     string    `xml:"xNome,omitempty"`
+	EnderDest *Endereco `xml:"enderDest,omitempty"`
+	IndIEDest string    `xml:"indIEDest"`
+	IE        string    `xml:"IE,omitempty"`
+	Email     string    `xml:"email,omitempty"`
+}
+
+// Det detalha um item da nota (grupo H)
+type Det struct {
+	NItem     int     `xml:"nItem,attr"`
+	Prod      Prod    `xml:"prod"`
+	Imposto   Imposto `xml:"imposto"`
+	InfAdProd string  `xml:"infAdProd,omitempty"`
+}
+
+// Prod contém os dados do produto (grupo I)
+type Prod struct {
+	CProd    string `xml:"cProd"`
+	CEAN     string `xml:"cEAN"`
+	XProd    string `xml:"xProd"`
+	NCM      string `xml:"NCM"`
+	CFOP     string `xml:"CFOP"`
+	UCom     string `xml:"uCom"`
+	QCom     string `xml:"qCom"`
+	VUnCom   string `xml:"vUnCom"`
+	VProd    string `xml:"vProd"`
+	CEANTrib string `xml:"cEANTrib"`
+	UTrib    string `xml:"uTrib"`
+	QTrib    string `xml:"qTrib"`
+	VUnTrib  string `xml:"vUnTrib"`
+	IndTot   string `xml:"indTot"`
+}
+
+// Imposto agrupa os tributos do item (grupo M)
+type Imposto struct {
+	ICMS   ICMS    `xml:"ICMS"`
+	IPI    *IPI    `xml:"IPI,omitempty"`
+	PIS    PIS     `xml:"PIS"`
+	COFINS COFINS  `xml:"COFINS"`
+	IBSCBS *IBSCBS `xml:"IBSCBS,omitempty"`
+}
+
+// ICMS é uma escolha entre os grupos de tributação do ICMS (grupo N)
+type ICMS struct {
+	ICMS00    *ICMS00    `xml:"ICMS00,omitempty"`
+	ICMS20    *ICMS20    `xml:"ICMS20,omitempty"`
+	ICMS40    *ICMS40    `xml:"ICMS40,omitempty"`
+	ICMSSN101 *ICMSSN101 `xml:"ICMSSN101,omitempty"`
+	ICMSSN102 *ICMSSN102 `xml:"ICMSSN102,omitempty"`
+}
+
+// ICMS00 cobre o CST 00 (tributada integralmente)
+type ICMS00 struct {
+	Orig  string `xml:"orig"`
+	CST   string `xml:"CST"`
+	ModBC string `xml:"modBC"`
+	VBC   string `xml:"vBC"`
+	PICMS string `xml:"pICMS"`
+	VICMS string `xml:"vICMS"`
+}
+
+// ICMS20 cobre o CST 20 (com redução de base de cálculo)
+type ICMS20 struct {
+	Orig   string `xml:"orig"`
+	CST    string `xml:"CST"`
+	ModBC  string `xml:"modBC"`
+	PRedBC string `xml:"pRedBC"`
+	VBC    string `xml:"vBC"`
+	PICMS  string `xml:"pICMS"`
+	VICMS  string `xml:"vICMS"`
+}
+
+// ICMS40 cobre os CST 40 (isenta), 41 (não tributada) e 50 (suspensão)
+type ICMS40 struct {
+	Orig string `xml:"orig"`
+	CST  string `xml:"CST"`
+}
+
+// ICMSSN101 cobre o CSOSN 101, com permissão de crédito
+type ICMSSN101 struct {
+	Orig        string `xml:"orig"`
+	CSOSN       string `xml:"CSOSN"`
+	PCredSN     string `xml:"pCredSN"`
+	VCredICMSSN string `xml:"vCredICMSSN"`
+}
+
+// ICMSSN102 cobre os CSOSN 102, 103, 300 e 400 do Simples Nacional
+type ICMSSN102 struct {
+	Orig  string `xml:"orig"`
+	CSOSN string `xml:"CSOSN"`
+}
+
+// IPI contém o enquadramento e a tributação do IPI (grupo O)
+type IPI struct {
+	CEnq    string     `xml:"cEnq"`
+	IPITrib *IPITrib   `xml:"IPITrib,omitempty"`
+	IPINT   *TributoNT `xml:"IPINT,omitempty"`
+}
+
+// IPITrib cobre os CST 00, 49, 50 e 99, calculados por alíquota
+type IPITrib struct {
+	CST  string `xml:"CST"`
+	VBC  string `xml:"vBC"`
+	PIPI string `xml:"pIPI"`
+	VIPI string `xml:"vIPI"`
+}
+
+// PIS é uma escolha entre os grupos de tributação do PIS (grupo Q)
+type PIS struct {
+	PISAliq *PISAliq   `xml:"PISAliq,omitempty"`
+	PISNT   *TributoNT `xml:"PISNT,omitempty"`
+	PISOutr *PISAliq   `xml:"PISOutr,omitempty"`
+}
+
+// PISAliq cobre o PIS calculado por alíquota: CST 01 e 02 no grupo PISAliq e
+// as demais operações no grupo PISOutr, que tem os mesmos campos
+type PISAliq struct {
+	CST  string `xml:"CST"`
+	VBC  string `xml:"vBC"`
+	PPIS string `xml:"pPIS"`
+	VPIS string `xml:"vPIS"`
+}
+
+// COFINS é uma escolha entre os grupos de tributação da COFINS (grupo S)
+type COFINS struct {
+	COFINSAliq *COFINSAliq `xml:"COFINSAliq,omitempty"`
+	COFINSNT   *TributoNT  `xml:"COFINSNT,omitempty"`
+	COFINSOutr *COFINSAliq `xml:"COFINSOutr,omitempty"`
+}
+
+// COFINSAliq cobre a COFINS calculada por alíquota, nos grupos COFINSAliq e COFINSOutr
+type COFINSAliq struct {
+	CST     string `xml:"CST"`
+	VBC     string `xml:"vBC"`
+	PCOFINS string `xml:"pCOFINS"`
+	VCOFINS string `xml:"vCOFINS"`
+}
+
+// TributoNT representa IPI, PIS e COFINS não tributados, informados só pelo CST
+type TributoNT struct {
+	CST string `xml:"CST"`
+}
+
+// IBSCBS contém a classificação e os valores de IBS e CBS do item (grupo UB,
+// NT 2025.002 da Reforma Tributária)
+type IBSCBS struct {
+	CST        string   `xml:"CST"`
+	CClassTrib string   `xml:"cClassTrib"`
+	GIBSCBS    *GIBSCBS `xml:"gIBSCBS,omitempty"`
+}
+
+// GIBSCBS traz a base comum e os valores de IBS estadual, municipal e CBS
+type GIBSCBS struct {
+	VBC     string  `xml:"vBC"`
+	GIBSUF  GIBSUF  `xml:"gIBSUF"`
+	GIBSMun GIBSMun `xml:"gIBSMun"`
+	VIBS    string  `xml:"vIBS"`
+	GCBS    GCBS    `xml:"gCBS"`
+}
+
+// GIBSUF é o IBS de competência da UF
+type GIBSUF struct {
+	PIBSUF string `xml:"pIBSUF"`
+	VIBSUF string `xml:"vIBSUF"`
+}
+
+// GIBSMun é o IBS de competência do município
+type GIBSMun struct {
+	PIBSMun string `xml:"pIBSMun"`
+	VIBSMun string `xml:"vIBSMun"`
+}
+
+// GCBS é a CBS, de competência da União
+type GCBS struct {
+	PCBS string `xml:"pCBS"`
+	VCBS string `xml:"vCBS"`
+}
+
+// Total agrupa os totais da nota (grupo W)
+type Total struct {
+	ICMSTot   ICMSTot    `xml:"ICMSTot"`
+	IBSCBSTot *IBSCBSTot `xml:"IBSCBSTot,omitempty"`
+}
+
+// IBSCBSTot são os totais de IBS e CBS (grupo W03)
+type IBSCBSTot struct {
+	VBCIBSCBS string  `xml:"vBCIBSCBS"`
+	GIBS      GIBSTot `xml:"gIBS"`
+	GCBS      GCBSTot `xml:"gCBS"`
+}
+
+// GIBSTot totaliza o IBS; diferimento, devolução e créditos presumidos não são usados
+type GIBSTot struct {
+	GIBSUF           GIBSUFTot  `xml:"gIBSUF"`
+	GIBSMun          GIBSMunTot `xml:"gIBSMun"`
+	VIBS             string     `xml:"vIBS"`
+	VCredPres        string     `xml:"vCredPres"`
+	VCredPresCondSus string     `xml:"vCredPresCondSus"`
+}
+
+// GIBSUFTot totaliza o IBS estadual
+type GIBSUFTot struct {
+	VDif     string `xml:"vDif"`
+	VDevTrib string `xml:"vDevTrib"`
+	VIBSUF   string `xml:"vIBSUF"`
+}
+
+// GIBSMunTot totaliza o IBS municipal
+type GIBSMunTot struct {
+	VDif     string `xml:"vDif"`
+	VDevTrib string `xml:"vDevTrib"`
+	VIBSMun  string `xml:"vIBSMun"`
+}
+
+// GCBSTot totaliza a CBS
+type GCBSTot struct {
+	VDif             string `xml:"vDif"`
+	VDevTrib         string `xml:"vDevTrib"`
+	VCBS             string `xml:"vCBS"`
+	VCredPres        string `xml:"vCredPres"`
+	VCredPresCondSus string `xml:"vCredPresCondSus"`
+}
+
+// ICMSTot são os totais referentes ao ICMS e à nota
+type ICMSTot struct {
+	VBC        string `xml:"vBC"`
+	VICMS      string `xml:"vICMS"`
+	VICMSDeson string `xml:"vICMSDeson"`
+	VFCP       string `xml:"vFCP"`
+	VBCST      string `xml:"vBCST"`
+	VST        string `xml:"vST"`
+	VFCPST     string `xml:"vFCPST"`
+	VFCPSTRet  string `xml:"vFCPSTRet"`
+	VProd      string `xml:"vProd"`
+	VFrete     string `xml:"vFrete"`
+	VSeg       string `xml:"vSeg"`
+	VDesc      string `xml:"vDesc"`
+	VII        string `xml:"vII"`
+	VIPI       string `xml:"vIPI"`
+	VIPIDevol  string `xml:"vIPIDevol"`
+	VPIS       string `xml:"vPIS"`
+	VCOFINS    string `xml:"vCOFINS"`
+	VOutro     string `xml:"vOutro"`
+	VNF        string `xml:"vNF"`
+}
+
+// Transp contém as informações de transporte (grupo X)
+type Transp struct {
+	ModFrete string `xml:"modFrete"`
+}
+
+// Pag contém as formas de pagamento (grupo YA)
+type Pag struct {
+	DetPag []DetPag `xml:"detPag"`
+	VTroco string   `xml:"vTroco,omitempty"`
+}
+
+// DetPag detalha uma forma de pagamento
+type DetPag struct {
+	TPag string `xml:"tPag"`
+	XPag string `xml:"xPag,omitempty"`
+	VPag string `xml:"vPag"`
+	Card *Card  `xml:"card,omitempty"`
+}
+
+// Card identifica a operação com cartão ou PIX (grupo YA04)
+type Card struct {
+	TpIntegra string `xml:"tpIntegra"`
+	TBand     string `xml:"tBand,omitempty"`
+	CAut      string `xml:"cAut,omitempty"`
+}
+
+// InfAdic contém informações adicionais (grupo Z)
+type InfAdic struct {
+	InfAdFisco string `xml:"infAdFisco,omitempty"`
+	InfCpl     string `xml:"infCpl,omitempty"`
+}
@@ -0,0 +1,171 @@
+package nfe
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/dominio"
+)
+
+// versaoQRCode é a versão 2 do QR Code da NFC-e (NT 2015.002), com hash SHA-1 do CSC
+const versaoQRCode = "2"
+
+var (
+	// ErrCSCNaoConfigurado indica NFC-e sem o CSC e o seu identificador (NFCE_CSC_ID e NFCE_CSC)
+	ErrCSCNaoConfigurado = errors.New("CSC da NFC-e nao configurado")
+	// ErrUFSemNFCe indica UF sem URLs de consulta conhecidas e sem NFCE_URL_QRCODE/NFCE_URL_CONSULTA
+	ErrUFSemNFCe = errors.New("URLs da NFC-e nao configuradas para a UF")
+)
+
+// enderecosNFCe são as URLs do QR Code e da consulta por chave publicadas pela UF
+type enderecosNFCe struct {
+	qrCode, consulta string
+}
+
+// urlsNFCe traz as URLs por UF e ambiente (1 = produção, 2 = homologação); as
+// demais UFs usam NFCE_URL_QRCODE e NFCE_URL_CONSULTA
+var urlsNFCe = map[string]map[string]enderecosNFCe{
+	"SP": {
+		"1": {"https://www.nfce.fazenda.sp.gov.br/NFCeConsultaPublica/Paginas/ConsultaQRCode.aspx", "https://www.nfce.fazenda.sp.gov.br/consulta"},
+		"2": {"https://www.homologacao.nfce.fazenda.sp.gov.br/NFCeConsultaPublica/Paginas/ConsultaQRCode.aspx", "https://www.homologacao.nfce.fazenda.sp.gov.br/consulta"},
+	},
+	"RS": {
+		"1": {"https://www.sefaz.rs.gov.br/NFCE/NFCE-COM.aspx", "www.sefaz.rs.gov.br/nfce/consulta"},
+		"2": {"https://www.sefaz.rs.gov.br/NFCE/NFCE-COM.aspx", "www.sefaz.rs.gov.br/nfce/consulta"},
+	},
+	"PR": {
+		"1": {"http://www.fazenda.pr.gov.br/nfce/qrcode", "http://www.fazenda.pr.gov.br/nfce/consulta"},
+		"2": {"http://www.fazenda.pr.gov.br/nfce/qrcode", "http://www.fazenda.pr.gov.br/nfce/consulta"},
+	},
+	"MG": {
+		"1": {"https://portalsped.fazenda.mg.gov.br/portalnfce/sistema/qrcode.xhtml", "https://portalsped.fazenda.mg.gov.br/portalnfce"},
+		"2": {"https://hportalsped.fazenda.mg.gov.br/portalnfce/sistema/qrcode.xhtml", "https://hportalsped.fazenda.mg.gov.br/portalnfce"},
+	},
+	"RJ": {
+		"1": {"https://consultadfe.fazenda.rj.gov.br/consultaNFCe/QRCode", "www.fazenda.rj.gov.br/nfce/consulta"},
+		"2": {"https://consultadfe.fazenda.rj.gov.br/consultaNFCe/QRCode", "www.fazenda.rj.gov.br/nfce/consulta"},
+	},
+	"SC": {
+		"1": {"https://sat.sef.sc.gov.br/nfce/consulta", "https://sat.sef.sc.gov.br/nfce/consulta"},
+		"2": {"https://hom.sat.sef.sc.gov.br/nfce/consulta", "https://hom.sat.sef.sc.gov.br/nfce/consulta"},
+	},
+	"BA": {
+		"1": {"http://nfe.sefaz.ba.gov.br/servicos/nfce/qrcode.aspx", "www.sefaz.ba.gov.br/nfce/consulta"},
+		"2": {"http://hnfe.sefaz.ba.gov.br/servicos/nfce/qrcode.aspx", "http://hinternet.sefaz.ba.gov.br/nfce/consulta"},
+	},
+	"DF": {
+		"1": {"http://www.fazenda.df.gov.br/nfce/qrcode", "www.fazenda.df.gov.br/nfce/consulta"},
+		"2": {"http://www.fazenda.df.gov.br/nfce/qrcode", "www.fazenda.df.gov.br/nfce/consulta"},
+	},
+	"GO": {
+		"1": {"https://nfeweb.sefaz.go.gov.br/nfeweb/sites/nfce/danfeNFCe", "www.sefaz.go.gov.br/nfce/consulta"},
+		"2": {"https://nfewebhomolog.sefaz.go.gov.br/nfeweb/sites/nfce/danfeNFCe", "www.sefaz.go.gov.br/nfce/consulta"},
+	},
+	"ES": {
+		"1": {"http://app.sefaz.es.gov.br/ConsultaNFCe/qrcode.aspx", "www.sefaz.es.gov.br/nfce/consulta"},
+		"2": {"http://homologacao.sefaz.es.gov.br/ConsultaNFCe/qrcode.aspx", "www.sefaz.es.gov.br/nfce/consulta"},
+	},
+	"PE": {
+		"1": {"http://nfce.sefaz.pe.gov.br/nfce/consulta", "nfce.sefaz.pe.gov.br/nfce/consulta"},
+		"2": {"http://nfcehomolog.sefaz.pe.gov.br/nfce/consulta", "nfce.sefaz.pe.gov.br/nfce/consulta"},
+	},
+}
+
+// URLsNFCe devolve a URL do QR Code e a da consulta por chave para a UF do
+// emitente; as variáveis de ambiente substituem a tabela
+func URLsNFCe(uf string, cfg Configuracao) (qrCode, consulta string, err error) {
+	e := urlsNFCe[uf][cfg.Ambiente]
+	if cfg.URLQRCode != "" {
+		e.qrCode = cfg.URLQRCode
+	}
+	if cfg.URLConsulta != "" {
+		e.consulta = cfg.URLConsulta
+	}
+	if e.qrCode == "" || e.consulta == "" {
+		return "", "", fmt.Errorf("%w: %q", ErrUFSemNFCe, uf)
+	}
+	return e.qrCode, e.consulta, nil
+}
+
+// MontarInfNFeSupl gera o QR Code da NFC-e já montada. Na emissão normal o QR
+// Code leva só a chave; na offline (tpEmis 9) leva também o dia da emissão, o
+// vNF e o DigestValue da assinatura, calculado aqui sobre o infNFe serializado.
+func MontarInfNFeSupl(doc *NFe, cfg Configuracao) (*InfNFeSupl, error) {
+	idCSC := strings.TrimLeft(cfg.IDTokenCSC, "0")
+	if idCSC == "" || cfg.CSC == "" {
+		return nil, ErrCSCNaoConfigurado
+	}
+	urlQR, urlConsulta, err := URLsNFCe(doc.InfNFe.Emit.EnderEmit.UF, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ide := doc.InfNFe.Ide
+	chave := strings.TrimPrefix(doc.InfNFe.ID, "NFe")
+	campos := []string{chave, versaoQRCode, ide.TpAmb}
+	if ide.TpEmis == dominio.TipoEmissaoOffline {
+		semSupl := *doc
+		semSupl.InfNFeSupl = nil
+		xmlNFe, err := Serializar(&semSupl)
+		if err != nil {
+			return nil, err
+		}
+		digVal, err := assinatura.DigestValue(xmlNFe, "infNFe")
+		if err != nil {
+			return nil, err
+		}
+		campos = append(campos, ide.DhEmi[8:10], doc.InfNFe.Total.ICMSTot.VNF, strings.ToUpper(hex.EncodeToString([]byte(digVal))))
+	}
+	campos = append(campos, idCSC)
+
+	parametros := strings.Join(campos, "|")
+	hash := sha1.Sum([]byte(parametros + cfg.CSC))
+	return &InfNFeSupl{
+		QRCode:   CDATA{Texto: urlQR + "?p=" + parametros + "|" + strings.ToUpper(hex.EncodeToString(hash[:]))},
+		URLChave: urlConsulta,
+	}, nil
+}
+
+// MontarDestNFCe identifica o consumidor da NFC-e: só o CPF ou CNPJ e o nome,
+// sempre como não contribuinte (indIEDest 9) e sem inscrição estadual
+func MontarDestNFCe(c dominio.Cliente, ambiente string) *Dest {
+	dest := &Dest{XNome: c.Nome, IndIEDest: dominio.IndicadorIENaoContribuinte}
+	if c.PessoaJuridica() {
+		dest.CNPJ = c.Documento
+	} else {
+		dest.CPF = c.Documento
+	}
+	if ambiente == "2" {
+		dest.XNome = xNomeHomologacao
+	}
+	return dest
+}
+
+// montarPag converte os pagamentos da nota no grupo pag. Sem pagamentos a NF-e
+// sai com "99 - Outros" pelo valor total; a NFC-e é barrada antes, no fechamento.
+func montarPag(pagamentos []dominio.PagamentoNota, vNF, troco dominio.Decimal) Pag {
+	if len(pagamentos) == 0 {
+		return Pag{DetPag: []DetPag{{TPag: dominio.FormaOutros, XPag: "Outros", VPag: valor(vNF)}}}
+	}
+
+	var pag Pag
+	for _, p := range pagamentos {
+		det := DetPag{TPag: p.Forma, VPag: valor(p.Valor)}
+		if p.Forma == dominio.FormaOutros {
+			det.XPag = p.Descricao
+		}
+		if p.Cartao() {
+			// tpIntegra 2: pagamento não integrado ao sistema de automação
+			det.Card = &Card{TpIntegra: "2", TBand: p.Bandeira, CAut: p.Autorizacao}
+		}
+		pag.DetPag = append(pag.DetPag, det)
+	}
+	if troco.Sinal() > 0 {
+		pag.VTroco = valor(troco)
+	}
+	return pag
+}
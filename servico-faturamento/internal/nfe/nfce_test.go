@@ -0,0 +1,188 @@
+package nfe_test
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+)
+
+func configuracaoNFCe() nfe.Configuracao {
+	cfg := configuracaoTeste()
+	cfg.SerieNFCe = "2"
+	cfg.IDTokenCSC = "000001"
+	cfg.CSC = "0123456789ABCDEF0123456789ABCDEF"
+	return cfg
+}
+
+func notaNFCeTeste(t *testing.T, tpEmis string) dominio.NotaFiscal {
+	t.Helper()
+	nota := notaFechadaTeste(t)
+	nota.Modelo = nfe.ModeloNFCe
+	nota.Serie = 2
+	nota.TipoEmissao = tpEmis
+	if tpEmis == dominio.TipoEmissaoOffline {
+		inicio := nota.DataFechada.Add(-time.Hour)
+		justificativa := "SEFAZ autorizadora fora do ar"
+		nota.DataContingencia, nota.JustificativaContingencia = &inicio, &justificativa
+	}
+	nota.Pagamentos = []dominio.PagamentoNota{
+		{Forma: dominio.FormaCartaoCredito, Valor: dominio.MustParseDecimal("15.00"), Bandeira: "01", Autorizacao: "123456"},
+		{Forma: dominio.FormaDinheiro, Valor: dominio.MustParseDecimal("10.00")},
+	}
+	if err := nfe.AtribuirChave(&nota, configuracaoNFCe()); err != nil {
+		t.Fatalf("falha ao atribuir chave: %v", err)
+	}
+	return nota
+}
+
+func TestGerarNFCe(t *testing.T) {
+	t.Run("deve gerar NFC-e com QR Code online e grupo pag com troco", func(t *testing.T) {
+		nota := notaNFCeTeste(t, dominio.TipoEmissaoNormal)
+		doc, err := nfe.Gerar(nota, configuracaoNFCe())
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+
+		ide := doc.InfNFe.Ide
+		if ide.Mod != "65" || ide.TpImp != "4" || ide.IndFinal != "1" || ide.IndPres != "1" || ide.IdDest != "1" {
+			t.Errorf("ide inesperada para NFC-e: %+v", ide)
+		}
+		pag := doc.InfNFe.Pag
+		if len(pag.DetPag) != 2 || pag.VTroco != "4.00" {
+			t.Fatalf("esperava 2 pagamentos e troco 4.00, obteve %+v", pag)
+		}
+		if card := pag.DetPag[0].Card; card == nil || card.TpIntegra != "2" || card.TBand != "01" || card.CAut != "123456" {
+			t.Errorf("esperava grupo card no cartao de credito, obteve %+v", card)
+		}
+		if pag.DetPag[1].Card != nil {
+			t.Error("dinheiro nao deve ter grupo card")
+		}
+
+		parametros := *nota.ChaveAcesso + "|2|2|1"
+		hash := sha1.Sum([]byte(parametros + configuracaoNFCe().CSC))
+		esperado := "https://www.homologacao.nfce.fazenda.sp.gov.br/NFCeConsultaPublica/Paginas/ConsultaQRCode.aspx?p=" +
+			parametros + "|" + strings.ToUpper(hex.EncodeToString(hash[:]))
+		if doc.InfNFeSupl == nil || doc.InfNFeSupl.QRCode.Texto != esperado {
+			t.Fatalf("QR Code inesperado:\n%+v\nesperava\n%s", doc.InfNFeSupl, esperado)
+		}
+		if doc.InfNFeSupl.URLChave != "https://www.homologacao.nfce.fazenda.sp.gov.br/consulta" {
+			t.Errorf("urlChave inesperada: %s", doc.InfNFeSupl.URLChave)
+		}
+
+		xmlNFe, err := nfe.Serializar(doc)
+		if err != nil {
+			t.Fatalf("erro ao serializar: %v", err)
+		}
+		if !strings.Contains(string(xmlNFe), "</infNFe><infNFeSupl><qrCode><![CDATA[https://") {
+			t.Errorf("esperava infNFeSupl apos infNFe com qrCode em CDATA:\n%s", xmlNFe)
+		}
+	})
+
+	t.Run("deve incluir dia, vNF e digest no QR Code offline", func(t *testing.T) {
+		doc, err := nfe.Gerar(notaNFCeTeste(t, dominio.TipoEmissaoOffline), configuracaoNFCe())
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		_, parametros, _ := strings.Cut(doc.InfNFeSupl.QRCode.Texto, "?p=")
+		campos := strings.Split(parametros, "|")
+		if len(campos) != 8 || campos[3] != "10" || campos[4] != "21.00" || campos[6] != "1" {
+			t.Fatalf("campos do QR Code offline inesperados: %v", campos)
+		}
+		digVal, err := hex.DecodeString(campos[5])
+		if err != nil {
+			t.Fatalf("digVal nao esta em hexadecimal: %v", err)
+		}
+		if digest, err := base64.StdEncoding.DecodeString(string(digVal)); err != nil || len(digest) != sha1.Size {
+			t.Errorf("digVal deve ser o DigestValue em base64 (SHA-1), obteve %q", digVal)
+		}
+	})
+
+	t.Run("deve identificar o consumidor sem IE e aceitar NFC-e sem destinatario", func(t *testing.T) {
+		nota := notaNFCeTeste(t, dominio.TipoEmissaoNormal)
+		nota.Cliente = &dominio.Cliente{Nome: "Maria", Documento: "52998224725", IndicadorIE: dominio.IndicadorIEContribuinte, IE: "111222333444"}
+		doc, err := nfe.Gerar(nota, configuracaoNFCe())
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if d := doc.InfNFe.Dest; d == nil || d.CPF != "52998224725" || d.IndIEDest != "9" || d.IE != "" || d.EnderDest != nil {
+			t.Errorf("dest inesperado para NFC-e: %+v", d)
+		}
+	})
+
+	t.Run("deve exigir CSC configurado", func(t *testing.T) {
+		cfg := configuracaoNFCe()
+		cfg.CSC = ""
+		if _, err := nfe.Gerar(notaNFCeTeste(t, dominio.TipoEmissaoNormal), cfg); !errors.Is(err, nfe.ErrCSCNaoConfigurado) {
+			t.Errorf("esperava ErrCSCNaoConfigurado, obteve %v", err)
+		}
+	})
+
+	t.Run("deve exigir URLs para UF fora da tabela", func(t *testing.T) {
+		cfg := configuracaoNFCe()
+		cfg.Emitente.EnderEmit.UF = "AC"
+		nota := notaNFCeTeste(t, dominio.TipoEmissaoNormal)
+		if err := nfe.AtribuirChave(&nota, cfg); err != nil {
+			t.Fatalf("falha ao atribuir chave: %v", err)
+		}
+		if _, err := nfe.Gerar(nota, cfg); !errors.Is(err, nfe.ErrUFSemNFCe) {
+			t.Errorf("esperava ErrUFSemNFCe, obteve %v", err)
+		}
+		cfg.URLQRCode, cfg.URLConsulta = "https://www.sefaznet.ac.gov.br/nfce/qrcode", "www.sefaznet.ac.gov.br/nfce/consulta"
+		if _, err := nfe.Gerar(nota, cfg); err != nil {
+			t.Errorf("esperava URLs das variaveis de ambiente, obteve %v", err)
+		}
+	})
+}
+
+func TestValidarNFCe(t *testing.T) {
+	gerar := func(t *testing.T) *nfe.NFe {
+		t.Helper()
+		doc, err := nfe.Gerar(notaNFCeTeste(t, dominio.TipoEmissaoNormal), configuracaoNFCe())
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		return doc
+	}
+
+	casos := []struct {
+		nome   string
+		mudar  func(doc *nfe.NFe)
+		trecho string
+	}{
+		{"deve exigir infNFeSupl", func(doc *nfe.NFe) { doc.InfNFeSupl = nil }, "infNFeSupl: obrigatorio"},
+		{"deve recusar pagamento 90", func(doc *nfe.NFe) { doc.InfNFe.Pag.DetPag[0].TPag = "90" }, "nao aceita 90"},
+		{"deve recusar CFOP interestadual", func(doc *nfe.NFe) { doc.InfNFe.Det[0].Prod.CFOP = "6102" }, "prod/CFOP"},
+		{"deve recusar IE do destinatario", func(doc *nfe.NFe) {
+			doc.InfNFe.Dest = &nfe.Dest{CPF: "52998224725", IndIEDest: "1", IE: "111222333444"}
+		}, "dest/IE"},
+		{"deve recusar DANFE retrato", func(doc *nfe.NFe) { doc.InfNFe.Ide.TpImp = "1" }, "ide/tpImp"},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			doc := gerar(t)
+			caso.mudar(doc)
+			err := nfe.Validar(doc)
+			if err == nil || !strings.Contains(err.Error(), caso.trecho) {
+				t.Errorf("esperava erro com %q, obteve %v", caso.trecho, err)
+			}
+		})
+	}
+
+	t.Run("deve recusar infNFeSupl na NF-e", func(t *testing.T) {
+		doc, err := nfe.Gerar(notaFechadaTeste(t), configuracaoTeste())
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		doc.InfNFeSupl = &nfe.InfNFeSupl{}
+		if err := nfe.Validar(doc); err == nil || !strings.Contains(err.Error(), "exclusivo da NFC-e") {
+			t.Errorf("esperava erro de infNFeSupl, obteve %v", err)
+		}
+	})
+}
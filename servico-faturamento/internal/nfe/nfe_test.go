@@ -0,0 +1,319 @@
+package nfe_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+
+	"github.com/google/uuid"
+)
+
+func configuracaoTeste() nfe.Configuracao {
+	return nfe.Configuracao{
+		Emitente: nfe.Emit{
+			CNPJ:  "11222333000181",
+			XNome: "Empresa Teste LTDA",
+			EnderEmit: nfe.Endereco{
+				XLgr:    "Rua das Flores",
+				Nro:     "100",
+				XBairro: "Centro",
+				CMun:    "3550308",
+				XMun:    "Sao Paulo",
+				UF:      "SP",
+				CEP:     "01001000",
+			},
+			IE:  "111222333444",
+			CRT: "1",
+		},
+		Serie:            "1",
+		Ambiente:         "2",
+		NaturezaOperacao: "VENDA DE MERCADORIA",
+	}
+}
+
+func configuracaoTesteEndereco() dominio.Endereco {
+	return dominio.Endereco{
+		Logradouro:      "Rua das Flores",
+		Numero:          "100",
+		Bairro:          "Centro",
+		CodigoMunicipio: "3550308",
+		Municipio:       "Sao Paulo",
+		UF:              "SP",
+		CEP:             "01001000",
+	}
+}
+
+func notaFechadaTeste(t *testing.T) dominio.NotaFiscal {
+	t.Helper()
+	fechada := time.Date(2025, 3, 10, 14, 30, 0, 0, time.UTC)
+	nota := dominio.NotaFiscal{
+		ID:          uuid.MustParse("a1b2c3d4-e5f6-4a5b-8c9d-0e1f2a3b4c5d"),
+		Numero:      "123",
+		Modelo:      nfe.ModeloNFe,
+		Serie:       1,
+		Status:      dominio.StatusNotaFechada,
+		DataCriacao: fechada.Add(-time.Hour),
+		DataFechada: &fechada,
+		Itens: []dominio.ItemNota{
+			{
+				ID:            uuid.New(),
+				ProdutoID:     uuid.New(),
+				Quantidade:    dominio.DecimalDeInteiro(2),
+				PrecoUnitario: dominio.MustParseDecimal("10.50"),
+				Descricao:     "Caneta esferografica azul",
+				NCM:           "96081000",
+				CFOP:          "5102",
+			},
+		},
+	}
+	if err := nfe.AtribuirChave(&nota, configuracaoTeste()); err != nil {
+		t.Fatalf("falha ao atribuir chave: %v", err)
+	}
+	return nota
+}
+
+func TestGerar(t *testing.T) {
+	t.Run("deve gerar NF-e valida para nota fechada", func(t *testing.T) {
+		doc, err := nfe.Gerar(notaFechadaTeste(t), configuracaoTeste())
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		chave := strings.TrimPrefix(doc.InfNFe.ID, "NFe")
+		if len(chave) != 44 {
+			t.Fatalf("esperava chave com 44 digitos, obteve %d", len(chave))
+		}
+		if !strings.HasPrefix(chave, "352503") {
+			t.Errorf("esperava chave iniciando com cUF+AAMM 352503, obteve %s", chave[:6])
+		}
+		if chave[43:] != dominio.DigitoModulo11(chave[:43]) {
+			t.Errorf("digito verificador invalido na chave %s", chave)
+		}
+		if doc.InfNFe.Ide.NNF != "123" {
+			t.Errorf("esperava nNF 123, obteve %s", doc.InfNFe.Ide.NNF)
+		}
+		if doc.InfNFe.Ide.DhEmi != "2025-03-10T11:30:00-03:00" {
+			t.Errorf("esperava dhEmi no fuso de Brasilia, obteve %s", doc.InfNFe.Ide.DhEmi)
+		}
+		if doc.InfNFe.Total.ICMSTot.VNF != "21.00" {
+			t.Errorf("esperava vNF 21.00, obteve %s", doc.InfNFe.Total.ICMSTot.VNF)
+		}
+	})
+
+	t.Run("deve usar a chave de acesso persistida", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+
+		doc, err := nfe.Gerar(nota, configuracaoTeste())
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		if doc.InfNFe.ID != "NFe"+*nota.ChaveAcesso {
+			t.Errorf("esperava Id NFe%s, obteve %s", *nota.ChaveAcesso, doc.InfNFe.ID)
+		}
+		if doc.InfNFe.Ide.CNF != (*nota.ChaveAcesso)[35:43] {
+			t.Errorf("esperava cNF da chave, obteve %s", doc.InfNFe.Ide.CNF)
+		}
+	})
+
+	t.Run("deve usar emitente e destinatario vinculados a nota", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+		endereco := dominio.Endereco{
+			Logradouro:      "Rua da Praia",
+			Numero:          "50",
+			Bairro:          "Centro Historico",
+			CodigoMunicipio: "4314902",
+			Municipio:       "Porto Alegre",
+			UF:              "RS",
+			CEP:             "90010000",
+		}
+		nota.Emitente = &dominio.Emitente{
+			CNPJ:        "11222333000181",
+			RazaoSocial: "Emitente Cadastrado LTDA",
+			IE:          "111222333444",
+			CRT:         dominio.CRTRegimeNormal,
+			Endereco:    configuracaoTesteEndereco(),
+		}
+		nota.Cliente = &dominio.Cliente{
+			Documento:   "52998224725",
+			Nome:        "Maria da Silva",
+			IndicadorIE: dominio.IndicadorIENaoContribuinte,
+			Endereco:    endereco,
+		}
+
+		doc, err := nfe.Gerar(nota, configuracaoTeste())
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		if doc.InfNFe.Emit.XNome != "Emitente Cadastrado LTDA" || doc.InfNFe.Emit.CRT != "3" {
+			t.Errorf("esperava emit do cadastro, obteve %+v", doc.InfNFe.Emit)
+		}
+		if doc.InfNFe.Det[0].Imposto.ICMS.ICMS40 == nil {
+			t.Error("esperava ICMS40 para emitente do regime normal")
+		}
+		dest := doc.InfNFe.Dest
+		if dest == nil || dest.CPF != "52998224725" || dest.CNPJ != "" {
+			t.Fatalf("esperava dest com CPF, obteve %+v", dest)
+		}
+		if dest.XNome != "NF-E EMITIDA EM AMBIENTE DE HOMOLOGACAO - SEM VALOR FISCAL" {
+			t.Errorf("esperava xNome de homologacao, obteve %s", dest.XNome)
+		}
+		if doc.InfNFe.Ide.IdDest != "2" || doc.InfNFe.Ide.IndFinal != "1" {
+			t.Errorf("esperava operacao interestadual com consumidor final, obteve idDest %s indFinal %s",
+				doc.InfNFe.Ide.IdDest, doc.InfNFe.Ide.IndFinal)
+		}
+	})
+
+	t.Run("deve emitir os grupos de ICMS, IPI, PIS e COFINS calculados no fechamento", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+		regra := dominio.RegraTributaria{
+			CSTICMS: "00", PICMS: dominio.MustParseDecimal("18"),
+			CSTIPI: "50", CEnqIPI: "999", PIPI: dominio.MustParseDecimal("10"),
+			CSTPIS: "01", PPIS: dominio.MustParseDecimal("1.65"),
+			CSTCOFINS: "01", PCOFINS: dominio.MustParseDecimal("7.6"),
+		}
+		op := dominio.OperacaoTributaria{Regime: dominio.RegimeNormal, UFDestino: "SP", Data: *nota.DataFechada}
+		nota.CalcularTributos([]dominio.RegraTributaria{regra}, op)
+		cfg := configuracaoTeste()
+		cfg.Emitente.CRT = dominio.CRTRegimeNormal
+
+		doc, err := nfe.Gerar(nota, cfg)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		xmlNFe, err := nfe.Serializar(doc)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		conteudo := string(xmlNFe)
+		esperados := []string{
+			`<ICMS><ICMS00><orig>0</orig><CST>00</CST><modBC>3</modBC><vBC>21.00</vBC><pICMS>18.00</pICMS><vICMS>3.78</vICMS></ICMS00></ICMS>`,
+			`<IPI><cEnq>999</cEnq><IPITrib><CST>50</CST><vBC>21.00</vBC><pIPI>10.00</pIPI><vIPI>2.10</vIPI></IPITrib></IPI>`,
+			`<PISAliq><CST>01</CST><vBC>17.22</vBC><pPIS>1.65</pPIS><vPIS>0.28</vPIS></PISAliq>`,
+			`<COFINSAliq><CST>01</CST><vBC>17.22</vBC><pCOFINS>7.60</pCOFINS><vCOFINS>1.31</vCOFINS></COFINSAliq>`,
+			`<vBC>21.00</vBC><vICMS>3.78</vICMS>`,
+			`<vIPI>2.10</vIPI><vIPIDevol>0.00</vIPIDevol><vPIS>0.28</vPIS><vCOFINS>1.31</vCOFINS>`,
+			`<vNF>23.10</vNF>`,
+			`<vPag>23.10</vPag>`,
+		}
+		for _, trecho := range esperados {
+			if !strings.Contains(conteudo, trecho) {
+				t.Errorf("esperava trecho %q no XML", trecho)
+			}
+		}
+	})
+
+	t.Run("deve emitir os grupos de IBS e CBS do periodo vigente", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+		periodo := dominio.AliquotaIBSCBS{
+			VigenciaInicio: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			PIBSUF:         dominio.MustParseDecimal("0.1"),
+			PCBS:           dominio.MustParseDecimal("0.9"),
+		}
+		op := dominio.OperacaoTributaria{Regime: dominio.RegimeNormal, UFDestino: "SP", Data: *nota.DataFechada, AliquotaIBSCBS: &periodo}
+		nota.CalcularTributos(nil, op)
+		cfg := configuracaoTeste()
+		cfg.Emitente.CRT = dominio.CRTRegimeNormal
+
+		doc, err := nfe.Gerar(nota, cfg)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		xmlNFe, err := nfe.Serializar(doc)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+
+		conteudo := string(xmlNFe)
+		esperados := []string{
+			`</COFINS><IBSCBS><CST>000</CST><cClassTrib>000001</cClassTrib><gIBSCBS><vBC>21.00</vBC>` +
+				`<gIBSUF><pIBSUF>0.10</pIBSUF><vIBSUF>0.02</vIBSUF></gIBSUF><gIBSMun><pIBSMun>0.00</pIBSMun><vIBSMun>0.00</vIBSMun></gIBSMun>` +
+				`<vIBS>0.02</vIBS><gCBS><pCBS>0.90</pCBS><vCBS>0.19</vCBS></gCBS></gIBSCBS></IBSCBS>`,
+			`</ICMSTot><IBSCBSTot><vBCIBSCBS>21.00</vBCIBSCBS>`,
+			`<gCBS><vDif>0.00</vDif><vDevTrib>0.00</vDevTrib><vCBS>0.19</vCBS>`,
+			`<vNF>21.00</vNF>`,
+		}
+		for _, trecho := range esperados {
+			if !strings.Contains(conteudo, trecho) {
+				t.Errorf("esperava trecho %q no XML", trecho)
+			}
+		}
+	})
+
+	t.Run("deve rejeitar nota fechada sem chave", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+		nota.ChaveAcesso = nil
+
+		_, err := nfe.Gerar(nota, configuracaoTeste())
+
+		if !errors.Is(err, nfe.ErrNotaSemChave) {
+			t.Errorf("esperava ErrNotaSemChave, obteve %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar nota aberta", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+		nota.Status = dominio.StatusNotaAberta
+
+		_, err := nfe.Gerar(nota, configuracaoTeste())
+
+		if !errors.Is(err, nfe.ErrNotaNaoFechada) {
+			t.Errorf("esperava ErrNotaNaoFechada, obteve %v", err)
+		}
+	})
+
+	t.Run("deve apontar campos fora do leiaute", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+		nota.Itens[0].NCM = ""
+		cfg := configuracaoTeste()
+		cfg.Emitente.CNPJ = ""
+
+		_, err := nfe.Gerar(nota, cfg)
+
+		var erros nfe.ErrosValidacao
+		if !errors.As(err, &erros) {
+			t.Fatalf("esperava ErrosValidacao, obteve %v", err)
+		}
+		mensagem := err.Error()
+		for _, campo := range []string{"emit/CNPJ", "det[1]/prod/NCM"} {
+			if !strings.Contains(mensagem, campo) {
+				t.Errorf("esperava erro no campo %s, obteve: %s", campo, mensagem)
+			}
+		}
+	})
+}
+
+func TestSerializar(t *testing.T) {
+	doc, err := nfe.Gerar(notaFechadaTeste(t), configuracaoTeste())
+	if err != nil {
+		t.Fatalf("esperava nil, obteve erro: %v", err)
+	}
+
+	xmlNFe, err := nfe.Serializar(doc)
+	if err != nil {
+		t.Fatalf("esperava nil, obteve erro: %v", err)
+	}
+
+	conteudo := string(xmlNFe)
+	esperados := []string{
+		`<?xml version="1.0" encoding="UTF-8"?><NFe xmlns="http://www.portalfiscal.inf.br/nfe">`,
+		`<infNFe versao="4.00" Id="` + doc.InfNFe.ID + `">`,
+		`<det nItem="1"><prod>`,
+		`<ICMSSN102><orig>0</orig><CSOSN>102</CSOSN></ICMSSN102>`,
+		`<vNF>21.00</vNF>`,
+	}
+	for _, trecho := range esperados {
+		if !strings.Contains(conteudo, trecho) {
+			t.Errorf("esperava trecho %q no XML", trecho)
+		}
+	}
+	if strings.Contains(conteudo, "\n") {
+		t.Error("XML da NF-e nao deve conter quebras de linha")
+	}
+}
@@ -0,0 +1,439 @@
+package nfe
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Padrões dos tipos simples do leiauteNFe_v4.00.xsd e tiposBasico_v4.00.xsd
+var (
+	padraoChaveID    = regexp.MustCompile(`^NFe[0-9]{44}$`)
+	padraoCUF        = regexp.MustCompile(`^[0-9]{2}$`)
+	padraoCNF        = regexp.MustCompile(`^[0-9]{8}$`)
+	padraoMod        = regexp.MustCompile(`^(55|65)$`)
+	padraoSerie      = regexp.MustCompile(`^(0|[1-9]{1}[0-9]{0,2})$`)
+	padraoNNF        = regexp.MustCompile(`^[1-9]{1}[0-9]{0,8}$`)
+	padraoDataHora   = regexp.MustCompile(`^(((20(([02468][048])|([13579][26]))-02-29))|(20[0-9][0-9])-((((0[1-9])|(1[0-2]))-((0[1-9])|(1\d)|(2[0-8])))|((((0[13578])|(1[02]))-31)|(((0[1,3-9])|(1[0-2]))-(29|30)))))T(20|21|22|23|[0-1]\d):[0-5]\d:[0-5]\d([\-,\+](0[0-9]|10|11):00|([\+](12):00))$`)
+	padraoCodMun     = regexp.MustCompile(`^[0-9]{7}$`)
+	padraoDigito     = regexp.MustCompile(`^[0-9]$`)
+	padraoCNPJ       = regexp.MustCompile(`^[0-9]{14}$`)
+	padraoCPF        = regexp.MustCompile(`^[0-9]{11}$`)
+	padraoIE         = regexp.MustCompile(`^([0-9]{2,14}|ISENTO)$`)
+	padraoCEP        = regexp.MustCompile(`^[0-9]{8}$`)
+	padraoUF         = regexp.MustCompile(`^(AC|AL|AM|AP|BA|CE|DF|ES|GO|MA|MG|MS|MT|PA|PB|PE|PI|PR|RJ|RN|RO|RR|RS|SC|SE|SP|TO|EX)$`)
+	padraoCRT        = regexp.MustCompile(`^[1-4]$`)
+	padraoNCM        = regexp.MustCompile(`^([0-9]{2}|[0-9]{8})$`)
+	padraoCFOP       = regexp.MustCompile(`^[123567][0-9]{3}$`)
+	padraoGTIN       = regexp.MustCompile(`^(SEM GTIN|[0-9]{0}|[0-9]{8}|[0-9]{12,14})$`)
+	padraoDec1302    = regexp.MustCompile(`^(0|0\.[0-9]{2}|[1-9]{1}[0-9]{0,12}(\.[0-9]{2})?)$`)
+	padraoDec1104v   = regexp.MustCompile(`^(0|0\.[0-9]{1,4}|[1-9]{1}[0-9]{0,10}|[1-9]{1}[0-9]{0,10}(\.[0-9]{1,4})?)$`)
+	padraoDec1110v   = regexp.MustCompile(`^(0|0\.[0-9]{1,10}|[1-9]{1}[0-9]{0,10}|[1-9]{1}[0-9]{0,10}(\.[0-9]{1,10})?)$`)
+	padraoOrig       = regexp.MustCompile(`^[0-8]$`)
+	padraoDec0302a4  = regexp.MustCompile(`^(0|0\.[0-9]{2,4}|[1-9]{1}[0-9]{0,2}(\.[0-9]{2,4})?)$`)
+	padraoCSTICMS40  = regexp.MustCompile(`^(40|41|50)$`)
+	padraoCSOSN102   = regexp.MustCompile(`^(102|103|300|400)$`)
+	padraoModBC      = regexp.MustCompile(`^[0-3]$`)
+	padraoCEnq       = regexp.MustCompile(`^[0-9]{3}$`)
+	padraoCSTIPI     = regexp.MustCompile(`^(00|49|50|99)$`)
+	padraoCSTIPINT   = regexp.MustCompile(`^(01|02|03|04|05|51|52|53|54|55)$`)
+	padraoCSTPISAliq = regexp.MustCompile(`^(01|02)$`)
+	padraoCSTPISNT   = regexp.MustCompile(`^0[4-9]$`)
+	padraoCSTPISOutr = regexp.MustCompile(`^(49|5[0-6]|6[0-7]|7[0-5]|98|99)$`)
+	padraoCSTIBSCBS  = regexp.MustCompile(`^[0-9]{3}$`)
+	padraoCClassTrib = regexp.MustCompile(`^[0-9]{6}$`)
+	padraoModFrete   = regexp.MustCompile(`^[0-49]$`)
+	padraoTPag       = regexp.MustCompile(`^(01|02|03|04|05|10|11|12|13|15|16|17|18|19|20|90|99)$`)
+	padraoIEDest     = regexp.MustCompile(`^[0-9]{2,14}$`)
+	padraoTBand      = regexp.MustCompile(`^[0-9]{2}$`)
+	padraoCFOPNFCe   = regexp.MustCompile(`^5[0-9]{3}$`)
+	padraoTString    = regexp.MustCompile(`^([!-ÿ]{1}[ -ÿ]*[!-ÿ]{1}|[!-ÿ]{1})$`)
+)
+
+// tiposXSD liga os padrões copiados ao tipo simples de origem no pacote oficial;
+// TestPadroesContraXSD compara cada cópia com o pattern do XSD, para que uma
+// revisão do leiaute não passe despercebida pelo validador
+var tiposXSD = map[string]*regexp.Regexp{
+	"TCodMunIBGE":  padraoCodMun,
+	"TCnpj":        padraoCNPJ,
+	"TCpf":         padraoCPF,
+	"TDateTimeUTC": padraoDataHora,
+	"TDec_1302":    padraoDec1302,
+	"TDec_1104v":   padraoDec1104v,
+	"TDec_1110v":   padraoDec1110v,
+	"TDec_0302a04": padraoDec0302a4,
+	"TSerie":       padraoSerie,
+	"TNF":          padraoNNF,
+	"TString":      padraoTString,
+}
+
+// ErrosValidacao lista as violações do leiaute encontradas no documento
+type ErrosValidacao []string
+
+func (e ErrosValidacao) Error() string {
+	return "NF-e invalida: " + strings.Join(e, "; ")
+}
+
+type validador struct {
+	erros ErrosValidacao
+}
+
+func (v *validador) padrao(campo, valor string, re *regexp.Regexp) {
+	if !re.MatchString(valor) {
+		v.erros = append(v.erros, fmt.Sprintf("%s: valor %q fora do padrao", campo, valor))
+	}
+}
+
+// texto valida um TString com limites de tamanho
+func (v *validador) texto(campo, valor string, min, max int) {
+	n := utf8.RuneCountInString(valor)
+	if n < min || n > max {
+		v.erros = append(v.erros, fmt.Sprintf("%s: tamanho %d fora do intervalo %d-%d", campo, n, min, max))
+		return
+	}
+	if n > 0 && !padraoTString.MatchString(valor) {
+		v.erros = append(v.erros, fmt.Sprintf("%s: espacos nas extremidades ou caracteres invalidos", campo))
+	}
+}
+
+// enum valida campos de um único caractere com domínio fechado
+func (v *validador) enum(campo, valor, permitidos string) {
+	if len(valor) != 1 || !strings.Contains(permitidos, valor) {
+		v.erros = append(v.erros, fmt.Sprintf("%s: valor %q fora do dominio", campo, valor))
+	}
+}
+
+// igual valida campos com valor fixo dentro do grupo, como o CST de ICMS00
+func (v *validador) igual(campo, valor, esperado string) {
+	if valor != esperado {
+		v.erros = append(v.erros, fmt.Sprintf("%s: esperado %q, obtido %q", campo, esperado, valor))
+	}
+}
+
+// aliquotaPIS valida os grupos de PIS e COFINS calculados por alíquota
+func (v *validador) aliquotaPIS(grupo, tributo, cst string, padraoCST *regexp.Regexp, vBC, aliquota, valorTributo string) {
+	v.padrao(grupo+"/CST", cst, padraoCST)
+	v.padrao(grupo+"/vBC", vBC, padraoDec1302)
+	v.padrao(grupo+"/p"+tributo, aliquota, padraoDec0302a4)
+	v.padrao(grupo+"/v"+tributo, valorTributo, padraoDec1302)
+}
+
+func (v *validador) opcional(campo, valor string, min, max int) {
+	if valor != "" {
+		v.texto(campo, valor, min, max)
+	}
+}
+
+func (v *validador) endereco(grupo string, e Endereco) {
+	v.texto(grupo+"/xLgr", e.XLgr, 2, 60)
+	v.texto(grupo+"/nro", e.Nro, 1, 60)
+	v.opcional(grupo+"/xCpl", e.XCpl, 1, 60)
+	v.texto(grupo+"/xBairro", e.XBairro, 2, 60)
+	v.padrao(grupo+"/cMun", e.CMun, padraoCodMun)
+	v.texto(grupo+"/xMun", e.XMun, 2, 60)
+	v.padrao(grupo+"/UF", e.UF, padraoUF)
+	if e.CEP != "" {
+		v.padrao(grupo+"/CEP", e.CEP, padraoCEP)
+	}
+}
+
+// Validar confere o documento contra as restrições do schema NF-e 4.00
+// (tipos, padrões, tamanhos e ocorrências dos grupos emitidos pelo serviço)
+func Validar(doc *NFe) error {
+	v := &validador{}
+	inf := doc.InfNFe
+
+	if inf.Versao != VersaoLayout {
+		v.erros = append(v.erros, fmt.Sprintf("infNFe/versao: esperado %s", VersaoLayout))
+	}
+	v.padrao("infNFe/Id", inf.ID, padraoChaveID)
+
+	ide := inf.Ide
+	v.padrao("ide/cUF", ide.CUF, padraoCUF)
+	v.padrao("ide/cNF", ide.CNF, padraoCNF)
+	v.texto("ide/natOp", ide.NatOp, 1, 60)
+	v.padrao("ide/mod", ide.Mod, padraoMod)
+	v.padrao("ide/serie", ide.Serie, padraoSerie)
+	v.padrao("ide/nNF", ide.NNF, padraoNNF)
+	v.padrao("ide/dhEmi", ide.DhEmi, padraoDataHora)
+	v.enum("ide/tpNF", ide.TpNF, "01")
+	v.enum("ide/idDest", ide.IdDest, "123")
+	v.padrao("ide/cMunFG", ide.CMunFG, padraoCodMun)
+	v.enum("ide/tpImp", ide.TpImp, "012345")
+	v.enum("ide/tpEmis", ide.TpEmis, "123456789")
+	v.padrao("ide/cDV", ide.CDV, padraoDigito)
+	v.enum("ide/tpAmb", ide.TpAmb, "12")
+	v.enum("ide/finNFe", ide.FinNFe, "1234")
+	v.enum("ide/indFinal", ide.IndFinal, "01")
+	v.enum("ide/indPres", ide.IndPres, "0123459")
+	v.enum("ide/procEmi", ide.ProcEmi, "0123")
+	v.texto("ide/verProc", ide.VerProc, 1, 20)
+	if ide.TpEmis != "1" {
+		v.padrao("ide/dhCont", ide.DhCont, padraoDataHora)
+		v.texto("ide/xJust", ide.XJust, 15, 256)
+	}
+
+	emit := inf.Emit
+	v.padrao("emit/CNPJ", emit.CNPJ, padraoCNPJ)
+	v.texto("emit/xNome", emit.XNome, 2, 60)
+	v.opcional("emit/xFant", emit.XFant, 1, 60)
+	v.endereco("emit/enderEmit", emit.EnderEmit)
+	v.padrao("emit/IE", emit.IE, padraoIE)
+	v.padrao("emit/CRT", emit.CRT, padraoCRT)
+
+	if dest := inf.Dest; dest != nil {
+		switch {
+		case dest.CNPJ != "" && dest.CPF == "":
+			v.padrao("dest/CNPJ", dest.CNPJ, padraoCNPJ)
+		case dest.CPF != "" && dest.CNPJ == "":
+			v.padrao("dest/CPF", dest.CPF, padraoCPF)
+		default:
+			v.erros = append(v.erros, "dest: informe exatamente um entre CNPJ e CPF")
+		}
+		v.opcional("dest/xNome", dest.XNome, 2, 60)
+		if dest.EnderDest != nil {
+			v.endereco("dest/enderDest", *dest.EnderDest)
+		}
+		v.enum("dest/indIEDest", dest.IndIEDest, "129")
+		if dest.IE != "" {
+			v.padrao("dest/IE", dest.IE, padraoIEDest)
+		}
+		v.opcional("dest/email", dest.Email, 1, 60)
+	}
+
+	if len(inf.Det) == 0 || len(inf.Det) > 990 {
+		v.erros = append(v.erros, fmt.Sprintf("det: quantidade de itens %d fora do intervalo 1-990", len(inf.Det)))
+	}
+	for i, det := range inf.Det {
+		validarDet(v, i, det)
+	}
+
+	tot := inf.Total.ICMSTot
+	for _, total := range []struct{ campo, valor string }{
+		{"vBC", tot.VBC}, {"vICMS", tot.VICMS}, {"vICMSDeson", tot.VICMSDeson}, {"vFCP", tot.VFCP},
+		{"vBCST", tot.VBCST}, {"vST", tot.VST}, {"vFCPST", tot.VFCPST}, {"vFCPSTRet", tot.VFCPSTRet},
+		{"vProd", tot.VProd}, {"vFrete", tot.VFrete}, {"vSeg", tot.VSeg}, {"vDesc", tot.VDesc},
+		{"vII", tot.VII}, {"vIPI", tot.VIPI}, {"vIPIDevol", tot.VIPIDevol}, {"vPIS", tot.VPIS},
+		{"vCOFINS", tot.VCOFINS}, {"vOutro", tot.VOutro}, {"vNF", tot.VNF},
+	} {
+		v.padrao("total/ICMSTot/"+total.campo, total.valor, padraoDec1302)
+	}
+
+	if t := inf.Total.IBSCBSTot; t != nil {
+		for _, total := range []struct{ campo, valor string }{
+			{"vBCIBSCBS", t.VBCIBSCBS},
+			{"gIBS/gIBSUF/vDif", t.GIBS.GIBSUF.VDif}, {"gIBS/gIBSUF/vDevTrib", t.GIBS.GIBSUF.VDevTrib}, {"gIBS/gIBSUF/vIBSUF", t.GIBS.GIBSUF.VIBSUF},
+			{"gIBS/gIBSMun/vDif", t.GIBS.GIBSMun.VDif}, {"gIBS/gIBSMun/vDevTrib", t.GIBS.GIBSMun.VDevTrib}, {"gIBS/gIBSMun/vIBSMun", t.GIBS.GIBSMun.VIBSMun},
+			{"gIBS/vIBS", t.GIBS.VIBS}, {"gIBS/vCredPres", t.GIBS.VCredPres}, {"gIBS/vCredPresCondSus", t.GIBS.VCredPresCondSus},
+			{"gCBS/vDif", t.GCBS.VDif}, {"gCBS/vDevTrib", t.GCBS.VDevTrib}, {"gCBS/vCBS", t.GCBS.VCBS},
+			{"gCBS/vCredPres", t.GCBS.VCredPres}, {"gCBS/vCredPresCondSus", t.GCBS.VCredPresCondSus},
+		} {
+			v.padrao("total/IBSCBSTot/"+total.campo, total.valor, padraoDec1302)
+		}
+	}
+
+	v.padrao("transp/modFrete", inf.Transp.ModFrete, padraoModFrete)
+
+	if len(inf.Pag.DetPag) == 0 || len(inf.Pag.DetPag) > 100 {
+		v.erros = append(v.erros, "pag/detPag: informe de 1 a 100 formas de pagamento")
+	}
+	for i, det := range inf.Pag.DetPag {
+		campo := fmt.Sprintf("pag/detPag[%d]", i+1)
+		v.padrao(campo+"/tPag", det.TPag, padraoTPag)
+		v.opcional(campo+"/xPag", det.XPag, 2, 60)
+		v.padrao(campo+"/vPag", det.VPag, padraoDec1302)
+	}
+
+	if t := inf.Pag.VTroco; t != "" {
+		v.padrao("pag/vTroco", t, padraoDec1302)
+	}
+	for i, det := range inf.Pag.DetPag {
+		if card := det.Card; card != nil {
+			campo := fmt.Sprintf("pag/detPag[%d]/card", i+1)
+			v.enum(campo+"/tpIntegra", card.TpIntegra, "12")
+			if card.TBand != "" {
+				v.padrao(campo+"/tBand", card.TBand, padraoTBand)
+			}
+			v.opcional(campo+"/cAut", card.CAut, 1, 128)
+		}
+	}
+
+	if ide.Mod == ModeloNFCe {
+		validarNFCe(v, doc)
+	} else if doc.InfNFeSupl != nil {
+		v.erros = append(v.erros, "infNFeSupl: exclusivo da NFC-e")
+	}
+
+	if adic := inf.InfAdic; adic != nil {
+		v.opcional("infAdic/infAdFisco", adic.InfAdFisco, 1, 2000)
+		v.opcional("infAdic/infCpl", adic.InfCpl, 1, 5000)
+	}
+
+	if len(v.erros) > 0 {
+		return v.erros
+	}
+	return nil
+}
+
+func validarDet(v *validador, i int, det Det) {
+	campo := fmt.Sprintf("det[%d]", i+1)
+	if det.NItem != i+1 {
+		v.erros = append(v.erros, fmt.Sprintf("%s/nItem: esperado %d", campo, i+1))
+	}
+
+	p := det.Prod
+	v.texto(campo+"/prod/cProd", p.CProd, 1, 60)
+	v.padrao(campo+"/prod/cEAN", p.CEAN, padraoGTIN)
+	v.texto(campo+"/prod/xProd", p.XProd, 1, 120)
+	v.padrao(campo+"/prod/NCM", p.NCM, padraoNCM)
+	v.padrao(campo+"/prod/CFOP", p.CFOP, padraoCFOP)
+	v.texto(campo+"/prod/uCom", p.UCom, 1, 6)
+	v.padrao(campo+"/prod/qCom", p.QCom, padraoDec1104v)
+	v.padrao(campo+"/prod/vUnCom", p.VUnCom, padraoDec1110v)
+	v.padrao(campo+"/prod/vProd", p.VProd, padraoDec1302)
+	v.padrao(campo+"/prod/cEANTrib", p.CEANTrib, padraoGTIN)
+	v.texto(campo+"/prod/uTrib", p.UTrib, 1, 6)
+	v.padrao(campo+"/prod/qTrib", p.QTrib, padraoDec1104v)
+	v.padrao(campo+"/prod/vUnTrib", p.VUnTrib, padraoDec1110v)
+	v.enum(campo+"/prod/indTot", p.IndTot, "01")
+
+	icms := det.Imposto.ICMS
+	grupos := 0
+	if g := icms.ICMS00; g != nil {
+		grupos++
+		v.padrao(campo+"/ICMS00/orig", g.Orig, padraoOrig)
+		v.igual(campo+"/ICMS00/CST", g.CST, "00")
+		v.padrao(campo+"/ICMS00/modBC", g.ModBC, padraoModBC)
+		v.padrao(campo+"/ICMS00/vBC", g.VBC, padraoDec1302)
+		v.padrao(campo+"/ICMS00/pICMS", g.PICMS, padraoDec0302a4)
+		v.padrao(campo+"/ICMS00/vICMS", g.VICMS, padraoDec1302)
+	}
+	if g := icms.ICMS20; g != nil {
+		grupos++
+		v.padrao(campo+"/ICMS20/orig", g.Orig, padraoOrig)
+		v.igual(campo+"/ICMS20/CST", g.CST, "20")
+		v.padrao(campo+"/ICMS20/modBC", g.ModBC, padraoModBC)
+		v.padrao(campo+"/ICMS20/pRedBC", g.PRedBC, padraoDec0302a4)
+		v.padrao(campo+"/ICMS20/vBC", g.VBC, padraoDec1302)
+		v.padrao(campo+"/ICMS20/pICMS", g.PICMS, padraoDec0302a4)
+		v.padrao(campo+"/ICMS20/vICMS", g.VICMS, padraoDec1302)
+	}
+	if g := icms.ICMS40; g != nil {
+		grupos++
+		v.padrao(campo+"/ICMS40/orig", g.Orig, padraoOrig)
+		v.padrao(campo+"/ICMS40/CST", g.CST, padraoCSTICMS40)
+	}
+	if g := icms.ICMSSN101; g != nil {
+		grupos++
+		v.padrao(campo+"/ICMSSN101/orig", g.Orig, padraoOrig)
+		v.igual(campo+"/ICMSSN101/CSOSN", g.CSOSN, "101")
+		v.padrao(campo+"/ICMSSN101/pCredSN", g.PCredSN, padraoDec0302a4)
+		v.padrao(campo+"/ICMSSN101/vCredICMSSN", g.VCredICMSSN, padraoDec1302)
+	}
+	if g := icms.ICMSSN102; g != nil {
+		grupos++
+		v.padrao(campo+"/ICMSSN102/orig", g.Orig, padraoOrig)
+		v.padrao(campo+"/ICMSSN102/CSOSN", g.CSOSN, padraoCSOSN102)
+	}
+	if grupos != 1 {
+		v.erros = append(v.erros, campo+"/imposto/ICMS: informe exatamente um grupo de tributacao")
+	}
+
+	if ipi := det.Imposto.IPI; ipi != nil {
+		v.padrao(campo+"/IPI/cEnq", ipi.CEnq, padraoCEnq)
+		switch {
+		case ipi.IPITrib != nil && ipi.IPINT == nil:
+			v.padrao(campo+"/IPITrib/CST", ipi.IPITrib.CST, padraoCSTIPI)
+			v.padrao(campo+"/IPITrib/vBC", ipi.IPITrib.VBC, padraoDec1302)
+			v.padrao(campo+"/IPITrib/pIPI", ipi.IPITrib.PIPI, padraoDec0302a4)
+			v.padrao(campo+"/IPITrib/vIPI", ipi.IPITrib.VIPI, padraoDec1302)
+		case ipi.IPINT != nil && ipi.IPITrib == nil:
+			v.padrao(campo+"/IPINT/CST", ipi.IPINT.CST, padraoCSTIPINT)
+		default:
+			v.erros = append(v.erros, campo+"/imposto/IPI: informe exatamente um entre IPITrib e IPINT")
+		}
+	}
+
+	pis := det.Imposto.PIS
+	switch {
+	case pis.PISAliq != nil && pis.PISNT == nil && pis.PISOutr == nil:
+		v.aliquotaPIS(campo+"/PISAliq", "PIS", pis.PISAliq.CST, padraoCSTPISAliq, pis.PISAliq.VBC, pis.PISAliq.PPIS, pis.PISAliq.VPIS)
+	case pis.PISNT != nil && pis.PISAliq == nil && pis.PISOutr == nil:
+		v.padrao(campo+"/PISNT/CST", pis.PISNT.CST, padraoCSTPISNT)
+	case pis.PISOutr != nil && pis.PISAliq == nil && pis.PISNT == nil:
+		v.aliquotaPIS(campo+"/PISOutr", "PIS", pis.PISOutr.CST, padraoCSTPISOutr, pis.PISOutr.VBC, pis.PISOutr.PPIS, pis.PISOutr.VPIS)
+	case pis.PISAliq == nil && pis.PISNT == nil && pis.PISOutr == nil:
+		v.erros = append(v.erros, campo+"/imposto/PIS: grupo obrigatorio")
+	default:
+		v.erros = append(v.erros, campo+"/imposto/PIS: informe exatamente um grupo de tributacao")
+	}
+
+	cofins := det.Imposto.COFINS
+	switch {
+	case cofins.COFINSAliq != nil && cofins.COFINSNT == nil && cofins.COFINSOutr == nil:
+		v.aliquotaPIS(campo+"/COFINSAliq", "COFINS", cofins.COFINSAliq.CST, padraoCSTPISAliq, cofins.COFINSAliq.VBC, cofins.COFINSAliq.PCOFINS, cofins.COFINSAliq.VCOFINS)
+	case cofins.COFINSNT != nil && cofins.COFINSAliq == nil && cofins.COFINSOutr == nil:
+		v.padrao(campo+"/COFINSNT/CST", cofins.COFINSNT.CST, padraoCSTPISNT)
+	case cofins.COFINSOutr != nil && cofins.COFINSAliq == nil && cofins.COFINSNT == nil:
+		v.aliquotaPIS(campo+"/COFINSOutr", "COFINS", cofins.COFINSOutr.CST, padraoCSTPISOutr, cofins.COFINSOutr.VBC, cofins.COFINSOutr.PCOFINS, cofins.COFINSOutr.VCOFINS)
+	case cofins.COFINSAliq == nil && cofins.COFINSNT == nil && cofins.COFINSOutr == nil:
+		v.erros = append(v.erros, campo+"/imposto/COFINS: grupo obrigatorio")
+	default:
+		v.erros = append(v.erros, campo+"/imposto/COFINS: informe exatamente um grupo de tributacao")
+	}
+
+	if g := det.Imposto.IBSCBS; g != nil {
+		v.padrao(campo+"/IBSCBS/CST", g.CST, padraoCSTIBSCBS)
+		v.padrao(campo+"/IBSCBS/cClassTrib", g.CClassTrib, padraoCClassTrib)
+		if b := g.GIBSCBS; b != nil {
+			v.padrao(campo+"/gIBSCBS/vBC", b.VBC, padraoDec1302)
+			v.padrao(campo+"/gIBSUF/pIBSUF", b.GIBSUF.PIBSUF, padraoDec0302a4)
+			v.padrao(campo+"/gIBSUF/vIBSUF", b.GIBSUF.VIBSUF, padraoDec1302)
+			v.padrao(campo+"/gIBSMun/pIBSMun", b.GIBSMun.PIBSMun, padraoDec0302a4)
+			v.padrao(campo+"/gIBSMun/vIBSMun", b.GIBSMun.VIBSMun, padraoDec1302)
+			v.padrao(campo+"/gIBSCBS/vIBS", b.VIBS, padraoDec1302)
+			v.padrao(campo+"/gCBS/pCBS", b.GCBS.PCBS, padraoDec0302a4)
+			v.padrao(campo+"/gCBS/vCBS", b.GCBS.VCBS, padraoDec1302)
+		}
+	}
+
+	v.opcional(campo+"/infAdProd", det.InfAdProd, 1, 500)
+}
+
+// validarNFCe aplica as regras da NFC-e (modelo 65) que diferem da NF-e: venda
+// presencial interna a consumidor final, destinatário opcional e sem IE, grupo
+// pag com pagamento efetivo e o QR Code em infNFeSupl
+func validarNFCe(v *validador, doc *NFe) {
+	inf := doc.InfNFe
+	v.enum("ide/tpImp", inf.Ide.TpImp, "45")
+	v.enum("ide/tpEmis", inf.Ide.TpEmis, "19")
+	v.igual("ide/idDest", inf.Ide.IdDest, "1")
+	v.igual("ide/indFinal", inf.Ide.IndFinal, "1")
+	v.enum("ide/indPres", inf.Ide.IndPres, "14")
+
+	if dest := inf.Dest; dest != nil {
+		v.igual("dest/indIEDest", dest.IndIEDest, "9")
+		if dest.IE != "" {
+			v.erros = append(v.erros, "dest/IE: nao informar na NFC-e")
+		}
+	}
+
+	for i, det := range inf.Det {
+		v.padrao(fmt.Sprintf("det[%d]/prod/CFOP", i+1), det.Prod.CFOP, padraoCFOPNFCe)
+	}
+
+	for i, det := range inf.Pag.DetPag {
+		if det.TPag == "90" {
+			v.erros = append(v.erros, fmt.Sprintf("pag/detPag[%d]/tPag: NFC-e nao aceita 90 (sem pagamento)", i+1))
+		}
+	}
+
+	supl := doc.InfNFeSupl
+	if supl == nil {
+		v.erros = append(v.erros, "infNFeSupl: obrigatorio na NFC-e")
+		return
+	}
+	v.texto("infNFeSupl/qrCode", supl.QRCode.Texto, 100, 600)
+	v.texto("infNFeSupl/urlChave", supl.URLChave, 21, 85)
+}
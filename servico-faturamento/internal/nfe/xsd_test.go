@@ -0,0 +1,153 @@
+package nfe_test
+
+import (
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/nfe"
+)
+
+// Pacotes de liberação oficiais, sem alteração. nfe_v4.00.xsd é a raiz que importa
+// o leiaute, os tipos básicos e o xmldsig da mesma pasta. O PL_009_V4 não tem o
+// grupo IBSCBS; as notas com IBS/CBS são conferidas no pacote da NT 2025.002.
+var (
+	esquemaNFe       = filepath.Join("testdata", "schemas", "PL_009_V4", "nfe_v4.00.xsd")
+	esquemaNFeIBSCBS = filepath.Join("testdata", "schemas", "NT2025_002", "nfe_v4.00.xsd")
+)
+
+// exigirEsquema falha o teste sem o pacote oficial: os XSDs fazem parte do
+// repositório e a ausência deles não pode passar como sucesso
+func exigirEsquema(t *testing.T, esquema string) {
+	t.Helper()
+	if _, err := os.Stat(esquema); err != nil {
+		t.Fatalf("XSDs oficiais ausentes em %s (ver o README.md da pasta): %v", filepath.Dir(esquema), err)
+	}
+}
+
+// validarXSD confere o documento contra os XSDs oficiais com o xmllint (libxml2)
+func validarXSD(t *testing.T, esquema string, documento []byte) {
+	t.Helper()
+	exigirEsquema(t, esquema)
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		t.Fatal("xmllint nao instalado (pacote libxml2-utils)")
+	}
+
+	arquivo := filepath.Join(t.TempDir(), "nfe.xml")
+	if err := os.WriteFile(arquivo, documento, 0o600); err != nil {
+		t.Fatalf("falha ao gravar XML: %v", err)
+	}
+	saida, err := exec.Command(xmllint, "--noout", "--nonet", "--schema", esquema, arquivo).CombinedOutput()
+	if err != nil {
+		t.Fatalf("XML fora do XSD oficial: %v\n%s", err, saida)
+	}
+}
+
+// gerarAssinado monta, serializa e assina a NF-e da nota com o certificado de teste;
+// o Signature é obrigatório no TNFe
+func gerarAssinado(t *testing.T, nota dominio.NotaFiscal) []byte {
+	t.Helper()
+	dados, err := os.ReadFile(filepath.Join("..", "assinatura", "testdata", "certificado-teste.pfx"))
+	if err != nil {
+		t.Fatalf("falha ao ler certificado de teste: %v", err)
+	}
+	certificado, err := assinatura.CarregarPFX(dados, "1234")
+	if err != nil {
+		t.Fatalf("falha ao carregar certificado de teste: %v", err)
+	}
+
+	doc, err := nfe.Gerar(nota, configuracaoTeste())
+	if err != nil {
+		t.Fatalf("Gerar() erro = %v", err)
+	}
+	xmlNFe, err := nfe.Serializar(doc)
+	if err != nil {
+		t.Fatalf("Serializar() erro = %v", err)
+	}
+	assinado, err := certificado.Assinar(xmlNFe, "infNFe")
+	if err != nil {
+		t.Fatalf("Assinar() erro = %v", err)
+	}
+	return assinado
+}
+
+func TestSerializarContraXSD(t *testing.T) {
+	t.Run("deve gerar NF-e assinada valida no XSD oficial", func(t *testing.T) {
+		validarXSD(t, esquemaNFe, gerarAssinado(t, notaFechadaTeste(t)))
+	})
+
+	t.Run("deve gerar NF-e com IBS/CBS valida no XSD da NT 2025.002", func(t *testing.T) {
+		nota := notaFechadaTeste(t)
+		nota.Itens[0].Tributos.CSTIBSCBS = dominio.CSTIBSCBSPadrao
+		nota.Itens[0].Tributos.CClassTrib = "000001"
+		nota.Itens[0].Tributos.VBCIBSCBS = dominio.MustParseDecimal("21.00")
+		nota.Itens[0].Tributos.PIBSUF = dominio.MustParseDecimal("0.1")
+		nota.Itens[0].Tributos.VIBSUF = dominio.MustParseDecimal("0.02")
+		nota.Itens[0].Tributos.PCBS = dominio.MustParseDecimal("0.9")
+		nota.Itens[0].Tributos.VCBS = dominio.MustParseDecimal("0.19")
+
+		validarXSD(t, esquemaNFeIBSCBS, gerarAssinado(t, nota))
+	})
+}
+
+// esquemaXSD lê só o necessário dos XSDs: os tipos simples e o pattern de cada um
+type esquemaXSD struct {
+	Tipos []struct {
+		Nome     string `xml:"name,attr"`
+		Patterns []struct {
+			Valor string `xml:"value,attr"`
+		} `xml:"restriction>pattern"`
+	} `xml:"simpleType"`
+}
+
+// patternsXSD reúne os patterns dos tipos simples nomeados de todos os XSDs da pasta
+func patternsXSD(t *testing.T, pasta string) map[string]string {
+	t.Helper()
+	arquivos, err := filepath.Glob(filepath.Join(pasta, "*.xsd"))
+	if err != nil || len(arquivos) == 0 {
+		t.Fatalf("XSDs oficiais ausentes em %s (ver o README.md da pasta)", pasta)
+	}
+	patterns := map[string]string{}
+	for _, arquivo := range arquivos {
+		conteudo, err := os.ReadFile(arquivo)
+		if err != nil {
+			t.Fatalf("falha ao ler %s: %v", arquivo, err)
+		}
+		var esquema esquemaXSD
+		if err := xml.Unmarshal(conteudo, &esquema); err != nil {
+			t.Fatalf("falha ao interpretar %s: %v", arquivo, err)
+		}
+		for _, tipo := range esquema.Tipos {
+			if tipo.Nome != "" && len(tipo.Patterns) == 1 {
+				patterns[tipo.Nome] = tipo.Patterns[0].Valor
+			}
+		}
+	}
+	return patterns
+}
+
+func TestPadroesContraXSD(t *testing.T) {
+	t.Run("deve copiar os patterns dos tipos simples sem alteracao", func(t *testing.T) {
+		exigirEsquema(t, esquemaNFe)
+		patterns := patternsXSD(t, filepath.Dir(esquemaNFe))
+
+		for tipo, re := range nfe.TiposXSD {
+			oficial, ok := patterns[tipo]
+			if !ok {
+				t.Errorf("%s: tipo sem pattern no PL_009_V4", tipo)
+				continue
+			}
+			// Os patterns do XSD são ancorados implicitamente; a cópia usa ^(...)$
+			copia := strings.TrimSuffix(strings.TrimPrefix(re.String(), "^"), "$")
+			if copia != oficial && copia != "("+oficial+")" {
+				t.Errorf("%s: validador usa %s, XSD define %s", tipo, re, oficial)
+			}
+		}
+	})
+}
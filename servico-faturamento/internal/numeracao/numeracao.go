@@ -0,0 +1,294 @@
+package numeracao
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrSerieInvalida indica modelo ou série fora do leiaute
+	ErrSerieInvalida = errors.New("modelo ou serie invalidos")
+	// ErrNumeroInvalido indica número importado fora do intervalo do nNF
+	ErrNumeroInvalido = errors.New("numero deve conter apenas digitos entre 1 e 999999999")
+	// ErrNumeracaoEsgotada indica série que já emitiu o número 999999999
+	ErrNumeracaoEsgotada = errors.New("numeracao da serie esgotada")
+	// ErrNumeroInutilizado indica número importado que está em faixa inutilizada
+	ErrNumeroInutilizado = errors.New("numero pertence a faixa inutilizada")
+	// ErrFaixaJaInutilizada indica sobreposição com inutilização já registrada
+	ErrFaixaJaInutilizada = errors.New("faixa sobrepoe inutilizacao ja registrada")
+	// ErrFaixaComNotas indica faixa que contém números já usados por notas
+	ErrFaixaComNotas = errors.New("faixa contem numeros ja utilizados por notas")
+)
+
+// PrazoNotaAberta é o tempo sem fechamento depois do qual a nota aberta é dada como
+// abandonada: o número reservado entra nas lacunas e pode ser inutilizado antes do
+// prazo da SEFAZ (dia 10 do mês seguinte)
+const PrazoNotaAberta = 72 * time.Hour
+
+// Serie identifica uma sequência de numeração independente
+type Serie struct {
+	CNPJEmitente string
+	Modelo       string
+	Serie        int
+}
+
+func (s Serie) validar() error {
+	if (s.Modelo != "55" && s.Modelo != "65") || s.Serie < 0 || s.Serie > 999 {
+		return fmt.Errorf("%w: modelo %q serie %d", ErrSerieInvalida, s.Modelo, s.Serie)
+	}
+	return nil
+}
+
+// Proximo reserva o próximo número da série. Deve ser chamado na mesma transação
+// que grava a nota: a trava na linha da sequência serializa emissões concorrentes
+// (API e Lambda) e o rollback devolve o número, mantendo a série sem lacunas.
+func Proximo(tx *gorm.DB, s Serie) (int64, error) {
+	seq, err := travar(tx, s)
+	if err != nil {
+		return 0, err
+	}
+
+	// Números inutilizados são pulados; a faixa encontrada pode encostar em outra
+	numero := seq.UltimoNumero + 1
+	for numero <= dominio.NumeroMaximoNF {
+		faixa, err := inutilizacaoQueContem(tx, s, numero)
+		if err != nil {
+			return 0, err
+		}
+		if faixa == nil {
+			break
+		}
+		numero = faixa.NumeroFinal + 1
+	}
+
+	if numero > dominio.NumeroMaximoNF {
+		return 0, fmt.Errorf("%w: modelo %s serie %d", ErrNumeracaoEsgotada, s.Modelo, s.Serie)
+	}
+
+	if err := atualizar(tx, s, numero); err != nil {
+		return 0, err
+	}
+	return numero, nil
+}
+
+// RegistrarImportado valida o número de uma nota importada e avança a sequência
+// quando ele passa do último emitido, para que a numeração própria continue depois dele
+func RegistrarImportado(tx *gorm.DB, s Serie, numero string) (int64, error) {
+	n, err := ParseNumero(numero)
+	if err != nil {
+		return 0, err
+	}
+
+	seq, err := travar(tx, s)
+	if err != nil {
+		return 0, err
+	}
+
+	faixa, err := inutilizacaoQueContem(tx, s, n)
+	if err != nil {
+		return 0, err
+	}
+	if faixa != nil {
+		return 0, fmt.Errorf("%w: %d a %d", ErrNumeroInutilizado, faixa.NumeroInicial, faixa.NumeroFinal)
+	}
+
+	if n > seq.UltimoNumero {
+		if err := atualizar(tx, s, n); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// Inutilizar grava a faixa inutilizada da série. A sequência fica travada durante a
+// verificação, então nenhuma nota pode receber um número da faixa em paralelo. As
+// notas da faixa que não consumiram o número (ver notaInutilizavel) são canceladas,
+// para que não sejam fechadas nem reenviadas com um número inutilizado.
+func Inutilizar(tx *gorm.DB, inut *dominio.Inutilizacao, agora time.Time) error {
+	if err := inut.Validar(); err != nil {
+		return err
+	}
+	s := Serie{CNPJEmitente: inut.CNPJEmitente, Modelo: inut.Modelo, Serie: inut.Serie}
+	if _, err := travar(tx, s); err != nil {
+		return err
+	}
+
+	var sobrepostas int64
+	if err := porSerie(tx.Model(&dominio.Inutilizacao{}), s).
+		Where("numero_inicial <= ? AND numero_final >= ?", inut.NumeroFinal, inut.NumeroInicial).
+		Count(&sobrepostas).Error; err != nil {
+		return fmt.Errorf("falha ao verificar inutilizacoes: %w", err)
+	}
+	if sobrepostas > 0 {
+		return ErrFaixaJaInutilizada
+	}
+
+	var usadas int64
+	if err := porSerie(tx.Model(&dominio.NotaFiscal{}), s).
+		Where(numeroDaNota+" BETWEEN ? AND ?", inut.NumeroInicial, inut.NumeroFinal).
+		Where("NOT "+notaInutilizavel, condicoesInutilizavel(agora)).
+		Count(&usadas).Error; err != nil {
+		return fmt.Errorf("falha ao verificar notas da faixa: %w", err)
+	}
+	if usadas > 0 {
+		return fmt.Errorf("%w: %d nota(s)", ErrFaixaComNotas, usadas)
+	}
+
+	if err := tx.Create(inut).Error; err != nil {
+		return err
+	}
+
+	justificativa := fmt.Sprintf("Numero inutilizado (inutilizacao %s)", inut.ID)
+	if err := porSerie(tx.Model(&dominio.NotaFiscal{}), s).
+		Where(numeroDaNota+" BETWEEN ? AND ?", inut.NumeroInicial, inut.NumeroFinal).
+		Where("status <> ?", dominio.StatusNotaCancelada).
+		Updates(map[string]interface{}{
+			"status":                     dominio.StatusNotaCancelada,
+			"data_cancelamento":          agora,
+			"justificativa_cancelamento": justificativa,
+		}).Error; err != nil {
+		return fmt.Errorf("falha ao cancelar notas da faixa: %w", err)
+	}
+	return nil
+}
+
+// Lacunas lista os números até o último alocado da série que não pertencem a nenhuma
+// nota nem a inutilizações registradas, ou seja, os que ainda precisam ser inutilizados.
+// Notas que não consumiram o número (ver notaInutilizavel) entram nas lacunas.
+func Lacunas(db *gorm.DB, s Serie, agora time.Time) ([]dominio.FaixaNumeracao, error) {
+	if err := s.validar(); err != nil {
+		return nil, err
+	}
+
+	var seq dominio.SequenciaNumeracao
+	err := db.Where("cnpj_emitente = ? AND modelo = ? AND serie = ?", s.CNPJEmitente, s.Modelo, s.Serie).First(&seq).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return []dominio.FaixaNumeracao{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar sequencia de numeracao: %w", err)
+	}
+
+	parametros := condicoesInutilizavel(agora)
+	parametros["cnpj"], parametros["modelo"], parametros["serie"] = s.CNPJEmitente, s.Modelo, s.Serie
+
+	var ocupadas []dominio.FaixaNumeracao
+	err = db.Raw(`SELECT n AS inicio, n AS fim FROM (
+			SELECT `+numeroDaNota+` AS n FROM notas_fiscais
+			WHERE cnpj_emitente = @cnpj AND modelo = @modelo AND serie = @serie AND NOT `+notaInutilizavel+`
+		) notas WHERE n IS NOT NULL
+		UNION ALL
+		SELECT numero_inicial, numero_final FROM inutilizacoes
+		WHERE cnpj_emitente = @cnpj AND modelo = @modelo AND serie = @serie
+		ORDER BY inicio, fim`, parametros).
+		Scan(&ocupadas).Error
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar numeros utilizados: %w", err)
+	}
+
+	return dominio.CalcularLacunas(ocupadas, seq.UltimoNumero), nil
+}
+
+// numeroDaNota converte o número da nota em nNF; números fora do padrão (notas
+// antigas como "NF-001") viram NULL em vez de abortar a consulta. TRIM com a lista
+// de dígitos é o btrim do PostgreSQL e o trim do SQLite.
+const numeroDaNota = `(CASE WHEN LENGTH(numero) BETWEEN 1 AND 9 AND TRIM(numero, '0123456789') = '' THEN CAST(numero AS BIGINT) END)`
+
+// notaInutilizavel marca as notas cujo número não foi consumido na SEFAZ e precisa
+// ser inutilizado: rejeitadas, canceladas sem protocolo de autorização nem EPEC
+// (cancelamento local antes da transmissão) e abertas há mais de PrazoNotaAberta
+const notaInutilizavel = `(status = @rejeitada
+	OR (status = @cancelada AND protocolo_autorizacao IS NULL AND protocolo_epec IS NULL)
+	OR (status = @aberta AND data_criacao < @abertaAte))`
+
+func condicoesInutilizavel(agora time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"rejeitada": dominio.StatusNotaRejeitada,
+		"cancelada": dominio.StatusNotaCancelada,
+		"aberta":    dominio.StatusNotaAberta,
+		"abertaAte": agora.Add(-PrazoNotaAberta),
+	}
+}
+
+func porSerie(q *gorm.DB, s Serie) *gorm.DB {
+	return q.Where("cnpj_emitente = ? AND modelo = ? AND serie = ?", s.CNPJEmitente, s.Modelo, s.Serie)
+}
+
+// inutilizacaoQueContem devolve a inutilização da série que cobre o número, ou nil
+func inutilizacaoQueContem(tx *gorm.DB, s Serie, numero int64) (*dominio.Inutilizacao, error) {
+	var faixas []dominio.Inutilizacao
+	if err := porSerie(tx.Select("numero_inicial", "numero_final"), s).
+		Where("numero_inicial <= ? AND numero_final >= ?", numero, numero).
+		Limit(1).Find(&faixas).Error; err != nil {
+		return nil, fmt.Errorf("falha ao consultar inutilizacoes: %w", err)
+	}
+	if len(faixas) == 0 {
+		return nil, nil
+	}
+	return &faixas[0], nil
+}
+
+// ParseNumero converte o número textual da nota em nNF
+func ParseNumero(numero string) (int64, error) {
+	if numero == "" || len(numero) > 9 {
+		return 0, ErrNumeroInvalido
+	}
+	for _, r := range numero {
+		if r < '0' || r > '9' {
+			return 0, ErrNumeroInvalido
+		}
+	}
+	n, _ := strconv.ParseInt(numero, 10, 64)
+	if n < 1 {
+		return 0, ErrNumeroInvalido
+	}
+	return n, nil
+}
+
+// travar garante a existência da linha da série e a bloqueia até o fim da transação
+func travar(tx *gorm.DB, s Serie) (dominio.SequenciaNumeracao, error) {
+	var seq dominio.SequenciaNumeracao
+	if err := s.validar(); err != nil {
+		return seq, err
+	}
+
+	// ON CONFLICT DO NOTHING: a primeira emissão da série cria a linha sem disputar
+	// com outra transação que esteja fazendo o mesmo
+	nova := dominio.SequenciaNumeracao{
+		CNPJEmitente:    s.CNPJEmitente,
+		Modelo:          s.Modelo,
+		Serie:           s.Serie,
+		DataAtualizacao: time.Now(),
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&nova).Error; err != nil {
+		return seq, fmt.Errorf("falha ao criar sequencia de numeracao: %w", err)
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("cnpj_emitente = ? AND modelo = ? AND serie = ?", s.CNPJEmitente, s.Modelo, s.Serie).
+		First(&seq).Error
+	if err != nil {
+		return seq, fmt.Errorf("falha ao travar sequencia de numeracao: %w", err)
+	}
+	return seq, nil
+}
+
+func atualizar(tx *gorm.DB, s Serie, ultimo int64) error {
+	err := tx.Model(&dominio.SequenciaNumeracao{}).
+		Where("cnpj_emitente = ? AND modelo = ? AND serie = ?", s.CNPJEmitente, s.Modelo, s.Serie).
+		Updates(map[string]interface{}{
+			"ultimo_numero":    ultimo,
+			"data_atualizacao": time.Now(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("falha ao atualizar sequencia de numeracao: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package numeracao_test
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/numeracao"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var serieTeste = numeracao.Serie{CNPJEmitente: "11222333000181", Modelo: "55", Serie: 1}
+
+func bancoTeste(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "faturamento.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("falha ao abrir banco de teste: %v", err)
+	}
+	if err := db.AutoMigrate(&dominio.SequenciaNumeracao{}, &dominio.Inutilizacao{}, &dominio.NotaFiscal{}); err != nil {
+		t.Fatalf("falha ao criar tabelas: %v", err)
+	}
+	return db
+}
+
+// emitir reserva o próximo número da série e grava a nota com o status informado
+func emitir(t *testing.T, db *gorm.DB, status string, criacao time.Time) dominio.NotaFiscal {
+	t.Helper()
+	var nota dominio.NotaFiscal
+	err := db.Transaction(func(tx *gorm.DB) error {
+		numero, err := numeracao.Proximo(tx, serieTeste)
+		if err != nil {
+			return err
+		}
+		nota = dominio.NotaFiscal{
+			Numero:       strconv.FormatInt(numero, 10),
+			CNPJEmitente: serieTeste.CNPJEmitente,
+			Modelo:       serieTeste.Modelo,
+			Serie:        serieTeste.Serie,
+			Status:       status,
+			DataCriacao:  criacao,
+		}
+		return tx.Create(&nota).Error
+	})
+	if err != nil {
+		t.Fatalf("falha ao emitir nota: %v", err)
+	}
+	return nota
+}
+
+func inutilizar(db *gorm.DB, inicio, fim int64, agora time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		return numeracao.Inutilizar(tx, &dominio.Inutilizacao{
+			CNPJEmitente:  serieTeste.CNPJEmitente,
+			Modelo:        serieTeste.Modelo,
+			Serie:         serieTeste.Serie,
+			NumeroInicial: inicio,
+			NumeroFinal:   fim,
+			Justificativa: "Falha no sistema emissor durante a transmissao",
+			XML:           "<retInutNFe/>",
+		}, agora)
+	})
+}
+
+func TestProximo(t *testing.T) {
+	agora := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+
+	t.Run("deve numerar a serie em sequencia", func(t *testing.T) {
+		db := bancoTeste(t)
+		for esperado := 1; esperado <= 3; esperado++ {
+			if nota := emitir(t, db, dominio.StatusNotaAutorizada, agora); nota.Numero != strconv.Itoa(esperado) {
+				t.Errorf("esperava numero %d, obteve %s", esperado, nota.Numero)
+			}
+		}
+	})
+
+	t.Run("deve pular faixas inutilizadas", func(t *testing.T) {
+		db := bancoTeste(t)
+		emitir(t, db, dominio.StatusNotaAutorizada, agora)
+		if err := inutilizar(db, 2, 4, agora); err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if err := inutilizar(db, 5, 5, agora); err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if nota := emitir(t, db, dominio.StatusNotaAutorizada, agora); nota.Numero != "6" {
+			t.Errorf("esperava numero 6, obteve %s", nota.Numero)
+		}
+	})
+
+	t.Run("deve recusar serie esgotada", func(t *testing.T) {
+		db := bancoTeste(t)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if _, err := numeracao.RegistrarImportado(tx, serieTeste, "999999999"); err != nil {
+				return err
+			}
+			_, err := numeracao.Proximo(tx, serieTeste)
+			return err
+		})
+		if !errors.Is(err, numeracao.ErrNumeracaoEsgotada) {
+			t.Errorf("esperava ErrNumeracaoEsgotada, obteve %v", err)
+		}
+	})
+}
+
+func TestInutilizar(t *testing.T) {
+	agora := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	antiga := agora.Add(-numeracao.PrazoNotaAberta - time.Hour)
+
+	t.Run("deve recusar faixa com nota autorizada ou aberta dentro do prazo", func(t *testing.T) {
+		db := bancoTeste(t)
+		emitir(t, db, dominio.StatusNotaAutorizada, agora)
+		emitir(t, db, dominio.StatusNotaAberta, agora.Add(-time.Hour))
+		for _, numero := range []int64{1, 2} {
+			if err := inutilizar(db, numero, numero, agora); !errors.Is(err, numeracao.ErrFaixaComNotas) {
+				t.Errorf("numero %d: esperava ErrFaixaComNotas, obteve %v", numero, err)
+			}
+		}
+	})
+
+	t.Run("deve recusar faixa com nota cancelada depois de autorizada", func(t *testing.T) {
+		db := bancoTeste(t)
+		nota := emitir(t, db, dominio.StatusNotaCancelada, agora)
+		if err := db.Model(&nota).Update("protocolo_autorizacao", "135250000000001").Error; err != nil {
+			t.Fatalf("falha ao gravar protocolo: %v", err)
+		}
+		if err := inutilizar(db, 1, 1, agora); !errors.Is(err, numeracao.ErrFaixaComNotas) {
+			t.Errorf("esperava ErrFaixaComNotas, obteve %v", err)
+		}
+	})
+
+	t.Run("deve inutilizar numeros de notas rejeitadas, canceladas sem protocolo e abertas abandonadas", func(t *testing.T) {
+		db := bancoTeste(t)
+		rejeitada := emitir(t, db, dominio.StatusNotaRejeitada, agora)
+		cancelada := emitir(t, db, dominio.StatusNotaCancelada, agora)
+		abandonada := emitir(t, db, dominio.StatusNotaAberta, antiga)
+
+		if err := inutilizar(db, 1, 3, agora); err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+
+		// A nota cujo número foi inutilizado não pode mais ser fechada nem reenviada
+		for _, nota := range []dominio.NotaFiscal{rejeitada, cancelada, abandonada} {
+			var atual dominio.NotaFiscal
+			if err := db.First(&atual, "id = ?", nota.ID).Error; err != nil {
+				t.Fatalf("falha ao carregar nota: %v", err)
+			}
+			if atual.Status != dominio.StatusNotaCancelada {
+				t.Errorf("nota %s: esperava status CANCELADA, obteve %s", atual.Numero, atual.Status)
+			}
+		}
+	})
+
+	t.Run("deve recusar faixa sobreposta a inutilizacao registrada", func(t *testing.T) {
+		db := bancoTeste(t)
+		if err := inutilizar(db, 10, 20, agora); err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if err := inutilizar(db, 15, 25, agora); !errors.Is(err, numeracao.ErrFaixaJaInutilizada) {
+			t.Errorf("esperava ErrFaixaJaInutilizada, obteve %v", err)
+		}
+	})
+}
+
+func TestLacunas(t *testing.T) {
+	agora := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	antiga := agora.Add(-numeracao.PrazoNotaAberta - time.Hour)
+
+	t.Run("deve listar numeros sem nota e de notas que nao consumiram o numero", func(t *testing.T) {
+		db := bancoTeste(t)
+		emitir(t, db, dominio.StatusNotaAutorizada, agora) // 1
+		emitir(t, db, dominio.StatusNotaRejeitada, agora)  // 2
+		emitir(t, db, dominio.StatusNotaCancelada, agora)  // 3
+		emitir(t, db, dominio.StatusNotaAberta, antiga)    // 4
+		emitir(t, db, dominio.StatusNotaAberta, agora)     // 5
+		emitir(t, db, dominio.StatusNotaAutorizada, agora) // 6
+		if err := db.Model(&dominio.SequenciaNumeracao{}).Where("1 = 1").Update("ultimo_numero", 8).Error; err != nil {
+			t.Fatalf("falha ao avancar sequencia: %v", err)
+		}
+
+		lacunas, err := numeracao.Lacunas(db, serieTeste, agora)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		esperado := []dominio.FaixaNumeracao{{Inicio: 2, Fim: 4, Quantidade: 3}, {Inicio: 7, Fim: 8, Quantidade: 2}}
+		if !reflect.DeepEqual(lacunas, esperado) {
+			t.Errorf("esperava %+v, obteve %+v", esperado, lacunas)
+		}
+	})
+
+	t.Run("deve omitir faixas ja inutilizadas", func(t *testing.T) {
+		db := bancoTeste(t)
+		emitir(t, db, dominio.StatusNotaAutorizada, agora)
+		emitir(t, db, dominio.StatusNotaRejeitada, agora)
+		emitir(t, db, dominio.StatusNotaAutorizada, agora)
+		if err := inutilizar(db, 2, 2, agora); err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+
+		lacunas, err := numeracao.Lacunas(db, serieTeste, agora)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		if len(lacunas) != 0 {
+			t.Errorf("esperava nenhuma lacuna, obteve %+v", lacunas)
+		}
+	})
+
+	t.Run("deve devolver lista vazia para serie sem emissao", func(t *testing.T) {
+		lacunas, err := numeracao.Lacunas(bancoTeste(t), serieTeste, agora)
+		if err != nil || len(lacunas) != 0 {
+			t.Errorf("esperava lista vazia, obteve %+v, %v", lacunas, err)
+		}
+	})
+}
+
+func TestParseNumero(t *testing.T) {
+	t.Run("deve aceitar numeros de 1 a 999999999", func(t *testing.T) {
+		casos := map[string]int64{"1": 1, "000123": 123, "999999999": 999999999}
+		for entrada, esperado := range casos {
+			numero, err := numeracao.ParseNumero(entrada)
+			if err != nil {
+				t.Fatalf("esperava nil para %q, obteve erro: %v", entrada, err)
+			}
+			if numero != esperado {
+				t.Errorf("esperava %d para %q, obteve %d", esperado, entrada, numero)
+			}
+		}
+	})
+
+	t.Run("deve rejeitar numeros fora do nNF", func(t *testing.T) {
+		for _, entrada := range []string{"", "0", "000", "1000000000", "NF-001", "-5", "12 3"} {
+			if _, err := numeracao.ParseNumero(entrada); !errors.Is(err, numeracao.ErrNumeroInvalido) {
+				t.Errorf("esperava ErrNumeroInvalido para %q, obteve %v", entrada, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package publicador
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// alertaLatenciaConfirmacao é a latência de ack acima da qual a publicação é logada
+// como aviso: broker sob pressão (memória, disco, fila em flow control)
+const alertaLatenciaConfirmacao = time.Second
+
+var (
+	// ErrMensagemNaoRoteada indica que nenhuma fila está ligada à routing key do
+	// evento; o broker devolveu a mensagem (mandatory) em vez de descartá-la
+	ErrMensagemNaoRoteada = errors.New("mensagem sem fila para a routing key")
+	// ErrMensagemRecusada indica nack do broker
+	ErrMensagemRecusada = errors.New("broker recusou a mensagem (nack)")
+)
+
+// canalConfirmado publica em modo confirm: a mensagem só conta como entregue depois
+// do ack do broker e sem basic.return
+type canalConfirmado struct {
+	ch         *amqp.Channel
+	devolvidas chan amqp.Return
+}
+
+// confirmarCanal coloca o channel em modo confirm e registra o NotifyReturn
+func confirmarCanal(ch *amqp.Channel) (*canalConfirmado, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("falha ao ativar publisher confirms: %w", err)
+	}
+	return &canalConfirmado{ch: ch, devolvidas: ch.NotifyReturn(make(chan amqp.Return, 10))}, nil
+}
+
+// publicar publica com mandatory e espera o ack até o prazo de ctx, devolvendo a
+// latência da confirmação. Uma mensagem por vez: o basic.return de uma mensagem
+// não roteada chega antes do ack dela, então basta olhar as devolvidas depois do ack.
+func (c *canalConfirmado) publicar(ctx context.Context, exchange, chave string, msg amqp.Publishing) (time.Duration, error) {
+	c.descartarDevolvidas()
+
+	inicio := time.Now()
+	confirmacao, err := c.ch.PublishWithDeferredConfirmWithContext(ctx, exchange, chave, true, false, msg)
+	if err != nil {
+		return 0, err
+	}
+	ack, err := confirmacao.WaitContext(ctx)
+	latencia := time.Since(inicio)
+	if err != nil {
+		return latencia, fmt.Errorf("sem confirmacao do broker: %w", err)
+	}
+	if !ack {
+		return latencia, ErrMensagemRecusada
+	}
+
+	select {
+	case devolvida, ok := <-c.devolvidas:
+		if ok && devolvida.MessageId == msg.MessageId {
+			return latencia, fmt.Errorf("%w %q: %d %s", ErrMensagemNaoRoteada, chave, devolvida.ReplyCode, devolvida.ReplyText)
+		}
+	default:
+	}
+	return latencia, nil
+}
+
+// descartarDevolvidas esvazia devoluções de publicações anteriores que venceram o
+// prazo antes do ack
+func (c *canalConfirmado) descartarDevolvidas() {
+	for {
+		select {
+		case _, ok := <-c.devolvidas:
+			if !ok {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package publicador
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// CanalOutbox é o canal do NOTIFY disparado pelo trigger de INSERT em eventos_outbox
+const CanalOutbox = "eventos_outbox"
+
+// esperaReconexaoListen é o intervalo até escutar de novo depois de perder a conexão;
+// nesse meio tempo o publicador segue só com a varredura
+const esperaReconexaoListen = 5 * time.Second
+
+var errListenIndisponivel = errors.New("driver do banco sem suporte a LISTEN")
+
+// NotificarOutbox acorda os publicadores que escutam CanalOutbox. Dentro de uma
+// transação a notificação só é entregue no commit, como a do trigger.
+func NotificarOutbox(db *gorm.DB) error {
+	return db.Exec("SELECT pg_notify(?, '')", CanalOutbox).Error
+}
+
+// Escutar mantém uma conexão dedicada em LISTEN no CanalOutbox e sinaliza acordar a
+// cada notificação, sem bloquear: várias notificações seguidas viram uma só
+// varredura. Caindo a conexão, tenta de novo a cada esperaReconexaoListen; a
+// varredura periódica do publicador cobre o intervalo.
+func Escutar(ctx context.Context, db *gorm.DB, acordar chan<- struct{}) {
+	for {
+		err := escutarConexao(ctx, db, acordar)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errListenIndisponivel) {
+			slog.Warn("Publicador outbox sem LISTEN; segue so por varredura", "erro", err)
+			return
+		}
+		slog.Warn("LISTEN do outbox interrompido; publicador segue por varredura ate reconectar",
+			"erro", err, "espera", esperaReconexaoListen)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(esperaReconexaoListen):
+		}
+	}
+}
+
+func escutarConexao(ctx context.Context, db *gorm.DB, acordar chan<- struct{}) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("falha ao obter conexao para LISTEN: %w", err)
+	}
+	defer conn.Close()
+
+	var falha error
+	conn.Raw(func(driverConn any) error {
+		pgConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			falha = errListenIndisponivel
+			return nil
+		}
+		if _, err := pgConn.Conn().Exec(ctx, "LISTEN "+CanalOutbox); err != nil {
+			falha = fmt.Errorf("falha no LISTEN: %w", err)
+			return driver.ErrBadConn
+		}
+		slog.Info("Publicador outbox escutando notificacoes", "canal", CanalOutbox)
+
+		// Eventos gravados antes do LISTEN não notificam mais
+		sinalizar(acordar)
+		for {
+			if _, err := pgConn.Conn().WaitForNotification(ctx); err != nil {
+				falha = err
+				// A sessão fica em LISTEN: a conexão é descartada em vez de voltar ao pool
+				return driver.ErrBadConn
+			}
+			sinalizar(acordar)
+		}
+	})
+	return falha
+}
+
+func sinalizar(acordar chan<- struct{}) {
+	select {
+	case acordar <- struct{}{}:
+	default:
+	}
+}
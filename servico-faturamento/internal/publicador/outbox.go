@@ -2,6 +2,8 @@ package publicador
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -9,82 +11,281 @@ import (
 	"time"
 
 	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/mensageria"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// intervaloVarredura é a espera com o outbox vazio. Com o LISTEN ativo o publicador
+// acorda antes, no commit do evento; a varredura cobre as novas tentativas
+// agendadas, as reservas vencidas e a conexão de LISTEN caída.
+const intervaloVarredura = 2 * time.Second
+
+// DuracaoReserva é quanto um publicador tem para entregar os eventos que reservou,
+// acima do timeout da lambda-outbox (60s); depois disso outro publicador os assume
+const DuracaoReserva = 2 * time.Minute
+
+var (
+	// ErrEventoInvalido marca a falha que nenhuma nova tentativa resolve, como payload
+	// que não é JSON: o evento vai direto para MORTO
+	ErrEventoInvalido = errors.New("evento do outbox impublicavel")
+	// ErrReservaPerdida indica que a reserva venceu e outro publicador assumiu o evento
+	ErrReservaPerdida = errors.New("reserva do evento do outbox vencida")
 )
 
 type PublicadorOutbox struct {
 	DB *gorm.DB
+	// ID identifica a réplica nas reservas de eventos
+	ID string
 }
 
-func IniciarPublicador(db *gorm.DB) error {
-	pub := &PublicadorOutbox{DB: db}
+// IdentificarPublicador gera o identificador gravado nas reservas: host, processo e
+// um sufixo aleatório, para distinguir réplicas e contêineres reaproveitados
+func IdentificarPublicador() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), uuid.NewString()[:8])
+}
 
-	rabbitURL := os.Getenv("RABBITMQ_URL")
+// ReservarPendentes reserva para o publicador até limite eventos prontos para
+// publicação, em ordem de criação. A leitura usa FOR UPDATE SKIP LOCKED, de modo
+// que réplicas concorrentes nunca recebem o mesmo evento, e só devolve o evento
+// mais antigo ainda pendente de cada agregado: o seguinte só é reservado depois
+// que ele é publicado ou fica MORTO, o que preserva a ordem por IdAgregado.
+func ReservarPendentes(db *gorm.DB, publicador string, limite int) ([]dominio.EventoOutbox, error) {
+	var eventos []dominio.EventoOutbox
+	err := db.Transaction(func(tx *gorm.DB) error {
+		agora := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("data_publicacao IS NULL AND status = ?", dominio.StatusOutboxPendente).
+			Where("proxima_tentativa IS NULL OR proxima_tentativa <= ?", agora).
+			Where("reservado_ate IS NULL OR reservado_ate <= ?", agora).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM eventos_outbox anterior
+				WHERE anterior.id_agregado = eventos_outbox.id_agregado
+				  AND anterior.id < eventos_outbox.id
+				  AND anterior.data_publicacao IS NULL
+				  AND anterior.status = ?)`, dominio.StatusOutboxPendente).
+			Order("id").Limit(limite).Find(&eventos).Error
+		if err != nil || len(eventos) == 0 {
+			return err
+		}
 
-	// Se RABBITMQ_URL estiver vazio ou "disabled", pula inicialização (para Lambda/EventBridge)
-	if rabbitURL == "" || rabbitURL == "disabled" {
-		slog.Info("RabbitMQ desabilitado, pulando inicialização do publicador outbox")
-		return nil
+		ate := agora.Add(DuracaoReserva)
+		ids := make([]int64, len(eventos))
+		for i := range eventos {
+			ids[i] = eventos[i].ID
+			eventos[i].ReservadoPor, eventos[i].ReservadoAte = &publicador, &ate
+		}
+		return tx.Model(&dominio.EventoOutbox{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"reservado_por": publicador,
+			"reservado_ate": ate,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return eventos, nil
+}
+
+// atualizarReservado grava o resultado da publicação se a reserva ainda é deste
+// publicador; reserva vencida devolve ErrReservaPerdida
+func atualizarReservado(db *gorm.DB, evt *dominio.EventoOutbox, reserva *string, campos map[string]interface{}) error {
+	consulta := db.Model(&dominio.EventoOutbox{}).Where("id = ?", evt.ID)
+	if reserva != nil {
+		consulta = consulta.Where("reservado_por = ?", *reserva)
+	}
+	campos["reservado_por"], campos["reservado_ate"] = nil, nil
+	resultado := consulta.Updates(campos)
+	if resultado.Error != nil {
+		return resultado.Error
 	}
+	if resultado.RowsAffected == 0 {
+		return ErrReservaPerdida
+	}
+	return nil
+}
 
-	var conn *amqp.Connection
-	var err error
+// RegistrarPublicacao grava o evento como publicado e libera a reserva
+func RegistrarPublicacao(db *gorm.DB, evt *dominio.EventoOutbox) error {
+	reserva := evt.ReservadoPor
+	evt.RegistrarPublicacao(time.Now())
+	return atualizarReservado(db, evt, reserva, map[string]interface{}{
+		"status":            evt.Status,
+		"data_publicacao":   evt.DataPublicacao,
+		"proxima_tentativa": evt.ProximaTentativa,
+	})
+}
 
-	for tentativa := 1; tentativa <= 30; tentativa++ {
-		conn, err = amqp.Dial(rabbitURL)
-		if err == nil {
-			break
-		}
-		slog.Warn("Falha ao conectar RabbitMQ no outbox", "tentativa", tentativa, "erro", err)
-		time.Sleep(3 * time.Second)
+// RegistrarFalha conta a tentativa falha do evento e agenda a próxima; com
+// ErrEventoInvalido ou as tentativas esgotadas o evento fica MORTO
+func RegistrarFalha(db *gorm.DB, evt *dominio.EventoOutbox, falha error) error {
+	reserva := evt.ReservadoPor
+	evt.RegistrarFalha(falha.Error(), errors.Is(falha, ErrEventoInvalido), time.Now())
+	if evt.Status == dominio.StatusOutboxMorto {
+		slog.Error("Evento do outbox MORTO; reenfileire pelo /api/v1/admin/outbox depois de corrigir a causa",
+			"eventoId", evt.ID, "tipoEvento", evt.TipoEvento, "tentativas", evt.Tentativas, "erro", falha)
+	} else {
+		slog.Warn("Falha ao publicar evento do outbox; nova tentativa agendada",
+			"eventoId", evt.ID, "tipoEvento", evt.TipoEvento, "tentativa", evt.Tentativas, "proximaTentativa", evt.ProximaTentativa, "erro", falha)
 	}
+	return atualizarReservado(db, evt, reserva, map[string]interface{}{
+		"status":            evt.Status,
+		"tentativas":        evt.Tentativas,
+		"ultimo_erro":       evt.UltimoErro,
+		"proxima_tentativa": evt.ProximaTentativa,
+		"data_morte":        evt.DataMorte,
+	})
+}
 
+// DevolverFalhaConsumo registra a falha do consumidor que recebeu o evento eventoID
+// e o devolve à publicação com espera crescente, em vez de a mensagem voltar à
+// fila para sempre; esgotadas as tentativas o evento fica MORTO. Evento que não
+// está mais PUBLICADO (já republicado ou reenfileirado) é devolvido sem mudança.
+func DevolverFalhaConsumo(db *gorm.DB, eventoID int64, falha error) (dominio.EventoOutbox, error) {
+	var evt dominio.EventoOutbox
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&evt, "id = ?", eventoID).Error; err != nil {
+			return err
+		}
+		if evt.Status != dominio.StatusOutboxPublicado {
+			return nil
+		}
+		evt.RegistrarFalhaConsumo(falha.Error(), time.Now())
+		return tx.Model(&evt).Updates(map[string]interface{}{
+			"status":            evt.Status,
+			"data_publicacao":   evt.DataPublicacao,
+			"tentativas":        evt.Tentativas,
+			"ultimo_erro":       evt.UltimoErro,
+			"proxima_tentativa": evt.ProximaTentativa,
+			"data_morte":        evt.DataMorte,
+		}).Error
+	})
 	if err != nil {
-		return fmt.Errorf("falha ao conectar RabbitMQ: %w", err)
+		return evt, err
 	}
 
-	ch, err := conn.Channel()
-	if err != nil {
-		return fmt.Errorf("falha ao abrir channel: %w", err)
+	if evt.Status == dominio.StatusOutboxMorto {
+		slog.Error("Evento do outbox MORTO depois de falhas do consumidor; reenfileire pelo /api/v1/admin/outbox depois de corrigir a causa",
+			"eventoId", evt.ID, "tipoEvento", evt.TipoEvento, "tentativas", evt.Tentativas, "erro", falha)
+	} else {
+		slog.Warn("Falha do consumidor; evento devolvido ao outbox",
+			"eventoId", evt.ID, "tipoEvento", evt.TipoEvento, "tentativa", evt.Tentativas, "proximaTentativa", evt.ProximaTentativa, "erro", falha)
 	}
+	return evt, nil
+}
 
-	if err := ch.ExchangeDeclare("faturamento-eventos", "topic", true, false, false, false, nil); err != nil {
-		return fmt.Errorf("falha ao declarar exchange: %w", err)
+// liberarReservas devolve os eventos reservados pelo publicador que não chegaram a
+// ser publicados, para não esperarem a reserva vencer depois da queda do channel
+func liberarReservas(db *gorm.DB, publicador string) error {
+	return db.Model(&dominio.EventoOutbox{}).
+		Where("reservado_por = ? AND data_publicacao IS NULL", publicador).
+		Updates(map[string]interface{}{"reservado_por": nil, "reservado_ate": nil}).Error
+}
+
+// IniciarPublicador publica o outbox no RabbitMQ pela conexão de publicação; sem
+// conexão (RabbitMQ desabilitado) quem publica é a lambda-outbox, no EventBridge
+func IniciarPublicador(ctx context.Context, db *gorm.DB, conexao *mensageria.Conexao) {
+	if conexao == nil {
+		slog.Info("RabbitMQ desabilitado, pulando inicialização do publicador outbox")
+		return
 	}
 
-	slog.Info("Publicador outbox conectado ao RabbitMQ e pronto para publicar")
-	go pub.processar(ch)
-	return nil
+	pub := &PublicadorOutbox{DB: db, ID: IdentificarPublicador()}
+	slog.Info("Publicador outbox iniciado", "publicador", pub.ID, "conexao", conexao.Nome)
+	go pub.processar(ctx, conexao)
 }
 
-func (p *PublicadorOutbox) processar(ch *amqp.Channel) {
-	ctx := context.Background()
+// abrirCanal abre o channel de publicação na conexão vigente, declara o exchange e
+// ativa o modo confirm; é refeito a cada queda do channel ou da conexão
+func abrirCanal(ctx context.Context, conexao *mensageria.Conexao) (*canalConfirmado, error) {
+	ch, err := conexao.Canal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.ExchangeDeclare("faturamento-eventos", "topic", true, false, false, false, nil); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("falha ao declarar exchange: %w", err)
+	}
+	canal, err := confirmarCanal(ch)
+	if err != nil {
+		ch.Close()
+		return nil, err
+	}
+	return canal, nil
+}
+
+func (p *PublicadorOutbox) processar(ctx context.Context, conexao *mensageria.Conexao) {
+	acordar := make(chan struct{}, 1)
+	go Escutar(ctx, p.DB, acordar)
 
 	for {
-		var eventos []dominio.EventoOutbox
-		if err := p.DB.Where("data_publicacao IS NULL").Order("id").Limit(20).Find(&eventos).Error; err != nil {
+		canal, err := abrirCanal(ctx, conexao)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.Error("Falha ao abrir channel do publicador outbox; nova tentativa", "erro", err)
+			time.Sleep(3 * time.Second)
+			continue
+		}
+
+		slog.Info("Publicador outbox conectado ao RabbitMQ e pronto para publicar", "publicador", p.ID)
+		p.publicarEnquantoAberto(ctx, canal, acordar)
+		canal.ch.Close()
+		if ctx.Err() != nil {
+			return
+		}
+
+		// O evento em publicação na queda pode ter chegado ao broker; volta a ser
+		// publicado e o consumidor o recebe de novo com o mesmo MessageId
+		if err := liberarReservas(p.DB, p.ID); err != nil {
+			slog.Error("Falha ao liberar reservas do outbox; os eventos esperam a reserva vencer", "publicador", p.ID, "erro", err)
+		}
+		slog.Warn("Channel do publicador outbox fechado; reabrindo", "publicador", p.ID)
+	}
+}
+
+// publicarEnquantoAberto publica os eventos reservados até o channel fechar ou o
+// contexto ser cancelado. Falha por channel fechado não conta tentativa do evento.
+func (p *PublicadorOutbox) publicarEnquantoAberto(ctx context.Context, canal *canalConfirmado, acordar <-chan struct{}) {
+	for !canal.ch.IsClosed() && ctx.Err() == nil {
+		eventos, err := ReservarPendentes(p.DB, p.ID, 20)
+		if err != nil {
 			slog.Error("Erro ao carregar eventos pendentes do outbox", "erro", err)
 			time.Sleep(3 * time.Second)
 			continue
 		}
 
 		if len(eventos) == 0 {
-			time.Sleep(2 * time.Second)
+			select {
+			case <-ctx.Done():
+			case <-acordar:
+			case <-time.After(intervaloVarredura):
+			}
 			continue
 		}
 
 		for _, evt := range eventos {
 			msgID := strconv.FormatInt(evt.ID, 10)
 
+			if !json.Valid([]byte(evt.Payload)) {
+				if err := RegistrarFalha(p.DB, &evt, fmt.Errorf("%w: payload nao e JSON valido", ErrEventoInvalido)); err != nil {
+					slog.Error("Falha ao registrar tentativa do evento do outbox", "eventoId", evt.ID, "erro", err)
+				}
+				continue
+			}
+
+			// Só conta como publicado com o ack do broker e sem basic.return; sem ack
+			// no prazo o evento volta a ser tentado, e o consumidor pode recebê-lo duas
+			// vezes (MessageId é o ID do evento)
 			publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-			err := ch.PublishWithContext(
+			latencia, err := canal.publicar(
 				publishCtx,
 				"faturamento-eventos",
 				evt.TipoEvento,
-				false,
-				false,
 				amqp.Publishing{
 					MessageId:   msgID,
 					ContentType: "application/json",
@@ -95,18 +296,24 @@ func (p *PublicadorOutbox) processar(ch *amqp.Channel) {
 			cancel()
 
 			if err != nil {
-				slog.Error("Erro ao publicar evento do outbox", "eventoId", evt.ID, "tipoEvento", evt.TipoEvento, "erro", err)
+				if canal.ch.IsClosed() || ctx.Err() != nil {
+					return
+				}
+				if err := RegistrarFalha(p.DB, &evt, err); err != nil {
+					slog.Error("Falha ao registrar tentativa do evento do outbox", "eventoId", evt.ID, "erro", err)
+				}
 				continue
 			}
+			if latencia > alertaLatenciaConfirmacao {
+				slog.Warn("Confirmacao lenta do RabbitMQ", "eventoId", evt.ID, "latenciaConfirmacaoMs", latencia.Milliseconds())
+			}
 
-			if err := p.DB.Model(&dominio.EventoOutbox{}).
-				Where("id = ?", evt.ID).
-				Update("data_publicacao", time.Now()).Error; err != nil {
+			if err := RegistrarPublicacao(p.DB, &evt); err != nil {
 				slog.Error("Evento publicado mas falhou ao atualizar data_publicacao", "eventoId", evt.ID, "erro", err)
 				continue
 			}
 
-			slog.Info("Evento publicado com sucesso", "eventoId", evt.ID, "tipoEvento", evt.TipoEvento)
+			slog.Info("Evento publicado com sucesso", "eventoId", evt.ID, "tipoEvento", evt.TipoEvento, "latenciaConfirmacaoMs", latencia.Milliseconds())
 		}
 	}
 }
@@ -0,0 +1,436 @@
+// Package qrcode gera o símbolo QR Code (ISO/IEC 18004) impresso no DANFE NFC-e.
+// Só implementa o que a consulta da NFC-e usa: modo byte, nível de correção M e
+// versões 1 a 20, suficientes para a URL do QR Code com folga.
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTextoLongo indica texto que não cabe na maior versão suportada
+var ErrTextoLongo = errors.New("texto excede a capacidade do QR Code")
+
+// versaoMaxima é a maior versão suportada (97x97 módulos, 666 bytes no nível M)
+const versaoMaxima = 20
+
+// blocosM descreve a correção de erros do nível M por versão: codewords de correção
+// por bloco e, nos dois grupos, a quantidade de blocos e de codewords de dados
+var blocosM = [versaoMaxima + 1]struct {
+	correcao, blocos1, dados1, blocos2, dados2 int
+}{
+	1: {10, 1, 16, 0, 0}, 2: {16, 1, 28, 0, 0}, 3: {26, 1, 44, 0, 0}, 4: {18, 2, 32, 0, 0},
+	5: {24, 2, 43, 0, 0}, 6: {16, 4, 27, 0, 0}, 7: {18, 4, 31, 0, 0}, 8: {22, 2, 38, 2, 39},
+	9: {22, 3, 36, 2, 37}, 10: {26, 4, 43, 1, 44}, 11: {30, 1, 50, 4, 51}, 12: {22, 6, 36, 2, 37},
+	13: {22, 8, 37, 1, 38}, 14: {24, 4, 40, 5, 41}, 15: {24, 5, 41, 5, 42}, 16: {28, 7, 45, 3, 46},
+	17: {28, 10, 46, 1, 47}, 18: {26, 9, 43, 4, 44}, 19: {26, 3, 44, 11, 45}, 20: {26, 3, 41, 13, 42},
+}
+
+// alinhamento são as coordenadas dos centros dos padrões de alinhamento por versão
+var alinhamento = [versaoMaxima + 1][]int{
+	2: {6, 18}, 3: {6, 22}, 4: {6, 26}, 5: {6, 30}, 6: {6, 34},
+	7: {6, 22, 38}, 8: {6, 24, 42}, 9: {6, 26, 46}, 10: {6, 28, 50}, 11: {6, 30, 54},
+	12: {6, 32, 58}, 13: {6, 34, 62}, 14: {6, 26, 46, 66}, 15: {6, 26, 48, 70},
+	16: {6, 26, 50, 74}, 17: {6, 30, 54, 78}, 18: {6, 30, 56, 82}, 19: {6, 30, 58, 86},
+	20: {6, 34, 62, 90},
+}
+
+// Simbolo é a matriz de módulos do QR Code, sem a zona de silêncio
+type Simbolo struct {
+	Versao  int
+	Mascara int
+	Tamanho int
+
+	modulos [][]bool
+	funcao  [][]bool // módulos dos padrões fixos, fora da área de dados
+}
+
+// Escuro informa se o módulo da linha e coluna é escuro
+func (s *Simbolo) Escuro(linha, coluna int) bool {
+	return s.modulos[linha][coluna]
+}
+
+// Codificar gera o QR Code do texto na menor versão que o comporta, com a máscara
+// de menor penalidade
+func Codificar(texto string) (*Simbolo, error) {
+	dados := []byte(texto)
+	versao := 1
+	for ; versao <= versaoMaxima; versao++ {
+		if capacidade(versao)*8 >= bitsNecessarios(versao, len(dados)) {
+			break
+		}
+	}
+	if versao > versaoMaxima {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTextoLongo, len(dados))
+	}
+
+	codewords := intercalar(versao, codificarDados(versao, dados))
+
+	var melhor *Simbolo
+	menor := -1
+	for mascara := 0; mascara < 8; mascara++ {
+		s := novoSimbolo(versao)
+		s.posicionarDados(codewords)
+		s.aplicarMascara(mascara)
+		s.desenharFormato(mascara)
+		if p := s.penalidade(); menor < 0 || p < menor {
+			melhor, menor = s, p
+		}
+	}
+	return melhor, nil
+}
+
+// capacidade devolve a quantidade de codewords de dados da versão
+func capacidade(versao int) int {
+	b := blocosM[versao]
+	return b.blocos1*b.dados1 + b.blocos2*b.dados2
+}
+
+// bitsContagem é o tamanho do indicador de quantidade de caracteres no modo byte
+func bitsContagem(versao int) int {
+	if versao <= 9 {
+		return 8
+	}
+	return 16
+}
+
+func bitsNecessarios(versao, n int) int {
+	return 4 + bitsContagem(versao) + 8*n
+}
+
+type escritorBits struct {
+	bytes []byte
+	n     int
+}
+
+func (e *escritorBits) escrever(valor, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if e.n%8 == 0 {
+			e.bytes = append(e.bytes, 0)
+		}
+		if valor>>i&1 == 1 {
+			e.bytes[e.n/8] |= 0x80 >> (e.n % 8)
+		}
+		e.n++
+	}
+}
+
+// codificarDados monta o fluxo de dados: modo byte, quantidade, bytes, terminador
+// e os codewords de preenchimento 0xEC 0x11 até a capacidade da versão
+func codificarDados(versao int, dados []byte) []byte {
+	total := capacidade(versao)
+	e := &escritorBits{}
+	e.escrever(0b0100, 4)
+	e.escrever(len(dados), bitsContagem(versao))
+	for _, b := range dados {
+		e.escrever(int(b), 8)
+	}
+	terminador := total*8 - e.n
+	if terminador > 4 {
+		terminador = 4
+	}
+	e.escrever(0, terminador)
+	if e.n%8 != 0 {
+		e.escrever(0, 8-e.n%8)
+	}
+	for preenchimento := 0; len(e.bytes) < total; preenchimento++ {
+		if preenchimento%2 == 0 {
+			e.bytes = append(e.bytes, 0xEC)
+		} else {
+			e.bytes = append(e.bytes, 0x11)
+		}
+	}
+	return e.bytes
+}
+
+// intercalar divide os dados em blocos, calcula a correção de cada um e intercala
+// os codewords de dados e depois os de correção, bloco a bloco
+func intercalar(versao int, dados []byte) []byte {
+	b := blocosM[versao]
+	gerador := polinomioGerador(b.correcao)
+
+	var blocos, correcoes [][]byte
+	inicio := 0
+	for i := 0; i < b.blocos1+b.blocos2; i++ {
+		tamanho := b.dados1
+		if i >= b.blocos1 {
+			tamanho = b.dados2
+		}
+		bloco := dados[inicio : inicio+tamanho]
+		inicio += tamanho
+		blocos = append(blocos, bloco)
+		correcoes = append(correcoes, restoReedSolomon(bloco, gerador))
+	}
+
+	var saida []byte
+	for i := 0; i < max(b.dados1, b.dados2); i++ {
+		for _, bloco := range blocos {
+			if i < len(bloco) {
+				saida = append(saida, bloco[i])
+			}
+		}
+	}
+	for i := 0; i < b.correcao; i++ {
+		for _, correcao := range correcoes {
+			saida = append(saida, correcao[i])
+		}
+	}
+	return saida
+}
+
+func novoSimbolo(versao int) *Simbolo {
+	tamanho := 17 + 4*versao
+	s := &Simbolo{Versao: versao, Tamanho: tamanho}
+	s.modulos = make([][]bool, tamanho)
+	s.funcao = make([][]bool, tamanho)
+	for i := range s.modulos {
+		s.modulos[i] = make([]bool, tamanho)
+		s.funcao[i] = make([]bool, tamanho)
+	}
+	s.desenharPadroes()
+	return s
+}
+
+func (s *Simbolo) fixar(linha, coluna int, escuro bool) {
+	s.modulos[linha][coluna] = escuro
+	s.funcao[linha][coluna] = true
+}
+
+// desenharPadroes desenha os localizadores, as linhas de sincronismo, os padrões de
+// alinhamento, o módulo escuro e reserva as áreas de formato e versão
+func (s *Simbolo) desenharPadroes() {
+	n := s.Tamanho
+	for i := 0; i < n; i++ {
+		s.fixar(6, i, i%2 == 0)
+		s.fixar(i, 6, i%2 == 0)
+	}
+
+	for _, centro := range [][2]int{{3, 3}, {3, n - 4}, {n - 4, 3}} {
+		for dl := -4; dl <= 4; dl++ {
+			for dc := -4; dc <= 4; dc++ {
+				l, c := centro[0]+dl, centro[1]+dc
+				if l < 0 || l >= n || c < 0 || c >= n {
+					continue
+				}
+				distancia := max(abs(dl), abs(dc))
+				s.fixar(l, c, distancia != 2 && distancia != 4)
+			}
+		}
+	}
+
+	posicoes := alinhamento[s.Versao]
+	for i, l := range posicoes {
+		for j, c := range posicoes {
+			// Os cantos ocupados pelos localizadores não recebem alinhamento
+			if (i == 0 && j == 0) || (i == 0 && j == len(posicoes)-1) || (i == len(posicoes)-1 && j == 0) {
+				continue
+			}
+			for dl := -2; dl <= 2; dl++ {
+				for dc := -2; dc <= 2; dc++ {
+					s.fixar(l+dl, c+dc, max(abs(dl), abs(dc)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserva do formato; o conteúdo é gravado depois da escolha da máscara
+	s.desenharFormato(0)
+
+	if s.Versao >= 7 {
+		resto := s.Versao
+		for i := 0; i < 12; i++ {
+			resto = resto<<1 ^ (resto>>11)*0x1F25
+		}
+		bits := s.Versao<<12 | resto
+		for i := 0; i < 18; i++ {
+			escuro := bits>>i&1 == 1
+			a, b := n-11+i%3, i/3
+			s.fixar(b, a, escuro)
+			s.fixar(a, b, escuro)
+		}
+	}
+}
+
+// desenharFormato grava as duas cópias da informação de formato (nível M e máscara)
+// e o módulo escuro fixo
+func (s *Simbolo) desenharFormato(mascara int) {
+	const nivelM = 0b00
+	dados := nivelM<<3 | mascara
+	resto := dados
+	for i := 0; i < 10; i++ {
+		resto = resto<<1 ^ (resto>>9)*0x537
+	}
+	bits := (dados<<10 | resto) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	n := s.Tamanho
+	for i := 0; i <= 5; i++ {
+		s.fixar(i, 8, bit(i))
+	}
+	s.fixar(7, 8, bit(6))
+	s.fixar(8, 8, bit(7))
+	s.fixar(8, 7, bit(8))
+	for i := 9; i < 15; i++ {
+		s.fixar(8, 14-i, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		s.fixar(8, n-1-i, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		s.fixar(n-15+i, 8, bit(i))
+	}
+	s.fixar(n-8, 8, true)
+}
+
+// posicionarDados percorre as colunas em pares, da direita para a esquerda, em
+// zigue-zague vertical, preenchendo os módulos livres com os bits dos codewords
+func (s *Simbolo) posicionarDados(codewords []byte) {
+	n := s.Tamanho
+	i := 0
+	for direita := n - 1; direita >= 1; direita -= 2 {
+		if direita == 6 {
+			direita = 5
+		}
+		for vertical := 0; vertical < n; vertical++ {
+			for j := 0; j < 2; j++ {
+				coluna := direita - j
+				linha := vertical
+				if (direita+1)&2 == 0 {
+					linha = n - 1 - vertical
+				}
+				if s.funcao[linha][coluna] {
+					continue
+				}
+				// Os bits de resto, além dos codewords, ficam claros
+				if i < len(codewords)*8 {
+					s.modulos[linha][coluna] = codewords[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// mascarada informa se a máscara inverte o módulo da linha e coluna
+func mascarada(mascara, linha, coluna int) bool {
+	switch mascara {
+	case 0:
+		return (linha+coluna)%2 == 0
+	case 1:
+		return linha%2 == 0
+	case 2:
+		return coluna%3 == 0
+	case 3:
+		return (linha+coluna)%3 == 0
+	case 4:
+		return (linha/2+coluna/3)%2 == 0
+	case 5:
+		return linha*coluna%2+linha*coluna%3 == 0
+	case 6:
+		return (linha*coluna%2+linha*coluna%3)%2 == 0
+	default:
+		return ((linha+coluna)%2+linha*coluna%3)%2 == 0
+	}
+}
+
+func (s *Simbolo) aplicarMascara(mascara int) {
+	s.Mascara = mascara
+	for l := 0; l < s.Tamanho; l++ {
+		for c := 0; c < s.Tamanho; c++ {
+			if !s.funcao[l][c] && mascarada(mascara, l, c) {
+				s.modulos[l][c] = !s.modulos[l][c]
+			}
+		}
+	}
+}
+
+// penalidade aplica as quatro regras de avaliação da norma: sequências da mesma
+// cor, blocos 2x2, padrões parecidos com o localizador e o equilíbrio de escuros
+func (s *Simbolo) penalidade() int {
+	n := s.Tamanho
+	total := 0
+	escuros := 0
+
+	for eixo := 0; eixo < 2; eixo++ {
+		for i := 0; i < n; i++ {
+			sequencia := 0
+			var anterior bool
+			linhaBits := make([]bool, n)
+			for j := 0; j < n; j++ {
+				modulo := s.modulos[i][j]
+				if eixo == 1 {
+					modulo = s.modulos[j][i]
+				}
+				linhaBits[j] = modulo
+				if j > 0 && modulo == anterior {
+					sequencia++
+				} else {
+					if sequencia >= 5 {
+						total += sequencia - 2
+					}
+					sequencia = 1
+				}
+				anterior = modulo
+			}
+			if sequencia >= 5 {
+				total += sequencia - 2
+			}
+			total += 40 * padroesLocalizador(linhaBits)
+		}
+	}
+
+	for l := 0; l < n; l++ {
+		for c := 0; c < n; c++ {
+			if s.modulos[l][c] {
+				escuros++
+			}
+			if l < n-1 && c < n-1 {
+				cor := s.modulos[l][c]
+				if s.modulos[l][c+1] == cor && s.modulos[l+1][c] == cor && s.modulos[l+1][c+1] == cor {
+					total += 3
+				}
+			}
+		}
+	}
+
+	modulos := n * n
+	k := (abs(escuros*20-modulos*10)+modulos-1)/modulos - 1
+	total += k * 10
+	return total
+}
+
+// padroesLocalizador conta as ocorrências de 1:1:3:1:1 com quatro módulos claros
+// antes ou depois; a borda do símbolo conta como clara
+func padroesLocalizador(linha []bool) int {
+	padrao := []bool{true, false, true, true, true, false, true}
+	claro := func(i int) bool { return i < 0 || i >= len(linha) || !linha[i] }
+	ocorrencias := 0
+	for inicio := 0; inicio+len(padrao) <= len(linha); inicio++ {
+		igual := true
+		for k, escuro := range padrao {
+			if linha[inicio+k] != escuro {
+				igual = false
+				break
+			}
+		}
+		if !igual {
+			continue
+		}
+		antes, depois := true, true
+		for k := 1; k <= 4; k++ {
+			antes = antes && claro(inicio-k)
+			depois = depois && claro(inicio+len(padrao)-1+k)
+		}
+		if antes || depois {
+			ocorrencias++
+		}
+	}
+	return ocorrencias
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,287 @@
+package qrcode_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"servico-faturamento/internal/qrcode"
+)
+
+const urlNFCe = "https://www.homologacao.nfce.fazenda.sp.gov.br/NFCeConsultaPublica/Paginas/ConsultaQRCode.aspx?p=35250311222333000181650010000001231123456780|2|2|1|3F2B7C9A0D1E4F5A6B7C8D9E0F1A2B3C4D5E6F70"
+
+// leitor lê o símbolo como um leitor de QR Code: confere o formato, desfaz a
+// máscara, recolhe os codewords em zigue-zague e confere a correção de cada bloco
+type leitor struct {
+	t *testing.T
+	s *qrcode.Simbolo
+	n int
+}
+
+// funcao reproduz as áreas fixas da norma para a versão do símbolo
+func (l *leitor) funcao(linha, coluna int) bool {
+	n := l.n
+	switch {
+	case linha < 9 && coluna < 9, linha < 9 && coluna >= n-8, linha >= n-8 && coluna < 9:
+		return true
+	case linha == 6 || coluna == 6:
+		return true
+	case l.s.Versao >= 7 && ((linha < 6 && coluna >= n-11) || (coluna < 6 && linha >= n-11)):
+		return true
+	}
+	if l.s.Versao == 1 {
+		return false
+	}
+	centros := map[int][]int{3: {6, 22}, 8: {6, 24, 42}, 10: {6, 28, 50}}[l.s.Versao]
+	for i, cl := range centros {
+		for j, cc := range centros {
+			if (i == 0 && j == 0) || (i == 0 && j == len(centros)-1) || (i == len(centros)-1 && j == 0) {
+				continue
+			}
+			if linha >= cl-2 && linha <= cl+2 && coluna >= cc-2 && coluna <= cc+2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l *leitor) formato() (nivel, mascara int) {
+	n := l.n
+	bit := func(linha, coluna int) int {
+		if l.s.Escuro(linha, coluna) {
+			return 1
+		}
+		return 0
+	}
+	var primeira, segunda int
+	for i := 0; i <= 5; i++ {
+		primeira |= bit(i, 8) << i
+	}
+	primeira |= bit(7, 8)<<6 | bit(8, 8)<<7 | bit(8, 7)<<8
+	for i := 9; i < 15; i++ {
+		primeira |= bit(8, 14-i) << i
+	}
+	for i := 0; i < 8; i++ {
+		segunda |= bit(8, n-1-i) << i
+	}
+	for i := 8; i < 15; i++ {
+		segunda |= bit(n-15+i, 8) << i
+	}
+	if primeira != segunda {
+		l.t.Fatalf("copias do formato divergem: %015b e %015b", primeira, segunda)
+	}
+	bits := primeira ^ 0x5412
+	resto := bits
+	for i := 14; i >= 10; i-- {
+		if resto>>i&1 == 1 {
+			resto ^= 0x537 << (i - 10)
+		}
+	}
+	if resto != 0 {
+		l.t.Fatalf("BCH do formato invalido: %015b", primeira)
+	}
+	return bits >> 13, bits >> 10 & 7
+}
+
+func (l *leitor) codewords(mascara int) []byte {
+	n := l.n
+	var saida []byte
+	var atual byte
+	bits := 0
+	for direita := n - 1; direita >= 1; direita -= 2 {
+		if direita == 6 {
+			direita = 5
+		}
+		for vertical := 0; vertical < n; vertical++ {
+			for j := 0; j < 2; j++ {
+				coluna := direita - j
+				linha := vertical
+				if (direita+1)&2 == 0 {
+					linha = n - 1 - vertical
+				}
+				if l.funcao(linha, coluna) {
+					continue
+				}
+				escuro := l.s.Escuro(linha, coluna)
+				if mascaras[mascara](linha, coluna) {
+					escuro = !escuro
+				}
+				atual <<= 1
+				if escuro {
+					atual |= 1
+				}
+				if bits++; bits%8 == 0 {
+					saida = append(saida, atual)
+					atual = 0
+				}
+			}
+		}
+	}
+	return saida
+}
+
+var mascaras = []func(l, c int) bool{
+	func(l, c int) bool { return (l+c)%2 == 0 },
+	func(l, c int) bool { return l%2 == 0 },
+	func(l, c int) bool { return c%3 == 0 },
+	func(l, c int) bool { return (l+c)%3 == 0 },
+	func(l, c int) bool { return (l/2+c/3)%2 == 0 },
+	func(l, c int) bool { return l*c%2+l*c%3 == 0 },
+	func(l, c int) bool { return (l*c%2+l*c%3)%2 == 0 },
+	func(l, c int) bool { return ((l+c)%2+l*c%3)%2 == 0 },
+}
+
+// gfMul e sindromeNula conferem, de forma independente do codificador, que o bloco
+// é múltiplo do gerador: o polinômio se anula em α^0..α^(correcao-1)
+func gfMul(x, y byte) byte {
+	var p byte
+	for y > 0 {
+		if y&1 == 1 {
+			p ^= x
+		}
+		alto := x & 0x80
+		x <<= 1
+		if alto != 0 {
+			x ^= 0x1D
+		}
+		y >>= 1
+	}
+	return p
+}
+
+func sindromeNula(bloco []byte, correcao int) bool {
+	var alfa byte = 1
+	for i := 0; i < correcao; i++ {
+		var soma byte
+		for _, c := range bloco {
+			soma = gfMul(soma, alfa) ^ c
+		}
+		if soma != 0 {
+			return false
+		}
+		alfa = gfMul(alfa, 2)
+	}
+	return true
+}
+
+// estrutura do nível M nas versões exercitadas: correção, blocos e dados por grupo
+var estrutura = map[int][5]int{
+	1: {10, 1, 16, 0, 0}, 3: {26, 1, 44, 0, 0}, 8: {22, 2, 38, 2, 39}, 10: {26, 4, 43, 1, 44},
+}
+
+func ler(t *testing.T, s *qrcode.Simbolo) string {
+	t.Helper()
+	l := &leitor{t: t, s: s, n: s.Tamanho}
+	if s.Tamanho != 17+4*s.Versao {
+		t.Fatalf("tamanho %d incompativel com a versao %d", s.Tamanho, s.Versao)
+	}
+	nivel, mascara := l.formato()
+	if nivel != 0 || mascara != s.Mascara {
+		t.Fatalf("formato lido: nivel %02b mascara %d; esperava M (00) e mascara %d", nivel, mascara, s.Mascara)
+	}
+
+	e, ok := estrutura[s.Versao]
+	if !ok {
+		t.Fatalf("versao %d sem estrutura no teste", s.Versao)
+	}
+	correcao, blocos := e[0], e[1]+e[3]
+	lidos := l.codewords(mascara)
+
+	dadosBloco := make([][]byte, blocos)
+	pos := 0
+	for i := 0; i < max(e[2], e[4]); i++ {
+		for b := 0; b < blocos; b++ {
+			if tamanho := map[bool]int{true: e[2], false: e[4]}[b < e[1]]; i < tamanho {
+				dadosBloco[b] = append(dadosBloco[b], lidos[pos])
+				pos++
+			}
+		}
+	}
+	var dados []byte
+	for b := 0; b < blocos; b++ {
+		bloco := append([]byte(nil), dadosBloco[b]...)
+		for i := 0; i < correcao; i++ {
+			bloco = append(bloco, lidos[pos+i*blocos+b])
+		}
+		if !sindromeNula(bloco, correcao) {
+			t.Fatalf("bloco %d com correcao de erros invalida", b)
+		}
+		dados = append(dados, dadosBloco[b]...)
+	}
+
+	if dados[0]>>4 != 0b0100 {
+		t.Fatalf("modo %04b, esperava byte (0100)", dados[0]>>4)
+	}
+	bit := 4
+	lerBits := func(n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | int(dados[bit/8]>>(7-bit%8)&1)
+			bit++
+		}
+		return v
+	}
+	contagem := 8
+	if s.Versao > 9 {
+		contagem = 16
+	}
+	tamanho := lerBits(contagem)
+	var texto strings.Builder
+	for i := 0; i < tamanho; i++ {
+		texto.WriteByte(byte(lerBits(8)))
+	}
+	return texto.String()
+}
+
+func TestCodificar(t *testing.T) {
+	t.Run("deve gerar simbolo legivel na menor versao", func(t *testing.T) {
+		casos := []struct {
+			texto  string
+			versao int
+		}{
+			{"NFC-e", 1},
+			{"https://www.sefaz.rs.gov.br/nfce/consulta", 3},
+			{strings.Repeat("0123456789", 15), 8},
+			{urlNFCe, 10},
+		}
+		for _, caso := range casos {
+			s, err := qrcode.Codificar(caso.texto)
+			if err != nil {
+				t.Fatalf("erro inesperado: %v", err)
+			}
+			if s.Versao != caso.versao {
+				t.Errorf("%d bytes: esperava versao %d, obteve %d", len(caso.texto), caso.versao, s.Versao)
+				continue
+			}
+			if lido := ler(t, s); lido != caso.texto {
+				t.Errorf("texto lido %q difere de %q", lido, caso.texto)
+			}
+		}
+	})
+
+	t.Run("deve desenhar os localizadores nos tres cantos", func(t *testing.T) {
+		s, err := qrcode.Codificar(urlNFCe)
+		if err != nil {
+			t.Fatalf("erro inesperado: %v", err)
+		}
+		n := s.Tamanho
+		for _, canto := range [][2]int{{0, 0}, {0, n - 7}, {n - 7, 0}} {
+			for i := 0; i < 7; i++ {
+				for j := 0; j < 7; j++ {
+					borda := i == 0 || i == 6 || j == 0 || j == 6
+					centro := i >= 2 && i <= 4 && j >= 2 && j <= 4
+					if s.Escuro(canto[0]+i, canto[1]+j) != (borda || centro) {
+						t.Fatalf("localizador em %v incorreto no modulo (%d,%d)", canto, i, j)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("deve recusar texto acima da capacidade", func(t *testing.T) {
+		if _, err := qrcode.Codificar(strings.Repeat("x", 700)); !errors.Is(err, qrcode.ErrTextoLongo) {
+			t.Errorf("esperava ErrTextoLongo, obteve %v", err)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package qrcode
+
+// multiplicar multiplica no corpo GF(256) de polinômio primitivo x^8+x^4+x^3+x^2+1 (0x11D)
+func multiplicar(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// polinomioGerador devolve os coeficientes, do maior grau ao menor e sem o
+// coeficiente líder, de (x - α^0)(x - α^1)...(x - α^(grau-1))
+func polinomioGerador(grau int) []byte {
+	resultado := make([]byte, grau)
+	resultado[grau-1] = 1
+	var raiz byte = 1
+	for i := 0; i < grau; i++ {
+		for j := range resultado {
+			resultado[j] = multiplicar(resultado[j], raiz)
+			if j+1 < len(resultado) {
+				resultado[j] ^= resultado[j+1]
+			}
+		}
+		raiz = multiplicar(raiz, 0x02)
+	}
+	return resultado
+}
+
+// restoReedSolomon calcula os codewords de correção do bloco: o resto da divisão
+// dos dados, deslocados pelo grau, pelo polinômio gerador
+func restoReedSolomon(dados, gerador []byte) []byte {
+	resto := make([]byte, len(gerador))
+	for _, b := range dados {
+		fator := b ^ resto[0]
+		copy(resto, resto[1:])
+		resto[len(resto)-1] = 0
+		for i, coeficiente := range gerador {
+			resto[i] ^= multiplicar(coeficiente, fator)
+		}
+	}
+	return resto
+}
@@ -0,0 +1,182 @@
+package sefaz
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+// StatusServico consulta se o autorizador da UF está em operação (cStat 107)
+func (c *Cliente) StatusServico(ctx context.Context) (*RetConsStatServ, error) {
+	var ret RetConsStatServ
+	err := c.chamar(ctx, ServicoStatusServico, ConsStatServ{
+		Versao: Versao,
+		TpAmb:  c.cfg.Ambiente,
+		CUF:    c.cfg.CUF,
+		XServ:  "STATUS",
+	}, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Autorizar envia a NF-e assinada em um lote de uma nota. indSinc=1 pede o
+// protocolo na própria resposta; o autorizador pode ainda assim devolver um recibo.
+func (c *Cliente) Autorizar(ctx context.Context, nfeAssinada []byte) (*RetEnviNFe, error) {
+	var ret RetEnviNFe
+	err := c.chamar(ctx, ServicoAutorizacao, EnviNFe{
+		Versao:  Versao,
+		IdLote:  fmt.Sprintf("%015d", time.Now().UnixNano()%1e15),
+		IndSinc: "1",
+		NFe:     semDeclaracao(nfeAssinada),
+	}, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// ConsultarRecibo busca o resultado do lote assíncrono
+func (c *Cliente) ConsultarRecibo(ctx context.Context, nRec string) (*RetConsReciNFe, error) {
+	var ret RetConsReciNFe
+	err := c.chamar(ctx, ServicoRetAutorizacao, ConsReciNFe{
+		Versao: Versao,
+		TpAmb:  c.cfg.Ambiente,
+		NRec:   nRec,
+	}, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// ConsultarProtocolo busca a situação da nota pela chave de acesso
+func (c *Cliente) ConsultarProtocolo(ctx context.Context, chave string) (*RetConsSitNFe, error) {
+	var ret RetConsSitNFe
+	err := c.chamar(ctx, ServicoConsultaProtocolo, ConsSitNFe{
+		Versao: Versao,
+		TpAmb:  c.cfg.Ambiente,
+		XServ:  "CONSULTAR",
+		ChNFe:  chave,
+	}, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// Transmitir autoriza a NF-e e devolve o protocolo da nota. O lote assíncrono (103)
+// é acompanhado pelo recibo, e a rejeição por duplicidade (204), que acontece quando
+// uma transmissão anterior chegou à SEFAZ mas a resposta se perdeu, é resolvida pela
+// consulta do protocolo da chave. Rejeições do lote inteiro voltam como protocolo da
+// nota com o cStat do lote; serviço paralisado devolve ErrSefazIndisponivel.
+func (c *Cliente) Transmitir(ctx context.Context, nfeAssinada []byte, chave string) (*ProtNFe, error) {
+	ret, err := c.Autorizar(ctx, nfeAssinada)
+	if err != nil {
+		return nil, err
+	}
+
+	var prot *ProtNFe
+	switch {
+	case ret.ProtNFe != nil:
+		prot = ret.ProtNFe
+	case ret.CStat == CStatLoteRecebido && ret.InfRec != nil:
+		prot, err = c.aguardarRecibo(ctx, ret.InfRec.NRec, chave)
+		if err != nil {
+			return nil, err
+		}
+	case cStatIndisponivel[ret.CStat]:
+		return nil, fmt.Errorf("%w: %d %s", ErrSefazIndisponivel, ret.CStat, ret.XMotivo)
+	default:
+		prot = protocoloDoLote(chave, ret.TpAmb, ret.CStat, ret.XMotivo, ret.DhRecbto)
+	}
+
+	if prot.InfProt.CStat == dominio.CStatDuplicidade {
+		return c.resolverDuplicidade(ctx, chave, prot)
+	}
+	return prot, nil
+}
+
+// aguardarRecibo consulta o recibo até o lote sair de processamento (105)
+func (c *Cliente) aguardarRecibo(ctx context.Context, nRec, chave string) (*ProtNFe, error) {
+	for tentativa := 0; tentativa < c.cfg.TentativasConsulta; tentativa++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.cfg.IntervaloConsulta):
+		}
+
+		ret, err := c.ConsultarRecibo(ctx, nRec)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case ret.CStat == CStatLoteEmProcessamento:
+			continue
+		case cStatIndisponivel[ret.CStat]:
+			return nil, fmt.Errorf("%w: %d %s", ErrSefazIndisponivel, ret.CStat, ret.XMotivo)
+		}
+		for i := range ret.ProtNFe {
+			if ret.ProtNFe[i].InfProt.ChNFe == chave {
+				return &ret.ProtNFe[i], nil
+			}
+		}
+		return protocoloDoLote(chave, ret.TpAmb, ret.CStat, ret.XMotivo, ret.DhRecbto), nil
+	}
+	// A nota será retransmitida e, se o lote tiver sido autorizado, cairá na duplicidade
+	return nil, fmt.Errorf("%w: lote %s ainda em processamento", ErrSefazIndisponivel, nRec)
+}
+
+// resolverDuplicidade troca a rejeição 204 pelo protocolo já registrado para a chave
+func (c *Cliente) resolverDuplicidade(ctx context.Context, chave string, rejeicao *ProtNFe) (*ProtNFe, error) {
+	ret, err := c.ConsultarProtocolo(ctx, chave)
+	if err != nil {
+		return nil, err
+	}
+	if ret.ProtNFe != nil && ret.ProtNFe.InfProt.ChNFe == chave &&
+		dominio.StatusDoRetorno(ret.ProtNFe.InfProt.CStat) != dominio.StatusNotaRejeitada {
+		return ret.ProtNFe, nil
+	}
+	return rejeicao, nil
+}
+
+// protocoloDoLote representa como protocolo da nota a rejeição do lote, que vem sem protNFe
+func protocoloDoLote(chave, tpAmb string, cStat int, xMotivo, dhRecbto string) *ProtNFe {
+	return &ProtNFe{
+		Versao: Versao,
+		InfProt: InfProt{
+			TpAmb:    tpAmb,
+			ChNFe:    chave,
+			DhRecbto: dhRecbto,
+			CStat:    cStat,
+			XMotivo:  xMotivo,
+		},
+	}
+}
+
+// Resultado converte o protocolo no retorno registrado na nota; nfeAssinada entra no
+// nfeProc das notas autorizadas ou denegadas
+func (p *ProtNFe) Resultado(nfeAssinada []byte) (dominio.ResultadoAutorizacao, error) {
+	r := dominio.ResultadoAutorizacao{
+		CStat:     p.InfProt.CStat,
+		XMotivo:   p.InfProt.XMotivo,
+		Protocolo: p.InfProt.NProt,
+	}
+	if p.InfProt.DhRecbto != "" {
+		if data, err := time.Parse(time.RFC3339, p.InfProt.DhRecbto); err == nil {
+			r.DataRecebimento = data
+		}
+	}
+	if dominio.StatusDoRetorno(r.CStat) == dominio.StatusNotaRejeitada {
+		return r, nil
+	}
+	proc, err := MontarNFeProc(nfeAssinada, *p)
+	if err != nil {
+		return r, err
+	}
+	r.XML = string(proc)
+	return r, nil
+}
@@ -0,0 +1,193 @@
+package sefaz
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"servico-faturamento/internal/assinatura"
+)
+
+// NamespaceSOAP é o envelope SOAP 1.2 exigido pelos web services 4.00
+const NamespaceSOAP = "http://www.w3.org/2003/05/soap-envelope"
+
+// tamanhoMaximoResposta limita a leitura do retorno; o maior (retConsReciNFe com
+// 50 protocolos) fica bem abaixo disso
+const tamanhoMaximoResposta = 10 << 20
+
+var (
+	// ErrSefazIndisponivel indica falha de comunicação ou serviço paralisado; o pedido
+	// não foi processado e pode ser repetido
+	ErrSefazIndisponivel = errors.New("SEFAZ indisponivel")
+	// ErrSefazDesabilitada indica que não há endereço configurado para a transmissão
+	ErrSefazDesabilitada = errors.New("transmissao para a SEFAZ nao configurada (SEFAZ_URL)")
+	// ErrRespostaInvalida indica retorno fora do padrão SOAP/leiaute esperado
+	ErrRespostaInvalida = errors.New("resposta invalida da SEFAZ")
+)
+
+// Cliente chama os web services da SEFAZ com autenticação mútua pelo certificado A1
+type Cliente struct {
+	cfg  Configuracao
+	http *http.Client
+}
+
+// NovoCliente monta o cliente TLS com o certificado do emitente. Sem certificado a
+// conexão não apresenta identidade, o que só é aceito pelo simulador local.
+func NovoCliente(cfg Configuracao, certificado *assinatura.Certificado) (*Cliente, error) {
+	tlsCfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Alguns autorizadores pedem o certificado do cliente em renegociação
+		Renegotiation: tls.RenegotiateOnceAsClient,
+	}
+	if certificado != nil {
+		cadeia := [][]byte{certificado.X509.Raw}
+		for _, c := range certificado.Cadeia {
+			cadeia = append(cadeia, c.Raw)
+		}
+		tlsCfg.Certificates = []tls.Certificate{{
+			Certificate: cadeia,
+			PrivateKey:  certificado.Chave,
+			Leaf:        certificado.X509,
+		}}
+	}
+	if cfg.ArquivoCA != "" {
+		pem, err := os.ReadFile(cfg.ArquivoCA)
+		if err != nil {
+			return nil, fmt.Errorf("falha ao ler SEFAZ_CA_ARQUIVO: %w", err)
+		}
+		raizes := x509.NewCertPool()
+		if !raizes.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("SEFAZ_CA_ARQUIVO sem certificados PEM: %s", cfg.ArquivoCA)
+		}
+		tlsCfg.RootCAs = raizes
+	}
+
+	transporte := http.DefaultTransport.(*http.Transport).Clone()
+	transporte.TLSClientConfig = tlsCfg
+	return &Cliente{
+		cfg:  cfg,
+		http: &http.Client{Transport: transporte, Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Configuracao devolve os parâmetros com que o cliente foi criado
+func (c *Cliente) Configuracao() Configuracao {
+	return c.cfg
+}
+
+// chamar envia a mensagem no nfeDadosMsg do serviço e decodifica o conteúdo do nfeResultMsg
+func (c *Cliente) chamar(ctx context.Context, s Servico, mensagem, retorno interface{}) error {
+	url := c.cfg.URLs[s.Nome]
+	if url == "" {
+		return fmt.Errorf("%w: sem endereco para %s", ErrSefazDesabilitada, s.Nome)
+	}
+
+	corpo, err := xml.Marshal(mensagem)
+	if err != nil {
+		return fmt.Errorf("falha ao serializar %s: %w", s.Nome, err)
+	}
+	var envelope bytes.Buffer
+	envelope.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	envelope.WriteString(`<soap12:Envelope xmlns:soap12="` + NamespaceSOAP + `"><soap12:Body>`)
+	envelope.WriteString(`<nfeDadosMsg xmlns="` + s.namespace() + `">`)
+	envelope.Write(corpo)
+	envelope.WriteString(`</nfeDadosMsg></soap12:Body></soap12:Envelope>`)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &envelope)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", `application/soap+xml; charset=utf-8; action="`+s.namespace()+`/`+s.Operacao+`"`)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrSefazIndisponivel, s.Nome, err)
+	}
+	defer resp.Body.Close()
+
+	dados, err := io.ReadAll(io.LimitReader(resp.Body, tamanhoMaximoResposta))
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrSefazIndisponivel, s.Nome, err)
+	}
+
+	err = LerResultado(dados, retorno)
+	var fault *FaultSOAP
+	switch {
+	case err == nil && resp.StatusCode < 300:
+		return nil
+	case errors.As(err, &fault):
+		// O SOAP 1.2 responde o Fault com HTTP 500; não é indisponibilidade do serviço
+		return fmt.Errorf("%s: %w", s.Nome, err)
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s respondeu HTTP %d: %v", ErrSefazIndisponivel, s.Nome, resp.StatusCode, err)
+	case err == nil:
+		return fmt.Errorf("%w: %s respondeu HTTP %d", ErrRespostaInvalida, s.Nome, resp.StatusCode)
+	default:
+		return fmt.Errorf("%s respondeu HTTP %d: %w", s.Nome, resp.StatusCode, err)
+	}
+}
+
+// FaultSOAP é o erro devolvido pelo servidor no lugar do nfeResultMsg
+type FaultSOAP struct {
+	Codigo string
+	Motivo string
+}
+
+func (f *FaultSOAP) Error() string {
+	return fmt.Sprintf("SOAP Fault %s: %s", f.Codigo, f.Motivo)
+}
+
+// LerResultado decodifica em retorno o elemento dentro do nfeResultMsg do envelope
+func LerResultado(envelope []byte, retorno interface{}) error {
+	dec := xml.NewDecoder(bytes.NewReader(envelope))
+	dentroResultado := false
+	for {
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w: envelope sem nfeResultMsg", ErrRespostaInvalida)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRespostaInvalida, err)
+		}
+		inicio, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case inicio.Name.Local == "Fault" && inicio.Name.Space == NamespaceSOAP:
+			var fault struct {
+				Codigo string `xml:"Code>Value"`
+				Motivo string `xml:"Reason>Text"`
+			}
+			if err := dec.DecodeElement(&fault, &inicio); err != nil {
+				return fmt.Errorf("%w: %v", ErrRespostaInvalida, err)
+			}
+			return &FaultSOAP{Codigo: strings.TrimSpace(fault.Codigo), Motivo: strings.TrimSpace(fault.Motivo)}
+		case inicio.Name.Local == "nfeResultMsg":
+			dentroResultado = true
+		case dentroResultado:
+			if err := dec.DecodeElement(retorno, &inicio); err != nil {
+				return fmt.Errorf("%w: %v", ErrRespostaInvalida, err)
+			}
+			return nil
+		}
+	}
+}
+
+// CarregarConfigurado cria o cliente com a configuração das variáveis de ambiente.
+// Sem SEFAZ_URL (ou SEFAZ_URL_AUTORIZACAO) devolve nil, nil: as notas ficam em FECHADA.
+func CarregarConfigurado(certificado *assinatura.Certificado) (*Cliente, error) {
+	cfg := CarregarConfiguracao()
+	if !cfg.Habilitada() {
+		return nil, nil
+	}
+	return NovoCliente(cfg, certificado)
+}
@@ -0,0 +1,255 @@
+package sefaz_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/sefaz"
+)
+
+const chaveTeste = "35250311222333000181550010000000101000000105"
+
+// nfeTeste é uma NF-e reduzida aos campos conferidos pelo simulador
+func nfeTeste(chave, cnpjDestinatario string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<NFe xmlns="http://www.portalfiscal.inf.br/nfe"><infNFe versao="4.00" Id="NFe` + chave + `">` +
+		`<ide><cUF>35</cUF><tpAmb>2</tpAmb></ide>` +
+		`<emit><CNPJ>11222333000181</CNPJ></emit>` +
+		`<dest><CNPJ>` + cnpjDestinatario + `</CNPJ></dest></infNFe></NFe>`
+}
+
+// nfeAssinada assina a nota com o certificado de teste
+func nfeAssinada(t *testing.T, chave, cnpjDestinatario string) []byte {
+	t.Helper()
+	return assinar(t, nfeTeste(chave, cnpjDestinatario), "infNFe")
+}
+
+// assinar usa testdata/certificado-teste.pfx do pacote assinatura (senha 1234)
+func assinar(t *testing.T, documento, elemento string) []byte {
+	t.Helper()
+	dados, err := os.ReadFile("../assinatura/testdata/certificado-teste.pfx")
+	if err != nil {
+		t.Fatalf("falha ao ler certificado de teste: %v", err)
+	}
+	c, err := assinatura.CarregarPFX(dados, "1234")
+	if err != nil {
+		t.Fatalf("falha ao carregar certificado de teste: %v", err)
+	}
+	assinado, err := c.Assinar([]byte(documento), elemento)
+	if err != nil {
+		t.Fatalf("falha ao assinar: %v", err)
+	}
+	return assinado
+}
+
+// clienteSimulador sobe o simulador em httptest e aponta SEFAZ_URL para ele
+func clienteSimulador(t *testing.T, sim *sefaz.Simulador) *sefaz.Cliente {
+	t.Helper()
+	servidor := httptest.NewServer(sim)
+	t.Cleanup(servidor.Close)
+	return clientePara(t, servidor.URL)
+}
+
+func clientePara(t *testing.T, url string) *sefaz.Cliente {
+	t.Helper()
+	t.Setenv("SEFAZ_URL", url)
+	cfg := sefaz.CarregarConfiguracao()
+	cfg.IntervaloConsulta = time.Millisecond
+	c, err := sefaz.NovoCliente(cfg, nil)
+	if err != nil {
+		t.Fatalf("falha ao criar cliente: %v", err)
+	}
+	return c
+}
+
+func TestCliente_StatusServico(t *testing.T) {
+	c := clienteSimulador(t, sefaz.NovoSimulador())
+
+	ret, err := c.StatusServico(context.Background())
+	if err != nil {
+		t.Fatalf("esperava nil, obteve erro: %v", err)
+	}
+	if ret.CStat != sefaz.CStatServicoEmOperacao {
+		t.Errorf("esperava cStat 107, obteve %d %s", ret.CStat, ret.XMotivo)
+	}
+}
+
+func TestCliente_Transmitir(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("deve autorizar nota assinada e montar o nfeProc", func(t *testing.T) {
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		nfe := nfeAssinada(t, chaveTeste, "99888777000100")
+
+		prot, err := c.Transmitir(ctx, nfe, chaveTeste)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if prot.InfProt.CStat != dominio.CStatAutorizada || prot.InfProt.NProt == "" {
+			t.Fatalf("esperava autorizacao com protocolo, obteve %d %q", prot.InfProt.CStat, prot.InfProt.NProt)
+		}
+
+		r, err := prot.Resultado(nfe)
+		if err != nil {
+			t.Fatalf("falha ao montar resultado: %v", err)
+		}
+		if r.DataRecebimento.IsZero() || r.Protocolo != prot.InfProt.NProt {
+			t.Errorf("resultado sem data ou protocolo: %+v", r)
+		}
+		for _, trecho := range []string{
+			`<nfeProc xmlns="http://www.portalfiscal.inf.br/nfe" versao="4.00"><NFe xmlns=`,
+			`</NFe><protNFe versao="4.00"><infProt`,
+			`<nProt>` + prot.InfProt.NProt + `</nProt>`,
+		} {
+			if !strings.Contains(r.XML, trecho) {
+				t.Errorf("nfeProc sem o trecho %q:\n%s", trecho, r.XML)
+			}
+		}
+		if _, err := assinatura.Verificar([]byte(r.XML)); err != nil {
+			t.Errorf("esperava assinatura da NF-e preservada no nfeProc, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve denegar destinatario irregular", func(t *testing.T) {
+		sim := sefaz.NovoSimulador()
+		sim.Denegar["99888777000100"] = true
+		c := clienteSimulador(t, sim)
+
+		prot, err := c.Transmitir(ctx, nfeAssinada(t, chaveTeste, "99888777000100"), chaveTeste)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if dominio.StatusDoRetorno(prot.InfProt.CStat) != dominio.StatusNotaDenegada || prot.InfProt.NProt == "" {
+			t.Errorf("esperava denegacao com protocolo, obteve %d %q", prot.InfProt.CStat, prot.InfProt.NProt)
+		}
+	})
+
+	t.Run("deve resolver duplicidade pela consulta do protocolo", func(t *testing.T) {
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		nfe := nfeAssinada(t, chaveTeste, "99888777000100")
+
+		primeiro, err := c.Transmitir(ctx, nfe, chaveTeste)
+		if err != nil {
+			t.Fatalf("falha na primeira transmissao: %v", err)
+		}
+		segundo, err := c.Transmitir(ctx, nfe, chaveTeste)
+		if err != nil {
+			t.Fatalf("esperava nil na retransmissao, obteve erro: %v", err)
+		}
+		if segundo.InfProt.CStat != dominio.CStatAutorizada || segundo.InfProt.NProt != primeiro.InfProt.NProt {
+			t.Errorf("esperava o protocolo original %s, obteve %d %q", primeiro.InfProt.NProt, segundo.InfProt.CStat, segundo.InfProt.NProt)
+		}
+	})
+
+	t.Run("deve acompanhar o recibo do lote assincrono", func(t *testing.T) {
+		sim := sefaz.NovoSimulador()
+		sim.Assincrono = true
+		c := clienteSimulador(t, sim)
+
+		prot, err := c.Transmitir(ctx, nfeAssinada(t, chaveTeste, "99888777000100"), chaveTeste)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if prot.InfProt.CStat != dominio.CStatAutorizada {
+			t.Errorf("esperava autorizacao pelo recibo, obteve %d %s", prot.InfProt.CStat, prot.InfProt.XMotivo)
+		}
+	})
+
+	t.Run("deve rejeitar nota sem assinatura", func(t *testing.T) {
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+
+		prot, err := c.Transmitir(ctx, []byte(nfeTeste(chaveTeste, "99888777000100")), chaveTeste)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if prot.InfProt.CStat != 225 || dominio.StatusDoRetorno(prot.InfProt.CStat) != dominio.StatusNotaRejeitada {
+			t.Errorf("esperava rejeicao 225, obteve %d %s", prot.InfProt.CStat, prot.InfProt.XMotivo)
+		}
+		r, err := prot.Resultado(nil)
+		if err != nil || r.XML != "" {
+			t.Errorf("rejeicao nao deveria montar nfeProc: %v %q", err, r.XML)
+		}
+	})
+
+	t.Run("deve rejeitar nota alterada depois da assinatura", func(t *testing.T) {
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		alterada := strings.Replace(string(nfeAssinada(t, chaveTeste, "99888777000100")), "99888777000100", "99888777000101", 1)
+
+		prot, err := c.Transmitir(ctx, []byte(alterada), chaveTeste)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if prot.InfProt.CStat != 297 {
+			t.Errorf("esperava rejeicao 297, obteve %d %s", prot.InfProt.CStat, prot.InfProt.XMotivo)
+		}
+	})
+}
+
+func TestCliente_Erros(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("deve devolver SOAP Fault do servidor", func(t *testing.T) {
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		_, err := c.Transmitir(ctx, []byte(`<NFe xmlns="http://www.portalfiscal.inf.br/nfe"><infNFe`), chaveTeste)
+		var fault *sefaz.FaultSOAP
+		if !errors.As(err, &fault) {
+			t.Fatalf("esperava FaultSOAP, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve tratar servidor fora do ar como indisponivel", func(t *testing.T) {
+		servidor := httptest.NewServer(http.NotFoundHandler())
+		url := servidor.URL
+		servidor.Close()
+
+		_, err := clientePara(t, url).StatusServico(ctx)
+		if !errors.Is(err, sefaz.ErrSefazIndisponivel) {
+			t.Errorf("esperava ErrSefazIndisponivel, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve tratar HTTP 503 como indisponivel", func(t *testing.T) {
+		servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "manutencao", http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(servidor.Close)
+
+		_, err := clientePara(t, servidor.URL).StatusServico(ctx)
+		if !errors.Is(err, sefaz.ErrSefazIndisponivel) {
+			t.Errorf("esperava ErrSefazIndisponivel, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve tratar servico paralisado como indisponivel", func(t *testing.T) {
+		servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope"><soap:Body>` +
+				`<nfeResultMsg xmlns="http://www.portalfiscal.inf.br/nfe/wsdl/NFeAutorizacao4">` +
+				`<retEnviNFe xmlns="http://www.portalfiscal.inf.br/nfe" versao="4.00"><tpAmb>2</tpAmb><cStat>108</cStat>` +
+				`<xMotivo>Servico Paralisado Momentaneamente</xMotivo></retEnviNFe></nfeResultMsg></soap:Body></soap:Envelope>`))
+		}))
+		t.Cleanup(servidor.Close)
+
+		_, err := clientePara(t, servidor.URL).Transmitir(ctx, nfeAssinada(t, chaveTeste, "99888777000100"), chaveTeste)
+		if !errors.Is(err, sefaz.ErrSefazIndisponivel) {
+			t.Errorf("esperava ErrSefazIndisponivel, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve exigir endereco configurado", func(t *testing.T) {
+		c, err := sefaz.NovoCliente(sefaz.Configuracao{}, nil)
+		if err != nil {
+			t.Fatalf("falha ao criar cliente: %v", err)
+		}
+		if _, err := c.StatusServico(ctx); !errors.Is(err, sefaz.ErrSefazDesabilitada) {
+			t.Errorf("esperava ErrSefazDesabilitada, obteve: %v", err)
+		}
+	})
+}
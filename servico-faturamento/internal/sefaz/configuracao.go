@@ -0,0 +1,168 @@
+package sefaz
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+)
+
+// Servico identifica o web service pelo WSDL e pela operação SOAP
+type Servico struct {
+	Nome     string
+	Operacao string
+	// caminho segue a publicação da SEFAZ-SP, usada quando só SEFAZ_URL é informada
+	caminho string
+}
+
+// namespace do nfeDadosMsg/nfeResultMsg do serviço
+func (s Servico) namespace() string {
+	return "http://www.portalfiscal.inf.br/nfe/wsdl/" + s.Nome
+}
+
+var (
+	ServicoAutorizacao       = Servico{"NFeAutorizacao4", "nfeAutorizacaoLote", "/ws/nfeautorizacao4.asmx"}
+	ServicoRetAutorizacao    = Servico{"NFeRetAutorizacao4", "nfeRetAutorizacaoLote", "/ws/nferetautorizacao4.asmx"}
+	ServicoStatusServico     = Servico{"NFeStatusServico4", "nfeStatusServicoNF", "/ws/nfestatusservico4.asmx"}
+	ServicoConsultaProtocolo = Servico{"NFeConsultaProtocolo4", "nfeConsultaNF", "/ws/nfeconsultaprotocolo4.asmx"}
+	// ServicoRecepcaoEvento recebe os eventos da nota no autorizador (cancelamento) e
+	// o EPEC no Ambiente Nacional
+	ServicoRecepcaoEvento = Servico{"NFeRecepcaoEvento4", "nfeRecepcaoEvento", "/ws/nferecepcaoevento4.asmx"}
+
+	servicos = []Servico{ServicoAutorizacao, ServicoRetAutorizacao, ServicoStatusServico, ServicoConsultaProtocolo, ServicoRecepcaoEvento}
+)
+
+// caminhoEventoAN é o NFeRecepcaoEvento4 do Ambiente Nacional, somado a SEFAZ_EPEC_URL
+const caminhoEventoAN = "/NFeRecepcaoEvento4/NFeRecepcaoEvento4.asmx"
+
+// caminhosSVC são os caminhos publicados por cada SVC, somados a SEFAZ_SVC_URL
+var caminhosSVC = map[string]map[string]string{
+	dominio.ModoSVCAN: {
+		ServicoAutorizacao.Nome:       "/NFeAutorizacao4/NFeAutorizacao4.asmx",
+		ServicoRetAutorizacao.Nome:    "/NFeRetAutorizacao4/NFeRetAutorizacao4.asmx",
+		ServicoStatusServico.Nome:     "/NFeStatusServico4/NFeStatusServico4.asmx",
+		ServicoConsultaProtocolo.Nome: "/NFeConsultaProtocolo4/NFeConsultaProtocolo4.asmx",
+		ServicoRecepcaoEvento.Nome:    caminhoEventoAN,
+	},
+	dominio.ModoSVCRS: {
+		ServicoAutorizacao.Nome:       "/ws/NfeAutorizacao/NFeAutorizacao4.asmx",
+		ServicoRetAutorizacao.Nome:    "/ws/NfeRetAutorizacao/NFeRetAutorizacao4.asmx",
+		ServicoStatusServico.Nome:     "/ws/NfeStatusServico/NfeStatusServico4.asmx",
+		ServicoConsultaProtocolo.Nome: "/ws/NfeConsulta/NfeConsulta4.asmx",
+		ServicoRecepcaoEvento.Nome:    "/ws/recepcaoevento/recepcaoevento4.asmx",
+	},
+}
+
+// Configuracao reúne os endereços dos web services e os parâmetros da transmissão
+type Configuracao struct {
+	Ambiente string // tpAmb: 1 = produção, 2 = homologação
+	CUF      string // código IBGE da UF do emitente, usado na consulta de status
+	// URLs por serviço (nome do WSDL); sem a URL de autorização a transmissão fica desligada
+	URLs map[string]string
+	// URLsSVC são os endereços da SVC da UF, usados pelas notas com tpEmis 6 ou 7
+	URLsSVC map[string]string
+	// URLEPEC é o NFeRecepcaoEvento4 do Ambiente Nacional, que registra o EPEC
+	URLEPEC string
+	// URLsNFCe são os endereços do autorizador da NFC-e (modelo 65), separado do da NF-e
+	URLsNFCe map[string]string
+	// ArquivoCA é o PEM com as autoridades aceitas no TLS da SEFAZ; vazio usa as do sistema
+	ArquivoCA string
+
+	Timeout            time.Duration
+	IntervaloConsulta  time.Duration // espera entre as consultas do recibo do lote assíncrono
+	TentativasConsulta int
+}
+
+// CarregarConfiguracao lê os endereços das variáveis de ambiente. SEFAZ_URL é a base
+// (https://homologacao.nfe.fazenda.sp.gov.br ou o simulador local) à qual são somados
+// os caminhos da SEFAZ-SP; SEFAZ_URL_<SERVICO> sobrepõe o endereço de um serviço.
+// Para a contingência, SEFAZ_SVC_URL recebe os caminhos da SVC da UF do emitente
+// (SEFAZ_SVC_URL_<SERVICO> sobrepõe) e SEFAZ_EPEC_URL o do Ambiente Nacional.
+// SEFAZ_NFCE_URL é a base do autorizador da NFC-e, com os mesmos caminhos
+// (SEFAZ_NFCE_URL_<SERVICO> sobrepõe).
+func CarregarConfiguracao() Configuracao {
+	cfg := Configuracao{
+		Ambiente:           getEnv("NFE_AMBIENTE", "2"),
+		URLs:               make(map[string]string),
+		URLsSVC:            make(map[string]string),
+		URLsNFCe:           make(map[string]string),
+		ArquivoCA:          os.Getenv("SEFAZ_CA_ARQUIVO"),
+		Timeout:            time.Duration(inteiro("SEFAZ_TIMEOUT_SEGUNDOS", 30)) * time.Second,
+		IntervaloConsulta:  time.Duration(inteiro("SEFAZ_INTERVALO_CONSULTA_SEGUNDOS", 2)) * time.Second,
+		TentativasConsulta: inteiro("SEFAZ_TENTATIVAS_CONSULTA", 5),
+	}
+	uf := strings.ToUpper(os.Getenv("EMITENTE_UF"))
+	cfg.CUF, _ = dominio.CodigoUF(uf)
+
+	base := strings.TrimRight(os.Getenv("SEFAZ_URL"), "/")
+	variaveis := map[string]string{
+		ServicoAutorizacao.Nome:       "SEFAZ_URL_AUTORIZACAO",
+		ServicoRetAutorizacao.Nome:    "SEFAZ_URL_RET_AUTORIZACAO",
+		ServicoStatusServico.Nome:     "SEFAZ_URL_STATUS_SERVICO",
+		ServicoConsultaProtocolo.Nome: "SEFAZ_URL_CONSULTA_PROTOCOLO",
+		ServicoRecepcaoEvento.Nome:    "SEFAZ_URL_RECEPCAO_EVENTO",
+	}
+	for _, s := range servicos {
+		if url := os.Getenv(variaveis[s.Nome]); url != "" {
+			cfg.URLs[s.Nome] = url
+		} else if base != "" {
+			cfg.URLs[s.Nome] = base + s.caminho
+		}
+	}
+
+	baseSVC := strings.TrimRight(os.Getenv("SEFAZ_SVC_URL"), "/")
+	caminhos := caminhosSVC[dominio.ModoSVCDaUF(uf)]
+	for _, s := range servicos {
+		if url := os.Getenv(strings.Replace(variaveis[s.Nome], "SEFAZ_", "SEFAZ_SVC_", 1)); url != "" {
+			cfg.URLsSVC[s.Nome] = url
+		} else if baseSVC != "" {
+			cfg.URLsSVC[s.Nome] = baseSVC + caminhos[s.Nome]
+		}
+	}
+
+	baseNFCe := strings.TrimRight(os.Getenv("SEFAZ_NFCE_URL"), "/")
+	for _, s := range servicos {
+		if url := os.Getenv(strings.Replace(variaveis[s.Nome], "SEFAZ_", "SEFAZ_NFCE_", 1)); url != "" {
+			cfg.URLsNFCe[s.Nome] = url
+		} else if baseNFCe != "" {
+			cfg.URLsNFCe[s.Nome] = baseNFCe + s.caminho
+		}
+	}
+	if base := strings.TrimRight(os.Getenv("SEFAZ_EPEC_URL"), "/"); base != "" {
+		cfg.URLEPEC = base + caminhoEventoAN
+	}
+	return cfg
+}
+
+// Habilitada informa se há endereço para transmitir as notas
+func (cfg Configuracao) Habilitada() bool {
+	return cfg.URLs[ServicoAutorizacao.Nome] != ""
+}
+
+// HabilitadaSVC informa se há endereço da SVC para as notas em contingência
+func (cfg Configuracao) HabilitadaSVC() bool {
+	return cfg.URLsSVC[ServicoAutorizacao.Nome] != ""
+}
+
+// HabilitadaNFCe informa se há endereço do autorizador da NFC-e
+func (cfg Configuracao) HabilitadaNFCe() bool {
+	return cfg.URLsNFCe[ServicoAutorizacao.Nome] != ""
+}
+
+func getEnv(chave, padrao string) string {
+	if valor := os.Getenv(chave); valor != "" {
+		return valor
+	}
+	return padrao
+}
+
+// inteiro lê um inteiro positivo; valores ausentes ou inválidos usam o padrão
+func inteiro(chave string, padrao int) int {
+	n, err := strconv.Atoi(os.Getenv(chave))
+	if err != nil || n <= 0 {
+		return padrao
+	}
+	return n
+}
@@ -0,0 +1,54 @@
+package sefaz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrSVCDesabilitada indica nota em SVC sem endereço da SVC configurado
+	ErrSVCDesabilitada = errors.New("SVC nao configurada (SEFAZ_SVC_URL)")
+	// ErrEPECDesabilitado indica nota em EPEC sem endereço do Ambiente Nacional
+	ErrEPECDesabilitado = errors.New("recepcao do EPEC nao configurada (SEFAZ_EPEC_URL)")
+	// ErrEventoRejeitado indica evento recusado pelo Ambiente Nacional
+	ErrEventoRejeitado = errors.New("evento rejeitado pela SEFAZ")
+)
+
+// SVC devolve o cliente apontado para a SEFAZ Virtual de Contingência, que atende
+// as notas com tpEmis 6 ou 7 com as mesmas mensagens do autorizador normal
+func (c *Cliente) SVC() (*Cliente, error) {
+	if !c.cfg.HabilitadaSVC() {
+		return nil, ErrSVCDesabilitada
+	}
+	return c.comURLs(c.cfg.URLsSVC), nil
+}
+
+// comURLs copia o cliente, reaproveitando a conexão TLS, com outros endereços
+func (c *Cliente) comURLs(urls map[string]string) *Cliente {
+	cfg := c.cfg
+	cfg.URLs = urls
+	return &Cliente{cfg: cfg, http: c.http}
+}
+
+// RegistrarEPEC envia o evento prévio assinado ao Ambiente Nacional e devolve o
+// registro com o nProt. A duplicidade (573) acontece quando um envio anterior foi
+// registrado mas a resposta se perdeu; o evento consta no AN e o registro volta
+// sem protocolo.
+func (c *Cliente) RegistrarEPEC(ctx context.Context, eventoAssinado []byte) (*InfRetEvento, error) {
+	if c.cfg.URLEPEC == "" {
+		return nil, ErrEPECDesabilitado
+	}
+	an := c.comURLs(map[string]string{ServicoRecepcaoEvento.Nome: c.cfg.URLEPEC})
+
+	inf, err := an.enviarEvento(ctx, eventoAssinado)
+	if err != nil {
+		return nil, err
+	}
+	switch inf.CStat {
+	case CStatEventoVinculado, CStatEventoNaoVinculado, CStatEventoDuplicado:
+		return inf, nil
+	default:
+		return nil, fmt.Errorf("%w: %d %s", ErrEventoRejeitado, inf.CStat, inf.XMotivo)
+	}
+}
@@ -0,0 +1,91 @@
+package sefaz_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/sefaz"
+)
+
+// eventoEPECTeste é um EPEC reduzido aos campos conferidos pelo simulador
+func eventoEPECTeste(chave string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<evento xmlns="http://www.portalfiscal.inf.br/nfe" versao="1.00"><infEvento Id="ID110140` + chave + `01">` +
+		`<cOrgao>91</cOrgao><tpAmb>2</tpAmb><CNPJ>11222333000181</CNPJ><chNFe>` + chave + `</chNFe>` +
+		`<tpEvento>110140</tpEvento><nSeqEvento>1</nSeqEvento></infEvento></evento>`
+}
+
+func TestCliente_Contingencia(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("deve transmitir pela SVC quando o autorizador normal esta paralisado", func(t *testing.T) {
+		normal := sefaz.NovoSimulador()
+		normal.Indisponivel.Store(true)
+		svc := httptest.NewServer(sefaz.NovoSimulador())
+		t.Cleanup(svc.Close)
+		t.Setenv("SEFAZ_SVC_URL", svc.URL)
+		c := clienteSimulador(t, normal)
+
+		if _, err := c.Transmitir(ctx, nfeAssinada(t, chaveTeste, "44555666000110"), chaveTeste); !errors.Is(err, sefaz.ErrSefazIndisponivel) {
+			t.Fatalf("esperava ErrSefazIndisponivel no autorizador normal, obteve: %v", err)
+		}
+		if ret, err := c.StatusServico(ctx); err != nil || ret.CStat != 108 {
+			t.Errorf("esperava status 108, obteve %v %v", ret, err)
+		}
+
+		contingencia, err := c.SVC()
+		if err != nil {
+			t.Fatalf("esperava cliente da SVC, obteve erro: %v", err)
+		}
+		prot, err := contingencia.Transmitir(ctx, nfeAssinada(t, chaveTeste, "44555666000110"), chaveTeste)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if prot.InfProt.CStat != dominio.CStatAutorizada {
+			t.Errorf("esperava autorizacao pela SVC, obteve %d %s", prot.InfProt.CStat, prot.InfProt.XMotivo)
+		}
+	})
+
+	t.Run("deve exigir SEFAZ_SVC_URL", func(t *testing.T) {
+		t.Setenv("SEFAZ_SVC_URL", "")
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		if _, err := c.SVC(); !errors.Is(err, sefaz.ErrSVCDesabilitada) {
+			t.Errorf("esperava ErrSVCDesabilitada, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve registrar o EPEC no Ambiente Nacional", func(t *testing.T) {
+		an := httptest.NewServer(sefaz.NovoSimulador())
+		t.Cleanup(an.Close)
+		t.Setenv("SEFAZ_EPEC_URL", an.URL)
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		evento := assinar(t, eventoEPECTeste(chaveTeste), "infEvento")
+
+		reg, err := c.RegistrarEPEC(ctx, evento)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if reg.CStat != sefaz.CStatEventoNaoVinculado || reg.NProt == "" {
+			t.Errorf("esperava registro 136 com protocolo, obteve %d %q", reg.CStat, reg.NProt)
+		}
+
+		// A repetição do envio cai na duplicidade e não é tratada como rejeição
+		if reg, err := c.RegistrarEPEC(ctx, evento); err != nil || reg.CStat != sefaz.CStatEventoDuplicado {
+			t.Errorf("esperava duplicidade 573, obteve %v %v", reg, err)
+		}
+	})
+
+	t.Run("deve recusar EPEC sem assinatura", func(t *testing.T) {
+		an := httptest.NewServer(sefaz.NovoSimulador())
+		t.Cleanup(an.Close)
+		t.Setenv("SEFAZ_EPEC_URL", an.URL)
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+
+		if _, err := c.RegistrarEPEC(ctx, []byte(eventoEPECTeste(chaveTeste))); !errors.Is(err, sefaz.ErrEventoRejeitado) {
+			t.Errorf("esperava ErrEventoRejeitado, obteve: %v", err)
+		}
+	})
+}
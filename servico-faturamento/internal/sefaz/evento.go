@@ -0,0 +1,77 @@
+package sefaz
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// RegistrarEvento envia ao autorizador da nota um evento assinado (o cancelamento
+// 110111) e devolve o registro com o nProt. A duplicidade (573) acontece quando um
+// envio anterior foi registrado mas a resposta se perdeu; o registro original é
+// buscado na consulta da nota, para que o protocolo não se perca.
+func (c *Cliente) RegistrarEvento(ctx context.Context, eventoAssinado []byte) (*InfRetEvento, error) {
+	inf, err := c.enviarEvento(ctx, eventoAssinado)
+	if err != nil {
+		return nil, err
+	}
+
+	switch inf.CStat {
+	case CStatEventoVinculado, CStatEventoForaPrazo:
+		return inf, nil
+	case CStatEventoDuplicado:
+		return c.registroAnterior(ctx, eventoAssinado)
+	default:
+		return nil, fmt.Errorf("%w: %d %s", ErrEventoRejeitado, inf.CStat, inf.XMotivo)
+	}
+}
+
+// enviarEvento manda o evento em um lote de um e devolve o registro dele
+func (c *Cliente) enviarEvento(ctx context.Context, eventoAssinado []byte) (*InfRetEvento, error) {
+	var ret RetEnvEvento
+	err := c.chamar(ctx, ServicoRecepcaoEvento, EnvEvento{
+		Versao: "1.00",
+		IdLote: fmt.Sprintf("%015d", time.Now().UnixNano()%1e15),
+		Evento: semDeclaracao(eventoAssinado),
+	}, &ret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cStatIndisponivel[ret.CStat]:
+		return nil, fmt.Errorf("%w: %d %s", ErrSefazIndisponivel, ret.CStat, ret.XMotivo)
+	case ret.CStat != CStatLoteEventoProcessado:
+		return nil, fmt.Errorf("%w: lote %d %s", ErrEventoRejeitado, ret.CStat, ret.XMotivo)
+	case len(ret.RetEvento) == 0:
+		return nil, fmt.Errorf("%w: lote processado sem retEvento", ErrRespostaInvalida)
+	}
+	inf := ret.RetEvento[0].InfRetEvento
+	return &inf, nil
+}
+
+// registroAnterior procura, entre os eventos da consulta da nota, o registro do
+// mesmo tipo e sequência do evento enviado
+func (c *Cliente) registroAnterior(ctx context.Context, eventoAssinado []byte) (*InfRetEvento, error) {
+	var ev struct {
+		ChNFe      string `xml:"infEvento>chNFe"`
+		TpEvento   string `xml:"infEvento>tpEvento"`
+		NSeqEvento string `xml:"infEvento>nSeqEvento"`
+	}
+	if err := xml.Unmarshal(eventoAssinado, &ev); err != nil {
+		return nil, fmt.Errorf("falha ao ler evento enviado: %w", err)
+	}
+
+	sit, err := c.ConsultarProtocolo(ctx, ev.ChNFe)
+	if err != nil {
+		return nil, err
+	}
+	for _, proc := range sit.ProcEventoNFe {
+		inf := proc.RetEvento.InfRetEvento
+		if inf.TpEvento == ev.TpEvento && inf.NSeqEvento == ev.NSeqEvento && inf.NProt != "" {
+			return &inf, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: evento %s em duplicidade sem registro na consulta da nota", ErrRespostaInvalida, ev.TpEvento)
+}
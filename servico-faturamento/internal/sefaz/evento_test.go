@@ -0,0 +1,86 @@
+package sefaz_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/sefaz"
+)
+
+// eventoCancelamentoTeste é um cancelamento reduzido aos campos conferidos pelo simulador
+func eventoCancelamentoTeste(chave, protocolo string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<evento xmlns="http://www.portalfiscal.inf.br/nfe" versao="1.00"><infEvento Id="ID110111` + chave + `01">` +
+		`<cOrgao>35</cOrgao><tpAmb>2</tpAmb><CNPJ>11222333000181</CNPJ><chNFe>` + chave + `</chNFe>` +
+		`<tpEvento>110111</tpEvento><nSeqEvento>1</nSeqEvento>` +
+		`<detEvento versao="1.00"><descEvento>Cancelamento</descEvento><nProt>` + protocolo + `</nProt>` +
+		`<xJust>Pedido cancelado pelo cliente</xJust></detEvento></infEvento></evento>`
+}
+
+func TestCliente_RegistrarEvento(t *testing.T) {
+	ctx := context.Background()
+
+	// autorizada sobe um simulador com a nota de teste autorizada e devolve o protocolo
+	autorizada := func(t *testing.T) (*sefaz.Cliente, string) {
+		t.Helper()
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		prot, err := c.Transmitir(ctx, nfeAssinada(t, chaveTeste, "44555666000110"), chaveTeste)
+		if err != nil || prot.InfProt.CStat != dominio.CStatAutorizada {
+			t.Fatalf("falha ao autorizar nota de teste: %v %v", prot, err)
+		}
+		return c, prot.InfProt.NProt
+	}
+
+	t.Run("deve registrar o cancelamento da nota autorizada", func(t *testing.T) {
+		c, protocolo := autorizada(t)
+
+		reg, err := c.RegistrarEvento(ctx, assinar(t, eventoCancelamentoTeste(chaveTeste, protocolo), "infEvento"))
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if reg.CStat != sefaz.CStatEventoVinculado || reg.NProt == "" {
+			t.Errorf("esperava registro 135 com protocolo, obteve %d %q", reg.CStat, reg.NProt)
+		}
+
+		sit, err := c.ConsultarProtocolo(ctx, chaveTeste)
+		if err != nil || sit.CStat != sefaz.CStatNotaCancelada {
+			t.Errorf("esperava consulta com cStat 101, obteve %v %v", sit, err)
+		}
+	})
+
+	t.Run("deve recuperar o protocolo do envio repetido", func(t *testing.T) {
+		c, protocolo := autorizada(t)
+		evento := assinar(t, eventoCancelamentoTeste(chaveTeste, protocolo), "infEvento")
+
+		primeiro, err := c.RegistrarEvento(ctx, evento)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		// A duplicidade (573) devolve o registro original, buscado na consulta da nota
+		repetido, err := c.RegistrarEvento(ctx, evento)
+		if err != nil {
+			t.Fatalf("esperava nil na duplicidade, obteve erro: %v", err)
+		}
+		if repetido.NProt != primeiro.NProt {
+			t.Errorf("esperava protocolo %s, obteve %s", primeiro.NProt, repetido.NProt)
+		}
+	})
+
+	t.Run("deve rejeitar cancelamento com outro protocolo de autorizacao", func(t *testing.T) {
+		c, _ := autorizada(t)
+		evento := assinar(t, eventoCancelamentoTeste(chaveTeste, "135259999999999"), "infEvento")
+		if _, err := c.RegistrarEvento(ctx, evento); !errors.Is(err, sefaz.ErrEventoRejeitado) {
+			t.Errorf("esperava ErrEventoRejeitado, obteve: %v", err)
+		}
+	})
+
+	t.Run("deve rejeitar cancelamento de nota que o autorizador nao conhece", func(t *testing.T) {
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		evento := assinar(t, eventoCancelamentoTeste(chaveTeste, "135250000000001"), "infEvento")
+		if _, err := c.RegistrarEvento(ctx, evento); !errors.Is(err, sefaz.ErrEventoRejeitado) {
+			t.Errorf("esperava ErrEventoRejeitado, obteve: %v", err)
+		}
+	})
+}
@@ -0,0 +1,247 @@
+package sefaz
+
+import (
+	"bytes"
+	"encoding/xml"
+)
+
+// Leiaute 4.00 das mensagens dos web services de autorização (MOC, anexo I)
+const (
+	NamespaceNFe = "http://www.portalfiscal.inf.br/nfe"
+	Versao       = "4.00"
+)
+
+// Códigos de situação das mensagens de lote e de serviço
+const (
+	CStatLoteRecebido        = 103
+	CStatLoteProcessado      = 104
+	CStatLoteEmProcessamento = 105
+	CStatServicoEmOperacao   = 107
+	CStatNaoConsta           = 217
+
+	// Recepção de eventos: lote processado, evento vinculado à NF-e, evento registrado
+	// sem vínculo (caso do EPEC, anterior à nota), cancelamento aceito fora do prazo
+	// e duplicidade do evento
+	CStatLoteEventoProcessado = 128
+	CStatEventoVinculado      = 135
+	CStatEventoNaoVinculado   = 136
+	CStatEventoForaPrazo      = 155
+	CStatEventoDuplicado      = 573
+
+	// CStatNotaCancelada é a situação da nota com cancelamento homologado na consulta
+	CStatNotaCancelada = 101
+)
+
+// cStatIndisponivel são os retornos em que a SEFAZ não processou o pedido e ele deve
+// ser repetido depois: serviço paralisado, consumo indevido e erro não catalogado
+var cStatIndisponivel = map[int]bool{108: true, 109: true, 656: true, 999: true}
+
+// ConsStatServ é o pedido de status do serviço (NFeStatusServico4)
+type ConsStatServ struct {
+	XMLName xml.Name `xml:"http://www.portalfiscal.inf.br/nfe consStatServ"`
+	Versao  string   `xml:"versao,attr"`
+	TpAmb   string   `xml:"tpAmb"`
+	CUF     string   `xml:"cUF"`
+	XServ   string   `xml:"xServ"`
+}
+
+// RetConsStatServ é o retorno do status do serviço
+type RetConsStatServ struct {
+	XMLName   xml.Name `xml:"http://www.portalfiscal.inf.br/nfe retConsStatServ" json:"-"`
+	Versao    string   `xml:"versao,attr" json:"versao"`
+	TpAmb     string   `xml:"tpAmb" json:"tpAmb"`
+	VerAplic  string   `xml:"verAplic" json:"verAplic"`
+	CStat     int      `xml:"cStat" json:"cStat"`
+	XMotivo   string   `xml:"xMotivo" json:"xMotivo"`
+	CUF       string   `xml:"cUF" json:"cUF"`
+	DhRecbto  string   `xml:"dhRecbto" json:"dhRecbto"`
+	TMed      int      `xml:"tMed,omitempty" json:"tMed,omitempty"`
+	DhRetorno string   `xml:"dhRetorno,omitempty" json:"dhRetorno,omitempty"`
+	XObs      string   `xml:"xObs,omitempty" json:"xObs,omitempty"`
+}
+
+// EnviNFe é o lote de autorização; NFe leva os documentos assinados, sem a declaração XML
+type EnviNFe struct {
+	XMLName xml.Name `xml:"http://www.portalfiscal.inf.br/nfe enviNFe"`
+	Versao  string   `xml:"versao,attr"`
+	IdLote  string   `xml:"idLote"`
+	IndSinc string   `xml:"indSinc"` // 1 = síncrono, com o protocolo na resposta
+	NFe     []byte   `xml:",innerxml"`
+}
+
+// RetEnviNFe é o retorno do lote: protocolo no modo síncrono (104) ou recibo (103)
+type RetEnviNFe struct {
+	XMLName  xml.Name `xml:"http://www.portalfiscal.inf.br/nfe retEnviNFe"`
+	Versao   string   `xml:"versao,attr"`
+	TpAmb    string   `xml:"tpAmb"`
+	VerAplic string   `xml:"verAplic"`
+	CStat    int      `xml:"cStat"`
+	XMotivo  string   `xml:"xMotivo"`
+	CUF      string   `xml:"cUF"`
+	DhRecbto string   `xml:"dhRecbto"`
+	InfRec   *InfRec  `xml:"infRec,omitempty"`
+	ProtNFe  *ProtNFe `xml:"protNFe,omitempty"`
+}
+
+// InfRec identifica o recibo do lote assíncrono
+type InfRec struct {
+	NRec string `xml:"nRec"`
+	TMed int    `xml:"tMed"`
+}
+
+// ConsReciNFe consulta o resultado do lote pelo recibo (NFeRetAutorizacao4)
+type ConsReciNFe struct {
+	XMLName xml.Name `xml:"http://www.portalfiscal.inf.br/nfe consReciNFe"`
+	Versao  string   `xml:"versao,attr"`
+	TpAmb   string   `xml:"tpAmb"`
+	NRec    string   `xml:"nRec"`
+}
+
+// RetConsReciNFe traz os protocolos das notas do lote, ou 105 enquanto ele é processado
+type RetConsReciNFe struct {
+	XMLName  xml.Name  `xml:"http://www.portalfiscal.inf.br/nfe retConsReciNFe"`
+	Versao   string    `xml:"versao,attr"`
+	TpAmb    string    `xml:"tpAmb"`
+	VerAplic string    `xml:"verAplic"`
+	NRec     string    `xml:"nRec"`
+	CStat    int       `xml:"cStat"`
+	XMotivo  string    `xml:"xMotivo"`
+	CUF      string    `xml:"cUF"`
+	DhRecbto string    `xml:"dhRecbto"`
+	ProtNFe  []ProtNFe `xml:"protNFe"`
+}
+
+// ConsSitNFe consulta a situação da nota pela chave (NFeConsultaProtocolo4)
+type ConsSitNFe struct {
+	XMLName xml.Name `xml:"http://www.portalfiscal.inf.br/nfe consSitNFe"`
+	Versao  string   `xml:"versao,attr"`
+	TpAmb   string   `xml:"tpAmb"`
+	XServ   string   `xml:"xServ"`
+	ChNFe   string   `xml:"chNFe"`
+}
+
+// RetConsSitNFe é a situação da nota, com o protocolo de autorização e os eventos
+// registrados quando houver
+type RetConsSitNFe struct {
+	XMLName       xml.Name        `xml:"http://www.portalfiscal.inf.br/nfe retConsSitNFe"`
+	Versao        string          `xml:"versao,attr"`
+	TpAmb         string          `xml:"tpAmb"`
+	VerAplic      string          `xml:"verAplic"`
+	CStat         int             `xml:"cStat"`
+	XMotivo       string          `xml:"xMotivo"`
+	CUF           string          `xml:"cUF"`
+	DhRecbto      string          `xml:"dhRecbto"`
+	ChNFe         string          `xml:"chNFe"`
+	ProtNFe       *ProtNFe        `xml:"protNFe,omitempty"`
+	ProcEventoNFe []ProcEventoNFe `xml:"procEventoNFe,omitempty"`
+}
+
+// ProcEventoNFe é um evento da nota com o registro da SEFAZ; na consulta interessa
+// só o registro
+type ProcEventoNFe struct {
+	Versao    string    `xml:"versao,attr"`
+	RetEvento RetEvento `xml:"retEvento"`
+}
+
+// EnvEvento é o lote de eventos (NFeRecepcaoEvento4); Evento leva os eventos
+// assinados, sem a declaração XML
+type EnvEvento struct {
+	XMLName xml.Name `xml:"http://www.portalfiscal.inf.br/nfe envEvento"`
+	Versao  string   `xml:"versao,attr"`
+	IdLote  string   `xml:"idLote"`
+	Evento  []byte   `xml:",innerxml"`
+}
+
+// RetEnvEvento é o retorno do lote de eventos, com o resultado de cada evento
+type RetEnvEvento struct {
+	XMLName   xml.Name    `xml:"http://www.portalfiscal.inf.br/nfe retEnvEvento"`
+	Versao    string      `xml:"versao,attr"`
+	IdLote    string      `xml:"idLote"`
+	TpAmb     string      `xml:"tpAmb"`
+	VerAplic  string      `xml:"verAplic"`
+	COrgao    string      `xml:"cOrgao"`
+	CStat     int         `xml:"cStat"`
+	XMotivo   string      `xml:"xMotivo"`
+	RetEvento []RetEvento `xml:"retEvento"`
+}
+
+// RetEvento é o registro de um evento
+type RetEvento struct {
+	Versao       string       `xml:"versao,attr"`
+	InfRetEvento InfRetEvento `xml:"infEvento"`
+}
+
+// InfRetEvento são os dados do registro do evento; nProt só vem nos eventos aceitos
+type InfRetEvento struct {
+	ID          string `xml:"Id,attr,omitempty"`
+	TpAmb       string `xml:"tpAmb"`
+	VerAplic    string `xml:"verAplic"`
+	COrgao      string `xml:"cOrgao"`
+	CStat       int    `xml:"cStat"`
+	XMotivo     string `xml:"xMotivo"`
+	ChNFe       string `xml:"chNFe,omitempty"`
+	TpEvento    string `xml:"tpEvento,omitempty"`
+	XEvento     string `xml:"xEvento,omitempty"`
+	NSeqEvento  string `xml:"nSeqEvento,omitempty"`
+	DhRegEvento string `xml:"dhRegEvento,omitempty"`
+	NProt       string `xml:"nProt,omitempty"`
+}
+
+// ProtNFe é o protocolo da nota. Interno guarda o conteúdo recebido da SEFAZ, com
+// a assinatura do protocolo quando houver, e é reproduzido no nfeProc.
+type ProtNFe struct {
+	Versao  string  `xml:"versao,attr"`
+	InfProt InfProt `xml:"infProt"`
+	Interno string  `xml:",innerxml"`
+}
+
+// InfProt são os dados do protocolo
+type InfProt struct {
+	XMLName  xml.Name `xml:"infProt"`
+	ID       string   `xml:"Id,attr,omitempty"`
+	TpAmb    string   `xml:"tpAmb"`
+	VerAplic string   `xml:"verAplic"`
+	ChNFe    string   `xml:"chNFe"`
+	DhRecbto string   `xml:"dhRecbto"`
+	NProt    string   `xml:"nProt,omitempty"`
+	DigVal   string   `xml:"digVal,omitempty"`
+	CStat    int      `xml:"cStat"`
+	XMotivo  string   `xml:"xMotivo"`
+}
+
+// MontarNFeProc junta a NF-e assinada e o protocolo no nfeProc, o XML de distribuição
+// que acompanha a mercadoria e é guardado pelo emitente
+func MontarNFeProc(nfeAssinada []byte, prot ProtNFe) ([]byte, error) {
+	interno := prot.Interno
+	if interno == "" {
+		corpo, err := xml.Marshal(prot.InfProt)
+		if err != nil {
+			return nil, err
+		}
+		interno = string(corpo)
+	}
+	versao := prot.Versao
+	if versao == "" {
+		versao = Versao
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header[:len(xml.Header)-1])
+	buf.WriteString(`<nfeProc xmlns="` + NamespaceNFe + `" versao="` + Versao + `">`)
+	buf.Write(semDeclaracao(nfeAssinada))
+	buf.WriteString(`<protNFe versao="` + versao + `">`)
+	buf.WriteString(interno)
+	buf.WriteString(`</protNFe></nfeProc>`)
+	return buf.Bytes(), nil
+}
+
+// semDeclaracao remove o <?xml ...?> do início do documento, para embuti-lo em outro
+func semDeclaracao(documento []byte) []byte {
+	documento = bytes.TrimSpace(documento)
+	if bytes.HasPrefix(documento, []byte("<?xml")) {
+		if fim := bytes.Index(documento, []byte("?>")); fim >= 0 {
+			return bytes.TrimSpace(documento[fim+2:])
+		}
+	}
+	return documento
+}
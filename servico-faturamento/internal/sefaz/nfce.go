@@ -0,0 +1,16 @@
+package sefaz
+
+import "errors"
+
+// ErrNFCeDesabilitada indica NFC-e sem endereço do autorizador configurado
+var ErrNFCeDesabilitada = errors.New("autorizador da NFC-e nao configurado (SEFAZ_NFCE_URL)")
+
+// NFCe devolve o cliente apontado para o autorizador da NFC-e da UF, que recebe
+// as notas modelo 65 com as mesmas mensagens da NF-e. Não há SVC nem EPEC para
+// a NFC-e: na contingência ela é emitida offline e transmitida depois por aqui.
+func (c *Cliente) NFCe() (*Cliente, error) {
+	if !c.cfg.HabilitadaNFCe() {
+		return nil, ErrNFCeDesabilitada
+	}
+	return c.comURLs(c.cfg.URLsNFCe), nil
+}
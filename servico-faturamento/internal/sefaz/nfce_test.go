@@ -0,0 +1,72 @@
+package sefaz_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"servico-faturamento/internal/dominio"
+	"servico-faturamento/internal/sefaz"
+)
+
+const chaveNFCeTeste = "35250311222333000181650010000000101000000108"
+
+// nfceTeste é uma NFC-e reduzida aos campos conferidos pelo simulador
+func nfceTeste(chave, qrCode string) string {
+	supl := ""
+	if qrCode != "" {
+		supl = `<infNFeSupl><qrCode><![CDATA[` + qrCode + `]]></qrCode><urlChave>www.sefaz.rs.gov.br/nfce/consulta</urlChave></infNFeSupl>`
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<NFe xmlns="http://www.portalfiscal.inf.br/nfe"><infNFe versao="4.00" Id="NFe` + chave + `">` +
+		`<ide><cUF>35</cUF><mod>65</mod><tpAmb>2</tpAmb></ide>` +
+		`<emit><CNPJ>11222333000181</CNPJ></emit></infNFe>` + supl + `</NFe>`
+}
+
+func TestCliente_NFCe(t *testing.T) {
+	ctx := context.Background()
+	if err := dominio.ValidarChaveAcesso(chaveNFCeTeste); err != nil {
+		t.Fatalf("chave de teste invalida: %v", err)
+	}
+
+	t.Run("deve transmitir a NFC-e ao autorizador proprio", func(t *testing.T) {
+		autorizador := httptest.NewServer(sefaz.NovoSimulador())
+		t.Cleanup(autorizador.Close)
+		t.Setenv("SEFAZ_NFCE_URL", autorizador.URL)
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+
+		nfce, err := c.NFCe()
+		if err != nil {
+			t.Fatalf("esperava cliente da NFC-e, obteve erro: %v", err)
+		}
+		qrCode := "https://www.sefaz.rs.gov.br/NFCE/NFCE-COM.aspx?p=" + chaveNFCeTeste + "|2|2|1|" + strings.Repeat("A", 40)
+		prot, err := nfce.Transmitir(ctx, assinar(t, nfceTeste(chaveNFCeTeste, qrCode), "infNFe"), chaveNFCeTeste)
+		if err != nil {
+			t.Fatalf("esperava nil, obteve erro: %v", err)
+		}
+		if prot.InfProt.CStat != dominio.CStatAutorizada {
+			t.Errorf("esperava autorizacao, obteve %d %s", prot.InfProt.CStat, prot.InfProt.XMotivo)
+		}
+	})
+
+	t.Run("deve rejeitar NFC-e sem QR Code", func(t *testing.T) {
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		prot, err := c.Transmitir(ctx, assinar(t, nfceTeste(chaveNFCeTeste, ""), "infNFe"), chaveNFCeTeste)
+		if err != nil {
+			t.Fatalf("esperava protocolo de rejeicao, obteve erro: %v", err)
+		}
+		if prot.InfProt.CStat != 225 {
+			t.Errorf("esperava rejeicao 225, obteve %d %s", prot.InfProt.CStat, prot.InfProt.XMotivo)
+		}
+	})
+
+	t.Run("deve exigir SEFAZ_NFCE_URL", func(t *testing.T) {
+		t.Setenv("SEFAZ_NFCE_URL", "")
+		c := clienteSimulador(t, sefaz.NovoSimulador())
+		if _, err := c.NFCe(); !errors.Is(err, sefaz.ErrNFCeDesabilitada) {
+			t.Errorf("esperava ErrNFCeDesabilitada, obteve: %v", err)
+		}
+	})
+}
@@ -0,0 +1,435 @@
+package sefaz
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"servico-faturamento/internal/assinatura"
+	"servico-faturamento/internal/dominio"
+)
+
+// versaoSimulador é o verAplic das respostas do simulador
+const versaoSimulador = "SIMULADOR-4.00"
+
+// Simulador responde como os web services de autorização da SEFAZ, para testes e
+// desenvolvimento local. Confere a assinatura das notas, autoriza as válidas, nega
+// as dos destinatários listados em Denegar e guarda os protocolos em memória para
+// a consulta por chave e a detecção de duplicidade. Também registra o EPEC, como
+// a recepção de eventos do Ambiente Nacional, e o cancelamento das notas que
+// autorizou.
+type Simulador struct {
+	// Denegar lista CPF/CNPJ de destinatários em situação irregular (302)
+	Denegar map[string]bool
+	// Assincrono responde o lote com recibo (103); o protocolo vem na consulta do recibo
+	Assincrono bool
+	// Agora permite fixar o relógio nos testes
+	Agora func() time.Time
+	// Indisponivel responde os serviços de autorização com 108 (serviço paralisado),
+	// para exercitar a contingência
+	Indisponivel atomic.Bool
+
+	mu         sync.Mutex
+	sequencia  int64
+	protocolos map[string]ProtNFe      // por chave de acesso
+	recibos    map[string][]ProtNFe    // por nRec
+	eventos    map[string]InfRetEvento // por Id do evento
+}
+
+// NovoSimulador cria o simulador sem notas autorizadas
+func NovoSimulador() *Simulador {
+	return &Simulador{
+		Denegar:    map[string]bool{},
+		Agora:      time.Now,
+		protocolos: map[string]ProtNFe{},
+		recibos:    map[string][]ProtNFe{},
+		eventos:    map[string]InfRetEvento{},
+	}
+}
+
+// nfeRecebida são os campos da NF-e conferidos pelo simulador
+type nfeRecebida struct {
+	InfNFe struct {
+		ID  string `xml:"Id,attr"`
+		Ide struct {
+			Mod   string `xml:"mod"`
+			TpAmb string `xml:"tpAmb"`
+		} `xml:"ide"`
+		Dest struct {
+			CNPJ string `xml:"CNPJ"`
+			CPF  string `xml:"CPF"`
+		} `xml:"dest"`
+	} `xml:"infNFe"`
+	QRCode      string `xml:"infNFeSupl>qrCode"`
+	DigestValue string `xml:"Signature>SignedInfo>Reference>DigestValue"`
+	Interno     string `xml:",innerxml"`
+}
+
+// eventoRecebido são os campos do evento conferidos pelo simulador
+type eventoRecebido struct {
+	InfEvento struct {
+		ID       string `xml:"Id,attr"`
+		COrgao   string `xml:"cOrgao"`
+		TpAmb    string `xml:"tpAmb"`
+		ChNFe    string `xml:"chNFe"`
+		TpEvento string `xml:"tpEvento"`
+		NSeq     string `xml:"nSeqEvento"`
+		NProt    string `xml:"detEvento>nProt"`
+	} `xml:"infEvento"`
+	Interno string `xml:",innerxml"`
+}
+
+type loteEventos struct {
+	IdLote string           `xml:"idLote"`
+	Evento []eventoRecebido `xml:"evento"`
+}
+
+type loteRecebido struct {
+	IdLote  string        `xml:"idLote"`
+	IndSinc string        `xml:"indSinc"`
+	NFe     []nfeRecebida `xml:"NFe"`
+}
+
+func (s *Simulador) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST com envelope SOAP 1.2", http.StatusMethodNotAllowed)
+		return
+	}
+	corpo, err := io.ReadAll(io.LimitReader(r.Body, tamanhoMaximoResposta))
+	if err != nil {
+		s.responderFault(w, "soap12:Receiver", err.Error())
+		return
+	}
+
+	wsdl, mensagem, err := lerDadosMsg(corpo)
+	if err != nil {
+		s.responderFault(w, "soap12:Sender", err.Error())
+		return
+	}
+
+	var retorno interface{}
+	switch wsdl {
+	case ServicoStatusServico.namespace():
+		retorno = s.statusServico()
+	case ServicoAutorizacao.namespace():
+		var lote loteRecebido
+		if err := xml.Unmarshal(mensagem, &lote); err != nil {
+			s.responderFault(w, "soap12:Sender", err.Error())
+			return
+		}
+		retorno = s.autorizar(lote)
+	case ServicoRetAutorizacao.namespace():
+		var cons ConsReciNFe
+		if err := xml.Unmarshal(mensagem, &cons); err != nil {
+			s.responderFault(w, "soap12:Sender", err.Error())
+			return
+		}
+		retorno = s.consultarRecibo(cons.NRec)
+	case ServicoConsultaProtocolo.namespace():
+		var cons ConsSitNFe
+		if err := xml.Unmarshal(mensagem, &cons); err != nil {
+			s.responderFault(w, "soap12:Sender", err.Error())
+			return
+		}
+		retorno = s.consultarProtocolo(cons.ChNFe)
+	case ServicoRecepcaoEvento.namespace():
+		var lote loteEventos
+		if err := xml.Unmarshal(mensagem, &lote); err != nil {
+			s.responderFault(w, "soap12:Sender", err.Error())
+			return
+		}
+		retorno = s.receberEventos(lote)
+	default:
+		s.responderFault(w, "soap12:Sender", fmt.Sprintf("servico nao suportado pelo simulador: %q", wsdl))
+		return
+	}
+
+	conteudo, err := xml.Marshal(retorno)
+	if err != nil {
+		s.responderFault(w, "soap12:Receiver", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><soap:Envelope xmlns:soap="%s"><soap:Body><nfeResultMsg xmlns="%s">%s</nfeResultMsg></soap:Body></soap:Envelope>`,
+		NamespaceSOAP, wsdl, conteudo)
+}
+
+// lerDadosMsg devolve o namespace do nfeDadosMsg, que identifica o serviço, e a mensagem dentro dele
+func lerDadosMsg(envelope []byte) (string, []byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(envelope))
+	var wsdl string
+	for {
+		offset := dec.InputOffset()
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return "", nil, errors.New("envelope sem nfeDadosMsg")
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		inicio, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if inicio.Name.Local == "nfeDadosMsg" {
+			wsdl = inicio.Name.Space
+			continue
+		}
+		if wsdl != "" {
+			if err := dec.Skip(); err != nil {
+				return "", nil, err
+			}
+			return wsdl, envelope[offset:dec.InputOffset()], nil
+		}
+	}
+}
+
+func (s *Simulador) responderFault(w http.ResponseWriter, codigo, motivo string) {
+	w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+	w.WriteHeader(http.StatusInternalServerError)
+	var texto bytes.Buffer
+	xml.EscapeText(&texto, []byte(motivo))
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><soap:Envelope xmlns:soap="%s"><soap:Body><soap:Fault><soap:Code><soap:Value>%s</soap:Value></soap:Code><soap:Reason><soap:Text xml:lang="pt-BR">%s</soap:Text></soap:Reason></soap:Fault></soap:Body></soap:Envelope>`,
+		NamespaceSOAP, codigo, texto.String())
+}
+
+func (s *Simulador) dhRecbto() string {
+	return s.Agora().In(dominio.FusoBrasilia).Format("2006-01-02T15:04:05-07:00")
+}
+
+func (s *Simulador) statusServico() RetConsStatServ {
+	ret := RetConsStatServ{
+		Versao:   Versao,
+		TpAmb:    "2",
+		VerAplic: versaoSimulador,
+		CStat:    CStatServicoEmOperacao,
+		XMotivo:  "Servico em Operacao",
+		CUF:      "35",
+		DhRecbto: s.dhRecbto(),
+		TMed:     1,
+	}
+	if s.Indisponivel.Load() {
+		ret.CStat, ret.XMotivo, ret.TMed = 108, "Servico Paralisado Momentaneamente (curto prazo)", 0
+	}
+	return ret
+}
+
+func (s *Simulador) autorizar(lote loteRecebido) RetEnviNFe {
+	ret := RetEnviNFe{Versao: Versao, TpAmb: "2", VerAplic: versaoSimulador, CUF: "35", DhRecbto: s.dhRecbto()}
+	if s.Indisponivel.Load() {
+		ret.CStat, ret.XMotivo = 108, "Servico Paralisado Momentaneamente (curto prazo)"
+		return ret
+	}
+	if len(lote.NFe) == 0 || (lote.IndSinc == "1" && len(lote.NFe) > 1) {
+		ret.CStat, ret.XMotivo = 225, "Rejeicao: Falha no Schema XML do lote de NFe"
+		return ret
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	protocolos := make([]ProtNFe, 0, len(lote.NFe))
+	for _, nota := range lote.NFe {
+		protocolos = append(protocolos, s.processarNFe(nota))
+	}
+	if len(protocolos) > 0 {
+		if chave := protocolos[0].InfProt.ChNFe; len(chave) >= 2 {
+			ret.CUF = chave[:2]
+		}
+		ret.TpAmb = protocolos[0].InfProt.TpAmb
+	}
+
+	if s.Assincrono || lote.IndSinc != "1" {
+		s.sequencia++
+		nRec := fmt.Sprintf("%s%013d", ret.CUF, s.sequencia)
+		s.recibos[nRec] = protocolos
+		ret.CStat, ret.XMotivo = CStatLoteRecebido, "Lote recebido com sucesso"
+		ret.InfRec = &InfRec{NRec: nRec, TMed: 1}
+		return ret
+	}
+	ret.CStat, ret.XMotivo = CStatLoteProcessado, "Lote processado"
+	ret.ProtNFe = &protocolos[0]
+	return ret
+}
+
+// processarNFe valida a nota e gera o protocolo; chamado com o mutex travado
+func (s *Simulador) processarNFe(nota nfeRecebida) ProtNFe {
+	chave := strings.TrimPrefix(nota.InfNFe.ID, "NFe")
+	tpAmb := nota.InfNFe.Ide.TpAmb
+	if tpAmb == "" {
+		tpAmb = "2"
+	}
+	prot := ProtNFe{Versao: Versao, InfProt: InfProt{
+		TpAmb: tpAmb, VerAplic: versaoSimulador, ChNFe: chave, DhRecbto: s.dhRecbto(),
+	}}
+	rejeitar := func(cStat int, xMotivo string) ProtNFe {
+		prot.InfProt.CStat, prot.InfProt.XMotivo = cStat, xMotivo
+		return prot
+	}
+
+	if err := dominio.ValidarChaveAcesso(chave); err != nil {
+		return rejeitar(502, "Rejeicao: Erro na Chave de Acesso - Campo Id nao corresponde a concatenacao dos campos correspondentes")
+	}
+	if nota.InfNFe.Ide.Mod == "65" && nota.QRCode == "" {
+		return rejeitar(225, "Rejeicao: Falha no Schema XML da NFe (NFC-e sem infNFeSupl/qrCode)")
+	}
+	documento := `<NFe xmlns="` + NamespaceNFe + `">` + nota.Interno + `</NFe>`
+	if _, err := assinatura.Verificar([]byte(documento)); err != nil {
+		if errors.Is(err, assinatura.ErrDocumentoSemAssinatura) {
+			return rejeitar(225, "Rejeicao: Falha no Schema XML da NFe (assinatura ausente)")
+		}
+		slog.Info("Simulador SEFAZ: assinatura invalida", "chave", chave, "erro", err)
+		return rejeitar(297, "Rejeicao: Assinatura difere do calculado")
+	}
+	if anterior, ok := s.protocolos[chave]; ok {
+		return rejeitar(dominio.CStatDuplicidade, "Rejeicao: Duplicidade de NF-e [nProt:"+anterior.InfProt.NProt+"]")
+	}
+
+	s.sequencia++
+	prot.InfProt.NProt = fmt.Sprintf("1%s%s%010d", chave[:2], s.Agora().In(dominio.FusoBrasilia).Format("06"), s.sequencia)
+	prot.InfProt.DigVal = nota.DigestValue
+	prot.InfProt.ID = "ID" + prot.InfProt.NProt
+
+	dest := nota.InfNFe.Dest.CNPJ + nota.InfNFe.Dest.CPF
+	if dest != "" && s.Denegar[dest] {
+		prot.InfProt.CStat, prot.InfProt.XMotivo = 302, "Uso Denegado: Irregularidade fiscal do destinatario"
+	} else {
+		prot.InfProt.CStat, prot.InfProt.XMotivo = dominio.CStatAutorizada, "Autorizado o uso da NF-e"
+	}
+	s.protocolos[chave] = prot
+	return prot
+}
+
+func (s *Simulador) consultarRecibo(nRec string) RetConsReciNFe {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret := RetConsReciNFe{Versao: Versao, TpAmb: "2", VerAplic: versaoSimulador, NRec: nRec, CUF: "35", DhRecbto: s.dhRecbto()}
+	protocolos, ok := s.recibos[nRec]
+	if !ok {
+		ret.CStat, ret.XMotivo = 106, "Lote nao localizado"
+		return ret
+	}
+	ret.CStat, ret.XMotivo = CStatLoteProcessado, "Lote processado"
+	ret.ProtNFe = protocolos
+	return ret
+}
+
+func (s *Simulador) consultarProtocolo(chave string) RetConsSitNFe {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ret := RetConsSitNFe{Versao: Versao, TpAmb: "2", VerAplic: versaoSimulador, CUF: "35", DhRecbto: s.dhRecbto(), ChNFe: chave}
+	prot, ok := s.protocolos[chave]
+	if !ok {
+		ret.CStat, ret.XMotivo = CStatNaoConsta, "Rejeicao: NF-e nao consta na base de dados da SEFAZ"
+		return ret
+	}
+	ret.TpAmb = prot.InfProt.TpAmb
+	ret.CStat, ret.XMotivo = prot.InfProt.CStat, prot.InfProt.XMotivo
+	ret.ProtNFe = &prot
+	for _, reg := range s.eventos {
+		if reg.ChNFe != chave {
+			continue
+		}
+		ret.ProcEventoNFe = append(ret.ProcEventoNFe, ProcEventoNFe{Versao: "1.00", RetEvento: RetEvento{Versao: "1.00", InfRetEvento: reg}})
+		if reg.TpEvento == dominio.TipoEventoCancelamento {
+			ret.CStat, ret.XMotivo = CStatNotaCancelada, "Cancelamento de NF-e homologado"
+		}
+	}
+	return ret
+}
+
+// cancelada informa se a nota já tem o cancelamento registrado; chamado com o mutex travado
+func (s *Simulador) cancelada(chave string) bool {
+	for _, reg := range s.eventos {
+		if reg.ChNFe == chave && reg.TpEvento == dominio.TipoEventoCancelamento {
+			return true
+		}
+	}
+	return false
+}
+
+// receberEventos registra os eventos assinados do lote. O EPEC é registrado sem
+// vínculo (136), pois a nota ainda não existe no autorizador; os demais eventos
+// exigem a nota autorizada pelo simulador (135).
+func (s *Simulador) receberEventos(lote loteEventos) RetEnvEvento {
+	ret := RetEnvEvento{Versao: "1.00", IdLote: lote.IdLote, TpAmb: "2", VerAplic: versaoSimulador, COrgao: dominio.COrgaoAmbienteNacional}
+	if len(lote.Evento) == 0 || len(lote.Evento) > 20 {
+		ret.CStat, ret.XMotivo = 225, "Rejeicao: Falha no Schema XML do lote de eventos"
+		return ret
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, evento := range lote.Evento {
+		ret.RetEvento = append(ret.RetEvento, RetEvento{Versao: "1.00", InfRetEvento: s.processarEvento(evento)})
+	}
+	ret.CStat, ret.XMotivo = CStatLoteEventoProcessado, "Lote de Evento Processado"
+	return ret
+}
+
+// processarEvento valida o evento e gera o registro; chamado com o mutex travado
+func (s *Simulador) processarEvento(evento eventoRecebido) InfRetEvento {
+	inf := evento.InfEvento
+	reg := InfRetEvento{
+		TpAmb: inf.TpAmb, VerAplic: versaoSimulador, COrgao: inf.COrgao,
+		ChNFe: inf.ChNFe, TpEvento: inf.TpEvento, NSeqEvento: inf.NSeq,
+	}
+	rejeitar := func(cStat int, xMotivo string) InfRetEvento {
+		reg.CStat, reg.XMotivo = cStat, xMotivo
+		return reg
+	}
+
+	if err := dominio.ValidarChaveAcesso(inf.ChNFe); err != nil {
+		return rejeitar(236, "Rejeicao: Chave de Acesso com digito verificador invalido")
+	}
+	documento := `<evento xmlns="` + NamespaceNFe + `">` + evento.Interno + `</evento>`
+	if _, err := assinatura.Verificar([]byte(documento)); err != nil {
+		if errors.Is(err, assinatura.ErrDocumentoSemAssinatura) {
+			return rejeitar(225, "Rejeicao: Falha no Schema XML do evento (assinatura ausente)")
+		}
+		slog.Info("Simulador SEFAZ: assinatura invalida no evento", "id", inf.ID, "erro", err)
+		return rejeitar(297, "Rejeicao: Assinatura difere do calculado")
+	}
+	if _, ok := s.eventos[inf.ID]; ok {
+		return rejeitar(CStatEventoDuplicado, "Rejeicao: Duplicidade de evento")
+	}
+
+	switch {
+	case inf.TpEvento == dominio.TipoEventoEPEC:
+		if inf.COrgao != dominio.COrgaoAmbienteNacional {
+			return rejeitar(489, "Rejeicao: EPEC deve ser enviado ao Ambiente Nacional")
+		}
+		reg.CStat, reg.XMotivo = CStatEventoNaoVinculado, "Evento registrado, mas nao vinculado a NF-e"
+		reg.XEvento = dominio.DescEventoEPEC
+	case s.protocolos[inf.ChNFe].InfProt.CStat != dominio.CStatAutorizada:
+		return rejeitar(494, "Rejeicao: Chave de Acesso inexistente")
+	case s.cancelada(inf.ChNFe):
+		return rejeitar(218, "Rejeicao: NF-e ja esta cancelada na base de dados da SEFAZ")
+	case inf.TpEvento == dominio.TipoEventoCancelamento && inf.NProt != s.protocolos[inf.ChNFe].InfProt.NProt:
+		return rejeitar(222, "Rejeicao: Protocolo de Autorizacao de Uso difere do cadastrado")
+	default:
+		reg.CStat, reg.XMotivo = CStatEventoVinculado, "Evento registrado e vinculado a NF-e"
+		if inf.TpEvento == dominio.TipoEventoCancelamento {
+			reg.XEvento = dominio.DescEventoCancelamento
+		}
+	}
+
+	s.sequencia++
+	reg.NProt = fmt.Sprintf("8%s%s%010d", dominio.COrgaoAmbienteNacional, s.Agora().In(dominio.FusoBrasilia).Format("06"), s.sequencia)
+	reg.ID = "ID" + reg.NProt
+	reg.DhRegEvento = s.dhRecbto()
+	s.eventos[inf.ID] = reg
+	return reg
+}
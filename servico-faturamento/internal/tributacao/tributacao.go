@@ -0,0 +1,65 @@
+package tributacao
+
+import (
+	"fmt"
+	"time"
+
+	"servico-faturamento/internal/dominio"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Aplicar calcula os tributos dos itens com as regras vigentes na data da
+// operação e os grava junto com a nota. Deve ser chamado na transação do
+// fechamento, depois de Fechar: os valores gravados não mudam quando o fiscal
+// altera as regras depois.
+func Aplicar(tx *gorm.DB, nota *dominio.NotaFiscal, op dominio.OperacaoTributaria) error {
+	regras, err := Vigentes(tx, op)
+	if err != nil {
+		return err
+	}
+	if op.AliquotaIBSCBS == nil {
+		if op.AliquotaIBSCBS, err = AliquotaIBSCBSVigente(tx, op.Data); err != nil {
+			return err
+		}
+	}
+
+	nota.CalcularTributos(regras, op)
+
+	for i := range nota.Itens {
+		if err := tx.Omit(clause.Associations).Save(&nota.Itens[i]).Error; err != nil {
+			return fmt.Errorf("falha ao gravar tributos do item %s: %w", nota.Itens[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// Vigentes carrega as regras que podem atender a operação; a seleção por item
+// fica com dominio.SelecionarRegra
+func Vigentes(tx *gorm.DB, op dominio.OperacaoTributaria) ([]dominio.RegraTributaria, error) {
+	var regras []dominio.RegraTributaria
+	err := tx.
+		Where("regime = '' OR regime IS NULL OR regime = ?", op.Regime).
+		Where("vigencia_inicio IS NULL OR vigencia_inicio <= ?", op.Data).
+		Where("vigencia_fim IS NULL OR vigencia_fim > ?", op.Data).
+		Find(&regras).Error
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar regras tributarias: %w", err)
+	}
+	return regras, nil
+}
+
+// AliquotaIBSCBSVigente devolve as alíquotas de IBS/CBS do período que cobre a
+// data, ou nil quando nenhum período foi cadastrado
+func AliquotaIBSCBSVigente(tx *gorm.DB, data time.Time) (*dominio.AliquotaIBSCBS, error) {
+	var periodos []dominio.AliquotaIBSCBS
+	err := tx.
+		Where("vigencia_inicio <= ?", data).
+		Where("vigencia_fim IS NULL OR vigencia_fim > ?", data).
+		Find(&periodos).Error
+	if err != nil {
+		return nil, fmt.Errorf("falha ao carregar aliquotas de IBS/CBS: %w", err)
+	}
+	return dominio.SelecionarAliquotaIBSCBS(periodos, data), nil
+}
@@ -20,7 +20,7 @@ echo -e "\n"
 echo "2. Criando nota fiscal..."
 NOTA_RESPONSE=$(curl -s -X POST "$API_URL/notas" \
   -H "Content-Type: application/json" \
-  -d '{"numero": "NF-2025-001"}')
+  -d '{}')
 
 echo "$NOTA_RESPONSE" | jq .
 NOTA_ID=$(echo "$NOTA_RESPONSE" | jq -r '.id')
@@ -32,9 +32,9 @@ echo "3. Adicionando item 1 à nota..."
 curl -s -X POST "$API_URL/notas/$NOTA_ID/itens" \
   -H "Content-Type: application/json" \
   -d "{
-    \"produto_id\": \"$PRODUTO_ID\",
-    \"quantidade\": 10,
-    \"preco_unitario\": 99.90
+    \"produtoId\": \"$PRODUTO_ID\",
+    \"quantidade\": \"10\",
+    \"precoUnitario\": \"99.90\"
   }" | jq .
 echo -e "\n"
 
@@ -43,9 +43,9 @@ echo "4. Adicionando item 2 à nota..."
 curl -s -X POST "$API_URL/notas/$NOTA_ID/itens" \
   -H "Content-Type: application/json" \
   -d "{
-    \"produto_id\": \"$PRODUTO_ID\",
-    \"quantidade\": 5,
-    \"preco_unitario\": 49.90
+    \"produtoId\": \"$PRODUTO_ID\",
+    \"quantidade\": \"5\",
+    \"precoUnitario\": \"49.90\"
   }" | jq .
 echo -e "\n"
 
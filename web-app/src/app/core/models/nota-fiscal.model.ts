@@ -1,26 +1,71 @@
 export interface NotaFiscal {
   id: string;
   numero: string;
-  status: 'ABERTA' | 'FECHADA';
+  modelo?: string;
+  serie?: number;
+  emitenteId?: string;
+  clienteId?: string;
+  status: 'ABERTA' | 'FECHADA' | 'AUTORIZADA' | 'REJEITADA' | 'DENEGADA' | 'CANCELADA';
   dataCriacao: string;
   dataFechada?: string;
+  // Retorno da SEFAZ
+  protocoloAutorizacao?: string;
+  cStat?: number;
+  xMotivo?: string;
+  dataAutorizacao?: string;
+  dataCancelamento?: string;
+  justificativaCancelamento?: string;
   itens?: ItemNota[];
+  totais?: TotaisNota;
+}
+
+// CC-e: a carta vigente (maior sequencia) substitui as anteriores
+export interface CartaCorrecao {
+  id: string;
+  notaId: string;
+  sequencia: number;
+  correcao: string;
+  dataEvento: string;
+  vigente: boolean;
+}
+
+// Valores decimais como strings, calculados pelo motor tributario no fechamento
+export interface TotaisNota {
+  vProd: string;
+  vBC: string;
+  vICMS: string;
+  vIPI: string;
+  vPIS: string;
+  vCOFINS: string;
+  vNF: string;
+  // Reforma Tributaria: informativos, nao compoem o vNF na fase de teste
+  vBCIBSCBS?: string;
+  vIBSUF?: string;
+  vIBSMun?: string;
+  vIBS?: string;
+  vCBS?: string;
 }
 
 export interface ItemNota {
   id: string;
   notaId: string;
   produtoId: string;
-  quantidade: number;
-  precoUnitario: number;
+  quantidade: string;
+  precoUnitario: string;
+  origem?: string;
 }
 
 export interface CriarNotaRequest {
-  numero: string;
+  serie?: number;
+  importacao?: boolean;
+  numero?: string;
+  emitenteId?: string;
+  clienteId?: string;
 }
 
 export interface AdicionarItemRequest {
   produtoId: string;
-  quantidade: number;
-  precoUnitario: number;
+  quantidade: number | string;
+  precoUnitario: number | string;
+  origem?: string;
 }
@@ -1,7 +1,7 @@
 import { Injectable, inject } from '@angular/core';
 import { HttpClient, HttpHeaders } from '@angular/common/http';
 import { Observable } from 'rxjs';
-import { NotaFiscal, CriarNotaRequest, AdicionarItemRequest, ItemNota } from '../models/nota-fiscal.model';
+import { NotaFiscal, CriarNotaRequest, AdicionarItemRequest, ItemNota, CartaCorrecao } from '../models/nota-fiscal.model';
 import { SolicitacaoImpressao, ImprimirNotaResponse } from '../models/solicitacao-impressao.model';
 import { environment } from '../../../environments/environment';
 
@@ -49,4 +49,16 @@ export class NotaFiscalService {
   fecharNota(notaId: string): Observable<{mensagem: string}> {
     return this.http.put<{mensagem: string}>(`${this.baseUrl}/${notaId}/fechar`, {});
   }
+
+  cancelarNota(notaId: string, justificativa: string): Observable<NotaFiscal> {
+    return this.http.post<NotaFiscal>(`${this.baseUrl}/${notaId}/cancelar`, { justificativa });
+  }
+
+  listarCartasCorrecao(notaId: string): Observable<CartaCorrecao[]> {
+    return this.http.get<CartaCorrecao[]>(`${this.baseUrl}/${notaId}/correcoes`);
+  }
+
+  registrarCartaCorrecao(notaId: string, correcao: string): Observable<CartaCorrecao> {
+    return this.http.post<CartaCorrecao>(`${this.baseUrl}/${notaId}/correcoes`, { correcao });
+  }
 }
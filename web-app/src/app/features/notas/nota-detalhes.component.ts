@@ -7,7 +7,7 @@ import { finalize } from 'rxjs/operators';
 import { NotaFiscalService } from '../../core/services/nota-fiscal.service';
 import { ProdutoService } from '../../core/services/produto.service';
 import { IdempotenciaService } from '../../core/services/idempotencia.service';
-import { NotaFiscal, AdicionarItemRequest } from '../../core/models/nota-fiscal.model';
+import { NotaFiscal, ItemNota, AdicionarItemRequest } from '../../core/models/nota-fiscal.model';
 import { Produto } from '../../core/models/produto.model';
 
 @Component({
@@ -47,7 +47,9 @@ import { Produto } from '../../core/models/produto.model';
             <span class="tag self-start"
                   [ngClass]="{
                     'bg-yellow-100 text-yellow-800': nota()!.status === 'ABERTA',
-                    'bg-green-100 text-green-800': nota()!.status === 'FECHADA'
+                    'bg-green-100 text-green-800': nota()!.status === 'FECHADA',
+                    'bg-emerald-100 text-emerald-800': nota()!.status === 'AUTORIZADA',
+                    'bg-red-100 text-red-800': nota()!.status === 'CANCELADA' || nota()!.status === 'REJEITADA' || nota()!.status === 'DENEGADA'
                   }">
               {{ nota()!.status }}
             </span>
@@ -58,6 +60,24 @@ import { Produto } from '../../core/models/produto.model';
               Fechada em {{ nota()!.dataFechada | date:'dd/MM/yyyy HH:mm' }}
             </div>
           }
+
+          @if (nota()!.protocoloAutorizacao) {
+            <div class="text-sm text-gray-600 mt-2">
+              Protocolo {{ nota()!.protocoloAutorizacao }} em {{ nota()!.dataAutorizacao | date:'dd/MM/yyyy HH:mm' }}
+            </div>
+          }
+
+          @if (nota()!.cStat && nota()!.status !== 'AUTORIZADA') {
+            <div class="text-sm text-red-700 mt-2">
+              SEFAZ {{ nota()!.cStat }}: {{ nota()!.xMotivo }}
+            </div>
+          }
+
+          @if (nota()!.dataCancelamento) {
+            <div class="text-sm text-red-700 mt-2">
+              Cancelada em {{ nota()!.dataCancelamento | date:'dd/MM/yyyy HH:mm' }}: {{ nota()!.justificativaCancelamento }}
+            </div>
+          }
         </div>
 
         @if (statusImpressao() === 'aguardando') {
@@ -118,7 +138,7 @@ import { Produto } from '../../core/models/produto.model';
                       <td class="p-3 text-right">{{ item.quantidade }}</td>
                       <td class="p-3 text-right">R$ {{ item.precoUnitario | number:'1.2-2' }}</td>
                       <td class="p-3 text-right font-medium">
-                        R$ {{ (item.quantidade * item.precoUnitario) | number:'1.2-2' }}
+                        R$ {{ calcularSubtotal(item) | number:'1.2-2' }}
                       </td>
                     </tr>
                   }
@@ -157,7 +177,7 @@ import { Produto } from '../../core/models/produto.model';
                 </div>
                 <div>
                   <label class="block text-sm font-medium text-gray-700 mb-1">Quantidade</label>
-                  <input type="number" min="1" class="input-field"
+                  <input type="number" min="0.0001" step="0.0001" class="input-field"
                          name="quantidade"
                          required
                          [(ngModel)]="novoItem.quantidade" />
@@ -427,8 +447,18 @@ export class NotaDetalhesComponent implements OnInit, OnDestroy {
     });
   }
 
+  // valores chegam como strings decimais; o subtotal é arredondado para centavos como no servidor
+  calcularSubtotal(item: ItemNota): number {
+    return Math.round(Number(item.quantidade) * Number(item.precoUnitario) * 100) / 100;
+  }
+
   calcularTotal(): number {
+    // vNF calculado pelo servidor inclui o IPI dos itens
+    const totais = this.nota()?.totais;
+    if (totais) {
+      return Number(totais.vNF);
+    }
     const itens = this.nota()?.itens || [];
-    return itens.reduce((total, item) => total + (item.quantidade * item.precoUnitario), 0);
+    return itens.reduce((total, item) => total + this.calcularSubtotal(item), 0);
   }
 }
@@ -14,16 +14,17 @@ import { CriarNotaRequest } from '../../core/models/nota-fiscal.model';
       
       <form (ngSubmit)="onSubmit()" #form="ngForm">
         <div>
-          <label class="block text-sm font-medium text-gray-700 mb-1">Número da Nota</label>
+          <label class="block text-sm font-medium text-gray-700 mb-1">Série</label>
           <input
-            type="text"
-            [(ngModel)]="formulario.numero"
-            name="numero"
-            required
-            maxlength="50"
+            type="number"
+            [(ngModel)]="formulario.serie"
+            name="serie"
+            min="0"
+            max="999"
             class="input-field"
-            placeholder="NFE-001"
+            placeholder="Padrão do emitente"
           />
+          <p class="text-xs text-gray-500 mt-1">O número da nota é atribuído automaticamente na série.</p>
         </div>
 
         @if (erro()) {
@@ -57,7 +58,7 @@ export class NotaFormComponent {
   @Output() notaCriada = new EventEmitter<void>();
   @Output() cancelar = new EventEmitter<void>();
 
-  formulario: CriarNotaRequest = { numero: '' };
+  formulario: CriarNotaRequest = {};
   salvando = signal(false);
   erro = signal<string | null>(null);
 
@@ -69,7 +70,7 @@ export class NotaFormComponent {
       next: () => {
         this.salvando.set(false);
         this.notaCriada.emit();
-        this.formulario.numero = '';
+        this.formulario = {};
       },
       error: (err) => {
         this.salvando.set(false);
@@ -84,7 +84,11 @@ import { NotaFormComponent } from './nota-form.component';
                           [class.bg-yellow-100]="nota.status === 'ABERTA'"
                           [class.text-yellow-800]="nota.status === 'ABERTA'"
                           [class.bg-green-100]="nota.status === 'FECHADA'"
-                          [class.text-green-800]="nota.status === 'FECHADA'">
+                          [class.text-green-800]="nota.status === 'FECHADA'"
+                          [class.bg-emerald-100]="nota.status === 'AUTORIZADA'"
+                          [class.text-emerald-800]="nota.status === 'AUTORIZADA'"
+                          [class.bg-red-100]="nota.status === 'CANCELADA' || nota.status === 'REJEITADA' || nota.status === 'DENEGADA'"
+                          [class.text-red-800]="nota.status === 'CANCELADA' || nota.status === 'REJEITADA' || nota.status === 'DENEGADA'">
                       {{ nota.status }}
                     </span>
                   </div>